@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/host-operator/pkg/apis"
+	"github.com/codeready-toolchain/host-operator/pkg/templates/assets"
+	"github.com/codeready-toolchain/host-operator/pkg/templates/nstemplatetiers"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+)
+
+// tier-diff shows the changes of the objects provisioned on the member clusters between two revisions of a tier:
+// - between two TierTemplates of the host cluster: `tier-diff -from basic-dev-abcd123-abcd123 -to basic-dev-1234567-1234567`
+// - between the current NSTemplateTier of the host cluster and the templates on disk: `tier-diff -tier basic`
+func main() {
+	var namespace, tierName, from, to, templatesDir, output string
+	params := paramsFlag{}
+	flag.StringVar(&namespace, "namespace", "toolchain-host-operator", "the namespace of the NSTemplateTiers and TierTemplates in the host cluster")
+	flag.StringVar(&tierName, "tier", "", "the name of the NSTemplateTier to compare with the templates on disk")
+	flag.StringVar(&templatesDir, "templates", "deploy/templates/nstemplatetiers", "the directory of the tier templates on disk")
+	flag.StringVar(&from, "from", "", "the name of the TierTemplate to compare from")
+	flag.StringVar(&to, "to", "", "the name of the TierTemplate to compare to")
+	flag.StringVar(&output, "output", "text", "the output format: 'text' or 'json'")
+	flag.Var(params, "param", "a template parameter in the KEY=VALUE form (can be repeated)")
+	flag.Parse()
+
+	if err := run(namespace, tierName, from, to, templatesDir, output, params); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(namespace, tierName, from, to, templatesDir, output string, params map[string]string) error {
+	if output != "text" && output != "json" {
+		return fmt.Errorf("invalid output format: '%s'", output)
+	}
+	s := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(s))
+	utilruntime.Must(apis.AddToScheme(s))
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return errors.Wrap(err, "unable to get the config of the host cluster")
+	}
+	cl, err := client.New(cfg, client.Options{Scheme: s})
+	if err != nil {
+		return errors.Wrap(err, "unable to create a client for the host cluster")
+	}
+
+	var fromTmpls, toTmpls []*toolchainv1alpha1.TierTemplate
+	switch {
+	case from != "" && to != "" && tierName == "":
+		fromTmpl := &toolchainv1alpha1.TierTemplate{}
+		if err := cl.Get(context.TODO(), client.ObjectKey{Namespace: namespace, Name: from}, fromTmpl); err != nil {
+			return errors.Wrapf(err, "unable to get the '%s' TierTemplate", from)
+		}
+		toTmpl := &toolchainv1alpha1.TierTemplate{}
+		if err := cl.Get(context.TODO(), client.ObjectKey{Namespace: namespace, Name: to}, toTmpl); err != nil {
+			return errors.Wrapf(err, "unable to get the '%s' TierTemplate", to)
+		}
+		fromTmpls = []*toolchainv1alpha1.TierTemplate{fromTmpl}
+		toTmpls = []*toolchainv1alpha1.TierTemplate{toTmpl}
+	case tierName != "" && from == "" && to == "":
+		tier := &toolchainv1alpha1.NSTemplateTier{}
+		if err := cl.Get(context.TODO(), client.ObjectKey{Namespace: namespace, Name: tierName}, tier); err != nil {
+			return errors.Wrapf(err, "unable to get the '%s' NSTemplateTier", tierName)
+		}
+		if fromTmpls, err = nstemplatetiers.GetTierTemplates(cl, tier); err != nil {
+			return err
+		}
+		dirAssets, err := assets.NewDirAssets(templatesDir)
+		if err != nil {
+			return err
+		}
+		if toTmpls, err = nstemplatetiers.GenerateTierTemplates(s, namespace, dirAssets, tierName); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("either the '-tier' flag or both the '-from' and '-to' flags must be set")
+	}
+
+	diff, err := nstemplatetiers.DiffTierTemplates(s, fromTmpls, toTmpls, params)
+	if err != nil {
+		return err
+	}
+	if output == "json" {
+		result, err := json.MarshalIndent(diff, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "unable to marshal the diff")
+		}
+		fmt.Println(string(result))
+		return nil
+	}
+	fmt.Print(diff.Text())
+	return nil
+}
+
+// paramsFlag the template parameters set with the repeatable `-param KEY=VALUE` flag
+type paramsFlag map[string]string
+
+func (p paramsFlag) String() string {
+	params := make([]string, 0, len(p))
+	for key, value := range p {
+		params = append(params, key+"="+value)
+	}
+	return strings.Join(params, ",")
+}
+
+func (p paramsFlag) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return fmt.Errorf("invalid parameter '%s', expected KEY=VALUE", value)
+	}
+	p[parts[0]] = parts[1]
+	return nil
+}
@@ -6,9 +6,11 @@ import (
 	"time"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	notify "github.com/codeready-toolchain/host-operator/controllers/notification"
 	tierutil "github.com/codeready-toolchain/host-operator/controllers/nstemplatetier/util"
 	"github.com/codeready-toolchain/host-operator/controllers/toolchainconfig"
 	"github.com/codeready-toolchain/host-operator/controllers/usersignup"
+	"github.com/codeready-toolchain/host-operator/pkg/templates/notificationtemplates"
 	"github.com/codeready-toolchain/toolchain-common/pkg/condition"
 	"github.com/codeready-toolchain/toolchain-common/pkg/states"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
@@ -19,6 +21,7 @@ import (
 	errs "github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -27,6 +30,29 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
+const (
+	// NotificationTypeTierChanged is the type of the notifications sent to the users whose tier was changed by a ChangeTierRequest
+	NotificationTypeTierChanged = notify.NotificationTypeTierChanged
+
+	// PreviousTierAnnotationKey is the key of the annotation of a ChangeTierRequest holding the name of the tier of the MasterUserRecord
+	// before the change, which is mentioned in the notification sent to the user
+	PreviousTierAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "previous-tier"
+
+	// ChangeTierRequestLabelKey is the key of the label of a tier changed notification holding the name of the ChangeTierRequest
+	// it was created for, so that a single notification is sent per ChangeTierRequest
+	ChangeTierRequestLabelKey = toolchainv1alpha1.LabelKeyPrefix + "changetierrequest"
+
+	// TierChangedNotificationCreated is the type of the condition of a ChangeTierRequest reporting whether the notification
+	// informing the user of the change of tier was created
+	TierChangedNotificationCreated toolchainv1alpha1.ConditionType = "TierChangedNotificationCreated"
+
+	// TierChangedNotificationCRCreatedReason is the reason of the `TierChangedNotificationCreated=True` condition
+	TierChangedNotificationCRCreatedReason = "NotificationCRCreated"
+	// TierChangedNotificationCRCreationFailedReason is the reason of the `TierChangedNotificationCreated=False` condition
+	// when the notification could not be created
+	TierChangedNotificationCRCreationFailedReason = "NotificationCRCreationFailed"
+)
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *Reconciler) SetupWithManager(mgr manager.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
@@ -87,10 +113,25 @@ func (r *Reconciler) Reconcile(ctx context.Context, request ctrl.Request) (ctrl.
 		}, nil
 	}
 
-	if err = r.changeTier(reqLogger, changeTierRequest, request.Namespace); err != nil {
+	userSignup, err := r.changeTier(reqLogger, changeTierRequest, request.Namespace)
+	if err != nil {
 		return reconcile.Result{}, err
 	}
 
+	// notify the user, unless the MasterUserRecord was already in the requested tier
+	previousTier := changeTierRequest.Annotations[PreviousTierAnnotationKey]
+	if userSignup != nil && previousTier != changeTierRequest.Spec.TierName &&
+		condition.IsNotTrue(changeTierRequest.Status.Conditions, TierChangedNotificationCreated) {
+		if err := r.sendTierChangedNotification(reqLogger, config, changeTierRequest, userSignup); err != nil {
+			return reconcile.Result{}, r.wrapErrorWithStatusUpdate(reqLogger, changeTierRequest, r.setStatusTierChangedNotificationCreationFailed, err,
+				"failed to create the tier changed notification")
+		}
+		if err := r.setStatusTierChangedNotificationCreated(changeTierRequest); err != nil {
+			reqLogger.Error(err, "unable to set notification created status to ChangeTierRequest")
+			return reconcile.Result{}, err
+		}
+	}
+
 	reqLogger.Info("Change of the tier is completed")
 	if err = r.setStatusChangeComplete(changeTierRequest); err != nil {
 		reqLogger.Error(err, "unable to set change complete status to ChangeTierRequest")
@@ -126,52 +167,73 @@ func (r *Reconciler) checkTransitionTimeAndDelete(logger logr.Logger, durationBe
 }
 
 // changeTier changes the Tier in the MasterUserRecord and then in the Space (with the same name as the MasterUserRecord)
+// and returns the UserSignup owning the MasterUserRecord (nil if there is no such MasterUserRecord)
 // If an error occurs while updating the MasterUserRecord, then the controller will "exit" the reconcile loop, and the request
 // will be requeued.
 // If an error occurs while updating the Space, then the controller will "exit" the reconcile loop, and the request
 // will be requeued, and the MasterUserRecord that was already updated during the previous reconcile loop will remain unchanged afterwards
-func (r *Reconciler) changeTier(logger logr.Logger, changeTierRequest *toolchainv1alpha1.ChangeTierRequest, namespace string) error {
+func (r *Reconciler) changeTier(logger logr.Logger, changeTierRequest *toolchainv1alpha1.ChangeTierRequest, namespace string) (*toolchainv1alpha1.UserSignup, error) {
 	nsTemplateTier := &toolchainv1alpha1.NSTemplateTier{}
 	tierName := types.NamespacedName{Namespace: namespace, Name: changeTierRequest.Spec.TierName}
 	if err := r.Client.Get(context.TODO(), tierName, nsTemplateTier); err != nil {
-		return r.wrapErrorWithStatusUpdate(logger, changeTierRequest, r.setStatusChangeFailed, err, "unable to get NSTemplateTier with name %s", changeTierRequest.Spec.TierName)
+		return nil, r.wrapErrorWithStatusUpdate(logger, changeTierRequest, r.setStatusChangeFailed, err, "unable to get NSTemplateTier with name %s", changeTierRequest.Spec.TierName)
+	}
+	// a deprecated tier is no longer assigned
+	if successor, deprecated := tierutil.SuccessorOf(nsTemplateTier); deprecated {
+		err := fmt.Errorf("the NSTemplateTier '%s' is deprecated in favour of the '%s' tier", nsTemplateTier.Name, successor)
+		return nil, r.wrapErrorWithStatusUpdate(logger, changeTierRequest, r.setStatusChangeFailed, err, "unable to change the tier to %s", changeTierRequest.Spec.TierName)
 	}
 
 	// apply the change in MasterUserRecord
-	murUpdated, err := r.changeTierInMasterUserRecord(logger, changeTierRequest, namespace, nsTemplateTier)
+	userSignup, err := r.changeTierInMasterUserRecord(logger, changeTierRequest, namespace, nsTemplateTier)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	// then apply the change in Space
-	spaceUpdated, err := r.changeTierInSpace(logger, changeTierRequest, namespace)
+	spaceUpdated, err := r.changeTierInSpace(logger, changeTierRequest, namespace, nsTemplateTier)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	// if neither MUR nor Space was updated, then return an error
-	if !murUpdated && !spaceUpdated {
+	if userSignup == nil && !spaceUpdated {
 		cause := fmt.Errorf("no MasterUserRecord nor Space named '%s' matching the ChangeTierRequest", changeTierRequest.Spec.MurName)
 		if err := r.setStatusChangeFailed(changeTierRequest, cause.Error()); err != nil {
-			return err
+			return nil, err
 		}
-		return cause
+		return nil, cause
 	}
-	return nil
+	return userSignup, nil
 }
 
-// changeTierInMasterUserRecord changes the tier in the MasterUserRecord.
-// returns `false` if there was no MasterUserRecord matching the `changeTierRequest.Spec.MurName`.
-func (r *Reconciler) changeTierInMasterUserRecord(logger logr.Logger, changeTierRequest *toolchainv1alpha1.ChangeTierRequest, namespace string, nsTemplateTier *toolchainv1alpha1.NSTemplateTier) (bool, error) {
+// changeTierInMasterUserRecord changes the tier in the MasterUserRecord and returns the UserSignup owning the MasterUserRecord.
+// returns `nil` if there was no MasterUserRecord matching the `changeTierRequest.Spec.MurName`.
+func (r *Reconciler) changeTierInMasterUserRecord(logger logr.Logger, changeTierRequest *toolchainv1alpha1.ChangeTierRequest, namespace string, nsTemplateTier *toolchainv1alpha1.NSTemplateTier) (*toolchainv1alpha1.UserSignup, error) {
 	mur := &toolchainv1alpha1.MasterUserRecord{}
 	murName := types.NamespacedName{Namespace: namespace, Name: changeTierRequest.Spec.MurName}
 	if err := r.Client.Get(context.TODO(), murName, mur); err != nil {
 		if errors.IsNotFound(err) {
 			logger.Info("No MasterUserRecord found for ChangeTierRequest")
-			return false, nil
+			return nil, nil
+		}
+		return nil, r.wrapErrorWithStatusUpdate(logger, changeTierRequest, r.setStatusChangeFailed, err, "unable to get MasterUserRecord with name %s", changeTierRequest.Spec.MurName)
+	}
+
+	// record the tier before the change (only once, since the MasterUserRecord is already in the new tier if the request is retried)
+	if _, found := changeTierRequest.Annotations[PreviousTierAnnotationKey]; !found {
+		if changeTierRequest.Annotations == nil {
+			changeTierRequest.Annotations = map[string]string{}
+		}
+		changeTierRequest.Annotations[PreviousTierAnnotationKey] = mur.Spec.TierName
+		if err := r.Client.Update(context.TODO(), changeTierRequest); err != nil {
+			return nil, r.wrapErrorWithStatusUpdate(logger, changeTierRequest, r.setStatusChangeFailed, err, "unable to record the previous tier of MasterUserRecord %s", changeTierRequest.Spec.MurName)
 		}
-		return false, r.wrapErrorWithStatusUpdate(logger, changeTierRequest, r.setStatusChangeFailed, err, "unable to get MasterUserRecord with name %s", changeTierRequest.Spec.MurName)
 	}
 
 	newNsTemplateSet := usersignup.NewNSTemplateSetSpec(nsTemplateTier)
+	// the template parameter overrides of the MasterUserRecord are preserved, so they must be valid for the new tier
+	if err := validateParameterOverrides(r.Client, mur, namespace, *newNsTemplateSet); err != nil {
+		return nil, r.wrapErrorWithStatusUpdate(logger, changeTierRequest, r.setStatusChangeFailed, err, "unable to change tier in MasterUserRecord %s", changeTierRequest.Spec.MurName)
+	}
 	changed := false
 
 	for i, ua := range mur.Spec.UserAccounts {
@@ -196,7 +258,7 @@ func (r *Reconciler) changeTierInMasterUserRecord(logger logr.Logger, changeTier
 	}
 	if !changed {
 		err := fmt.Errorf("the MasterUserRecord '%s' doesn't contain UserAccount with cluster '%s' whose tier should be changed", changeTierRequest.Spec.MurName, changeTierRequest.Spec.TargetCluster)
-		return false, r.wrapErrorWithStatusUpdate(logger, changeTierRequest, r.setStatusChangeFailed, err, "unable to change tier in MasterUserRecord %s", changeTierRequest.Spec.MurName)
+		return nil, r.wrapErrorWithStatusUpdate(logger, changeTierRequest, r.setStatusChangeFailed, err, "unable to change tier in MasterUserRecord %s", changeTierRequest.Spec.MurName)
 	}
 
 	mur.Spec.TierName = changeTierRequest.Spec.TierName
@@ -211,39 +273,39 @@ func (r *Reconciler) changeTierInMasterUserRecord(logger logr.Logger, changeTier
 		if ua.Spec.NSTemplateSet == nil {
 			continue
 		}
-		hash, err := tierutil.ComputeHashForNSTemplateSetSpec(*ua.Spec.NSTemplateSet)
+		hash, err := tierutil.ComputeHashForNSTemplateSet(nsTemplateTier, *ua.Spec.NSTemplateSet)
 		if err != nil {
-			return false, r.wrapErrorWithStatusUpdate(logger, changeTierRequest, r.setStatusChangeFailed, err, "unable to compute hash for NSTemplateTier with name '%s'", nsTemplateTier.Name)
+			return nil, r.wrapErrorWithStatusUpdate(logger, changeTierRequest, r.setStatusChangeFailed, err, "unable to compute hash for NSTemplateTier with name '%s'", nsTemplateTier.Name)
 		}
 		mur.Labels[tierutil.TemplateTierHashLabelKey(ua.Spec.NSTemplateSet.TierName)] = hash
 	}
 	if err := r.Client.Update(context.TODO(), mur); err != nil {
-		return false, r.wrapErrorWithStatusUpdate(logger, changeTierRequest, r.setStatusChangeFailed, err, "unable to change tier in MasterUserRecord %s", changeTierRequest.Spec.MurName)
+		return nil, r.wrapErrorWithStatusUpdate(logger, changeTierRequest, r.setStatusChangeFailed, err, "unable to change tier in MasterUserRecord %s", changeTierRequest.Spec.MurName)
 	}
 
 	// get the corresponding UserSignup and set the deactivating state to false to prevent the user from being deactivated prematurely
 	userSignupName, found := mur.Labels[toolchainv1alpha1.MasterUserRecordOwnerLabelKey]
 	if !found || userSignupName == "" {
 		err := fmt.Errorf(`MasterUserRecord is missing label '%s'`, toolchainv1alpha1.MasterUserRecordOwnerLabelKey)
-		return false, r.wrapErrorWithStatusUpdate(logger, changeTierRequest, r.setStatusChangeFailed, err, `failed to get corresponding UserSignup for MasterUserRecord with name '%s'`, changeTierRequest.Spec.MurName)
+		return nil, r.wrapErrorWithStatusUpdate(logger, changeTierRequest, r.setStatusChangeFailed, err, `failed to get corresponding UserSignup for MasterUserRecord with name '%s'`, changeTierRequest.Spec.MurName)
 	}
 	userSignupToUpdate := &toolchainv1alpha1.UserSignup{}
 	if err := r.Client.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: userSignupName}, userSignupToUpdate); err != nil {
-		return false, r.wrapErrorWithStatusUpdate(logger, changeTierRequest, r.setStatusChangeFailed, err, `failed to get UserSignup '%s'`, userSignupName)
+		return nil, r.wrapErrorWithStatusUpdate(logger, changeTierRequest, r.setStatusChangeFailed, err, `failed to get UserSignup '%s'`, userSignupName)
 	}
 	if states.Deactivating(userSignupToUpdate) {
 		states.SetDeactivating(userSignupToUpdate, false)
 		if err := r.Client.Update(context.TODO(), userSignupToUpdate); err != nil {
-			return false, r.wrapErrorWithStatusUpdate(logger, changeTierRequest, r.setStatusChangeFailed, err, `failed to reset deactivating state for UserSignup '%s'`, userSignupName)
+			return nil, r.wrapErrorWithStatusUpdate(logger, changeTierRequest, r.setStatusChangeFailed, err, `failed to reset deactivating state for UserSignup '%s'`, userSignupName)
 		}
 	}
 
-	return true, nil
+	return userSignupToUpdate, nil
 }
 
 // changeTierInSpace changes the tier in the Space.
 // returns `false` if there was no Space matching the `changeTierRequest.Spec.MurName`.
-func (r *Reconciler) changeTierInSpace(logger logr.Logger, changeTierRequest *toolchainv1alpha1.ChangeTierRequest, namespace string) (bool, error) {
+func (r *Reconciler) changeTierInSpace(logger logr.Logger, changeTierRequest *toolchainv1alpha1.ChangeTierRequest, namespace string, nsTemplateTier *toolchainv1alpha1.NSTemplateTier) (bool, error) {
 	space := &toolchainv1alpha1.Space{}
 	if err := r.Client.Get(context.TODO(), types.NamespacedName{
 		Namespace: namespace,
@@ -260,6 +322,11 @@ func (r *Reconciler) changeTierInSpace(logger logr.Logger, changeTierRequest *to
 		return true, nil // here we consider that the Space was processed, even though there was no update. But the ChangeTierRequest controller will not return an error.
 	}
 
+	// the template parameter overrides of the Space are preserved, so they must be valid for the new tier
+	if err := validateParameterOverrides(r.Client, space, namespace, *usersignup.NewNSTemplateSetSpec(nsTemplateTier)); err != nil {
+		return false, r.wrapErrorWithStatusUpdate(logger, changeTierRequest, r.setStatusChangeFailed, err, "unable to change tier in Space %s", changeTierRequest.Spec.MurName)
+	}
+
 	// remove the TemplateTierHash label on the Space resource (and let the SpaceController set it to the latest value)
 	delete(space.Labels, tierutil.TemplateTierHashLabelKey(space.Spec.TierName))
 	// set the new TierName
@@ -272,6 +339,55 @@ func (r *Reconciler) changeTierInSpace(logger logr.Logger, changeTierRequest *to
 	return true, nil
 }
 
+// validateParameterOverrides checks that the template parameter overrides of the given MasterUserRecord or Space are declared
+// by the templates of the given NSTemplateSet spec
+func validateParameterOverrides(cl client.Client, obj metav1.Object, namespace string, spec toolchainv1alpha1.NSTemplateSetSpec) error {
+	overrides, err := tierutil.GetParameterOverrides(obj)
+	if err != nil {
+		return err
+	}
+	return tierutil.ValidateParameterOverrides(cl, namespace, spec, overrides)
+}
+
+// sendTierChangedNotification creates the notification informing the owner of the given UserSignup that the tier was changed
+func (r *Reconciler) sendTierChangedNotification(logger logr.Logger, config toolchainconfig.ToolchainConfig, changeTierRequest *toolchainv1alpha1.ChangeTierRequest,
+	userSignup *toolchainv1alpha1.UserSignup) error {
+	labels := map[string]string{
+		toolchainv1alpha1.NotificationUserNameLabelKey: userSignup.Status.CompliantUsername,
+		toolchainv1alpha1.NotificationTypeLabelKey:     NotificationTypeTierChanged,
+		ChangeTierRequestLabelKey:                      changeTierRequest.Name,
+	}
+	notificationList := &toolchainv1alpha1.NotificationList{}
+	if err := r.Client.List(context.TODO(), notificationList, client.InNamespace(userSignup.Namespace), client.MatchingLabels(labels)); err != nil {
+		return err
+	}
+	// the notification may have been created during a previous reconcile whose status update failed
+	if len(notificationList.Items) > 0 {
+		logger.Info("Tier changed notification resource already exists", "name", notificationList.Items[0].Name)
+		return nil
+	}
+
+	keysAndVals := map[string]string{
+		toolchainconfig.NotificationContextRegistrationURLKey: config.RegistrationService().RegistrationServiceURL(),
+		toolchainconfig.NotificationContextSupportURLKey:      config.Notifications().SupportURL(),
+		"OldTierName": changeTierRequest.Annotations[PreviousTierAnnotationKey],
+		"NewTierName": changeTierRequest.Spec.TierName,
+	}
+	notification, err := notify.NewNotificationBuilder(r.Client, userSignup.Namespace).
+		WithTemplate(notificationtemplates.TierChanged.Name).
+		WithNotificationType(NotificationTypeTierChanged).
+		WithControllerReference(userSignup, r.Scheme).
+		WithUserContext(userSignup).
+		WithKeysAndValues(keysAndVals).
+		WithLabel(ChangeTierRequestLabelKey, changeTierRequest.Name).
+		Create(userSignup.Annotations[toolchainv1alpha1.UserSignupUserEmailAnnotationKey])
+	if err != nil {
+		return err
+	}
+	logger.Info(fmt.Sprintf("Tier changed notification resource [%s] created", notification.Name))
+	return nil
+}
+
 func (r *Reconciler) wrapErrorWithStatusUpdate(logger logr.Logger, changeRequest *toolchainv1alpha1.ChangeTierRequest, statusUpdater func(changeRequest *toolchainv1alpha1.ChangeTierRequest, message string) error, err error, format string, args ...interface{}) error {
 	if err == nil {
 		return nil
@@ -313,6 +429,27 @@ func (r *Reconciler) setStatusChangeFailed(changeRequest *toolchainv1alpha1.Chan
 		})
 }
 
+func (r *Reconciler) setStatusTierChangedNotificationCreated(changeRequest *toolchainv1alpha1.ChangeTierRequest) error {
+	return r.updateStatusConditions(
+		changeRequest,
+		toolchainv1alpha1.Condition{
+			Type:   TierChangedNotificationCreated,
+			Status: corev1.ConditionTrue,
+			Reason: TierChangedNotificationCRCreatedReason,
+		})
+}
+
+func (r *Reconciler) setStatusTierChangedNotificationCreationFailed(changeRequest *toolchainv1alpha1.ChangeTierRequest, message string) error {
+	return r.updateStatusConditions(
+		changeRequest,
+		toolchainv1alpha1.Condition{
+			Type:    TierChangedNotificationCreated,
+			Status:  corev1.ConditionFalse,
+			Reason:  TierChangedNotificationCRCreationFailedReason,
+			Message: message,
+		})
+}
+
 func (r *Reconciler) setStatusChangeTierRequestDeletionFailed(changeRequest *toolchainv1alpha1.ChangeTierRequest, message string) error {
 	return r.updateStatusConditions(
 		changeRequest,
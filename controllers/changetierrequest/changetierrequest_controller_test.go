@@ -13,6 +13,8 @@ import (
 	"github.com/codeready-toolchain/host-operator/pkg/apis"
 	"github.com/codeready-toolchain/host-operator/pkg/templates/nstemplatetiers"
 	. "github.com/codeready-toolchain/host-operator/test"
+	hostmurtest "github.com/codeready-toolchain/host-operator/test/masteruserrecord"
+	tiertest "github.com/codeready-toolchain/host-operator/test/nstemplatetier"
 	spacetest "github.com/codeready-toolchain/host-operator/test/space"
 	commonconfig "github.com/codeready-toolchain/toolchain-common/pkg/configuration"
 	"github.com/codeready-toolchain/toolchain-common/pkg/states"
@@ -56,9 +58,10 @@ func TestChangeTierSuccess(t *testing.T) {
 		require.NoError(t, err)
 		murtest.AssertThatMasterUserRecord(t, "john", cl).
 			HasTier(*teamTier).
-			AllUserAccountsHaveTier(*teamTier).
 			DoesNotHaveLabel(tierutil.TemplateTierHashLabelKey(murtest.DefaultNSTemplateTierName))
-		AssertThatChangeTierRequestHasCondition(t, cl, changeTierRequest.Name, toBeComplete())
+		hostmurtest.AssertThatMasterUserRecord(t, "john", cl).
+			AllUserAccountsHaveTier(teamTier)
+		AssertThatChangeTierRequestHasCondition(t, cl, changeTierRequest.Name, toBeComplete(), toHaveTierChangedNotificationCreated())
 	})
 
 	t.Run("should update tier in all UserAccounts in MUR", func(t *testing.T) {
@@ -74,9 +77,10 @@ func TestChangeTierSuccess(t *testing.T) {
 		require.NoError(t, err)
 		murtest.AssertThatMasterUserRecord(t, "johny", cl).
 			HasTier(*teamTier).
-			AllUserAccountsHaveTier(*teamTier).
 			DoesNotHaveLabel(tierutil.TemplateTierHashLabelKey(murtest.DefaultNSTemplateTierName))
-		AssertThatChangeTierRequestHasCondition(t, cl, changeTierRequest.Name, toBeComplete())
+		hostmurtest.AssertThatMasterUserRecord(t, "johny", cl).
+			AllUserAccountsHaveTier(teamTier)
+		AssertThatChangeTierRequestHasCondition(t, cl, changeTierRequest.Name, toBeComplete(), toHaveTierChangedNotificationCreated())
 	})
 
 	t.Run("should update tier only in specified UserAccount in MUR", func(t *testing.T) {
@@ -90,10 +94,11 @@ func TestChangeTierSuccess(t *testing.T) {
 
 		// then
 		require.NoError(t, err)
-		murtest.AssertThatMasterUserRecord(t, "johny", cl).
-			UserAccountHasTier("another-cluster", *teamTier).
-			UserAccountHasTier(test.MemberClusterName, murtest.DefaultNSTemplateTier())
-		AssertThatChangeTierRequestHasCondition(t, cl, changeTierRequest.Name, toBeComplete())
+		defaultTier := murtest.DefaultNSTemplateTier()
+		hostmurtest.AssertThatMasterUserRecord(t, "johny", cl).
+			UserAccountHasTier(test.MemberClusterName, &defaultTier).
+			UserAccountHasTier("another-cluster", teamTier)
+		AssertThatChangeTierRequestHasCondition(t, cl, changeTierRequest.Name, toBeComplete(), toHaveTierChangedNotificationCreated())
 	})
 
 	t.Run("completed changetierrequest is requeued with the remaining deletion timeout", func(t *testing.T) {
@@ -224,9 +229,10 @@ func TestChangeTierSuccess(t *testing.T) {
 		// then
 		require.NoError(t, err)
 		murtest.AssertThatMasterUserRecord(t, "johny", cl).
-			UserAccountHasNoTier(test.MemberClusterName). // nothing set since there was no NStemplateSet to begin with
-			UserAccountHasTier("another-cluster", *teamTier)
-		AssertThatChangeTierRequestHasCondition(t, cl, changeTierRequest.Name, toBeComplete())
+			UserAccountHasNoTier(test.MemberClusterName) // nothing set since there was no NStemplateSet to begin with
+		hostmurtest.AssertThatMasterUserRecord(t, "johny", cl).
+			UserAccountHasTier("another-cluster", teamTier)
+		AssertThatChangeTierRequestHasCondition(t, cl, changeTierRequest.Name, toBeComplete(), toHaveTierChangedNotificationCreated())
 	})
 
 	t.Run("should also update the Space with the same name", func(t *testing.T) {
@@ -247,14 +253,15 @@ func TestChangeTierSuccess(t *testing.T) {
 			require.NoError(t, err)
 			murtest.AssertThatMasterUserRecord(t, "john", cl).
 				HasTier(*teamTier).
-				AllUserAccountsHaveTier(*teamTier).
 				DoesNotHaveLabel(tierutil.TemplateTierHashLabelKey(murtest.DefaultNSTemplateTierName))
+			hostmurtest.AssertThatMasterUserRecord(t, "john", cl).
+				AllUserAccountsHaveTier(teamTier)
 			spacetest.AssertThatSpace(t, space.Namespace, space.Name, cl).
 				HasTier(teamTier.Name).
 				HasSpecTargetCluster("member-1").                                   // unchanged
 				DoesNotHaveLabel(tierutil.TemplateTierHashLabelKey(basicTierName)). // label for old tier is removed
 				DoesNotHaveLabel(tierutil.TemplateTierHashLabelKey(teamTier.Name))  // label for new tier is not set yet
-			AssertThatChangeTierRequestHasCondition(t, cl, changeTierRequest.Name, toBeComplete())
+			AssertThatChangeTierRequestHasCondition(t, cl, changeTierRequest.Name, toBeComplete(), toHaveTierChangedNotificationCreated())
 		})
 
 		t.Run("when the MasterUserRecord does not exist", func(t *testing.T) {
@@ -295,6 +302,219 @@ func TestChangeTierSuccess(t *testing.T) {
 	})
 }
 
+func TestChangeTierWithParameterOverrides(t *testing.T) {
+	// given
+	logf.SetLogger(zap.New(zap.UseDevMode(true)))
+	config := commonconfig.NewToolchainConfigObjWithReset(t, testconfig.Tiers().DurationBeforeChangeTierRequestDeletion("10s"))
+	teamTier := NewNSTemplateTier("team", "123team", "123clusterteam", "stage", "dev")
+	userSignup := NewUserSignup()
+
+	t.Run("the parameter overrides are preserved", func(t *testing.T) {
+		// given
+		changeTierRequest := newChangeTierRequest("john", teamTier.Name)
+		mur := murtest.NewMasterUserRecord(t, "john", murtest.WithOwnerLabel(userSignup.Name),
+			murtest.WithAnnotation(tierutil.TemplateParametersAnnotationKey, `{"MEMORY_LIMIT":"10Gi"}`))
+		space := spacetest.NewSpace("john", spacetest.WithSpecTargetCluster("member-1"),
+			spacetest.WithAnnotation(tierutil.TemplateParametersAnnotationKey, `{"MEMORY_LIMIT":"10Gi"}`))
+		initObjs := append(tiertest.TierTemplatesFor(teamTier), config, userSignup, mur, space, teamTier)
+		controller, request, cl := newController(t, changeTierRequest, initObjs...)
+
+		// when
+		_, err := controller.Reconcile(context.TODO(), request)
+
+		// then
+		require.NoError(t, err)
+		murtest.AssertThatMasterUserRecord(t, "john", cl).
+			HasTier(*teamTier).
+			HasAnnotationWithValue(tierutil.TemplateParametersAnnotationKey, `{"MEMORY_LIMIT":"10Gi"}`)
+		spacetest.AssertThatSpace(t, space.Namespace, space.Name, cl).
+			HasTier(teamTier.Name).
+			HasAnnotation(tierutil.TemplateParametersAnnotationKey, `{"MEMORY_LIMIT":"10Gi"}`)
+		AssertThatChangeTierRequestHasCondition(t, cl, changeTierRequest.Name, toBeComplete(), toHaveTierChangedNotificationCreated())
+	})
+
+	t.Run("the parameter overrides are not declared by the new tier", func(t *testing.T) {
+		msg := "the template parameter 'CPU_LIMIT' is not declared by the templates of the 'team' tier"
+
+		t.Run("in the MasterUserRecord", func(t *testing.T) {
+			// given
+			changeTierRequest := newChangeTierRequest("john", teamTier.Name)
+			mur := murtest.NewMasterUserRecord(t, "john", murtest.WithOwnerLabel(userSignup.Name),
+				murtest.WithAnnotation(tierutil.TemplateParametersAnnotationKey, `{"CPU_LIMIT":"2"}`))
+			initObjs := append(tiertest.TierTemplatesFor(teamTier), config, userSignup, mur, teamTier)
+			controller, request, cl := newController(t, changeTierRequest, initObjs...)
+
+			// when
+			_, err := controller.Reconcile(context.TODO(), request)
+
+			// then
+			require.EqualError(t, err, "unable to change tier in MasterUserRecord john: "+msg)
+			murtest.AssertThatMasterUserRecord(t, "john", cl).
+				HasTier(murtest.DefaultNSTemplateTier()) // unchanged
+			AssertThatChangeTierRequestHasCondition(t, cl, changeTierRequest.Name, toBeNotComplete(msg))
+		})
+
+		t.Run("in the Space", func(t *testing.T) {
+			// given
+			changeTierRequest := newChangeTierRequest("john", teamTier.Name)
+			space := spacetest.NewSpace("john", spacetest.WithSpecTargetCluster("member-1"), spacetest.WithTierName("basic"),
+				spacetest.WithAnnotation(tierutil.TemplateParametersAnnotationKey, `{"CPU_LIMIT":"2"}`))
+			initObjs := append(tiertest.TierTemplatesFor(teamTier), config, userSignup, space, teamTier)
+			controller, request, cl := newController(t, changeTierRequest, initObjs...)
+
+			// when
+			_, err := controller.Reconcile(context.TODO(), request)
+
+			// then
+			require.EqualError(t, err, "unable to change tier in Space john: "+msg)
+			spacetest.AssertThatSpace(t, space.Namespace, space.Name, cl).
+				HasTier("basic") // unchanged
+			AssertThatChangeTierRequestHasCondition(t, cl, changeTierRequest.Name, toBeNotComplete(msg))
+		})
+	})
+}
+
+func TestChangeTierNotification(t *testing.T) {
+	// given
+	config := commonconfig.NewToolchainConfigObjWithReset(t,
+		testconfig.Tiers().DurationBeforeChangeTierRequestDeletion("10s"),
+		testconfig.RegistrationService().RegistrationServiceURL("https://registration.crt-placeholder.com"))
+	teamTier := NewNSTemplateTier("team", "123team", "123clusterteam", "stage", "dev")
+	userSignup := NewUserSignup()
+
+	t.Run("notification is created with the old and new tier names", func(t *testing.T) {
+		// given
+		mur := murtest.NewMasterUserRecord(t, "john", murtest.WithOwnerLabel(userSignup.Name))
+		changeTierRequest := newChangeTierRequest("john", "team")
+		controller, request, cl := newController(t, changeTierRequest, config, userSignup, mur, teamTier)
+
+		// when
+		_, err := controller.Reconcile(context.TODO(), request)
+
+		// then
+		require.NoError(t, err)
+		AssertThatChangeTierRequestHasCondition(t, cl, changeTierRequest.Name, toBeComplete(), toHaveTierChangedNotificationCreated())
+		notifications := assertTierChangedNotifications(t, cl, 1)
+		assert.Equal(t, "tierchanged", notifications[0].Spec.Template)
+		assert.Equal(t, userSignup.Annotations[toolchainv1alpha1.UserSignupUserEmailAnnotationKey], notifications[0].Spec.Recipient)
+		assert.Equal(t, murtest.DefaultNSTemplateTierName, notifications[0].Spec.Context["OldTierName"])
+		assert.Equal(t, "team", notifications[0].Spec.Context["NewTierName"])
+		assert.Equal(t, "https://developers.redhat.com/developer-sandbox", notifications[0].Spec.Context["SupportURL"])
+		assert.Equal(t, "https://registration.crt-placeholder.com", notifications[0].Spec.Context["RegistrationURL"])
+		assert.Equal(t, changeTierRequest.Name, notifications[0].Labels[ChangeTierRequestLabelKey])
+		require.Len(t, notifications[0].OwnerReferences, 1)
+		assert.Equal(t, userSignup.Name, notifications[0].OwnerReferences[0].Name)
+	})
+
+	t.Run("notification is created with the previous tier name when the change is retried", func(t *testing.T) {
+		// given
+		mur := murtest.NewMasterUserRecord(t, "john", murtest.WithOwnerLabel(userSignup.Name), murtest.TierName("team"))
+		changeTierRequest := newChangeTierRequest("john", "team")
+		changeTierRequest.Annotations = map[string]string{PreviousTierAnnotationKey: "basic"}
+		controller, request, cl := newController(t, changeTierRequest, config, userSignup, mur, teamTier)
+
+		// when
+		_, err := controller.Reconcile(context.TODO(), request)
+
+		// then
+		require.NoError(t, err)
+		notifications := assertTierChangedNotifications(t, cl, 1)
+		assert.Equal(t, "basic", notifications[0].Spec.Context["OldTierName"])
+	})
+
+	t.Run("notification is not created twice", func(t *testing.T) {
+		// given
+		mur := murtest.NewMasterUserRecord(t, "john", murtest.WithOwnerLabel(userSignup.Name))
+		changeTierRequest := newChangeTierRequest("john", "team")
+		changeTierRequest.Annotations = map[string]string{PreviousTierAnnotationKey: "basic"}
+		changeTierRequest.Status.Conditions = []toolchainv1alpha1.Condition{toHaveTierChangedNotificationCreated()}
+		controller, request, cl := newController(t, changeTierRequest, config, userSignup, mur, teamTier)
+
+		// when
+		_, err := controller.Reconcile(context.TODO(), request)
+
+		// then
+		require.NoError(t, err)
+		AssertThatChangeTierRequestHasCondition(t, cl, changeTierRequest.Name, toBeComplete(), toHaveTierChangedNotificationCreated())
+		assertTierChangedNotifications(t, cl, 0)
+	})
+
+	t.Run("notification is not created twice when the condition was not set", func(t *testing.T) {
+		// given
+		mur := murtest.NewMasterUserRecord(t, "john", murtest.WithOwnerLabel(userSignup.Name))
+		changeTierRequest := newChangeTierRequest("john", "team")
+		changeTierRequest.Annotations = map[string]string{PreviousTierAnnotationKey: "basic"}
+		existing := newTierChangedNotification(userSignup, changeTierRequest.Name)
+		controller, request, cl := newController(t, changeTierRequest, config, userSignup, mur, teamTier, existing)
+
+		// when
+		_, err := controller.Reconcile(context.TODO(), request)
+
+		// then
+		require.NoError(t, err)
+		AssertThatChangeTierRequestHasCondition(t, cl, changeTierRequest.Name, toBeComplete(), toHaveTierChangedNotificationCreated())
+		notifications := assertTierChangedNotifications(t, cl, 1)
+		assert.Equal(t, existing.Name, notifications[0].Name)
+	})
+
+	t.Run("notification is created when another change of tier was notified", func(t *testing.T) {
+		// given
+		mur := murtest.NewMasterUserRecord(t, "john", murtest.WithOwnerLabel(userSignup.Name))
+		changeTierRequest := newChangeTierRequest("john", "team")
+		existing := newTierChangedNotification(userSignup, "previous-request-name")
+		controller, request, cl := newController(t, changeTierRequest, config, userSignup, mur, teamTier, existing)
+
+		// when
+		_, err := controller.Reconcile(context.TODO(), request)
+
+		// then
+		require.NoError(t, err)
+		AssertThatChangeTierRequestHasCondition(t, cl, changeTierRequest.Name, toBeComplete(), toHaveTierChangedNotificationCreated())
+		assertTierChangedNotifications(t, cl, 2)
+	})
+
+	t.Run("notification is not created when the tier is unchanged", func(t *testing.T) {
+		// given
+		mur := murtest.NewMasterUserRecord(t, "john", murtest.WithOwnerLabel(userSignup.Name), murtest.TierName("team"))
+		changeTierRequest := newChangeTierRequest("john", "team")
+		controller, request, cl := newController(t, changeTierRequest, config, userSignup, mur, teamTier)
+
+		// when
+		_, err := controller.Reconcile(context.TODO(), request)
+
+		// then
+		require.NoError(t, err)
+		AssertThatChangeTierRequestHasCondition(t, cl, changeTierRequest.Name, toBeComplete())
+		assertTierChangedNotifications(t, cl, 0)
+	})
+
+	t.Run("notification creation fails", func(t *testing.T) {
+		// given
+		mur := murtest.NewMasterUserRecord(t, "john", murtest.WithOwnerLabel(userSignup.Name))
+		changeTierRequest := newChangeTierRequest("john", "team")
+		controller, request, cl := newController(t, changeTierRequest, config, userSignup, mur, teamTier)
+		cl.MockCreate = func(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+			if _, ok := obj.(*toolchainv1alpha1.Notification); ok {
+				return fmt.Errorf("unable to create notification")
+			}
+			return cl.Client.Create(ctx, obj, opts...)
+		}
+
+		// when
+		_, err := controller.Reconcile(context.TODO(), request)
+
+		// then
+		require.EqualError(t, err, "failed to create the tier changed notification: unable to create notification")
+		AssertThatChangeTierRequestHasCondition(t, cl, changeTierRequest.Name, toolchainv1alpha1.Condition{
+			Type:    TierChangedNotificationCreated,
+			Status:  apiv1.ConditionFalse,
+			Reason:  TierChangedNotificationCRCreationFailedReason,
+			Message: "unable to create notification",
+		})
+		murtest.AssertThatMasterUserRecord(t, "john", cl).HasTier(*teamTier)
+	})
+}
+
 func TestChangeTierFailure(t *testing.T) {
 	config := commonconfig.NewToolchainConfigObjWithReset(t, testconfig.Tiers().DurationBeforeChangeTierRequestDeletion("10s"))
 	userSignup := NewUserSignup(WithName("john"))
@@ -336,6 +556,25 @@ func TestChangeTierFailure(t *testing.T) {
 		AssertThatChangeTierRequestHasCondition(t, cl, changeTierRequest.Name, toBeNotComplete("nstemplatetiers.toolchain.dev.openshift.com \"team\" not found"))
 	})
 
+	t.Run("will fail since the provided tier is deprecated", func(t *testing.T) {
+		// given
+		mur := murtest.NewMasterUserRecord(t, "johny", murtest.WithOwnerLabel(userSignup.Name))
+		changeTierRequest := newChangeTierRequest("johny", "team")
+		deprecatedTeamTier := NewNSTemplateTier("team", "123team", "123clusterteam", "stage", "dev")
+		deprecatedTeamTier.Annotations = map[string]string{tierutil.DeprecatedByAnnotationKey: "advanced"}
+		controller, request, cl := newController(t, changeTierRequest, config, userSignup, mur, deprecatedTeamTier)
+
+		// when
+		_, err := controller.Reconcile(context.TODO(), request)
+
+		// then
+		require.EqualError(t, err, "unable to change the tier to team: the NSTemplateTier 'team' is deprecated in favour of the 'advanced' tier")
+		murtest.AssertThatMasterUserRecord(t, "johny", cl).
+			HasTier(murtest.DefaultNSTemplateTier())
+		AssertThatChangeTierRequestHasCondition(t, cl, changeTierRequest.Name,
+			toBeNotComplete("the NSTemplateTier 'team' is deprecated in favour of the 'advanced' tier"))
+	})
+
 	t.Run("will fail since it won't be able to find the correct UserAccount in MUR", func(t *testing.T) {
 		// given
 		mur := murtest.NewMasterUserRecord(t, "johny", murtest.WithOwnerLabel(userSignup.Name))
@@ -434,10 +673,10 @@ func TestChangeTierFailure(t *testing.T) {
 		states.SetDeactivating(userSignupDeactivating, true)
 		controller, request, cl := newController(t, changeTierRequest, config, userSignupDeactivating, mur, teamTier)
 		cl.MockUpdate = func(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
-			if obj.GetObjectKind().GroupVersionKind().Kind != "MasterUserRecord" {
+			if _, ok := obj.(*toolchainv1alpha1.UserSignup); ok {
 				return fmt.Errorf("update UserSignup failure")
 			}
-			return nil
+			return cl.Client.Update(ctx, obj, opts...)
 		}
 
 		// when
@@ -603,6 +842,23 @@ func toBeNotComplete(msg string) toolchainv1alpha1.Condition {
 	}
 }
 
+func toHaveTierChangedNotificationCreated() toolchainv1alpha1.Condition {
+	return toolchainv1alpha1.Condition{
+		Type:   TierChangedNotificationCreated,
+		Status: apiv1.ConditionTrue,
+		Reason: TierChangedNotificationCRCreatedReason,
+	}
+}
+
+func assertTierChangedNotifications(t *testing.T, cl client.Client, expected int) []toolchainv1alpha1.Notification {
+	notifications := &toolchainv1alpha1.NotificationList{}
+	err := cl.List(context.TODO(), notifications, client.InNamespace(test.HostOperatorNs),
+		client.MatchingLabels{toolchainv1alpha1.NotificationTypeLabelKey: NotificationTypeTierChanged})
+	require.NoError(t, err)
+	require.Len(t, notifications.Items, expected)
+	return notifications.Items
+}
+
 func toBeDeletionError(msg string) toolchainv1alpha1.Condition {
 	return toolchainv1alpha1.Condition{
 		Type:               toolchainv1alpha1.ChangeTierRequestDeletionError,
@@ -621,6 +877,20 @@ func targetCluster(c string) changeTierRequestOption {
 	}
 }
 
+func newTierChangedNotification(userSignup *toolchainv1alpha1.UserSignup, changeTierRequestName string) *toolchainv1alpha1.Notification {
+	return &toolchainv1alpha1.Notification{
+		ObjectMeta: v1.ObjectMeta{
+			Namespace: userSignup.Namespace,
+			Name:      changeTierRequestName + "-tierchanged",
+			Labels: map[string]string{
+				toolchainv1alpha1.NotificationUserNameLabelKey: userSignup.Status.CompliantUsername,
+				toolchainv1alpha1.NotificationTypeLabelKey:     NotificationTypeTierChanged,
+				ChangeTierRequestLabelKey:                      changeTierRequestName,
+			},
+		},
+	}
+}
+
 func newChangeTierRequest(murName, tierName string, options ...changeTierRequestOption) *toolchainv1alpha1.ChangeTierRequest {
 	ctr := &toolchainv1alpha1.ChangeTierRequest{
 		ObjectMeta: v1.ObjectMeta{
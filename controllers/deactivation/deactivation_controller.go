@@ -42,8 +42,9 @@ func (r *Reconciler) SetupWithManager(mgr manager.Manager) error {
 
 // Reconciler reconciles a Deactivation object
 type Reconciler struct {
-	Client client.Client
-	Scheme *runtime.Scheme
+	Client   client.Client
+	Scheme   *runtime.Scheme
+	throttle throttle
 }
 
 // Reconcile reads the state of the cluster for a MUR object and determines whether to trigger deactivation or requeue based on its current status
@@ -54,6 +55,10 @@ func (r *Reconciler) Reconcile(ctx context.Context, request ctrl.Request) (ctrl.
 	logger := log.FromContext(ctx)
 	logger.Info("Reconciling Deactivation")
 
+	// the MasterUserRecord is removed from the deactivation backlog for now, it will be added again if its deactivation is
+	// still postponed by the throttle at the end of this reconcile loop
+	r.throttle.forget(request.Name)
+
 	config, err := toolchainconfig.GetToolchainConfig(r.Client)
 	if err != nil {
 		return reconcile.Result{}, errs.Wrapf(err, "unable to get ToolchainConfig")
@@ -198,10 +203,20 @@ func (r *Reconciler) Reconcile(ctx context.Context, request ctrl.Request) (ctrl.
 		// The UserSignup is already set for deactivation, nothing left to do
 		return reconcile.Result{}, nil
 	}
+
+	// Check that the number of recent deactivations is within the limits, otherwise postpone the deactivation.
+	// The deactivating condition is left untouched, so the due time promised to the user in the deactivating notification remains the same.
+	targetCluster := mur.Spec.UserAccounts[0].TargetCluster
+	if ok, retryAfter := r.throttle.reserve(time.Now(), mur.Name, targetCluster, config.Deactivation()); !ok {
+		logger.Info("deactivation postponed by the throttle", "RequeueAfter", retryAfter, "targetCluster", targetCluster,
+			"Expected deactivation date/time", deactivationDueTime.String())
+		return reconcile.Result{RequeueAfter: retryAfter}, nil
+	}
 	states.SetDeactivated(usersignup, true)
 
 	if err := r.Client.Update(context.TODO(), usersignup); err != nil {
 		logger.Error(err, "failed to update usersignup")
+		r.throttle.release(mur.Name)
 		return reconcile.Result{}, err
 	}
 
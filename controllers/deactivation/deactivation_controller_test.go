@@ -286,6 +286,121 @@ func TestReconcile(t *testing.T) {
 
 	})
 
+	t.Run("deactivation throttle", func(t *testing.T) {
+
+		deactivatingUser := func(username, cluster string) (*toolchainv1alpha1.MasterUserRecord, *toolchainv1alpha1.UserSignup) {
+			userSignup := userSignupWithEmail(username, "foo@bar.com")
+			states.SetDeactivating(userSignup, true)
+			userSignup.Status.Conditions = []toolchainv1alpha1.Condition{
+				{
+					Type:               toolchainv1alpha1.UserSignupUserDeactivatingNotificationCreated,
+					Status:             corev1.ConditionTrue,
+					LastTransitionTime: metav1.Time{Time: time.Now().Add(time.Duration(-3) * time.Hour * 24)},
+					Reason:             toolchainv1alpha1.UserSignupDeactivatingNotificationCRCreatedReason,
+				},
+			}
+			murProvisionedTime := &metav1.Time{Time: time.Now().Add(-time.Duration(expectedDeactivationTimeoutBasicTier*24) * time.Hour)}
+			mur := murtest.NewMasterUserRecord(t, username, murtest.Account(cluster, *basicTier), murtest.ProvisionedMur(murProvisionedTime), murtest.UserIDFromUserSignup(userSignup))
+			mur.Labels[toolchainv1alpha1.MasterUserRecordOwnerLabelKey] = userSignup.Name
+			return mur, userSignup
+		}
+
+		t.Run("global limit reached", func(t *testing.T) {
+			// given
+			commonconfig.ResetCache() // discard the config loaded by the previous tests
+			config := commonconfig.NewToolchainConfigObjWithReset(t,
+				testconfig.Deactivation().DeactivatingNotificationDays(3),
+				DeactivationThrottle("1h", 1, nil))
+			mur1, userSignup1 := deactivatingUser("user-1", "cluster1")
+			mur2, userSignup2 := deactivatingUser("user-2", "cluster2")
+			r, req1, cl := prepareReconcile(t, mur1.Name, basicTier, mur1, userSignup1, mur2, userSignup2, config)
+			req2 := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: operatorNamespace, Name: mur2.Name}}
+
+			// when
+			res1, err1 := r.Reconcile(context.TODO(), req1)
+			res2, err2 := r.Reconcile(context.TODO(), req2)
+
+			// then
+			require.NoError(t, err1)
+			require.Equal(t, reconcile.Result{}, res1)
+			assertThatUserSignupDeactivated(t, cl, userSignup1.Name, true)
+			require.NoError(t, err2)
+			require.False(t, res2.Requeue)
+			require.WithinDuration(t, time.Now().Add(time.Hour), time.Now().Add(res2.RequeueAfter), 5*time.Second)
+			assertThatUserSignupDeactivated(t, cl, userSignup2.Name, false)
+			// the due time promised to the user is not modified
+			userSignup := &toolchainv1alpha1.UserSignup{}
+			require.NoError(t, cl.Get(context.TODO(), types.NamespacedName{Namespace: operatorNamespace, Name: userSignup2.Name}, userSignup))
+			require.True(t, states.Deactivating(userSignup))
+			require.Len(t, userSignup.Status.Conditions, 1)
+			require.WithinDuration(t, userSignup2.Status.Conditions[0].LastTransitionTime.Time, userSignup.Status.Conditions[0].LastTransitionTime.Time, time.Second)
+			AssertMetricsCounterEquals(t, 1, metrics.UserSignupAutoDeactivatedTotal)
+			AssertMetricsGaugeEquals(t, 1, metrics.UserSignupDeactivationBacklog)
+
+			t.Run("deactivated when a slot is available", func(t *testing.T) {
+				// given
+				r.(*Reconciler).throttle.deactivations[0].time = time.Now().Add(-2 * time.Hour)
+
+				// when
+				res, err := r.Reconcile(context.TODO(), req2)
+
+				// then
+				require.NoError(t, err)
+				require.Equal(t, reconcile.Result{}, res)
+				assertThatUserSignupDeactivated(t, cl, userSignup2.Name, true)
+				AssertMetricsCounterEquals(t, 2, metrics.UserSignupAutoDeactivatedTotal)
+				AssertMetricsGaugeEquals(t, 0, metrics.UserSignupDeactivationBacklog)
+			})
+		})
+
+		t.Run("member cluster limit reached", func(t *testing.T) {
+			// given
+			commonconfig.ResetCache() // discard the config loaded by the previous tests
+			config := commonconfig.NewToolchainConfigObjWithReset(t,
+				testconfig.Deactivation().DeactivatingNotificationDays(3),
+				DeactivationThrottle("1h", 10, map[string]int{"cluster1": 1}))
+			mur1, userSignup1 := deactivatingUser("user-1", "cluster1")
+			mur2, userSignup2 := deactivatingUser("user-2", "cluster1")
+			mur3, userSignup3 := deactivatingUser("user-3", "cluster2")
+			r, req1, cl := prepareReconcile(t, mur1.Name, basicTier, mur1, userSignup1, mur2, userSignup2, mur3, userSignup3, config)
+
+			// when
+			_, err1 := r.Reconcile(context.TODO(), req1)
+			res2, err2 := r.Reconcile(context.TODO(), reconcile.Request{NamespacedName: types.NamespacedName{Namespace: operatorNamespace, Name: mur2.Name}})
+			_, err3 := r.Reconcile(context.TODO(), reconcile.Request{NamespacedName: types.NamespacedName{Namespace: operatorNamespace, Name: mur3.Name}})
+
+			// then
+			require.NoError(t, err1)
+			require.NoError(t, err2)
+			require.NoError(t, err3)
+			assertThatUserSignupDeactivated(t, cl, userSignup1.Name, true)
+			assertThatUserSignupDeactivated(t, cl, userSignup2.Name, false)
+			require.WithinDuration(t, time.Now().Add(time.Hour), time.Now().Add(res2.RequeueAfter), 5*time.Second)
+			assertThatUserSignupDeactivated(t, cl, userSignup3.Name, true)
+			AssertMetricsGaugeEquals(t, 1, metrics.UserSignupDeactivationBacklog)
+		})
+
+		t.Run("slot released when deactivation failed", func(t *testing.T) {
+			// given
+			commonconfig.ResetCache() // discard the config loaded by the previous tests
+			config := commonconfig.NewToolchainConfigObjWithReset(t,
+				testconfig.Deactivation().DeactivatingNotificationDays(3),
+				DeactivationThrottle("1h", 1, nil))
+			mur1, userSignup1 := deactivatingUser("user-1", "cluster1")
+			r, req1, cl := prepareReconcile(t, mur1.Name, basicTier, mur1, userSignup1, config)
+			cl.MockUpdate = func(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+				return fmt.Errorf("usersignup update error")
+			}
+
+			// when
+			_, err := r.Reconcile(context.TODO(), req1)
+
+			// then
+			require.EqualError(t, err, "usersignup update error")
+			require.Empty(t, r.(*Reconciler).throttle.deactivations)
+		})
+	})
+
 	t.Run("failures", func(t *testing.T) {
 
 		// cannot find NSTemplateTier
@@ -0,0 +1,110 @@
+package deactivation
+
+import (
+	"sync"
+	"time"
+
+	"github.com/codeready-toolchain/host-operator/controllers/toolchainconfig"
+	"github.com/codeready-toolchain/host-operator/pkg/metrics"
+)
+
+// throttle limits the number of deactivations within a sliding interval, across all member clusters and per member cluster,
+// so that a large cohort of users reaching their deactivation due time together does not trigger a mass deletion
+// of resources on the member clusters. Deactivations which exceed the limits are kept in a backlog until a slot is available.
+type throttle struct {
+	sync.Mutex
+	// deactivations the deactivations which occurred within the last interval, in chronological order
+	deactivations []deactivationRecord
+	// backlog the names of the MasterUserRecords whose deactivation is due but was postponed
+	backlog map[string]bool
+}
+
+type deactivationRecord struct {
+	murName string
+	cluster string
+	time    time.Time
+}
+
+// reserve attempts to reserve a deactivation slot for the given MasterUserRecord provisioned on the given member cluster.
+// Returns `true` if the deactivation can proceed, otherwise `false` along with the duration after which a slot should be available again.
+// In the latter case, the MasterUserRecord is added to the backlog until a subsequent reservation succeeds or it is forgotten.
+func (t *throttle) reserve(now time.Time, murName, cluster string, config toolchainconfig.DeactivationConfig) (bool, time.Duration) {
+	t.Lock()
+	defer t.Unlock()
+	interval := config.ThrottleInterval()
+	t.prune(now.Add(-interval))
+
+	var retryAfter time.Duration
+	if max := config.MaxDeactivationsPerInterval(); max > 0 && len(t.deactivations) >= max {
+		// the oldest deactivation of the window is the next one to expire
+		retryAfter = t.deactivations[len(t.deactivations)-max].time.Add(interval).Sub(now)
+	}
+	if max := config.MaxDeactivationsPerIntervalSpecificPerMemberCluster()[cluster]; max > 0 {
+		var inCluster []deactivationRecord
+		for _, d := range t.deactivations {
+			if d.cluster == cluster {
+				inCluster = append(inCluster, d)
+			}
+		}
+		if len(inCluster) >= max {
+			if clusterRetryAfter := inCluster[len(inCluster)-max].time.Add(interval).Sub(now); clusterRetryAfter > retryAfter {
+				retryAfter = clusterRetryAfter
+			}
+		}
+	}
+	if retryAfter > 0 {
+		t.addToBacklog(murName)
+		return false, retryAfter
+	}
+	t.deactivations = append(t.deactivations, deactivationRecord{
+		murName: murName,
+		cluster: cluster,
+		time:    now,
+	})
+	t.removeFromBacklog(murName)
+	return true, 0
+}
+
+// release cancels the reservation made for the given MasterUserRecord, for example when the deactivation failed
+func (t *throttle) release(murName string) {
+	t.Lock()
+	defer t.Unlock()
+	for i := len(t.deactivations) - 1; i >= 0; i-- {
+		if t.deactivations[i].murName == murName {
+			t.deactivations = append(t.deactivations[:i], t.deactivations[i+1:]...)
+			return
+		}
+	}
+}
+
+// forget removes the given MasterUserRecord from the backlog
+func (t *throttle) forget(murName string) {
+	t.Lock()
+	defer t.Unlock()
+	t.removeFromBacklog(murName)
+}
+
+// prune removes the deactivations which occurred before the given time
+func (t *throttle) prune(before time.Time) {
+	i := 0
+	for i < len(t.deactivations) && !t.deactivations[i].time.After(before) {
+		i++
+	}
+	t.deactivations = t.deactivations[i:]
+}
+
+func (t *throttle) addToBacklog(murName string) {
+	if t.backlog == nil {
+		t.backlog = map[string]bool{}
+	}
+	t.backlog[murName] = true
+	metrics.UserSignupDeactivationBacklog.Set(float64(len(t.backlog)))
+}
+
+func (t *throttle) removeFromBacklog(murName string) {
+	if !t.backlog[murName] {
+		return
+	}
+	delete(t.backlog, murName)
+	metrics.UserSignupDeactivationBacklog.Set(float64(len(t.backlog)))
+}
@@ -0,0 +1,124 @@
+package deactivation
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/codeready-toolchain/host-operator/controllers/toolchainconfig"
+	"github.com/codeready-toolchain/host-operator/pkg/metrics"
+	. "github.com/codeready-toolchain/host-operator/test"
+	commonconfig "github.com/codeready-toolchain/toolchain-common/pkg/configuration"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThrottle(t *testing.T) {
+	// given
+	restore := test.SetEnvVarAndRestore(t, "WATCH_NAMESPACE", test.HostOperatorNs)
+	defer restore()
+	now := time.Now()
+
+	t.Run("no limit by default", func(t *testing.T) {
+		// given
+		metrics.Reset()
+		config := deactivationConfig(t)
+		thr := &throttle{}
+
+		for i := 0; i < 100; i++ {
+			// when
+			ok, retryAfter := thr.reserve(now, fmt.Sprintf("user-%d", i), "member-1", config)
+
+			// then
+			assert.True(t, ok)
+			assert.Zero(t, retryAfter)
+		}
+		AssertMetricsGaugeEquals(t, 0, metrics.UserSignupDeactivationBacklog)
+	})
+
+	t.Run("global limit", func(t *testing.T) {
+		// given
+		metrics.Reset()
+		config := deactivationConfig(t, DeactivationThrottle("10m", 2, nil))
+		thr := &throttle{}
+
+		// when
+		ok1, _ := thr.reserve(now.Add(-8*time.Minute), "user-1", "member-1", config)
+		ok2, _ := thr.reserve(now.Add(-5*time.Minute), "user-2", "member-2", config)
+		ok3, retryAfter := thr.reserve(now, "user-3", "member-1", config)
+
+		// then
+		assert.True(t, ok1)
+		assert.True(t, ok2)
+		assert.False(t, ok3)
+		assert.Equal(t, 2*time.Minute, retryAfter) // when the deactivation of user-1 is out of the window
+		AssertMetricsGaugeEquals(t, 1, metrics.UserSignupDeactivationBacklog)
+
+		t.Run("slot available after the oldest deactivation is out of the window", func(t *testing.T) {
+			// when
+			ok, retryAfter := thr.reserve(now.Add(2*time.Minute), "user-3", "member-1", config)
+
+			// then
+			assert.True(t, ok)
+			assert.Zero(t, retryAfter)
+			require.Len(t, thr.deactivations, 2)
+			assert.Equal(t, "user-2", thr.deactivations[0].murName)
+			assert.Equal(t, "user-3", thr.deactivations[1].murName)
+			AssertMetricsGaugeEquals(t, 0, metrics.UserSignupDeactivationBacklog)
+		})
+	})
+
+	t.Run("member cluster limit", func(t *testing.T) {
+		// given
+		metrics.Reset()
+		config := deactivationConfig(t, DeactivationThrottle("10m", 0, map[string]int{"member-1": 1}))
+		thr := &throttle{}
+
+		// when
+		ok1, _ := thr.reserve(now.Add(-1*time.Minute), "user-1", "member-1", config)
+		ok2, _ := thr.reserve(now.Add(-1*time.Minute), "user-2", "member-2", config)
+		ok3, retryAfter := thr.reserve(now, "user-3", "member-1", config)
+		ok4, _ := thr.reserve(now, "user-4", "member-2", config)
+
+		// then
+		assert.True(t, ok1)
+		assert.True(t, ok2)
+		assert.False(t, ok3)
+		assert.Equal(t, 9*time.Minute, retryAfter)
+		assert.True(t, ok4)
+		AssertMetricsGaugeEquals(t, 1, metrics.UserSignupDeactivationBacklog)
+	})
+
+	t.Run("release and forget", func(t *testing.T) {
+		// given
+		metrics.Reset()
+		config := deactivationConfig(t, DeactivationThrottle("10m", 1, nil))
+		thr := &throttle{}
+		ok1, _ := thr.reserve(now, "user-1", "member-1", config)
+		ok2, _ := thr.reserve(now, "user-2", "member-1", config)
+		require.True(t, ok1)
+		require.False(t, ok2)
+
+		// when
+		thr.release("user-1")
+		thr.forget("user-2")
+
+		// then
+		assert.Empty(t, thr.deactivations)
+		assert.Empty(t, thr.backlog)
+		AssertMetricsGaugeEquals(t, 0, metrics.UserSignupDeactivationBacklog)
+	})
+}
+
+func deactivationConfig(t *testing.T, options ...HostConfigExtensionOption) toolchainconfig.DeactivationConfig {
+	cfgObj := commonconfig.NewToolchainConfigObjWithReset(t)
+	for _, opt := range options {
+		opt.Apply(cfgObj)
+	}
+	cl := test.NewFakeClient(t, cfgObj)
+	config, err := toolchainconfig.GetToolchainConfig(cl)
+	require.NoError(t, err)
+	return config.Deactivation()
+}
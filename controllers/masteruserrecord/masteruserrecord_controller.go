@@ -6,6 +6,7 @@ import (
 	"time"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	tierutil "github.com/codeready-toolchain/host-operator/controllers/nstemplatetier/util"
 	"github.com/codeready-toolchain/host-operator/pkg/counter"
 	"github.com/codeready-toolchain/host-operator/pkg/metrics"
 	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
@@ -31,12 +32,16 @@ import (
 const (
 	// Finalizers
 	murFinalizerName = "finalizer.toolchain.dev.openshift.com"
+
+	// InvalidTemplateParametersReason the reason of the `Ready=false` condition when the template parameter overrides of the MasterUserRecord are not valid
+	InvalidTemplateParametersReason = "InvalidTemplateParameters"
 )
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *Reconciler) SetupWithManager(mgr manager.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&toolchainv1alpha1.MasterUserRecord{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		// watch MasterUserRecords (including when their template parameter overrides are changed)
+		For(&toolchainv1alpha1.MasterUserRecord{}, builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{}))).
 		Complete(r)
 }
 
@@ -131,6 +136,12 @@ func (r *Reconciler) ensureUserAccount(logger logr.Logger, murAccount toolchainv
 			"failed to get the member cluster '%s'", murAccount.TargetCluster)
 	}
 
+	// apply the template parameter overrides of the MasterUserRecord (if any) on the NSTemplateSet of the UserAccount
+	if murAccount.Spec.NSTemplateSet, err = r.nsTemplateSetSpecOf(mur, murAccount.Spec.NSTemplateSet); err != nil {
+		return r.wrapErrorWithStatusUpdate(logger, mur, r.setStatusFailed(InvalidTemplateParametersReason), err,
+			"invalid template parameter overrides for the UserAccount in the cluster '%s'", murAccount.TargetCluster)
+	}
+
 	// get UserAccount from member
 	nsdName := namespacedName(memberCluster.OperatorNamespace, mur.Name)
 	userAccount := &toolchainv1alpha1.UserAccount{}
@@ -186,6 +197,20 @@ func (r *Reconciler) ensureUserAccount(logger logr.Logger, murAccount toolchainv
 	return nil
 }
 
+// nsTemplateSetSpecOf returns the given NSTemplateSet spec of a UserAccount of the given MasterUserRecord, or a copy which refers to the
+// TierTemplates with the template parameter overrides of the MasterUserRecord (if any)
+func (r *Reconciler) nsTemplateSetSpecOf(mur *toolchainv1alpha1.MasterUserRecord, nsTmplSetSpec *toolchainv1alpha1.NSTemplateSetSpec) (*toolchainv1alpha1.NSTemplateSetSpec, error) {
+	overrides, err := tierutil.GetParameterOverrides(mur)
+	if err != nil || len(overrides) == 0 || nsTmplSetSpec == nil {
+		return nsTmplSetSpec, err
+	}
+	spec, err := tierutil.ApplyParameterOverrides(r.Client, mur.Namespace, *nsTmplSetSpec, overrides)
+	if err != nil {
+		return nsTmplSetSpec, err
+	}
+	return &spec, nil
+}
+
 func (r *Reconciler) getMemberCluster(targetCluster string) (*cluster.CachedToolchainCluster, error) {
 	// get & check toolchain cluster
 	toolchainCluster, ok := r.RetrieveMemberCluster(targetCluster)
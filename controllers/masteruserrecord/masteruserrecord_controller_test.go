@@ -8,9 +8,11 @@ import (
 	"time"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	tierutil "github.com/codeready-toolchain/host-operator/controllers/nstemplatetier/util"
 	"github.com/codeready-toolchain/host-operator/pkg/apis"
 	"github.com/codeready-toolchain/host-operator/pkg/metrics"
 	. "github.com/codeready-toolchain/host-operator/test"
+	tiertest "github.com/codeready-toolchain/host-operator/test/nstemplatetier"
 	"github.com/codeready-toolchain/toolchain-common/pkg/test"
 	murtest "github.com/codeready-toolchain/toolchain-common/pkg/test/masteruserrecord"
 	uatest "github.com/codeready-toolchain/toolchain-common/pkg/test/useraccount"
@@ -804,6 +806,103 @@ func TestModifyUserAccounts(t *testing.T) {
 		HaveUserAccountsForCluster("member3-cluster", 1)
 }
 
+func TestUserAccountTemplateParameters(t *testing.T) {
+	// given
+	logf.SetLogger(zap.New(zap.UseDevMode(true)))
+	s := apiScheme(t)
+	defaultTier := murtest.DefaultNSTemplateTier()
+	tierTemplates := tiertest.TierTemplatesFor(&defaultTier)
+	overrides := map[string]string{"MEMORY_LIMIT": "10Gi"}
+	assertOverriddenRefs := func(t *testing.T, userAccount *toolchainv1alpha1.UserAccount) {
+		require.NotNil(t, userAccount.Spec.NSTemplateSet)
+		require.NotNil(t, userAccount.Spec.NSTemplateSet.ClusterResources)
+		assert.Equal(t, tierutil.OverriddenTemplateRef("basic-clusterresources-654321a", overrides), userAccount.Spec.NSTemplateSet.ClusterResources.TemplateRef)
+		require.Len(t, userAccount.Spec.NSTemplateSet.Namespaces, 3)
+		assert.Equal(t, tierutil.OverriddenTemplateRef("basic-dev-123abc", overrides), userAccount.Spec.NSTemplateSet.Namespaces[0].TemplateRef)
+		assert.Equal(t, tierutil.OverriddenTemplateRef("basic-code-123abc", overrides), userAccount.Spec.NSTemplateSet.Namespaces[1].TemplateRef)
+		assert.Equal(t, tierutil.OverriddenTemplateRef("basic-stage-123abc", overrides), userAccount.Spec.NSTemplateSet.Namespaces[2].TemplateRef)
+	}
+
+	t.Run("create UserAccount with the parameter overrides", func(t *testing.T) {
+		// given
+		mur := murtest.NewMasterUserRecord(t, "john",
+			murtest.Finalizer("finalizer.toolchain.dev.openshift.com"),
+			murtest.WithAnnotation(tierutil.TemplateParametersAnnotationKey, `{"MEMORY_LIMIT":"10Gi"}`))
+		memberClient := test.NewFakeClient(t)
+		hostClient := test.NewFakeClient(t, append(tierTemplates, mur)...)
+		InitializeCounters(t, NewToolchainStatus())
+		cntrl := newController(hostClient, s, NewGetMemberCluster(true, v1.ConditionTrue),
+			ClusterClient(test.MemberClusterName, memberClient))
+
+		// when
+		_, err := cntrl.Reconcile(context.TODO(), newMurRequest(mur))
+
+		// then
+		require.NoError(t, err)
+		userAccount := uatest.AssertThatUserAccount(t, "john", memberClient).
+			Exists().
+			Get()
+		assertOverriddenRefs(t, userAccount)
+		tierTmpl := &toolchainv1alpha1.TierTemplate{}
+		require.NoError(t, hostClient.Get(context.TODO(), test.NamespacedName(test.HostOperatorNs, userAccount.Spec.NSTemplateSet.ClusterResources.TemplateRef), tierTmpl))
+		require.Len(t, tierTmpl.Spec.Template.Parameters, 2)
+		assert.Equal(t, "10Gi", tierTmpl.Spec.Template.Parameters[1].Value)
+		// the MasterUserRecord still refers to the TierTemplates of the tier
+		murtest.AssertThatMasterUserRecord(t, "john", hostClient).
+			HasTier(defaultTier)
+	})
+
+	t.Run("synchronize UserAccount when the parameter overrides changed", func(t *testing.T) {
+		// given
+		mur := murtest.NewMasterUserRecord(t, "john",
+			murtest.Finalizer("finalizer.toolchain.dev.openshift.com"),
+			murtest.StatusCondition(toBeProvisioned()))
+		userAccount := uatest.NewUserAccountFromMur(mur)
+		require.NoError(t, murtest.Modify(mur, murtest.WithAnnotation(tierutil.TemplateParametersAnnotationKey, `{"MEMORY_LIMIT":"10Gi"}`)))
+		toolchainStatus := NewToolchainStatus(
+			WithMember(test.MemberClusterName, WithUserAccountCount(1), WithRoutes("https://console.member-cluster/", "", ToBeReady())))
+		memberClient := test.NewFakeClient(t, userAccount)
+		hostClient := test.NewFakeClient(t, append(tierTemplates, mur, toolchainStatus)...)
+		InitializeCounters(t, toolchainStatus)
+		cntrl := newController(hostClient, s, NewGetMemberCluster(true, v1.ConditionTrue),
+			ClusterClient(test.MemberClusterName, memberClient))
+
+		// when
+		_, err := cntrl.Reconcile(context.TODO(), newMurRequest(mur))
+
+		// then
+		require.NoError(t, err)
+		userAccount = uatest.AssertThatUserAccount(t, "john", memberClient).
+			Exists().
+			Get()
+		assertOverriddenRefs(t, userAccount)
+		murtest.AssertThatMasterUserRecord(t, "john", hostClient).
+			HasConditions(toBeNotReady(toolchainv1alpha1.MasterUserRecordUpdatingReason, ""))
+	})
+
+	t.Run("invalid parameter overrides", func(t *testing.T) {
+		// given
+		mur := murtest.NewMasterUserRecord(t, "john",
+			murtest.Finalizer("finalizer.toolchain.dev.openshift.com"),
+			murtest.WithAnnotation(tierutil.TemplateParametersAnnotationKey, `{"CPU_LIMIT":"2"}`))
+		memberClient := test.NewFakeClient(t)
+		hostClient := test.NewFakeClient(t, append(tierTemplates, mur)...)
+		InitializeCounters(t, NewToolchainStatus())
+		cntrl := newController(hostClient, s, NewGetMemberCluster(true, v1.ConditionTrue),
+			ClusterClient(test.MemberClusterName, memberClient))
+
+		// when
+		_, err := cntrl.Reconcile(context.TODO(), newMurRequest(mur))
+
+		// then
+		msg := "the template parameter 'CPU_LIMIT' is not declared by the templates of the 'basic' tier"
+		require.EqualError(t, err, "invalid template parameter overrides for the UserAccount in the cluster 'member-cluster': "+msg)
+		uatest.AssertThatUserAccount(t, "john", memberClient).DoesNotExist()
+		murtest.AssertThatMasterUserRecord(t, "john", hostClient).
+			HasConditions(toBeNotReady(InvalidTemplateParametersReason, msg))
+	})
+}
+
 func TestSyncMurStatusWithUserAccountStatuses(t *testing.T) {
 	logf.SetLogger(zap.New(zap.UseDevMode(true)))
 	s := apiScheme(t)
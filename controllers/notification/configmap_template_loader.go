@@ -0,0 +1,194 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/host-operator/pkg/templates/assets"
+	"github.com/codeready-toolchain/host-operator/pkg/templates/notificationtemplates"
+
+	errs "github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// TemplateOverrideLabelKey is the key of the label of the ConfigMaps which override the embedded notification templates.
+// The value of the label is the name of the overridden template, and the data of the ConfigMap contains the files of the template,
+// ie, `subject.txt`, `notification.html` and the optional `notification.txt`, as well as their variants in other locales
+// (eg. `subject.de.txt` and `notification.de.html`)
+const TemplateOverrideLabelKey = toolchainv1alpha1.LabelKeyPrefix + "notification-template"
+
+// ConfigMapTemplateLoader loads the notification templates from the ConfigMaps in the operator namespace which override them,
+// and falls back to the embedded templates when a template is not overridden or when its override is invalid.
+// The changes in the overrides apply to the next notifications, without restarting the operator.
+type ConfigMapTemplateLoader struct {
+	Client    client.Client
+	Namespace string
+	Fallback  TemplateLoader
+
+	// overrides contains the parsed overrides (or their errors) indexed by template name, if the loader caches them.
+	// An entry is invalidated when a ConfigMap overriding the template changes.
+	overridesLock sync.RWMutex
+	overrides     map[string]templateOverride
+}
+
+// templateOverride is the result of the parsing of the ConfigMap overriding a template
+type templateOverride struct {
+	templates *notificationtemplates.Templates
+	err       error
+}
+
+// NewConfigMapTemplateLoader returns a new ConfigMapTemplateLoader which falls back to the embedded templates,
+// and which looks-up the ConfigMaps each time a template is loaded
+func NewConfigMapTemplateLoader(cl client.Client, namespace string) *ConfigMapTemplateLoader {
+	return &ConfigMapTemplateLoader{
+		Client:    cl,
+		Namespace: namespace,
+		Fallback:  &DefaultTemplateLoader{},
+	}
+}
+
+// NewCachingConfigMapTemplateLoader returns a new ConfigMapTemplateLoader which falls back to the embedded templates,
+// and which caches the parsed overrides. The ConfigMaps must be watched with the handler returned by InvalidationHandler,
+// so the cached overrides are reloaded when they change.
+func NewCachingConfigMapTemplateLoader(cl client.Client, namespace string) *ConfigMapTemplateLoader {
+	loader := NewConfigMapTemplateLoader(cl, namespace)
+	loader.overrides = map[string]templateOverride{}
+	return loader
+}
+
+// InvalidationHandler returns the handler of the events of the ConfigMaps, which invalidates the cached override
+// of the template named by the label of the ConfigMap. No request is enqueued.
+func (l *ConfigMapTemplateLoader) InvalidationHandler() handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(func(obj client.Object) []reconcile.Request {
+		if name := obj.GetLabels()[TemplateOverrideLabelKey]; name != "" && obj.GetNamespace() == l.Namespace {
+			l.overridesLock.Lock()
+			defer l.overridesLock.Unlock()
+			delete(l.overrides, name)
+		}
+		return nil
+	})
+}
+
+func (l *ConfigMapTemplateLoader) GetNotificationTemplate(name string, locales ...string) (*notificationtemplates.NotificationTemplate, bool, error) {
+	templates, err := l.templateOverride(name)
+	if err != nil && !IsTemplateOverrideError(err) {
+		return nil, false, err
+	}
+	if templates != nil {
+		template, found := templates.Get(name, locales...)
+		return template, found, nil
+	}
+	// the template is not overridden or its override is invalid
+	return l.Fallback.GetNotificationTemplate(name, locales...)
+}
+
+// ValidateTemplateOverride returns an error if the ConfigMap overriding the template with the given name is invalid
+// (in which case the embedded template is used instead), or if the ConfigMaps cannot be listed
+func (l *ConfigMapTemplateLoader) ValidateTemplateOverride(name string) error {
+	_, err := l.templateOverride(name)
+	return err
+}
+
+// templateOverride returns the templates defined in the ConfigMap overriding the template with the given name,
+// or nil if the template is not overridden. A TemplateOverrideError is returned if the override is invalid.
+func (l *ConfigMapTemplateLoader) templateOverride(name string) (*notificationtemplates.Templates, error) {
+	if name == "" {
+		return nil, nil
+	}
+	if l.overrides == nil {
+		return l.loadTemplateOverride(name)
+	}
+	l.overridesLock.RLock()
+	override, found := l.overrides[name]
+	l.overridesLock.RUnlock()
+	if found {
+		return override.templates, override.err
+	}
+	templates, err := l.loadTemplateOverride(name)
+	if err != nil && !IsTemplateOverrideError(err) {
+		// the ConfigMaps could not be listed, so they are listed again next time
+		return nil, err
+	}
+	l.overridesLock.Lock()
+	defer l.overridesLock.Unlock()
+	l.overrides[name] = templateOverride{templates: templates, err: err}
+	return templates, err
+}
+
+// loadTemplateOverride looks-up and parses the ConfigMap overriding the template with the given name
+func (l *ConfigMapTemplateLoader) loadTemplateOverride(name string) (*notificationtemplates.Templates, error) {
+	configMaps := &corev1.ConfigMapList{}
+	if err := l.Client.List(context.TODO(), configMaps, client.InNamespace(l.Namespace), client.MatchingLabels{TemplateOverrideLabelKey: name}); err != nil {
+		return nil, errs.Wrapf(err, "unable to list the ConfigMaps overriding the '%s' notification template", name)
+	}
+	if len(configMaps.Items) == 0 {
+		return nil, nil
+	}
+	if len(configMaps.Items) > 1 {
+		names := make([]string, len(configMaps.Items))
+		for i, cm := range configMaps.Items {
+			names[i] = cm.Name
+		}
+		sort.Strings(names)
+		return nil, NewTemplateOverrideError(name, fmt.Sprintf("the template is overridden by several ConfigMaps: %s", strings.Join(names, ", ")))
+	}
+
+	configMap := configMaps.Items[0]
+	files := make([]string, 0, len(configMap.Data))
+	for file := range configMap.Data {
+		files = append(files, name+"/"+file)
+	}
+	sort.Strings(files)
+	templates, err := notificationtemplates.ParseTemplates(assets.NewAssets(
+		func() []string {
+			return files
+		},
+		func(path string) ([]byte, error) {
+			return []byte(configMap.Data[strings.TrimPrefix(path, name+"/")]), nil
+		}))
+	if err != nil {
+		return nil, NewTemplateOverrideError(name, fmt.Sprintf("the ConfigMap '%s' is invalid: %s", configMap.Name, err.Error()))
+	}
+	variants := templates.Variants(name)
+	if len(variants) == 0 || variants[0].Locale != notificationtemplates.DefaultLocale || variants[0].Subject == "" || variants[0].Content == "" {
+		return nil, NewTemplateOverrideError(name, fmt.Sprintf("the ConfigMap '%s' must contain notification.html and subject.txt", configMap.Name))
+	}
+	for _, variant := range variants {
+		for _, definition := range []string{variant.Subject, variant.Content, variant.TextContent} {
+			if _, err := template.New("template").Parse(definition); err != nil {
+				return nil, NewTemplateOverrideError(name, fmt.Sprintf("the ConfigMap '%s' is invalid: %s", configMap.Name, err.Error()))
+			}
+		}
+	}
+	return templates, nil
+}
+
+// TemplateOverrideError is returned when the ConfigMap overriding a notification template is invalid
+type TemplateOverrideError struct {
+	errorMessage string
+}
+
+func (e TemplateOverrideError) Error() string {
+	return e.errorMessage
+}
+
+func NewTemplateOverrideError(name, msg string) error {
+	return TemplateOverrideError{
+		errorMessage: fmt.Sprintf("invalid override of the '%s' notification template: %s", name, msg),
+	}
+}
+
+// IsTemplateOverrideError returns true if the given error is a TemplateOverrideError
+func IsTemplateOverrideError(err error) bool {
+	var oErr TemplateOverrideError
+	return errors.As(err, &oErr)
+}
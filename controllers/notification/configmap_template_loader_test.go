@@ -0,0 +1,249 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/codeready-toolchain/host-operator/pkg/templates/notificationtemplates"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestConfigMapTemplateLoader(t *testing.T) {
+	// given
+	fallback := NewMockTemplateLoader(&notificationtemplates.NotificationTemplate{
+		Subject: "Deactivated",
+		Content: "<p>Your account is deactivated</p>",
+		Name:    "userdeactivated",
+	})
+	newTemplateConfigMap := func(name, templateName string, data map[string]string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: test.HostOperatorNs,
+				Labels: map[string]string{
+					TemplateOverrideLabelKey: templateName,
+				},
+			},
+			Data: data,
+		}
+	}
+	newLoader := func(cl client.Client) *ConfigMapTemplateLoader {
+		loader := NewConfigMapTemplateLoader(cl, test.HostOperatorNs)
+		loader.Fallback = fallback
+		return loader
+	}
+
+	t.Run("template not overridden", func(t *testing.T) {
+		// given
+		cl := test.NewFakeClient(t, newTemplateConfigMap("userprovisioned-override", "userprovisioned", map[string]string{
+			"subject.txt":       "Provisioned",
+			"notification.html": "<p>Your account is provisioned</p>",
+		}))
+		loader := newLoader(cl)
+
+		// when
+		template, found, err := loader.GetNotificationTemplate("userdeactivated")
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, "Deactivated", template.Subject)
+		assert.NoError(t, loader.ValidateTemplateOverride("userdeactivated"))
+	})
+
+	t.Run("template overridden", func(t *testing.T) {
+		// given
+		cl := test.NewFakeClient(t, newTemplateConfigMap("userdeactivated-override", "userdeactivated", map[string]string{
+			"subject.txt":          "Account deactivated",
+			"notification.html":    "<p>Your account was deactivated</p>",
+			"notification.txt":     "Your account was deactivated",
+			"subject.de.txt":       "Konto deaktiviert",
+			"notification.de.html": "<p>Ihr Konto wurde deaktiviert</p>",
+		}))
+		loader := newLoader(cl)
+
+		t.Run("default locale", func(t *testing.T) {
+			// when
+			template, found, err := loader.GetNotificationTemplate("userdeactivated")
+
+			// then
+			require.NoError(t, err)
+			assert.True(t, found)
+			assert.Equal(t, "userdeactivated", template.Name)
+			assert.Equal(t, "Account deactivated", template.Subject)
+			assert.Equal(t, "<p>Your account was deactivated</p>", template.Content)
+			assert.Equal(t, "Your account was deactivated", template.TextContent)
+			assert.NoError(t, loader.ValidateTemplateOverride("userdeactivated"))
+		})
+
+		t.Run("other locale", func(t *testing.T) {
+			// when
+			template, found, err := loader.GetNotificationTemplate("userdeactivated", "de-CH")
+
+			// then
+			require.NoError(t, err)
+			assert.True(t, found)
+			assert.Equal(t, "de", template.Locale)
+			assert.Equal(t, "Konto deaktiviert", template.Subject)
+		})
+
+		t.Run("override updated", func(t *testing.T) {
+			// given
+			cm := &corev1.ConfigMap{}
+			require.NoError(t, cl.Get(context.TODO(), test.NamespacedName(test.HostOperatorNs, "userdeactivated-override"), cm))
+			cm.Data["subject.txt"] = "Your account was deactivated"
+			require.NoError(t, cl.Update(context.TODO(), cm))
+
+			// when
+			template, found, err := loader.GetNotificationTemplate("userdeactivated")
+
+			// then
+			require.NoError(t, err)
+			assert.True(t, found)
+			assert.Equal(t, "Your account was deactivated", template.Subject)
+		})
+	})
+
+	t.Run("invalid override", func(t *testing.T) {
+		for name, tc := range map[string]struct {
+			configMaps    []*corev1.ConfigMap
+			expectedError string
+		}{
+			"missing subject": {
+				configMaps: []*corev1.ConfigMap{
+					newTemplateConfigMap("userdeactivated-override", "userdeactivated", map[string]string{
+						"notification.html": "<p>Your account was deactivated</p>",
+					}),
+				},
+				expectedError: "invalid override of the 'userdeactivated' notification template: the ConfigMap 'userdeactivated-override' must contain notification.html and subject.txt",
+			},
+			"unknown file": {
+				configMaps: []*corev1.ConfigMap{
+					newTemplateConfigMap("userdeactivated-override", "userdeactivated", map[string]string{
+						"subject.txt":       "Account deactivated",
+						"notification.html": "<p>Your account was deactivated</p>",
+						"notification.json": "{}",
+					}),
+				},
+				expectedError: "invalid override of the 'userdeactivated' notification template: the ConfigMap 'userdeactivated-override' is invalid: unable to load templates: must contain notification.html and subject.txt",
+			},
+			"invalid syntax": {
+				configMaps: []*corev1.ConfigMap{
+					newTemplateConfigMap("userdeactivated-override", "userdeactivated", map[string]string{
+						"subject.txt":       "Goodbye {{.FirstName}",
+						"notification.html": "<p>Your account was deactivated</p>",
+					}),
+				},
+				expectedError: `invalid override of the 'userdeactivated' notification template: the ConfigMap 'userdeactivated-override' is invalid: template: template:1: bad character U+007D '}'`,
+			},
+			"several overrides": {
+				configMaps: []*corev1.ConfigMap{
+					newTemplateConfigMap("userdeactivated-override-2", "userdeactivated", map[string]string{
+						"subject.txt":       "Account deactivated",
+						"notification.html": "<p>Your account was deactivated</p>",
+					}),
+					newTemplateConfigMap("userdeactivated-override-1", "userdeactivated", map[string]string{
+						"subject.txt":       "Account deactivated",
+						"notification.html": "<p>Your account was deactivated</p>",
+					}),
+				},
+				expectedError: "invalid override of the 'userdeactivated' notification template: the template is overridden by several ConfigMaps: userdeactivated-override-1, userdeactivated-override-2",
+			},
+		} {
+			t.Run(name, func(t *testing.T) {
+				// given
+				cl := test.NewFakeClient(t)
+				for _, cm := range tc.configMaps {
+					require.NoError(t, cl.Create(context.TODO(), cm))
+				}
+				loader := newLoader(cl)
+
+				// when
+				template, found, err := loader.GetNotificationTemplate("userdeactivated")
+
+				// then
+				require.NoError(t, err)
+				assert.True(t, found)
+				assert.Equal(t, "Deactivated", template.Subject) // fallback
+				err = loader.ValidateTemplateOverride("userdeactivated")
+				require.EqualError(t, err, tc.expectedError)
+				assert.True(t, IsTemplateOverrideError(err))
+			})
+		}
+	})
+
+	t.Run("unable to list the overrides", func(t *testing.T) {
+		// given
+		cl := test.NewFakeClient(t)
+		cl.MockList = func(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+			return errors.New("mock error")
+		}
+		loader := newLoader(cl)
+
+		// when
+		_, _, err := loader.GetNotificationTemplate("userdeactivated")
+
+		// then
+		require.EqualError(t, err, "unable to list the ConfigMaps overriding the 'userdeactivated' notification template: mock error")
+		err = loader.ValidateTemplateOverride("userdeactivated")
+		require.Error(t, err)
+		assert.False(t, IsTemplateOverrideError(err))
+	})
+
+	t.Run("cached overrides", func(t *testing.T) {
+		// given
+		configMap := newTemplateConfigMap("userdeactivated-override", "userdeactivated", map[string]string{
+			"subject.txt":       "Account deactivated",
+			"notification.html": "<p>Your account was deactivated</p>",
+		})
+		cl := test.NewFakeClient(t, configMap)
+		loader := NewCachingConfigMapTemplateLoader(cl, test.HostOperatorNs)
+		loader.Fallback = fallback
+		template, _, err := loader.GetNotificationTemplate("userdeactivated")
+		require.NoError(t, err)
+		require.Equal(t, "Account deactivated", template.Subject)
+		configMap.Data["subject.txt"] = "Goodbye"
+		require.NoError(t, cl.Update(context.TODO(), configMap))
+
+		t.Run("ConfigMaps not listed again", func(t *testing.T) {
+			// given
+			cl.MockList = func(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+				return errors.New("mock error")
+			}
+			defer func() {
+				cl.MockList = nil
+			}()
+
+			// when
+			template, _, err := loader.GetNotificationTemplate("userdeactivated")
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, "Account deactivated", template.Subject)
+		})
+
+		t.Run("override reloaded when the ConfigMap changed", func(t *testing.T) {
+			// given
+			queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+			defer queue.ShutDown()
+			loader.InvalidationHandler().Update(event.UpdateEvent{ObjectOld: configMap, ObjectNew: configMap}, queue)
+
+			// when
+			template, _, err := loader.GetNotificationTemplate("userdeactivated")
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, "Goodbye", template.Subject)
+			assert.Zero(t, queue.Len()) // no request enqueued
+		})
+	})
+}
@@ -17,6 +17,7 @@ const (
 type MailgunDeliveryError struct {
 	id           string
 	response     string
+	statusCode   int
 	errorMessage string
 }
 
@@ -24,10 +25,17 @@ func (e MailgunDeliveryError) Error() string {
 	return fmt.Sprintf("error while delivering notification (ID: %s, Response: %s) - %s", e.id, e.response, e.errorMessage)
 }
 
-func NewMailgunDeliveryError(id, response, errorMessage string) error {
+// Permanent returns true if the Mailgun API rejected the message with a client error (eg. invalid recipient)
+func (e MailgunDeliveryError) Permanent() bool {
+	return isPermanentHTTPStatus(e.statusCode)
+}
+
+// NewMailgunDeliveryError returns a new error with the given status code of the response of the Mailgun API (-1 if there was no response)
+func NewMailgunDeliveryError(id, response string, statusCode int, errorMessage string) error {
 	return MailgunDeliveryError{
 		id:           id,
 		response:     response,
+		statusCode:   statusCode,
 		errorMessage: errorMessage,
 	}
 }
@@ -71,48 +79,21 @@ func NewMailgunNotificationDeliveryService(config DeliveryServiceFactoryConfig,
 
 func (s *MailgunNotificationDeliveryService) Send(notification *toolchainv1alpha1.Notification) error {
 
-	var subject, body string
-
-	if notification.Spec.Template != "" {
-		template, found, err := s.base.TemplateLoader.GetNotificationTemplate(notification.Spec.Template)
-		if err != nil {
-			return err
-		}
-
-		if !found {
-			return fmt.Errorf("notification template [%s] not found", notification.Spec.Template)
-		}
-
-		// Copy the context to a local variable, we will add some more values to it here
-		context := notification.Spec.Context
-
-		if s.ReplyToEmail != "" {
-			context[ContextReplyTo] = s.ReplyToEmail
-		} else {
-			context[ContextReplyTo] = s.SenderEmail
-		}
-
-		subject, err = s.base.GenerateContent(context, template.Subject)
-		if err != nil {
-			return err
-		}
-
-		body, err = s.base.GenerateContent(context, template.Content)
-		if err != nil {
-			return err
-		}
-	} else {
-		// If there is no template specified then simply use the subject and content provided by the notification
-		subject = notification.Spec.Subject
-		body = notification.Spec.Content
+	replyTo := s.ReplyToEmail
+	if replyTo == "" {
+		replyTo = s.SenderEmail
+	}
+	subject, body, textBody, err := s.base.GenerateSubjectAndBodies(notification, replyTo)
+	if err != nil {
+		return err
 	}
 
 	if subject == "" && body == "" {
-		return fmt.Errorf("no subject or body specified for notification")
+		return NewNotificationContentError("no subject or body specified for notification")
 	}
 
 	// The message object allows you to add attachments and Bcc recipients
-	message := s.Mailgun.NewMessage(s.SenderEmail, subject, "", notification.Spec.Recipient)
+	message := s.Mailgun.NewMessage(s.SenderEmail, subject, textBody, notification.Spec.Recipient)
 
 	if s.ReplyToEmail != "" {
 		message.SetReplyTo(s.ReplyToEmail)
@@ -126,7 +107,7 @@ func (s *MailgunNotificationDeliveryService) Send(notification *toolchainv1alpha
 	// Send the message with a 10 second timeout
 	response, id, err := s.Mailgun.Send(ctx, message)
 	if err != nil {
-		return NewMailgunDeliveryError(id, response, err.Error())
+		return NewMailgunDeliveryError(id, response, mailgun.GetStatusFromErr(err), err.Error())
 	}
 
 	return nil
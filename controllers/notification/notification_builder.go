@@ -3,17 +3,30 @@ package notification
 import (
 	"context"
 	"fmt"
+	"net/url"
 	"regexp"
+	"strings"
+	"time"
 
 	"k8s.io/apimachinery/pkg/runtime"
 
+	"github.com/codeready-toolchain/host-operator/controllers/toolchainconfig"
+	"github.com/codeready-toolchain/host-operator/pkg/metrics"
+
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
 	"github.com/gofrs/uuid"
+	errs "github.com/pkg/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
+// LocaleAnnotationKey is the key of the annotation holding the preferred locale(s) of a user, either a single locale (eg. `de`)
+// or the value of the Accept-Language header of the signup request (eg. `de-CH,de;q=0.9,en;q=0.8`).
+// The annotation is set on the UserSignup by the registration service and it is propagated to the Notifications sent to the user,
+// whose templates are then localized accordingly.
+const LocaleAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "locale"
+
 var emailRegex = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
 
 type Option = func(notification *toolchainv1alpha1.Notification) error
@@ -26,6 +39,9 @@ type Builder interface {
 	WithControllerReference(owner v1.Object, scheme *runtime.Scheme) Builder
 	WithKeysAndValues(keysAndValues map[string]string) Builder
 	WithUserContext(userSignup *toolchainv1alpha1.UserSignup) Builder
+	WithLocale(locale string) Builder
+	WithCategory(category string) Builder
+	WithLabel(key, value string) Builder
 	Create(recipient string) (*toolchainv1alpha1.Notification, error)
 }
 
@@ -67,9 +83,17 @@ func (b *notificationBuilderImpl) Create(recipient string) (*toolchainv1alpha1.N
 		}
 	}
 
+	if err := b.addUnsubscribeToken(notification); err != nil {
+		return nil, err
+	}
+
 	generateName(notification)
 
-	return notification, b.client.Create(context.TODO(), notification)
+	if err := b.client.Create(context.TODO(), notification); err != nil {
+		return notification, err
+	}
+	metrics.NotificationCreatedCounterVec.WithLabelValues(notification.Labels[toolchainv1alpha1.NotificationTypeLabelKey]).Inc()
+	return notification, nil
 }
 
 func generateName(notification *toolchainv1alpha1.Notification) {
@@ -142,19 +166,100 @@ func (b *notificationBuilderImpl) WithKeysAndValues(keysAndValues map[string]str
 func (b *notificationBuilderImpl) WithUserContext(userSignup *toolchainv1alpha1.UserSignup) Builder {
 	b.options = append(b.options, func(n *toolchainv1alpha1.Notification) error {
 
-		n.Spec.Context["UserID"] = userSignup.Spec.Userid
-		n.Spec.Context["UserName"] = userSignup.Status.CompliantUsername
-		n.Spec.Context["FirstName"] = userSignup.Spec.GivenName
-		n.Spec.Context["LastName"] = userSignup.Spec.FamilyName
-		n.Spec.Context["CompanyName"] = userSignup.Spec.Company
+		for k, v := range userContext(userSignup) {
+			n.Spec.Context[k] = v
+		}
 
 		n.ObjectMeta.Labels[toolchainv1alpha1.NotificationUserNameLabelKey] = userSignup.Status.CompliantUsername
+		setAnnotation(n, UserSignupAnnotationKey, userSignup.Name)
 
-		if emailLbl, exists := userSignup.Annotations[toolchainv1alpha1.UserSignupUserEmailAnnotationKey]; exists {
-			n.Spec.Context["UserEmail"] = emailLbl
+		if locale, exists := userSignup.Annotations[LocaleAnnotationKey]; exists {
+			setLocale(n, locale)
 		}
 
 		return nil
 	})
 	return b
 }
+
+// userContext returns the context of the notifications sent to the owner of the given UserSignup
+func userContext(userSignup *toolchainv1alpha1.UserSignup) map[string]string {
+	context := map[string]string{
+		"UserID":      userSignup.Spec.Userid,
+		"UserName":    userSignup.Status.CompliantUsername,
+		"FirstName":   userSignup.Spec.GivenName,
+		"LastName":    userSignup.Spec.FamilyName,
+		"CompanyName": userSignup.Spec.Company,
+	}
+	if emailLbl, exists := userSignup.Annotations[toolchainv1alpha1.UserSignupUserEmailAnnotationKey]; exists {
+		context["UserEmail"] = emailLbl
+	}
+	return context
+}
+
+func (b *notificationBuilderImpl) WithLocale(locale string) Builder {
+	b.options = append(b.options, func(n *toolchainv1alpha1.Notification) error {
+		setLocale(n, locale)
+		return nil
+	})
+	return b
+}
+
+func setLocale(n *toolchainv1alpha1.Notification, locale string) {
+	if locale == "" {
+		return
+	}
+	setAnnotation(n, LocaleAnnotationKey, locale)
+}
+
+// WithCategory sets the category of the notification, which is otherwise derived from its type
+func (b *notificationBuilderImpl) WithCategory(category string) Builder {
+	b.options = append(b.options, func(n *toolchainv1alpha1.Notification) error {
+		setAnnotation(n, CategoryAnnotationKey, category)
+		return nil
+	})
+	return b
+}
+
+// WithLabel sets the given label on the notification, so that it can be looked-up afterwards
+func (b *notificationBuilderImpl) WithLabel(key, value string) Builder {
+	b.options = append(b.options, func(n *toolchainv1alpha1.Notification) error {
+		n.ObjectMeta.Labels[key] = value
+		return nil
+	})
+	return b
+}
+
+func setAnnotation(n *toolchainv1alpha1.Notification, key, value string) {
+	if n.ObjectMeta.Annotations == nil {
+		n.ObjectMeta.Annotations = map[string]string{}
+	}
+	n.ObjectMeta.Annotations[key] = value
+}
+
+// addUnsubscribeToken adds a token allowing the recipient to opt out of the category of the given notification to its context,
+// along with the URL of the registration service which processes it,
+// unless the notification is essential, or is not sent to a user, or no signing key is configured
+func (b *notificationBuilderImpl) addUnsubscribeToken(n *toolchainv1alpha1.Notification) error {
+	userSignupName := n.Annotations[UserSignupAnnotationKey]
+	category := Category(n)
+	if userSignupName == "" || category == NotificationCategoryEssential {
+		return nil
+	}
+	config, err := toolchainconfig.GetToolchainConfig(b.client)
+	if err != nil {
+		return errs.Wrapf(err, "unable to get ToolchainConfig")
+	}
+	signingKey := config.Notifications().UnsubscribeSigningKey()
+	if signingKey == "" {
+		return nil
+	}
+	token, err := NewUnsubscribeToken(signingKey, userSignupName, category, time.Now().Add(config.Notifications().UnsubscribeTokenValidity()))
+	if err != nil {
+		return errs.Wrapf(err, "unable to generate the unsubscribe token")
+	}
+	n.Spec.Context[ContextUnsubscribeToken] = token
+	n.Spec.Context[ContextUnsubscribeURL] = fmt.Sprintf("%s/unsubscribe?token=%s",
+		strings.TrimSuffix(config.RegistrationService().RegistrationServiceURL(), "/"), url.QueryEscape(token))
+	return nil
+}
@@ -6,9 +6,12 @@ import (
 	"github.com/gofrs/uuid"
 
 	"github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/host-operator/pkg/metrics"
 	test2 "github.com/codeready-toolchain/host-operator/test"
 
+	commonconfig "github.com/codeready-toolchain/toolchain-common/pkg/configuration"
 	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	testconfig "github.com/codeready-toolchain/toolchain-common/pkg/test/config"
 	"github.com/stretchr/testify/require"
 )
 
@@ -81,6 +84,34 @@ func TestNotificationBuilder(t *testing.T) {
 		require.Equal(t, userSignup.Spec.Company, notification.Spec.Context["CompanyName"])
 		require.Equal(t, userSignup.Spec.Userid, notification.Spec.Context["UserID"])
 		require.Equal(t, userSignup.Status.CompliantUsername, notification.Spec.Context["UserName"])
+		require.Equal(t, userSignup.Name, notification.Annotations[UserSignupAnnotationKey])
+		require.NotContains(t, notification.Annotations, LocaleAnnotationKey)
+		require.NotContains(t, notification.Spec.Context, ContextUnsubscribeToken)
+	})
+
+	t.Run("test notification builder with user context and locale", func(t *testing.T) {
+		// when
+		userSignup := test2.NewUserSignup()
+		userSignup.Annotations[LocaleAnnotationKey] = "de-CH,de;q=0.9"
+
+		notification, err := NewNotificationBuilder(client, test.HostOperatorNs).
+			WithUserContext(userSignup).
+			Create(userSignup.Annotations[v1alpha1.UserSignupUserEmailAnnotationKey])
+
+		// then
+		require.NoError(t, err)
+		require.Equal(t, "de-CH,de;q=0.9", notification.Annotations[LocaleAnnotationKey])
+	})
+
+	t.Run("test notification builder with locale", func(t *testing.T) {
+		// when
+		notification, err := NewNotificationBuilder(client, test.HostOperatorNs).
+			WithLocale("fr").
+			Create("foo@bar.com")
+
+		// then
+		require.NoError(t, err)
+		require.Equal(t, "fr", notification.Annotations[LocaleAnnotationKey])
 	})
 
 	t.Run("test notification builder with hard coded notification name", func(t *testing.T) {
@@ -130,6 +161,9 @@ func TestNotificationBuilder(t *testing.T) {
 	})
 
 	t.Run("test notification builder with notification type", func(t *testing.T) {
+		// given
+		metrics.Reset()
+
 		// when
 		notification, err := NewNotificationBuilder(client, test.HostOperatorNs).
 			WithNotificationType("TestNotificationType").
@@ -138,5 +172,82 @@ func TestNotificationBuilder(t *testing.T) {
 		// then
 		require.NoError(t, err)
 		require.Equal(t, "TestNotificationType", notification.Labels[v1alpha1.NotificationTypeLabelKey])
+		test2.AssertMetricsCounterEquals(t, 1, metrics.NotificationCreatedCounterVec.WithLabelValues("TestNotificationType"))
+	})
+
+	t.Run("test notification builder with category", func(t *testing.T) {
+		// when
+		notification, err := NewNotificationBuilder(client, test.HostOperatorNs).
+			WithCategory(NotificationCategoryAnnouncements).
+			Create("foo@bar.com")
+
+		// then
+		require.NoError(t, err)
+		require.Equal(t, NotificationCategoryAnnouncements, notification.Annotations[CategoryAnnotationKey])
+	})
+
+	t.Run("test notification builder with label", func(t *testing.T) {
+		// when
+		notification, err := NewNotificationBuilder(client, test.HostOperatorNs).
+			WithLabel("foo", "bar").
+			Create("foo@bar.com")
+
+		// then
+		require.NoError(t, err)
+		require.Equal(t, "bar", notification.Labels["foo"])
+	})
+
+	t.Run("test notification builder with unsubscribe token", func(t *testing.T) {
+		// given
+		restore := test.SetEnvVarAndRestore(t, commonconfig.WatchNamespaceEnvVar, test.HostOperatorNs)
+		t.Cleanup(restore)
+		cfg := commonconfig.NewToolchainConfigObjWithReset(t, testconfig.Notifications().Secret().Ref("notifications-secret"))
+		secret := test.CreateSecret("notifications-secret", test.HostOperatorNs, map[string][]byte{
+			"unsubscribeSigningKey": []byte("s3cr3t"),
+		})
+		client := test.NewFakeClient(t, cfg, secret)
+		userSignup := test2.NewUserSignup()
+
+		t.Run("non-essential notification", func(t *testing.T) {
+			// when
+			notification, err := NewNotificationBuilder(client, test.HostOperatorNs).
+				WithNotificationType(v1alpha1.NotificationTypeDeactivating).
+				WithUserContext(userSignup).
+				Create("foo@bar.com")
+
+			// then
+			require.NoError(t, err)
+			token := notification.Spec.Context[ContextUnsubscribeToken]
+			userSignupName, category, err := ParseUnsubscribeToken("s3cr3t", token)
+			require.NoError(t, err)
+			require.Equal(t, userSignup.Name, userSignupName)
+			require.Equal(t, NotificationCategoryReminders, category)
+			require.Equal(t, "https://registration.crt-placeholder.com/unsubscribe?token="+token, notification.Spec.Context[ContextUnsubscribeURL])
+		})
+
+		t.Run("essential notification", func(t *testing.T) {
+			// when
+			notification, err := NewNotificationBuilder(client, test.HostOperatorNs).
+				WithNotificationType(v1alpha1.NotificationTypeDeactivated).
+				WithUserContext(userSignup).
+				Create("foo@bar.com")
+
+			// then
+			require.NoError(t, err)
+			require.NotContains(t, notification.Spec.Context, ContextUnsubscribeToken)
+			require.NotContains(t, notification.Spec.Context, ContextUnsubscribeURL)
+		})
+
+		t.Run("notification not sent to a user", func(t *testing.T) {
+			// when
+			notification, err := NewNotificationBuilder(client, test.HostOperatorNs).
+				WithCategory(NotificationCategoryAnnouncements).
+				Create("foo@bar.com")
+
+			// then
+			require.NoError(t, err)
+			require.NotContains(t, notification.Spec.Context, ContextUnsubscribeToken)
+			require.NotContains(t, notification.Spec.Context, ContextUnsubscribeURL)
+		})
 	})
 }
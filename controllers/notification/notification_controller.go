@@ -2,9 +2,13 @@ package notification
 
 import (
 	"context"
+	"fmt"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/codeready-toolchain/host-operator/controllers/toolchainconfig"
+	"github.com/codeready-toolchain/host-operator/pkg/metrics"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
 	"github.com/codeready-toolchain/toolchain-common/pkg/condition"
@@ -13,6 +17,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -20,27 +25,107 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+const (
+	// NotificationDeliveryAttempts is the type of the condition which reports the failed attempts to deliver a notification.
+	// Its message mentions the number of failed attempts, the time before which the delivery is not attempted again
+	// (unless the delivery was abandoned) and the last error, which are tracked by the `delivery-attempts` and
+	// `next-delivery-attempt` annotations.
+	NotificationDeliveryAttempts toolchainv1alpha1.ConditionType = "DeliveryAttempts"
+
+	// DeliveryAttemptsAnnotationKey is the key of the annotation holding the number of failed attempts to deliver a notification
+	DeliveryAttemptsAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "delivery-attempts"
+
+	// NextDeliveryAttemptAnnotationKey is the key of the annotation holding the time (in RFC3339 format) before which the delivery
+	// of a notification is not attempted again. The annotation is removed when the delivery is abandoned.
+	NextDeliveryAttemptAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "next-delivery-attempt"
+
+	// NotificationDeliveryRetryingReason is the reason of the `DeliveryAttempts` condition of a notification whose delivery
+	// will be attempted again
+	NotificationDeliveryRetryingReason = "Retrying"
+
+	// NotificationDeliveryFailedReason is the reason of the `Sent=False` and `DeliveryAttempts` conditions of a notification whose
+	// delivery was abandoned, because the error was permanent or because the maximum number of attempts was reached.
+	// Such a notification is not retried, and is kept during the configured retention of the failed notifications.
+	NotificationDeliveryFailedReason = "DeliveryFailed"
+
+	// NotificationSuppressedReason is the reason of the `Sent=False` condition of a notification which was not sent because
+	// an identical notification was sent to the same recipient during the quiet period. Such a notification is deleted like
+	// the sent notifications.
+	NotificationSuppressedReason = "Suppressed"
+
+	// NotificationRateLimitedReason is the reason of the `Sent=False` condition of a notification which is postponed because
+	// the maximum number of notifications sent to the recipient within 24h was reached
+	NotificationRateLimitedReason = "RateLimited"
+
+	// NotificationOptedOutReason is the reason of the `Sent=False` condition of a notification which was not sent because
+	// the recipient opted out of its category. Such a notification is deleted like the sent notifications.
+	NotificationOptedOutReason = "OptedOut"
+
+	// NotificationTemplateOverrideError is the type of the condition of a notification whose template is overridden by an invalid
+	// ConfigMap. Such a notification is sent with the embedded template.
+	NotificationTemplateOverrideError toolchainv1alpha1.ConditionType = "TemplateOverrideError"
+
+	// NotificationInvalidTemplateOverrideReason is the reason of the `TemplateOverrideError` condition
+	NotificationInvalidTemplateOverrideReason = "InvalidTemplateOverride"
 )
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *Reconciler) SetupWithManager(mgr manager.Manager, config toolchainconfig.ToolchainConfig) error {
-	factory := NewNotificationDeliveryServiceFactory(mgr.GetClient(), toolchainconfig.DeliveryServiceFactoryConfig{ToolchainConfig: config})
-	svc, err := factory.CreateNotificationDeliveryService()
-	if err != nil {
+	// the templates can be overridden by ConfigMaps in the operator namespace, which are parsed once until they change
+	r.templateLoader = NewCachingConfigMapTemplateLoader(mgr.GetClient(), r.Namespace)
+	r.newDeliveryService = func(config DeliveryServiceFactoryConfig) (DeliveryService, error) {
+		factory := NewNotificationDeliveryServiceFactory(mgr.GetClient(), config)
+		factory.TemplateLoader = r.templateLoader
+		return factory.CreateNotificationDeliveryService()
+	}
+	// fail fast if the delivery service cannot be created with the startup config
+	if _, err := r.getDeliveryService(log.Log, toolchainconfig.DeliveryServiceFactoryConfig{ToolchainConfig: config}); err != nil {
+		return err
+	}
+
+	// keep track of the notifications pending delivery
+	if err := mgr.Add(&UnsentNotificationsGauge{
+		Client:    mgr.GetClient(),
+		Namespace: r.Namespace,
+		Interval:  unsentNotificationsGaugeInterval,
+	}); err != nil {
+		return err
+	}
+
+	// delete the ledgers of the recipients which were not notified recently
+	if err := mgr.Add(&NotificationLedgerCleanup{
+		Client:    mgr.GetClient(),
+		Namespace: r.Namespace,
+		Interval:  notificationLedgerCleanupInterval,
+	}); err != nil {
 		return err
 	}
-	r.deliveryService = svc
 
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&toolchainv1alpha1.Notification{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		// reload the overrides of the templates when they change
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, r.templateLoader.InvalidationHandler()).
 		Complete(r)
 }
 
 // Reconciler reconciles a Notification object
 type Reconciler struct {
-	Client          client.Client
-	Scheme          *runtime.Scheme
-	deliveryService DeliveryService
+	Client    client.Client
+	Scheme    *runtime.Scheme
+	Namespace string
+	// newDeliveryService creates a delivery service with the given config. It is used to rebuild the delivery service
+	// when the ToolchainConfig or the notification secret changes (eg. when the Mailgun API key is rotated)
+	newDeliveryService func(config DeliveryServiceFactoryConfig) (DeliveryService, error)
+
+	deliveryServiceLock        sync.RWMutex
+	deliveryService            DeliveryService
+	deliveryServiceFingerprint string
+
+	// templateLoader loads the templates overridden by ConfigMaps. A non-caching loader is used if nil.
+	templateLoader *ConfigMapTemplateLoader
 }
 
 //+kubebuilder:rbac:groups=toolchain.dev.openshift.com,resources=notifications,verbs=get;list;watch;create;update;patch;delete
@@ -70,10 +155,15 @@ func (r *Reconciler) Reconcile(ctx context.Context, request ctrl.Request) (ctrl.
 		return reconcile.Result{}, errs.Wrapf(err, "unable to get ToolchainConfig")
 	}
 
-	// if is sent, then check when status was changed and delete it if the requested duration has passed
+	// if is sent (or suppressed, opted out or abandoned), then check when status was changed and delete it if the requested duration has passed
 	completeCond, found := condition.FindConditionByType(notification.Status.Conditions, toolchainv1alpha1.NotificationSent)
-	if found && completeCond.Status == corev1.ConditionTrue {
-		deleted, requeueAfter, err := r.checkTransitionTimeAndDelete(reqLogger, config.Notifications().DurationBeforeNotificationDeletion(), notification, completeCond)
+	if found && (completeCond.Status == corev1.ConditionTrue || completeCond.Reason == NotificationSuppressedReason ||
+		completeCond.Reason == NotificationOptedOutReason || completeCond.Reason == NotificationDeliveryFailedReason) {
+		durationBeforeDeletion := config.Notifications().DurationBeforeNotificationDeletion()
+		if completeCond.Reason == NotificationDeliveryFailedReason {
+			durationBeforeDeletion = config.Notifications().DeliveryFailedRetention()
+		}
+		deleted, requeueAfter, err := r.checkTransitionTimeAndDelete(reqLogger, durationBeforeDeletion, notification, completeCond)
 		if deleted {
 			return reconcile.Result{}, err
 		}
@@ -88,17 +178,99 @@ func (r *Reconciler) Reconcile(ctx context.Context, request ctrl.Request) (ctrl.
 
 	// if the environment is set to e2e do not attempt sending via mailgun
 	if config.Environment() != "e2e-tests" {
+		// if the previous attempt failed, then wait for the backoff to elapse
+		if wait := time.Until(nextDeliveryAttempt(notification)); wait > 0 {
+			reqLogger.Info("the next delivery attempt is postponed", "reconcileAfter", wait.String())
+			return reconcile.Result{
+				Requeue:      true,
+				RequeueAfter: wait,
+			}, nil
+		}
+		// honour the notification preferences of the recipient
+		optedOut, err := r.isOptedOut(notification)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		if optedOut {
+			reqLogger.Info("the recipient opted out of the category of the Notification, so it is not sent", "category", Category(notification))
+			return reconcile.Result{
+				Requeue:      true,
+				RequeueAfter: config.Notifications().DurationBeforeNotificationDeletion(),
+			}, r.setStatusNotificationOptedOut(notification, fmt.Sprintf("the recipient opted out of the '%s' notifications", Category(notification)))
+		}
+		// suppress the duplicate notifications and enforce the limit of notifications per recipient, if enabled
+		now := time.Now()
+		quietPeriod := config.Notifications().QuietPeriod()
+		maxPerDay := config.Notifications().MaxPerRecipientPerDay()
+		var ledger *notificationLedger
+		if (quietPeriod > 0 || maxPerDay > 0) && !isExemptFromLedger(notification) {
+			if ledger, err = loadNotificationLedger(r.Client, notification); err != nil {
+				return reconcile.Result{}, err
+			}
+			if lastSent := ledger.lastSent(notification); quietPeriod > 0 && now.Sub(lastSent) < quietPeriod {
+				reqLogger.Info("an identical Notification was already sent to the recipient during the quiet period, so this one is suppressed",
+					"lastSent", lastSent.String())
+				return reconcile.Result{
+					Requeue:      true,
+					RequeueAfter: config.Notifications().DurationBeforeNotificationDeletion(),
+				}, r.setStatusNotificationSuppressed(notification, fmt.Sprintf("an identical notification was sent at %s", lastSent.Format(time.RFC3339)))
+			}
+			if sent := ledger.sentSince(now.Add(-rateLimitWindow)); maxPerDay > 0 && len(sent) >= maxPerDay {
+				// wait until enough notifications are out of the window
+				wait := sent[len(sent)-maxPerDay].Add(rateLimitWindow).Sub(now)
+				reqLogger.Info("the maximum number of Notifications sent to the recipient was reached, so this one is postponed",
+					"reconcileAfter", wait.String())
+				return reconcile.Result{
+					Requeue:      true,
+					RequeueAfter: wait,
+				}, r.setStatusNotificationRateLimited(notification, fmt.Sprintf("%d notifications were already sent to the recipient within 24h", len(sent)))
+			}
+		}
+
+		// an invalid override of the template is reported, but does not prevent the notification to be sent with the embedded template
+		templateLoader := r.templateLoader
+		if templateLoader == nil {
+			templateLoader = NewConfigMapTemplateLoader(r.Client, notification.Namespace)
+		}
+		if err := templateLoader.ValidateTemplateOverride(notification.Spec.Template); err != nil {
+			if !IsTemplateOverrideError(err) {
+				return reconcile.Result{}, err
+			}
+			reqLogger.Info("the template of the Notification is overridden by an invalid ConfigMap, so the embedded template is used", "error", err.Error())
+			if err := r.setStatusTemplateOverrideError(notification, err.Error()); err != nil {
+				return reconcile.Result{}, err
+			}
+		} else {
+			// the override was fixed since a previous attempt
+			clearTemplateOverrideError(notification)
+		}
+
 		// Send the notification via the configured delivery service
-		err = r.deliveryService.Send(notification)
+		svc, err := r.getDeliveryService(reqLogger, toolchainconfig.DeliveryServiceFactoryConfig{ToolchainConfig: config})
+		if err != nil {
+			return reconcile.Result{}, errs.Wrap(err, "unable to create the notification delivery service")
+		}
+		err = svc.Send(notification)
 		if err != nil {
 			reqLogger.Error(err, "delivery service failed to send notification",
 				"notification spec", notification.Spec,
 			)
-
-			return reconcile.Result{}, r.wrapErrorWithStatusUpdate(reqLogger, notification,
-				r.setStatusNotificationDeliveryError, err, "failed to send notification")
+			return r.handleDeliveryFailure(reqLogger, config.Notifications(), notification, err)
 		}
+		deliveryService := deliveryServiceName(config.Notifications(), notification)
+		metrics.NotificationSentCounterVec.WithLabelValues(notificationType(notification), deliveryService).Inc()
+		metrics.NotificationDeliveryLatencyHistogramVec.WithLabelValues(notificationType(notification), deliveryService).
+			Observe(time.Since(notification.CreationTimestamp.Time).Seconds())
 		reqLogger.Info("Notification has been sent")
+		if ledger != nil {
+			if err := ledger.record(notification, now, quietPeriod); err != nil {
+				// the notification must not be sent again, so it is marked as sent before the error is returned
+				if err := r.updateStatus(reqLogger, notification, r.setStatusNotificationSent); err != nil {
+					reqLogger.Error(err, "status update failed")
+				}
+				return reconcile.Result{}, errs.Wrap(err, "unable to record the Notification in the ledger")
+			}
+		}
 	} else {
 		reqLogger.Info("Notification has been skipped")
 	}
@@ -109,6 +281,45 @@ func (r *Reconciler) Reconcile(ctx context.Context, request ctrl.Request) (ctrl.
 	}, r.updateStatus(reqLogger, notification, r.setStatusNotificationSent)
 }
 
+// isExemptFromLedger returns true if the given notification is neither deduplicated nor rate limited, which is the case
+// of the notifications sent to the admins about the ToolchainStatus, since they must always be delivered
+func isExemptFromLedger(notification *toolchainv1alpha1.Notification) bool {
+	return notification.Labels[toolchainv1alpha1.NotificationTypeLabelKey] == NotificationTypeToolchainStatus
+}
+
+// getDeliveryService returns the delivery service created with the given config. The delivery service is rebuilt if the settings
+// changed since it was created, in which case the reconciles in progress keep using the previous delivery service.
+func (r *Reconciler) getDeliveryService(logger logr.Logger, config DeliveryServiceFactoryConfig) (DeliveryService, error) {
+	if r.newDeliveryService == nil {
+		// the delivery service was provided as-is
+		return r.deliveryService, nil
+	}
+	fingerprint := deliveryServiceConfigFingerprint(config)
+	r.deliveryServiceLock.RLock()
+	svc, current := r.deliveryService, r.deliveryServiceFingerprint
+	r.deliveryServiceLock.RUnlock()
+	if svc != nil && current == fingerprint {
+		return svc, nil
+	}
+
+	r.deliveryServiceLock.Lock()
+	defer r.deliveryServiceLock.Unlock()
+	// the delivery service may have been rebuilt by another reconcile in the meantime
+	if r.deliveryService != nil && r.deliveryServiceFingerprint == fingerprint {
+		return r.deliveryService, nil
+	}
+	svc, err := r.newDeliveryService(config)
+	if err != nil {
+		return nil, err
+	}
+	if r.deliveryService != nil {
+		logger.Info("the notification delivery settings changed, the delivery service was rebuilt", "delivery_service", config.GetNotificationDeliveryService())
+	}
+	r.deliveryService = svc
+	r.deliveryServiceFingerprint = fingerprint
+	return svc, nil
+}
+
 // checkTransitionTimeAndDelete checks if the last transition time has surpassed
 // the duration before the notification should be deleted. If so, the notification is deleted.
 // Returns bool indicating if the notification was deleted, the time before the notification
@@ -117,7 +328,12 @@ func (r *Reconciler) checkTransitionTimeAndDelete(log logr.Logger, durationBefor
 	completeCond toolchainv1alpha1.Condition) (bool, time.Duration, error) {
 
 	log.Info("the Notification is sent so we can deal with its deletion")
-	timeSinceCompletion := time.Since(completeCond.LastTransitionTime.Time)
+	completionTime := completeCond.LastTransitionTime.Time
+	if completeCond.LastUpdatedTime != nil && completeCond.LastUpdatedTime.After(completionTime) {
+		// the delivery was abandoned after the condition was set by a previous failed attempt
+		completionTime = completeCond.LastUpdatedTime.Time
+	}
+	timeSinceCompletion := time.Since(completionTime)
 
 	if timeSinceCompletion >= durationBeforeNotificationDeletion {
 		log.Info("the Notification has been sent for a longer time than the 'durationBeforeNotificationDeletion', so it's ready to be deleted",
@@ -133,6 +349,137 @@ func (r *Reconciler) checkTransitionTimeAndDelete(log logr.Logger, durationBefor
 	return false, diff, nil
 }
 
+// isOptedOut returns true if the recipient of the given notification opted out of its category, according to the preferences
+// stored in the UserSignup of the recipient (if any)
+func (r *Reconciler) isOptedOut(notification *toolchainv1alpha1.Notification) (bool, error) {
+	userSignupName, found := notification.Annotations[UserSignupAnnotationKey]
+	if !found || Category(notification) == NotificationCategoryEssential {
+		return false, nil
+	}
+	userSignup := &toolchainv1alpha1.UserSignup{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Namespace: notification.Namespace, Name: userSignupName}, userSignup); err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, errs.Wrapf(err, "unable to get the UserSignup '%s' of the recipient of Notification '%s'", userSignupName, notification.Name)
+	}
+	return IsOptedOut(userSignup, Category(notification)), nil
+}
+
+// notificationType returns the type of the given notification, or an empty string if it has no type
+func notificationType(notification *toolchainv1alpha1.Notification) string {
+	return notification.Labels[toolchainv1alpha1.NotificationTypeLabelKey]
+}
+
+// deliveryServiceName returns the name of the service delivering the given notification: the delivery channel to which the type
+// of the notification is routed, or else the configured email delivery service (eg. `mailgun` or `smtp`)
+func deliveryServiceName(config toolchainconfig.NotificationsConfig, notification *toolchainv1alpha1.Notification) string {
+	if channel, found := config.NotificationRouting()[notificationType(notification)]; found && channel != toolchainconfig.NotificationChannelEmail {
+		return channel
+	}
+	return config.NotificationDeliveryService()
+}
+
+// handleDeliveryFailure records the failed delivery attempt in the annotations and in the status of the notification.
+// The delivery is retried with an exponential backoff, unless the error is permanent or the maximum number of attempts was reached,
+// in which case the delivery is abandoned and the notification is deleted after the configured retention of the failed notifications.
+func (r *Reconciler) handleDeliveryFailure(logger logr.Logger, config toolchainconfig.NotificationsConfig, notification *toolchainv1alpha1.Notification,
+	deliveryErr error) (reconcile.Result, error) {
+
+	metrics.NotificationDeliveryFailedCounterVec.WithLabelValues(notificationType(notification), deliveryServiceName(config, notification)).Inc()
+	attempts, _ := deliveryAttempts(notification)
+	attempts++
+
+	if IsPermanentDeliveryError(deliveryErr) || attempts >= config.DeliveryMaxAttempts() {
+		logger.Info("abandoning the delivery of the Notification", "attempts", attempts, "permanent", IsPermanentDeliveryError(deliveryErr))
+		if err := r.recordDeliveryAttempts(notification, attempts, time.Time{}); err != nil {
+			return reconcile.Result{}, err
+		}
+		if err := r.setStatusNotificationDeliveryFailed(notification, attempts, deliveryErr.Error()); err != nil {
+			return reconcile.Result{}, errs.Wrapf(err, "unable to record the failed delivery attempt of Notification '%s'", notification.Name)
+		}
+		metrics.NotificationDeadLetteredTotal.Inc()
+		return reconcile.Result{
+			Requeue:      true,
+			RequeueAfter: config.DeliveryFailedRetention(),
+		}, nil
+	}
+
+	backoff := deliveryBackoff(config, attempts)
+	nextAttempt := time.Now().Add(backoff)
+	logger.Info("the delivery of the Notification will be retried", "attempts", attempts, "reconcileAfter", backoff.String())
+	if err := r.recordDeliveryAttempts(notification, attempts, nextAttempt); err != nil {
+		return reconcile.Result{}, err
+	}
+	if err := r.setStatusNotificationDeliveryError(notification, attempts, nextAttempt, deliveryErr.Error()); err != nil {
+		return reconcile.Result{}, errs.Wrapf(err, "unable to record the failed delivery attempt of Notification '%s'", notification.Name)
+	}
+	return reconcile.Result{
+		Requeue:      true,
+		RequeueAfter: backoff,
+	}, nil
+}
+
+// recordDeliveryAttempts sets the number of failed delivery attempts and the time of the next attempt (if any) in the annotations
+// of the given notification
+func (r *Reconciler) recordDeliveryAttempts(notification *toolchainv1alpha1.Notification, attempts int, nextAttempt time.Time) error {
+	if notification.Annotations == nil {
+		notification.Annotations = map[string]string{}
+	}
+	notification.Annotations[DeliveryAttemptsAnnotationKey] = strconv.Itoa(attempts)
+	if nextAttempt.IsZero() {
+		delete(notification.Annotations, NextDeliveryAttemptAnnotationKey)
+	} else {
+		notification.Annotations[NextDeliveryAttemptAnnotationKey] = nextAttempt.UTC().Format(time.RFC3339)
+	}
+	// the status is not updated along with the metadata, hence it is restored once the notification was updated
+	status := notification.Status.DeepCopy()
+	if err := r.Client.Update(context.TODO(), notification); err != nil {
+		return errs.Wrapf(err, "unable to record the failed delivery attempt of Notification '%s'", notification.Name)
+	}
+	notification.Status = *status
+	return nil
+}
+
+const (
+	deliveryRetryingMessageFormat  = "attempt %d failed, next attempt at %s - last error: %s"
+	deliveryAbandonedMessageFormat = "attempt %d failed, delivery abandoned - last error: %s"
+)
+
+// deliveryAttempts returns the number of failed attempts to deliver the given notification, along with the time before which
+// the delivery must not be attempted again (or the zero time if there is no such constraint), as recorded in its annotations
+func deliveryAttempts(notification *toolchainv1alpha1.Notification) (int, time.Time) {
+	attempts, err := strconv.Atoi(notification.Annotations[DeliveryAttemptsAnnotationKey])
+	if err != nil || attempts < 0 {
+		attempts = 0
+	}
+	nextAttempt, err := time.Parse(time.RFC3339, notification.Annotations[NextDeliveryAttemptAnnotationKey])
+	if err != nil {
+		return attempts, time.Time{}
+	}
+	return attempts, nextAttempt
+}
+
+// nextDeliveryAttempt returns the time before which the delivery of the given notification must not be attempted again
+// (or the zero time if there is no such constraint)
+func nextDeliveryAttempt(notification *toolchainv1alpha1.Notification) time.Time {
+	_, next := deliveryAttempts(notification)
+	return next
+}
+
+// deliveryBackoff returns the delay before the next attempt to deliver a notification, after the given number of failed attempts:
+// the initial backoff is doubled after each attempt, up to the max backoff
+func deliveryBackoff(config toolchainconfig.NotificationsConfig, attempts int) time.Duration {
+	backoff := config.DeliveryInitialBackoff()
+	for i := 1; i < attempts && backoff < config.DeliveryMaxBackoff(); i++ {
+		backoff *= 2
+	}
+	if backoff > config.DeliveryMaxBackoff() {
+		backoff = config.DeliveryMaxBackoff()
+	}
+	return backoff
+}
+
 type statusUpdater func(notification *toolchainv1alpha1.Notification, message string) error
 
 func (r *Reconciler) wrapErrorWithStatusUpdate(logger logr.Logger, notification *toolchainv1alpha1.Notification,
@@ -167,7 +514,7 @@ func (r *Reconciler) setStatusNotificationDeletionFailed(notification *toolchain
 		})
 }
 
-func (r *Reconciler) setStatusNotificationDeliveryError(notification *toolchainv1alpha1.Notification, msg string) error {
+func (r *Reconciler) setStatusNotificationDeliveryError(notification *toolchainv1alpha1.Notification, attempts int, nextAttempt time.Time, msg string) error {
 	return r.updateStatusConditions(
 		notification,
 		toolchainv1alpha1.Condition{
@@ -175,9 +522,90 @@ func (r *Reconciler) setStatusNotificationDeliveryError(notification *toolchainv
 			Status:  corev1.ConditionFalse,
 			Reason:  toolchainv1alpha1.NotificationDeliveryErrorReason,
 			Message: msg,
+		},
+		toolchainv1alpha1.Condition{
+			Type:    NotificationDeliveryAttempts,
+			Status:  corev1.ConditionTrue,
+			Reason:  NotificationDeliveryRetryingReason,
+			Message: fmt.Sprintf(deliveryRetryingMessageFormat, attempts, nextAttempt.UTC().Format(time.RFC3339), msg),
+		})
+}
+
+// setStatusNotificationDeliveryFailed records the abandoned delivery. The `Sent=False` condition may already exist since the first failed
+// attempt, so its last updated time is set to let the notification be deleted after the configured duration since the abandonment.
+func (r *Reconciler) setStatusNotificationDeliveryFailed(notification *toolchainv1alpha1.Notification, attempts int, msg string) error {
+	notification.Status.Conditions = condition.AddOrUpdateStatusConditionsWithLastUpdatedTimestamp(notification.Status.Conditions,
+		toolchainv1alpha1.Condition{
+			Type:    toolchainv1alpha1.NotificationSent,
+			Status:  corev1.ConditionFalse,
+			Reason:  NotificationDeliveryFailedReason,
+			Message: fmt.Sprintf("delivery abandoned after %d attempt(s): %s", attempts, msg),
+		},
+		toolchainv1alpha1.Condition{
+			Type:    NotificationDeliveryAttempts,
+			Status:  corev1.ConditionTrue,
+			Reason:  NotificationDeliveryFailedReason,
+			Message: fmt.Sprintf(deliveryAbandonedMessageFormat, attempts, msg),
+		})
+	return r.Client.Status().Update(context.TODO(), notification)
+}
+
+func (r *Reconciler) setStatusNotificationSuppressed(notification *toolchainv1alpha1.Notification, msg string) error {
+	return r.updateStatusConditions(
+		notification,
+		toolchainv1alpha1.Condition{
+			Type:    toolchainv1alpha1.NotificationSent,
+			Status:  corev1.ConditionFalse,
+			Reason:  NotificationSuppressedReason,
+			Message: msg,
 		})
 }
 
+func (r *Reconciler) setStatusNotificationOptedOut(notification *toolchainv1alpha1.Notification, msg string) error {
+	return r.updateStatusConditions(
+		notification,
+		toolchainv1alpha1.Condition{
+			Type:    toolchainv1alpha1.NotificationSent,
+			Status:  corev1.ConditionFalse,
+			Reason:  NotificationOptedOutReason,
+			Message: msg,
+		})
+}
+
+func (r *Reconciler) setStatusNotificationRateLimited(notification *toolchainv1alpha1.Notification, msg string) error {
+	return r.updateStatusConditions(
+		notification,
+		toolchainv1alpha1.Condition{
+			Type:    toolchainv1alpha1.NotificationSent,
+			Status:  corev1.ConditionFalse,
+			Reason:  NotificationRateLimitedReason,
+			Message: msg,
+		})
+}
+
+func (r *Reconciler) setStatusTemplateOverrideError(notification *toolchainv1alpha1.Notification, msg string) error {
+	return r.updateStatusConditions(
+		notification,
+		toolchainv1alpha1.Condition{
+			Type:    NotificationTemplateOverrideError,
+			Status:  corev1.ConditionTrue,
+			Reason:  NotificationInvalidTemplateOverrideReason,
+			Message: msg,
+		})
+}
+
+// clearTemplateOverrideError removes the TemplateOverrideError condition from the status of the given notification.
+// The status is updated along with the next condition.
+func clearTemplateOverrideError(notification *toolchainv1alpha1.Notification) {
+	conditions := make([]toolchainv1alpha1.Condition, 0, len(notification.Status.Conditions))
+	for _, cond := range notification.Status.Conditions {
+		if cond.Type != NotificationTemplateOverrideError {
+			conditions = append(conditions, cond)
+		}
+	}
+	notification.Status.Conditions = conditions
+}
+
 func (r *Reconciler) setStatusNotificationSent(notification *toolchainv1alpha1.Notification, msg string) error {
 	return r.updateStatusConditions(
 		notification,
@@ -6,14 +6,18 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"strconv"
 	"testing"
 	"time"
 
 	ctrl "sigs.k8s.io/controller-runtime"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/host-operator/controllers/toolchainconfig"
 	"github.com/codeready-toolchain/host-operator/pkg/apis"
+	"github.com/codeready-toolchain/host-operator/pkg/metrics"
 	"github.com/codeready-toolchain/host-operator/pkg/templates/notificationtemplates"
+	. "github.com/codeready-toolchain/host-operator/test"
 	ntest "github.com/codeready-toolchain/host-operator/test/notification"
 	commonconfig "github.com/codeready-toolchain/toolchain-common/pkg/configuration"
 	"github.com/codeready-toolchain/toolchain-common/pkg/test"
@@ -23,6 +27,7 @@ import (
 	events2 "github.com/mailgun/mailgun-go/v4/events"
 	"k8s.io/apimachinery/pkg/types"
 
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/spf13/cast"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -32,6 +37,7 @@ import (
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/pointer"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
@@ -130,6 +136,7 @@ func TestNotificationDelivery(t *testing.T) {
 
 	t.Run("test notification delivery ok", func(t *testing.T) {
 		// given
+		metrics.Reset()
 		userSignup := &toolchainv1alpha1.UserSignup{
 			ObjectMeta: newObjectMeta("abc123", "foo@redhat.com"),
 			Spec: toolchainv1alpha1.UserSignupSpec{
@@ -145,6 +152,7 @@ func TestNotificationDelivery(t *testing.T) {
 		notification, err := NewNotificationBuilder(client, test.HostOperatorNs).
 			WithUserContext(userSignup).
 			WithSubjectAndContent("foo", "test content").
+			WithNotificationType(toolchainv1alpha1.NotificationTypeProvisioned).
 			Create("foo@redhat.com")
 		require.NoError(t, err)
 
@@ -154,6 +162,9 @@ func TestNotificationDelivery(t *testing.T) {
 		// then
 		require.NoError(t, err)
 		require.True(t, result.Requeue)
+		AssertMetricsCounterEquals(t, 1, metrics.NotificationCreatedCounterVec.WithLabelValues(toolchainv1alpha1.NotificationTypeProvisioned))
+		AssertMetricsCounterEquals(t, 1, metrics.NotificationSentCounterVec.WithLabelValues(toolchainv1alpha1.NotificationTypeProvisioned, "mailgun"))
+		assert.Equal(t, 1, promtestutil.CollectAndCount(metrics.NotificationDeliveryLatencyHistogramVec))
 
 		// Load the reconciled notification
 		key := types.NamespacedName{
@@ -295,21 +306,687 @@ func TestNotificationDelivery(t *testing.T) {
 		result, err := reconcileNotification(controller, notification)
 
 		// then
-		require.Error(t, err)
-		require.False(t, result.Requeue)
-		require.Equal(t, "failed to send notification: delivery error", err.Error())
+		require.NoError(t, err)
+		require.True(t, result.Requeue)
+		assert.Equal(t, time.Minute, result.RequeueAfter)
 
-		// Load the reconciled notification
-		key := types.NamespacedName{
-			Namespace: test.HostOperatorNs,
-			Name:      notification.Name,
+		assertDeliveryAttempts(t, client, notification.Name, 1, time.Now().Add(time.Minute-time.Second))
+		ntest.AssertThatNotification(t, notification.Name, client).
+			HasConditions(deliveryErrorCond("delivery error"), retryingCond(1, nextDeliveryAttemptOf(t, client, notification.Name), "delivery error"))
+	})
+}
+
+type failingDeliveryService struct {
+	err   error
+	calls int
+}
+
+func (s *failingDeliveryService) Send(_ *toolchainv1alpha1.Notification) error {
+	s.calls++
+	return s.err
+}
+
+func TestNotificationDeliveryRetries(t *testing.T) {
+	// given
+	commonconfig.NewToolchainConfigObjWithReset(t, testconfig.Notifications().DurationBeforeNotificationDeletion("10s"))
+
+	// newNotification creates a notification with the given failed delivery attempts, the given time of the next attempt (if any)
+	// and the given conditions
+	newNotification := func(t *testing.T, cl *test.FakeClient, attempts int, next time.Time, conditions ...toolchainv1alpha1.Condition) *toolchainv1alpha1.Notification {
+		notification, err := NewNotificationBuilder(cl, test.HostOperatorNs).
+			WithSubjectAndContent("foo", "test content").
+			Create("foo@redhat.com")
+		require.NoError(t, err)
+		if attempts > 0 {
+			notification.Annotations = map[string]string{
+				DeliveryAttemptsAnnotationKey: strconv.Itoa(attempts),
+			}
+			if !next.IsZero() {
+				notification.Annotations[NextDeliveryAttemptAnnotationKey] = next.UTC().Format(time.RFC3339)
+			}
+			require.NoError(t, cl.Update(context.TODO(), notification))
 		}
+		if len(conditions) > 0 {
+			notification.Status.Conditions = conditions
+			require.NoError(t, cl.Status().Update(context.TODO(), notification))
+		}
+		return notification
+	}
+
+	t.Run("delivery postponed until the backoff elapsed", func(t *testing.T) {
+		// given
+		ds := &failingDeliveryService{err: errors.New("delivery error")}
+		controller, cl := newController(t, ds)
+		next := time.Now().Add(30 * time.Second)
+		notification := newNotification(t, cl, 1, next, deliveryErrorCond("delivery error"), retryingCond(1, next, "delivery error"))
+
+		// when
+		result, err := reconcileNotification(controller, notification)
+
+		// then
+		require.NoError(t, err)
+		require.True(t, result.Requeue)
+		assert.LessOrEqual(t, result.RequeueAfter, 30*time.Second)
+		assert.Greater(t, result.RequeueAfter, 28*time.Second)
+		assert.Equal(t, 0, ds.calls)
+		ntest.AssertThatNotification(t, notification.Name, cl).
+			HasConditions(deliveryErrorCond("delivery error"), retryingCond(1, next, "delivery error"))
+	})
+
+	t.Run("delivery postponed regardless of the message of the condition", func(t *testing.T) {
+		// given
+		ds := &failingDeliveryService{err: errors.New("delivery error")}
+		controller, cl := newController(t, ds)
+		next := time.Now().Add(30 * time.Second)
+		notification := newNotification(t, cl, 2, next, deliveryErrorCond("delivery error"), toolchainv1alpha1.Condition{
+			Type:    NotificationDeliveryAttempts,
+			Status:  corev1.ConditionTrue,
+			Reason:  NotificationDeliveryRetryingReason,
+			Message: "some other wording",
+		})
+
+		// when
+		result, err := reconcileNotification(controller, notification)
+
+		// then
+		require.NoError(t, err)
+		require.True(t, result.Requeue)
+		assert.Greater(t, result.RequeueAfter, 28*time.Second)
+		assert.Equal(t, 0, ds.calls)
+		assertDeliveryAttempts(t, cl, notification.Name, 2, next)
+	})
+
+	t.Run("delivery retried with a longer backoff", func(t *testing.T) {
+		// given
+		metrics.Reset()
+		ds := &failingDeliveryService{err: errors.New("delivery error")}
+		controller, cl := newController(t, ds)
+		notification := newNotification(t, cl, 2, time.Now().Add(-time.Second), deliveryErrorCond("delivery error"), retryingCond(2, time.Now().Add(-time.Second), "delivery error"))
+
+		// when
+		result, err := reconcileNotification(controller, notification)
+
+		// then
+		require.NoError(t, err)
+		require.True(t, result.Requeue)
+		assert.Equal(t, 4*time.Minute, result.RequeueAfter)
+		assert.Equal(t, 1, ds.calls)
+		assertDeliveryAttempts(t, cl, notification.Name, 3, time.Now().Add(4*time.Minute-time.Second))
+		ntest.AssertThatNotification(t, notification.Name, cl).
+			HasConditions(deliveryErrorCond("delivery error"), retryingCond(3, nextDeliveryAttemptOf(t, cl, notification.Name), "delivery error"))
+		AssertMetricsCounterEquals(t, 1, metrics.NotificationDeliveryFailedCounterVec.WithLabelValues("", "mailgun"))
+		AssertMetricsCounterEquals(t, 0, metrics.NotificationDeadLetteredTotal)
+	})
+
+	t.Run("backoff capped with max backoff", func(t *testing.T) {
+		// given
+		ds := &failingDeliveryService{err: errors.New("delivery error")}
+		controller, cl := newController(t, ds)
+		notification := newNotification(t, cl, 3, time.Time{}, deliveryErrorCond("delivery error"), retryingCond(3, time.Time{}, "delivery error"))
+		cfg := commonconfig.NewToolchainConfigObjWithReset(t)
+		cfg.Annotations = map[string]string{
+			toolchainconfig.HostConfigExtensionAnnotationKey: `{"notifications":{"retries":{"maxAttempts":10,"initialBackoff":"10m","maxBackoff":"30m"}}}`,
+		}
+		require.NoError(t, cl.Create(context.TODO(), cfg))
+		defer commonconfig.ResetCache()
+
+		// when
+		result, err := reconcileNotification(controller, notification)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 30*time.Minute, result.RequeueAfter)
+		assertDeliveryAttempts(t, cl, notification.Name, 4, time.Now().Add(30*time.Minute-time.Second))
+	})
+
+	t.Run("delivery abandoned when max attempts reached", func(t *testing.T) {
+		// given
+		metrics.Reset()
+		ds := &failingDeliveryService{err: errors.New("delivery error")}
+		controller, cl := newController(t, ds)
+		notification := newNotification(t, cl, 4, time.Now().Add(-time.Second), deliveryErrorCond("delivery error"), retryingCond(4, time.Now().Add(-time.Second), "delivery error"))
+
+		// when
+		result, err := reconcileNotification(controller, notification)
+
+		// then
+		require.NoError(t, err)
+		require.True(t, result.Requeue)
+		assert.Equal(t, 30*24*time.Hour, result.RequeueAfter)
+		assert.Equal(t, 1, ds.calls)
+		ntest.AssertThatNotification(t, notification.Name, cl).
+			HasConditions(deliveryFailedCond("delivery abandoned after 5 attempt(s): delivery error"), abandonedCond(5, "delivery error"))
+		AssertMetricsCounterEquals(t, 1, metrics.NotificationDeliveryFailedCounterVec.WithLabelValues("", "mailgun"))
+		AssertMetricsCounterEquals(t, 1, metrics.NotificationDeadLetteredTotal)
+
+		t.Run("abandoned notification not retried", func(t *testing.T) {
+			// when
+			result, err := reconcileNotification(controller, notification)
+
+			// then
+			require.NoError(t, err)
+			require.True(t, result.Requeue)
+			assert.Greater(t, result.RequeueAfter, 719*time.Hour)
+			assert.Equal(t, 1, ds.calls)
+			AssertMetricsCounterEquals(t, 1, metrics.NotificationDeadLetteredTotal)
+		})
+
+		t.Run("abandoned notification kept after the duration before the deletion of the sent notifications", func(t *testing.T) {
+			// given
+			abandoned := deliveryFailedCond("delivery abandoned after 5 attempt(s): delivery error")
+			abandoned.LastTransitionTime = v1.Time{Time: time.Now().Add(-30 * time.Hour)}
+			abandoned.LastUpdatedTime = &v1.Time{Time: time.Now().Add(-25 * time.Hour)}
+			notification := newNotification(t, cl, 5, time.Time{}, abandoned, abandonedCond(5, "delivery error"))
+
+			// when
+			result, err := reconcileNotification(controller, notification)
+
+			// then
+			require.NoError(t, err)
+			require.True(t, result.Requeue)
+			assert.Greater(t, result.RequeueAfter, 694*time.Hour)
+			assert.LessOrEqual(t, result.RequeueAfter, 695*time.Hour)
+			ntest.AssertThatNotification(t, notification.Name, cl).
+				HasConditions(abandoned, abandonedCond(5, "delivery error"))
+		})
+
+		t.Run("abandoned notification deleted after the retention of the failed notifications", func(t *testing.T) {
+			// given
+			abandoned := deliveryFailedCond("delivery abandoned after 5 attempt(s): delivery error")
+			abandoned.LastTransitionTime = v1.Time{Time: time.Now().Add(-35 * 24 * time.Hour)}
+			abandoned.LastUpdatedTime = &v1.Time{Time: time.Now().Add(-31 * 24 * time.Hour)}
+			notification := newNotification(t, cl, 5, time.Time{}, abandoned, abandonedCond(5, "delivery error"))
+
+			// when
+			result, err := reconcileNotification(controller, notification)
+
+			// then
+			require.NoError(t, err)
+			assert.False(t, result.Requeue)
+			assert.Equal(t, 1, ds.calls)
+			AssertThatNotificationIsDeleted(t, cl, notification.Name)
+		})
+	})
+
+	t.Run("delivery abandoned after permanent error", func(t *testing.T) {
+		// given
+		metrics.Reset()
+		ds := &failingDeliveryService{err: NewSMTPDeliveryError("smtp.redhat.com", 550, `550 "no such user"`)}
+		controller, cl := newController(t, ds)
+		notification := newNotification(t, cl, 0, time.Time{})
+
+		// when
+		result, err := reconcileNotification(controller, notification)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 30*24*time.Hour, result.RequeueAfter)
+		ntest.AssertThatNotification(t, notification.Name, cl).
+			HasConditions(deliveryFailedCond(`delivery abandoned after 1 attempt(s): error while delivering notification via SMTP server smtp.redhat.com - 550 "no such user"`),
+				abandonedCond(1, `error while delivering notification via SMTP server smtp.redhat.com - 550 "no such user"`))
+		AssertMetricsCounterEquals(t, 1, metrics.NotificationDeliveryFailedCounterVec.WithLabelValues("", "mailgun"))
+		AssertMetricsCounterEquals(t, 1, metrics.NotificationDeadLetteredTotal)
+	})
+
+	t.Run("failed attempt cannot be recorded", func(t *testing.T) {
+		// given
+		ds := &failingDeliveryService{err: errors.New("delivery error")}
+		controller, cl := newController(t, ds)
+		notification := newNotification(t, cl, 0, time.Time{})
+		cl.MockStatusUpdate = func(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+			return errors.New("mock error")
+		}
+
+		// when
+		_, err := reconcileNotification(controller, notification)
+
+		// then
+		require.EqualError(t, err, fmt.Sprintf("unable to record the failed delivery attempt of Notification '%s': mock error", notification.Name))
+	})
+}
+
+func TestNotificationDeduplicationAndRateLimit(t *testing.T) {
+	// given
+	newNotification := func(t *testing.T, cl *test.FakeClient, subject string) *toolchainv1alpha1.Notification {
+		notification, err := NewNotificationBuilder(cl, test.HostOperatorNs).
+			WithSubjectAndContent(subject, "test content").
+			Create("foo@redhat.com")
+		require.NoError(t, err)
+		return notification
+	}
+	withQuietPeriod := func(cfg *toolchainv1alpha1.ToolchainConfig) *toolchainv1alpha1.ToolchainConfig {
+		cfg.Annotations = map[string]string{
+			toolchainconfig.HostConfigExtensionAnnotationKey: `{"notifications":{"quietPeriod":"24h"}}`,
+		}
+		return cfg
+	}
+
+	t.Run("duplicate notification suppressed", func(t *testing.T) {
+		// given
+		cfg := withQuietPeriod(commonconfig.NewToolchainConfigObjWithReset(t, testconfig.Notifications().DurationBeforeNotificationDeletion("10s")))
+		ds := &recordingDeliveryService{}
+		controller, cl := newController(t, ds, cfg)
+		first := newNotification(t, cl, "foo")
+		_, err := reconcileNotification(controller, first)
+		require.NoError(t, err)
+		duplicate := newNotification(t, cl, "foo")
+
+		// when
+		result, err := reconcileNotification(controller, duplicate)
+
+		// then
+		require.NoError(t, err)
+		require.True(t, result.Requeue)
+		assert.Equal(t, 10*time.Second, result.RequeueAfter)
+		require.Len(t, ds.sent, 1)
+		assert.Equal(t, first.Name, ds.sent[0].Name)
 		instance := &toolchainv1alpha1.Notification{}
-		err = client.Get(context.TODO(), key, instance)
+		require.NoError(t, cl.Get(context.TODO(), test.NamespacedName(test.HostOperatorNs, duplicate.Name), instance))
+		require.Len(t, instance.Status.Conditions, 1)
+		assert.Equal(t, NotificationSuppressedReason, instance.Status.Conditions[0].Reason)
+		assert.Equal(t, corev1.ConditionFalse, instance.Status.Conditions[0].Status)
+		assert.Contains(t, instance.Status.Conditions[0].Message, "an identical notification was sent at ")
+
+		t.Run("suppressed notification deleted when deletion timeout passed", func(t *testing.T) {
+			// given
+			instance.Status.Conditions[0].LastTransitionTime = v1.Time{Time: time.Now().Add(-11 * time.Second)}
+			require.NoError(t, cl.Status().Update(context.TODO(), instance))
+
+			// when
+			_, err := reconcileNotification(controller, duplicate)
+
+			// then
+			require.NoError(t, err)
+			AssertThatNotificationIsDeleted(t, cl, duplicate.Name)
+			assert.Len(t, ds.sent, 1)
+		})
+
+		t.Run("different notification sent", func(t *testing.T) {
+			// given
+			other := newNotification(t, cl, "bar")
+
+			// when
+			_, err := reconcileNotification(controller, other)
+
+			// then
+			require.NoError(t, err)
+			require.Len(t, ds.sent, 2)
+			ntest.AssertThatNotification(t, other.Name, cl).HasConditions(sentCond())
+		})
+	})
+
+	t.Run("duplicate notification with a different unsubscribe token suppressed", func(t *testing.T) {
+		// given
+		ds := &recordingDeliveryService{}
+		controller, cl := newController(t, ds, withQuietPeriod(commonconfig.NewToolchainConfigObjWithReset(t)))
+		newNotificationWithToken := func(expiresAt time.Time) *toolchainv1alpha1.Notification {
+			token, err := NewUnsubscribeToken("secret", "foo", NotificationCategoryReminders, expiresAt)
+			require.NoError(t, err)
+			notification, err := NewNotificationBuilder(cl, test.HostOperatorNs).
+				WithSubjectAndContent("foo", "test content").
+				WithKeysAndValues(map[string]string{
+					ContextUnsubscribeToken: token,
+					ContextUnsubscribeURL:   "https://registration.crt-placeholder.com/unsubscribe?token=" + token,
+				}).
+				Create("foo@redhat.com")
+			require.NoError(t, err)
+			return notification
+		}
+		first := newNotificationWithToken(time.Now().Add(time.Hour))
+		_, err := reconcileNotification(controller, first)
 		require.NoError(t, err)
+		duplicate := newNotificationWithToken(time.Now().Add(2 * time.Hour))
+		require.NotEqual(t, first.Spec.Context[ContextUnsubscribeToken], duplicate.Spec.Context[ContextUnsubscribeToken])
 
-		ntest.AssertThatNotification(t, instance.Name, client).
-			HasConditions(deliveryErrorCond("delivery error"))
+		// when
+		_, err = reconcileNotification(controller, duplicate)
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, ds.sent, 1)
+		assert.Equal(t, first.Name, ds.sent[0].Name)
+		instance := &toolchainv1alpha1.Notification{}
+		require.NoError(t, cl.Get(context.TODO(), test.NamespacedName(test.HostOperatorNs, duplicate.Name), instance))
+		require.Len(t, instance.Status.Conditions, 1)
+		assert.Equal(t, NotificationSuppressedReason, instance.Status.Conditions[0].Reason)
+	})
+
+	t.Run("duplicate notification sent when quiet period is not set", func(t *testing.T) {
+		// given
+		ds := &recordingDeliveryService{}
+		controller, cl := newController(t, ds, commonconfig.NewToolchainConfigObjWithReset(t))
+		first := newNotification(t, cl, "foo")
+		_, err := reconcileNotification(controller, first)
+		require.NoError(t, err)
+		duplicate := newNotification(t, cl, "foo")
+
+		// when
+		_, err = reconcileNotification(controller, duplicate)
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, ds.sent, 2)
+		ntest.AssertThatNotification(t, duplicate.Name, cl).HasConditions(sentCond())
+		cms := &corev1.ConfigMapList{}
+		require.NoError(t, cl.List(context.TODO(), cms, client.HasLabels{LedgerLabelKey}))
+		assert.Empty(t, cms.Items) // no ledger when the feature is disabled
+	})
+
+	t.Run("duplicate admin notification not suppressed", func(t *testing.T) {
+		// given
+		ds := &recordingDeliveryService{}
+		controller, cl := newController(t, ds, withQuietPeriod(commonconfig.NewToolchainConfigObjWithReset(t)))
+		newAdminNotification := func() *toolchainv1alpha1.Notification {
+			notification, err := NewNotificationBuilder(cl, test.HostOperatorNs).
+				WithNotificationType(NotificationTypeToolchainStatus).
+				WithSubjectAndContent("ToolchainStatus has been restored", "test content").
+				Create("admin@redhat.com")
+			require.NoError(t, err)
+			return notification
+		}
+		_, err := reconcileNotification(controller, newAdminNotification())
+		require.NoError(t, err)
+		duplicate := newAdminNotification()
+
+		// when
+		_, err = reconcileNotification(controller, duplicate)
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, ds.sent, 2)
+		ntest.AssertThatNotification(t, duplicate.Name, cl).HasConditions(sentCond())
+	})
+
+	t.Run("notification postponed when max notifications per recipient is reached", func(t *testing.T) {
+		// given
+		ds := &recordingDeliveryService{}
+		controller, cl := newController(t, ds)
+		cfg := commonconfig.NewToolchainConfigObjWithReset(t)
+		cfg.Annotations = map[string]string{
+			toolchainconfig.HostConfigExtensionAnnotationKey: `{"notifications":{"maxPerRecipientPerDay":2}}`,
+		}
+		require.NoError(t, cl.Create(context.TODO(), cfg))
+		for _, subject := range []string{"foo", "bar"} {
+			_, err := reconcileNotification(controller, newNotification(t, cl, subject))
+			require.NoError(t, err)
+		}
+		third := newNotification(t, cl, "baz")
+
+		// when
+		result, err := reconcileNotification(controller, third)
+
+		// then
+		require.NoError(t, err)
+		require.True(t, result.Requeue)
+		assert.Greater(t, result.RequeueAfter, 23*time.Hour)
+		assert.LessOrEqual(t, result.RequeueAfter, 24*time.Hour)
+		require.Len(t, ds.sent, 2)
+		ntest.AssertThatNotification(t, third.Name, cl).HasConditions(toolchainv1alpha1.Condition{
+			Type:    toolchainv1alpha1.NotificationSent,
+			Status:  corev1.ConditionFalse,
+			Reason:  NotificationRateLimitedReason,
+			Message: "2 notifications were already sent to the recipient within 24h",
+		})
+	})
+
+	t.Run("notification marked as sent and error returned when ledger cannot be updated", func(t *testing.T) {
+		// given
+		ds := &recordingDeliveryService{}
+		controller, cl := newController(t, ds, withQuietPeriod(commonconfig.NewToolchainConfigObjWithReset(t)))
+		notification := newNotification(t, cl, "foo")
+		cl.MockCreate = func(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+			if _, ok := obj.(*corev1.ConfigMap); ok {
+				return errors.New("mock error")
+			}
+			return cl.Client.Create(ctx, obj, opts...)
+		}
+
+		// when
+		_, err := reconcileNotification(controller, notification)
+
+		// then
+		require.EqualError(t, err, "unable to record the Notification in the ledger: unable to create the notification ledger: mock error")
+		require.Len(t, ds.sent, 1)
+		ntest.AssertThatNotification(t, notification.Name, cl).HasConditions(sentCond())
+
+		t.Run("notification not sent again", func(t *testing.T) {
+			// when
+			_, err := reconcileNotification(controller, notification)
+
+			// then
+			require.NoError(t, err)
+			require.Len(t, ds.sent, 1)
+		})
+	})
+
+	t.Run("ledger cannot be loaded", func(t *testing.T) {
+		// given
+		ds := &recordingDeliveryService{}
+		controller, cl := newController(t, ds, withQuietPeriod(commonconfig.NewToolchainConfigObjWithReset(t)))
+		notification := newNotification(t, cl, "foo")
+		cl.MockGet = func(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+			if _, ok := obj.(*corev1.ConfigMap); ok {
+				return errors.New("mock error")
+			}
+			return cl.Client.Get(ctx, key, obj)
+		}
+
+		// when
+		_, err := reconcileNotification(controller, notification)
+
+		// then
+		require.EqualError(t, err, "unable to get the notification ledger: mock error")
+		assert.Empty(t, ds.sent)
+	})
+}
+
+func TestNotificationPreferences(t *testing.T) {
+	// given
+	newNotification := func(t *testing.T, cl *test.FakeClient, userSignup *toolchainv1alpha1.UserSignup, notificationType string) *toolchainv1alpha1.Notification {
+		notification, err := NewNotificationBuilder(cl, test.HostOperatorNs).
+			WithSubjectAndContent("foo", "bar").
+			WithNotificationType(notificationType).
+			WithUserContext(userSignup).
+			Create("foo@redhat.com")
+		require.NoError(t, err)
+		return notification
+	}
+	userSignup := NewUserSignup()
+	userSignup.Annotations[OptOutAnnotationKey] = NotificationCategoryReminders
+
+	t.Run("opted out notification not sent", func(t *testing.T) {
+		// given
+		cfg := commonconfig.NewToolchainConfigObjWithReset(t, testconfig.Notifications().DurationBeforeNotificationDeletion("10s"))
+		ds := &recordingDeliveryService{}
+		controller, cl := newController(t, ds, cfg, userSignup)
+		notification := newNotification(t, cl, userSignup, toolchainv1alpha1.NotificationTypeDeactivating)
+
+		// when
+		result, err := reconcileNotification(controller, notification)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 10*time.Second, result.RequeueAfter)
+		assert.Empty(t, ds.sent)
+		ntest.AssertThatNotification(t, notification.Name, cl).HasConditions(toolchainv1alpha1.Condition{
+			Type:    toolchainv1alpha1.NotificationSent,
+			Status:  corev1.ConditionFalse,
+			Reason:  NotificationOptedOutReason,
+			Message: "the recipient opted out of the 'reminders' notifications",
+		})
+
+		t.Run("opted out notification deleted when deletion timeout passed", func(t *testing.T) {
+			// given
+			instance := &toolchainv1alpha1.Notification{}
+			require.NoError(t, cl.Get(context.TODO(), test.NamespacedName(test.HostOperatorNs, notification.Name), instance))
+			instance.Status.Conditions[0].LastTransitionTime = v1.Time{Time: time.Now().Add(-11 * time.Second)}
+			require.NoError(t, cl.Status().Update(context.TODO(), instance))
+
+			// when
+			_, err := reconcileNotification(controller, notification)
+
+			// then
+			require.NoError(t, err)
+			AssertThatNotificationIsDeleted(t, cl, notification.Name)
+			assert.Empty(t, ds.sent)
+		})
+	})
+
+	t.Run("essential notification sent", func(t *testing.T) {
+		// given
+		ds := &recordingDeliveryService{}
+		controller, cl := newController(t, ds, userSignup)
+		notification := newNotification(t, cl, userSignup, toolchainv1alpha1.NotificationTypeDeactivated)
+
+		// when
+		_, err := reconcileNotification(controller, notification)
+
+		// then
+		require.NoError(t, err)
+		assert.Len(t, ds.sent, 1)
+		ntest.AssertThatNotification(t, notification.Name, cl).HasConditions(sentCond())
+	})
+
+	t.Run("notification sent when UserSignup not found", func(t *testing.T) {
+		// given
+		ds := &recordingDeliveryService{}
+		controller, cl := newController(t, ds)
+		notification := newNotification(t, cl, userSignup, toolchainv1alpha1.NotificationTypeDeactivating)
+
+		// when
+		_, err := reconcileNotification(controller, notification)
+
+		// then
+		require.NoError(t, err)
+		assert.Len(t, ds.sent, 1)
+	})
+
+	t.Run("unable to get UserSignup", func(t *testing.T) {
+		// given
+		ds := &recordingDeliveryService{}
+		controller, cl := newController(t, ds, userSignup)
+		notification := newNotification(t, cl, userSignup, toolchainv1alpha1.NotificationTypeDeactivating)
+		cl.MockGet = func(ctx context.Context, key types.NamespacedName, obj client.Object) error {
+			if _, ok := obj.(*toolchainv1alpha1.UserSignup); ok {
+				return errors.New("mock error")
+			}
+			return cl.Client.Get(ctx, key, obj)
+		}
+
+		// when
+		_, err := reconcileNotification(controller, notification)
+
+		// then
+		require.EqualError(t, err, fmt.Sprintf("unable to get the UserSignup '%s' of the recipient of Notification '%s': mock error", userSignup.Name, notification.Name))
+		assert.Empty(t, ds.sent)
+	})
+}
+
+func TestNotificationWithTemplateOverride(t *testing.T) {
+	// given
+	newNotification := func(t *testing.T, cl *test.FakeClient) *toolchainv1alpha1.Notification {
+		notification, err := NewNotificationBuilder(cl, test.HostOperatorNs).
+			WithTemplate("userdeactivated").
+			Create("foo@redhat.com")
+		require.NoError(t, err)
+		return notification
+	}
+	newTemplateConfigMap := func(data map[string]string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "userdeactivated-override",
+				Namespace: test.HostOperatorNs,
+				Labels: map[string]string{
+					TemplateOverrideLabelKey: "userdeactivated",
+				},
+			},
+			Data: data,
+		}
+	}
+
+	t.Run("valid override", func(t *testing.T) {
+		// given
+		ds := &recordingDeliveryService{}
+		controller, cl := newController(t, ds, newTemplateConfigMap(map[string]string{
+			"subject.txt":       "Account deactivated",
+			"notification.html": "<p>Your account was deactivated</p>",
+		}))
+		notification := newNotification(t, cl)
+
+		// when
+		_, err := reconcileNotification(controller, notification)
+
+		// then
+		require.NoError(t, err)
+		assert.Len(t, ds.sent, 1)
+		ntest.AssertThatNotification(t, notification.Name, cl).HasConditions(sentCond())
+	})
+
+	t.Run("invalid override reported but notification sent", func(t *testing.T) {
+		// given
+		ds := &recordingDeliveryService{}
+		controller, cl := newController(t, ds, newTemplateConfigMap(map[string]string{
+			"notification.html": "<p>Your account was deactivated</p>",
+		}))
+		notification := newNotification(t, cl)
+
+		// when
+		_, err := reconcileNotification(controller, notification)
+
+		// then
+		require.NoError(t, err)
+		assert.Len(t, ds.sent, 1)
+		ntest.AssertThatNotification(t, notification.Name, cl).HasConditions(sentCond(), toolchainv1alpha1.Condition{
+			Type:    NotificationTemplateOverrideError,
+			Status:  corev1.ConditionTrue,
+			Reason:  NotificationInvalidTemplateOverrideReason,
+			Message: "invalid override of the 'userdeactivated' notification template: the ConfigMap 'userdeactivated-override' must contain notification.html and subject.txt",
+		})
+	})
+
+	t.Run("override error cleared when the override was fixed", func(t *testing.T) {
+		// given
+		ds := &recordingDeliveryService{}
+		controller, cl := newController(t, ds, newTemplateConfigMap(map[string]string{
+			"subject.txt":       "Account deactivated",
+			"notification.html": "<p>Your account was deactivated</p>",
+		}))
+		notification := newNotification(t, cl)
+		notification.Status.Conditions = []toolchainv1alpha1.Condition{{
+			Type:    NotificationTemplateOverrideError,
+			Status:  corev1.ConditionTrue,
+			Reason:  NotificationInvalidTemplateOverrideReason,
+			Message: "invalid override of the 'userdeactivated' notification template",
+		}}
+		require.NoError(t, cl.Status().Update(context.TODO(), notification))
+
+		// when
+		_, err := reconcileNotification(controller, notification)
+
+		// then
+		require.NoError(t, err)
+		assert.Len(t, ds.sent, 1)
+		ntest.AssertThatNotification(t, notification.Name, cl).HasConditions(sentCond())
+	})
+
+	t.Run("unable to list the overrides", func(t *testing.T) {
+		// given
+		ds := &recordingDeliveryService{}
+		controller, cl := newController(t, ds)
+		notification := newNotification(t, cl)
+		cl.MockList = func(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+			if _, ok := list.(*corev1.ConfigMapList); ok {
+				return errors.New("mock error")
+			}
+			return cl.Client.List(ctx, list, opts...)
+		}
+
+		// when
+		_, err := reconcileNotification(controller, notification)
+
+		// then
+		require.EqualError(t, err, "unable to list the ConfigMaps overriding the 'userdeactivated' notification template: mock error")
+		assert.Empty(t, ds.sent)
 	})
 }
 
@@ -359,6 +1036,50 @@ func deliveryErrorCond(msg string) toolchainv1alpha1.Condition {
 	}
 }
 
+func deliveryFailedCond(msg string) toolchainv1alpha1.Condition {
+	return toolchainv1alpha1.Condition{
+		Type:    toolchainv1alpha1.NotificationSent,
+		Status:  corev1.ConditionFalse,
+		Reason:  NotificationDeliveryFailedReason,
+		Message: msg,
+	}
+}
+
+func retryingCond(attempts int, next time.Time, msg string) toolchainv1alpha1.Condition {
+	return toolchainv1alpha1.Condition{
+		Type:    NotificationDeliveryAttempts,
+		Status:  corev1.ConditionTrue,
+		Reason:  NotificationDeliveryRetryingReason,
+		Message: fmt.Sprintf("attempt %d failed, next attempt at %s - last error: %s", attempts, next.UTC().Format(time.RFC3339), msg),
+	}
+}
+
+func abandonedCond(attempts int, msg string) toolchainv1alpha1.Condition {
+	return toolchainv1alpha1.Condition{
+		Type:    NotificationDeliveryAttempts,
+		Status:  corev1.ConditionTrue,
+		Reason:  NotificationDeliveryFailedReason,
+		Message: fmt.Sprintf("attempt %d failed, delivery abandoned - last error: %s", attempts, msg),
+	}
+}
+
+// nextDeliveryAttemptOf returns the time of the next delivery attempt of the notification with the given name
+func nextDeliveryAttemptOf(t *testing.T, cl client.Client, name string) time.Time {
+	notification := &toolchainv1alpha1.Notification{}
+	require.NoError(t, cl.Get(context.TODO(), test.NamespacedName(test.HostOperatorNs, name), notification))
+	return nextDeliveryAttempt(notification)
+}
+
+// assertDeliveryAttempts asserts the number of failed delivery attempts of the notification with the given name,
+// and that its next delivery attempt is not before the given time
+func assertDeliveryAttempts(t *testing.T, cl client.Client, name string, expectedAttempts int, nextAfter time.Time) {
+	notification := &toolchainv1alpha1.Notification{}
+	require.NoError(t, cl.Get(context.TODO(), test.NamespacedName(test.HostOperatorNs, name), notification))
+	attempts, next := deliveryAttempts(notification)
+	assert.Equal(t, expectedAttempts, attempts)
+	assert.False(t, next.Before(nextAfter.Truncate(time.Second)), "expected %s to be after %s", next, nextAfter)
+}
+
 func deletionCond(msg string) toolchainv1alpha1.Condition {
 	return toolchainv1alpha1.Condition{
 		Type:               toolchainv1alpha1.NotificationDeletionError,
@@ -415,3 +1136,134 @@ func newObjectMeta(name, email string) v1.ObjectMeta {
 		},
 	}
 }
+
+type countingDeliveryService struct {
+	apiKey string
+	sent   int
+}
+
+func (s *countingDeliveryService) Send(notification *toolchainv1alpha1.Notification) error {
+	s.sent++
+	return nil
+}
+
+func TestNotificationDeliveryServiceReload(t *testing.T) {
+	// given
+	cfg := commonconfig.NewToolchainConfigObjWithReset(t, testconfig.Notifications().
+		DurationBeforeNotificationDeletion("10s").
+		Secret().Ref("notifications-secret").MailgunAPIKey("mailgunAPIKey"))
+	secret := test.CreateSecret("notifications-secret", test.HostOperatorNs, map[string][]byte{
+		"mailgunAPIKey": []byte("key-1"),
+	})
+	controller, cl := newController(t, nil, cfg, secret)
+	var services []*countingDeliveryService
+	controller.newDeliveryService = func(config DeliveryServiceFactoryConfig) (DeliveryService, error) {
+		if config.GetNotificationDeliveryService() != toolchainconfig.NotificationDeliveryServiceMailgun {
+			return nil, fmt.Errorf("invalid notification delivery service configuration")
+		}
+		svc := &countingDeliveryService{apiKey: config.GetMailgunAPIKey()}
+		services = append(services, svc)
+		return svc, nil
+	}
+	sendNotification := func(t *testing.T) (ctrl.Result, error) {
+		// distinct subjects, so the notifications are not deduplicated
+		notification, err := NewNotificationBuilder(cl, test.HostOperatorNs).
+			WithSubjectAndContent(t.Name(), "test content").
+			Create("jane@acme.com")
+		require.NoError(t, err)
+		return reconcileNotification(controller, notification)
+	}
+
+	t.Run("delivery service created on first delivery", func(t *testing.T) {
+		// when
+		_, err := sendNotification(t)
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, services, 1)
+		assert.Equal(t, "key-1", services[0].apiKey)
+		assert.Equal(t, 1, services[0].sent)
+	})
+
+	t.Run("delivery service reused when the settings are unchanged", func(t *testing.T) {
+		// given
+		_, err := toolchainconfig.ForceLoadToolchainConfig(cl)
+		require.NoError(t, err)
+
+		// when
+		_, err = sendNotification(t)
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, services, 1)
+		assert.Equal(t, 2, services[0].sent)
+	})
+
+	t.Run("delivery service rebuilt when the secret changes", func(t *testing.T) {
+		// given
+		secret.Data["mailgunAPIKey"] = []byte("key-2")
+		require.NoError(t, cl.Update(context.TODO(), secret))
+		_, err := toolchainconfig.ForceLoadToolchainConfig(cl)
+		require.NoError(t, err)
+
+		// when
+		_, err = sendNotification(t)
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, services, 2)
+		assert.Equal(t, "key-2", services[1].apiKey)
+		assert.Equal(t, 2, services[0].sent) // unchanged
+		assert.Equal(t, 1, services[1].sent)
+	})
+
+	t.Run("notification not sent when the delivery service cannot be rebuilt", func(t *testing.T) {
+		// given
+		cfg.Spec.Host.Notifications.NotificationDeliveryService = pointer.StringPtr("unknown")
+		require.NoError(t, cl.Update(context.TODO(), cfg))
+		_, err := toolchainconfig.ForceLoadToolchainConfig(cl)
+		require.NoError(t, err)
+
+		// when
+		_, err = sendNotification(t)
+
+		// then
+		require.EqualError(t, err, "unable to create the notification delivery service: invalid notification delivery service configuration")
+		require.Len(t, services, 2)
+		assert.Equal(t, 1, services[1].sent)
+	})
+}
+
+func TestDeliveryServiceName(t *testing.T) {
+	// given
+	restore := test.SetEnvVarAndRestore(t, commonconfig.WatchNamespaceEnvVar, test.HostOperatorNs)
+	defer restore()
+	cfg := commonconfig.NewToolchainConfigObjWithReset(t, testconfig.Notifications().NotificationDeliveryService("smtp"))
+	cfg.Annotations = map[string]string{
+		toolchainconfig.HostConfigExtensionAnnotationKey: `{"notifications":{"routing":{"toolchainstatus":"webhook","provisioned":"email"}}}`,
+	}
+	cl := test.NewFakeClient(t, cfg)
+	config, err := toolchainconfig.GetToolchainConfig(cl)
+	require.NoError(t, err)
+	newNotification := func(notificationType string) *toolchainv1alpha1.Notification {
+		return &toolchainv1alpha1.Notification{
+			ObjectMeta: v1.ObjectMeta{
+				Labels: map[string]string{
+					toolchainv1alpha1.NotificationTypeLabelKey: notificationType,
+				},
+			},
+		}
+	}
+
+	t.Run("routed to another channel", func(t *testing.T) {
+		assert.Equal(t, "webhook", deliveryServiceName(config.Notifications(), newNotification("toolchainstatus")))
+	})
+
+	t.Run("routed to the email channel", func(t *testing.T) {
+		assert.Equal(t, "smtp", deliveryServiceName(config.Notifications(), newNotification(toolchainv1alpha1.NotificationTypeProvisioned)))
+	})
+
+	t.Run("not routed", func(t *testing.T) {
+		assert.Equal(t, "smtp", deliveryServiceName(config.Notifications(), newNotification(toolchainv1alpha1.NotificationTypeDeactivated)))
+	})
+}
@@ -2,7 +2,15 @@ package notification
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"regexp"
+	"strings"
 	"text/template"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
@@ -18,48 +26,230 @@ type notificationDeliveryServiceConfig interface {
 }
 
 type TemplateLoader interface {
-	GetNotificationTemplate(name string) (*notificationtemplates.NotificationTemplate, bool, error)
+	// GetNotificationTemplate returns the template with the given name, in the first of the given locales in which it is available,
+	// or in the default locale
+	GetNotificationTemplate(name string, locales ...string) (*notificationtemplates.NotificationTemplate, bool, error)
 }
 
 type DefaultTemplateLoader struct{}
 
-func (l *DefaultTemplateLoader) GetNotificationTemplate(name string) (*notificationtemplates.NotificationTemplate, bool, error) {
-	return notificationtemplates.GetNotificationTemplate(name)
+func (l *DefaultTemplateLoader) GetNotificationTemplate(name string, locales ...string) (*notificationtemplates.NotificationTemplate, bool, error) {
+	return notificationtemplates.GetNotificationTemplate(name, locales...)
 }
 
 type DeliveryService interface {
 	Send(notification *toolchainv1alpha1.Notification) error
 }
 
+// permanentError is implemented by the delivery errors which know if retrying the delivery could succeed
+type permanentError interface {
+	Permanent() bool
+}
+
+// IsPermanentDeliveryError returns true if the given error, returned by a delivery service, means that the notification
+// will never be delivered, no matter how many times the delivery is retried (eg. the recipient was rejected).
+// All other errors (eg. network errors, server unavailable or rate limited) are considered as transient.
+func IsPermanentDeliveryError(err error) bool {
+	var pErr permanentError
+	if errors.As(err, &pErr) {
+		return pErr.Permanent()
+	}
+	return false
+}
+
+// isPermanentHTTPStatus returns true if the given HTTP response status is a client error which will occur again if the
+// same request is sent again, ie, a 4xx status but `408 Request Timeout` and `429 Too Many Requests`.
+// `401 Unauthorized` and `403 Forbidden` are not permanent either, since they are caused by the credentials of the
+// delivery service (eg. an invalid or rotated API key), which can be fixed in the configuration without changing the notification.
+func isPermanentHTTPStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests, http.StatusUnauthorized, http.StatusForbidden:
+		return false
+	}
+	return statusCode >= 400 && statusCode < 500
+}
+
+// NotificationContentError is returned when the subject and the body of a notification cannot be generated
+// (eg. the template does not exist), which is a permanent error
+type NotificationContentError struct {
+	errorMessage string
+}
+
+func (e NotificationContentError) Error() string {
+	return e.errorMessage
+}
+
+func (e NotificationContentError) Permanent() bool {
+	return true
+}
+
+func NewNotificationContentError(errorMessage string) error {
+	return NotificationContentError{
+		errorMessage: errorMessage,
+	}
+}
+
 type DeliveryServiceFactory struct {
-	Client client.Client
-	Config DeliveryServiceFactoryConfig
+	Client         client.Client
+	Config         DeliveryServiceFactoryConfig
+	TemplateLoader TemplateLoader
 }
 
 type DeliveryServiceFactoryConfig interface {
 	notificationDeliveryServiceConfig
 	MailgunConfig
+	SMTPConfig
+	WebhookConfig
+	RoutingConfig
+}
+
+type RoutingConfig interface {
+	GetNotificationRouting() map[string]string
 }
 
 func NewNotificationDeliveryServiceFactory(client client.Client, config DeliveryServiceFactoryConfig) *DeliveryServiceFactory {
 	return &DeliveryServiceFactory{
-		Client: client,
-		Config: config,
+		Client:         client,
+		Config:         config,
+		TemplateLoader: &DefaultTemplateLoader{},
 	}
 }
 
+// CreateNotificationDeliveryService creates the delivery service which sends the notifications by email with the configured service.
+// If some notification types are routed to another delivery channel, then the returned delivery service dispatches
+// the notifications to the channel of their type.
 func (f *DeliveryServiceFactory) CreateNotificationDeliveryService() (DeliveryService, error) {
+	emailService, err := f.createEmailDeliveryService()
+	if err != nil {
+		return nil, err
+	}
+	routes := f.Config.GetNotificationRouting()
+	if len(routes) == 0 {
+		return emailService, nil
+	}
+
+	channels := map[string]DeliveryService{
+		toolchainconfig.NotificationChannelEmail: emailService,
+	}
+	if f.Config.GetWebhookURL() != "" {
+		webhookService, err := NewWebhookNotificationDeliveryService(f.Config, f.TemplateLoader)
+		if err != nil {
+			return nil, err
+		}
+		channels[toolchainconfig.NotificationChannelWebhook] = webhookService
+	}
+	for notificationType, channel := range routes {
+		if _, found := channels[channel]; !found {
+			return nil, fmt.Errorf("invalid notification routing configuration: delivery channel '%s' of notification type '%s' is unknown or not configured", channel, notificationType)
+		}
+	}
+	return &RoutingNotificationDeliveryService{
+		Channels: channels,
+		Routes:   routes,
+	}, nil
+}
+
+func (f *DeliveryServiceFactory) createEmailDeliveryService() (DeliveryService, error) {
 	switch f.Config.GetNotificationDeliveryService() {
 	case toolchainconfig.NotificationDeliveryServiceMailgun:
-		return NewMailgunNotificationDeliveryService(f.Config, &DefaultTemplateLoader{}), nil
+		return NewMailgunNotificationDeliveryService(f.Config, f.TemplateLoader), nil
+	case toolchainconfig.NotificationDeliveryServiceSMTP:
+		return NewSMTPNotificationDeliveryService(f.Config, f.TemplateLoader), nil
 	}
 	return nil, errors.New("invalid notification delivery service configuration")
 }
 
+// deliveryServiceConfigFingerprint returns a hash of all the settings of the given config which are used to create a delivery service,
+// including the credentials read from the notification secret, so that the delivery service can be rebuilt when one of them changes
+func deliveryServiceConfigFingerprint(config DeliveryServiceFactoryConfig) string {
+	settings, _ := json.Marshal([]interface{}{
+		config.GetNotificationDeliveryService(),
+		config.GetMailgunDomain(),
+		config.GetMailgunAPIKey(),
+		config.GetMailgunSenderEmail(),
+		config.GetMailgunReplyToEmail(),
+		config.GetSMTPHost(),
+		config.GetSMTPPort(),
+		config.GetSMTPTLSMode(),
+		config.GetSMTPUsername(),
+		config.GetSMTPPassword(),
+		config.GetSMTPSenderEmail(),
+		config.GetSMTPReplyToEmail(),
+		config.GetWebhookURL(),
+		config.GetWebhookFormat(),
+		config.GetNotificationRouting(), // the keys of the maps are sorted when marshalled
+	})
+	hash := sha256.Sum256(settings)
+	return hex.EncodeToString(hash[:])
+}
+
+// RoutingNotificationDeliveryService sends the notifications via the delivery channel configured for their type,
+// or by email if no channel is configured for their type
+type RoutingNotificationDeliveryService struct {
+	Channels map[string]DeliveryService
+	Routes   map[string]string
+}
+
+func (s *RoutingNotificationDeliveryService) Send(notification *toolchainv1alpha1.Notification) error {
+	channel, found := s.Routes[notification.Labels[toolchainv1alpha1.NotificationTypeLabelKey]]
+	if !found {
+		channel = toolchainconfig.NotificationChannelEmail
+	}
+	svc, found := s.Channels[channel]
+	if !found {
+		return fmt.Errorf("no delivery service for the '%s' delivery channel", channel)
+	}
+	return svc.Send(notification)
+}
+
 type BaseNotificationDeliveryService struct {
 	TemplateLoader TemplateLoader
 }
 
+// GenerateSubjectAndBodies returns the subject, the HTML body and the plain-text body of the given notification.
+// If the notification refers to a template, then they are generated from the template and the notification context,
+// in which the given reply-to address is set. Otherwise, the subject and the content specified in the notification are returned.
+// The plain-text body is generated from the text variant of the template, if any, or else converted from the HTML body.
+func (s *BaseNotificationDeliveryService) GenerateSubjectAndBodies(notification *toolchainv1alpha1.Notification, replyTo string) (string, string, string, error) {
+	if notification.Spec.Template == "" {
+		// If there is no template specified then simply use the subject and content provided by the notification
+		return notification.Spec.Subject, notification.Spec.Content, HTMLToText(notification.Spec.Content), nil
+	}
+
+	template, found, err := s.TemplateLoader.GetNotificationTemplate(notification.Spec.Template,
+		notificationtemplates.PreferredLocales(notification.Annotations[LocaleAnnotationKey])...)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	if !found {
+		return "", "", "", NewNotificationContentError(fmt.Sprintf("notification template [%s] not found", notification.Spec.Template))
+	}
+
+	// Copy the context to a local variable, we will add some more values to it here
+	context := notification.Spec.Context
+	context[ContextReplyTo] = replyTo
+
+	subject, err := s.GenerateContent(context, template.Subject)
+	if err != nil {
+		return "", "", "", NewNotificationContentError(err.Error())
+	}
+
+	body, err := s.GenerateContent(context, template.Content)
+	if err != nil {
+		return "", "", "", NewNotificationContentError(err.Error())
+	}
+
+	if template.TextContent == "" {
+		return subject, body, HTMLToText(body), nil
+	}
+	textBody, err := s.GenerateContent(context, template.TextContent)
+	if err != nil {
+		return "", "", "", NewNotificationContentError(err.Error())
+	}
+	return subject, body, textBody, nil
+}
+
 func (s *BaseNotificationDeliveryService) GenerateContent(context map[string]string,
 	templateDefinition string) (string, error) {
 
@@ -77,3 +267,63 @@ func (s *BaseNotificationDeliveryService) GenerateContent(context map[string]str
 
 	return buf.String(), nil
 }
+
+var (
+	htmlIgnoredElementsRegex = regexp.MustCompile(`(?is)<(head|style|script|title)[^>]*>.*?</(head|style|script|title)>`)
+	htmlPreElementRegex      = regexp.MustCompile(`(?is)<pre[^>]*>(.*?)</pre>`)
+	htmlLinkRegex            = regexp.MustCompile(`(?is)<a\s[^>]*href\s*=\s*["']([^"']*)["'][^>]*>(.*?)</a>`)
+	htmlListItemRegex        = regexp.MustCompile(`(?i)<li[^>]*>`)
+	htmlLineBreakRegex       = regexp.MustCompile(`(?i)<br\s*/?>|</(div|tr|ul|ol|table)>`)
+	htmlParagraphEndRegex    = regexp.MustCompile(`(?i)</(p|h[1-6])>`)
+	htmlTagRegex             = regexp.MustCompile(`<[^>]*>`)
+	whitespacesRegex         = regexp.MustCompile(`\s+`)
+	blankLinesRegex          = regexp.MustCompile(`\n{3,}`)
+)
+
+// preformattedPlaceholder is the placeholder of the content of the `pre` elements, which is kept as-is
+const preformattedPlaceholder = "\x00pre\x00"
+
+var preformattedPlaceholderRegex = regexp.MustCompile(`\n*` + preformattedPlaceholder + `\n*`)
+
+// HTMLToText converts the given HTML document to plain text: the head, the styles and the scripts are dropped, the links are
+// replaced with their label followed by their URL (unless it is the email address of a `mailto:` link), the paragraphs, the line breaks and the list items are preserved, the preformatted
+// text is kept as-is, the whitespaces are collapsed and all other tags are removed.
+func HTMLToText(content string) string {
+	content = htmlIgnoredElementsRegex.ReplaceAllString(content, "")
+	// extract the preformatted text so its whitespaces are not collapsed
+	var preformatted []string
+	content = htmlPreElementRegex.ReplaceAllStringFunc(content, func(pre string) string {
+		preformatted = append(preformatted, htmlPreElementRegex.FindStringSubmatch(pre)[1])
+		return preformattedPlaceholder
+	})
+	content = htmlLinkRegex.ReplaceAllStringFunc(content, func(link string) string {
+		submatches := htmlLinkRegex.FindStringSubmatch(link)
+		url, label := submatches[1], strings.TrimSpace(htmlTagRegex.ReplaceAllString(submatches[2], ""))
+		if label == "" || label == url {
+			return url
+		}
+		if label == strings.TrimPrefix(url, "mailto:") {
+			// no need to repeat the email address
+			return label
+		}
+		return fmt.Sprintf("%s (%s)", label, url)
+	})
+	content = whitespacesRegex.ReplaceAllString(content, " ")
+	content = htmlListItemRegex.ReplaceAllString(content, "\n- ")
+	content = htmlLineBreakRegex.ReplaceAllString(content, "\n")
+	content = htmlParagraphEndRegex.ReplaceAllString(content, "\n\n")
+	content = htmlTagRegex.ReplaceAllString(content, "")
+
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	content = strings.Join(lines, "\n")
+	// the preformatted text is a block on its own
+	content = preformattedPlaceholderRegex.ReplaceAllString(content, "\n"+preformattedPlaceholder+"\n")
+	for _, pre := range preformatted {
+		content = strings.Replace(content, preformattedPlaceholder, strings.Trim(htmlTagRegex.ReplaceAllString(pre, ""), "\n"), 1)
+	}
+	content = html.UnescapeString(content)
+	return strings.TrimSpace(blankLinesRegex.ReplaceAllString(content, "\n\n"))
+}
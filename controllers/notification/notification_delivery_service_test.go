@@ -2,15 +2,21 @@ package notification
 
 import (
 	"errors"
+	"fmt"
 	"testing"
 
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
 	"github.com/codeready-toolchain/host-operator/pkg/templates/notificationtemplates"
 	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 type MockNotificationDeliveryServiceFactoryConfig struct {
 	Mailgun MockMailgunConfiguration
+	SMTP    MockSMTPConfiguration
+	Webhook MockWebhookConfiguration
+	Routing map[string]string
 	Service MockNotificationDeliveryServiceConfig
 }
 
@@ -45,6 +51,61 @@ func (c *MockNotificationDeliveryServiceFactoryConfig) GetMailgunReplyToEmail()
 	return c.Mailgun.ReplyToEmail
 }
 
+type MockSMTPConfiguration struct {
+	Host         string
+	Port         int
+	TLSMode      string
+	Username     string
+	Password     string
+	SenderEmail  string
+	ReplyToEmail string
+}
+
+func (c *MockNotificationDeliveryServiceFactoryConfig) GetSMTPHost() string {
+	return c.SMTP.Host
+}
+
+func (c *MockNotificationDeliveryServiceFactoryConfig) GetSMTPPort() int {
+	return c.SMTP.Port
+}
+
+func (c *MockNotificationDeliveryServiceFactoryConfig) GetSMTPTLSMode() string {
+	return c.SMTP.TLSMode
+}
+
+func (c *MockNotificationDeliveryServiceFactoryConfig) GetSMTPUsername() string {
+	return c.SMTP.Username
+}
+
+func (c *MockNotificationDeliveryServiceFactoryConfig) GetSMTPPassword() string {
+	return c.SMTP.Password
+}
+
+func (c *MockNotificationDeliveryServiceFactoryConfig) GetSMTPSenderEmail() string {
+	return c.SMTP.SenderEmail
+}
+
+func (c *MockNotificationDeliveryServiceFactoryConfig) GetSMTPReplyToEmail() string {
+	return c.SMTP.ReplyToEmail
+}
+
+type MockWebhookConfiguration struct {
+	URL    string
+	Format string
+}
+
+func (c *MockNotificationDeliveryServiceFactoryConfig) GetWebhookURL() string {
+	return c.Webhook.URL
+}
+
+func (c *MockNotificationDeliveryServiceFactoryConfig) GetWebhookFormat() string {
+	return c.Webhook.Format
+}
+
+func (c *MockNotificationDeliveryServiceFactoryConfig) GetNotificationRouting() map[string]string {
+	return c.Routing
+}
+
 func NewNotificationDeliveryServiceFactoryConfig(domain, apiKey, senderEmail, replyToEmail, service string) DeliveryServiceFactoryConfig {
 	return &MockNotificationDeliveryServiceFactoryConfig{
 		Mailgun: MockMailgunConfiguration{
@@ -62,7 +123,12 @@ type MockTemplateLoader struct {
 	templates map[string]*notificationtemplates.NotificationTemplate
 }
 
-func (l *MockTemplateLoader) GetNotificationTemplate(name string) (*notificationtemplates.NotificationTemplate, bool, error) {
+func (l *MockTemplateLoader) GetNotificationTemplate(name string, locales ...string) (*notificationtemplates.NotificationTemplate, bool, error) {
+	for _, locale := range locales {
+		if template := l.templates[name+"."+locale]; template != nil {
+			return template, true, nil
+		}
+	}
 	template := l.templates[name]
 	if template != nil {
 		return template, true, nil
@@ -70,13 +136,21 @@ func (l *MockTemplateLoader) GetNotificationTemplate(name string) (*notification
 	return nil, false, errors.New("template not found")
 }
 
+// NewMockTemplateLoader returns a template loader with the given templates. The templates with a locale are only returned
+// when this locale is requested.
 func NewMockTemplateLoader(templates ...*notificationtemplates.NotificationTemplate) TemplateLoader {
 	tmpl := make(map[string]*notificationtemplates.NotificationTemplate)
 	for _, template := range templates {
-		tmpl[template.Name] = &notificationtemplates.NotificationTemplate{
-			Subject: template.Subject,
-			Content: template.Content,
-			Name:    template.Name,
+		key := template.Name
+		if template.Locale != "" {
+			key = template.Name + "." + template.Locale
+		}
+		tmpl[key] = &notificationtemplates.NotificationTemplate{
+			Subject:     template.Subject,
+			Content:     template.Content,
+			TextContent: template.TextContent,
+			Name:        template.Name,
+			Locale:      template.Locale,
 		}
 	}
 	return &MockTemplateLoader{tmpl}
@@ -97,6 +171,69 @@ func TestNotificationDeliveryServiceFactory(t *testing.T) {
 		require.IsType(t, &MailgunNotificationDeliveryService{}, svc)
 	})
 
+	t.Run("factory configured with smtp delivery service", func(t *testing.T) {
+		// when
+		factory := NewNotificationDeliveryServiceFactory(client, &MockNotificationDeliveryServiceFactoryConfig{
+			SMTP: MockSMTPConfiguration{
+				Host:        "smtp.foo.com",
+				Port:        587,
+				TLSMode:     "starttls",
+				SenderEmail: "noreply@foo.com",
+			},
+			Service: MockNotificationDeliveryServiceConfig{service: "smtp"},
+		})
+		svc, err := factory.CreateNotificationDeliveryService()
+
+		// then
+		require.NoError(t, err)
+		require.IsType(t, &SMTPNotificationDeliveryService{}, svc)
+		assert.Equal(t, "smtp.foo.com", svc.(*SMTPNotificationDeliveryService).Host)
+	})
+
+	t.Run("factory configured with routing to webhook", func(t *testing.T) {
+		// when
+		factory := NewNotificationDeliveryServiceFactory(client, &MockNotificationDeliveryServiceFactoryConfig{
+			Mailgun: MockMailgunConfiguration{Domain: "mg.foo.com", APIKey: "abcd12345", SenderEmail: "noreply@foo.com"},
+			Webhook: MockWebhookConfiguration{URL: "https://hooks.foo.com/abc", Format: "slack"},
+			Routing: map[string]string{"toolchainstatus": "webhook", "deactivated": "email"},
+			Service: MockNotificationDeliveryServiceConfig{service: "mailgun"},
+		})
+		svc, err := factory.CreateNotificationDeliveryService()
+
+		// then
+		require.NoError(t, err)
+		require.IsType(t, &RoutingNotificationDeliveryService{}, svc)
+		channels := svc.(*RoutingNotificationDeliveryService).Channels
+		require.Len(t, channels, 2)
+		assert.IsType(t, &MailgunNotificationDeliveryService{}, channels["email"])
+		assert.IsType(t, &WebhookNotificationDeliveryService{}, channels["webhook"])
+	})
+
+	t.Run("factory configured with routing to unconfigured webhook", func(t *testing.T) {
+		// when
+		factory := NewNotificationDeliveryServiceFactory(client, &MockNotificationDeliveryServiceFactoryConfig{
+			Routing: map[string]string{"toolchainstatus": "webhook"},
+			Service: MockNotificationDeliveryServiceConfig{service: "mailgun"},
+		})
+		_, err := factory.CreateNotificationDeliveryService()
+
+		// then
+		require.EqualError(t, err, "invalid notification routing configuration: delivery channel 'webhook' of notification type 'toolchainstatus' is unknown or not configured")
+	})
+
+	t.Run("factory configured with invalid webhook format", func(t *testing.T) {
+		// when
+		factory := NewNotificationDeliveryServiceFactory(client, &MockNotificationDeliveryServiceFactoryConfig{
+			Webhook: MockWebhookConfiguration{URL: "https://hooks.foo.com/abc", Format: "xml"},
+			Routing: map[string]string{"toolchainstatus": "webhook"},
+			Service: MockNotificationDeliveryServiceConfig{service: "mailgun"},
+		})
+		_, err := factory.CreateNotificationDeliveryService()
+
+		// then
+		require.EqualError(t, err, "invalid webhook format: 'xml'")
+	})
+
 	t.Run("factory configured with invalid delivery service", func(t *testing.T) {
 
 		// when
@@ -111,6 +248,39 @@ func TestNotificationDeliveryServiceFactory(t *testing.T) {
 	})
 }
 
+func TestDeliveryServiceConfigFingerprint(t *testing.T) {
+	// given
+	newConfig := func() *MockNotificationDeliveryServiceFactoryConfig {
+		return &MockNotificationDeliveryServiceFactoryConfig{
+			Service: MockNotificationDeliveryServiceConfig{service: "mailgun"},
+			Mailgun: MockMailgunConfiguration{Domain: "mg.foo.com", APIKey: "key-1"},
+			Routing: map[string]string{"toolchainstatus": "webhook", "deactivated": "email"},
+		}
+	}
+
+	t.Run("same settings", func(t *testing.T) {
+		assert.Equal(t, deliveryServiceConfigFingerprint(newConfig()), deliveryServiceConfigFingerprint(newConfig()))
+	})
+
+	t.Run("different credentials", func(t *testing.T) {
+		config := newConfig()
+		config.Mailgun.APIKey = "key-2"
+		assert.NotEqual(t, deliveryServiceConfigFingerprint(newConfig()), deliveryServiceConfigFingerprint(config))
+	})
+
+	t.Run("different delivery service", func(t *testing.T) {
+		config := newConfig()
+		config.Service.service = "smtp"
+		assert.NotEqual(t, deliveryServiceConfigFingerprint(newConfig()), deliveryServiceConfigFingerprint(config))
+	})
+
+	t.Run("different routing", func(t *testing.T) {
+		config := newConfig()
+		config.Routing["deactivated"] = "webhook"
+		assert.NotEqual(t, deliveryServiceConfigFingerprint(newConfig()), deliveryServiceConfigFingerprint(config))
+	})
+}
+
 func TestBaseNotificationDeliveryServiceGenerateContent(t *testing.T) {
 	// given
 	baseService := &BaseNotificationDeliveryService{}
@@ -163,3 +333,229 @@ func TestBaseNotificationDeliveryServiceGenerateContent(t *testing.T) {
 		require.Equal(t, "Increase developer productivity at Red Hat today!", content)
 	})
 }
+
+func TestBaseNotificationDeliveryServiceGenerateSubjectAndBodies(t *testing.T) {
+	// given
+	baseService := &BaseNotificationDeliveryService{
+		TemplateLoader: NewMockTemplateLoader(
+			&notificationtemplates.NotificationTemplate{
+				Subject: "Goodbye {{.FirstName}}",
+				Content: "Your account was deactivated",
+				Name:    "deactivated",
+			},
+			&notificationtemplates.NotificationTemplate{
+				Subject:     "Auf Wiedersehen {{.FirstName}}",
+				Content:     "<p>Ihr Konto wurde deaktiviert</p>",
+				TextContent: "Ihr Konto wurde deaktiviert, {{.FirstName}}",
+				Name:        "deactivated",
+				Locale:      "de",
+			}),
+	}
+	newNotification := func(locale string) *toolchainv1alpha1.Notification {
+		n := &toolchainv1alpha1.Notification{
+			Spec: toolchainv1alpha1.NotificationSpec{
+				Template: "deactivated",
+				Context: map[string]string{
+					"FirstName": "John",
+				},
+			},
+		}
+		if locale != "" {
+			n.Annotations = map[string]string{
+				LocaleAnnotationKey: locale,
+			}
+		}
+		return n
+	}
+
+	t.Run("default locale", func(t *testing.T) {
+		// when
+		subject, body, textBody, err := baseService.GenerateSubjectAndBodies(newNotification(""), "")
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "Goodbye John", subject)
+		assert.Equal(t, "Your account was deactivated", body)
+		assert.Equal(t, "Your account was deactivated", textBody) // converted from the HTML body
+	})
+
+	t.Run("user locale", func(t *testing.T) {
+		// when
+		subject, body, textBody, err := baseService.GenerateSubjectAndBodies(newNotification("fr-FR,de;q=0.8"), "")
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "Auf Wiedersehen John", subject)
+		assert.Equal(t, "<p>Ihr Konto wurde deaktiviert</p>", body)
+		assert.Equal(t, "Ihr Konto wurde deaktiviert, John", textBody) // from the text variant of the template
+	})
+
+	t.Run("without template", func(t *testing.T) {
+		// when
+		subject, body, textBody, err := baseService.GenerateSubjectAndBodies(&toolchainv1alpha1.Notification{
+			Spec: toolchainv1alpha1.NotificationSpec{
+				Subject: "ToolchainStatus",
+				Content: "<div><pre>ToolchainStatus is back to ready status.</pre></div>",
+			},
+		}, "")
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "ToolchainStatus", subject)
+		assert.Equal(t, "<div><pre>ToolchainStatus is back to ready status.</pre></div>", body)
+		assert.Equal(t, "ToolchainStatus is back to ready status.", textBody)
+	})
+
+	t.Run("invalid text template", func(t *testing.T) {
+		// given
+		baseService := &BaseNotificationDeliveryService{
+			TemplateLoader: NewMockTemplateLoader(
+				&notificationtemplates.NotificationTemplate{
+					Subject:     "Goodbye",
+					Content:     "Your account was deactivated",
+					TextContent: "Goodbye {{.FirstName",
+					Name:        "deactivated",
+				}),
+		}
+
+		// when
+		_, _, _, err := baseService.GenerateSubjectAndBodies(newNotification(""), "")
+
+		// then
+		require.EqualError(t, err, "template: template:1: unclosed action")
+		assert.True(t, IsPermanentDeliveryError(err))
+	})
+}
+
+func TestUnsubscribeLink(t *testing.T) {
+	// given
+	baseService := &BaseNotificationDeliveryService{
+		TemplateLoader: &DefaultTemplateLoader{},
+	}
+	newNotification := func(template string, context map[string]string) *toolchainv1alpha1.Notification {
+		return &toolchainv1alpha1.Notification{
+			Spec: toolchainv1alpha1.NotificationSpec{
+				Template: template,
+				Context:  context,
+			},
+		}
+	}
+
+	for _, template := range []string{"userdeactivating", "spaceexpiring", "tierchanged"} {
+		t.Run(template, func(t *testing.T) {
+
+			t.Run("with unsubscribe URL", func(t *testing.T) {
+				// when
+				_, body, _, err := baseService.GenerateSubjectAndBodies(newNotification(template, map[string]string{
+					ContextUnsubscribeURL: "https://registration.example.com/unsubscribe?token=abc.def",
+				}), "")
+
+				// then
+				require.NoError(t, err)
+				assert.Contains(t, body, `<a href="https://registration.example.com/unsubscribe?token=abc.def">unsubscribe</a>`)
+			})
+
+			t.Run("without unsubscribe URL", func(t *testing.T) {
+				// when
+				_, body, _, err := baseService.GenerateSubjectAndBodies(newNotification(template, map[string]string{}), "")
+
+				// then
+				require.NoError(t, err)
+				assert.NotContains(t, body, "unsubscribe")
+			})
+		})
+	}
+}
+
+func TestHTMLToText(t *testing.T) {
+	for name, tc := range map[string]struct {
+		html     string
+		expected string
+	}{
+		"plain text": {
+			html:     "hello",
+			expected: "hello",
+		},
+		"document": {
+			html: `<!DOCTYPE html>
+<html lang="en">
+<head>
+    <title>Notice</title>
+    <style>
+        p { margin: 30px 0; }
+    </style>
+</head>
+<body style="padding: 10px;">
+<div style="max-width: 750px;">
+    <p>
+        You are receiving this email because your email account
+        john@redhat.com was provisioned.
+    </p>
+
+    <p>
+        Sign up again at <a href="https://sandbox.redhat.com">the registration page</a> or at https://dn.dev/DevNationSlack.
+    </p>
+
+    <p>
+        Thanks,<br />
+        The Developer Sandbox team &amp; friends
+    </p>
+</div>
+</body>
+</html>`,
+			expected: "You are receiving this email because your email account john@redhat.com was provisioned.\n\n" +
+				"Sign up again at the registration page (https://sandbox.redhat.com) or at https://dn.dev/DevNationSlack.\n\n" +
+				"Thanks,\n" +
+				"The Developer Sandbox team & friends",
+		},
+		"lists and headers": {
+			html:     `<h3>Issues</h3><ul><li>host: <b>not ready</b></li><li>member: <a href="https://member">https://member</a></li></ul>`,
+			expected: "Issues\n\n- host: not ready\n- member: https://member",
+		},
+		"preformatted text": {
+			html:     "<div>Status:</div><div><pre>{\n  \"ready\": false\n}</pre></div>",
+			expected: "Status:\n{\n  \"ready\": false\n}",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, HTMLToText(tc.html))
+		})
+	}
+}
+
+func TestIsPermanentDeliveryError(t *testing.T) {
+
+	t.Run("permanent errors", func(t *testing.T) {
+		for name, err := range map[string]error{
+			"mailgun bad request":    NewMailgunDeliveryError("", "", 400, "bad request"),
+			"smtp mailbox not found": NewSMTPDeliveryError("smtp.redhat.com", 550, "no such user"),
+			"webhook not found":      NewWebhookDeliveryError(404, "not found", "unexpected response status"),
+			"template not found":     NewNotificationContentError("notification template [foo] not found"),
+			"wrapped content error":  fmt.Errorf("failed to send notification: %w", NewNotificationContentError("no subject or body specified for notification")),
+		} {
+			t.Run(name, func(t *testing.T) {
+				assert.True(t, IsPermanentDeliveryError(err))
+			})
+		}
+	})
+
+	t.Run("transient errors", func(t *testing.T) {
+		for name, err := range map[string]error{
+			"mailgun no response":       NewMailgunDeliveryError("", "", -1, "connection refused"),
+			"mailgun too many requests": NewMailgunDeliveryError("", "", 429, "too many requests"),
+			"mailgun server error":      NewMailgunDeliveryError("", "", 503, "service unavailable"),
+			"smtp mailbox busy":         NewSMTPDeliveryError("smtp.redhat.com", 451, "try again later"),
+			"smtp no reply":             NewSMTPDeliveryError("smtp.redhat.com", 0, "i/o timeout"),
+			"webhook request timeout":   NewWebhookDeliveryError(408, "", "unexpected response status"),
+			"webhook server error":      NewWebhookDeliveryError(500, "", "unexpected response status"),
+			"mailgun invalid api key":   NewMailgunDeliveryError("", "", 401, "unauthorized"),
+			"webhook invalid token":     NewWebhookDeliveryError(403, "invalid_token", "unexpected response status"),
+			"smtp invalid credential":   NewSMTPDeliveryError("smtp.redhat.com", 535, "authentication failed"),
+			"unclassified error":        errors.New("delivery error"),
+		} {
+			t.Run(name, func(t *testing.T) {
+				assert.False(t, IsPermanentDeliveryError(err))
+			})
+		}
+	})
+}
@@ -0,0 +1,191 @@
+package notification
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+
+	errs "github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// LedgerConfigMapNamePrefix is the prefix of the name of the ConfigMaps which keep track of the notifications sent to each recipient,
+	// so that duplicate notifications can be suppressed and the number of notifications per recipient can be limited.
+	// The name is suffixed with the hash of the recipient: the email addresses of the recipients are not stored in the ledgers.
+	LedgerConfigMapNamePrefix = "notification-ledger-"
+	// LedgerLabelKey is the key of the label set on the ledger ConfigMaps
+	LedgerLabelKey = toolchainv1alpha1.LabelKeyPrefix + "notification-ledger"
+
+	// ledgerNotificationKeyPrefix is the prefix of the ledger entries holding the last time a given notification was sent
+	ledgerNotificationKeyPrefix = "n."
+	// ledgerSentKey is the key of the ledger entry holding the times the notifications were sent to the recipient
+	ledgerSentKey = "sent"
+
+	// rateLimitWindow is the window in which the notifications sent to a recipient are counted
+	rateLimitWindow = 24 * time.Hour
+)
+
+// notificationLedger is the record of the notifications sent to a recipient, stored in a ConfigMap per recipient.
+// The entries are pruned when they become irrelevant, ie, after the quiet period or after the rate limit window,
+// so the size of a ledger is bounded by the number of notifications sent to its recipient within these periods.
+type notificationLedger struct {
+	client    client.Client
+	configMap *corev1.ConfigMap
+	exists    bool
+}
+
+// loadNotificationLedger loads the ledger of the recipient of the given notification from its ConfigMap in the namespace of the notification.
+// The ledger is empty if the ConfigMap does not exist yet.
+func loadNotificationLedger(cl client.Client, notification *toolchainv1alpha1.Notification) (*notificationLedger, error) {
+	name := LedgerConfigMapNamePrefix + recipientHash(notification)
+	cm := &corev1.ConfigMap{}
+	if err := cl.Get(context.TODO(), types.NamespacedName{Namespace: notification.Namespace, Name: name}, cm); err != nil {
+		if !errors.IsNotFound(err) {
+			return nil, errs.Wrap(err, "unable to get the notification ledger")
+		}
+		return &notificationLedger{
+			client: cl,
+			configMap: &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: notification.Namespace,
+					Name:      name,
+					Labels: map[string]string{
+						LedgerLabelKey: "true",
+					},
+				},
+			},
+		}, nil
+	}
+	return &notificationLedger{
+		client:    cl,
+		configMap: cm,
+		exists:    true,
+	}, nil
+}
+
+// lastSent returns the last time a notification identical to the given one was sent to the same recipient (or the zero time if never)
+func (l *notificationLedger) lastSent(notification *toolchainv1alpha1.Notification) time.Time {
+	t, err := time.Parse(time.RFC3339, l.configMap.Data[ledgerNotificationKeyPrefix+notificationHash(notification)])
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// sentSince returns the times, in ascending order, at which notifications were sent to the recipient since the given time
+func (l *notificationLedger) sentSince(since time.Time) []time.Time {
+	var times []time.Time
+	for _, v := range strings.Fields(l.configMap.Data[ledgerSentKey]) {
+		if t, err := time.Parse(time.RFC3339, v); err == nil && t.After(since) {
+			times = append(times, t)
+		}
+	}
+	sort.Slice(times, func(i, j int) bool {
+		return times[i].Before(times[j])
+	})
+	return times
+}
+
+// record records that the given notification was sent at the given time, prunes the entries older than the quiet period
+// (or the rate limit window) and saves the ledger
+func (l *notificationLedger) record(notification *toolchainv1alpha1.Notification, now time.Time, quietPeriod time.Duration) error {
+	if l.configMap.Data == nil {
+		l.configMap.Data = map[string]string{}
+	}
+	l.prune(now, quietPeriod)
+	// record
+	sentAt := now.Format(time.RFC3339)
+	if quietPeriod > 0 {
+		l.configMap.Data[ledgerNotificationKeyPrefix+notificationHash(notification)] = sentAt
+	}
+	l.configMap.Data[ledgerSentKey] = strings.TrimSpace(l.configMap.Data[ledgerSentKey] + " " + sentAt)
+
+	if !l.exists {
+		if err := l.client.Create(context.TODO(), l.configMap); err != nil {
+			return errs.Wrap(err, "unable to create the notification ledger")
+		}
+		l.exists = true
+		return nil
+	}
+	return errs.Wrap(l.client.Update(context.TODO(), l.configMap), "unable to update the notification ledger")
+}
+
+// prune deletes the entries older than the quiet period (or the rate limit window).
+// Returns `true` if some entries were deleted.
+func (l *notificationLedger) prune(now time.Time, quietPeriod time.Duration) bool {
+	pruned := false
+	for key, value := range l.configMap.Data {
+		switch {
+		case strings.HasPrefix(key, ledgerNotificationKeyPrefix):
+			if t, err := time.Parse(time.RFC3339, value); err != nil || !t.After(now.Add(-quietPeriod)) {
+				delete(l.configMap.Data, key)
+				pruned = true
+			}
+		case key == ledgerSentKey:
+			var recent []string
+			for _, v := range strings.Fields(value) {
+				if t, err := time.Parse(time.RFC3339, v); err == nil && t.After(now.Add(-rateLimitWindow)) {
+					recent = append(recent, v)
+				}
+			}
+			if len(recent) == 0 {
+				delete(l.configMap.Data, key)
+				pruned = true
+			} else if joined := strings.Join(recent, " "); joined != value {
+				l.configMap.Data[key] = joined
+				pruned = true
+			}
+		}
+	}
+	return pruned
+}
+
+// notificationHash returns the hash of the recipient, the type, the template, the subject, the content and the context
+// of the given notification (except the reply-to address and the unsubscribe token and URL)
+func notificationHash(notification *toolchainv1alpha1.Notification) string {
+	keys := make([]string, 0, len(notification.Spec.Context))
+	for k := range notification.Spec.Context {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	h := sha256.New()
+	for _, v := range []string{
+		notification.Spec.Recipient,
+		notification.Labels[toolchainv1alpha1.NotificationTypeLabelKey],
+		notification.Spec.Template,
+		notification.Spec.Subject,
+		notification.Spec.Content,
+	} {
+		h.Write([]byte(v))
+		h.Write([]byte{0})
+	}
+	for _, k := range keys {
+		switch k {
+		case ContextReplyTo:
+			// set by the delivery services, not part of the notification itself
+			continue
+		case ContextUnsubscribeToken, ContextUnsubscribeURL:
+			// the unsubscribe token embeds its expiry, hence differs for each notification
+			continue
+		}
+		h.Write([]byte(k + "=" + notification.Spec.Context[k]))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recipientHash returns the hash of the recipient of the given notification
+func recipientHash(notification *toolchainv1alpha1.Notification) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(notification.Spec.Recipient))))
+	return hex.EncodeToString(sum[:])
+}
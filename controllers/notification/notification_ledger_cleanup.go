@@ -0,0 +1,81 @@
+package notification
+
+import (
+	"context"
+	"time"
+
+	"github.com/codeready-toolchain/host-operator/controllers/toolchainconfig"
+
+	"github.com/go-logr/logr"
+	errs "github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// notificationLedgerCleanupInterval is the interval between two cleanups of the notification ledgers
+const notificationLedgerCleanupInterval = time.Hour
+
+// NotificationLedgerCleanup periodically prunes the expired entries of the notification ledgers, and deletes the ledgers which
+// have no entry left, since a ledger is otherwise only pruned when a new notification is sent to its recipient
+type NotificationLedgerCleanup struct {
+	Client    client.Client
+	Namespace string
+	Interval  time.Duration
+}
+
+// Start cleans up the ledgers at the configured interval, until the given context is done
+func (c *NotificationLedgerCleanup) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("notification-ledger-cleanup")
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+	for {
+		if err := c.Cleanup(logger, time.Now()); err != nil {
+			logger.Error(err, "unable to clean up the notification ledgers")
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Cleanup prunes the entries of the notification ledgers which expired at the given time, and deletes the ledgers which have
+// no entry left
+func (c *NotificationLedgerCleanup) Cleanup(logger logr.Logger, now time.Time) error {
+	config, err := toolchainconfig.GetToolchainConfig(c.Client)
+	if err != nil {
+		return errs.Wrap(err, "unable to get the ToolchainConfig")
+	}
+	cms := &corev1.ConfigMapList{}
+	if err := c.Client.List(context.TODO(), cms, client.InNamespace(c.Namespace), client.HasLabels{LedgerLabelKey}); err != nil {
+		return errs.Wrap(err, "unable to list the notification ledgers")
+	}
+	deleted := 0
+	for i := range cms.Items {
+		ledger := &notificationLedger{
+			client:    c.Client,
+			configMap: &cms.Items[i],
+			exists:    true,
+		}
+		if !ledger.prune(now, config.Notifications().QuietPeriod()) {
+			continue
+		}
+		if len(ledger.configMap.Data) == 0 {
+			if err := c.Client.Delete(context.TODO(), ledger.configMap); err != nil && !errors.IsNotFound(err) {
+				return errs.Wrapf(err, "unable to delete the notification ledger '%s'", ledger.configMap.Name)
+			}
+			deleted++
+			continue
+		}
+		if err := c.Client.Update(context.TODO(), ledger.configMap); err != nil && !errors.IsNotFound(err) {
+			return errs.Wrapf(err, "unable to update the notification ledger '%s'", ledger.configMap.Name)
+		}
+	}
+	if deleted > 0 {
+		logger.Info("deleted the expired notification ledgers", "deleted", deleted, "remaining", len(cms.Items)-deleted)
+	}
+	return nil
+}
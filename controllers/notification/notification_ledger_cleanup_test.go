@@ -0,0 +1,224 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/host-operator/controllers/toolchainconfig"
+	commonconfig "github.com/codeready-toolchain/toolchain-common/pkg/configuration"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestNotificationLedgerCleanup(t *testing.T) {
+	// given
+	restore := test.SetEnvVarAndRestore(t, commonconfig.WatchNamespaceEnvVar, test.HostOperatorNs)
+	t.Cleanup(restore)
+	now := time.Now().UTC().Truncate(time.Second)
+	newLedger := func(name string, data map[string]string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: test.HostOperatorNs,
+				Name:      LedgerConfigMapNamePrefix + name,
+				Labels: map[string]string{
+					LedgerLabelKey: "true",
+				},
+			},
+			Data: data,
+		}
+	}
+	ago := func(d time.Duration) string {
+		return now.Add(-d).Format(time.RFC3339)
+	}
+	newInitObjs := func(t *testing.T) []runtime.Object {
+		config := commonconfig.NewToolchainConfigObjWithReset(t)
+		config.Annotations = map[string]string{
+			toolchainconfig.HostConfigExtensionAnnotationKey: `{"notifications":{"quietPeriod":"2h"}}`,
+		}
+		return []runtime.Object{
+			config,
+			newLedger("expired", map[string]string{
+				ledgerNotificationKeyPrefix + "foo": ago(26 * time.Hour),
+				ledgerSentKey:                       ago(27*time.Hour) + " " + ago(26*time.Hour),
+			}),
+			newLedger("partially-expired", map[string]string{
+				ledgerNotificationKeyPrefix + "foo": ago(3 * time.Hour),
+				ledgerNotificationKeyPrefix + "bar": ago(time.Hour),
+				ledgerSentKey:                       ago(25*time.Hour) + " " + ago(time.Hour),
+			}),
+			newLedger("recent", map[string]string{
+				ledgerNotificationKeyPrefix + "foo": ago(time.Hour),
+				ledgerSentKey:                       ago(time.Hour),
+			}),
+			// not a ledger
+			&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: test.HostOperatorNs,
+					Name:      "other",
+				},
+			},
+		}
+	}
+	assertLedgers := func(t *testing.T, cl client.Client) {
+		cm := &corev1.ConfigMap{}
+		err := cl.Get(context.TODO(), test.NamespacedName(test.HostOperatorNs, LedgerConfigMapNamePrefix+"expired"), cm)
+		require.True(t, apierrors.IsNotFound(err))
+		cm = &corev1.ConfigMap{}
+		require.NoError(t, cl.Get(context.TODO(), test.NamespacedName(test.HostOperatorNs, LedgerConfigMapNamePrefix+"partially-expired"), cm))
+		assert.Equal(t, map[string]string{
+			ledgerNotificationKeyPrefix + "bar": ago(time.Hour),
+			ledgerSentKey:                       ago(time.Hour),
+		}, cm.Data)
+		cm = &corev1.ConfigMap{}
+		require.NoError(t, cl.Get(context.TODO(), test.NamespacedName(test.HostOperatorNs, LedgerConfigMapNamePrefix+"recent"), cm))
+		assert.Equal(t, map[string]string{
+			ledgerNotificationKeyPrefix + "foo": ago(time.Hour),
+			ledgerSentKey:                       ago(time.Hour),
+		}, cm.Data)
+		require.NoError(t, cl.Get(context.TODO(), test.NamespacedName(test.HostOperatorNs, "other"), cm))
+	}
+
+	t.Run("cleanup", func(t *testing.T) {
+		// given
+		cl := test.NewFakeClient(t, newInitObjs(t)...)
+		cleanup := &NotificationLedgerCleanup{
+			Client:    cl,
+			Namespace: test.HostOperatorNs,
+		}
+
+		// when
+		err := cleanup.Cleanup(logf.Log, now)
+
+		// then
+		require.NoError(t, err)
+		assertLedgers(t, cl)
+	})
+
+	t.Run("recipient notified after the cleanup", func(t *testing.T) {
+		// given
+		cl := test.NewFakeClient(t, newInitObjs(t)...)
+		cleanup := &NotificationLedgerCleanup{
+			Client:    cl,
+			Namespace: test.HostOperatorNs,
+		}
+		require.NoError(t, cleanup.Cleanup(logf.Log, now))
+		notification := &toolchainv1alpha1.Notification{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: test.HostOperatorNs,
+			},
+			Spec: toolchainv1alpha1.NotificationSpec{
+				Recipient: "foo@redhat.com",
+				Subject:   "foo",
+				Content:   "bar",
+			},
+		}
+		ledger, err := loadNotificationLedger(cl, notification)
+		require.NoError(t, err)
+
+		// when
+		err = ledger.record(notification, now, 2*time.Hour)
+
+		// then the ledger is created again
+		require.NoError(t, err)
+		ledger, err = loadNotificationLedger(cl, notification)
+		require.NoError(t, err)
+		assert.Equal(t, now, ledger.lastSent(notification))
+	})
+
+	t.Run("start", func(t *testing.T) {
+		// given
+		cl := test.NewFakeClient(t, newInitObjs(t)...)
+		cleanup := &NotificationLedgerCleanup{
+			Client:    cl,
+			Namespace: test.HostOperatorNs,
+			Interval:  time.Hour,
+		}
+		ctx, cancel := context.WithCancel(context.TODO())
+		done := make(chan error)
+
+		// when
+		go func() {
+			done <- cleanup.Start(ctx)
+		}()
+
+		// then
+		require.Eventually(t, func() bool {
+			cms := &corev1.ConfigMapList{}
+			require.NoError(t, cl.List(context.TODO(), cms, client.HasLabels{LedgerLabelKey}))
+			return len(cms.Items) == 2
+		}, 5*time.Second, 10*time.Millisecond)
+		cancel()
+		require.NoError(t, <-done)
+	})
+
+	t.Run("failures", func(t *testing.T) {
+
+		t.Run("unable to list the ledgers", func(t *testing.T) {
+			// given
+			cl := test.NewFakeClient(t, newInitObjs(t)...)
+			cl.MockList = func(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+				if _, ok := list.(*corev1.ConfigMapList); ok {
+					return errors.New("mock error")
+				}
+				return cl.Client.List(ctx, list, opts...)
+			}
+			cleanup := &NotificationLedgerCleanup{
+				Client:    cl,
+				Namespace: test.HostOperatorNs,
+			}
+
+			// when
+			err := cleanup.Cleanup(logf.Log, now)
+
+			// then
+			require.EqualError(t, err, "unable to list the notification ledgers: mock error")
+		})
+
+		t.Run("unable to delete a ledger", func(t *testing.T) {
+			// given
+			cl := test.NewFakeClient(t, newInitObjs(t)...)
+			cl.MockDelete = func(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+				return errors.New("mock error")
+			}
+			cleanup := &NotificationLedgerCleanup{
+				Client:    cl,
+				Namespace: test.HostOperatorNs,
+			}
+
+			// when
+			err := cleanup.Cleanup(logf.Log, now)
+
+			// then
+			require.EqualError(t, err, "unable to delete the notification ledger 'notification-ledger-expired': mock error")
+		})
+
+		t.Run("unable to update a ledger", func(t *testing.T) {
+			// given
+			cl := test.NewFakeClient(t, newInitObjs(t)...)
+			cl.MockUpdate = func(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+				return errors.New("mock error")
+			}
+			cleanup := &NotificationLedgerCleanup{
+				Client:    cl,
+				Namespace: test.HostOperatorNs,
+			}
+
+			// when
+			err := cleanup.Cleanup(logf.Log, now)
+
+			// then
+			require.EqualError(t, err, "unable to update the notification ledger 'notification-ledger-partially-expired': mock error")
+		})
+	})
+}
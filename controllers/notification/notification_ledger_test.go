@@ -0,0 +1,238 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestNotificationHash(t *testing.T) {
+	// given
+	newNotification := func(recipient, notificationType string, context map[string]string) *toolchainv1alpha1.Notification {
+		return &toolchainv1alpha1.Notification{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "foo-" + notificationType,
+				Labels: map[string]string{
+					toolchainv1alpha1.NotificationTypeLabelKey: notificationType,
+				},
+			},
+			Spec: toolchainv1alpha1.NotificationSpec{
+				Recipient: recipient,
+				Template:  notificationType,
+				Context:   context,
+			},
+		}
+	}
+	notification := newNotification("foo@redhat.com", "deactivated", map[string]string{"FirstName": "Foo", "LastName": "Bar"})
+
+	t.Run("same hash", func(t *testing.T) {
+		// when
+		other := newNotification("foo@redhat.com", "deactivated", map[string]string{"LastName": "Bar", "FirstName": "Foo", ContextReplyTo: "info@redhat.com"})
+		other.Name = "other"
+
+		// then
+		assert.Equal(t, notificationHash(notification), notificationHash(other))
+	})
+
+	t.Run("same hash with different unsubscribe tokens", func(t *testing.T) {
+		// given
+		first, err := NewUnsubscribeToken("secret", "foo", NotificationCategoryReminders, time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		second, err := NewUnsubscribeToken("secret", "foo", NotificationCategoryReminders, time.Now().Add(2*time.Hour))
+		require.NoError(t, err)
+		require.NotEqual(t, first, second)
+
+		// when
+		withFirst := newNotification("foo@redhat.com", "deactivated", map[string]string{"FirstName": "Foo", "LastName": "Bar",
+			ContextUnsubscribeToken: first, ContextUnsubscribeURL: "https://registration.crt-placeholder.com/unsubscribe?token=" + first})
+		withSecond := newNotification("foo@redhat.com", "deactivated", map[string]string{"FirstName": "Foo", "LastName": "Bar",
+			ContextUnsubscribeToken: second, ContextUnsubscribeURL: "https://registration.crt-placeholder.com/unsubscribe?token=" + second})
+
+		// then
+		assert.Equal(t, notificationHash(notification), notificationHash(withFirst))
+		assert.Equal(t, notificationHash(withFirst), notificationHash(withSecond))
+	})
+
+	t.Run("different hash", func(t *testing.T) {
+		for name, other := range map[string]*toolchainv1alpha1.Notification{
+			"different recipient": newNotification("bar@redhat.com", "deactivated", map[string]string{"FirstName": "Foo", "LastName": "Bar"}),
+			"different type":      newNotification("foo@redhat.com", "deactivating", map[string]string{"FirstName": "Foo", "LastName": "Bar"}),
+			"different context":   newNotification("foo@redhat.com", "deactivated", map[string]string{"FirstName": "Foo", "LastName": "Baz"}),
+		} {
+			t.Run(name, func(t *testing.T) {
+				assert.NotEqual(t, notificationHash(notification), notificationHash(other))
+			})
+		}
+	})
+}
+
+func TestNotificationLedger(t *testing.T) {
+	// given
+	notification := &toolchainv1alpha1.Notification{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: test.HostOperatorNs,
+		},
+		Spec: toolchainv1alpha1.NotificationSpec{
+			Recipient: "foo@redhat.com",
+			Subject:   "foo",
+			Content:   "bar",
+		},
+	}
+	other := &toolchainv1alpha1.Notification{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: test.HostOperatorNs,
+		},
+		Spec: toolchainv1alpha1.NotificationSpec{
+			Recipient: "Foo@redhat.com", // same recipient
+			Subject:   "other",
+			Content:   "bar",
+		},
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+
+	t.Run("empty ledger", func(t *testing.T) {
+		// given
+		cl := test.NewFakeClient(t)
+
+		// when
+		ledger, err := loadNotificationLedger(cl, notification)
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, ledger.lastSent(notification).IsZero())
+		assert.Empty(t, ledger.sentSince(now.Add(-24*time.Hour)))
+	})
+
+	t.Run("record notifications", func(t *testing.T) {
+		// given
+		cl := test.NewFakeClient(t)
+		ledger, err := loadNotificationLedger(cl, notification)
+		require.NoError(t, err)
+
+		// when
+		err1 := ledger.record(notification, now.Add(-2*time.Hour), time.Hour)
+		err2 := ledger.record(other, now, time.Hour)
+
+		// then
+		require.NoError(t, err1)
+		require.NoError(t, err2)
+		ledger, err = loadNotificationLedger(cl, notification)
+		require.NoError(t, err)
+		assert.True(t, ledger.lastSent(notification).IsZero()) // pruned since out of the quiet period
+		assert.Equal(t, now, ledger.lastSent(other))
+		assert.Equal(t, []time.Time{now.Add(-2 * time.Hour), now}, ledger.sentSince(now.Add(-24*time.Hour)))
+		assert.Equal(t, []time.Time{now}, ledger.sentSince(now.Add(-time.Hour)))
+		assert.NotContains(t, strings.ToLower(ledger.configMap.Name), "redhat.com")
+		for key := range ledger.configMap.Data {
+			assert.NotContains(t, strings.ToLower(key), "redhat.com")
+		}
+		assert.Equal(t, "true", ledger.configMap.Labels[LedgerLabelKey])
+	})
+
+	t.Run("one ledger per recipient", func(t *testing.T) {
+		// given
+		cl := test.NewFakeClient(t)
+		ledger, err := loadNotificationLedger(cl, notification)
+		require.NoError(t, err)
+		require.NoError(t, ledger.record(notification, now, time.Hour))
+		bar := &toolchainv1alpha1.Notification{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: test.HostOperatorNs,
+			},
+			Spec: toolchainv1alpha1.NotificationSpec{
+				Recipient: "bar@redhat.com",
+				Subject:   "foo",
+				Content:   "bar",
+			},
+		}
+
+		// when
+		barLedger, err := loadNotificationLedger(cl, bar)
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, barLedger.lastSent(bar).IsZero())
+		assert.Empty(t, barLedger.sentSince(now.Add(-24*time.Hour)))
+		cms := &corev1.ConfigMapList{}
+		require.NoError(t, cl.List(context.TODO(), cms, client.HasLabels{LedgerLabelKey}))
+		assert.Len(t, cms.Items, 1)
+	})
+
+	t.Run("recipient entries pruned after 24h", func(t *testing.T) {
+		// given
+		cl := test.NewFakeClient(t)
+		ledger, err := loadNotificationLedger(cl, notification)
+		require.NoError(t, err)
+		require.NoError(t, ledger.record(notification, now.Add(-25*time.Hour), time.Hour))
+
+		// when
+		err = ledger.record(other, now, 0)
+
+		// then
+		require.NoError(t, err)
+		cm := &corev1.ConfigMap{}
+		require.NoError(t, cl.Get(context.TODO(), test.NamespacedName(test.HostOperatorNs, LedgerConfigMapNamePrefix+recipientHash(notification)), cm))
+		assert.Equal(t, map[string]string{"sent": now.Format(time.RFC3339)}, cm.Data) // no notification entry since the quiet period is 0
+	})
+
+	t.Run("failures", func(t *testing.T) {
+
+		t.Run("unable to get ledger", func(t *testing.T) {
+			// given
+			cl := test.NewFakeClient(t)
+			cl.MockGet = func(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+				return errors.New("mock error")
+			}
+
+			// when
+			_, err := loadNotificationLedger(cl, notification)
+
+			// then
+			require.EqualError(t, err, "unable to get the notification ledger: mock error")
+		})
+
+		t.Run("unable to create ledger", func(t *testing.T) {
+			// given
+			cl := test.NewFakeClient(t)
+			cl.MockCreate = func(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+				return errors.New("mock error")
+			}
+			ledger, err := loadNotificationLedger(cl, notification)
+			require.NoError(t, err)
+
+			// when
+			err = ledger.record(notification, now, time.Hour)
+
+			// then
+			require.EqualError(t, err, "unable to create the notification ledger: mock error")
+		})
+
+		t.Run("unable to update ledger", func(t *testing.T) {
+			// given
+			cl := test.NewFakeClient(t)
+			ledger, err := loadNotificationLedger(cl, notification)
+			require.NoError(t, err)
+			require.NoError(t, ledger.record(notification, now, time.Hour))
+			cl.MockUpdate = func(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+				return errors.New("mock error")
+			}
+
+			// when
+			err = ledger.record(other, now, time.Hour)
+
+			// then
+			require.EqualError(t, err, "unable to update the notification ledger: mock error")
+		})
+	})
+}
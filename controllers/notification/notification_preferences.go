@@ -0,0 +1,143 @@
+package notification
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+)
+
+const (
+	// NotificationCategoryEssential is the category of the notifications about the lifecycle of the account of a user
+	// (eg. provisioned or deactivated), which are always sent
+	NotificationCategoryEssential = "essential"
+	// NotificationCategoryReminders is the category of the notifications reminding the users of an upcoming event
+	// (eg. the deactivation of their account or the expiration of a space)
+	NotificationCategoryReminders = "reminders"
+	// NotificationCategoryAnnouncements is the category of the notifications announcing changes in the service
+	NotificationCategoryAnnouncements = "announcements"
+
+	// CategoryAnnotationKey is the key of the annotation holding the category of a notification
+	CategoryAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "notification-category"
+
+	// UserSignupAnnotationKey is the key of the annotation holding the name of the UserSignup of the recipient of a notification,
+	// whose notification preferences apply
+	UserSignupAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "usersignup"
+
+	// OptOutAnnotationKey is the key of the annotation of a UserSignup holding the comma-separated categories of notifications
+	// the user opted out of (eg. `reminders,announcements`). The essential notifications are sent regardless of this annotation.
+	// The annotation is set by the registration service, eg. when the user follows an unsubscribe link.
+	OptOutAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "notification-opt-out"
+
+	// NotificationTypeToolchainStatus is the type of the notifications sent to the admins when the ToolchainStatus becomes unready or is restored
+	NotificationTypeToolchainStatus = "toolchainstatus"
+	// NotificationTypeSpaceExpiring is the type of the notifications sent to the users bound to a Space which is about to expire
+	NotificationTypeSpaceExpiring = "spaceexpiring"
+	// NotificationTypeTierChanged is the type of the notifications sent to a user whose tier was changed by an admin
+	NotificationTypeTierChanged = "tierchanged"
+	// NotificationTypeTierMigrated is the type of the notifications sent to a user migrated from a deprecated tier to its successor
+	NotificationTypeTierMigrated = "tiermigrated"
+	// NotificationTypeBanned is the type of the notifications sent to a banned user
+	NotificationTypeBanned = "banned"
+
+	// ContextUnsubscribeToken is the key of the unsubscribe token in the context of the non-essential notifications sent to a user
+	ContextUnsubscribeToken = "UnsubscribeToken"
+	// ContextUnsubscribeURL is the key of the URL of the registration service processing the unsubscribe token,
+	// in the context of the non-essential notifications sent to a user
+	ContextUnsubscribeURL = "UnsubscribeURL"
+)
+
+// notificationTypeCategories contains the categories of the notification types which are not essential.
+// The notifications about the provisioning, the deactivation and the ban of an account are essential.
+var notificationTypeCategories = map[string]string{
+	toolchainv1alpha1.NotificationTypeDeactivating: NotificationCategoryReminders,
+	NotificationTypeSpaceExpiring:                  NotificationCategoryReminders,
+	NotificationTypeTierChanged:                    NotificationCategoryAnnouncements,
+	NotificationTypeTierMigrated:                   NotificationCategoryAnnouncements,
+}
+
+// Category returns the category of the given notification: the category set by the notification builder,
+// or else the category of its type, or else the essential category
+func Category(notification *toolchainv1alpha1.Notification) string {
+	if category, found := notification.Annotations[CategoryAnnotationKey]; found {
+		return category
+	}
+	if category, found := notificationTypeCategories[notification.Labels[toolchainv1alpha1.NotificationTypeLabelKey]]; found {
+		return category
+	}
+	return NotificationCategoryEssential
+}
+
+// IsOptedOut returns true if the given user opted out of the given category of notifications.
+// A user cannot opt out of the essential notifications.
+func IsOptedOut(userSignup *toolchainv1alpha1.UserSignup, category string) bool {
+	if category == NotificationCategoryEssential {
+		return false
+	}
+	for _, optedOut := range strings.Split(userSignup.Annotations[OptOutAnnotationKey], ",") {
+		if strings.TrimSpace(optedOut) == category {
+			return true
+		}
+	}
+	return false
+}
+
+// unsubscribeTokenPayload is the payload of an unsubscribe token
+type unsubscribeTokenPayload struct {
+	UserSignup string `json:"u"`
+	Category   string `json:"c"`
+	// ExpiresAt is the time (in seconds since the epoch) after which the token is rejected
+	ExpiresAt int64 `json:"e"`
+}
+
+// NewUnsubscribeToken returns a token allowing the owner of the given UserSignup to opt out of the given category of notifications,
+// until the given expiration time.
+// The token is made of the base64 (URL) encoded JSON payload and of its base64 (URL) encoded HMAC-SHA256 signature, separated by a dot.
+func NewUnsubscribeToken(signingKey, userSignupName, category string, expiresAt time.Time) (string, error) {
+	payload, err := json.Marshal(unsubscribeTokenPayload{
+		UserSignup: userSignupName,
+		Category:   category,
+		ExpiresAt:  expiresAt.Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	encodedPayload := base64.RawURLEncoding.EncodeToString(payload)
+	return encodedPayload + "." + base64.RawURLEncoding.EncodeToString(sign(signingKey, encodedPayload)), nil
+}
+
+// ParseUnsubscribeToken verifies the signature and the expiration time of the given unsubscribe token and returns the name
+// of the UserSignup and the category of notifications it was issued for
+func ParseUnsubscribeToken(signingKey, token string) (string, string, error) {
+	segments := strings.Split(token, ".")
+	if len(segments) != 2 {
+		return "", "", errors.New("invalid unsubscribe token: malformed")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(segments[1])
+	if err != nil || !hmac.Equal(signature, sign(signingKey, segments[0])) {
+		return "", "", errors.New("invalid unsubscribe token: bad signature")
+	}
+	decodedPayload, err := base64.RawURLEncoding.DecodeString(segments[0])
+	if err != nil {
+		return "", "", errors.New("invalid unsubscribe token: malformed payload")
+	}
+	payload := unsubscribeTokenPayload{}
+	if err := json.Unmarshal(decodedPayload, &payload); err != nil {
+		return "", "", errors.New("invalid unsubscribe token: malformed payload")
+	}
+	if time.Now().After(time.Unix(payload.ExpiresAt, 0)) {
+		return "", "", errors.New("invalid unsubscribe token: expired")
+	}
+	return payload.UserSignup, payload.Category, nil
+}
+
+func sign(signingKey, value string) []byte {
+	mac := hmac.New(sha256.New, []byte(signingKey))
+	mac.Write([]byte(value))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,146 @@
+package notification
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCategory(t *testing.T) {
+	for name, tc := range map[string]struct {
+		labels           map[string]string
+		annotations      map[string]string
+		expectedCategory string
+	}{
+		"no type": {
+			expectedCategory: NotificationCategoryEssential,
+		},
+		"essential type": {
+			labels:           map[string]string{toolchainv1alpha1.NotificationTypeLabelKey: toolchainv1alpha1.NotificationTypeDeactivated},
+			expectedCategory: NotificationCategoryEssential,
+		},
+		"reminder type": {
+			labels:           map[string]string{toolchainv1alpha1.NotificationTypeLabelKey: toolchainv1alpha1.NotificationTypeDeactivating},
+			expectedCategory: NotificationCategoryReminders,
+		},
+		"space expiring type": {
+			labels:           map[string]string{toolchainv1alpha1.NotificationTypeLabelKey: NotificationTypeSpaceExpiring},
+			expectedCategory: NotificationCategoryReminders,
+		},
+		"tier changed type": {
+			labels:           map[string]string{toolchainv1alpha1.NotificationTypeLabelKey: NotificationTypeTierChanged},
+			expectedCategory: NotificationCategoryAnnouncements,
+		},
+		"tier migrated type": {
+			labels:           map[string]string{toolchainv1alpha1.NotificationTypeLabelKey: NotificationTypeTierMigrated},
+			expectedCategory: NotificationCategoryAnnouncements,
+		},
+		"banned type": {
+			labels:           map[string]string{toolchainv1alpha1.NotificationTypeLabelKey: NotificationTypeBanned},
+			expectedCategory: NotificationCategoryEssential,
+		},
+		"explicit category": {
+			labels:           map[string]string{toolchainv1alpha1.NotificationTypeLabelKey: toolchainv1alpha1.NotificationTypeDeactivated},
+			annotations:      map[string]string{CategoryAnnotationKey: NotificationCategoryAnnouncements},
+			expectedCategory: NotificationCategoryAnnouncements,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			// given
+			notification := &toolchainv1alpha1.Notification{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      tc.labels,
+					Annotations: tc.annotations,
+				},
+			}
+
+			// when
+			category := Category(notification)
+
+			// then
+			assert.Equal(t, tc.expectedCategory, category)
+		})
+	}
+}
+
+func TestIsOptedOut(t *testing.T) {
+	// given
+	userSignup := &toolchainv1alpha1.UserSignup{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				OptOutAnnotationKey: "reminders, essential",
+			},
+		},
+	}
+
+	// then
+	assert.True(t, IsOptedOut(userSignup, NotificationCategoryReminders))
+	assert.False(t, IsOptedOut(userSignup, NotificationCategoryAnnouncements))
+	assert.False(t, IsOptedOut(userSignup, NotificationCategoryEssential)) // cannot opt out of the essential notifications
+	assert.False(t, IsOptedOut(&toolchainv1alpha1.UserSignup{}, NotificationCategoryReminders))
+}
+
+func TestUnsubscribeToken(t *testing.T) {
+	// given
+	token, err := NewUnsubscribeToken("s3cr3t", "johnsmith", NotificationCategoryReminders, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+
+	t.Run("valid token", func(t *testing.T) {
+		// when
+		userSignupName, category, err := ParseUnsubscribeToken("s3cr3t", token)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "johnsmith", userSignupName)
+		assert.Equal(t, NotificationCategoryReminders, category)
+	})
+
+	t.Run("invalid tokens", func(t *testing.T) {
+		other, err := NewUnsubscribeToken("s3cr3t", "janedoe", NotificationCategoryReminders, time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		payload, signature := strings.Split(token, ".")[0], strings.Split(other, ".")[1]
+		expired, err := NewUnsubscribeToken("s3cr3t", "johnsmith", NotificationCategoryReminders, time.Now().Add(-time.Minute))
+		require.NoError(t, err)
+
+		for name, tc := range map[string]struct {
+			signingKey    string
+			token         string
+			expectedError string
+		}{
+			"other signing key": {
+				signingKey:    "other",
+				token:         token,
+				expectedError: "invalid unsubscribe token: bad signature",
+			},
+			"tampered payload": {
+				signingKey:    "s3cr3t",
+				token:         payload + "." + signature,
+				expectedError: "invalid unsubscribe token: bad signature",
+			},
+			"expired": {
+				signingKey:    "s3cr3t",
+				token:         expired,
+				expectedError: "invalid unsubscribe token: expired",
+			},
+			"malformed": {
+				signingKey:    "s3cr3t",
+				token:         "foo",
+				expectedError: "invalid unsubscribe token: malformed",
+			},
+		} {
+			t.Run(name, func(t *testing.T) {
+				// when
+				_, _, err := ParseUnsubscribeToken(tc.signingKey, tc.token)
+
+				// then
+				require.EqualError(t, err, tc.expectedError)
+			})
+		}
+	})
+}
@@ -0,0 +1,97 @@
+package notification
+
+import (
+	"io/ioutil"
+	"text/template"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/host-operator/controllers/toolchainconfig"
+	"github.com/codeready-toolchain/host-operator/pkg/templates/notificationtemplates"
+
+	errs "github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// sampleContext returns a context representative of the notifications sent to the users, ie, with the keys set by the notification
+// builder, by the delivery services and by the controllers which create the notifications
+func sampleContext() map[string]string {
+	context := userContext(&toolchainv1alpha1.UserSignup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "johnsmith",
+			Annotations: map[string]string{
+				toolchainv1alpha1.UserSignupUserEmailAnnotationKey: "jsmith@example.com",
+			},
+		},
+		Spec: toolchainv1alpha1.UserSignupSpec{
+			Userid:     "123456",
+			GivenName:  "John",
+			FamilyName: "Smith",
+			Company:    "ACME Corp",
+		},
+		Status: toolchainv1alpha1.UserSignupStatus{
+			CompliantUsername: "johnsmith",
+		},
+	})
+	context[toolchainconfig.NotificationContextRegistrationURLKey] = "https://registration.example.com"
+	context[toolchainconfig.NotificationContextSupportURLKey] = "https://support.example.com"
+	context[ContextReplyTo] = "info@example.com"
+	context[ContextUnsubscribeToken] = "token"
+	context[ContextUnsubscribeURL] = "https://registration.example.com/unsubscribe?token=token"
+	// set by the space expiration controller
+	context["SpaceName"] = "johnsmith"
+	context["ExpirationDate"] = "January 2, 2006 15:04 MST"
+	// set by the change tier request controller
+	context["OldTierName"] = "base"
+	context["NewTierName"] = "advanced"
+	return context
+}
+
+// ValidateTemplates parses every variant of the given notification templates and dry-renders it with a representative context,
+// and returns the errors. A template referring to a key which is not in the context is invalid.
+func ValidateTemplates(templates *notificationtemplates.Templates) []error {
+	context := sampleContext()
+	var validationErrs []error
+	for _, name := range templates.Names() {
+		for _, variant := range templates.Variants(name) {
+			for _, part := range []struct {
+				kind       string
+				definition string
+			}{
+				{kind: "subject", definition: variant.Subject},
+				{kind: "content", definition: variant.Content},
+				{kind: "text content", definition: variant.TextContent},
+			} {
+				if err := dryRender(part.definition, context); err != nil {
+					validationErrs = append(validationErrs, errs.Wrapf(err, "invalid %s of the '%s' notification template in the '%s' locale", part.kind, name, variant.Locale))
+				}
+			}
+		}
+	}
+	return validationErrs
+}
+
+// ValidateTemplateOverrides returns the errors of the ConfigMaps in the given namespace which override the given notification templates
+func ValidateTemplateOverrides(cl client.Client, namespace string, templates *notificationtemplates.Templates) []error {
+	loader := NewConfigMapTemplateLoader(cl, namespace)
+	var validationErrs []error
+	for _, name := range templates.Names() {
+		overrides, err := loader.templateOverride(name)
+		if err != nil {
+			validationErrs = append(validationErrs, err)
+			continue
+		}
+		if overrides != nil {
+			validationErrs = append(validationErrs, ValidateTemplates(overrides)...)
+		}
+	}
+	return validationErrs
+}
+
+func dryRender(definition string, context map[string]string) error {
+	tmpl, err := template.New("template").Option("missingkey=error").Parse(definition)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(ioutil.Discard, context)
+}
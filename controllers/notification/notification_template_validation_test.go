@@ -0,0 +1,113 @@
+package notification
+
+import (
+	"testing"
+
+	"github.com/codeready-toolchain/host-operator/pkg/templates/assets"
+	"github.com/codeready-toolchain/host-operator/pkg/templates/notificationtemplates"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateTemplates(t *testing.T) {
+
+	t.Run("embedded templates are valid", func(t *testing.T) {
+		// given
+		templates, err := notificationtemplates.GetTemplates()
+		require.NoError(t, err)
+
+		// when
+		validationErrs := ValidateTemplates(templates)
+
+		// then
+		assert.Empty(t, validationErrs)
+	})
+
+	t.Run("invalid templates", func(t *testing.T) {
+		// given
+		files := map[string]string{
+			"userdeactivated/subject.txt":          "Goodbye {{.FirstName}}",
+			"userdeactivated/notification.html":    "<p>Your account {{.Nickname}} was deactivated</p>",
+			"userdeactivated/subject.de.txt":       "Auf Wiedersehen {{.FirstName}}",
+			"userdeactivated/notification.de.html": "<p>Ihr Konto {{.UserID}} wurde deaktiviert</p>",
+			"userprovisioned/subject.txt":          "Welcome {{.FirstName}}",
+			"userprovisioned/notification.html":    "<p>Welcome {{.FirstName}</p>",
+		}
+		templates, err := notificationtemplates.ParseTemplates(assets.NewAssets(
+			func() []string {
+				names := make([]string, 0, len(files))
+				for name := range files {
+					names = append(names, name)
+				}
+				return names
+			},
+			func(name string) ([]byte, error) {
+				return []byte(files[name]), nil
+			}))
+		require.NoError(t, err)
+
+		// when
+		validationErrs := ValidateTemplates(templates)
+
+		// then
+		require.Len(t, validationErrs, 2)
+		assert.EqualError(t, validationErrs[0], `invalid content of the 'userdeactivated' notification template in the 'en' locale: template: template:1:18: executing "template" at <.Nickname>: map has no entry for key "Nickname"`)
+		assert.EqualError(t, validationErrs[1], `invalid content of the 'userprovisioned' notification template in the 'en' locale: template: template:1: bad character U+007D '}'`)
+	})
+}
+
+func TestValidateTemplateOverrides(t *testing.T) {
+	// given
+	templates, err := notificationtemplates.GetTemplates()
+	require.NoError(t, err)
+	newTemplateConfigMap := func(name, templateName string, data map[string]string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: test.HostOperatorNs,
+				Labels: map[string]string{
+					TemplateOverrideLabelKey: templateName,
+				},
+			},
+			Data: data,
+		}
+	}
+
+	t.Run("valid overrides", func(t *testing.T) {
+		// given
+		cl := test.NewFakeClient(t, newTemplateConfigMap("userdeactivated-override", "userdeactivated", map[string]string{
+			"subject.txt":       "Goodbye {{.FirstName}}",
+			"notification.html": "<p>Your account was deactivated</p>",
+		}))
+
+		// when
+		validationErrs := ValidateTemplateOverrides(cl, test.HostOperatorNs, templates)
+
+		// then
+		assert.Empty(t, validationErrs)
+	})
+
+	t.Run("invalid overrides", func(t *testing.T) {
+		// given
+		cl := test.NewFakeClient(t,
+			newTemplateConfigMap("userdeactivated-override", "userdeactivated", map[string]string{
+				"notification.html": "<p>Your account was deactivated</p>",
+			}),
+			newTemplateConfigMap("userprovisioned-override", "userprovisioned", map[string]string{
+				"subject.txt":       "Welcome {{.Nickname}}",
+				"notification.html": "<p>Your account is provisioned</p>",
+			}))
+
+		// when
+		validationErrs := ValidateTemplateOverrides(cl, test.HostOperatorNs, templates)
+
+		// then
+		require.Len(t, validationErrs, 2)
+		assert.EqualError(t, validationErrs[0], "invalid override of the 'userdeactivated' notification template: the ConfigMap 'userdeactivated-override' must contain notification.html and subject.txt")
+		assert.EqualError(t, validationErrs[1], `invalid subject of the 'userprovisioned' notification template in the 'en' locale: template: template:1:10: executing "template" at <.Nickname>: map has no entry for key "Nickname"`)
+	})
+}
@@ -0,0 +1,245 @@
+package notification
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/host-operator/controllers/toolchainconfig"
+	"github.com/gofrs/uuid"
+)
+
+// smtpTimeout is the maximum duration of the whole exchange with the SMTP server
+const smtpTimeout = 10 * time.Second
+
+type SMTPDeliveryError struct {
+	host         string
+	replyCode    int
+	errorMessage string
+}
+
+func (e SMTPDeliveryError) Error() string {
+	return fmt.Sprintf("error while delivering notification via SMTP server %s - %s", e.host, e.errorMessage)
+}
+
+// Permanent returns true if the SMTP server replied with a permanent negative completion reply (5xx), eg. when the
+// recipient does not exist. The authentication errors (530, 534 and 535) are not permanent, since they are caused by the
+// credentials configured for the SMTP server, not by the notification.
+func (e SMTPDeliveryError) Permanent() bool {
+	switch e.replyCode {
+	case 530, 534, 535:
+		return false
+	}
+	return e.replyCode >= 500 && e.replyCode < 600
+}
+
+// NewSMTPDeliveryError returns a new error with the given reply code of the SMTP server (0 if the server did not reply)
+func NewSMTPDeliveryError(host string, replyCode int, errorMessage string) error {
+	return SMTPDeliveryError{
+		host:         host,
+		replyCode:    replyCode,
+		errorMessage: errorMessage,
+	}
+}
+
+type SMTPConfig interface {
+	GetSMTPHost() string
+	GetSMTPPort() int
+	GetSMTPTLSMode() string
+	GetSMTPUsername() string
+	GetSMTPPassword() string
+	GetSMTPSenderEmail() string
+	GetSMTPReplyToEmail() string
+}
+
+type SMTPOption interface {
+	// ApplyToSMTP applies this configuration to the given SMTP delivery service.
+	ApplyToSMTP(*SMTPNotificationDeliveryService)
+}
+
+type SMTPNotificationDeliveryService struct {
+	base         BaseNotificationDeliveryService
+	Host         string
+	Port         int
+	TLSMode      string
+	Username     string
+	Password     string
+	SenderEmail  string
+	ReplyToEmail string
+	// TLSConfig the configuration of the TLS connection to the SMTP server (optional)
+	TLSConfig *tls.Config
+}
+
+// NewSMTPNotificationDeliveryService creates a delivery service that uses an SMTP server to deliver email notifications
+func NewSMTPNotificationDeliveryService(config DeliveryServiceFactoryConfig, templateLoader TemplateLoader,
+	opts ...SMTPOption) DeliveryService {
+
+	s := &SMTPNotificationDeliveryService{
+		base:         BaseNotificationDeliveryService{TemplateLoader: templateLoader},
+		Host:         config.GetSMTPHost(),
+		Port:         config.GetSMTPPort(),
+		TLSMode:      config.GetSMTPTLSMode(),
+		Username:     config.GetSMTPUsername(),
+		Password:     config.GetSMTPPassword(),
+		SenderEmail:  config.GetSMTPSenderEmail(),
+		ReplyToEmail: config.GetSMTPReplyToEmail(),
+	}
+
+	for _, opt := range opts {
+		opt.ApplyToSMTP(s)
+	}
+
+	return s
+}
+
+func (s *SMTPNotificationDeliveryService) Send(notification *toolchainv1alpha1.Notification) error {
+
+	replyTo := s.ReplyToEmail
+	if replyTo == "" {
+		replyTo = s.SenderEmail
+	}
+	subject, body, textBody, err := s.base.GenerateSubjectAndBodies(notification, replyTo)
+	if err != nil {
+		return err
+	}
+
+	if subject == "" && body == "" {
+		return NewNotificationContentError("no subject or body specified for notification")
+	}
+
+	msg, err := s.message(notification.Spec.Recipient, subject, body, textBody)
+	if err != nil {
+		return err
+	}
+	if err := s.send(notification.Spec.Recipient, msg); err != nil {
+		replyCode := 0
+		var replyErr *textproto.Error
+		if errors.As(err, &replyErr) {
+			replyCode = replyErr.Code
+		}
+		return NewSMTPDeliveryError(s.Host, replyCode, err.Error())
+	}
+	return nil
+}
+
+// message returns the multipart MIME message with the given subject, HTML body and plain-text body
+func (s *SMTPNotificationDeliveryService) message(recipient, subject, body, textBody string) ([]byte, error) {
+	msg := &bytes.Buffer{}
+	fmt.Fprintf(msg, "From: %s\r\n", s.SenderEmail)
+	fmt.Fprintf(msg, "To: %s\r\n", recipient)
+	if s.ReplyToEmail != "" {
+		fmt.Fprintf(msg, "Reply-To: %s\r\n", s.ReplyToEmail)
+	}
+	fmt.Fprintf(msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(msg, "Message-ID: <%s@%s>\r\n", uuid.Must(uuid.NewV4()).String(), s.Host)
+	msg.WriteString("MIME-Version: 1.0\r\n")
+
+	parts := multipart.NewWriter(msg)
+	fmt.Fprintf(msg, "Content-Type: multipart/alternative; boundary=%q\r\n", parts.Boundary())
+	msg.WriteString("\r\n")
+	// the preferred alternative comes last
+	for _, part := range []struct {
+		contentType string
+		content     string
+	}{
+		{contentType: "text/plain", content: textBody},
+		{contentType: "text/html", content: body},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType + `; charset="utf-8"`},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+	return msg.Bytes(), nil
+}
+
+// send connects to the SMTP server, secures the connection (depending on the TLS mode), authenticates (if a username is configured)
+// and sends the given message to the given recipient
+func (s *SMTPNotificationDeliveryService) send(recipient string, msg []byte) error {
+	addr := net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+	tlsConfig := s.TLSConfig
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{ServerName: s.Host, MinVersion: tls.VersionTLS12}
+	}
+
+	dialer := &net.Dialer{Timeout: smtpTimeout}
+	var conn net.Conn
+	var err error
+	switch s.TLSMode {
+	case toolchainconfig.SMTPTLSModeTLS:
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	case toolchainconfig.SMTPTLSModeStartTLS, toolchainconfig.SMTPTLSModeNone:
+		conn, err = dialer.Dial("tcp", addr)
+	default:
+		return fmt.Errorf("invalid TLS mode: '%s'", s.TLSMode)
+	}
+	if err != nil {
+		return err
+	}
+	if err := conn.SetDeadline(time.Now().Add(smtpTimeout)); err != nil {
+		conn.Close()
+		return err
+	}
+
+	c, err := smtp.NewClient(conn, s.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if s.TLSMode == toolchainconfig.SMTPTLSModeStartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("the server does not support STARTTLS")
+		}
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if s.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.Username, s.Password, s.Host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(s.SenderEmail); err != nil {
+		return err
+	}
+	if err := c.Rcpt(recipient); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
@@ -0,0 +1,432 @@
+package notification
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/host-operator/pkg/templates/notificationtemplates"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type SMTPTLSConfigOption struct {
+	config *tls.Config
+}
+
+func (o *SMTPTLSConfigOption) ApplyToSMTP(s *SMTPNotificationDeliveryService) {
+	s.TLSConfig = o.config
+}
+
+func TestSMTPNotificationDeliveryService(t *testing.T) {
+	// given
+	serverTLSConfig, clientTLSConfig := newTestTLSConfigs(t)
+	clientTLSConfigOption := &SMTPTLSConfigOption{config: clientTLSConfig}
+
+	notCtx := map[string]string{
+		"UserID":      "jsmith123",
+		"FirstName":   "John",
+		"LastName":    "Smith",
+		"UserEmail":   "jsmith@redhat.com",
+		"CompanyName": "Red Hat",
+	}
+
+	templateLoader := NewMockTemplateLoader(
+		&notificationtemplates.NotificationTemplate{
+			Subject: "Bienvenue {{.FirstName}}",
+			Content: `<p>a message sent to <a href="mailto:{{.ReplyTo}}">{{.ReplyTo}}</a></p>`,
+			Name:    "replyto",
+		},
+		&notificationtemplates.NotificationTemplate{
+			Subject: "Hi there, {{invalid_expression}}",
+			Content: "Content",
+			Name:    "invalid_subject",
+		})
+
+	newConfig := func(server *testSMTPServer, tlsMode string) DeliveryServiceFactoryConfig {
+		return &MockNotificationDeliveryServiceFactoryConfig{
+			SMTP: MockSMTPConfiguration{
+				Host:         "127.0.0.1",
+				Port:         server.port(),
+				TLSMode:      tlsMode,
+				Username:     "sandbox",
+				Password:     "s3cr3t",
+				SenderEmail:  "noreply@foo.com",
+				ReplyToEmail: "info@foo.com",
+			},
+			Service: MockNotificationDeliveryServiceConfig{service: "smtp"},
+		}
+	}
+
+	for _, tlsMode := range []string{"starttls", "tls", "none"} {
+		t.Run("send with tls mode "+tlsMode, func(t *testing.T) {
+			// given
+			server := startTestSMTPServer(t, serverTLSConfig, tlsMode == "tls")
+			svc := NewSMTPNotificationDeliveryService(newConfig(server, tlsMode), templateLoader, clientTLSConfigOption)
+
+			// when
+			err := svc.Send(&toolchainv1alpha1.Notification{
+				Spec: toolchainv1alpha1.NotificationSpec{
+					Recipient: "foo@bar.com",
+					Template:  "replyto",
+					Context:   notCtx,
+				},
+			})
+
+			// then
+			require.NoError(t, err)
+			require.Len(t, server.messages(), 1)
+			received := server.messages()[0]
+			assert.Equal(t, "noreply@foo.com", received.from)
+			assert.Equal(t, []string{"foo@bar.com"}, received.recipients)
+			assert.Equal(t, "sandbox", received.username)
+			assert.Equal(t, tlsMode != "none", received.tls)
+			msg, err := mail.ReadMessage(bytes.NewReader(received.data))
+			require.NoError(t, err)
+			subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+			require.NoError(t, err)
+			assert.Equal(t, "Bienvenue John", subject)
+			assert.Equal(t, "foo@bar.com", msg.Header.Get("To"))
+			assert.Equal(t, "noreply@foo.com", msg.Header.Get("From"))
+			assert.Equal(t, "info@foo.com", msg.Header.Get("Reply-To"))
+			mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+			require.NoError(t, err)
+			assert.Equal(t, "multipart/alternative", mediaType)
+			parts := multipart.NewReader(msg.Body, params["boundary"])
+			for _, expected := range []struct {
+				contentType string
+				body        string
+			}{
+				{contentType: `text/plain; charset="utf-8"`, body: "a message sent to info@foo.com"},
+				{contentType: `text/html; charset="utf-8"`, body: "<p>a message sent to <a href=\"mailto:info@foo.com\">info@foo.com</a></p>"},
+			} {
+				part, err := parts.NextPart()
+				require.NoError(t, err)
+				assert.Equal(t, expected.contentType, part.Header.Get("Content-Type"))
+				body, err := ioutil.ReadAll(part)
+				require.NoError(t, err)
+				assert.Equal(t, expected.body, strings.TrimSpace(string(body)))
+			}
+			_, err = parts.NextPart()
+			assert.Equal(t, io.EOF, err)
+		})
+	}
+
+	t.Run("send without template and without authentication", func(t *testing.T) {
+		// given
+		server := startTestSMTPServer(t, serverTLSConfig, false)
+		config := newConfig(server, "starttls").(*MockNotificationDeliveryServiceFactoryConfig)
+		config.SMTP.Username = ""
+		svc := NewSMTPNotificationDeliveryService(config, templateLoader, clientTLSConfigOption)
+
+		// when
+		err := svc.Send(&toolchainv1alpha1.Notification{
+			Spec: toolchainv1alpha1.NotificationSpec{
+				Recipient: "foo@bar.com",
+				Subject:   "test",
+				Content:   "abc",
+			},
+		})
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, server.messages(), 1)
+		assert.Empty(t, server.messages()[0].username)
+	})
+
+	t.Run("send fails", func(t *testing.T) {
+
+		t.Run("recipient rejected", func(t *testing.T) {
+			// given
+			server := startTestSMTPServer(t, serverTLSConfig, false)
+			svc := NewSMTPNotificationDeliveryService(newConfig(server, "starttls"), templateLoader, clientTLSConfigOption)
+
+			// when
+			err := svc.Send(&toolchainv1alpha1.Notification{
+				Spec: toolchainv1alpha1.NotificationSpec{
+					Recipient: "unknown@bar.com",
+					Subject:   "test",
+					Content:   "abc",
+				},
+			})
+
+			// then
+			require.Error(t, err)
+			require.IsType(t, SMTPDeliveryError{}, err)
+			assert.Equal(t, "error while delivering notification via SMTP server 127.0.0.1 - 550 \"no such user\"", err.Error())
+			assert.True(t, IsPermanentDeliveryError(err))
+			assert.Empty(t, server.messages())
+		})
+
+		t.Run("invalid credentials", func(t *testing.T) {
+			// given
+			server := startTestSMTPServer(t, serverTLSConfig, false)
+			config := newConfig(server, "starttls").(*MockNotificationDeliveryServiceFactoryConfig)
+			config.SMTP.Password = "wrong"
+			svc := NewSMTPNotificationDeliveryService(config, templateLoader, clientTLSConfigOption)
+
+			// when
+			err := svc.Send(&toolchainv1alpha1.Notification{
+				Spec: toolchainv1alpha1.NotificationSpec{
+					Recipient: "foo@bar.com",
+					Subject:   "test",
+					Content:   "abc",
+				},
+			})
+
+			// then
+			require.EqualError(t, err, "error while delivering notification via SMTP server 127.0.0.1 - 535 \"authentication failed\"")
+			assert.Empty(t, server.messages())
+		})
+
+		t.Run("untrusted server certificate", func(t *testing.T) {
+			// given
+			server := startTestSMTPServer(t, serverTLSConfig, false)
+			svc := NewSMTPNotificationDeliveryService(newConfig(server, "starttls"), templateLoader) // default TLS config
+
+			// when
+			err := svc.Send(&toolchainv1alpha1.Notification{
+				Spec: toolchainv1alpha1.NotificationSpec{
+					Recipient: "foo@bar.com",
+					Subject:   "test",
+					Content:   "abc",
+				},
+			})
+
+			// then
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "certificate")
+			assert.Empty(t, server.messages())
+		})
+
+		t.Run("server unavailable", func(t *testing.T) {
+			// given
+			config := &MockNotificationDeliveryServiceFactoryConfig{
+				SMTP: MockSMTPConfiguration{
+					Host:    "127.0.0.1",
+					Port:    60000,
+					TLSMode: "starttls",
+				},
+			}
+			svc := NewSMTPNotificationDeliveryService(config, templateLoader)
+
+			// when
+			err := svc.Send(&toolchainv1alpha1.Notification{
+				Spec: toolchainv1alpha1.NotificationSpec{
+					Recipient: "foo@bar.com",
+					Subject:   "test",
+					Content:   "abc",
+				},
+			})
+
+			// then
+			require.EqualError(t, err, "error while delivering notification via SMTP server 127.0.0.1 - dial tcp 127.0.0.1:60000: connect: connection refused")
+		})
+
+		t.Run("invalid tls mode", func(t *testing.T) {
+			// given
+			server := startTestSMTPServer(t, serverTLSConfig, false)
+			svc := NewSMTPNotificationDeliveryService(newConfig(server, "ssl"), templateLoader)
+
+			// when
+			err := svc.Send(&toolchainv1alpha1.Notification{
+				Spec: toolchainv1alpha1.NotificationSpec{
+					Recipient: "foo@bar.com",
+					Subject:   "test",
+					Content:   "abc",
+				},
+			})
+
+			// then
+			require.EqualError(t, err, "error while delivering notification via SMTP server 127.0.0.1 - invalid TLS mode: 'ssl'")
+		})
+
+		t.Run("invalid subject template", func(t *testing.T) {
+			// given
+			server := startTestSMTPServer(t, serverTLSConfig, false)
+			svc := NewSMTPNotificationDeliveryService(newConfig(server, "starttls"), templateLoader, clientTLSConfigOption)
+
+			// when
+			err := svc.Send(&toolchainv1alpha1.Notification{
+				Spec: toolchainv1alpha1.NotificationSpec{
+					Recipient: "foo@bar.com",
+					Template:  "invalid_subject",
+					Context:   notCtx,
+				},
+			})
+
+			// then
+			require.EqualError(t, err, "template: template:1: function \"invalid_expression\" not defined")
+			assert.Empty(t, server.messages())
+		})
+
+		t.Run("no subject or body", func(t *testing.T) {
+			// given
+			server := startTestSMTPServer(t, serverTLSConfig, false)
+			svc := NewSMTPNotificationDeliveryService(newConfig(server, "starttls"), templateLoader, clientTLSConfigOption)
+
+			// when
+			err := svc.Send(&toolchainv1alpha1.Notification{
+				Spec: toolchainv1alpha1.NotificationSpec{
+					Recipient: "foo@bar.com",
+				},
+			})
+
+			// then
+			require.EqualError(t, err, "no subject or body specified for notification")
+			assert.Empty(t, server.messages())
+		})
+	})
+}
+
+// newTestTLSConfigs returns the TLS configs of a server and of a client which trusts the server certificate
+// (issued for 127.0.0.1)
+func newTestTLSConfigs(t *testing.T) (*tls.Config, *tls.Config) {
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+	serverConfig := &tls.Config{Certificates: srv.TLS.Certificates} // nolint:gosec
+	clientConfig := srv.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+	clientConfig.ServerName = "127.0.0.1"
+	return serverConfig, clientConfig
+}
+
+type receivedMessage struct {
+	username   string
+	tls        bool
+	from       string
+	recipients []string
+	data       []byte
+}
+
+// testSMTPServer is a minimal in-process SMTP server which supports the STARTTLS and AUTH PLAIN extensions,
+// accepts the `sandbox/s3cr3t` credentials and rejects the `unknown@bar.com` recipient
+type testSMTPServer struct {
+	sync.Mutex
+	listener  net.Listener
+	tlsConfig *tls.Config
+	received  []receivedMessage
+}
+
+func startTestSMTPServer(t *testing.T, tlsConfig *tls.Config, implicitTLS bool) *testSMTPServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	if implicitTLS {
+		l = tls.NewListener(l, tlsConfig)
+	}
+	s := &testSMTPServer{
+		listener:  l,
+		tlsConfig: tlsConfig,
+	}
+	t.Cleanup(func() {
+		_ = l.Close()
+	})
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn, implicitTLS)
+		}
+	}()
+	return s
+}
+
+func (s *testSMTPServer) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *testSMTPServer) messages() []receivedMessage {
+	s.Lock()
+	defer s.Unlock()
+	return s.received
+}
+
+func (s *testSMTPServer) serve(conn net.Conn, secured bool) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	msg := receivedMessage{tls: secured}
+	reply := func(line string) {
+		_ = tp.PrintfLine("%s", line)
+	}
+	reply("220 localhost ESMTP test server")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		arg := strings.TrimSpace(strings.TrimPrefix(line, strings.SplitN(line, " ", 2)[0]))
+		switch cmd {
+		case "EHLO", "HELO":
+			reply("250-localhost")
+			if !msg.tls {
+				reply("250-STARTTLS")
+			}
+			reply("250 AUTH PLAIN")
+		case "STARTTLS":
+			reply("220 ready to start TLS")
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn = tlsConn
+			tp = textproto.NewConn(conn)
+			msg.tls = true
+		case "AUTH":
+			credentials, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(arg, "PLAIN "))
+			if err != nil {
+				reply("501 invalid credentials encoding")
+				continue
+			}
+			parts := strings.Split(string(credentials), "\x00")
+			if len(parts) != 3 || parts[1] != "sandbox" || parts[2] != "s3cr3t" {
+				reply("535 authentication failed")
+				continue
+			}
+			msg.username = parts[1]
+			reply("235 authenticated")
+		case "MAIL":
+			msg.from = strings.Trim(strings.TrimPrefix(arg, "FROM:"), "<>")
+			reply("250 OK")
+		case "RCPT":
+			recipient := strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>")
+			if recipient == "unknown@bar.com" {
+				reply("550 no such user")
+				continue
+			}
+			msg.recipients = append(msg.recipients, recipient)
+			reply("250 OK")
+		case "DATA":
+			reply("354 end data with <CR><LF>.<CR><LF>")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			msg.data = data
+			s.Lock()
+			s.received = append(s.received, msg)
+			s.Unlock()
+			reply("250 OK")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 command not implemented")
+		}
+	}
+}
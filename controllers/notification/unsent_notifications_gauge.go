@@ -0,0 +1,62 @@
+package notification
+
+import (
+	"context"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/host-operator/pkg/metrics"
+	"github.com/codeready-toolchain/toolchain-common/pkg/condition"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// unsentNotificationsGaugeInterval is the interval between two updates of the gauge of the notifications pending delivery
+const unsentNotificationsGaugeInterval = time.Minute
+
+// UnsentNotificationsGauge periodically sets the gauge of the notifications pending delivery, rather than on each reconcile
+// of a notification, since it requires to list all the notifications
+type UnsentNotificationsGauge struct {
+	Client    client.Client
+	Namespace string
+	Interval  time.Duration
+}
+
+// Start updates the gauge at the configured interval, until the given context is done
+func (g *UnsentNotificationsGauge) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("unsent-notifications-gauge")
+	ticker := time.NewTicker(g.Interval)
+	defer ticker.Stop()
+	for {
+		g.Update(logger)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Update sets the gauge of the notifications pending delivery, ie, the notifications which were not sent yet,
+// and which were neither suppressed, opted out nor abandoned
+func (g *UnsentNotificationsGauge) Update(logger logr.Logger) {
+	notifications := &toolchainv1alpha1.NotificationList{}
+	if err := g.Client.List(context.TODO(), notifications, client.InNamespace(g.Namespace)); err != nil {
+		logger.Error(err, "unable to list the Notifications pending delivery")
+		return
+	}
+	unsent := 0
+	for _, notification := range notifications.Items {
+		sentCond, found := condition.FindConditionByType(notification.Status.Conditions, toolchainv1alpha1.NotificationSent)
+		if !found || (sentCond.Status != corev1.ConditionTrue &&
+			sentCond.Reason != NotificationSuppressedReason &&
+			sentCond.Reason != NotificationOptedOutReason &&
+			sentCond.Reason != NotificationDeliveryFailedReason) {
+			unsent++
+		}
+	}
+	metrics.NotificationUnsentGauge.Set(float64(unsent))
+}
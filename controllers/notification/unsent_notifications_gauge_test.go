@@ -0,0 +1,111 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/host-operator/pkg/metrics"
+	. "github.com/codeready-toolchain/host-operator/test"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestUnsentNotificationsGauge(t *testing.T) {
+	// given
+	newNotification := func(name string, conditions ...toolchainv1alpha1.Condition) *toolchainv1alpha1.Notification {
+		return &toolchainv1alpha1.Notification{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      name,
+				Namespace: test.HostOperatorNs,
+			},
+			Status: toolchainv1alpha1.NotificationStatus{
+				Conditions: conditions,
+			},
+		}
+	}
+	notSentCond := func(reason string) toolchainv1alpha1.Condition {
+		return toolchainv1alpha1.Condition{
+			Type:   toolchainv1alpha1.NotificationSent,
+			Status: corev1.ConditionFalse,
+			Reason: reason,
+		}
+	}
+	initObjs := []runtime.Object{
+		newNotification("new"),
+		newNotification("retrying", notSentCond(toolchainv1alpha1.NotificationDeliveryErrorReason)),
+		newNotification("rate-limited", notSentCond(NotificationRateLimitedReason)),
+		newNotification("sent", sentCond()),
+		newNotification("suppressed", notSentCond(NotificationSuppressedReason)),
+		newNotification("opted-out", notSentCond(NotificationOptedOutReason)),
+		newNotification("abandoned", notSentCond(NotificationDeliveryFailedReason)),
+	}
+
+	t.Run("update", func(t *testing.T) {
+		// given
+		metrics.Reset()
+		gauge := &UnsentNotificationsGauge{
+			Client:    test.NewFakeClient(t, initObjs...),
+			Namespace: test.HostOperatorNs,
+		}
+
+		// when
+		gauge.Update(logf.Log)
+
+		// then
+		AssertMetricsGaugeEquals(t, 3, metrics.NotificationUnsentGauge)
+	})
+
+	t.Run("start", func(t *testing.T) {
+		// given
+		metrics.Reset()
+		gauge := &UnsentNotificationsGauge{
+			Client:    test.NewFakeClient(t, initObjs...),
+			Namespace: test.HostOperatorNs,
+			Interval:  time.Hour,
+		}
+		ctx, cancel := context.WithCancel(context.TODO())
+		done := make(chan error)
+
+		// when
+		go func() {
+			done <- gauge.Start(ctx)
+		}()
+
+		// then
+		require.Eventually(t, func() bool {
+			return promtestutil.ToFloat64(metrics.NotificationUnsentGauge) == 3
+		}, 5*time.Second, 10*time.Millisecond)
+		cancel()
+		require.NoError(t, <-done)
+	})
+
+	t.Run("unable to list the notifications", func(t *testing.T) {
+		// given
+		metrics.Reset()
+		metrics.NotificationUnsentGauge.Set(5)
+		cl := test.NewFakeClient(t, initObjs...)
+		cl.MockList = func(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+			return errors.New("mock error")
+		}
+		gauge := &UnsentNotificationsGauge{
+			Client:    cl,
+			Namespace: test.HostOperatorNs,
+		}
+
+		// when
+		gauge.Update(logf.Log)
+
+		// then the gauge is unchanged
+		AssertMetricsGaugeEquals(t, 5, metrics.NotificationUnsentGauge)
+	})
+}
@@ -0,0 +1,139 @@
+package notification
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/host-operator/controllers/toolchainconfig"
+)
+
+type WebhookDeliveryError struct {
+	statusCode   int
+	response     string
+	errorMessage string
+}
+
+func (e WebhookDeliveryError) Error() string {
+	return fmt.Sprintf("error while delivering notification to webhook (Status: %d, Response: %s) - %s", e.statusCode, e.response, e.errorMessage)
+}
+
+// Permanent returns true if the webhook rejected the notification with a client error (eg. invalid token)
+func (e WebhookDeliveryError) Permanent() bool {
+	return isPermanentHTTPStatus(e.statusCode)
+}
+
+func NewWebhookDeliveryError(statusCode int, response, errorMessage string) error {
+	return WebhookDeliveryError{
+		statusCode:   statusCode,
+		response:     response,
+		errorMessage: errorMessage,
+	}
+}
+
+type WebhookConfig interface {
+	GetWebhookURL() string
+	GetWebhookFormat() string
+}
+
+// WebhookPayload is the content of a notification posted to a webhook with the `json` format
+type WebhookPayload struct {
+	Subject          string            `json:"subject"`
+	Body             string            `json:"body"`
+	TextBody         string            `json:"textBody"`
+	NotificationType string            `json:"notificationType,omitempty"`
+	Recipient        string            `json:"recipient,omitempty"`
+	Context          map[string]string `json:"context,omitempty"`
+}
+
+// WebhookFormatter converts the payload of a notification into the body of the request posted to the webhook
+type WebhookFormatter func(payload WebhookPayload) ([]byte, error)
+
+// webhookFormatters the supported webhook formats
+var webhookFormatters = map[string]WebhookFormatter{
+	toolchainconfig.WebhookFormatJSON:  FormatJSON,
+	toolchainconfig.WebhookFormatSlack: FormatSlack,
+}
+
+// FormatJSON formats the payload as-is, in JSON
+func FormatJSON(payload WebhookPayload) ([]byte, error) {
+	return json.Marshal(payload)
+}
+
+// FormatSlack formats the payload as a message for a Slack incoming webhook, ie, with the subject in bold
+// followed by the plain-text body
+func FormatSlack(payload WebhookPayload) ([]byte, error) {
+	return json.Marshal(map[string]string{
+		"text": fmt.Sprintf("*%s*\n%s", escapeSlack(payload.Subject), escapeSlack(payload.TextBody)),
+	})
+}
+
+// escapeSlack escapes the control characters of the Slack message format
+func escapeSlack(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+type WebhookNotificationDeliveryService struct {
+	base       BaseNotificationDeliveryService
+	URL        string
+	Formatter  WebhookFormatter
+	HTTPClient *http.Client
+}
+
+// NewWebhookNotificationDeliveryService creates a delivery service that posts the notifications to a webhook
+func NewWebhookNotificationDeliveryService(config DeliveryServiceFactoryConfig, templateLoader TemplateLoader) (DeliveryService, error) {
+	formatter, found := webhookFormatters[config.GetWebhookFormat()]
+	if !found {
+		return nil, fmt.Errorf("invalid webhook format: '%s'", config.GetWebhookFormat())
+	}
+	return &WebhookNotificationDeliveryService{
+		base:       BaseNotificationDeliveryService{TemplateLoader: templateLoader},
+		URL:        config.GetWebhookURL(),
+		Formatter:  formatter,
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+func (s *WebhookNotificationDeliveryService) Send(notification *toolchainv1alpha1.Notification) error {
+	subject, body, textBody, err := s.base.GenerateSubjectAndBodies(notification, "")
+	if err != nil {
+		return err
+	}
+
+	if subject == "" && body == "" {
+		return NewNotificationContentError("no subject or body specified for notification")
+	}
+
+	content, err := s.Formatter(WebhookPayload{
+		Subject:          subject,
+		Body:             body,
+		TextBody:         textBody,
+		NotificationType: notification.Labels[toolchainv1alpha1.NotificationTypeLabelKey],
+		Recipient:        notification.Spec.Recipient,
+		Context:          notification.Spec.Context,
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.HTTPClient.Post(s.URL, "application/json", bytes.NewReader(content))
+	if err != nil {
+		// the webhook URL may contain a token, so it must not appear in the error (which ends up in the status of the notification)
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+		return NewWebhookDeliveryError(0, "", err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		response, _ := ioutil.ReadAll(resp.Body)
+		return NewWebhookDeliveryError(resp.StatusCode, strings.TrimSpace(string(response)), "unexpected response status")
+	}
+	return nil
+}
@@ -0,0 +1,244 @@
+package notification
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/host-operator/pkg/templates/notificationtemplates"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestWebhookNotificationDeliveryService(t *testing.T) {
+	// given
+	templateLoader := NewMockTemplateLoader(
+		&notificationtemplates.NotificationTemplate{
+			Subject: "Goodbye {{.FirstName}}",
+			Content: "<p>Your account was deactivated</p>",
+			Name:    "deactivated",
+		})
+
+	var received []byte
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		received, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte("invalid_token"))
+	}))
+	defer server.Close()
+
+	newService := func(t *testing.T, format string) DeliveryService {
+		svc, err := NewWebhookNotificationDeliveryService(&MockNotificationDeliveryServiceFactoryConfig{
+			Webhook: MockWebhookConfiguration{URL: server.URL + "/services/secret-token", Format: format},
+		}, templateLoader)
+		require.NoError(t, err)
+		return svc
+	}
+
+	t.Run("send with json format", func(t *testing.T) {
+		// given
+		status = http.StatusOK
+		svc := newService(t, "json")
+
+		// when
+		err := svc.Send(&toolchainv1alpha1.Notification{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{
+					toolchainv1alpha1.NotificationTypeLabelKey: "deactivated",
+				},
+			},
+			Spec: toolchainv1alpha1.NotificationSpec{
+				Recipient: "jsmith@redhat.com",
+				Template:  "deactivated",
+				Context: map[string]string{
+					"FirstName": "John",
+				},
+			},
+		})
+
+		// then
+		require.NoError(t, err)
+		payload := WebhookPayload{}
+		require.NoError(t, json.Unmarshal(received, &payload))
+		assert.Equal(t, WebhookPayload{
+			Subject:          "Goodbye John",
+			Body:             "<p>Your account was deactivated</p>",
+			TextBody:         "Your account was deactivated",
+			NotificationType: "deactivated",
+			Recipient:        "jsmith@redhat.com",
+			Context: map[string]string{
+				"FirstName": "John",
+				"ReplyTo":   "",
+			},
+		}, payload)
+	})
+
+	t.Run("send with slack format", func(t *testing.T) {
+		// given
+		status = http.StatusOK
+		svc := newService(t, "slack")
+
+		// when
+		err := svc.Send(&toolchainv1alpha1.Notification{
+			Spec: toolchainv1alpha1.NotificationSpec{
+				Recipient: "admin@redhat.com",
+				Subject:   "ToolchainStatus & co",
+				Content:   "<h3>The following issues:</h3><div><pre>host &lt;not ready&gt;</pre></div>",
+			},
+		})
+
+		// then
+		require.NoError(t, err)
+		payload := map[string]string{}
+		require.NoError(t, json.Unmarshal(received, &payload))
+		assert.Equal(t, map[string]string{
+			"text": "*ToolchainStatus &amp; co*\nThe following issues:\nhost &lt;not ready&gt;",
+		}, payload)
+	})
+
+	t.Run("send fails", func(t *testing.T) {
+
+		t.Run("error status", func(t *testing.T) {
+			// given
+			status = http.StatusForbidden
+			svc := newService(t, "json")
+
+			// when
+			err := svc.Send(&toolchainv1alpha1.Notification{
+				Spec: toolchainv1alpha1.NotificationSpec{
+					Subject: "test",
+					Content: "abc",
+				},
+			})
+
+			// then
+			require.Error(t, err)
+			require.IsType(t, WebhookDeliveryError{}, err)
+			assert.Equal(t, "error while delivering notification to webhook (Status: 403, Response: invalid_token) - unexpected response status", err.Error())
+			assert.False(t, IsPermanentDeliveryError(err)) // the token can be fixed in the configuration
+		})
+
+		t.Run("webhook unavailable", func(t *testing.T) {
+			// given
+			svc, err := NewWebhookNotificationDeliveryService(&MockNotificationDeliveryServiceFactoryConfig{
+				Webhook: MockWebhookConfiguration{URL: "http://127.0.0.1:60000/services/secret-token", Format: "json"},
+			}, templateLoader)
+			require.NoError(t, err)
+
+			// when
+			err = svc.Send(&toolchainv1alpha1.Notification{
+				Spec: toolchainv1alpha1.NotificationSpec{
+					Subject: "test",
+					Content: "abc",
+				},
+			})
+
+			// then
+			require.EqualError(t, err, "error while delivering notification to webhook (Status: 0, Response: ) - dial tcp 127.0.0.1:60000: connect: connection refused")
+			assert.NotContains(t, err.Error(), "secret-token")
+			assert.False(t, IsPermanentDeliveryError(err))
+		})
+
+		t.Run("no subject or body", func(t *testing.T) {
+			// given
+			svc := newService(t, "json")
+
+			// when
+			err := svc.Send(&toolchainv1alpha1.Notification{})
+
+			// then
+			require.EqualError(t, err, "no subject or body specified for notification")
+		})
+	})
+}
+
+type recordingDeliveryService struct {
+	sent []*toolchainv1alpha1.Notification
+}
+
+func (s *recordingDeliveryService) Send(notification *toolchainv1alpha1.Notification) error {
+	s.sent = append(s.sent, notification)
+	return nil
+}
+
+func TestRoutingNotificationDeliveryService(t *testing.T) {
+	// given
+	notificationOfType := func(notificationType string) *toolchainv1alpha1.Notification {
+		return &toolchainv1alpha1.Notification{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{
+					toolchainv1alpha1.NotificationTypeLabelKey: notificationType,
+				},
+			},
+		}
+	}
+
+	t.Run("notifications sent via the channel of their type", func(t *testing.T) {
+		// given
+		email := &recordingDeliveryService{}
+		webhook := &recordingDeliveryService{}
+		svc := &RoutingNotificationDeliveryService{
+			Channels: map[string]DeliveryService{
+				"email":   email,
+				"webhook": webhook,
+			},
+			Routes: map[string]string{
+				"toolchainstatus": "webhook",
+			},
+		}
+
+		// when
+		err1 := svc.Send(notificationOfType("toolchainstatus"))
+		err2 := svc.Send(notificationOfType("deactivated"))
+		err3 := svc.Send(&toolchainv1alpha1.Notification{}) // untyped
+
+		// then
+		require.NoError(t, err1)
+		require.NoError(t, err2)
+		require.NoError(t, err3)
+		require.Len(t, webhook.sent, 1)
+		assert.Equal(t, "toolchainstatus", webhook.sent[0].Labels[toolchainv1alpha1.NotificationTypeLabelKey])
+		require.Len(t, email.sent, 2)
+	})
+
+	t.Run("unknown channel", func(t *testing.T) {
+		// given
+		svc := &RoutingNotificationDeliveryService{
+			Channels: map[string]DeliveryService{
+				"email": &recordingDeliveryService{},
+			},
+			Routes: map[string]string{
+				"toolchainstatus": "chat",
+			},
+		}
+
+		// when
+		err := svc.Send(notificationOfType("toolchainstatus"))
+
+		// then
+		require.EqualError(t, err, "no delivery service for the 'chat' delivery channel")
+	})
+
+	t.Run("error from channel", func(t *testing.T) {
+		// given
+		svc := &RoutingNotificationDeliveryService{
+			Channels: map[string]DeliveryService{
+				"email": &MockDeliveryService{},
+			},
+		}
+
+		// when
+		err := svc.Send(notificationOfType("deactivated"))
+
+		// then
+		require.EqualError(t, err, "delivery error")
+	})
+}
@@ -0,0 +1,287 @@
+package nstemplatetier
+
+import (
+	"context"
+	"fmt"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	tierutil "github.com/codeready-toolchain/host-operator/controllers/nstemplatetier/util"
+	"github.com/codeready-toolchain/host-operator/controllers/toolchainconfig"
+	"github.com/codeready-toolchain/toolchain-common/pkg/condition"
+
+	"github.com/go-logr/logr"
+	errs "github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// Deprecated is the type of the NSTemplateTier condition set when the tier is (or was) deprecated
+	Deprecated toolchainv1alpha1.ConditionType = "Deprecated"
+	// DeprecationMigratingReason is the reason of the Deprecated condition while the MasterUserRecords and Spaces are migrated to the successor tier
+	DeprecationMigratingReason = "Migrating"
+	// DeprecationMigratedReason is the reason of the Deprecated condition once all MasterUserRecords and Spaces were migrated to the successor tier
+	DeprecationMigratedReason = "Migrated"
+	// DeprecationInvalidSuccessorReason is the reason of the Deprecated condition when the successor tier does not exist or is deprecated too
+	DeprecationInvalidSuccessorReason = "InvalidSuccessor"
+	// NotDeprecatedReason is the reason of the Deprecated condition when the deprecation of the tier was cancelled
+	NotDeprecatedReason = "NotDeprecated"
+)
+
+// labelDeprecatedTierUsers sets the deprecated tier label on the MasterUserRecords and Spaces which use the given tier if it is deprecated,
+// or removes the label if the tier is not (or no longer) deprecated.
+// Returns `true` if a whole batch was labelled, ie, if there may be more MasterUserRecords or Spaces to label.
+func (r *Reconciler) labelDeprecatedTierUsers(logger logr.Logger, tier *toolchainv1alpha1.NSTemplateTier) (bool, error) {
+	successor, deprecated := tierutil.SuccessorOf(tier)
+	labelKey := tierutil.TemplateTierDeprecatedLabelKey(tier.Name)
+	selector := labels.NewSelector()
+	if deprecated {
+		inTier, err := labels.NewRequirement(tierutil.TemplateTierHashLabelKey(tier.Name), selection.Exists, []string{})
+		if err != nil {
+			return false, err
+		}
+		notLabelled, err := labels.NewRequirement(labelKey, selection.NotEquals, []string{successor})
+		if err != nil {
+			return false, err
+		}
+		selector = selector.Add(*inTier, *notLabelled)
+	} else {
+		labelled, err := labels.NewRequirement(labelKey, selection.Exists, []string{})
+		if err != nil {
+			return false, err
+		}
+		selector = selector.Add(*labelled)
+	}
+	listOpts := []client.ListOption{
+		client.InNamespace(tier.Namespace),
+		client.MatchingLabelsSelector{Selector: selector},
+		client.Limit(hashMigrationBatchSize),
+	}
+	setLabel := func(obj client.Object) {
+		objLabels := obj.GetLabels()
+		if deprecated {
+			objLabels[labelKey] = successor
+		} else {
+			delete(objLabels, labelKey)
+		}
+		obj.SetLabels(objLabels)
+	}
+
+	murs := toolchainv1alpha1.MasterUserRecordList{}
+	if err := r.Client.List(context.TODO(), &murs, listOpts...); err != nil {
+		return false, errs.Wrap(err, "unable to list the MasterUserRecords to label with the tier deprecation")
+	}
+	for i := range murs.Items {
+		setLabel(&murs.Items[i])
+		if err := r.Client.Update(context.TODO(), &murs.Items[i]); err != nil {
+			return false, errs.Wrapf(err, "unable to set the tier deprecation label of the MasterUserRecord '%s'", murs.Items[i].Name)
+		}
+	}
+
+	spaces := toolchainv1alpha1.SpaceList{}
+	if err := r.Client.List(context.TODO(), &spaces, listOpts...); err != nil {
+		return false, errs.Wrap(err, "unable to list the Spaces to label with the tier deprecation")
+	}
+	for i := range spaces.Items {
+		setLabel(&spaces.Items[i])
+		if err := r.Client.Update(context.TODO(), &spaces.Items[i]); err != nil {
+			return false, errs.Wrapf(err, "unable to set the tier deprecation label of the Space '%s'", spaces.Items[i].Name)
+		}
+	}
+	if len(murs.Items) > 0 || len(spaces.Items) > 0 {
+		logger.Info("updated the tier deprecation labels", "deprecated", deprecated, "masteruserrecords", len(murs.Items), "spaces", len(spaces.Items))
+	}
+	if !deprecated && condition.IsTrue(tier.Status.Conditions, Deprecated) {
+		if err := r.setRolloutCondition(tier, toolchainv1alpha1.Condition{
+			Type:   Deprecated,
+			Status: corev1.ConditionFalse,
+			Reason: NotDeprecatedReason,
+		}); err != nil {
+			return false, err
+		}
+	}
+	return len(murs.Items) == hashMigrationBatchSize || len(spaces.Items) == hashMigrationBatchSize, nil
+}
+
+// ensureDeprecationUpdateRecord adds a new entry in the `status.updates` and starts a new rollout (without canary stage) when the tier
+// was deprecated, or when its successor changed or its deprecation was cancelled, since the current rollout started. This way, the
+// migration to the successor tier (or the update of the MasterUserRecords and Spaces which remain in the tier) is not affected
+// by the failures, the number of processed updates, nor the halted or aborted stage of the previous rollout.
+// Returns `true` if a new entry was added
+func (r *Reconciler) ensureDeprecationUpdateRecord(logger logr.Logger, tier *toolchainv1alpha1.NSTemplateTier, state *rolloutState) (bool, error) {
+	successor, _ := tierutil.SuccessorOf(tier)
+	if state.Successor == successor {
+		return false, nil
+	}
+	logger.Info("the deprecation of the tier changed, adding a new entry in tier.status.updates", "successor", successor)
+	// reset the `FailedAccounts` in the previous update, as in a regular tier update
+	latest := &tier.Status.Updates[len(tier.Status.Updates)-1]
+	latest.FailedAccounts = nil
+	tier.Status.Updates = append(tier.Status.Updates, toolchainv1alpha1.NSTemplateTierHistory{
+		StartTime: metav1.Now(),
+		Hash:      latest.Hash,
+	})
+	if err := r.Client.Status().Update(context.TODO(), tier); err != nil {
+		return false, errs.Wrap(err, "unable to insert a new entry in status.updates after the deprecation of the NSTemplateTier changed")
+	}
+	if err := r.resetRolloutConditions(tier); err != nil {
+		return false, err
+	}
+	*state = rolloutState{
+		Hash:      state.Hash,
+		Stage:     rolloutStageFull,
+		Successor: successor,
+	}
+	return true, r.saveRolloutState(tier, state)
+}
+
+// ensureMigration migrates the MasterUserRecords and Spaces of the given deprecated tier to its successor, by creating TemplateUpdateRequests
+// annotated with the deprecated tier, at the same pace as the rollout of a tier update (ie, within the `MaxPoolSize` threshold, and subject to
+// the rollout control and failure threshold).
+// Returns `true` when there is no MasterUserRecord or Space left to migrate (except the ones whose migration failed).
+func (r *Reconciler) ensureMigration(logger logr.Logger, config toolchainconfig.ToolchainConfig, tier *toolchainv1alpha1.NSTemplateTier, state *rolloutState) (bool, error) {
+	successorName, _ := tierutil.SuccessorOf(tier)
+	successor, err := r.getSuccessor(tier, successorName)
+	if err != nil {
+		if err2 := r.setDeprecatedCondition(tier, DeprecationInvalidSuccessorReason, err.Error()); err2 != nil {
+			logger.Error(err2, "unable to set the Deprecated condition")
+		}
+		return false, err
+	}
+
+	activeTemplateUpdateRequests, deleted, err := r.activeTemplateUpdateRequests(logger, config, tier, state)
+	if err != nil {
+		return false, errs.Wrap(err, "unable to get active TemplateUpdateRequests")
+	} else if deleted {
+		logger.Info("requeuing as a TemplateUpdateRequest was deleted")
+		return false, nil
+	}
+	latest := tier.Status.Updates[len(tier.Status.Updates)-1]
+	switch {
+	case state.Stage == rolloutStageAborted:
+		logger.Info("rollout of the tier was aborted, not migrating any MasterUserRecord or Space")
+		return false, nil
+	case state.Stage == rolloutStageHalted:
+		logger.Info("rollout of the tier is halted, not migrating any MasterUserRecord or Space")
+		return false, nil
+	case isRolloutPaused(tier):
+		logger.Info("rollout of the tier is paused, not migrating any MasterUserRecord or Space")
+		return false, nil
+	case state.Processed >= config.Tiers().RolloutMinUpdatesBeforeHalt() &&
+		failureThresholdExceeded(latest.Failures, state.Processed, config.Tiers().RolloutMaxFailurePercentage()):
+		return false, r.haltRollout(logger, config, tier, state, "migration")
+	case activeTemplateUpdateRequests >= config.Tiers().TemplateUpdateRequestMaxPoolSize():
+		logger.Info("waiting for the active migrations to complete", "active", activeTemplateUpdateRequests)
+		return false, nil
+	}
+
+	listOpts := []client.ListOption{
+		client.InNamespace(tier.Namespace),
+		client.HasLabels{tierutil.TemplateTierHashLabelKey(tier.Name)},
+		client.Limit(config.Tiers().TemplateUpdateRequestMaxPoolSize() + len(latest.FailedAccounts) + 1),
+	}
+	murs := toolchainv1alpha1.MasterUserRecordList{}
+	if err := r.Client.List(context.TODO(), &murs, listOpts...); err != nil {
+		return false, errs.Wrap(err, "unable to get MasterUserRecords to migrate")
+	}
+	spaces := toolchainv1alpha1.SpaceList{}
+	if err := r.Client.List(context.TODO(), &spaces, listOpts...); err != nil {
+		return false, errs.Wrap(err, "unable to get Spaces to migrate")
+	}
+	// do not retry the migrations which failed
+	murs.Items = withoutFailedMasterUserRecords(murs.Items, latest.FailedAccounts)
+	spaces.Items = withoutFailedSpaces(spaces.Items, latest.FailedAccounts)
+	logger.Info("listed MasterUserRecords and Spaces to migrate", "masteruserrecords", len(murs.Items), "spaces", len(spaces.Items), "successor", successor.Name)
+	if activeTemplateUpdateRequests == 0 && len(murs.Items) == 0 && len(spaces.Items) == 0 {
+		return true, r.setDeprecatedCondition(tier, DeprecationMigratedReason,
+			fmt.Sprintf("all MasterUserRecords and Spaces were migrated to the '%s' tier", successor.Name))
+	}
+	if err := r.setDeprecatedCondition(tier, DeprecationMigratingReason,
+		fmt.Sprintf("migrating the MasterUserRecords and Spaces to the '%s' tier", successor.Name)); err != nil {
+		return false, err
+	}
+	annotations := map[string]string{
+		tierutil.MigrateFromAnnotationKey: tier.Name,
+	}
+	for _, mur := range murs.Items {
+		exists, err := r.templateUpdateRequestExists(tier, mur.Name)
+		if err != nil {
+			return false, errs.Wrapf(err, "unable to get TemplateUpdateRequest for MasterUserRecord '%s'", mur.Name)
+		} else if exists {
+			logger.Info("MasterUserRecord already has an associated TemplateUpdateRequest", "name", mur.Name)
+			continue
+		}
+		logger.Info("creating a TemplateUpdateRequest to migrate the MasterUserRecord", "name", mur.Name, "tier", tier.Name, "successor", successor.Name)
+		return r.createTemplateUpdateRequest(tier, mur.Name, annotations, toolchainv1alpha1.TemplateUpdateRequestSpec{
+			TierName:         successor.Name,
+			Namespaces:       successor.Spec.Namespaces,
+			ClusterResources: successor.Spec.ClusterResources,
+		})
+	}
+	for _, space := range spaces.Items {
+		exists, err := r.templateUpdateRequestExists(tier, space.Name)
+		if err != nil {
+			return false, errs.Wrapf(err, "unable to get TemplateUpdateRequest for Space '%s'", space.Name)
+		} else if exists {
+			logger.Info("Space already has an associated TemplateUpdateRequest", "name", space.Name)
+			continue
+		}
+		logger.Info("creating a TemplateUpdateRequest to migrate the Space", "name", space.Name, "tier", tier.Name, "successor", successor.Name)
+		return r.createTemplateUpdateRequest(tier, space.Name, annotations, toolchainv1alpha1.TemplateUpdateRequestSpec{
+			TierName:        successor.Name,
+			CurrentTierHash: space.Labels[tierutil.TemplateTierHashLabelKey(tier.Name)],
+		})
+	}
+	logger.Info("done for now with creating TemplateUpdateRequest resources to migrate the deprecated tier", "tier", tier.Name)
+	return false, nil
+}
+
+// getSuccessor returns the NSTemplateTier with the given name, which replaces the given deprecated tier.
+// Returns an error if the successor does not exist, or if it is deprecated too (so the MasterUserRecords and Spaces are migrated only once)
+func (r *Reconciler) getSuccessor(tier *toolchainv1alpha1.NSTemplateTier, successorName string) (*toolchainv1alpha1.NSTemplateTier, error) {
+	if successorName == tier.Name {
+		return nil, fmt.Errorf("the tier '%s' cannot be deprecated in favour of itself", tier.Name)
+	}
+	successor := &toolchainv1alpha1.NSTemplateTier{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Namespace: tier.Namespace, Name: successorName}, successor); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, fmt.Errorf("the successor tier '%s' does not exist", successorName)
+		}
+		return nil, errs.Wrapf(err, "unable to get the successor tier '%s'", successorName)
+	}
+	if other, deprecated := tierutil.SuccessorOf(successor); deprecated {
+		return nil, fmt.Errorf("the successor tier '%s' is deprecated too, in favour of the '%s' tier", successorName, other)
+	}
+	return successor, nil
+}
+
+// templateUpdateRequestExists returns `true` if a TemplateUpdateRequest with the given name already exists
+func (r *Reconciler) templateUpdateRequestExists(tier *toolchainv1alpha1.NSTemplateTier, name string) (bool, error) {
+	templateUpdateRequest := toolchainv1alpha1.TemplateUpdateRequest{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Namespace: tier.Namespace, Name: name}, &templateUpdateRequest); err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// setDeprecatedCondition sets the Deprecated condition of the given tier with the given reason and message, if it changed
+func (r *Reconciler) setDeprecatedCondition(tier *toolchainv1alpha1.NSTemplateTier, reason, message string) error {
+	if c, found := condition.FindConditionByType(tier.Status.Conditions, Deprecated); found &&
+		c.Status == corev1.ConditionTrue && c.Reason == reason && c.Message == message {
+		return nil
+	}
+	return r.setRolloutCondition(tier, toolchainv1alpha1.Condition{
+		Type:    Deprecated,
+		Status:  corev1.ConditionTrue,
+		Reason:  reason,
+		Message: message,
+	})
+}
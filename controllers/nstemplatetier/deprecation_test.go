@@ -0,0 +1,262 @@
+package nstemplatetier_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/host-operator/controllers/nstemplatetier"
+	tierutil "github.com/codeready-toolchain/host-operator/controllers/nstemplatetier/util"
+	tiertest "github.com/codeready-toolchain/host-operator/test/nstemplatetier"
+	spacetest "github.com/codeready-toolchain/host-operator/test/space"
+	turtest "github.com/codeready-toolchain/host-operator/test/templateupdaterequest"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	murtest "github.com/codeready-toolchain/toolchain-common/pkg/test/masteruserrecord"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestDeprecation(t *testing.T) {
+
+	otherTier := tiertest.OtherTier()
+	deprecatedLabelKey := tierutil.TemplateTierDeprecatedLabelKey("basic")
+
+	t.Run("migrate the MasterUserRecords and Spaces to the successor tier", func(t *testing.T) {
+		// given
+		basicTier := tiertest.BasicTier(t, tiertest.CurrentBasicTemplates, tiertest.WithCurrentUpdateInProgress(),
+			tiertest.WithAnnotation(tierutil.DeprecatedByAnnotationKey, "other"))
+		initObjs := []runtime.Object{basicTier, otherTier}
+		initObjs = append(initObjs, murtest.NewMasterUserRecords(t, 3, "user-%d", murtest.Account("cluster1", *basicTier))...)
+		initObjs = append(initObjs, spacetest.NewSpaces(3, "space-%d", spacetest.WithTierNameAndHashLabelFor(basicTier))...)
+		r, req, cl := prepareReconcile(t, basicTier.Name, initObjs...)
+
+		// when
+		res, err := r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, reconcile.Result{}, res)
+		for i := 0; i < 3; i++ {
+			murtest.AssertThatMasterUserRecord(t, fmt.Sprintf("user-%d", i), cl).HasLabelWithValue(deprecatedLabelKey, "other")
+			spacetest.AssertThatSpace(t, test.HostOperatorNs, fmt.Sprintf("space-%d", i), cl).HasLabel(deprecatedLabelKey, "other")
+		}
+		tiertest.AssertThatNSTemplateTier(t, "basic", cl).HasConditions(toolchainv1alpha1.Condition{
+			Type:    nstemplatetier.Deprecated,
+			Status:  corev1.ConditionTrue,
+			Reason:  nstemplatetier.DeprecationMigratingReason,
+			Message: "migrating the MasterUserRecords and Spaces to the 'other' tier",
+		})
+		turtest.AssertThatTemplateUpdateRequests(t, cl).TotalCount(1)
+		tur := &toolchainv1alpha1.TemplateUpdateRequest{}
+		require.NoError(t, cl.Get(context.TODO(), types.NamespacedName{Namespace: test.HostOperatorNs, Name: "user-0"}, tur))
+		assert.Equal(t, "basic", tur.Labels[toolchainv1alpha1.NSTemplateTierNameLabelKey])
+		assert.Equal(t, "basic", tur.Annotations[tierutil.MigrateFromAnnotationKey])
+		assert.Equal(t, "other", tur.Spec.TierName)
+		assert.Equal(t, otherTier.Spec.Namespaces, tur.Spec.Namespaces)
+		assert.Equal(t, otherTier.Spec.ClusterResources, tur.Spec.ClusterResources)
+
+		t.Run("create TemplateUpdateRequests up to the max pool size", func(t *testing.T) {
+			// when
+			for i := 0; i < 10; i++ {
+				_, err := r.Reconcile(context.TODO(), req)
+				require.NoError(t, err)
+			}
+
+			// then
+			turtest.AssertThatTemplateUpdateRequests(t, cl).TotalCount(maxPoolSize)
+			spaceTUR := &toolchainv1alpha1.TemplateUpdateRequest{}
+			require.NoError(t, cl.Get(context.TODO(), types.NamespacedName{Namespace: test.HostOperatorNs, Name: "space-0"}, spaceTUR))
+			assert.Equal(t, "other", spaceTUR.Spec.TierName)
+			assert.NotEmpty(t, spaceTUR.Spec.CurrentTierHash)
+			assert.Equal(t, "basic", spaceTUR.Annotations[tierutil.MigrateFromAnnotationKey])
+		})
+	})
+
+	t.Run("new rollout when the deprecation begins", func(t *testing.T) {
+		// given
+		basicTier := tiertest.BasicTier(t, tiertest.CurrentBasicTemplates, tiertest.WithCurrentUpdateInProgress(),
+			tiertest.WithCondition(toolchainv1alpha1.Condition{
+				Type:   nstemplatetier.RolloutHalted,
+				Status: corev1.ConditionTrue,
+				Reason: nstemplatetier.RolloutFailureThresholdExceededReason,
+			}))
+		basicTier.Annotations = map[string]string{
+			nstemplatetier.RolloutStateAnnotationKey: rolloutStateFor(t, basicTier, "halted", 0, 10),
+			tierutil.DeprecatedByAnnotationKey:       "other",
+		}
+		initObjs := []runtime.Object{basicTier, otherTier}
+		initObjs = append(initObjs, murtest.NewMasterUserRecords(t, 3, "user-%d", murtest.Account("cluster1", *basicTier))...)
+		r, req, cl := prepareReconcile(t, basicTier.Name, initObjs...)
+
+		// when
+		res, err := r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, reconcile.Result{Requeue: true}, res)
+		tiertest.AssertThatNSTemplateTier(t, "basic", cl).
+			HasStatusUpdatesItems(2).
+			HasLatestUpdate(toolchainv1alpha1.NSTemplateTierHistory{
+				Hash: basicTier.Labels["toolchain.dev.openshift.com/basic-tier-hash"],
+			}).
+			HasAnnotation(nstemplatetier.RolloutStateAnnotationKey, migrationStateFor(t, basicTier, "other", "full", 0, 0)).
+			HasConditions(toolchainv1alpha1.Condition{
+				Type:   nstemplatetier.RolloutHalted,
+				Status: corev1.ConditionFalse,
+				Reason: nstemplatetier.RolloutInProgressReason,
+			})
+		tier := &toolchainv1alpha1.NSTemplateTier{}
+		require.NoError(t, cl.Get(context.TODO(), types.NamespacedName{Namespace: test.HostOperatorNs, Name: "basic"}, tier))
+		assert.Empty(t, tier.Status.Updates[0].FailedAccounts)
+		turtest.AssertThatTemplateUpdateRequests(t, cl).TotalCount(0)
+
+		t.Run("migration not affected by the previous rollout", func(t *testing.T) {
+			// when
+			res, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, reconcile.Result{}, res)
+			turtest.AssertThatTemplateUpdateRequests(t, cl).TotalCount(1)
+			tiertest.AssertThatNSTemplateTier(t, "basic", cl).
+				HasStatusUpdatesItems(2).
+				HasAnnotation(nstemplatetier.RolloutStateAnnotationKey, migrationStateFor(t, basicTier, "other", "full", 0, 0))
+		})
+	})
+
+	t.Run("migration completed", func(t *testing.T) {
+		// given
+		basicTier := tiertest.BasicTier(t, tiertest.CurrentBasicTemplates, tiertest.WithCurrentUpdateInProgress(),
+			tiertest.WithAnnotation(tierutil.DeprecatedByAnnotationKey, "other"))
+		r, req, cl := prepareReconcile(t, basicTier.Name, basicTier, otherTier)
+
+		// when
+		_, err := r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		turtest.AssertThatTemplateUpdateRequests(t, cl).TotalCount(0)
+		tiertest.AssertThatNSTemplateTier(t, "basic", cl).HasConditions(toolchainv1alpha1.Condition{
+			Type:    nstemplatetier.Deprecated,
+			Status:  corev1.ConditionTrue,
+			Reason:  nstemplatetier.DeprecationMigratedReason,
+			Message: "all MasterUserRecords and Spaces were migrated to the 'other' tier",
+		})
+		tier := &toolchainv1alpha1.NSTemplateTier{}
+		require.NoError(t, cl.Get(context.TODO(), types.NamespacedName{Namespace: test.HostOperatorNs, Name: "basic"}, tier))
+		assert.NotNil(t, tier.Status.Updates[len(tier.Status.Updates)-1].CompletionTime)
+	})
+
+	t.Run("migration paused", func(t *testing.T) {
+		// given
+		basicTier := tiertest.BasicTier(t, tiertest.CurrentBasicTemplates, tiertest.WithCurrentUpdateInProgress(),
+			tiertest.WithAnnotation(tierutil.DeprecatedByAnnotationKey, "other"),
+			tiertest.WithAnnotation(nstemplatetier.RolloutControlAnnotationKey, nstemplatetier.RolloutPause))
+		initObjs := []runtime.Object{basicTier, otherTier}
+		initObjs = append(initObjs, murtest.NewMasterUserRecords(t, 3, "user-%d", murtest.Account("cluster1", *basicTier))...)
+		r, req, cl := prepareReconcile(t, basicTier.Name, initObjs...)
+
+		// when
+		_, err := r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		turtest.AssertThatTemplateUpdateRequests(t, cl).TotalCount(0)
+		// the deprecation is shown even though the migration is paused
+		murtest.AssertThatMasterUserRecord(t, "user-0", cl).HasLabelWithValue(deprecatedLabelKey, "other")
+	})
+
+	t.Run("deprecation cancelled", func(t *testing.T) {
+		// given
+		basicTier := tiertest.BasicTier(t, tiertest.CurrentBasicTemplates, tiertest.WithCurrentUpdateInProgress(),
+			tiertest.WithCondition(toolchainv1alpha1.Condition{
+				Type:   nstemplatetier.Deprecated,
+				Status: corev1.ConditionTrue,
+				Reason: nstemplatetier.DeprecationMigratingReason,
+			}))
+		initObjs := []runtime.Object{basicTier}
+		initObjs = append(initObjs, murtest.NewMasterUserRecords(t, 2, "user-%d", murtest.Account("cluster1", *basicTier),
+			murtest.WithLabel(deprecatedLabelKey, "other"))...)
+		initObjs = append(initObjs, spacetest.NewSpace("space-0", spacetest.WithTierNameAndHashLabelFor(basicTier),
+			spacetest.WithLabel(deprecatedLabelKey, "other")))
+		r, req, cl := prepareReconcile(t, basicTier.Name, initObjs...)
+
+		// when
+		_, err := r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		murtest.AssertThatMasterUserRecord(t, "user-0", cl).DoesNotHaveLabel(deprecatedLabelKey)
+		murtest.AssertThatMasterUserRecord(t, "user-1", cl).DoesNotHaveLabel(deprecatedLabelKey)
+		spacetest.AssertThatSpace(t, test.HostOperatorNs, "space-0", cl).DoesNotHaveLabel(deprecatedLabelKey)
+		tiertest.AssertThatNSTemplateTier(t, "basic", cl).HasConditions(toolchainv1alpha1.Condition{
+			Type:   nstemplatetier.Deprecated,
+			Status: corev1.ConditionFalse,
+			Reason: nstemplatetier.NotDeprecatedReason,
+		})
+	})
+
+	t.Run("failures", func(t *testing.T) {
+
+		t.Run("invalid successor", func(t *testing.T) {
+			for successor, msg := range map[string]string{
+				"unknown": "the successor tier 'unknown' does not exist",
+				"basic":   "the tier 'basic' cannot be deprecated in favour of itself",
+				"other":   "the successor tier 'other' is deprecated too, in favour of the 'advanced' tier",
+			} {
+				t.Run(successor, func(t *testing.T) {
+					// given
+					basicTier := tiertest.BasicTier(t, tiertest.CurrentBasicTemplates, tiertest.WithCurrentUpdateInProgress(),
+						tiertest.WithAnnotation(tierutil.DeprecatedByAnnotationKey, successor))
+					deprecatedOtherTier := tiertest.OtherTier()
+					deprecatedOtherTier.Annotations = map[string]string{tierutil.DeprecatedByAnnotationKey: "advanced"}
+					initObjs := []runtime.Object{basicTier, deprecatedOtherTier}
+					initObjs = append(initObjs, murtest.NewMasterUserRecords(t, 1, "user-%d", murtest.Account("cluster1", *basicTier))...)
+					r, req, cl := prepareReconcile(t, basicTier.Name, initObjs...)
+
+					// when
+					_, err := r.Reconcile(context.TODO(), req)
+
+					// then
+					require.EqualError(t, err, "unable to migrate the MasterUserRecords and Spaces of the deprecated NSTemplateTier: "+msg)
+					turtest.AssertThatTemplateUpdateRequests(t, cl).TotalCount(0)
+					tiertest.AssertThatNSTemplateTier(t, "basic", cl).HasConditions(toolchainv1alpha1.Condition{
+						Type:    nstemplatetier.Deprecated,
+						Status:  corev1.ConditionTrue,
+						Reason:  nstemplatetier.DeprecationInvalidSuccessorReason,
+						Message: msg,
+					})
+				})
+			}
+		})
+
+		t.Run("unable to label the MasterUserRecords", func(t *testing.T) {
+			// given
+			basicTier := tiertest.BasicTier(t, tiertest.CurrentBasicTemplates, tiertest.WithCurrentUpdateInProgress(),
+				tiertest.WithAnnotation(tierutil.DeprecatedByAnnotationKey, "other"))
+			initObjs := []runtime.Object{basicTier, otherTier}
+			initObjs = append(initObjs, murtest.NewMasterUserRecords(t, 1, "user-%d", murtest.Account("cluster1", *basicTier))...)
+			r, req, cl := prepareReconcile(t, basicTier.Name, initObjs...)
+			cl.MockUpdate = func(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+				if _, ok := obj.(*toolchainv1alpha1.MasterUserRecord); ok && obj.GetLabels()[deprecatedLabelKey] != "" {
+					return fmt.Errorf("mock error")
+				}
+				return cl.Client.Update(ctx, obj, opts...)
+			}
+
+			// when
+			_, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.EqualError(t, err, "unable to update the tier deprecation labels: unable to set the tier deprecation label of the MasterUserRecord 'user-0': mock error")
+			turtest.AssertThatTemplateUpdateRequests(t, cl).TotalCount(0)
+		})
+	})
+}
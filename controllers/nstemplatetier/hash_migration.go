@@ -0,0 +1,62 @@
+package nstemplatetier
+
+import (
+	"context"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	tierutil "github.com/codeready-toolchain/host-operator/controllers/nstemplatetier/util"
+
+	"github.com/go-logr/logr"
+	errs "github.com/pkg/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// hashMigrationBatchSize the maximum number of MasterUserRecords and Spaces (each) whose tier hash label is migrated in a single reconcile loop
+const hashMigrationBatchSize = 100
+
+// migrateHashLabels replaces the legacy tier hash label of the MasterUserRecords and Spaces which are up-to-date with the templateRefs
+// of the given tier with the current (versioned) hash of the tier, so they are not considered as outdated (and not updated) only
+// because the way the hash is computed changed.
+// Returns `true` if a whole batch was migrated, ie, if there may be more MasterUserRecords or Spaces to migrate.
+func (r *Reconciler) migrateHashLabels(logger logr.Logger, tier *toolchainv1alpha1.NSTemplateTier) (bool, error) {
+	legacyHash, err := tierutil.ComputeLegacyHashForNSTemplateTier(tier)
+	if err != nil {
+		return false, errs.Wrap(err, "unable to compute the legacy hash of the tier")
+	}
+	hash, err := tierutil.ComputeHashForNSTemplateTier(tier)
+	if err != nil {
+		return false, errs.Wrap(err, "unable to compute the hash of the tier")
+	}
+	labelKey := tierutil.TemplateTierHashLabelKey(tier.Name)
+	listOpts := []client.ListOption{
+		client.InNamespace(tier.Namespace),
+		client.MatchingLabels{labelKey: legacyHash},
+		client.Limit(hashMigrationBatchSize),
+	}
+
+	murs := toolchainv1alpha1.MasterUserRecordList{}
+	if err := r.Client.List(context.TODO(), &murs, listOpts...); err != nil {
+		return false, errs.Wrap(err, "unable to list the MasterUserRecords with a legacy tier hash label")
+	}
+	for i := range murs.Items {
+		murs.Items[i].Labels[labelKey] = hash
+		if err := r.Client.Update(context.TODO(), &murs.Items[i]); err != nil {
+			return false, errs.Wrapf(err, "unable to migrate the tier hash label of the MasterUserRecord '%s'", murs.Items[i].Name)
+		}
+	}
+
+	spaces := toolchainv1alpha1.SpaceList{}
+	if err := r.Client.List(context.TODO(), &spaces, listOpts...); err != nil {
+		return false, errs.Wrap(err, "unable to list the Spaces with a legacy tier hash label")
+	}
+	for i := range spaces.Items {
+		spaces.Items[i].Labels[labelKey] = hash
+		if err := r.Client.Update(context.TODO(), &spaces.Items[i]); err != nil {
+			return false, errs.Wrapf(err, "unable to migrate the tier hash label of the Space '%s'", spaces.Items[i].Name)
+		}
+	}
+	if len(murs.Items) > 0 || len(spaces.Items) > 0 {
+		logger.Info("migrated the legacy tier hash labels", "masteruserrecords", len(murs.Items), "spaces", len(spaces.Items))
+	}
+	return len(murs.Items) == hashMigrationBatchSize || len(spaces.Items) == hashMigrationBatchSize, nil
+}
@@ -0,0 +1,133 @@
+package nstemplatetier_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	tierutil "github.com/codeready-toolchain/host-operator/controllers/nstemplatetier/util"
+	tiertest "github.com/codeready-toolchain/host-operator/test/nstemplatetier"
+	spacetest "github.com/codeready-toolchain/host-operator/test/space"
+	turtest "github.com/codeready-toolchain/host-operator/test/templateupdaterequest"
+	murtest "github.com/codeready-toolchain/toolchain-common/pkg/test/masteruserrecord"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestMigrateHashLabels(t *testing.T) {
+
+	previousBasicTier := tiertest.BasicTier(t, tiertest.PreviousBasicTemplates)
+	labelKey := tierutil.TemplateTierHashLabelKey("basic")
+
+	t.Run("legacy labels of up-to-date resources are migrated", func(t *testing.T) {
+		// given
+		basicTier := tiertest.BasicTier(t, tiertest.CurrentBasicTemplates, tiertest.WithCurrentUpdateInProgress())
+		hash, err := tierutil.ComputeHashForNSTemplateTier(basicTier)
+		require.NoError(t, err)
+		legacyHash, err := tierutil.ComputeLegacyHashForNSTemplateTier(basicTier)
+		require.NoError(t, err)
+		initObjs := []runtime.Object{basicTier}
+		// MasterUserRecords created by the test helpers have a legacy hash label
+		initObjs = append(initObjs, murtest.NewMasterUserRecords(t, 5, "current-user-%d", murtest.Account("cluster1", *basicTier))...)
+		initObjs = append(initObjs, murtest.NewMasterUserRecords(t, 5, "previous-user-%d", murtest.Account("cluster1", *previousBasicTier))...)
+		initObjs = append(initObjs, spacetest.NewSpaces(5, "current-space-%d", spacetest.WithTierName("basic"), spacetest.WithLabel(labelKey, legacyHash))...)
+		r, req, cl := prepareReconcile(t, basicTier.Name, initObjs...)
+
+		// when
+		res, err := r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, reconcile.Result{}, res)
+		for i := 0; i < 5; i++ {
+			murtest.AssertThatMasterUserRecord(t, fmt.Sprintf("current-user-%d", i), cl).HasLabelWithValue(labelKey, hash)
+			spacetest.AssertThatSpace(t, basicTier.Namespace, fmt.Sprintf("current-space-%d", i), cl).HasMatchingTierLabelForTier(basicTier)
+		}
+		// the outdated MasterUserRecords are not migrated, and they are updated with a TemplateUpdateRequest
+		previousLegacyHash, err := tierutil.ComputeLegacyHashForNSTemplateTier(previousBasicTier)
+		require.NoError(t, err)
+		murtest.AssertThatMasterUserRecord(t, "previous-user-0", cl).HasLabelWithValue(labelKey, previousLegacyHash)
+		turtest.AssertThatTemplateUpdateRequests(t, cl).TotalCount(1)
+	})
+
+	t.Run("legacy labels are migrated in batches", func(t *testing.T) {
+		// given
+		basicTier := tiertest.BasicTier(t, tiertest.CurrentBasicTemplates, tiertest.WithCurrentUpdateInProgress())
+		hash, err := tierutil.ComputeHashForNSTemplateTier(basicTier)
+		require.NoError(t, err)
+		initObjs := []runtime.Object{basicTier}
+		initObjs = append(initObjs, murtest.NewMasterUserRecords(t, 120, "user-%d", murtest.Account("cluster1", *basicTier))...)
+		r, req, cl := prepareReconcile(t, basicTier.Name, initObjs...)
+
+		// when
+		res, err := r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, reconcile.Result{Requeue: true}, res)
+		murs := &toolchainv1alpha1.MasterUserRecordList{}
+		require.NoError(t, cl.Client.List(context.TODO(), murs, client.MatchingLabels{labelKey: hash}))
+		assert.Len(t, murs.Items, 100)
+		turtest.AssertThatTemplateUpdateRequests(t, cl).TotalCount(0)
+
+		t.Run("remaining labels are migrated", func(t *testing.T) {
+			// when
+			res, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, reconcile.Result{}, res)
+			murs := &toolchainv1alpha1.MasterUserRecordList{}
+			require.NoError(t, cl.Client.List(context.TODO(), murs, client.MatchingLabels{labelKey: hash}))
+			assert.Len(t, murs.Items, 120)
+			turtest.AssertThatTemplateUpdateRequests(t, cl).TotalCount(0)
+		})
+	})
+
+	t.Run("failures", func(t *testing.T) {
+
+		t.Run("unable to list MasterUserRecords", func(t *testing.T) {
+			// given
+			basicTier := tiertest.BasicTier(t, tiertest.CurrentBasicTemplates, tiertest.WithCurrentUpdateInProgress())
+			r, req, cl := prepareReconcile(t, basicTier.Name, basicTier)
+			cl.MockList = func(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+				if _, ok := list.(*toolchainv1alpha1.MasterUserRecordList); ok {
+					return fmt.Errorf("mock error")
+				}
+				return cl.Client.List(ctx, list, opts...)
+			}
+
+			// when
+			_, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.EqualError(t, err, "unable to migrate the tier hash labels: unable to list the MasterUserRecords with a legacy tier hash label: mock error")
+		})
+
+		t.Run("unable to update Space", func(t *testing.T) {
+			// given
+			basicTier := tiertest.BasicTier(t, tiertest.CurrentBasicTemplates, tiertest.WithCurrentUpdateInProgress())
+			legacyHash, err := tierutil.ComputeLegacyHashForNSTemplateTier(basicTier)
+			require.NoError(t, err)
+			space := spacetest.NewSpace("oddity", spacetest.WithTierName("basic"), spacetest.WithLabel(labelKey, legacyHash))
+			r, req, cl := prepareReconcile(t, basicTier.Name, basicTier, space)
+			cl.MockUpdate = func(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+				if _, ok := obj.(*toolchainv1alpha1.Space); ok {
+					return fmt.Errorf("mock error")
+				}
+				return cl.Client.Update(ctx, obj, opts...)
+			}
+
+			// when
+			_, err = r.Reconcile(context.TODO(), req)
+
+			// then
+			require.EqualError(t, err, "unable to migrate the tier hash labels: unable to migrate the tier hash label of the Space 'oddity': mock error")
+		})
+	})
+}
@@ -226,3 +226,146 @@ func TestComputeHash(t *testing.T) {
 	})
 
 }
+
+func TestComputeVersionedHash(t *testing.T) {
+
+	newTier := func() *toolchainv1alpha1.NSTemplateTier {
+		return &toolchainv1alpha1.NSTemplateTier{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: operatorNamespace,
+				Name:      "basic",
+			},
+			Spec: toolchainv1alpha1.NSTemplateTierSpec{
+				Namespaces: []toolchainv1alpha1.NSTemplateTierNamespace{
+					{
+						TemplateRef: "basic-code-123456old",
+					},
+					{
+						TemplateRef: "basic-dev-123456old",
+					},
+				},
+				ClusterResources: &toolchainv1alpha1.NSTemplateTierClusterResources{
+					TemplateRef: "basic-clusterresources-123456a",
+				},
+				SpaceRoles: map[string]toolchainv1alpha1.NSTemplateTierSpaceRole{
+					"admin": {
+						TemplateRef: "basic-admin-123456a",
+					},
+				},
+				DeactivationTimeoutDays: 30,
+			},
+		}
+	}
+	hash, err := tierutil.ComputeHashForNSTemplateTier(newTier())
+	require.NoError(t, err)
+
+	t.Run("should be versioned", func(t *testing.T) {
+		// then
+		assert.Regexp(t, "^v2-[0-9a-f]{32}$", hash)
+		legacyHash, err := tierutil.ComputeLegacyHashForNSTemplateTier(newTier())
+		require.NoError(t, err)
+		assert.NotEqual(t, legacyHash, hash)
+	})
+
+	t.Run("should not depend on the order of the templateRefs", func(t *testing.T) {
+		// given
+		tier := newTier()
+		tier.Spec.Namespaces[0], tier.Spec.Namespaces[1] = tier.Spec.Namespaces[1], tier.Spec.Namespaces[0]
+		// when
+		other, err := tierutil.ComputeHashForNSTemplateTier(tier)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, hash, other)
+	})
+
+	t.Run("should change with the content of the tier", func(t *testing.T) {
+		for name, change := range map[string]func(*toolchainv1alpha1.NSTemplateTier){
+			"namespace templateRef": func(tier *toolchainv1alpha1.NSTemplateTier) {
+				tier.Spec.Namespaces[0].TemplateRef = "basic-code-123456new"
+			},
+			"space role templateRef": func(tier *toolchainv1alpha1.NSTemplateTier) {
+				tier.Spec.SpaceRoles["admin"] = toolchainv1alpha1.NSTemplateTierSpaceRole{TemplateRef: "basic-admin-123456b"}
+			},
+			"deactivation timeout": func(tier *toolchainv1alpha1.NSTemplateTier) {
+				tier.Spec.DeactivationTimeoutDays = 60
+			},
+		} {
+			t.Run(name, func(t *testing.T) {
+				// given
+				tier := newTier()
+				change(tier)
+				// when
+				other, err := tierutil.ComputeHashForNSTemplateTier(tier)
+				// then
+				require.NoError(t, err)
+				assert.NotEqual(t, hash, other)
+			})
+		}
+	})
+
+	t.Run("hash for an NSTemplateSet", func(t *testing.T) {
+		// given
+		setSpec := toolchainv1alpha1.NSTemplateSetSpec{
+			TierName: "basic",
+			Namespaces: []toolchainv1alpha1.NSTemplateSetNamespace{
+				{
+					TemplateRef: "basic-dev-123456old",
+				},
+				{
+					TemplateRef: "basic-code-123456old",
+				},
+			},
+			ClusterResources: &toolchainv1alpha1.NSTemplateSetClusterResources{
+				TemplateRef: "basic-clusterresources-123456a",
+			},
+		}
+
+		t.Run("should be the tier hash when the templateRefs match", func(t *testing.T) {
+			// when
+			setHash, err := tierutil.ComputeHashForNSTemplateSet(newTier(), setSpec)
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, hash, setHash)
+		})
+
+		t.Run("should be the hash of the templateRefs when they do not match", func(t *testing.T) {
+			// given
+			tier := newTier()
+			tier.Spec.Namespaces[0].TemplateRef = "basic-code-123456new"
+			// when
+			setHash, err := tierutil.ComputeHashForNSTemplateSet(tier, setSpec)
+			// then
+			require.NoError(t, err)
+			refsHash, err := tierutil.ComputeHashForNSTemplateSetSpec(setSpec)
+			require.NoError(t, err)
+			assert.Equal(t, refsHash, setHash)
+		})
+
+		t.Run("should be the hash of the templateRefs when there is no tier", func(t *testing.T) {
+			// when
+			setHash, err := tierutil.ComputeHashForNSTemplateSet(nil, setSpec)
+			// then
+			require.NoError(t, err)
+			refsHash, err := tierutil.ComputeHashForNSTemplateSetSpec(setSpec)
+			require.NoError(t, err)
+			assert.Equal(t, refsHash, setHash)
+		})
+
+		t.Run("should be versioned", func(t *testing.T) {
+			// given
+			tier := newTier()
+			tier.Spec.Namespaces[0].TemplateRef = "basic-code-123456new"
+			// when
+			setHash, err := tierutil.ComputeHashForNSTemplateSet(tier, setSpec)
+			// then
+			require.NoError(t, err)
+			assert.Regexp(t, "^v2-[0-9a-f]{32}$", setHash)
+			// the legacy hash label of an up-to-date MasterUserRecord is migrated to the tier hash, and
+			// the one of an outdated MasterUserRecord never matches the tier hash either
+			legacyHash, err := tierutil.ComputeLegacyHashForNSTemplateTier(newTier())
+			require.NoError(t, err)
+			assert.NotEqual(t, legacyHash, setHash)
+			assert.NotEqual(t, hash, setHash)
+		})
+	})
+}
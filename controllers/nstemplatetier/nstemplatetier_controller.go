@@ -35,24 +35,30 @@ import (
 // . after a TemplateUpdateRequest belonging to an NSTemplateTier was created/updated/deleted
 // .. creates or deletes subsequent TemplateUpdateRequest resources until all MasterUserRecords have been updated (or failed to)
 // .. if the MasterUserRecord failed to updated: increment the failure counter and retain the resource name
+// . the update is rolled out to the canaries first (if any), and the rollout is halted when too many updates failed
+// . the rollout can be paused, resumed or aborted with the `rollout-control` annotation
+// . the tier can be rolled back to a previous revision with the `rollback-to` annotation
+// . the tier can be deprecated in favour of a successor with the `deprecated-by` annotation, in which case its MasterUserRecords
+// .. and Spaces are migrated to the successor tier with TemplateUpdateRequests, at the same pace as an update
 // ----------------------------------------------------------------------------------------------------------------------------
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *Reconciler) SetupWithManager(mgr manager.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&toolchainv1alpha1.NSTemplateTier{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		For(&toolchainv1alpha1.NSTemplateTier{}, builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, RolloutControlChangedPredicate{}))).
 		Owns(&toolchainv1alpha1.TemplateUpdateRequest{}).
 		Complete(r)
 }
 
-// Reconciler reconciles a NSTemplateTier object (only when this latter's specs were updated)
+// Reconciler reconciles a NSTemplateTier object (only when this latter's specs or rollout control were updated)
 type Reconciler struct {
 	Client client.Client
 	Scheme *runtime.Scheme
 }
 
 //+kubebuilder:rbac:groups=toolchain.dev.openshift.com,resources=nstemplatetiers,verbs=get;list;watch;create;update;patch;delete
-//+kubebuilder:rbac:groups=toolchain.dev.openshift.com,resources=spaces,verbs=get;list;watch
+//+kubebuilder:rbac:groups=toolchain.dev.openshift.com,resources=spaces,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=toolchain.dev.openshift.com,resources=masteruserrecords,verbs=get;list;watch;update;patch
 //+kubebuilder:rbac:groups=toolchain.dev.openshift.com,resources=nstemplatetiers/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=toolchain.dev.openshift.com,resources=nstemplatetiers/finalizers,verbs=update
 
@@ -61,6 +67,11 @@ type Reconciler struct {
 // - creating and delete the TemplateUpdateRequest to update the MasterUserRecord associated with this tier
 // - updating the `Failed` counter in the `status.updates` when a MasterUserRecord failed to update
 // - setting the `completionTime` when all MasterUserRecord have been processed
+// - rolling out the update to the canaries first, and halting the rollout when the failure threshold is exceeded
+// - pausing, resuming or aborting the rollout on demand
+// - rolling the tier back to a previous revision on demand, and recording the revisions to roll back to
+// - migrating the legacy tier hash labels of the MasterUserRecords and Spaces which are up-to-date with the tier
+// - migrating the MasterUserRecords and Spaces of a deprecated tier to its successor
 func (r *Reconciler) Reconcile(ctx context.Context, request ctrl.Request) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
 
@@ -81,6 +92,15 @@ func (r *Reconciler) Reconcile(ctx context.Context, request ctrl.Request) (ctrl.
 		return reconcile.Result{}, errs.Wrapf(err, "unable to get ToolchainConfig")
 	}
 
+	// roll back to a previous revision if requested
+	if rolledBack, err := r.ensureRollback(logger, tier); err != nil {
+		logger.Error(err, "unable to roll back the NSTemplateTier")
+		return reconcile.Result{}, errs.Wrap(err, "unable to roll back the NSTemplateTier")
+	} else if rolledBack {
+		logger.Info("NSTemplateTier was rolled back, waiting for the spec update to be reconciled")
+		return reconcile.Result{}, nil
+	}
+
 	// create a new entry in the `status.history`
 	if added, err := r.ensureStatusUpdateRecord(logger, tier); err != nil {
 		logger.Error(err, "unable to insert a new entry in status.updates after NSTemplateTier changed")
@@ -89,7 +109,61 @@ func (r *Reconciler) Reconcile(ctx context.Context, request ctrl.Request) (ctrl.
 		logger.Info("Requeing after adding a new entry in tier.status.updates")
 		return reconcile.Result{Requeue: true}, nil
 	}
-	if done, err := r.ensureTemplateUpdateRequest(logger, config, tier); err != nil {
+	if err := r.recordRevision(logger, tier); err != nil {
+		logger.Error(err, "unable to record the revision of the NSTemplateTier")
+		return reconcile.Result{}, errs.Wrap(err, "unable to record the revision of the NSTemplateTier")
+	}
+	// replace the legacy hash labels before looking for outdated MasterUserRecords and Spaces
+	if more, err := r.migrateHashLabels(logger, tier); err != nil {
+		logger.Error(err, "unable to migrate the tier hash labels")
+		return reconcile.Result{}, errs.Wrap(err, "unable to migrate the tier hash labels")
+	} else if more {
+		logger.Info("Requeing to migrate the next batch of tier hash labels")
+		return reconcile.Result{Requeue: true}, nil
+	}
+	state, err := r.ensureRolloutState(logger, config, tier)
+	if err != nil {
+		logger.Error(err, "unable to initialize the rollout of the NSTemplateTier update")
+		return reconcile.Result{}, errs.Wrap(err, "unable to initialize the rollout of the NSTemplateTier update")
+	}
+	// start a new rollout when the tier was deprecated (or no longer is)
+	if added, err := r.ensureDeprecationUpdateRecord(logger, tier, state); err != nil {
+		logger.Error(err, "unable to start the rollout after the deprecation of the NSTemplateTier changed")
+		return reconcile.Result{}, errs.Wrap(err, "unable to start the rollout after the deprecation of the NSTemplateTier changed")
+	} else if added {
+		logger.Info("Requeing after adding a new entry in tier.status.updates")
+		return reconcile.Result{Requeue: true}, nil
+	}
+	if err := r.applyRolloutControl(logger, tier, state); err != nil {
+		logger.Error(err, "unable to apply the rollout control of the NSTemplateTier update")
+		return reconcile.Result{}, errs.Wrap(err, "unable to apply the rollout control of the NSTemplateTier update")
+	}
+	if latest := tier.Status.Updates[len(tier.Status.Updates)-1]; state.Stage == rolloutStageAborted && latest.CompletionTime != nil {
+		logger.Info("rollout of the tier update was aborted and is complete")
+		return reconcile.Result{}, nil
+	}
+	// label the MasterUserRecords and Spaces of a deprecated tier, and migrate them to the successor tier
+	if more, err := r.labelDeprecatedTierUsers(logger, tier); err != nil {
+		logger.Error(err, "unable to update the tier deprecation labels")
+		return reconcile.Result{}, errs.Wrap(err, "unable to update the tier deprecation labels")
+	} else if more {
+		logger.Info("Requeing to label the next batch of MasterUserRecords and Spaces with the tier deprecation")
+		return reconcile.Result{Requeue: true}, nil
+	}
+	if _, deprecated := tierutil.SuccessorOf(tier); deprecated {
+		if done, err := r.ensureMigration(logger, config, tier, state); err != nil {
+			logger.Error(err, "unable to migrate the MasterUserRecords and Spaces of the deprecated NSTemplateTier")
+			return reconcile.Result{}, errs.Wrap(err, "unable to migrate the MasterUserRecords and Spaces of the deprecated NSTemplateTier")
+		} else if done && tier.Status.Updates[len(tier.Status.Updates)-1].CompletionTime == nil {
+			logger.Info("All MasterUserRecords and Spaces were migrated. Setting the completion timestamp")
+			if err := r.markUpdateRecordAsCompleted(tier); err != nil {
+				logger.Error(err, "unable to mark latest status.update as complete")
+				return reconcile.Result{}, errs.Wrap(err, "unable to mark latest status.update as complete")
+			}
+		}
+		return reconcile.Result{}, nil
+	}
+	if done, err := r.ensureTemplateUpdateRequest(logger, config, tier, state); err != nil {
 		logger.Error(err, "unable to ensure TemplateRequestUpdate resource after NSTemplateTier changed")
 		return reconcile.Result{}, errs.Wrap(err, "unable to ensure TemplateRequestUpdate resource after NSTemplateTier changed")
 	} else if done {
@@ -138,13 +212,39 @@ func (r *Reconciler) ensureStatusUpdateRecord(logger logr.Logger, tier *toolchai
 // If not, then it creates a TemplateUpdateRequest resource for the first MasterUserRecord not up-to-date with the tier, and
 // returns `false, nil` so the controller will wait for the next reconcile loop to create subsequent TemplateUpdateRequest resources,
 // until the `MaxPoolSize` threshold is reached (returns `false, nil`) or no other MasterUserRecord needs to be updated (returns `true,nil`)
-func (r *Reconciler) ensureTemplateUpdateRequest(logger logr.Logger, config toolchainconfig.ToolchainConfig, tier *toolchainv1alpha1.NSTemplateTier) (bool, error) {
-	if activeTemplateUpdateRequests, deleted, err := r.activeTemplateUpdateRequests(logger, config, tier); err != nil {
+// During the canary stage, only the canaries are updated. The rollout is halted (and never completes) when the ratio of failed updates
+// exceeds the configured threshold.
+func (r *Reconciler) ensureTemplateUpdateRequest(logger logr.Logger, config toolchainconfig.ToolchainConfig, tier *toolchainv1alpha1.NSTemplateTier, state *rolloutState) (bool, error) {
+	if activeTemplateUpdateRequests, deleted, err := r.activeTemplateUpdateRequests(logger, config, tier, state); err != nil {
 		return false, errs.Wrap(err, "unable to get active TemplateUpdateRequests")
 	} else if deleted {
 		logger.Info("requeuing as a TemplateUpdateRequest was deleted")
 		// skip TemplateUpdateRequest creation in this reconcile loop since one was deleted
 		return false, nil
+	} else if state.Stage == rolloutStageAborted {
+		// the update record is marked as completed, even though some MasterUserRecords and Spaces were not updated
+		logger.Info("rollout of the tier update was aborted, not creating any TemplateUpdateRequest")
+		return activeTemplateUpdateRequests == 0, nil
+	} else if state.Stage == rolloutStageHalted {
+		logger.Info("rollout of the tier update is halted, not creating any TemplateUpdateRequest")
+		return false, nil
+	} else if isRolloutPaused(tier) {
+		logger.Info("rollout of the tier update is paused, not creating any TemplateUpdateRequest")
+		return false, nil
+	} else if latest := tier.Status.Updates[len(tier.Status.Updates)-1]; state.Stage == rolloutStageFull &&
+		state.Processed >= config.Tiers().RolloutMinUpdatesBeforeHalt() &&
+		failureThresholdExceeded(latest.Failures, state.Processed, config.Tiers().RolloutMaxFailurePercentage()) {
+		return false, r.haltRollout(logger, config, tier, state, "rollout")
+	} else if state.Stage == rolloutStageCanary && tier.Annotations[CanarySelectorAnnotationKey] == "" &&
+		state.Processed+activeTemplateUpdateRequests >= state.CanarySize {
+		if activeTemplateUpdateRequests > 0 {
+			logger.Info("waiting for the canaries to be updated", "active", activeTemplateUpdateRequests)
+			return false, nil
+		}
+		if halted, err := r.completeCanaryStage(logger, config, tier, state); err != nil || halted {
+			return false, err
+		}
+		return r.ensureTemplateUpdateRequest(logger, config, tier, state)
 	} else if activeTemplateUpdateRequests < config.Tiers().TemplateUpdateRequestMaxPoolSize() {
 		// create a TemplateUpdateRequest if active count < MaxPoolSize,
 		// ie, find a MasterUserRecord or Space which is not already up-to-date
@@ -155,27 +255,44 @@ func (r *Reconciler) ensureTemplateUpdateRequest(logger logr.Logger, config tool
 		if err != nil {
 			return false, errs.Wrap(err, "unable to get MasterUserRecords to update")
 		}
-		murs := toolchainv1alpha1.MasterUserRecordList{}
-		if err = r.Client.List(context.TODO(), &murs,
+		listOpts := []client.ListOption{
 			client.InNamespace(tier.Namespace),
-			client.Limit(config.Tiers().TemplateUpdateRequestMaxPoolSize()+1),
-			matchOutdated,
-		); err != nil {
+			client.Limit(config.Tiers().TemplateUpdateRequestMaxPoolSize() + 1),
+		}
+		// during the canary stage with a selector, only list the canaries, all at once so the ones which failed can be skipped
+		canaryBySelector := state.Stage == rolloutStageCanary && tier.Annotations[CanarySelectorAnnotationKey] != ""
+		if canaryBySelector {
+			if matchOutdated, err = canarySelector(tier, matchOutdated); err != nil {
+				return false, err
+			}
+			listOpts = []client.ListOption{client.InNamespace(tier.Namespace)}
+		}
+		listOpts = append(listOpts, matchOutdated)
+		murs := toolchainv1alpha1.MasterUserRecordList{}
+		if err = r.Client.List(context.TODO(), &murs, listOpts...); err != nil {
 			return false, errs.Wrap(err, "unable to get MasterUserRecords to update")
 		}
 		logger.Info("listed MasterUserRecords", "count", len(murs.Items), "selector", matchOutdated)
 
 		spaces := toolchainv1alpha1.SpaceList{}
-		if err = r.Client.List(context.TODO(), &spaces,
-			client.InNamespace(tier.Namespace),
-			client.Limit(config.Tiers().TemplateUpdateRequestMaxPoolSize()+1),
-			matchOutdated,
-		); err != nil {
+		if err = r.Client.List(context.TODO(), &spaces, listOpts...); err != nil {
 			return false, errs.Wrap(err, "unable to get Spaces to update")
 		}
 		logger.Info("listed Spaces", "count", len(spaces.Items), "selector", matchOutdated)
 
+		failedAccounts := tier.Status.Updates[len(tier.Status.Updates)-1].FailedAccounts
+		if canaryBySelector {
+			murs.Items = withoutFailedMasterUserRecords(murs.Items, failedAccounts)
+			spaces.Items = withoutFailedSpaces(spaces.Items, failedAccounts)
+		}
 		if activeTemplateUpdateRequests == 0 && len(murs.Items) == 0 && len(spaces.Items) == 0 {
+			if canaryBySelector {
+				// all canaries were processed
+				if halted, err := r.completeCanaryStage(logger, config, tier, state); err != nil || halted {
+					return false, err
+				}
+				return r.ensureTemplateUpdateRequest(logger, config, tier, state)
+			}
 			// we've reached the end: all MasterUserRecords and Spaces are up-to-date
 			return true, nil
 		}
@@ -192,7 +309,7 @@ func (r *Reconciler) ensureTemplateUpdateRequest(logger logr.Logger, config tool
 				return false, errs.Wrapf(err, "unable to get TemplateUpdateRequest for MasterUserRecord '%s'", mur.Name)
 			}
 			logger.Info("creating a TemplateUpdateRequest to update the MasterUserRecord", "name", mur.Name, "tier", tier.Name)
-			return r.createTemplateUpdateRequest(tier, mur.Name, toolchainv1alpha1.TemplateUpdateRequestSpec{
+			return r.createTemplateUpdateRequest(tier, mur.Name, nil, toolchainv1alpha1.TemplateUpdateRequestSpec{
 				TierName:         tier.Name,
 				Namespaces:       tier.Spec.Namespaces,
 				ClusterResources: tier.Spec.ClusterResources,
@@ -213,7 +330,7 @@ func (r *Reconciler) ensureTemplateUpdateRequest(logger logr.Logger, config tool
 			}
 			logger.Info("creating a TemplateUpdateRequest to update the Space", "name", space.Name, "tier", tier.Name)
 			hashLabel := tierutil.TemplateTierHashLabelKey(tier.Name)
-			return r.createTemplateUpdateRequest(tier, space.Name, toolchainv1alpha1.TemplateUpdateRequestSpec{
+			return r.createTemplateUpdateRequest(tier, space.Name, nil, toolchainv1alpha1.TemplateUpdateRequestSpec{
 				CurrentTierHash: space.Labels[hashLabel],
 			})
 		}
@@ -227,7 +344,7 @@ func (r *Reconciler) ensureTemplateUpdateRequest(logger logr.Logger, config tool
 // - the number of active TemplateUpdateRequests (ie, not complete, failed or being deleted)
 // - `true` if a TemplateUpdateRequest was deleted
 // - err if something bad happened
-func (r *Reconciler) activeTemplateUpdateRequests(logger logr.Logger, config toolchainconfig.ToolchainConfig, tier *toolchainv1alpha1.NSTemplateTier) (int, bool, error) {
+func (r *Reconciler) activeTemplateUpdateRequests(logger logr.Logger, config toolchainconfig.ToolchainConfig, tier *toolchainv1alpha1.NSTemplateTier, state *rolloutState) (int, bool, error) {
 	// fetch the list of TemplateUpdateRequest owned by the NSTemplateTier tier
 	templateUpdateRequests := toolchainv1alpha1.TemplateUpdateRequestList{}
 	if err := r.Client.List(context.TODO(), &templateUpdateRequests, client.MatchingLabels{
@@ -255,7 +372,7 @@ func (r *Reconciler) activeTemplateUpdateRequests(logger logr.Logger, config too
 		if condition.IsTrue(tur.Status.Conditions, toolchainv1alpha1.TemplateUpdateRequestComplete) ||
 			(condition.IsFalseWithReason(tur.Status.Conditions, toolchainv1alpha1.TemplateUpdateRequestComplete, toolchainv1alpha1.TemplateUpdateRequestUnableToUpdateReason) &&
 				maxUpdateFailuresReached(tur, config.Users().MasterUserRecordUpdateFailureThreshold())) {
-			if err := r.incrementCounters(logger, tier, tur); err != nil {
+			if err := r.incrementCounters(logger, tier, tur, state); err != nil {
 				return -1, false, err
 			}
 			if err := r.Client.Delete(context.TODO(), &tur); err != nil { // nolint:gosec
@@ -282,8 +399,9 @@ func maxUpdateFailuresReached(tur toolchainv1alpha1.TemplateUpdateRequest, thres
 		toolchainv1alpha1.TemplateUpdateRequestUnableToUpdateReason) >= threshod
 }
 
-// incrementCounters looks-up the latest entry in the `status.updates` and increments the `Total` and `Failures` counters
-func (r *Reconciler) incrementCounters(logger logr.Logger, tier *toolchainv1alpha1.NSTemplateTier, tur toolchainv1alpha1.TemplateUpdateRequest) error {
+// incrementCounters looks-up the latest entry in the `status.updates` and increments the `Failures` counter,
+// as well as the number of processed updates in the rollout state
+func (r *Reconciler) incrementCounters(logger logr.Logger, tier *toolchainv1alpha1.NSTemplateTier, tur toolchainv1alpha1.TemplateUpdateRequest, state *rolloutState) error {
 	if len(tier.Status.Updates) == 0 {
 		return fmt.Errorf("no entry in the `Status.Updates`")
 	}
@@ -299,7 +417,30 @@ func (r *Reconciler) incrementCounters(logger logr.Logger, tier *toolchainv1alph
 		return err
 	}
 	logger.Info("incrementing counter after TemplateUpdateRequest completed", "name", tur.Name)
-	return nil
+	state.Processed++
+	return r.saveRolloutState(tier, state)
+}
+
+// withoutFailedMasterUserRecords returns the given MasterUserRecords, except the ones whose update already failed
+func withoutFailedMasterUserRecords(murs []toolchainv1alpha1.MasterUserRecord, failedAccounts []string) []toolchainv1alpha1.MasterUserRecord {
+	result := make([]toolchainv1alpha1.MasterUserRecord, 0, len(murs))
+	for _, mur := range murs {
+		if !contains(failedAccounts, mur.Name) {
+			result = append(result, mur)
+		}
+	}
+	return result
+}
+
+// withoutFailedSpaces returns the given Spaces, except the ones whose update already failed
+func withoutFailedSpaces(spaces []toolchainv1alpha1.Space, failedAccounts []string) []toolchainv1alpha1.Space {
+	result := make([]toolchainv1alpha1.Space, 0, len(spaces))
+	for _, space := range spaces {
+		if !contains(failedAccounts, space.Name) {
+			result = append(result, space)
+		}
+	}
+	return result
 }
 
 // markUpdateRecordAsCompleted looks-up the latest entry in the `status.updates` and sets the `CompletionTime` to `metav1.Now()`,
@@ -315,8 +456,8 @@ func (r *Reconciler) markUpdateRecordAsCompleted(tier *toolchainv1alpha1.NSTempl
 	return r.Client.Status().Update(context.TODO(), tier)
 }
 
-// createTemplateUpdateRequest creates a TemplateUpdateRequest resource with the provided name and spec
-func (r *Reconciler) createTemplateUpdateRequest(tier *toolchainv1alpha1.NSTemplateTier, name string, spec toolchainv1alpha1.TemplateUpdateRequestSpec) (bool, error) {
+// createTemplateUpdateRequest creates a TemplateUpdateRequest resource with the provided name, annotations (if any) and spec
+func (r *Reconciler) createTemplateUpdateRequest(tier *toolchainv1alpha1.NSTemplateTier, name string, annotations map[string]string, spec toolchainv1alpha1.TemplateUpdateRequestSpec) (bool, error) {
 	tur := &toolchainv1alpha1.TemplateUpdateRequest{
 		ObjectMeta: metav1.ObjectMeta{
 			Namespace: tier.Namespace,
@@ -324,6 +465,7 @@ func (r *Reconciler) createTemplateUpdateRequest(tier *toolchainv1alpha1.NSTempl
 			Labels: map[string]string{
 				toolchainv1alpha1.NSTemplateTierNameLabelKey: tier.Name,
 			},
+			Annotations: annotations,
 		},
 		Spec: spec,
 	}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"testing"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
@@ -824,7 +825,8 @@ func TestReconcile(t *testing.T) {
 
 			r, req, cl := prepareReconcile(t, basicTier.Name, initObjs...)
 			cl.MockList = func(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
-				if _, ok := list.(*toolchainv1alpha1.MasterUserRecordList); ok {
+				// fail when listing the outdated resources, not when migrating the hash labels
+				if _, ok := list.(*toolchainv1alpha1.MasterUserRecordList); ok && hasOutdatedSelector(opts) {
 					return fmt.Errorf("mock error")
 				}
 				return cl.Client.List(ctx, list, opts...)
@@ -847,7 +849,8 @@ func TestReconcile(t *testing.T) {
 
 			r, req, cl := prepareReconcile(t, basicTier.Name, initObjs...)
 			cl.MockList = func(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
-				if _, ok := list.(*toolchainv1alpha1.SpaceList); ok {
+				// fail when listing the outdated resources, not when migrating the hash labels
+				if _, ok := list.(*toolchainv1alpha1.SpaceList); ok && hasOutdatedSelector(opts) {
 					return fmt.Errorf("mock error")
 				}
 				return cl.Client.List(ctx, list, opts...)
@@ -1103,7 +1106,7 @@ func prepareReconcile(t *testing.T, name string, initObjs ...runtime.Object) (re
 			if c > 0 {
 				murs.Items = murs.Items[c:]
 			}
-			if int(listOpts.Limit) < len(murs.Items) {
+			if listOpts.Limit > 0 && int(listOpts.Limit) < len(murs.Items) {
 				// keep the first items and remove the following ones to fit into the limit
 				murs.Items = murs.Items[:listOpts.Limit]
 			}
@@ -1128,7 +1131,7 @@ func prepareReconcile(t *testing.T, name string, initObjs ...runtime.Object) (re
 			if c > 0 {
 				spaces.Items = spaces.Items[c:]
 			}
-			if int(listOpts.Limit) < len(spaces.Items) {
+			if listOpts.Limit > 0 && int(listOpts.Limit) < len(spaces.Items) {
 				// keep the first items and remove the following ones to fit into the limit
 				spaces.Items = spaces.Items[:listOpts.Limit]
 			}
@@ -1158,3 +1161,13 @@ func prepareReconcile(t *testing.T, name string, initObjs ...runtime.Object) (re
 		},
 	}, cl
 }
+
+// hasOutdatedSelector returns `true` if the given options contain the label selector used to list outdated MasterUserRecords or Spaces
+func hasOutdatedSelector(opts []client.ListOption) bool {
+	for _, opt := range opts {
+		if selector, ok := opt.(client.MatchingLabelsSelector); ok && strings.Contains(selector.String(), "-tier-hash!=") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,30 @@
+package nstemplatetier
+
+import (
+	tierutil "github.com/codeready-toolchain/host-operator/controllers/nstemplatetier/util"
+
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// RolloutControlChangedPredicate triggers a reconcile when the RolloutControlAnnotationKey, RollbackToAnnotationKey or DeprecatedByAnnotationKey
+// annotation of an NSTemplateTier changed
+type RolloutControlChangedPredicate struct {
+	predicate.Funcs
+}
+
+var _ predicate.Predicate = RolloutControlChangedPredicate{}
+
+// Update filters update events and lets the reconcile loop be triggered when the rollout control, rollback or deprecation annotation
+// was added, changed or removed
+func (RolloutControlChangedPredicate) Update(e event.UpdateEvent) bool {
+	if e.ObjectOld == nil || e.ObjectNew == nil {
+		return false
+	}
+	for _, key := range []string{RolloutControlAnnotationKey, RollbackToAnnotationKey, tierutil.DeprecatedByAnnotationKey} {
+		if e.ObjectOld.GetAnnotations()[key] != e.ObjectNew.GetAnnotations()[key] {
+			return true
+		}
+	}
+	return false
+}
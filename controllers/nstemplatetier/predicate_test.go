@@ -0,0 +1,53 @@
+package nstemplatetier_test
+
+import (
+	"testing"
+
+	"github.com/codeready-toolchain/host-operator/controllers/nstemplatetier"
+	tierutil "github.com/codeready-toolchain/host-operator/controllers/nstemplatetier/util"
+	tiertest "github.com/codeready-toolchain/host-operator/test/nstemplatetier"
+
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestRolloutControlChangedPredicate(t *testing.T) {
+	// given
+	pred := nstemplatetier.RolloutControlChangedPredicate{}
+	withoutControl := tiertest.BasicTier(t, tiertest.CurrentBasicTemplates)
+	paused := tiertest.BasicTier(t, tiertest.CurrentBasicTemplates, tiertest.WithAnnotation(nstemplatetier.RolloutControlAnnotationKey, nstemplatetier.RolloutPause))
+	aborted := tiertest.BasicTier(t, tiertest.CurrentBasicTemplates, tiertest.WithAnnotation(nstemplatetier.RolloutControlAnnotationKey, nstemplatetier.RolloutAbort))
+	otherAnnotation := tiertest.BasicTier(t, tiertest.CurrentBasicTemplates, tiertest.WithAnnotation(nstemplatetier.RolloutStateAnnotationKey, "{}"))
+
+	t.Run("annotation added", func(t *testing.T) {
+		assert.True(t, pred.Update(event.UpdateEvent{ObjectOld: withoutControl, ObjectNew: paused}))
+	})
+
+	t.Run("annotation changed", func(t *testing.T) {
+		assert.True(t, pred.Update(event.UpdateEvent{ObjectOld: paused, ObjectNew: aborted}))
+	})
+
+	t.Run("annotation removed", func(t *testing.T) {
+		assert.True(t, pred.Update(event.UpdateEvent{ObjectOld: paused, ObjectNew: withoutControl}))
+	})
+
+	t.Run("rollback annotation added", func(t *testing.T) {
+		rollback := tiertest.BasicTier(t, tiertest.CurrentBasicTemplates, tiertest.WithAnnotation(nstemplatetier.RollbackToAnnotationKey, "abcd"))
+		assert.True(t, pred.Update(event.UpdateEvent{ObjectOld: withoutControl, ObjectNew: rollback}))
+	})
+
+	t.Run("deprecation annotation added", func(t *testing.T) {
+		deprecated := tiertest.BasicTier(t, tiertest.CurrentBasicTemplates, tiertest.WithAnnotation(tierutil.DeprecatedByAnnotationKey, "base"))
+		assert.True(t, pred.Update(event.UpdateEvent{ObjectOld: withoutControl, ObjectNew: deprecated}))
+	})
+
+	t.Run("annotation unchanged", func(t *testing.T) {
+		assert.False(t, pred.Update(event.UpdateEvent{ObjectOld: paused, ObjectNew: paused}))
+		assert.False(t, pred.Update(event.UpdateEvent{ObjectOld: withoutControl, ObjectNew: otherAnnotation}))
+	})
+
+	t.Run("missing objects", func(t *testing.T) {
+		assert.False(t, pred.Update(event.UpdateEvent{ObjectNew: paused}))
+		assert.False(t, pred.Update(event.UpdateEvent{ObjectOld: paused}))
+	})
+}
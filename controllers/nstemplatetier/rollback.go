@@ -0,0 +1,211 @@
+package nstemplatetier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	tierutil "github.com/codeready-toolchain/host-operator/controllers/nstemplatetier/util"
+	"github.com/codeready-toolchain/toolchain-common/pkg/condition"
+
+	"github.com/go-logr/logr"
+	errs "github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// RevisionsAnnotationKey is the key of the NSTemplateTier annotation holding the template refs of the revisions listed in the `status.updates`,
+	// indexed by their hash, so the tier can be rolled back to any of them
+	RevisionsAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "revisions"
+	// RollbackToAnnotationKey is the key of the NSTemplateTier annotation holding the hash of the revision (from the `status.updates`)
+	// to roll the tier back to. The annotation is removed once the rollback was applied.
+	RollbackToAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "rollback-to"
+	// RolledBackFromAnnotationKey is the key of the NSTemplateTier annotation holding the hash of the revision which was replaced by the last rollback.
+	// The tier generator does not re-apply this revision at startup, but it applies any other (ie, newer) revision.
+	RolledBackFromAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "rolled-back-from"
+
+	// RolledBack is the type of the NSTemplateTier condition set after a rollback was requested
+	RolledBack toolchainv1alpha1.ConditionType = "RolledBack"
+	// RolledBackReason is the reason of the RolledBack condition when the tier was rolled back
+	RolledBackReason = "RolledBack"
+	// RollbackUnknownRevisionReason is the reason of the RolledBack condition when the requested revision is unknown
+	RollbackUnknownRevisionReason = "UnknownRevision"
+)
+
+// tierRevision the template refs of a revision of an NSTemplateTier
+type tierRevision struct {
+	Namespaces       []string `json:"namespaces"`
+	ClusterResources string   `json:"clusterResources,omitempty"`
+}
+
+// getRevisions returns the revisions stored in the annotation of the given NSTemplateTier, indexed by their hash.
+// Returns an empty map if the annotation is missing or invalid.
+func getRevisions(logger logr.Logger, tier *toolchainv1alpha1.NSTemplateTier) map[string]tierRevision {
+	revisions := map[string]tierRevision{}
+	value, found := tier.Annotations[RevisionsAnnotationKey]
+	if !found || value == "" {
+		return revisions
+	}
+	if err := json.Unmarshal([]byte(value), &revisions); err != nil {
+		logger.Error(err, "invalid revisions, resetting them", "annotation", RevisionsAnnotationKey)
+		return map[string]tierRevision{}
+	}
+	return revisions
+}
+
+// recordRevision stores the template refs of the current spec of the given tier in the RevisionsAnnotationKey annotation,
+// and removes the revisions which are no longer listed in the `status.updates`
+func (r *Reconciler) recordRevision(logger logr.Logger, tier *toolchainv1alpha1.NSTemplateTier) error {
+	hash, err := tierutil.ComputeHashForNSTemplateTier(tier)
+	if err != nil {
+		return errs.Wrap(err, "unable to record the revision")
+	}
+	revisions := getRevisions(logger, tier)
+	revision := tierRevision{}
+	for _, ns := range tier.Spec.Namespaces {
+		revision.Namespaces = append(revision.Namespaces, ns.TemplateRef)
+	}
+	if tier.Spec.ClusterResources != nil {
+		revision.ClusterResources = tier.Spec.ClusterResources.TemplateRef
+	}
+	revisions[hash] = revision
+	history := make(map[string]bool, len(tier.Status.Updates))
+	for _, update := range tier.Status.Updates {
+		history[update.Hash] = true
+	}
+	for h := range revisions {
+		if !history[h] {
+			delete(revisions, h)
+		}
+	}
+	value, err := json.Marshal(revisions)
+	if err != nil {
+		return errs.Wrap(err, "unable to marshal the revisions")
+	}
+	if tier.Annotations[RevisionsAnnotationKey] == string(value) {
+		return nil
+	}
+	if tier.Annotations == nil {
+		tier.Annotations = map[string]string{}
+	}
+	tier.Annotations[RevisionsAnnotationKey] = string(value)
+	return errs.Wrap(r.Client.Update(context.TODO(), tier), "unable to record the revision")
+}
+
+// ensureRollback restores the template refs of the revision requested with the RollbackToAnnotationKey annotation.
+// The update of the tier spec then triggers the regular rollout of the TemplateUpdateRequests to bring the MasterUserRecords and Spaces back.
+// Returns `true` if the spec of the tier was rolled back
+func (r *Reconciler) ensureRollback(logger logr.Logger, tier *toolchainv1alpha1.NSTemplateTier) (bool, error) {
+	target, found := tier.Annotations[RollbackToAnnotationKey]
+	if !found {
+		return false, nil
+	}
+	currentHash, err := tierutil.ComputeHashForNSTemplateTier(tier)
+	if err != nil {
+		return false, errs.Wrap(err, "unable to roll back the tier")
+	}
+	revision, known := getRevisions(logger, tier)[target]
+	if !known {
+		logger.Info("unable to roll back the tier to an unknown revision", "revision", target)
+		if err := r.setRolledBackCondition(tier, corev1.ConditionFalse, RollbackUnknownRevisionReason,
+			fmt.Sprintf("unable to roll back to the revision '%s' as it is not in the tier history", target)); err != nil {
+			return false, err
+		}
+		delete(tier.Annotations, RollbackToAnnotationKey)
+		return false, errs.Wrap(r.Client.Update(context.TODO(), tier), "unable to remove the rollback annotation")
+	}
+	if err := r.setRolledBackCondition(tier, corev1.ConditionTrue, RolledBackReason,
+		fmt.Sprintf("rolled back from the revision '%s' to the revision '%s'", currentHash, target)); err != nil {
+		return false, err
+	}
+	logger.Info("rolling back the tier", "from", currentHash, "to", target)
+	delete(tier.Annotations, RollbackToAnnotationKey)
+	if currentHash == target {
+		// nothing to roll back
+		return false, errs.Wrap(r.Client.Update(context.TODO(), tier), "unable to remove the rollback annotation")
+	}
+	tier.Annotations[RolledBackFromAnnotationKey] = currentHash
+	tier.Spec.Namespaces = make([]toolchainv1alpha1.NSTemplateTierNamespace, len(revision.Namespaces))
+	for i, ref := range revision.Namespaces {
+		tier.Spec.Namespaces[i] = toolchainv1alpha1.NSTemplateTierNamespace{TemplateRef: ref}
+	}
+	tier.Spec.ClusterResources = nil
+	if revision.ClusterResources != "" {
+		tier.Spec.ClusterResources = &toolchainv1alpha1.NSTemplateTierClusterResources{TemplateRef: revision.ClusterResources}
+	}
+	return true, errs.Wrap(r.Client.Update(context.TODO(), tier), "unable to roll back the tier")
+}
+
+// setRolledBackCondition sets the RolledBack condition in the status of the tier
+func (r *Reconciler) setRolledBackCondition(tier *toolchainv1alpha1.NSTemplateTier, status corev1.ConditionStatus, reason, message string) error {
+	tier.Status.Conditions, _ = condition.AddOrUpdateStatusConditions(tier.Status.Conditions, toolchainv1alpha1.Condition{
+		Type:    RolledBack,
+		Status:  status,
+		Reason:  reason,
+		Message: message,
+	})
+	return errs.Wrap(r.Client.Status().Update(context.TODO(), tier), "unable to set the RolledBack condition")
+}
+
+// RetainedTierTemplateRefs returns the names of the TierTemplates used by the current spec of the given tier, by the revision of a pending
+// rollback, or by any of the given number of most recent revisions of the `status.updates` which the tier can be rolled back to.
+// These TierTemplates must be retained.
+func RetainedTierTemplateRefs(logger logr.Logger, tier *toolchainv1alpha1.NSTemplateTier, maxRevisions int) []string {
+	refs := map[string]bool{}
+	for _, ns := range tier.Spec.Namespaces {
+		refs[ns.TemplateRef] = true
+	}
+	if tier.Spec.ClusterResources != nil {
+		refs[tier.Spec.ClusterResources.TemplateRef] = true
+	}
+	revisions := getRevisions(logger, tier)
+	addRevision := func(hash string) bool {
+		revision, found := revisions[hash]
+		if !found {
+			return false
+		}
+		for _, ref := range revision.Namespaces {
+			refs[ref] = true
+		}
+		if revision.ClusterResources != "" {
+			refs[revision.ClusterResources] = true
+		}
+		return true
+	}
+	if target, found := tier.Annotations[RollbackToAnnotationKey]; found {
+		addRevision(target)
+	}
+	currentHash, _ := tierutil.ComputeHashForNSTemplateTier(tier)
+	retained := map[string]bool{}
+	for i := len(tier.Status.Updates) - 1; i >= 0 && len(retained) < maxRevisions; i-- {
+		hash := tier.Status.Updates[i].Hash
+		if hash == currentHash || retained[hash] {
+			continue
+		}
+		if addRevision(hash) {
+			retained[hash] = true
+		}
+	}
+	result := make([]string, 0, len(refs))
+	for ref := range refs {
+		result = append(result, ref)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// RevisionTierTemplateRefs returns the names of the TierTemplates of the revision of the given tier with the given hash,
+// or `nil` if the revision is unknown
+func RevisionTierTemplateRefs(logger logr.Logger, tier *toolchainv1alpha1.NSTemplateTier, hash string) []string {
+	revision, found := getRevisions(logger, tier)[hash]
+	if !found {
+		return nil
+	}
+	refs := append([]string{}, revision.Namespaces...)
+	if revision.ClusterResources != "" {
+		refs = append(refs, revision.ClusterResources)
+	}
+	return refs
+}
@@ -0,0 +1,262 @@
+package nstemplatetier_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/host-operator/controllers/nstemplatetier"
+	tiertest "github.com/codeready-toolchain/host-operator/test/nstemplatetier"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestRollback(t *testing.T) {
+
+	previousBasicTier := tiertest.BasicTier(t, tiertest.PreviousBasicTemplates)
+	previousHash := previousBasicTier.Labels["toolchain.dev.openshift.com/basic-tier-hash"]
+	now := metav1.Now()
+
+	t.Run("revisions are recorded", func(t *testing.T) {
+		// given
+		basicTier := tiertest.BasicTier(t, tiertest.CurrentBasicTemplates, tiertest.WithPreviousUpdates(
+			toolchainv1alpha1.NSTemplateTierHistory{
+				StartTime:      now,
+				CompletionTime: &now,
+				Hash:           previousHash,
+			}))
+		basicTier.Annotations = map[string]string{
+			nstemplatetier.RevisionsAnnotationKey: tiertest.RevisionsOf(t, previousBasicTier, tiertest.OtherTier()),
+		}
+		r, req, cl := prepareReconcile(t, basicTier.Name, basicTier)
+
+		// when
+		_, err := r.Reconcile(context.TODO(), req) // adds the new entry in `status.updates`
+		require.NoError(t, err)
+		_, err = r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		// the revision of the "other" tier is not in the history and is removed
+		tiertest.AssertThatNSTemplateTier(t, basicTier.Name, cl).
+			HasAnnotation(nstemplatetier.RevisionsAnnotationKey, tiertest.RevisionsOf(t, previousBasicTier, basicTier))
+	})
+
+	t.Run("rollback to a previous revision", func(t *testing.T) {
+		// given
+		basicTier := tiertest.BasicTier(t, tiertest.CurrentBasicTemplates, tiertest.WithPreviousUpdates(
+			toolchainv1alpha1.NSTemplateTierHistory{
+				StartTime:      now,
+				CompletionTime: &now,
+				Hash:           previousHash,
+			},
+			toolchainv1alpha1.NSTemplateTierHistory{
+				StartTime:      now,
+				CompletionTime: &now,
+				Hash:           tiertest.BasicTier(t, tiertest.CurrentBasicTemplates).Labels["toolchain.dev.openshift.com/basic-tier-hash"],
+			}))
+		currentHash := basicTier.Labels["toolchain.dev.openshift.com/basic-tier-hash"]
+		basicTier.Annotations = map[string]string{
+			nstemplatetier.RevisionsAnnotationKey:  tiertest.RevisionsOf(t, previousBasicTier, basicTier),
+			nstemplatetier.RollbackToAnnotationKey: previousHash,
+		}
+		r, req, cl := prepareReconcile(t, basicTier.Name, basicTier)
+
+		// when
+		res, err := r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, reconcile.Result{}, res)
+		tier := &toolchainv1alpha1.NSTemplateTier{}
+		require.NoError(t, cl.Get(context.TODO(), req.NamespacedName, tier))
+		assert.Equal(t, tiertest.PreviousBasicTemplates, tier.Spec)
+		assert.NotContains(t, tier.Annotations, nstemplatetier.RollbackToAnnotationKey)
+		assert.Equal(t, currentHash, tier.Annotations[nstemplatetier.RolledBackFromAnnotationKey])
+		tiertest.AssertThatNSTemplateTier(t, basicTier.Name, cl).
+			HasStatusUpdatesItems(2).
+			HasConditions(toolchainv1alpha1.Condition{
+				Type:    nstemplatetier.RolledBack,
+				Status:  corev1.ConditionTrue,
+				Reason:  nstemplatetier.RolledBackReason,
+				Message: fmt.Sprintf("rolled back from the revision '%s' to the revision '%s'", currentHash, previousHash),
+			})
+
+		t.Run("rollback is rolled out as a regular update", func(t *testing.T) {
+			// when
+			res, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, reconcile.Result{Requeue: true}, res)
+			tiertest.AssertThatNSTemplateTier(t, basicTier.Name, cl).
+				HasStatusUpdatesItems(3).
+				HasLatestUpdate(toolchainv1alpha1.NSTemplateTierHistory{
+					Hash: previousHash,
+				})
+		})
+	})
+
+	t.Run("rollback to the current revision", func(t *testing.T) {
+		// given
+		basicTier := tiertest.BasicTier(t, tiertest.CurrentBasicTemplates, tiertest.WithCurrentUpdateInProgress())
+		currentHash := basicTier.Labels["toolchain.dev.openshift.com/basic-tier-hash"]
+		basicTier.Annotations = map[string]string{
+			nstemplatetier.RevisionsAnnotationKey:  tiertest.RevisionsOf(t, basicTier),
+			nstemplatetier.RollbackToAnnotationKey: currentHash,
+		}
+		r, req, cl := prepareReconcile(t, basicTier.Name, basicTier)
+
+		// when
+		_, err := r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		tier := &toolchainv1alpha1.NSTemplateTier{}
+		require.NoError(t, cl.Get(context.TODO(), req.NamespacedName, tier))
+		assert.Equal(t, tiertest.CurrentBasicTemplates, tier.Spec)
+		assert.NotContains(t, tier.Annotations, nstemplatetier.RollbackToAnnotationKey)
+		assert.NotContains(t, tier.Annotations, nstemplatetier.RolledBackFromAnnotationKey)
+	})
+
+	t.Run("rollback to an unknown revision", func(t *testing.T) {
+		// given
+		basicTier := tiertest.BasicTier(t, tiertest.CurrentBasicTemplates, tiertest.WithCurrentUpdateInProgress(),
+			tiertest.WithAnnotation(nstemplatetier.RollbackToAnnotationKey, "unknown"))
+		r, req, cl := prepareReconcile(t, basicTier.Name, basicTier)
+
+		// when
+		_, err := r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		tier := &toolchainv1alpha1.NSTemplateTier{}
+		require.NoError(t, cl.Get(context.TODO(), req.NamespacedName, tier))
+		assert.Equal(t, tiertest.CurrentBasicTemplates, tier.Spec)
+		assert.NotContains(t, tier.Annotations, nstemplatetier.RollbackToAnnotationKey)
+		tiertest.AssertThatNSTemplateTier(t, basicTier.Name, cl).
+			HasConditions(toolchainv1alpha1.Condition{
+				Type:    nstemplatetier.RolledBack,
+				Status:  corev1.ConditionFalse,
+				Reason:  nstemplatetier.RollbackUnknownRevisionReason,
+				Message: "unable to roll back to the revision 'unknown' as it is not in the tier history",
+			})
+	})
+}
+
+func TestRetainedTierTemplateRefs(t *testing.T) {
+	// given
+	olderBasicTier := tiertest.BasicTier(t, toolchainv1alpha1.NSTemplateTierSpec{
+		Namespaces: []toolchainv1alpha1.NSTemplateTierNamespace{
+			{TemplateRef: "basic-dev-123older"},
+		},
+	})
+	previousBasicTier := tiertest.BasicTier(t, tiertest.PreviousBasicTemplates)
+	newBasicTier := func(options ...tiertest.TierOption) *toolchainv1alpha1.NSTemplateTier {
+		return tiertest.BasicTier(t, tiertest.CurrentBasicTemplates, append([]tiertest.TierOption{
+			tiertest.WithoutClusterResources(),
+			tiertest.WithAnnotation(nstemplatetier.RevisionsAnnotationKey, tiertest.RevisionsOf(t, olderBasicTier, previousBasicTier)),
+			tiertest.WithPreviousUpdates(
+				toolchainv1alpha1.NSTemplateTierHistory{Hash: olderBasicTier.Labels["toolchain.dev.openshift.com/basic-tier-hash"]},
+				toolchainv1alpha1.NSTemplateTierHistory{Hash: previousBasicTier.Labels["toolchain.dev.openshift.com/basic-tier-hash"]},
+			),
+		}, options...)...)
+	}
+
+	t.Run("retains the most recent revisions", func(t *testing.T) {
+		// when
+		refs := nstemplatetier.RetainedTierTemplateRefs(logf.Log, newBasicTier(), 1)
+
+		// then
+		assert.Equal(t, []string{
+			"basic-clusterresources-123456old",
+			"basic-code-123456new",
+			"basic-code-123456old",
+			"basic-dev-123456new",
+			"basic-dev-123456old",
+			"basic-stage-123456new",
+			"basic-stage-123456old",
+		}, refs)
+	})
+
+	t.Run("retains all revisions", func(t *testing.T) {
+		// when
+		refs := nstemplatetier.RetainedTierTemplateRefs(logf.Log, newBasicTier(), 5)
+
+		// then
+		assert.Equal(t, []string{
+			"basic-clusterresources-123456old",
+			"basic-code-123456new",
+			"basic-code-123456old",
+			"basic-dev-123456new",
+			"basic-dev-123456old",
+			"basic-dev-123older",
+			"basic-stage-123456new",
+			"basic-stage-123456old",
+		}, refs)
+	})
+
+	t.Run("retains only the current revision", func(t *testing.T) {
+		// when
+		refs := nstemplatetier.RetainedTierTemplateRefs(logf.Log, newBasicTier(), 0)
+
+		// then
+		assert.Equal(t, []string{
+			"basic-code-123456new",
+			"basic-dev-123456new",
+			"basic-stage-123456new",
+		}, refs)
+	})
+
+	t.Run("retains the revision of a pending rollback", func(t *testing.T) {
+		// given
+		tier := newBasicTier(tiertest.WithAnnotation(nstemplatetier.RollbackToAnnotationKey,
+			olderBasicTier.Labels["toolchain.dev.openshift.com/basic-tier-hash"]))
+
+		// when
+		refs := nstemplatetier.RetainedTierTemplateRefs(logf.Log, tier, 0)
+
+		// then
+		assert.Equal(t, []string{
+			"basic-code-123456new",
+			"basic-dev-123456new",
+			"basic-dev-123older",
+			"basic-stage-123456new",
+		}, refs)
+	})
+}
+
+func TestRevisionTierTemplateRefs(t *testing.T) {
+	// given
+	previousBasicTier := tiertest.BasicTier(t, tiertest.PreviousBasicTemplates)
+	previousHash := previousBasicTier.Labels["toolchain.dev.openshift.com/basic-tier-hash"]
+	basicTier := tiertest.BasicTier(t, tiertest.CurrentBasicTemplates,
+		tiertest.WithAnnotation(nstemplatetier.RevisionsAnnotationKey, tiertest.RevisionsOf(t, previousBasicTier)))
+
+	t.Run("known revision", func(t *testing.T) {
+		// when
+		refs := nstemplatetier.RevisionTierTemplateRefs(logf.Log, basicTier, previousHash)
+
+		// then
+		assert.Equal(t, []string{
+			"basic-code-123456old",
+			"basic-dev-123456old",
+			"basic-stage-123456old",
+			"basic-clusterresources-123456old",
+		}, refs)
+	})
+
+	t.Run("unknown revision", func(t *testing.T) {
+		// when
+		refs := nstemplatetier.RevisionTierTemplateRefs(logf.Log, basicTier, "unknown")
+
+		// then
+		assert.Nil(t, refs)
+	})
+}
@@ -0,0 +1,466 @@
+package nstemplatetier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	tierutil "github.com/codeready-toolchain/host-operator/controllers/nstemplatetier/util"
+	"github.com/codeready-toolchain/host-operator/controllers/toolchainconfig"
+	"github.com/codeready-toolchain/toolchain-common/pkg/condition"
+
+	"github.com/go-logr/logr"
+	errs "github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// CanarySelectorAnnotationKey is the key of the NSTemplateTier annotation holding the label selector of the MasterUserRecords and Spaces
+	// to update first (the canaries), before the rest of the rollout. Takes precedence over the canary percentage.
+	CanarySelectorAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "rollout-canary-selector"
+	// CanaryPercentageAnnotationKey is the key of the NSTemplateTier annotation holding the percentage of the outdated MasterUserRecords
+	// and Spaces to update first (the canaries), before the rest of the rollout. Overrides the value set in the ToolchainConfig.
+	CanaryPercentageAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "rollout-canary-percentage"
+	// RolloutStateAnnotationKey is the key of the NSTemplateTier annotation holding the state of the rollout of the current update
+	RolloutStateAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "rollout-state"
+	// RolloutControlAnnotationKey is the key of the NSTemplateTier annotation used to control the rollout of the current update:
+	// `pause` stops creating TemplateUpdateRequests until the annotation is removed or set to `resume`,
+	// `resume` resumes a paused or halted rollout, and `abort` stops the rollout for good, leaving the MasterUserRecords and Spaces
+	// which were already updated untouched. The `resume` and `abort` values are removed once applied.
+	RolloutControlAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "rollout-control"
+	// AbortedUpdatesAnnotationKey is the key of the NSTemplateTier annotation holding the outcome of the aborted rollouts listed in the
+	// `status.updates`, indexed by their hash, so an aborted update can be told apart from a completed one
+	AbortedUpdatesAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "rollout-aborted-updates"
+
+	// RolloutPause is the value of the RolloutControlAnnotationKey annotation to pause the rollout
+	RolloutPause = "pause"
+	// RolloutResume is the value of the RolloutControlAnnotationKey annotation to resume a paused or halted rollout
+	RolloutResume = "resume"
+	// RolloutAbort is the value of the RolloutControlAnnotationKey annotation to abort the rollout
+	RolloutAbort = "abort"
+
+	// RolloutHalted is the type of the NSTemplateTier condition set when the rollout of the current update was halted
+	RolloutHalted toolchainv1alpha1.ConditionType = "RolloutHalted"
+	// RolloutFailureThresholdExceededReason is the reason of the RolloutHalted condition when too many updates failed
+	RolloutFailureThresholdExceededReason = "FailureThresholdExceeded"
+	// RolloutInProgressReason is the reason of the RolloutHalted and RolloutAborted conditions when a new rollout started after
+	// a halted or aborted one
+	RolloutInProgressReason = "InProgress"
+	// RolloutPaused is the type of the NSTemplateTier condition set when the rollout of the current update was paused
+	RolloutPaused toolchainv1alpha1.ConditionType = "RolloutPaused"
+	// RolloutPausedReason is the reason of the RolloutPaused condition when the rollout is paused
+	RolloutPausedReason = "Paused"
+	// RolloutResumedReason is the reason of the RolloutPaused and RolloutHalted conditions when the rollout was resumed
+	RolloutResumedReason = "Resumed"
+	// RolloutAborted is the type of the NSTemplateTier condition set when the rollout of the current update was aborted
+	RolloutAborted toolchainv1alpha1.ConditionType = "RolloutAborted"
+	// RolloutAbortedReason is the reason of the RolloutAborted condition when the rollout was aborted
+	RolloutAbortedReason = "Aborted"
+
+	rolloutStageCanary  = "canary"
+	rolloutStageFull    = "full"
+	rolloutStageHalted  = "halted"
+	rolloutStageAborted = "aborted"
+)
+
+// rolloutState is the state of the rollout of the current update of an NSTemplateTier, stored in the RolloutStateAnnotationKey annotation
+type rolloutState struct {
+	// Hash is the hash of the tier update being rolled out
+	Hash string `json:"hash"`
+	// Stage is the current stage of the rollout: `canary`, `full`, `halted` or `aborted`
+	Stage string `json:"stage"`
+	// CanarySize is the number of MasterUserRecords and Spaces to update in the canary stage when a canary percentage is set
+	CanarySize int `json:"canarySize,omitempty"`
+	// Processed is the number of TemplateUpdateRequests which completed or failed during the rollout
+	Processed int `json:"processed,omitempty"`
+	// Successor is the name of the tier to which the MasterUserRecords and Spaces are migrated when the tier is deprecated
+	Successor string `json:"successor,omitempty"`
+}
+
+// abortedUpdate is the outcome of an aborted rollout, stored in the AbortedUpdatesAnnotationKey annotation
+type abortedUpdate struct {
+	// AbortTime is the time when the rollout was aborted
+	AbortTime metav1.Time `json:"abortTime"`
+	// Stage is the stage of the rollout when it was aborted
+	Stage string `json:"stage"`
+	// Processed is the number of TemplateUpdateRequests which completed or failed before the rollout was aborted
+	Processed int `json:"processed"`
+}
+
+// getAbortedUpdates returns the aborted updates stored in the annotation of the given NSTemplateTier, indexed by their hash.
+// Returns an empty map if the annotation is missing or invalid.
+func getAbortedUpdates(logger logr.Logger, tier *toolchainv1alpha1.NSTemplateTier) map[string]abortedUpdate {
+	aborted := map[string]abortedUpdate{}
+	value, found := tier.Annotations[AbortedUpdatesAnnotationKey]
+	if !found || value == "" {
+		return aborted
+	}
+	if err := json.Unmarshal([]byte(value), &aborted); err != nil {
+		logger.Error(err, "invalid aborted updates, resetting them", "annotation", AbortedUpdatesAnnotationKey)
+		return map[string]abortedUpdate{}
+	}
+	return aborted
+}
+
+// recordAbortedUpdate adds the outcome of the aborted rollout in the AbortedUpdatesAnnotationKey annotation of the given tier,
+// and removes the entries which are no longer listed in the `status.updates`. The annotation is saved along with the rollout state.
+func recordAbortedUpdate(logger logr.Logger, tier *toolchainv1alpha1.NSTemplateTier, state *rolloutState) error {
+	aborted := getAbortedUpdates(logger, tier)
+	aborted[state.Hash] = abortedUpdate{
+		AbortTime: metav1.Now(),
+		Stage:     state.Stage,
+		Processed: state.Processed,
+	}
+	history := make(map[string]bool, len(tier.Status.Updates))
+	for _, update := range tier.Status.Updates {
+		history[update.Hash] = true
+	}
+	for h := range aborted {
+		if !history[h] {
+			delete(aborted, h)
+		}
+	}
+	value, err := json.Marshal(aborted)
+	if err != nil {
+		return errs.Wrap(err, "unable to marshal the aborted updates")
+	}
+	if tier.Annotations == nil {
+		tier.Annotations = map[string]string{}
+	}
+	tier.Annotations[AbortedUpdatesAnnotationKey] = string(value)
+	return nil
+}
+
+// getRolloutState returns the rollout state stored in the annotation of the given NSTemplateTier.
+// Returns an empty state if the annotation is missing or invalid.
+func getRolloutState(logger logr.Logger, tier *toolchainv1alpha1.NSTemplateTier) *rolloutState {
+	state := &rolloutState{}
+	value, found := tier.Annotations[RolloutStateAnnotationKey]
+	if !found || value == "" {
+		return state
+	}
+	if err := json.Unmarshal([]byte(value), state); err != nil {
+		logger.Error(err, "invalid rollout state, resetting it", "annotation", RolloutStateAnnotationKey)
+		return &rolloutState{}
+	}
+	return state
+}
+
+// saveRolloutState stores the given rollout state in the annotation of the given NSTemplateTier, if it changed
+func (r *Reconciler) saveRolloutState(tier *toolchainv1alpha1.NSTemplateTier, state *rolloutState) error {
+	value, err := json.Marshal(state)
+	if err != nil {
+		return errs.Wrap(err, "unable to marshal the rollout state")
+	}
+	if tier.Annotations[RolloutStateAnnotationKey] == string(value) {
+		return nil
+	}
+	if tier.Annotations == nil {
+		tier.Annotations = map[string]string{}
+	}
+	tier.Annotations[RolloutStateAnnotationKey] = string(value)
+	return errs.Wrap(r.Client.Update(context.TODO(), tier), "unable to save the rollout state")
+}
+
+// ensureRolloutState returns the state of the rollout of the latest entry in the `status.updates`,
+// initializing it (with its canary stage, if any) when this update was not rolled out yet
+func (r *Reconciler) ensureRolloutState(logger logr.Logger, config toolchainconfig.ToolchainConfig, tier *toolchainv1alpha1.NSTemplateTier) (*rolloutState, error) {
+	if len(tier.Status.Updates) == 0 {
+		return nil, fmt.Errorf("no entry in the `Status.Updates`")
+	}
+	latest := tier.Status.Updates[len(tier.Status.Updates)-1]
+	state := getRolloutState(logger, tier)
+	if state.Hash == latest.Hash {
+		return state, nil
+	}
+	successor, _ := tierutil.SuccessorOf(tier)
+	state = &rolloutState{
+		Hash:      latest.Hash,
+		Stage:     rolloutStageFull,
+		Successor: successor,
+	}
+	if selector := tier.Annotations[CanarySelectorAnnotationKey]; selector != "" {
+		if _, err := labels.Parse(selector); err != nil {
+			return nil, errs.Wrapf(err, "invalid canary selector '%s'", selector)
+		}
+		state.Stage = rolloutStageCanary
+	} else if percentage := canaryPercentage(logger, config, tier); percentage > 0 {
+		total, err := r.countOutdated(tier)
+		if err != nil {
+			return nil, err
+		}
+		// round up, so there's at least one canary when some MasterUserRecords or Spaces are outdated
+		state.CanarySize = (total*percentage + 99) / 100
+		if state.CanarySize > 0 {
+			state.Stage = rolloutStageCanary
+		}
+	}
+	logger.Info("starting the rollout of the tier update", "stage", state.Stage, "canary_size", state.CanarySize)
+	if err := r.resetRolloutConditions(tier); err != nil {
+		return nil, err
+	}
+	return state, r.saveRolloutState(tier, state)
+}
+
+// resetRolloutConditions clears the conditions of a previously halted or aborted rollout
+func (r *Reconciler) resetRolloutConditions(tier *toolchainv1alpha1.NSTemplateTier) error {
+	reset := false
+	for _, conditionType := range []toolchainv1alpha1.ConditionType{RolloutHalted, RolloutAborted} {
+		if condition.IsTrue(tier.Status.Conditions, conditionType) {
+			tier.Status.Conditions, _ = condition.AddOrUpdateStatusConditions(tier.Status.Conditions, toolchainv1alpha1.Condition{
+				Type:   conditionType,
+				Status: corev1.ConditionFalse,
+				Reason: RolloutInProgressReason,
+			})
+			reset = true
+		}
+	}
+	if !reset {
+		return nil
+	}
+	return errs.Wrap(r.Client.Status().Update(context.TODO(), tier), "unable to reset the conditions of the previous rollout")
+}
+
+// isRolloutPaused returns `true` if the rollout of the given tier is paused
+func isRolloutPaused(tier *toolchainv1alpha1.NSTemplateTier) bool {
+	return tier.Annotations[RolloutControlAnnotationKey] == RolloutPause
+}
+
+// applyRolloutControl applies the pause, resume or abort requested with the RolloutControlAnnotationKey annotation
+// and reflects it in the conditions of the tier
+func (r *Reconciler) applyRolloutControl(logger logr.Logger, tier *toolchainv1alpha1.NSTemplateTier, state *rolloutState) error {
+	control := tier.Annotations[RolloutControlAnnotationKey]
+	switch control {
+	case RolloutPause:
+		if condition.IsTrue(tier.Status.Conditions, RolloutPaused) {
+			return nil
+		}
+		logger.Info("pausing the rollout of the tier update")
+		return r.setRolloutCondition(tier, toolchainv1alpha1.Condition{
+			Type:    RolloutPaused,
+			Status:  corev1.ConditionTrue,
+			Reason:  RolloutPausedReason,
+			Message: fmt.Sprintf("rollout paused after %d updates", state.Processed),
+		})
+
+	case RolloutResume:
+		logger.Info("resuming the rollout of the tier update", "stage", state.Stage)
+		if condition.IsTrue(tier.Status.Conditions, RolloutPaused) {
+			if err := r.setRolloutCondition(tier, toolchainv1alpha1.Condition{
+				Type:   RolloutPaused,
+				Status: corev1.ConditionFalse,
+				Reason: RolloutResumedReason,
+			}); err != nil {
+				return err
+			}
+		}
+		if state.Stage == rolloutStageHalted {
+			// the canary stage (if any) is considered as done
+			state.Stage = rolloutStageFull
+			if err := r.setRolloutCondition(tier, toolchainv1alpha1.Condition{
+				Type:   RolloutHalted,
+				Status: corev1.ConditionFalse,
+				Reason: RolloutResumedReason,
+			}); err != nil {
+				return err
+			}
+		}
+		return r.clearRolloutControl(tier, state)
+
+	case RolloutAbort:
+		switch {
+		case state.Stage == rolloutStageAborted:
+			logger.Info("the rollout of the tier update was already aborted")
+		case tier.Status.Updates[len(tier.Status.Updates)-1].CompletionTime != nil:
+			logger.Info("ignoring the abort since the rollout of the tier update is already complete")
+		default:
+			logger.Info("aborting the rollout of the tier update", "stage", state.Stage)
+			if err := r.deleteActiveTemplateUpdateRequests(logger, tier); err != nil {
+				return err
+			}
+			if err := r.setRolloutCondition(tier, toolchainv1alpha1.Condition{
+				Type:    RolloutAborted,
+				Status:  corev1.ConditionTrue,
+				Reason:  RolloutAbortedReason,
+				Message: fmt.Sprintf("rollout of the update '%s' aborted after %d updates", state.Hash, state.Processed),
+			}); err != nil {
+				return err
+			}
+			if err := recordAbortedUpdate(logger, tier, state); err != nil {
+				return err
+			}
+			state.Stage = rolloutStageAborted
+		}
+		return r.clearRolloutControl(tier, state)
+
+	case "":
+		// the `pause` annotation was removed
+		if condition.IsTrue(tier.Status.Conditions, RolloutPaused) {
+			logger.Info("resuming the rollout of the tier update", "stage", state.Stage)
+			return r.setRolloutCondition(tier, toolchainv1alpha1.Condition{
+				Type:   RolloutPaused,
+				Status: corev1.ConditionFalse,
+				Reason: RolloutResumedReason,
+			})
+		}
+		return nil
+
+	default:
+		logger.Info("ignoring invalid rollout control", "annotation", RolloutControlAnnotationKey, "value", control)
+		return nil
+	}
+}
+
+// setRolloutCondition sets the given condition in the status of the tier
+func (r *Reconciler) setRolloutCondition(tier *toolchainv1alpha1.NSTemplateTier, c toolchainv1alpha1.Condition) error {
+	tier.Status.Conditions, _ = condition.AddOrUpdateStatusConditions(tier.Status.Conditions, c)
+	return errs.Wrapf(r.Client.Status().Update(context.TODO(), tier), "unable to set the %s condition", c.Type)
+}
+
+// clearRolloutControl removes the RolloutControlAnnotationKey annotation once applied, and saves the rollout state
+func (r *Reconciler) clearRolloutControl(tier *toolchainv1alpha1.NSTemplateTier, state *rolloutState) error {
+	value, err := json.Marshal(state)
+	if err != nil {
+		return errs.Wrap(err, "unable to marshal the rollout state")
+	}
+	delete(tier.Annotations, RolloutControlAnnotationKey)
+	tier.Annotations[RolloutStateAnnotationKey] = string(value)
+	return errs.Wrap(r.Client.Update(context.TODO(), tier), "unable to save the rollout state")
+}
+
+// deleteActiveTemplateUpdateRequests deletes the TemplateUpdateRequests of the given tier which are not being deleted yet,
+// without counting them as processed
+func (r *Reconciler) deleteActiveTemplateUpdateRequests(logger logr.Logger, tier *toolchainv1alpha1.NSTemplateTier) error {
+	templateUpdateRequests := toolchainv1alpha1.TemplateUpdateRequestList{}
+	if err := r.Client.List(context.TODO(), &templateUpdateRequests, client.MatchingLabels{
+		toolchainv1alpha1.NSTemplateTierNameLabelKey: tier.Name,
+	}); err != nil {
+		return errs.Wrap(err, "unable to list the TemplateUpdateRequests to delete")
+	}
+	for i := range templateUpdateRequests.Items {
+		tur := &templateUpdateRequests.Items[i]
+		if tur.DeletionTimestamp != nil {
+			continue
+		}
+		logger.Info("deleting the TemplateUpdateRequest after the rollout was aborted", "name", tur.Name)
+		if err := r.Client.Delete(context.TODO(), tur); err != nil && !errors.IsNotFound(err) {
+			return errs.Wrapf(err, "unable to delete the TemplateUpdateRequest resource '%s'", tur.Name)
+		}
+	}
+	return nil
+}
+
+// canaryPercentage returns the canary percentage set in the NSTemplateTier annotation, or the one set in the ToolchainConfig
+func canaryPercentage(logger logr.Logger, config toolchainconfig.ToolchainConfig, tier *toolchainv1alpha1.NSTemplateTier) int {
+	percentage := config.Tiers().RolloutCanaryPercentage()
+	if value, found := tier.Annotations[CanaryPercentageAnnotationKey]; found {
+		p, err := strconv.Atoi(value)
+		if err != nil {
+			logger.Error(err, "invalid canary percentage, using the default value instead", "annotation", CanaryPercentageAnnotationKey, "default", percentage)
+		} else {
+			percentage = p
+		}
+	}
+	if percentage < 0 {
+		return 0
+	}
+	if percentage > 100 {
+		return 100
+	}
+	return percentage
+}
+
+// countOutdated returns the number of distinct MasterUserRecords and Spaces which are not up-to-date with the given tier
+func (r *Reconciler) countOutdated(tier *toolchainv1alpha1.NSTemplateTier) (int, error) {
+	matchOutdated, err := outdatedTierSelector(tier)
+	if err != nil {
+		return -1, errs.Wrap(err, "unable to count the MasterUserRecords and Spaces to update")
+	}
+	murs := toolchainv1alpha1.MasterUserRecordList{}
+	if err := r.Client.List(context.TODO(), &murs, client.InNamespace(tier.Namespace), matchOutdated); err != nil {
+		return -1, errs.Wrap(err, "unable to count the MasterUserRecords to update")
+	}
+	spaces := toolchainv1alpha1.SpaceList{}
+	if err := r.Client.List(context.TODO(), &spaces, client.InNamespace(tier.Namespace), matchOutdated); err != nil {
+		return -1, errs.Wrap(err, "unable to count the Spaces to update")
+	}
+	// a MasterUserRecord and a Space with the same name share the same TemplateUpdateRequest
+	names := make(map[string]bool, len(murs.Items)+len(spaces.Items))
+	for _, mur := range murs.Items {
+		names[mur.Name] = true
+	}
+	for _, space := range spaces.Items {
+		names[space.Name] = true
+	}
+	return len(names), nil
+}
+
+// canarySelector returns the given selector of the outdated MasterUserRecords and Spaces, restricted to the canaries
+func canarySelector(tier *toolchainv1alpha1.NSTemplateTier, matchOutdated client.MatchingLabelsSelector) (client.MatchingLabelsSelector, error) {
+	canaries, err := labels.Parse(tier.Annotations[CanarySelectorAnnotationKey])
+	if err != nil {
+		return client.MatchingLabelsSelector{}, errs.Wrapf(err, "invalid canary selector '%s'", tier.Annotations[CanarySelectorAnnotationKey])
+	}
+	requirements, _ := canaries.Requirements()
+	return client.MatchingLabelsSelector{
+		Selector: matchOutdated.Selector.Add(requirements...),
+	}, nil
+}
+
+// failureThresholdExceeded returns `true` if the ratio of failed updates is beyond the given maximum percentage
+func failureThresholdExceeded(failures, processed, maxFailurePercentage int) bool {
+	if failures > processed {
+		processed = failures
+	}
+	return maxFailurePercentage < 100 && processed > 0 && failures*100 > maxFailurePercentage*processed
+}
+
+// completeCanaryStage checks the failures of the canary stage, and either halts the rollout or moves on to the full rollout.
+// Returns `true` if the rollout was halted
+func (r *Reconciler) completeCanaryStage(logger logr.Logger, config toolchainconfig.ToolchainConfig, tier *toolchainv1alpha1.NSTemplateTier, state *rolloutState) (bool, error) {
+	latest := tier.Status.Updates[len(tier.Status.Updates)-1]
+	if failureThresholdExceeded(latest.Failures, state.Processed, config.Tiers().RolloutMaxFailurePercentage()) {
+		return true, r.haltRollout(logger, config, tier, state, "canary stage")
+	}
+	logger.Info("canary stage completed, rolling out the tier update to all MasterUserRecords and Spaces", "processed", state.Processed, "failures", latest.Failures)
+	state.Stage = rolloutStageFull
+	return false, r.saveRolloutState(tier, state)
+}
+
+// haltRollout stops the rollout of the current update and sets the RolloutHalted condition with the reason of the halt
+func (r *Reconciler) haltRollout(logger logr.Logger, config toolchainconfig.ToolchainConfig, tier *toolchainv1alpha1.NSTemplateTier, state *rolloutState, stage string) error {
+	latest := tier.Status.Updates[len(tier.Status.Updates)-1]
+	processed := state.Processed
+	if latest.Failures > processed {
+		processed = latest.Failures
+	}
+	message := fmt.Sprintf("rollout halted during the %s: %d of %d updates failed, which exceeds the maximum of %d%%",
+		stage, latest.Failures, processed, config.Tiers().RolloutMaxFailurePercentage())
+	logger.Info("halting the rollout of the tier update", "message", message)
+	tier.Status.Conditions, _ = condition.AddOrUpdateStatusConditions(tier.Status.Conditions, toolchainv1alpha1.Condition{
+		Type:    RolloutHalted,
+		Status:  corev1.ConditionTrue,
+		Reason:  RolloutFailureThresholdExceededReason,
+		Message: message,
+	})
+	if err := r.Client.Status().Update(context.TODO(), tier); err != nil {
+		return errs.Wrap(err, "unable to set the RolloutHalted condition")
+	}
+	state.Stage = rolloutStageHalted
+	return r.saveRolloutState(tier, state)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,639 @@
+package nstemplatetier_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/host-operator/controllers/nstemplatetier"
+	"github.com/codeready-toolchain/host-operator/controllers/toolchainconfig"
+	tiertest "github.com/codeready-toolchain/host-operator/test/nstemplatetier"
+	spacetest "github.com/codeready-toolchain/host-operator/test/space"
+	turtest "github.com/codeready-toolchain/host-operator/test/templateupdaterequest"
+	commonconfig "github.com/codeready-toolchain/toolchain-common/pkg/configuration"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestRollout(t *testing.T) {
+
+	previousBasicTier := tiertest.BasicTier(t, tiertest.PreviousBasicTemplates)
+
+	t.Run("canary percentage", func(t *testing.T) {
+
+		t.Run("rollout starts with the canaries", func(t *testing.T) {
+			// given
+			basicTier := tiertest.BasicTier(t, tiertest.CurrentBasicTemplates, tiertest.WithCurrentUpdateInProgress(),
+				tiertest.WithAnnotation(nstemplatetier.CanaryPercentageAnnotationKey, "15"))
+			initObjs := append(spacetest.NewSpaces(10, "user-%d", spacetest.WithTierNameAndHashLabelFor(previousBasicTier)), basicTier)
+			r, req, cl := prepareReconcile(t, basicTier.Name, initObjs...)
+
+			// when
+			_, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			// 15% of 10 Spaces, rounded up
+			tiertest.AssertThatNSTemplateTier(t, basicTier.Name, cl).
+				HasAnnotation(nstemplatetier.RolloutStateAnnotationKey, rolloutStateFor(t, basicTier, "canary", 2, 0)).
+				HasNoConditions()
+			turtest.AssertThatTemplateUpdateRequests(t, cl).TotalCount(1)
+
+			t.Run("second canary is created", func(t *testing.T) {
+				// when
+				_, err := r.Reconcile(context.TODO(), req)
+
+				// then
+				require.NoError(t, err)
+				turtest.AssertThatTemplateUpdateRequests(t, cl).TotalCount(2)
+
+				t.Run("no more TemplateUpdateRequest while the canaries are updated", func(t *testing.T) {
+					// when
+					_, err := r.Reconcile(context.TODO(), req)
+
+					// then
+					require.NoError(t, err)
+					turtest.AssertThatTemplateUpdateRequests(t, cl).TotalCount(2)
+					tiertest.AssertThatNSTemplateTier(t, basicTier.Name, cl).
+						HasAnnotation(nstemplatetier.RolloutStateAnnotationKey, rolloutStateFor(t, basicTier, "canary", 2, 0))
+				})
+			})
+		})
+
+		t.Run("canary percentage from the config", func(t *testing.T) {
+			// given
+			config := commonconfig.NewToolchainConfigObjWithReset(t)
+			config.Annotations = map[string]string{
+				toolchainconfig.HostConfigExtensionAnnotationKey: `{"tiers":{"rollout":{"canaryPercentage":50}}}`,
+			}
+			basicTier := tiertest.BasicTier(t, tiertest.CurrentBasicTemplates, tiertest.WithCurrentUpdateInProgress())
+			initObjs := append(spacetest.NewSpaces(10, "user-%d", spacetest.WithTierNameAndHashLabelFor(previousBasicTier)), basicTier, config)
+			r, req, cl := prepareReconcile(t, basicTier.Name, initObjs...)
+
+			// when
+			_, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			tiertest.AssertThatNSTemplateTier(t, basicTier.Name, cl).
+				HasAnnotation(nstemplatetier.RolloutStateAnnotationKey, rolloutStateFor(t, basicTier, "canary", 5, 0))
+		})
+
+		t.Run("canary stage succeeded", func(t *testing.T) {
+			// given
+			basicTier := tiertest.BasicTier(t, tiertest.CurrentBasicTemplates, tiertest.WithCurrentUpdateInProgress(),
+				tiertest.WithAnnotation(nstemplatetier.CanaryPercentageAnnotationKey, "20"))
+			basicTier.Annotations[nstemplatetier.RolloutStateAnnotationKey] = rolloutStateFor(t, basicTier, "canary", 2, 1)
+			basicTier.Status.Updates[0].Failures = 0
+			basicTier.Status.Updates[0].FailedAccounts = nil
+			initObjs := append(spacetest.NewSpaces(10, "user-%d", spacetest.WithTierNameAndHashLabelFor(previousBasicTier)), basicTier)
+			initObjs = append(initObjs, turtest.NewTemplateUpdateRequest("user-0", *basicTier, turtest.Complete("user-0")))
+			r, req, cl := prepareReconcile(t, basicTier.Name, initObjs...)
+
+			// when
+			_, err := r.Reconcile(context.TODO(), req) // deletes the completed TemplateUpdateRequest
+			require.NoError(t, err)
+			_, err = r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			tiertest.AssertThatNSTemplateTier(t, basicTier.Name, cl).
+				HasAnnotation(nstemplatetier.RolloutStateAnnotationKey, rolloutStateFor(t, basicTier, "full", 2, 2)).
+				HasNoConditions()
+			// the TemplateUpdateRequest being deleted + the one created for the full rollout
+			turtest.AssertThatTemplateUpdateRequests(t, cl).TotalCount(2)
+		})
+
+		t.Run("canary stage failed", func(t *testing.T) {
+			// given
+			basicTier := tiertest.BasicTier(t, tiertest.CurrentBasicTemplates, tiertest.WithCurrentUpdateInProgress(),
+				tiertest.WithAnnotation(nstemplatetier.CanaryPercentageAnnotationKey, "20"))
+			basicTier.Annotations[nstemplatetier.RolloutStateAnnotationKey] = rolloutStateFor(t, basicTier, "canary", 2, 2)
+			basicTier.Status.Updates[0].Failures = 2
+			basicTier.Status.Updates[0].FailedAccounts = []string{"user-0", "user-1"}
+			initObjs := append(spacetest.NewSpaces(10, "user-%d", spacetest.WithTierNameAndHashLabelFor(previousBasicTier)), basicTier)
+			r, req, cl := prepareReconcile(t, basicTier.Name, initObjs...)
+
+			// when
+			res, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			require.Equal(t, reconcile.Result{}, res)
+			tiertest.AssertThatNSTemplateTier(t, basicTier.Name, cl).
+				HasAnnotation(nstemplatetier.RolloutStateAnnotationKey, rolloutStateFor(t, basicTier, "halted", 2, 2)).
+				HasConditions(toolchainv1alpha1.Condition{
+					Type:    nstemplatetier.RolloutHalted,
+					Status:  corev1.ConditionTrue,
+					Reason:  nstemplatetier.RolloutFailureThresholdExceededReason,
+					Message: "rollout halted during the canary stage: 2 of 2 updates failed, which exceeds the maximum of 50%",
+				}).
+				HasLatestUpdate(toolchainv1alpha1.NSTemplateTierHistory{
+					Hash:           basicTier.Labels["toolchain.dev.openshift.com/basic-tier-hash"],
+					Failures:       2,
+					FailedAccounts: []string{"user-0", "user-1"},
+				}) // not complete
+			turtest.AssertThatTemplateUpdateRequests(t, cl).TotalCount(0)
+		})
+	})
+
+	t.Run("canary selector", func(t *testing.T) {
+
+		t.Run("only canaries are updated", func(t *testing.T) {
+			// given
+			basicTier := tiertest.BasicTier(t, tiertest.CurrentBasicTemplates, tiertest.WithCurrentUpdateInProgress(),
+				tiertest.WithAnnotation(nstemplatetier.CanarySelectorAnnotationKey, "canary=true"))
+			initObjs := append(spacetest.NewSpaces(5, "user-%d", spacetest.WithTierNameAndHashLabelFor(previousBasicTier)), basicTier)
+			initObjs = append(initObjs, spacetest.NewSpace("canary-0", spacetest.WithTierNameAndHashLabelFor(previousBasicTier), spacetest.WithLabel("canary", "true")))
+			r, req, cl := prepareReconcile(t, basicTier.Name, initObjs...)
+
+			// when
+			_, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			tiertest.AssertThatNSTemplateTier(t, basicTier.Name, cl).
+				HasAnnotation(nstemplatetier.RolloutStateAnnotationKey, rolloutStateFor(t, basicTier, "canary", 0, 0))
+			turtest.AssertThatTemplateUpdateRequests(t, cl).TotalCount(1)
+			turtest.AssertThatTemplateUpdateRequest(t, "canary-0", cl).Exists()
+
+			t.Run("no other TemplateUpdateRequest while the canary is updated", func(t *testing.T) {
+				// when
+				_, err := r.Reconcile(context.TODO(), req)
+
+				// then
+				require.NoError(t, err)
+				turtest.AssertThatTemplateUpdateRequests(t, cl).TotalCount(1)
+			})
+		})
+
+		t.Run("failed canaries are not retried and halt the rollout", func(t *testing.T) {
+			// given
+			basicTier := tiertest.BasicTier(t, tiertest.CurrentBasicTemplates, tiertest.WithCurrentUpdateInProgress(),
+				tiertest.WithAnnotation(nstemplatetier.CanarySelectorAnnotationKey, "canary=true"))
+			basicTier.Annotations[nstemplatetier.RolloutStateAnnotationKey] = rolloutStateFor(t, basicTier, "canary", 0, 1)
+			basicTier.Status.Updates[0].Failures = 1
+			basicTier.Status.Updates[0].FailedAccounts = []string{"canary-0"}
+			initObjs := append(spacetest.NewSpaces(5, "user-%d", spacetest.WithTierNameAndHashLabelFor(previousBasicTier)), basicTier)
+			initObjs = append(initObjs, spacetest.NewSpace("canary-0", spacetest.WithTierNameAndHashLabelFor(previousBasicTier), spacetest.WithLabel("canary", "true")))
+			r, req, cl := prepareReconcile(t, basicTier.Name, initObjs...)
+
+			// when
+			_, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			tiertest.AssertThatNSTemplateTier(t, basicTier.Name, cl).
+				HasAnnotation(nstemplatetier.RolloutStateAnnotationKey, rolloutStateFor(t, basicTier, "halted", 0, 1)).
+				HasConditions(toolchainv1alpha1.Condition{
+					Type:    nstemplatetier.RolloutHalted,
+					Status:  corev1.ConditionTrue,
+					Reason:  nstemplatetier.RolloutFailureThresholdExceededReason,
+					Message: "rollout halted during the canary stage: 1 of 1 updates failed, which exceeds the maximum of 50%",
+				})
+			turtest.AssertThatTemplateUpdateRequests(t, cl).TotalCount(0)
+		})
+
+		t.Run("invalid selector", func(t *testing.T) {
+			// given
+			basicTier := tiertest.BasicTier(t, tiertest.CurrentBasicTemplates, tiertest.WithCurrentUpdateInProgress(),
+				tiertest.WithAnnotation(nstemplatetier.CanarySelectorAnnotationKey, "canary in (true"))
+			r, req, _ := prepareReconcile(t, basicTier.Name, basicTier)
+
+			// when
+			_, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "unable to initialize the rollout of the NSTemplateTier update: invalid canary selector 'canary in (true'")
+		})
+	})
+
+	t.Run("full rollout", func(t *testing.T) {
+
+		t.Run("halted when failure threshold is exceeded", func(t *testing.T) {
+			// given
+			basicTier := tiertest.BasicTier(t, tiertest.CurrentBasicTemplates, tiertest.WithCurrentUpdateInProgress())
+			basicTier.Annotations = map[string]string{
+				nstemplatetier.RolloutStateAnnotationKey: rolloutStateFor(t, basicTier, "full", 0, 10),
+			}
+			basicTier.Status.Updates[0].Failures = 6
+			initObjs := append(spacetest.NewSpaces(20, "user-%d", spacetest.WithTierNameAndHashLabelFor(previousBasicTier)), basicTier)
+			r, req, cl := prepareReconcile(t, basicTier.Name, initObjs...)
+
+			// when
+			_, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			tiertest.AssertThatNSTemplateTier(t, basicTier.Name, cl).
+				HasAnnotation(nstemplatetier.RolloutStateAnnotationKey, rolloutStateFor(t, basicTier, "halted", 0, 10)).
+				HasConditions(toolchainv1alpha1.Condition{
+					Type:    nstemplatetier.RolloutHalted,
+					Status:  corev1.ConditionTrue,
+					Reason:  nstemplatetier.RolloutFailureThresholdExceededReason,
+					Message: "rollout halted during the rollout: 6 of 10 updates failed, which exceeds the maximum of 50%",
+				})
+			turtest.AssertThatTemplateUpdateRequests(t, cl).TotalCount(0)
+
+			t.Run("halted rollout does not resume", func(t *testing.T) {
+				// when
+				_, err := r.Reconcile(context.TODO(), req)
+
+				// then
+				require.NoError(t, err)
+				turtest.AssertThatTemplateUpdateRequests(t, cl).TotalCount(0)
+			})
+		})
+
+		t.Run("not halted before the minimum number of updates", func(t *testing.T) {
+			// given
+			basicTier := tiertest.BasicTier(t, tiertest.CurrentBasicTemplates, tiertest.WithCurrentUpdateInProgress())
+			basicTier.Annotations = map[string]string{
+				nstemplatetier.RolloutStateAnnotationKey: rolloutStateFor(t, basicTier, "full", 0, 5),
+			}
+			basicTier.Status.Updates[0].Failures = 5
+			initObjs := append(spacetest.NewSpaces(20, "user-%d", spacetest.WithTierNameAndHashLabelFor(previousBasicTier)), basicTier)
+			r, req, cl := prepareReconcile(t, basicTier.Name, initObjs...)
+
+			// when
+			_, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			tiertest.AssertThatNSTemplateTier(t, basicTier.Name, cl).HasNoConditions()
+			turtest.AssertThatTemplateUpdateRequests(t, cl).TotalCount(1)
+		})
+
+		t.Run("thresholds from the config", func(t *testing.T) {
+			// given
+			config := commonconfig.NewToolchainConfigObjWithReset(t)
+			config.Annotations = map[string]string{
+				toolchainconfig.HostConfigExtensionAnnotationKey: `{"tiers":{"rollout":{"maxFailurePercentage":20,"minUpdatesBeforeHalt":5}}}`,
+			}
+			basicTier := tiertest.BasicTier(t, tiertest.CurrentBasicTemplates, tiertest.WithCurrentUpdateInProgress())
+			basicTier.Annotations = map[string]string{
+				nstemplatetier.RolloutStateAnnotationKey: rolloutStateFor(t, basicTier, "full", 0, 5),
+			}
+			initObjs := append(spacetest.NewSpaces(20, "user-%d", spacetest.WithTierNameAndHashLabelFor(previousBasicTier)), basicTier, config)
+			r, req, cl := prepareReconcile(t, basicTier.Name, initObjs...)
+
+			// when
+			_, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			tiertest.AssertThatNSTemplateTier(t, basicTier.Name, cl).
+				HasConditions(toolchainv1alpha1.Condition{
+					Type:    nstemplatetier.RolloutHalted,
+					Status:  corev1.ConditionTrue,
+					Reason:  nstemplatetier.RolloutFailureThresholdExceededReason,
+					Message: "rollout halted during the rollout: 2 of 5 updates failed, which exceeds the maximum of 20%",
+				})
+		})
+
+		t.Run("processed updates are counted", func(t *testing.T) {
+			// given
+			basicTier := tiertest.BasicTier(t, tiertest.CurrentBasicTemplates, tiertest.WithCurrentUpdateInProgress())
+			basicTier.Annotations = map[string]string{
+				nstemplatetier.RolloutStateAnnotationKey: rolloutStateFor(t, basicTier, "full", 0, 3),
+			}
+			initObjs := []runtime.Object{basicTier, turtest.NewTemplateUpdateRequest("user-0", *basicTier, turtest.Failed("user-0"), turtest.Failed("user-0"), turtest.Failed("user-0"), turtest.Failed("user-0"), turtest.Failed("user-0"))}
+			r, req, cl := prepareReconcile(t, basicTier.Name, initObjs...)
+
+			// when
+			_, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			tiertest.AssertThatNSTemplateTier(t, basicTier.Name, cl).
+				HasAnnotation(nstemplatetier.RolloutStateAnnotationKey, rolloutStateFor(t, basicTier, "full", 0, 4)).
+				HasLatestUpdate(toolchainv1alpha1.NSTemplateTierHistory{
+					Hash:           basicTier.Labels["toolchain.dev.openshift.com/basic-tier-hash"],
+					Failures:       3,
+					FailedAccounts: []string{"failed1", "failed2", "user-0"},
+				})
+		})
+	})
+
+	t.Run("new rollout after a halted one", func(t *testing.T) {
+		// given
+		now := metav1.Now()
+		basicTier := tiertest.BasicTier(t, tiertest.CurrentBasicTemplates,
+			tiertest.WithAnnotation(nstemplatetier.RolloutStateAnnotationKey, `{"hash":"previous","stage":"halted","processed":10}`),
+			tiertest.WithCondition(toolchainv1alpha1.Condition{
+				Type:   nstemplatetier.RolloutHalted,
+				Status: corev1.ConditionTrue,
+				Reason: nstemplatetier.RolloutFailureThresholdExceededReason,
+			}),
+			tiertest.WithPreviousUpdates(toolchainv1alpha1.NSTemplateTierHistory{
+				StartTime:      now,
+				Hash:           "previous",
+				Failures:       8,
+				FailedAccounts: []string{"user-0"},
+			}))
+		initObjs := append(spacetest.NewSpaces(10, "user-%d", spacetest.WithTierNameAndHashLabelFor(previousBasicTier)), basicTier)
+		r, req, cl := prepareReconcile(t, basicTier.Name, initObjs...)
+
+		// when
+		_, err := r.Reconcile(context.TODO(), req) // adds the new entry in `status.updates`
+		require.NoError(t, err)
+		_, err = r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		tiertest.AssertThatNSTemplateTier(t, basicTier.Name, cl).
+			HasAnnotation(nstemplatetier.RolloutStateAnnotationKey, rolloutStateFor(t, basicTier, "full", 0, 0)).
+			HasConditions(toolchainv1alpha1.Condition{
+				Type:   nstemplatetier.RolloutHalted,
+				Status: corev1.ConditionFalse,
+				Reason: nstemplatetier.RolloutInProgressReason,
+			})
+		turtest.AssertThatTemplateUpdateRequests(t, cl).TotalCount(1)
+	})
+}
+
+func TestRolloutControl(t *testing.T) {
+
+	previousBasicTier := tiertest.BasicTier(t, tiertest.PreviousBasicTemplates)
+
+	t.Run("pause", func(t *testing.T) {
+		// given
+		basicTier := tiertest.BasicTier(t, tiertest.CurrentBasicTemplates, tiertest.WithCurrentUpdateInProgress(),
+			tiertest.WithAnnotation(nstemplatetier.RolloutControlAnnotationKey, nstemplatetier.RolloutPause))
+		initObjs := append(spacetest.NewSpaces(10, "user-%d", spacetest.WithTierNameAndHashLabelFor(previousBasicTier)), basicTier)
+		initObjs = append(initObjs, turtest.NewTemplateUpdateRequest("user-0", *basicTier, turtest.Complete("user-0")))
+		r, req, cl := prepareReconcile(t, basicTier.Name, initObjs...)
+
+		// when
+		_, err := r.Reconcile(context.TODO(), req) // deletes the completed TemplateUpdateRequest
+		require.NoError(t, err)
+		_, err = r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		tiertest.AssertThatNSTemplateTier(t, basicTier.Name, cl).
+			HasAnnotation(nstemplatetier.RolloutStateAnnotationKey, rolloutStateFor(t, basicTier, "full", 0, 1)).
+			HasConditions(toolchainv1alpha1.Condition{
+				Type:    nstemplatetier.RolloutPaused,
+				Status:  corev1.ConditionTrue,
+				Reason:  nstemplatetier.RolloutPausedReason,
+				Message: "rollout paused after 0 updates",
+			})
+		// only the TemplateUpdateRequest being deleted
+		turtest.AssertThatTemplateUpdateRequests(t, cl).TotalCount(1)
+
+		t.Run("resumed when the annotation is removed", func(t *testing.T) {
+			// given
+			tier := &toolchainv1alpha1.NSTemplateTier{}
+			require.NoError(t, cl.Get(context.TODO(), req.NamespacedName, tier))
+			delete(tier.Annotations, nstemplatetier.RolloutControlAnnotationKey)
+			require.NoError(t, cl.Update(context.TODO(), tier))
+
+			// when
+			_, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			tiertest.AssertThatNSTemplateTier(t, basicTier.Name, cl).
+				HasConditions(toolchainv1alpha1.Condition{
+					Type:   nstemplatetier.RolloutPaused,
+					Status: corev1.ConditionFalse,
+					Reason: nstemplatetier.RolloutResumedReason,
+				})
+			turtest.AssertThatTemplateUpdateRequests(t, cl).TotalCount(2)
+		})
+	})
+
+	t.Run("resume", func(t *testing.T) {
+
+		t.Run("paused rollout", func(t *testing.T) {
+			// given
+			basicTier := tiertest.BasicTier(t, tiertest.CurrentBasicTemplates, tiertest.WithCurrentUpdateInProgress(),
+				tiertest.WithAnnotation(nstemplatetier.RolloutControlAnnotationKey, nstemplatetier.RolloutResume),
+				tiertest.WithCondition(toolchainv1alpha1.Condition{
+					Type:   nstemplatetier.RolloutPaused,
+					Status: corev1.ConditionTrue,
+					Reason: nstemplatetier.RolloutPausedReason,
+				}))
+			initObjs := append(spacetest.NewSpaces(10, "user-%d", spacetest.WithTierNameAndHashLabelFor(previousBasicTier)), basicTier)
+			r, req, cl := prepareReconcile(t, basicTier.Name, initObjs...)
+
+			// when
+			_, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			tiertest.AssertThatNSTemplateTier(t, basicTier.Name, cl).
+				HasAnnotation(nstemplatetier.RolloutControlAnnotationKey, "").
+				HasConditions(toolchainv1alpha1.Condition{
+					Type:   nstemplatetier.RolloutPaused,
+					Status: corev1.ConditionFalse,
+					Reason: nstemplatetier.RolloutResumedReason,
+				})
+			turtest.AssertThatTemplateUpdateRequests(t, cl).TotalCount(1)
+		})
+
+		t.Run("halted rollout", func(t *testing.T) {
+			// given
+			basicTier := tiertest.BasicTier(t, tiertest.CurrentBasicTemplates, tiertest.WithCurrentUpdateInProgress(),
+				tiertest.WithCondition(toolchainv1alpha1.Condition{
+					Type:   nstemplatetier.RolloutHalted,
+					Status: corev1.ConditionTrue,
+					Reason: nstemplatetier.RolloutFailureThresholdExceededReason,
+				}))
+			basicTier.Annotations = map[string]string{
+				nstemplatetier.RolloutControlAnnotationKey: nstemplatetier.RolloutResume,
+				nstemplatetier.RolloutStateAnnotationKey:   rolloutStateFor(t, basicTier, "halted", 2, 2),
+			}
+			initObjs := append(spacetest.NewSpaces(10, "user-%d", spacetest.WithTierNameAndHashLabelFor(previousBasicTier)), basicTier)
+			r, req, cl := prepareReconcile(t, basicTier.Name, initObjs...)
+
+			// when
+			_, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			tiertest.AssertThatNSTemplateTier(t, basicTier.Name, cl).
+				HasAnnotation(nstemplatetier.RolloutControlAnnotationKey, "").
+				HasAnnotation(nstemplatetier.RolloutStateAnnotationKey, rolloutStateFor(t, basicTier, "full", 2, 2)).
+				HasConditions(toolchainv1alpha1.Condition{
+					Type:   nstemplatetier.RolloutHalted,
+					Status: corev1.ConditionFalse,
+					Reason: nstemplatetier.RolloutResumedReason,
+				})
+			turtest.AssertThatTemplateUpdateRequests(t, cl).TotalCount(1)
+		})
+	})
+
+	t.Run("abort", func(t *testing.T) {
+
+		t.Run("rollout in progress", func(t *testing.T) {
+			// given
+			basicTier := tiertest.BasicTier(t, tiertest.CurrentBasicTemplates, tiertest.WithCurrentUpdateInProgress())
+			basicTier.Annotations = map[string]string{
+				nstemplatetier.RolloutControlAnnotationKey: nstemplatetier.RolloutAbort,
+				nstemplatetier.RolloutStateAnnotationKey:   rolloutStateFor(t, basicTier, "full", 0, 3),
+			}
+			initObjs := append(spacetest.NewSpaces(10, "user-%d", spacetest.WithTierNameAndHashLabelFor(previousBasicTier)), basicTier)
+			initObjs = append(initObjs, turtest.NewTemplateUpdateRequests(2, "user-%d", *basicTier)...)
+			r, req, cl := prepareReconcile(t, basicTier.Name, initObjs...)
+
+			// when
+			_, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			now := metav1.Now()
+			tiertest.AssertThatNSTemplateTier(t, basicTier.Name, cl).
+				HasAnnotation(nstemplatetier.RolloutControlAnnotationKey, "").
+				HasAnnotation(nstemplatetier.RolloutStateAnnotationKey, rolloutStateFor(t, basicTier, "aborted", 0, 3)).
+				HasConditions(toolchainv1alpha1.Condition{
+					Type:    nstemplatetier.RolloutAborted,
+					Status:  corev1.ConditionTrue,
+					Reason:  nstemplatetier.RolloutAbortedReason,
+					Message: fmt.Sprintf("rollout of the update '%s' aborted after 3 updates", basicTier.Labels["toolchain.dev.openshift.com/basic-tier-hash"]),
+				}).
+				HasLatestUpdate(toolchainv1alpha1.NSTemplateTierHistory{
+					Hash:           basicTier.Labels["toolchain.dev.openshift.com/basic-tier-hash"],
+					Failures:       2,
+					FailedAccounts: []string{"failed1", "failed2"},
+					CompletionTime: &now,
+				})
+			// the active TemplateUpdateRequests are being deleted and no other one was created
+			turtest.AssertThatTemplateUpdateRequest(t, "user-0", cl).HasDeletionTimestamp()
+			turtest.AssertThatTemplateUpdateRequest(t, "user-1", cl).HasDeletionTimestamp()
+			turtest.AssertThatTemplateUpdateRequests(t, cl).TotalCount(2)
+			assertAbortedUpdate(t, cl, req, basicTier.Labels["toolchain.dev.openshift.com/basic-tier-hash"], "full", 3)
+
+			t.Run("abort applied only once", func(t *testing.T) {
+				// given
+				tier := &toolchainv1alpha1.NSTemplateTier{}
+				require.NoError(t, cl.Get(context.TODO(), req.NamespacedName, tier))
+				completionTime := tier.Status.Updates[len(tier.Status.Updates)-1].CompletionTime
+				require.NotNil(t, completionTime)
+				tier.Annotations[nstemplatetier.RolloutControlAnnotationKey] = nstemplatetier.RolloutAbort
+				require.NoError(t, cl.Update(context.TODO(), tier))
+				updated := false
+				cl.MockStatusUpdate = func(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+					updated = true
+					return cl.Client.Status().Update(ctx, obj, opts...)
+				}
+				defer func() {
+					cl.MockStatusUpdate = nil
+				}()
+
+				// when
+				_, err := r.Reconcile(context.TODO(), req)
+				require.NoError(t, err)
+				_, err = r.Reconcile(context.TODO(), req)
+
+				// then
+				require.NoError(t, err)
+				assert.False(t, updated, "the status of the aborted tier should not be updated again")
+				tier = &toolchainv1alpha1.NSTemplateTier{}
+				require.NoError(t, cl.Get(context.TODO(), req.NamespacedName, tier))
+				assert.Equal(t, completionTime, tier.Status.Updates[len(tier.Status.Updates)-1].CompletionTime)
+				assert.NotContains(t, tier.Annotations, nstemplatetier.RolloutControlAnnotationKey)
+				assertAbortedUpdate(t, cl, req, basicTier.Labels["toolchain.dev.openshift.com/basic-tier-hash"], "full", 3)
+			})
+
+			t.Run("new rollout after the abort", func(t *testing.T) {
+				// given
+				tier := &toolchainv1alpha1.NSTemplateTier{}
+				require.NoError(t, cl.Get(context.TODO(), req.NamespacedName, tier))
+				tier.Spec = tiertest.BasicTier(t, tiertest.CurrentBasicTemplates, tiertest.WithoutClusterResources()).Spec
+				require.NoError(t, cl.Update(context.TODO(), tier))
+
+				// when
+				_, err := r.Reconcile(context.TODO(), req) // adds the new entry in `status.updates`
+				require.NoError(t, err)
+				_, err = r.Reconcile(context.TODO(), req)
+
+				// then
+				require.NoError(t, err)
+				tiertest.AssertThatNSTemplateTier(t, basicTier.Name, cl).
+					HasStatusUpdatesItems(2).
+					HasConditions(toolchainv1alpha1.Condition{
+						Type:   nstemplatetier.RolloutAborted,
+						Status: corev1.ConditionFalse,
+						Reason: nstemplatetier.RolloutInProgressReason,
+					})
+				// the outcome of the aborted rollout is retained as long as its entry is listed in the `status.updates`
+				assertAbortedUpdate(t, cl, req, basicTier.Labels["toolchain.dev.openshift.com/basic-tier-hash"], "full", 3)
+			})
+		})
+
+		t.Run("ignored when the rollout is complete", func(t *testing.T) {
+			// given
+			now := metav1.Now()
+			basicTier := tiertest.BasicTier(t, tiertest.CurrentBasicTemplates, tiertest.WithCurrentUpdateInProgress())
+			basicTier.Status.Updates[0].CompletionTime = &now
+			basicTier.Annotations = map[string]string{
+				nstemplatetier.RolloutControlAnnotationKey: nstemplatetier.RolloutAbort,
+				nstemplatetier.RolloutStateAnnotationKey:   rolloutStateFor(t, basicTier, "full", 0, 3),
+			}
+			r, req, cl := prepareReconcile(t, basicTier.Name, basicTier)
+
+			// when
+			_, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			tiertest.AssertThatNSTemplateTier(t, basicTier.Name, cl).
+				HasAnnotation(nstemplatetier.RolloutControlAnnotationKey, "").
+				HasAnnotation(nstemplatetier.RolloutStateAnnotationKey, rolloutStateFor(t, basicTier, "full", 0, 3)).
+				HasNoConditions()
+		})
+	})
+}
+
+// assertAbortedUpdate verifies that the outcome of the aborted rollout of the update with the given hash is recorded in the annotation of the tier
+func assertAbortedUpdate(t *testing.T, cl client.Client, req reconcile.Request, hash, stage string, processed int) {
+	tier := &toolchainv1alpha1.NSTemplateTier{}
+	require.NoError(t, cl.Get(context.TODO(), req.NamespacedName, tier))
+	aborted := map[string]struct {
+		AbortTime metav1.Time `json:"abortTime"`
+		Stage     string      `json:"stage"`
+		Processed int         `json:"processed"`
+	}{}
+	require.NoError(t, json.Unmarshal([]byte(tier.Annotations[nstemplatetier.AbortedUpdatesAnnotationKey]), &aborted))
+	require.Contains(t, aborted, hash)
+	assert.False(t, aborted[hash].AbortTime.Time.IsZero())
+	assert.Equal(t, stage, aborted[hash].Stage)
+	assert.Equal(t, processed, aborted[hash].Processed)
+}
+
+// rolloutStateFor returns the expected value of the rollout state annotation of the current update of the given tier
+func rolloutStateFor(t *testing.T, tier *toolchainv1alpha1.NSTemplateTier, stage string, canarySize, processed int) string {
+	return migrationStateFor(t, tier, "", stage, canarySize, processed)
+}
+
+// migrationStateFor returns the expected value of the rollout state annotation of the current update of the given tier,
+// when its MasterUserRecords and Spaces are migrated to the given successor tier
+func migrationStateFor(t *testing.T, tier *toolchainv1alpha1.NSTemplateTier, successor, stage string, canarySize, processed int) string {
+	state := struct {
+		Hash       string `json:"hash"`
+		Stage      string `json:"stage"`
+		CanarySize int    `json:"canarySize,omitempty"`
+		Processed  int    `json:"processed,omitempty"`
+		Successor  string `json:"successor,omitempty"`
+	}{
+		Hash:       tier.Labels["toolchain.dev.openshift.com/basic-tier-hash"],
+		Stage:      stage,
+		CanarySize: canarySize,
+		Processed:  processed,
+		Successor:  successor,
+	}
+	value, err := json.Marshal(state)
+	require.NoError(t, err)
+	return string(value)
+}
@@ -0,0 +1,61 @@
+package util
+
+import (
+	"context"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// DeprecatedByAnnotationKey is the key of the NSTemplateTier annotation holding the name of the tier which replaces it.
+	// A deprecated tier is no longer assigned to new users, and its MasterUserRecords and Spaces are migrated to the successor tier.
+	DeprecatedByAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "deprecated-by"
+	// DeprecationNotificationAnnotationKey is the key of the NSTemplateTier annotation which, when set to `true`, enables the notification
+	// of the users whose MasterUserRecord is migrated from the deprecated tier to its successor
+	DeprecationNotificationAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "deprecation-notification"
+	// MigrateFromAnnotationKey is the key of the TemplateUpdateRequest annotation holding the name of the deprecated tier
+	// to migrate the MasterUserRecord or Space from. The target tier is the one set in the TemplateUpdateRequest spec.
+	MigrateFromAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "migrate-from"
+
+	// maxDeprecationChainLength the maximum number of successors followed when looking for a tier which is not deprecated
+	maxDeprecationChainLength = 10
+)
+
+// TemplateTierDeprecatedLabelKey returns the key of the label set on the MasterUserRecords and Spaces which use the given deprecated tier.
+// The value of the label is the name of the successor tier.
+func TemplateTierDeprecatedLabelKey(tierName string) string {
+	return toolchainv1alpha1.LabelKeyPrefix + tierName + "-tier-deprecated"
+}
+
+// SuccessorOf returns the name of the tier which replaces the given tier, and `true` if the given tier is deprecated
+func SuccessorOf(tier *toolchainv1alpha1.NSTemplateTier) (string, bool) {
+	successor := tier.Annotations[DeprecatedByAnnotationKey]
+	return successor, successor != ""
+}
+
+// GetAssignableTier returns the NSTemplateTier with the given name or, if this tier is deprecated, its successor (following
+// the successors until a tier which is not deprecated is found), so that a deprecated tier is never assigned to new users.
+// The last tier of the chain is returned if the successors loop or exceed the maximum length of the chain.
+func GetAssignableTier(cl client.Client, namespace, tierName string) (*toolchainv1alpha1.NSTemplateTier, error) {
+	tier := &toolchainv1alpha1.NSTemplateTier{}
+	if err := cl.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: tierName}, tier); err != nil {
+		return tier, err
+	}
+	visited := map[string]bool{tier.Name: true}
+	for i := 0; i < maxDeprecationChainLength; i++ {
+		successorName, deprecated := SuccessorOf(tier)
+		if !deprecated || visited[successorName] {
+			return tier, nil
+		}
+		successor := &toolchainv1alpha1.NSTemplateTier{}
+		if err := cl.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: successorName}, successor); err != nil {
+			return tier, err
+		}
+		visited[successorName] = true
+		tier = successor
+	}
+	return tier, nil
+}
@@ -2,6 +2,7 @@ package util
 
 import (
 	"crypto/md5" //nolint:gosec
+	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"sort"
@@ -14,8 +15,32 @@ func TemplateTierHashLabelKey(tierName string) string {
 	return toolchainv1alpha1.LabelKeyPrefix + tierName + "-tier-hash"
 }
 
-// ComputeHashForNSTemplateTier computes the hash of the `.spec.namespaces[].templateRef` + `.spec.clusteResource.TemplateRef`
+// HashVersion the version of the hash computed by `ComputeHashForNSTemplateTier`, used as a prefix of the hash
+// so it can be told apart from the legacy hash (which has no prefix)
+const HashVersion = "v2"
+
+// ComputeHashForNSTemplateTier computes the versioned hash of the tier content which affects the NSTemplateSets:
+// the `.spec.namespaces[].templateRef` + `.spec.clusteResource.TemplateRef` + `.spec.spaceRoles[].templateRef`
+// + `.spec.deactivationTimeoutDays`
 func ComputeHashForNSTemplateTier(tier *toolchainv1alpha1.NSTemplateTier) (string, error) {
+	content := tierContent{
+		Refs:                    templateRefsOf(tier),
+		SpaceRoles:              []string{},
+		DeactivationTimeoutDays: tier.Spec.DeactivationTimeoutDays,
+	}
+	for role, spaceRole := range tier.Spec.SpaceRoles {
+		content.SpaceRoles = append(content.SpaceRoles, role+"="+spaceRole.TemplateRef)
+	}
+	return computeVersionedHash(content)
+}
+
+// ComputeLegacyHashForNSTemplateTier computes the hash of the `.spec.namespaces[].templateRef` + `.spec.clusteResource.TemplateRef`,
+// as it was set in the tier hash labels before the hash was versioned
+func ComputeLegacyHashForNSTemplateTier(tier *toolchainv1alpha1.NSTemplateTier) (string, error) {
+	return computeHash(templateRefsOf(tier))
+}
+
+func templateRefsOf(tier *toolchainv1alpha1.NSTemplateTier) []string {
 	refs := []string{}
 	for _, ns := range tier.Spec.Namespaces {
 		refs = append(refs, ns.TemplateRef)
@@ -23,38 +48,85 @@ func ComputeHashForNSTemplateTier(tier *toolchainv1alpha1.NSTemplateTier) (strin
 	if tier.Spec.ClusterResources != nil {
 		refs = append(refs, tier.Spec.ClusterResources.TemplateRef)
 	}
-	return computeHash(refs)
+	return refs
 }
 
-// ComputeHashForNSTemplateSetSpec computes the hash of the `.spec.namespaces[].templateRef` + `.spec.clusteResource.TemplateRef`
+// ComputeHashForNSTemplateSetSpec computes the versioned hash of the `.spec.namespaces[].templateRef` + `.spec.clusteResource.TemplateRef`.
+// The NSTemplateSet has no space roles nor deactivation timeout, hence the hash only equals the hash of a tier which has none either:
+// use `ComputeHashForNSTemplateSet` to compute the value of the tier hash label of a MasterUserRecord.
 func ComputeHashForNSTemplateSetSpec(s toolchainv1alpha1.NSTemplateSetSpec) (string, error) {
-	refs := []string{}
-	for _, ns := range s.Namespaces {
-		refs = append(refs, ns.TemplateRef)
-	}
-	if s.ClusterResources != nil && s.ClusterResources.TemplateRef != "" { // ignore when ClusterResources only contains a custom template
-		refs = append(refs, s.ClusterResources.TemplateRef)
+	return computeVersionedHash(tierContent{
+		Refs:       templateRefsOfSet(s),
+		SpaceRoles: []string{},
+	})
+}
+
+// ComputeHashForNSTemplateSet computes the value of the tier hash label for a MasterUserRecord whose NSTemplateSet has the given spec:
+// the hash of the given tier if the NSTemplateSet has the same templateRefs as the tier, otherwise the hash of the NSTemplateSet
+// templateRefs, which differs from the hash of the tier so the MasterUserRecord will be selected during the next update of the tier.
+// The MasterUserRecords which are still labelled with a legacy (MD5) hash are either outdated too, or have their label migrated
+// to the hash of their tier by the NSTemplateTier controller (see `ComputeLegacyHashForNSTemplateTier`)
+func ComputeHashForNSTemplateSet(tmplTier *toolchainv1alpha1.NSTemplateTier, nsTmplSetSpec toolchainv1alpha1.NSTemplateSetSpec) (string, error) {
+	if tmplTier != nil && tmplTier.Name == nsTmplSetSpec.TierName && TierHashMatches(tmplTier, nsTmplSetSpec) {
+		return ComputeHashForNSTemplateTier(tmplTier)
 	}
-	return computeHash(refs)
+	return ComputeHashForNSTemplateSetSpec(nsTmplSetSpec)
 }
 
+// TierHashMatches returns `true` if the given NSTemplateSet spec has the same templateRefs as the given tier
 func TierHashMatches(tmplTier *toolchainv1alpha1.NSTemplateTier, nsTmplSetSpec toolchainv1alpha1.NSTemplateSetSpec) bool {
-	tierHash, err := ComputeHashForNSTemplateTier(tmplTier)
-	if err != nil {
+	return sameRefs(templateRefsOf(tmplTier), templateRefsOfSet(nsTmplSetSpec))
+}
+
+// sameRefs returns `true` if both given lists contain the same templateRefs, regardless of their order
+func sameRefs(expected, actual []string) bool {
+	if len(expected) != len(actual) {
 		return false
 	}
+	sort.Strings(expected)
+	sort.Strings(actual)
+	for i := range expected {
+		if expected[i] != actual[i] {
+			return false
+		}
+	}
+	return true
+}
 
-	nsTmplSetSpecHash, err := ComputeHashForNSTemplateSetSpec(nsTmplSetSpec)
-	if err != nil {
-		return false
+func templateRefsOfSet(s toolchainv1alpha1.NSTemplateSetSpec) []string {
+	refs := []string{}
+	for _, ns := range s.Namespaces {
+		refs = append(refs, ns.TemplateRef)
 	}
-	return tierHash == nsTmplSetSpecHash
+	if s.ClusterResources != nil && s.ClusterResources.TemplateRef != "" { // ignore when ClusterResources only contains a custom template
+		refs = append(refs, s.ClusterResources.TemplateRef)
+	}
+	return refs
 }
 
 type templateRefs struct {
 	Refs []string `json:"refs"`
 }
 
+type tierContent struct {
+	Refs                    []string `json:"refs"`
+	SpaceRoles              []string `json:"spaceRoles"`
+	DeactivationTimeoutDays int      `json:"deactivationTimeoutDays"`
+}
+
+func computeVersionedHash(content tierContent) (string, error) {
+	// sort the refs and roles to make sure we have a predictive hash!
+	sort.Strings(content.Refs)
+	sort.Strings(content.SpaceRoles)
+	m, err := json.Marshal(content)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(m)
+	// truncate the hash so the value fits in a label, along with its version
+	return HashVersion + "-" + hex.EncodeToString(sum[:16]), nil
+}
+
 func computeHash(refs []string) (string, error) {
 	// sort the refs to make sure we have a predictive hash!
 	sort.Strings(refs)
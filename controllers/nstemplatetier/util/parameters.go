@@ -0,0 +1,183 @@
+package util
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+
+	errs "github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// TemplateParametersAnnotationKey is the key of the MasterUserRecord and Space annotation holding the template parameters
+// which override the values of the tier for this user (eg: `{"MEMORY_LIMIT":"10Gi"}`). The overrides are applied in copies of
+// the TierTemplates of the tier, which are referenced by the NSTemplateSet of the user instead of the TierTemplates of the tier.
+// The annotation is also set on these copies.
+const TemplateParametersAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "template-parameters"
+
+// reservedParameters the template parameters which are set by the member operator and thus cannot be overridden
+var reservedParameters = map[string]bool{
+	"USERNAME":                  true,
+	"MEMBER_OPERATOR_NAMESPACE": true,
+}
+
+// GetParameterOverrides returns the template parameter overrides set in the annotation of the given object,
+// or `nil` if there is no such annotation
+func GetParameterOverrides(obj metav1.Object) (map[string]string, error) {
+	value, found := obj.GetAnnotations()[TemplateParametersAnnotationKey]
+	if !found || strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	overrides := map[string]string{}
+	if err := json.Unmarshal([]byte(value), &overrides); err != nil {
+		return nil, errs.Wrapf(err, "invalid value of the '%s' annotation", TemplateParametersAnnotationKey)
+	}
+	if len(overrides) == 0 {
+		return nil, nil
+	}
+	return overrides, nil
+}
+
+// OverriddenTemplateRef returns the name of the TierTemplate generated from the TierTemplate with the given name,
+// with the given template parameter overrides. The name ends with the hash of the overrides, so all users with the same
+// overrides share the same TierTemplate, and a change of the overrides results in a new TierTemplate.
+func OverriddenTemplateRef(templateRef string, overrides map[string]string) string {
+	if len(overrides) == 0 {
+		return templateRef
+	}
+	return templateRef + "-" + computeOverridesHash(overrides)
+}
+
+func computeOverridesHash(overrides map[string]string) string {
+	// Ignore the error, since a map of strings can always be marshalled (with its keys sorted)
+	m, _ := json.Marshal(overrides)
+	sum := sha256.Sum256(m)
+	return hex.EncodeToString(sum[:4])
+}
+
+// ApplyParameterOverrides returns a copy of the given NSTemplateSet spec which refers to the TierTemplates with the given template
+// parameter overrides, and creates these TierTemplates (in the given namespace) if they do not exist yet.
+// Returns an error if an override is not declared by the templates of the tier, or if it is a parameter set by the member operator.
+func ApplyParameterOverrides(cl client.Client, namespace string, spec toolchainv1alpha1.NSTemplateSetSpec, overrides map[string]string) (toolchainv1alpha1.NSTemplateSetSpec, error) {
+	result := *spec.DeepCopy()
+	if len(overrides) == 0 {
+		return result, nil
+	}
+	refs := templateRefsOfSet(spec)
+	tierTemplates, err := validatedTierTemplates(cl, namespace, spec, overrides)
+	if err != nil {
+		return result, err
+	}
+	for _, ref := range refs {
+		if err := ensureOverriddenTierTemplate(cl, tierTemplates[ref], overrides); err != nil {
+			return result, err
+		}
+	}
+	for i, ns := range result.Namespaces {
+		result.Namespaces[i].TemplateRef = OverriddenTemplateRef(ns.TemplateRef, overrides)
+	}
+	if result.ClusterResources != nil && result.ClusterResources.TemplateRef != "" {
+		result.ClusterResources.TemplateRef = OverriddenTemplateRef(result.ClusterResources.TemplateRef, overrides)
+	}
+	return result, nil
+}
+
+// ValidateParameterOverrides checks that the given template parameter overrides are declared by the templates of the given NSTemplateSet spec
+// (looked-up in the TierTemplates of the given namespace), and that none of them is set by the member operator
+func ValidateParameterOverrides(cl client.Client, namespace string, spec toolchainv1alpha1.NSTemplateSetSpec, overrides map[string]string) error {
+	if len(overrides) == 0 {
+		return nil
+	}
+	_, err := validatedTierTemplates(cl, namespace, spec, overrides)
+	return err
+}
+
+// validatedTierTemplates returns the TierTemplates of the given NSTemplateSet spec indexed by their name, after checking
+// that the given template parameter overrides are valid for these TierTemplates
+func validatedTierTemplates(cl client.Client, namespace string, spec toolchainv1alpha1.NSTemplateSetSpec, overrides map[string]string) (map[string]*toolchainv1alpha1.TierTemplate, error) {
+	refs := templateRefsOfSet(spec)
+	tierTemplates := make(map[string]*toolchainv1alpha1.TierTemplate, len(refs))
+	declared := map[string]bool{}
+	for _, ref := range refs {
+		tierTmpl := &toolchainv1alpha1.TierTemplate{}
+		if err := cl.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: ref}, tierTmpl); err != nil {
+			return nil, errs.Wrapf(err, "unable to get the TierTemplate '%s'", ref)
+		}
+		tierTemplates[ref] = tierTmpl
+		for _, param := range tierTmpl.Spec.Template.Parameters {
+			declared[param.Name] = true
+		}
+	}
+	if err := validateParameterOverrides(spec.TierName, declared, overrides); err != nil {
+		return nil, err
+	}
+	return tierTemplates, nil
+}
+
+// validateParameterOverrides checks that the given template parameter overrides are declared by the templates of the tier,
+// and that none of them is set by the member operator
+func validateParameterOverrides(tierName string, declared map[string]bool, overrides map[string]string) error {
+	names := make([]string, 0, len(overrides))
+	for name := range overrides {
+		names = append(names, name)
+	}
+	sort.Strings(names) // report the same error for the same overrides
+	for _, name := range names {
+		if reservedParameters[name] {
+			return fmt.Errorf("the template parameter '%s' cannot be overridden", name)
+		}
+		if !declared[name] {
+			return fmt.Errorf("the template parameter '%s' is not declared by the templates of the '%s' tier", name, tierName)
+		}
+	}
+	return nil
+}
+
+// ensureOverriddenTierTemplate creates the copy of the given TierTemplate with the given template parameter overrides, unless it already exists.
+// The copy is never updated, since its name depends on the name of the original TierTemplate (which is immutable) and on the overrides.
+func ensureOverriddenTierTemplate(cl client.Client, tierTmpl *toolchainv1alpha1.TierTemplate, overrides map[string]string) error {
+	name := OverriddenTemplateRef(tierTmpl.Name, overrides)
+	if err := cl.Get(context.TODO(), types.NamespacedName{Namespace: tierTmpl.Namespace, Name: name}, &toolchainv1alpha1.TierTemplate{}); err == nil {
+		return nil
+	} else if !errors.IsNotFound(err) {
+		return errs.Wrapf(err, "unable to get the TierTemplate '%s'", name)
+	}
+	value, err := json.Marshal(overrides)
+	if err != nil {
+		return err
+	}
+	overridden := &toolchainv1alpha1.TierTemplate{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: tierTmpl.Namespace,
+			Name:      name,
+			Annotations: map[string]string{
+				TemplateParametersAnnotationKey: string(value),
+			},
+		},
+		Spec: *tierTmpl.Spec.DeepCopy(),
+	}
+	for i, param := range overridden.Spec.Template.Parameters {
+		if v, found := overrides[param.Name]; found {
+			overridden.Spec.Template.Parameters[i].Value = v
+			overridden.Spec.Template.Parameters[i].Generate = ""
+		}
+	}
+	if err := cl.Create(context.TODO(), overridden); err != nil && !errors.IsAlreadyExists(err) {
+		return errs.Wrapf(err, "unable to create the TierTemplate '%s'", name)
+	}
+	return nil
+}
+
+// TemplateRefsMatch returns `true` if both given NSTemplateSet specs have the same templateRefs
+func TemplateRefsMatch(expected, actual toolchainv1alpha1.NSTemplateSetSpec) bool {
+	return sameRefs(templateRefsOfSet(expected), templateRefsOfSet(actual))
+}
@@ -0,0 +1,217 @@
+package nstemplatetierbundle
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/host-operator/controllers/toolchainconfig"
+	"github.com/codeready-toolchain/host-operator/pkg/templates/assets"
+	"github.com/codeready-toolchain/host-operator/pkg/templates/nstemplatetiers"
+
+	"github.com/go-logr/logr"
+	errs "github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// Reconciler creates or updates the NSTemplateTiers and TierTemplates from the tiers embedded in the host operator,
+// merged with the tier bundles loaded at runtime from the directory configured in the ToolchainConfig
+// and from the ConfigMaps labelled with `toolchain.dev.openshift.com/nstemplatetier-bundle`
+type Reconciler struct {
+	Client    client.Client
+	Scheme    *runtime.Scheme
+	Namespace string
+	Embedded  assets.Assets
+
+	mu sync.Mutex
+	// checksum of the tier bundles which were last created or updated
+	appliedChecksum string
+}
+
+// SetupWithManager sets up the controller with the Manager.
+// Watches the ToolchainConfig and the ConfigMaps containing a tier bundle, whose events are all mapped to the ToolchainConfig,
+// since the tier bundles are always processed together
+func (r *Reconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("nstemplatetierbundle").
+		For(&toolchainv1alpha1.ToolchainConfig{}).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}},
+			handler.EnqueueRequestsFromMapFunc(r.mapToToolchainConfig),
+			builder.WithPredicates(bundleConfigMapPredicate())).
+		Complete(r)
+}
+
+func (r *Reconciler) mapToToolchainConfig(_ client.Object) []reconcile.Request {
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: r.Namespace, Name: "config"}}}
+}
+
+// bundleConfigMapPredicate filters the events of the ConfigMaps which have (or had) the bundle label
+func bundleConfigMapPredicate() predicate.Predicate {
+	hasBundleLabel := func(obj client.Object) bool {
+		_, found := obj.GetLabels()[nstemplatetiers.BundleLabelKey]
+		return found
+	}
+	return predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool {
+			return hasBundleLabel(e.Object)
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return hasBundleLabel(e.ObjectOld) || hasBundleLabel(e.ObjectNew)
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return hasBundleLabel(e.Object)
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			return hasBundleLabel(e.Object)
+		},
+	}
+}
+
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
+//+kubebuilder:rbac:groups=toolchain.dev.openshift.com,resources=toolchainconfigs,verbs=get;list;watch
+
+// Reconcile creates or updates the NSTemplateTiers and TierTemplates when the tier bundles changed.
+// Since the files of the directory are not watched, the request is requeued after the resync period configured in the ToolchainConfig.
+func (r *Reconciler) Reconcile(ctx context.Context, _ ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+	logger.Info("reconciling the tier bundles")
+
+	config, err := toolchainconfig.GetToolchainConfig(r.Client)
+	if err != nil {
+		return reconcile.Result{}, errs.Wrap(err, "unable to get the ToolchainConfig")
+	}
+	if err := r.CreateOrUpdateTiers(logger, config); err != nil {
+		return reconcile.Result{}, err
+	}
+	return reconcile.Result{RequeueAfter: config.Tiers().BundlesResyncPeriod()}, nil
+}
+
+// CreateOrUpdateTiers creates or updates the NSTemplateTiers and TierTemplates from the embedded tiers merged with the tier bundles
+// of the directory configured in the given ToolchainConfig and of the ConfigMaps. Nothing is done if the tier bundles did not change
+// since the last call. The NSTemplateTiers which are no longer defined by any bundle are not deleted.
+// The invalid ConfigMaps are logged and skipped, so they do not prevent the other tiers from being created or updated.
+func (r *Reconciler) CreateOrUpdateTiers(logger logr.Logger, config toolchainconfig.ToolchainConfig) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sources := []assets.Assets{r.Embedded}
+	if dir := config.Tiers().BundlesDirectory(); dir != "" {
+		dirAssets, err := assets.NewDirAssets(dir)
+		if err != nil {
+			return errs.Wrap(err, "unable to load the tier bundles of the directory")
+		}
+		sources = append(sources, dirAssets)
+	}
+	configMaps := &corev1.ConfigMapList{}
+	if err := r.Client.List(context.TODO(), configMaps, client.InNamespace(r.Namespace), client.HasLabels{nstemplatetiers.BundleLabelKey}); err != nil {
+		return errs.Wrap(err, "unable to list the ConfigMaps containing a tier bundle")
+	}
+	var validConfigMaps []corev1.ConfigMap
+	invalid := map[string]bool{}
+	configMapAssets, err := nstemplatetiers.NewConfigMapAssets(configMaps.Items, func(cm corev1.ConfigMap, err error) {
+		logger.Error(err, "skipping the invalid tier bundle of the ConfigMap", "configmap", cm.Name)
+		invalid[cm.Name] = true
+	})
+	if err != nil {
+		return errs.Wrap(err, "unable to load the tier bundles of the ConfigMaps")
+	}
+	for _, cm := range configMaps.Items {
+		if !invalid[cm.Name] {
+			validConfigMaps = append(validConfigMaps, cm)
+		}
+	}
+
+	bundles, err := nstemplatetiers.MergeAssets(append(sources, configMapAssets)...)
+	if err != nil {
+		return errs.Wrap(err, "unable to merge the tier bundles")
+	}
+	checksum, err := checksumOf(bundles)
+	if err != nil {
+		return err
+	}
+	if checksum == r.appliedChecksum {
+		logger.Info("tier bundles did not change")
+		return nil
+	}
+	if validationErrs := nstemplatetiers.ValidateTemplates(r.Scheme, r.Namespace, bundles); len(validationErrs) > 0 {
+		logger.Info("some tier bundles are invalid, looking for the ConfigMaps to skip", "errors", len(validationErrs))
+		if bundles, err = r.mergeValidConfigMaps(logger, sources, validConfigMaps); err != nil {
+			return err
+		}
+	}
+	logger.Info("creating/updating the NSTemplateTier resources from the tier bundles", "configmaps", len(configMaps.Items), "checksum", checksum)
+	if err := nstemplatetiers.CreateOrUpdateResources(r.Scheme, r.Client, r.Namespace, bundles); err != nil {
+		return err
+	}
+	r.appliedChecksum = checksum
+	return nil
+}
+
+// mergeValidConfigMaps merges the given sources with the tier bundles of the ConfigMaps whose templates are valid, and skips the others.
+// Since a tier may be based on a tier of another ConfigMap, the ConfigMaps are accepted one by one, until no other one can be accepted.
+func (r *Reconciler) mergeValidConfigMaps(logger logr.Logger, sources []assets.Assets, configMaps []corev1.ConfigMap) (assets.Assets, error) {
+	merge := func(configMaps []corev1.ConfigMap) (assets.Assets, error) {
+		configMapAssets, err := nstemplatetiers.NewConfigMapAssets(configMaps, nil)
+		if err != nil {
+			return assets.Assets{}, errs.Wrap(err, "unable to load the tier bundles of the ConfigMaps")
+		}
+		bundles, err := nstemplatetiers.MergeAssets(append(sources, configMapAssets)...)
+		return bundles, errs.Wrap(err, "unable to merge the tier bundles")
+	}
+	var accepted []corev1.ConfigMap
+	pending := configMaps
+	for len(pending) > 0 {
+		var rejected []corev1.ConfigMap
+		validationErrs := map[string]error{}
+		for _, cm := range pending {
+			candidates := append(append([]corev1.ConfigMap{}, accepted...), cm)
+			bundles, err := merge(candidates)
+			if err != nil {
+				return assets.Assets{}, err
+			}
+			if cmErrs := nstemplatetiers.ValidateTemplates(r.Scheme, r.Namespace, bundles); len(cmErrs) > 0 {
+				rejected = append(rejected, cm)
+				validationErrs[cm.Name] = cmErrs[0]
+				continue
+			}
+			accepted = candidates
+		}
+		if len(rejected) == len(pending) {
+			for _, cm := range rejected {
+				logger.Error(validationErrs[cm.Name], "skipping the invalid tier bundle of the ConfigMap", "configmap", cm.Name)
+			}
+			break
+		}
+		pending = rejected
+	}
+	return merge(accepted)
+}
+
+// checksumOf computes a checksum of the names and contents of the given assets
+func checksumOf(bundles assets.Assets) (string, error) {
+	hash := sha256.New()
+	for _, name := range bundles.Names() {
+		content, err := bundles.Asset(name)
+		if err != nil {
+			return "", errs.Wrapf(err, "unable to load the '%s' asset", name)
+		}
+		hash.Write([]byte(name))
+		hash.Write([]byte{0})
+		hash.Write(content)
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
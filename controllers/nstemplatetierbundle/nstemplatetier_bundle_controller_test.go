@@ -0,0 +1,257 @@
+package nstemplatetierbundle_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/host-operator/controllers/nstemplatetierbundle"
+	"github.com/codeready-toolchain/host-operator/pkg/apis"
+	"github.com/codeready-toolchain/host-operator/pkg/templates/assets"
+	"github.com/codeready-toolchain/host-operator/pkg/templates/nstemplatetiers"
+	. "github.com/codeready-toolchain/host-operator/test"
+	commonconfig "github.com/codeready-toolchain/toolchain-common/pkg/configuration"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestReconcile(t *testing.T) {
+
+	t.Run("embedded tiers only", func(t *testing.T) {
+		// given
+		config := commonconfig.NewToolchainConfigObjWithReset(t)
+		r, req, cl := prepareReconcile(t, config)
+
+		// when
+		res, err := r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, reconcile.Result{RequeueAfter: time.Minute}, res)
+		assertTierExists(t, cl, "base")
+		assertTierExists(t, cl, "advanced")
+		assertTierNotFound(t, cl, "custom")
+	})
+
+	t.Run("new tier in a ConfigMap", func(t *testing.T) {
+		// given
+		config := commonconfig.NewToolchainConfigObjWithReset(t)
+		cm := newBundleConfigMap("custom-tier", "custom", `from: base
+parameters:
+- name: IDLER_TIMEOUT_SECONDS
+  value: "3600"
+`)
+		r, req, cl := prepareReconcile(t, config, cm)
+
+		// when
+		_, err := r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assertTierExists(t, cl, "base")
+		custom := assertTierExists(t, cl, "custom")
+
+		t.Run("nothing to do when the bundles did not change", func(t *testing.T) {
+			// given
+			cl.MockList = func(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+				if _, ok := list.(*corev1.ConfigMapList); !ok {
+					return fmt.Errorf("unexpected list of %T", list)
+				}
+				return cl.Client.List(ctx, list, opts...)
+			}
+			cl.MockCreate = func(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+				return fmt.Errorf("unexpected creation of %T", obj)
+			}
+
+			// when
+			_, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+		})
+
+		t.Run("tier updated when the ConfigMap changed", func(t *testing.T) {
+			// given
+			cl.MockList = nil
+			cl.MockCreate = nil
+			cm := &corev1.ConfigMap{}
+			require.NoError(t, cl.Get(context.TODO(), types.NamespacedName{Namespace: test.HostOperatorNs, Name: "custom-tier"}, cm))
+			cm.Data["based_on_tier.yaml"] = `from: base
+parameters:
+- name: IDLER_TIMEOUT_SECONDS
+  value: "7200"
+`
+			require.NoError(t, cl.Update(context.TODO(), cm))
+
+			// when
+			_, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			updated := assertTierExists(t, cl, "custom")
+			assert.NotEqual(t, custom.Spec.Namespaces, updated.Spec.Namespaces)
+		})
+	})
+
+	t.Run("new tier in the directory", func(t *testing.T) {
+		// given
+		dir, err := ioutil.TempDir("", "tiers")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		require.NoError(t, os.Mkdir(filepath.Join(dir, "custom"), 0755))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "custom", "based_on_tier.yaml"), []byte("from: base\n"), 0600))
+		config := commonconfig.NewToolchainConfigObjWithReset(t, TierBundles(dir, "10s"))
+		r, req, cl := prepareReconcile(t, config)
+
+		// when
+		res, err := r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, reconcile.Result{RequeueAfter: 10 * time.Second}, res)
+		assertTierExists(t, cl, "custom")
+	})
+
+	t.Run("failures", func(t *testing.T) {
+
+		t.Run("missing directory", func(t *testing.T) {
+			// given
+			config := commonconfig.NewToolchainConfigObjWithReset(t, TierBundles("/does/not/exist", "10s"))
+			r, req, _ := prepareReconcile(t, config)
+
+			// when
+			_, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "unable to load the tier bundles of the directory")
+		})
+
+		t.Run("unable to list ConfigMaps", func(t *testing.T) {
+			// given
+			config := commonconfig.NewToolchainConfigObjWithReset(t)
+			r, req, cl := prepareReconcile(t, config)
+			cl.MockList = func(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+				if _, ok := list.(*corev1.ConfigMapList); ok {
+					return fmt.Errorf("mock error")
+				}
+				return cl.Client.List(ctx, list, opts...)
+			}
+
+			// when
+			_, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.EqualError(t, err, "unable to list the ConfigMaps containing a tier bundle: mock error")
+		})
+
+		t.Run("invalid bundle", func(t *testing.T) {
+			// given
+			config := commonconfig.NewToolchainConfigObjWithReset(t)
+			r, req, _ := prepareReconcile(t, config)
+			// the embedded tiers are not skipped, unlike the ConfigMaps
+			r.Embedded = assets.NewAssets(func() []string {
+				return []string{"broken/based_on_tier.yaml"}
+			}, func(name string) ([]byte, error) {
+				return []byte("from: unknown\n"), nil
+			})
+
+			// when
+			_, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.EqualError(t, err, "unable to create TierTemplates: the tier broken is based on the tier unknown which does not exist")
+		})
+	})
+
+	t.Run("invalid ConfigMaps skipped", func(t *testing.T) {
+		// given
+		config := commonconfig.NewToolchainConfigObjWithReset(t)
+		valid := newBundleConfigMap("custom-tier", "custom", "from: base\n")
+		basedOnValid := newBundleConfigMap("a-custom-tier", "custom2", "from: custom\n")
+		duplicate := newBundleConfigMap("custom-tier-2", "custom", "from: advanced\n")
+		noTierName := newBundleConfigMap("no-tier-name", "", "from: base\n")
+		invalidMetadata := newBundleConfigMap("invalid-metadata", "custom3", "from: base\n")
+		invalidMetadata.Data["metadata.yaml"] = "- not a map"
+		unknownBase := newBundleConfigMap("unknown-base", "custom4", "from: unknown\n")
+		r, req, cl := prepareReconcile(t, config, valid, basedOnValid, duplicate, noTierName, invalidMetadata, unknownBase)
+
+		// when
+		res, err := r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, reconcile.Result{RequeueAfter: time.Minute}, res)
+		base := assertTierExists(t, cl, "base")
+		custom := assertTierExists(t, cl, "custom")
+		// the first ConfigMap of the tier is kept
+		assert.Equal(t, base.Spec.DeactivationTimeoutDays, custom.Spec.DeactivationTimeoutDays)
+		assertTierExists(t, cl, "custom2")
+		assertTierNotFound(t, cl, "custom3")
+		assertTierNotFound(t, cl, "custom4")
+	})
+}
+
+func prepareReconcile(t *testing.T, initObjs ...runtime.Object) (*nstemplatetierbundle.Reconciler, reconcile.Request, *test.FakeClient) {
+	require.NoError(t, os.Setenv("WATCH_NAMESPACE", test.HostOperatorNs))
+	s := scheme.Scheme
+	err := apis.AddToScheme(s)
+	require.NoError(t, err)
+	cl := test.NewFakeClient(t, initObjs...)
+	r := &nstemplatetierbundle.Reconciler{
+		Client:    cl,
+		Scheme:    s,
+		Namespace: test.HostOperatorNs,
+		Embedded:  assets.NewAssets(nstemplatetiers.AssetNames, nstemplatetiers.Asset),
+	}
+	req := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Namespace: test.HostOperatorNs,
+			Name:      "config",
+		},
+	}
+	return r, req, cl
+}
+
+func newBundleConfigMap(name, tier, basedOnTier string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: test.HostOperatorNs,
+			Name:      name,
+			Labels: map[string]string{
+				nstemplatetiers.BundleLabelKey: tier,
+			},
+		},
+		Data: map[string]string{
+			"based_on_tier.yaml": basedOnTier,
+		},
+	}
+}
+
+func assertTierExists(t *testing.T, cl client.Client, name string) *toolchainv1alpha1.NSTemplateTier {
+	tier := &toolchainv1alpha1.NSTemplateTier{}
+	err := cl.Get(context.TODO(), types.NamespacedName{Namespace: test.HostOperatorNs, Name: name}, tier)
+	require.NoError(t, err)
+	return tier
+}
+
+func assertTierNotFound(t *testing.T, cl client.Client, name string) {
+	tier := &toolchainv1alpha1.NSTemplateTier{}
+	err := cl.Get(context.TODO(), types.NamespacedName{Namespace: test.HostOperatorNs, Name: name}, tier)
+	require.True(t, errors.IsNotFound(err), "unexpected error: %v", err)
+}
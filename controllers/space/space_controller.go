@@ -28,6 +28,17 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
+const (
+	// HibernatedAnnotationKey is the annotation set on a Space which must be hibernated, ie, whose NSTemplateSet must be removed
+	// from the target member cluster while the Space itself is retained. The NSTemplateSet is provisioned again once the annotation is removed.
+	HibernatedAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "hibernated"
+
+	// Status condition reasons
+	SpaceHibernatingReason       = "Hibernating"
+	SpaceHibernatedReason        = "Hibernated"
+	SpaceHibernatingFailedReason = "UnableToHibernate"
+)
+
 // Reconciler reconciles a Space object
 type Reconciler struct {
 	Client         client.Client
@@ -40,8 +51,8 @@ type Reconciler struct {
 // Watches NSTemplateSets on the member clusters as its secondary resources.
 func (r *Reconciler) SetupWithManager(mgr ctrl.Manager, memberClusters map[string]cluster.Cluster) error {
 	b := ctrl.NewControllerManagedBy(mgr).
-		// watch Spaces in the host cluster
-		For(&toolchainv1alpha1.Space{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		// watch Spaces in the host cluster (including when they are annotated for hibernation)
+		For(&toolchainv1alpha1.Space{}, builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{}))).
 		Watches(&source.Kind{Type: &toolchainv1alpha1.TemplateUpdateRequest{}}, &handler.EnqueueRequestForObject{})
 	// watch NSTemplateSets in all the member clusters
 	for _, memberCluster := range memberClusters {
@@ -88,6 +99,10 @@ func (r *Reconciler) Reconcile(ctx context.Context, request ctrl.Request) (ctrl.
 		return reconcile.Result{}, r.ensureSpaceDeletion(logger, space)
 	}
 
+	if IsHibernated(space) {
+		return reconcile.Result{}, r.ensureSpaceHibernation(logger, space)
+	}
+
 	// if the NSTemplateSet was created or updated, we want to make sure that the NSTemplateSet Controller was kicked before
 	// reconciling the Space again. In particular, when the NSTemplateSet.Spec is updated, if the Space Controller is triggered
 	// *before* the NSTemplateSet Controller and the NSTemplateSet's status is still `Provisioned` (as it was with the previous templates)
@@ -182,6 +197,11 @@ func (r *Reconciler) ensureNSTemplateSet(logger logr.Logger, space *toolchainv1a
 	}, tmplTier); err != nil {
 		return false, r.setStatusProvisioningFailed(logger, space, err)
 	}
+	// apply the template parameter overrides of the Space (if any) on the NSTemplateSet spec of the tier
+	nsTmplSetSpec, err := r.nsTemplateSetSpecOf(space, tmplTier)
+	if err != nil {
+		return false, r.setStatusProvisioningFailed(logger, space, err)
+	}
 	// create if not found on the expected target cluster
 	nsTmplSet := &toolchainv1alpha1.NSTemplateSet{}
 	if err := memberCluster.Client.Get(context.TODO(), types.NamespacedName{
@@ -193,7 +213,7 @@ func (r *Reconciler) ensureNSTemplateSet(logger logr.Logger, space *toolchainv1a
 			if err := r.setStatusProvisioning(space); err != nil {
 				return false, r.setStatusProvisioningFailed(logger, space, err)
 			}
-			nsTmplSet = r.newNSTemplateSet(memberCluster.OperatorNamespace, space.Name, tmplTier)
+			nsTmplSet = r.newNSTemplateSet(memberCluster.OperatorNamespace, space.Name, nsTmplSetSpec)
 
 			if err := memberCluster.Client.Create(context.TODO(), nsTmplSet); err != nil {
 				logger.Error(err, "failed to create NSTemplateSet on target member cluster")
@@ -206,10 +226,9 @@ func (r *Reconciler) ensureNSTemplateSet(logger logr.Logger, space *toolchainv1a
 	}
 	logger.Info("NSTemplateSet already exists")
 
-	// update the NSTemplateSet if needed
-	if !tierutil.TierHashMatches(tmplTier, nsTmplSet.Spec) {
-		nsTmplSetSpec := usersignup.NewNSTemplateSetSpec(tmplTier)
-		nsTmplSet.Spec = *nsTmplSetSpec
+	// update the NSTemplateSet if needed (ie, if the templates of the tier or the template parameter overrides changed)
+	if !tierutil.TemplateRefsMatch(nsTmplSetSpec, nsTmplSet.Spec) {
+		nsTmplSet.Spec = nsTmplSetSpec
 		if err := memberCluster.Client.Update(context.TODO(), nsTmplSet); err != nil {
 			return false, r.setStatusNSTemplateSetUpdateFailed(logger, space, err)
 		}
@@ -256,17 +275,25 @@ func (r *Reconciler) ensureNSTemplateSet(logger logr.Logger, space *toolchainv1a
 	}
 }
 
-func (r *Reconciler) newNSTemplateSet(namespace string, name string, tmplTier *toolchainv1alpha1.NSTemplateTier) *toolchainv1alpha1.NSTemplateSet {
-	// create the NSTemplateSet from the NSTemplateTier
-	nsTmplSet := &toolchainv1alpha1.NSTemplateSet{
+// nsTemplateSetSpecOf returns the NSTemplateSet spec of the given tier, which refers to the TierTemplates with the template parameter
+// overrides of the given Space (if any)
+func (r *Reconciler) nsTemplateSetSpecOf(space *toolchainv1alpha1.Space, tmplTier *toolchainv1alpha1.NSTemplateTier) (toolchainv1alpha1.NSTemplateSetSpec, error) {
+	overrides, err := tierutil.GetParameterOverrides(space)
+	if err != nil {
+		return toolchainv1alpha1.NSTemplateSetSpec{}, err
+	}
+	return tierutil.ApplyParameterOverrides(r.Client, space.Namespace, *usersignup.NewNSTemplateSetSpec(tmplTier), overrides)
+}
+
+func (r *Reconciler) newNSTemplateSet(namespace string, name string, nsTmplSetSpec toolchainv1alpha1.NSTemplateSetSpec) *toolchainv1alpha1.NSTemplateSet {
+	// create the NSTemplateSet from the spec of the NSTemplateTier
+	return &toolchainv1alpha1.NSTemplateSet{
 		ObjectMeta: metav1.ObjectMeta{
 			Namespace: namespace,
 			Name:      name,
 		},
+		Spec: nsTmplSetSpec,
 	}
-	nsTmplSetSpec := usersignup.NewNSTemplateSetSpec(tmplTier)
-	nsTmplSet.Spec = *nsTmplSetSpec
-	return nsTmplSet
 }
 
 func (r *Reconciler) ensureSpaceDeletion(logger logr.Logger, space *toolchainv1alpha1.Space) error {
@@ -293,6 +320,50 @@ func (r *Reconciler) ensureSpaceDeletion(logger logr.Logger, space *toolchainv1a
 	return nil
 }
 
+// IsHibernated returns `true` if the given Space is annotated for hibernation
+func IsHibernated(space *toolchainv1alpha1.Space) bool {
+	return space.Annotations[HibernatedAnnotationKey] == "true"
+}
+
+// ensureSpaceHibernation deletes the NSTemplateSet of the given Space from the target member cluster, but retains the Space.
+// Also, the tier hash label is updated since there is nothing to keep up-to-date with the tier while the Space is hibernated
+// (the NSTemplateSet will be provisioned with the current templates of the tier when the Space is resumed)
+func (r *Reconciler) ensureSpaceHibernation(logger logr.Logger, space *toolchainv1alpha1.Space) error {
+	logger.Info("hibernating Space")
+	if isBeingDeleted, err := r.deleteNSTemplateSet(logger, space); err != nil {
+		logger.Error(err, "failed to delete the NSTemplateSet")
+		return r.setStatusHibernatingFailed(logger, space, err)
+	} else if isBeingDeleted {
+		return r.setStatusHibernating(space)
+	}
+	tmplTier := &toolchainv1alpha1.NSTemplateTier{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{
+		Namespace: space.Namespace,
+		Name:      space.Spec.TierName,
+	}, tmplTier); err != nil {
+		if !errors.IsNotFound(err) {
+			return r.setStatusHibernatingFailed(logger, space, err)
+		}
+		// no tier to keep up with
+		return r.setStatusHibernated(space)
+	}
+	hash, err := tierutil.ComputeHashForNSTemplateTier(tmplTier)
+	if err != nil {
+		return r.setStatusHibernatingFailed(logger, space, err)
+	}
+	if labelKey := tierutil.TemplateTierHashLabelKey(space.Spec.TierName); space.Labels[labelKey] != hash {
+		if space.Labels == nil {
+			space.Labels = map[string]string{}
+		}
+		space.Labels[labelKey] = hash
+		if err := r.Client.Update(context.TODO(), space); err != nil {
+			return r.setStatusHibernatingFailed(logger, space, err)
+		}
+	}
+	logger.Info("Space is hibernated")
+	return r.setStatusHibernated(space)
+}
+
 // deleteNSTemplateSet triggers the deletion of the NSTemplateSet on the target member cluster.
 // Returns `true/nil` if the NSTemplateSet is being deleted (whether deletion was triggered during this call,
 // or if it was triggered earlier and is still in progress)
@@ -485,6 +556,41 @@ func (r *Reconciler) setStatusTerminatingFailed(logger logr.Logger, space *toolc
 	return cause
 }
 
+func (r *Reconciler) setStatusHibernating(space *toolchainv1alpha1.Space) error {
+	return r.updateStatus(
+		space,
+		toolchainv1alpha1.Condition{
+			Type:   toolchainv1alpha1.ConditionReady,
+			Status: corev1.ConditionFalse,
+			Reason: SpaceHibernatingReason,
+		})
+}
+
+func (r *Reconciler) setStatusHibernated(space *toolchainv1alpha1.Space) error {
+	return r.updateStatus(
+		space,
+		toolchainv1alpha1.Condition{
+			Type:   toolchainv1alpha1.ConditionReady,
+			Status: corev1.ConditionFalse,
+			Reason: SpaceHibernatedReason,
+		})
+}
+
+func (r *Reconciler) setStatusHibernatingFailed(logger logr.Logger, space *toolchainv1alpha1.Space, cause error) error {
+	if err := r.updateStatus(
+		space,
+		toolchainv1alpha1.Condition{
+			Type:    toolchainv1alpha1.ConditionReady,
+			Status:  corev1.ConditionFalse,
+			Reason:  SpaceHibernatingFailedReason,
+			Message: cause.Error(),
+		}); err != nil {
+		logger.Error(cause, "unable to hibernate Space")
+		return err
+	}
+	return cause
+}
+
 func (r *Reconciler) setStatusNSTemplateSetCreationFailed(logger logr.Logger, space *toolchainv1alpha1.Space, cause error) error {
 	if err := r.updateStatus(
 		space,
@@ -1139,6 +1139,411 @@ func TestRetargetSpace(t *testing.T) {
 	})
 }
 
+func TestHibernateSpace(t *testing.T) {
+
+	// given
+	logf.SetLogger(zap.New(zap.UseDevMode(true)))
+	s := scheme.Scheme
+	err := apis.AddToScheme(s)
+	require.NoError(t, err)
+	basicTier := tiertest.BasicTier(t, tiertest.CurrentBasicTemplates)
+
+	t.Run("success", func(t *testing.T) {
+		// given
+		s := spacetest.NewSpace("oddity",
+			spacetest.WithFinalizer(),
+			spacetest.WithSpecTargetCluster("member-1"),
+			spacetest.WithStatusTargetCluster("member-1"),
+			spacetest.WithCondition(spacetest.Ready()),
+			spacetest.WithAnnotation(space.HibernatedAnnotationKey, "true"))
+		hostClient := test.NewFakeClient(t, s, basicTier)
+		nstmplSet := nstemplatetsettest.NewNSTemplateSet("oddity", nstemplatetsettest.WithReadyCondition())
+		member1Client := test.NewFakeClient(t, nstmplSet)
+		member1Client.MockDelete = mockDeleteNSTemplateSet(member1Client.Client)
+		member1 := NewMemberClusterWithClient(member1Client, "member-1", corev1.ConditionTrue)
+		ctrl := newReconciler(hostClient, member1)
+
+		// when
+		res, err := ctrl.Reconcile(context.TODO(), requestFor(s))
+
+		// then
+		require.NoError(t, err)
+		assert.False(t, res.Requeue) // no need to explicitly requeue while the NSTemplate is terminating
+		spacetest.AssertThatSpace(t, s.Namespace, s.Name, hostClient).
+			Exists().
+			HasFinalizer().
+			HasStatusTargetCluster("member-1").
+			HasConditions(spacetest.Hibernating())
+		nstemplatetsettest.AssertThatNSTemplateSet(t, test.MemberOperatorNs, "oddity", member1.Client).
+			HasDeletionTimestamp()
+
+		t.Run("hibernated when NSTemplateSet is deleted", func(t *testing.T) {
+			// given
+			err := member1Client.Client.Delete(context.TODO(), nstmplSet)
+			require.NoError(t, err)
+
+			// when
+			res, err := ctrl.Reconcile(context.TODO(), requestFor(s))
+
+			// then
+			require.NoError(t, err)
+			assert.False(t, res.Requeue)
+			spacetest.AssertThatSpace(t, s.Namespace, s.Name, hostClient).
+				Exists().
+				HasFinalizer().
+				HasStatusTargetCluster("member-1").
+				HasMatchingTierLabelForTier(basicTier). // nothing to update while the Space is hibernated
+				HasConditions(spacetest.Hibernated())
+			nstemplatetsettest.AssertThatNSTemplateSet(t, test.MemberOperatorNs, "oddity", member1.Client).
+				DoesNotExist()
+
+			t.Run("NSTemplateSet provisioned again when annotation is removed", func(t *testing.T) {
+				// given
+				hibernated := spacetest.AssertThatSpace(t, s.Namespace, s.Name, hostClient).Get()
+				delete(hibernated.Annotations, space.HibernatedAnnotationKey)
+				err := hostClient.Update(context.TODO(), hibernated)
+				require.NoError(t, err)
+
+				// when
+				res, err := ctrl.Reconcile(context.TODO(), requestFor(s))
+
+				// then
+				require.NoError(t, err)
+				assert.True(t, res.Requeue)
+				spacetest.AssertThatSpace(t, s.Namespace, s.Name, hostClient).
+					Exists().
+					HasStatusTargetCluster("member-1").
+					HasConditions(spacetest.Provisioning())
+				nstemplatetsettest.AssertThatNSTemplateSet(t, test.MemberOperatorNs, "oddity", member1.Client).
+					Exists().
+					HasTierName(basicTier.Name)
+			})
+		})
+	})
+
+	t.Run("annotation with other value is ignored", func(t *testing.T) {
+		// given
+		s := spacetest.NewSpace("oddity",
+			spacetest.WithFinalizer(),
+			spacetest.WithSpecTargetCluster("member-1"),
+			spacetest.WithAnnotation(space.HibernatedAnnotationKey, "false"))
+		hostClient := test.NewFakeClient(t, s, basicTier)
+		member1 := NewMemberCluster(t, "member-1", corev1.ConditionTrue)
+		ctrl := newReconciler(hostClient, member1)
+
+		// when
+		_, err := ctrl.Reconcile(context.TODO(), requestFor(s))
+
+		// then
+		require.NoError(t, err)
+		spacetest.AssertThatSpace(t, s.Namespace, s.Name, hostClient).
+			HasConditions(spacetest.Provisioning())
+		nstemplatetsettest.AssertThatNSTemplateSet(t, test.MemberOperatorNs, "oddity", member1.Client).
+			Exists()
+	})
+
+	t.Run("failure", func(t *testing.T) {
+
+		t.Run("error while deleting NSTemplateSet", func(t *testing.T) {
+			// given
+			s := spacetest.NewSpace("oddity",
+				spacetest.WithFinalizer(),
+				spacetest.WithSpecTargetCluster("member-1"),
+				spacetest.WithStatusTargetCluster("member-1"),
+				spacetest.WithAnnotation(space.HibernatedAnnotationKey, "true"))
+			hostClient := test.NewFakeClient(t, s, basicTier)
+			nstmplSet := nstemplatetsettest.NewNSTemplateSet("oddity", nstemplatetsettest.WithReadyCondition())
+			member1Client := test.NewFakeClient(t, nstmplSet)
+			member1Client.MockDelete = mockDeleteNSTemplateSetFail(member1Client.Client)
+			member1 := NewMemberClusterWithClient(member1Client, "member-1", corev1.ConditionTrue)
+			ctrl := newReconciler(hostClient, member1)
+
+			// when
+			_, err := ctrl.Reconcile(context.TODO(), requestFor(s))
+
+			// then
+			require.EqualError(t, err, "mock error")
+			spacetest.AssertThatSpace(t, s.Namespace, s.Name, hostClient).
+				HasConditions(spacetest.UnableToHibernate("mock error"))
+			nstemplatetsettest.AssertThatNSTemplateSet(t, test.MemberOperatorNs, "oddity", member1.Client).
+				Exists()
+		})
+
+		t.Run("error while getting NSTemplateTier", func(t *testing.T) {
+			// given
+			s := spacetest.NewSpace("oddity",
+				spacetest.WithFinalizer(),
+				spacetest.WithSpecTargetCluster("member-1"),
+				spacetest.WithStatusTargetCluster("member-1"),
+				spacetest.WithAnnotation(space.HibernatedAnnotationKey, "true"))
+			hostClient := test.NewFakeClient(t, s, basicTier)
+			hostClient.MockGet = func(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+				if _, ok := obj.(*toolchainv1alpha1.NSTemplateTier); ok {
+					return fmt.Errorf("mock error")
+				}
+				return hostClient.Client.Get(ctx, key, obj)
+			}
+			member1 := NewMemberCluster(t, "member-1", corev1.ConditionTrue)
+			ctrl := newReconciler(hostClient, member1)
+
+			// when
+			_, err := ctrl.Reconcile(context.TODO(), requestFor(s))
+
+			// then
+			require.EqualError(t, err, "mock error")
+			spacetest.AssertThatSpace(t, s.Namespace, s.Name, hostClient).
+				HasConditions(spacetest.UnableToHibernate("mock error"))
+		})
+	})
+}
+
+func TestSpaceTemplateParameters(t *testing.T) {
+
+	// given
+	logf.SetLogger(zap.New(zap.UseDevMode(true)))
+	s := scheme.Scheme
+	err := apis.AddToScheme(s)
+	require.NoError(t, err)
+	basicTier := tiertest.BasicTier(t, tiertest.CurrentBasicTemplates)
+	tierTemplates := tiertest.TierTemplatesFor(basicTier)
+	overrides := map[string]string{"MEMORY_LIMIT": "10Gi"}
+	overriddenRefs := func(overrides map[string]string) (string, []string) {
+		return tierutil.OverriddenTemplateRef("basic-clusterresources-123456new", overrides), []string{
+			tierutil.OverriddenTemplateRef("basic-code-123456new", overrides),
+			tierutil.OverriddenTemplateRef("basic-dev-123456new", overrides),
+			tierutil.OverriddenTemplateRef("basic-stage-123456new", overrides),
+		}
+	}
+
+	t.Run("create NSTemplateSet with the TierTemplates with the parameter overrides", func(t *testing.T) {
+		// given
+		s := spacetest.NewSpace("oddity", spacetest.WithSpecTargetCluster("member-1"),
+			spacetest.WithAnnotation(tierutil.TemplateParametersAnnotationKey, `{"MEMORY_LIMIT":"10Gi"}`))
+		hostClient := test.NewFakeClient(t, append(tierTemplates, s, basicTier)...)
+		member1 := NewMemberCluster(t, "member-1", corev1.ConditionTrue)
+		ctrl := newReconciler(hostClient, member1)
+
+		// when
+		_, err := ctrl.Reconcile(context.TODO(), requestFor(s))
+
+		// then
+		require.NoError(t, err)
+		spacetest.AssertThatSpace(t, test.HostOperatorNs, "oddity", hostClient).
+			HasConditions(spacetest.Provisioning())
+		clusterResourcesRef, namespaceRefs := overriddenRefs(overrides)
+		nstemplatetsettest.AssertThatNSTemplateSet(t, test.MemberOperatorNs, "oddity", member1.Client).
+			Exists().
+			HasTierName(basicTier.Name).
+			HasClusterResourcesTemplateRef(clusterResourcesRef).
+			HasNamespaceTemplateRefs(namespaceRefs...)
+		// the overrides are applied in the copies of the TierTemplates of the tier
+		tierTmpl := &toolchainv1alpha1.TierTemplate{}
+		require.NoError(t, hostClient.Get(context.TODO(), test.NamespacedName(test.HostOperatorNs, clusterResourcesRef), tierTmpl))
+		assert.Equal(t, basicTier.Name, tierTmpl.Spec.TierName)
+		assert.Equal(t, `{"MEMORY_LIMIT":"10Gi"}`, tierTmpl.Annotations[tierutil.TemplateParametersAnnotationKey])
+		require.Len(t, tierTmpl.Spec.Template.Parameters, 2)
+		assert.Equal(t, "USERNAME", tierTmpl.Spec.Template.Parameters[0].Name)
+		assert.Empty(t, tierTmpl.Spec.Template.Parameters[0].Value)
+		assert.Equal(t, "MEMORY_LIMIT", tierTmpl.Spec.Template.Parameters[1].Name)
+		assert.Equal(t, "10Gi", tierTmpl.Spec.Template.Parameters[1].Value)
+		for _, ref := range namespaceRefs {
+			require.NoError(t, hostClient.Get(context.TODO(), test.NamespacedName(test.HostOperatorNs, ref), tierTmpl))
+		}
+		// the TierTemplates of the tier are unchanged
+		require.NoError(t, hostClient.Get(context.TODO(), test.NamespacedName(test.HostOperatorNs, "basic-clusterresources-123456new"), tierTmpl))
+		assert.Equal(t, "7Gi", tierTmpl.Spec.Template.Parameters[1].Value)
+	})
+
+	t.Run("update NSTemplateSet when the parameter overrides changed", func(t *testing.T) {
+		// given
+		s := spacetest.NewSpace("oddity",
+			spacetest.WithTierNameAndHashLabelFor(basicTier),
+			spacetest.WithSpecTargetCluster("member-1"),
+			spacetest.WithStatusTargetCluster("member-1"),
+			spacetest.WithCondition(spacetest.Ready()),
+			spacetest.WithFinalizer(),
+			spacetest.WithAnnotation(tierutil.TemplateParametersAnnotationKey, `{"MEMORY_LIMIT":"12Gi"}`))
+		hostClient := test.NewFakeClient(t, append(tierTemplates, s, basicTier)...)
+		nsTmplSet := nstemplatetsettest.NewNSTemplateSet("oddity", nstemplatetsettest.WithReferencesFor(basicTier), nstemplatetsettest.WithReadyCondition())
+		clusterResourcesRef, namespaceRefs := overriddenRefs(overrides)
+		nsTmplSet.Spec.ClusterResources.TemplateRef = clusterResourcesRef
+		for i := range nsTmplSet.Spec.Namespaces {
+			nsTmplSet.Spec.Namespaces[i].TemplateRef = namespaceRefs[i]
+		}
+		member1 := NewMemberClusterWithClient(test.NewFakeClient(t, nsTmplSet), "member-1", corev1.ConditionTrue)
+		ctrl := newReconciler(hostClient, member1)
+
+		// when
+		_, err := ctrl.Reconcile(context.TODO(), requestFor(s))
+
+		// then
+		require.NoError(t, err)
+		spacetest.AssertThatSpace(t, test.HostOperatorNs, "oddity", hostClient).
+			HasConditions(spacetest.Updating()).
+			HasMatchingTierLabelForTier(basicTier) // the tier hash is unchanged
+		clusterResourcesRef, namespaceRefs = overriddenRefs(map[string]string{"MEMORY_LIMIT": "12Gi"})
+		nstemplatetsettest.AssertThatNSTemplateSet(t, test.MemberOperatorNs, "oddity", member1.Client).
+			Exists().
+			HasTierName(basicTier.Name).
+			HasClusterResourcesTemplateRef(clusterResourcesRef).
+			HasNamespaceTemplateRefs(namespaceRefs...)
+	})
+
+	t.Run("update NSTemplateSet when the parameter overrides are removed", func(t *testing.T) {
+		// given
+		s := spacetest.NewSpace("oddity",
+			spacetest.WithTierNameAndHashLabelFor(basicTier),
+			spacetest.WithSpecTargetCluster("member-1"),
+			spacetest.WithStatusTargetCluster("member-1"),
+			spacetest.WithCondition(spacetest.Ready()),
+			spacetest.WithFinalizer())
+		hostClient := test.NewFakeClient(t, s, basicTier)
+		nsTmplSet := nstemplatetsettest.NewNSTemplateSet("oddity", nstemplatetsettest.WithReferencesFor(basicTier), nstemplatetsettest.WithReadyCondition())
+		clusterResourcesRef, _ := overriddenRefs(overrides)
+		nsTmplSet.Spec.ClusterResources.TemplateRef = clusterResourcesRef
+		member1 := NewMemberClusterWithClient(test.NewFakeClient(t, nsTmplSet), "member-1", corev1.ConditionTrue)
+		ctrl := newReconciler(hostClient, member1)
+
+		// when
+		_, err := ctrl.Reconcile(context.TODO(), requestFor(s))
+
+		// then
+		require.NoError(t, err)
+		spacetest.AssertThatSpace(t, test.HostOperatorNs, "oddity", hostClient).
+			HasConditions(spacetest.Updating())
+		nstemplatetsettest.AssertThatNSTemplateSet(t, test.MemberOperatorNs, "oddity", member1.Client).
+			Exists().
+			HasClusterResourcesTemplateRef("basic-clusterresources-123456new")
+	})
+
+	t.Run("update NSTemplateSet with the parameter overrides when the tier changed", func(t *testing.T) {
+		// given
+		previousBasicTier := tiertest.BasicTier(t, tiertest.PreviousBasicTemplates)
+		s := spacetest.NewSpace("oddity",
+			spacetest.WithTierNameAndHashLabelFor(previousBasicTier),
+			spacetest.WithSpecTargetCluster("member-1"),
+			spacetest.WithStatusTargetCluster("member-1"),
+			spacetest.WithCondition(spacetest.Ready()),
+			spacetest.WithFinalizer(),
+			spacetest.WithAnnotation(tierutil.TemplateParametersAnnotationKey, `{"MEMORY_LIMIT":"10Gi"}`))
+		hostClient := test.NewFakeClient(t, append(tierTemplates, s, basicTier)...)
+		nsTmplSet := nstemplatetsettest.NewNSTemplateSet("oddity", nstemplatetsettest.WithReferencesFor(previousBasicTier), nstemplatetsettest.WithReadyCondition())
+		member1 := NewMemberClusterWithClient(test.NewFakeClient(t, nsTmplSet), "member-1", corev1.ConditionTrue)
+		ctrl := newReconciler(hostClient, member1)
+
+		// when
+		_, err := ctrl.Reconcile(context.TODO(), requestFor(s))
+
+		// then the overrides are preserved
+		require.NoError(t, err)
+		spacetest.AssertThatSpace(t, test.HostOperatorNs, "oddity", hostClient).
+			HasConditions(spacetest.Updating())
+		clusterResourcesRef, namespaceRefs := overriddenRefs(overrides)
+		nstemplatetsettest.AssertThatNSTemplateSet(t, test.MemberOperatorNs, "oddity", member1.Client).
+			Exists().
+			HasClusterResourcesTemplateRef(clusterResourcesRef).
+			HasNamespaceTemplateRefs(namespaceRefs...)
+	})
+
+	t.Run("NSTemplateSet is up-to-date", func(t *testing.T) {
+		// given
+		s := spacetest.NewSpace("oddity",
+			spacetest.WithTierNameAndHashLabelFor(basicTier),
+			spacetest.WithSpecTargetCluster("member-1"),
+			spacetest.WithStatusTargetCluster("member-1"),
+			spacetest.WithCondition(spacetest.Ready()),
+			spacetest.WithFinalizer(),
+			spacetest.WithAnnotation(tierutil.TemplateParametersAnnotationKey, `{"MEMORY_LIMIT":"10Gi"}`))
+		hostClient := test.NewFakeClient(t, append(tierTemplates, s, basicTier)...)
+		nsTmplSet := nstemplatetsettest.NewNSTemplateSet("oddity", nstemplatetsettest.WithReferencesFor(basicTier), nstemplatetsettest.WithReadyCondition())
+		clusterResourcesRef, namespaceRefs := overriddenRefs(overrides)
+		nsTmplSet.Spec.ClusterResources.TemplateRef = clusterResourcesRef
+		for i := range nsTmplSet.Spec.Namespaces {
+			nsTmplSet.Spec.Namespaces[i].TemplateRef = namespaceRefs[i]
+		}
+		memberClient := test.NewFakeClient(t, nsTmplSet)
+		memberClient.MockUpdate = func(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+			return fmt.Errorf("mock error")
+		}
+		member1 := NewMemberClusterWithClient(memberClient, "member-1", corev1.ConditionTrue)
+		ctrl := newReconciler(hostClient, member1)
+
+		// when
+		_, err := ctrl.Reconcile(context.TODO(), requestFor(s))
+
+		// then the NSTemplateSet is not updated
+		require.NoError(t, err)
+		spacetest.AssertThatSpace(t, test.HostOperatorNs, "oddity", hostClient).
+			HasConditions(spacetest.Ready())
+	})
+
+	t.Run("failures", func(t *testing.T) {
+
+		for overrides, msg := range map[string]string{
+			`{"CPU_LIMIT":"2"}`:        "the template parameter 'CPU_LIMIT' is not declared by the templates of the 'basic' tier",
+			`{"USERNAME":"admin"}`:     "the template parameter 'USERNAME' cannot be overridden",
+			`{"MEMORY_LIMIT":"10Gi",}`: "invalid value of the 'toolchain.dev.openshift.com/template-parameters' annotation: invalid character '}' looking for beginning of object key string",
+		} {
+			t.Run(overrides, func(t *testing.T) {
+				// given
+				s := spacetest.NewSpace("oddity", spacetest.WithSpecTargetCluster("member-1"),
+					spacetest.WithAnnotation(tierutil.TemplateParametersAnnotationKey, overrides))
+				hostClient := test.NewFakeClient(t, append(tierTemplates, s, basicTier)...)
+				member1 := NewMemberCluster(t, "member-1", corev1.ConditionTrue)
+				ctrl := newReconciler(hostClient, member1)
+
+				// when
+				_, err := ctrl.Reconcile(context.TODO(), requestFor(s))
+
+				// then
+				require.EqualError(t, err, msg)
+				spacetest.AssertThatSpace(t, test.HostOperatorNs, "oddity", hostClient).
+					HasConditions(spacetest.ProvisioningFailed(msg))
+				nstemplatetsettest.AssertThatNSTemplateSet(t, test.MemberOperatorNs, "oddity", member1.Client).
+					DoesNotExist()
+			})
+		}
+
+		t.Run("missing TierTemplate", func(t *testing.T) {
+			// given
+			s := spacetest.NewSpace("oddity", spacetest.WithSpecTargetCluster("member-1"),
+				spacetest.WithAnnotation(tierutil.TemplateParametersAnnotationKey, `{"MEMORY_LIMIT":"10Gi"}`))
+			hostClient := test.NewFakeClient(t, s, basicTier)
+			member1 := NewMemberCluster(t, "member-1", corev1.ConditionTrue)
+			ctrl := newReconciler(hostClient, member1)
+
+			// when
+			_, err := ctrl.Reconcile(context.TODO(), requestFor(s))
+
+			// then
+			require.EqualError(t, err, `unable to get the TierTemplate 'basic-code-123456new': tiertemplates.toolchain.dev.openshift.com "basic-code-123456new" not found`)
+			nstemplatetsettest.AssertThatNSTemplateSet(t, test.MemberOperatorNs, "oddity", member1.Client).
+				DoesNotExist()
+		})
+
+		t.Run("unable to create the TierTemplate", func(t *testing.T) {
+			// given
+			s := spacetest.NewSpace("oddity", spacetest.WithSpecTargetCluster("member-1"),
+				spacetest.WithAnnotation(tierutil.TemplateParametersAnnotationKey, `{"MEMORY_LIMIT":"10Gi"}`))
+			hostClient := test.NewFakeClient(t, append(tierTemplates, s, basicTier)...)
+			hostClient.MockCreate = func(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+				return fmt.Errorf("mock error")
+			}
+			member1 := NewMemberCluster(t, "member-1", corev1.ConditionTrue)
+			ctrl := newReconciler(hostClient, member1)
+
+			// when
+			_, err := ctrl.Reconcile(context.TODO(), requestFor(s))
+
+			// then
+			_, namespaceRefs := overriddenRefs(overrides)
+			require.EqualError(t, err, fmt.Sprintf("unable to create the TierTemplate '%s': mock error", namespaceRefs[0]))
+			nstemplatetsettest.AssertThatNSTemplateSet(t, test.MemberOperatorNs, "oddity", member1.Client).
+				DoesNotExist()
+		})
+	})
+}
+
 func mockDeleteNSTemplateSet(cl client.Client) func(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
 	return func(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
 		if nstmplSet, ok := obj.(*toolchainv1alpha1.NSTemplateSet); ok {
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	tierutil "github.com/codeready-toolchain/host-operator/controllers/nstemplatetier/util"
 	"github.com/codeready-toolchain/host-operator/controllers/toolchainconfig"
 	"github.com/codeready-toolchain/host-operator/pkg/capacity"
 	"github.com/codeready-toolchain/host-operator/pkg/pending"
@@ -88,8 +89,16 @@ func (r *Reconciler) ensureFields(logger logr.Logger, space *toolchainv1alpha1.S
 		if err != nil {
 			return false, errs.Wrapf(err, "unable to set the TierName")
 		}
+		// use the successor of the default tier if it is deprecated
+		tier, err := tierutil.GetAssignableTier(r.Client, space.Namespace, config.Tiers().DefaultSpaceTier())
+		if err != nil && !errors.IsNotFound(err) {
+			return false, errs.Wrapf(err, "unable to set the TierName")
+		}
 		space.Spec.TierName = config.Tiers().DefaultSpaceTier()
-		logger.Info("TierName has been set", "tierName", config.Tiers().DefaultSpaceTier())
+		if err == nil {
+			space.Spec.TierName = tier.Name
+		}
+		logger.Info("TierName has been set", "tierName", space.Spec.TierName)
 		return true, nil
 	}
 
@@ -0,0 +1,39 @@
+package spaceexpiration
+
+import (
+	"context"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var mapperLog = ctrl.Log.WithName("MapNSTemplateTierToSpaces")
+
+// MapNSTemplateTierToSpaces maps the NSTemplateTier to all the Spaces using it,
+// so that a change in the expiration policy of the tier is applied to its Spaces
+func MapNSTemplateTierToSpaces(cl client.Client) func(object client.Object) []reconcile.Request {
+	return func(obj client.Object) []reconcile.Request {
+		logger := mapperLog.WithValues("object-name", obj.GetName(), "object-kind", obj.GetObjectKind())
+		spaces := &toolchainv1alpha1.SpaceList{}
+		if err := cl.List(context.TODO(), spaces, client.InNamespace(obj.GetNamespace())); err != nil {
+			logger.Error(err, "unable to list the Spaces")
+			return []reconcile.Request{}
+		}
+		req := []reconcile.Request{}
+		for _, item := range spaces.Items {
+			if item.Spec.TierName != obj.GetName() {
+				continue
+			}
+			req = append(req, reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Namespace: item.Namespace,
+					Name:      item.Name,
+				},
+			})
+		}
+		return req
+	}
+}
@@ -0,0 +1,72 @@
+package spaceexpiration
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	tiertest "github.com/codeready-toolchain/host-operator/test/nstemplatetier"
+	spacetest "github.com/codeready-toolchain/host-operator/test/space"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestMapNSTemplateTierToSpaces(t *testing.T) {
+	// given
+	basicTier := tiertest.BasicTier(t, tiertest.CurrentBasicTemplates)
+	oddity := spacetest.NewSpace("oddity", spacetest.WithTierName("basic"))
+	johnny := spacetest.NewSpace("johnny", spacetest.WithTierName("basic"))
+	other := spacetest.NewSpace("other", spacetest.WithTierName("advanced"))
+
+	t.Run("should return requests for the spaces of the tier", func(t *testing.T) {
+		// given
+		cl := test.NewFakeClient(t, oddity, johnny, other)
+
+		// when
+		requests := MapNSTemplateTierToSpaces(cl)(basicTier)
+
+		// then
+		require.Len(t, requests, 2)
+		assert.Contains(t, requests, newRequest(oddity.Name))
+		assert.Contains(t, requests, newRequest(johnny.Name))
+	})
+
+	t.Run("should return no request when no space uses the tier", func(t *testing.T) {
+		// given
+		cl := test.NewFakeClient(t, other)
+
+		// when
+		requests := MapNSTemplateTierToSpaces(cl)(basicTier)
+
+		// then
+		assert.Empty(t, requests)
+	})
+
+	t.Run("should return no request when listing spaces fails", func(t *testing.T) {
+		// given
+		cl := test.NewFakeClient(t, oddity)
+		cl.MockList = func(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+			return fmt.Errorf("some error")
+		}
+
+		// when
+		requests := MapNSTemplateTierToSpaces(cl)(basicTier)
+
+		// then
+		assert.Empty(t, requests)
+	})
+}
+
+func newRequest(name string) reconcile.Request {
+	return reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Namespace: test.HostOperatorNs,
+			Name:      name,
+		},
+	}
+}
@@ -0,0 +1,385 @@
+package spaceexpiration
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	notify "github.com/codeready-toolchain/host-operator/controllers/notification"
+	"github.com/codeready-toolchain/host-operator/controllers/space"
+	"github.com/codeready-toolchain/host-operator/controllers/toolchainconfig"
+	"github.com/codeready-toolchain/host-operator/pkg/templates/notificationtemplates"
+	commoncontrollers "github.com/codeready-toolchain/toolchain-common/controllers"
+	"github.com/codeready-toolchain/toolchain-common/pkg/condition"
+	"github.com/go-logr/logr"
+	"github.com/redhat-cop/operator-utils/pkg/util"
+
+	errs "github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+const (
+	// SpaceTTLAnnotationKey is the annotation holding the time-to-live of a Space (eg: `720h`), counted from its creation.
+	// It can be set on the Space itself or on its NSTemplateTier, in which case it applies to all Spaces of the tier.
+	// The value set on the Space takes precedence over the value set on the tier.
+	SpaceTTLAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "space-ttl"
+
+	// SpaceExpirationActionAnnotationKey is the annotation holding the action to perform once a Space expired,
+	// ie, `delete` (default) or `hibernate`. As for the TTL, it can be set on the Space or on its NSTemplateTier.
+	SpaceExpirationActionAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "space-expiration-action"
+
+	// ExpirationActionDelete the expired Space is deleted
+	ExpirationActionDelete = "delete"
+	// ExpirationActionHibernate the expired Space is hibernated, ie, its namespaces are deleted but the Space is retained
+	ExpirationActionHibernate = "hibernate"
+
+	// NotificationTypeSpaceExpiring the type of the notifications sent to the users bound to a Space which is about to expire
+	NotificationTypeSpaceExpiring = notify.NotificationTypeSpaceExpiring
+
+	// Status condition types
+	SpaceExpiringNotificationCreated toolchainv1alpha1.ConditionType = "ExpiringNotificationCreated"
+	SpaceExpired                     toolchainv1alpha1.ConditionType = "Expired"
+
+	// Status condition reasons
+	SpaceNotInPreExpirationReason                   = "NotInPreExpiration"
+	SpaceExpiringNotificationCRCreatedReason        = "NotificationCRCreated"
+	SpaceExpiringNotificationCRCreationFailedReason = "NotificationCRCreationFailed"
+	SpaceExpiredReason                              = "TTLElapsed"
+	SpaceExpirationFailedReason                     = "UnableToExpire"
+	notificationNameFmt                             = "%s-%s-" + NotificationTypeSpaceExpiring
+)
+
+// Reconciler reconciles the expiration of the Spaces which have a TTL
+type Reconciler struct {
+	Client    client.Client
+	Scheme    *runtime.Scheme
+	Namespace string
+}
+
+// SetupWithManager sets up the controller reconciler with the Manager
+// Watches the Space resources and their SpaceBindings, as well as the NSTemplateTiers which may hold the TTL of the Spaces
+func (r *Reconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("spaceexpiration").
+		For(&toolchainv1alpha1.Space{}, builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{}))).
+		Watches(
+			&source.Kind{Type: &toolchainv1alpha1.SpaceBinding{}},
+			handler.EnqueueRequestsFromMapFunc(commoncontrollers.MapToOwnerByLabel(r.Namespace, toolchainv1alpha1.SpaceBindingSpaceLabelKey))).
+		Watches(
+			&source.Kind{Type: &toolchainv1alpha1.NSTemplateTier{}},
+			handler.EnqueueRequestsFromMapFunc(MapNSTemplateTierToSpaces(r.Client)),
+			builder.WithPredicates(predicate.AnnotationChangedPredicate{})).
+		Complete(r)
+}
+
+//+kubebuilder:rbac:groups=toolchain.dev.openshift.com,resources=spaces,verbs=get;list;watch;update;patch;delete
+//+kubebuilder:rbac:groups=toolchain.dev.openshift.com,resources=spaces/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=toolchain.dev.openshift.com,resources=spacebindings,verbs=get;list;watch
+//+kubebuilder:rbac:groups=toolchain.dev.openshift.com,resources=nstemplatetiers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=toolchain.dev.openshift.com,resources=masteruserrecords,verbs=get;list;watch
+//+kubebuilder:rbac:groups=toolchain.dev.openshift.com,resources=usersignups,verbs=get;list;watch
+//+kubebuilder:rbac:groups=toolchain.dev.openshift.com,resources=notifications,verbs=get;list;watch;create
+
+// Reconcile notifies the users bound to a Space which is about to expire, and deletes or hibernates the Space once it expired.
+// As for the deactivation of the MasterUserRecords, the expiration happens (at least) `expiringNotificationDays` after
+// the users were notified, so that they always get the promised notice, even if the TTL was shortened in the meantime.
+func (r *Reconciler) Reconcile(ctx context.Context, request ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx, "namespace", r.Namespace)
+	logger.Info("reconciling Space expiration")
+
+	// Fetch the Space
+	s := &toolchainv1alpha1.Space{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{
+		Namespace: r.Namespace,
+		Name:      request.Name,
+	}, s)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			logger.Info("Space not found")
+			return reconcile.Result{}, nil
+		}
+		// Error reading the object - requeue the request.
+		return reconcile.Result{}, errs.Wrap(err, "unable to get the current Space")
+	}
+	// if is already being deleted, then skip it
+	if util.IsBeingDeleted(s) {
+		logger.Info("Space is already being deleted - skipping...")
+		return reconcile.Result{}, nil
+	}
+
+	ttl, action, err := r.expirationPolicy(s)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if ttl == 0 {
+		logger.Info("Space has no TTL")
+		return reconcile.Result{}, r.resetExpiration(logger, s)
+	}
+
+	config, err := toolchainconfig.GetToolchainConfig(r.Client)
+	if err != nil {
+		return reconcile.Result{}, errs.Wrapf(err, "unable to get ToolchainConfig")
+	}
+	expiringNotificationDays := time.Duration(config.Spaces().ExpiringNotificationDays()*24) * time.Hour
+	expirationTime := s.CreationTimestamp.Add(ttl)
+
+	if notificationTime := expirationTime.Add(-expiringNotificationDays); time.Now().Before(notificationTime) {
+		// It is not yet time to send the expiring notification so requeue until it will be time to send it.
+		// Also, if the TTL was extended after the notification was sent or the Space expired, then its expiration is reset.
+		requeueAfter := time.Until(notificationTime)
+		logger.Info("requeueing request", "RequeueAfter", requeueAfter, "Expected expiring notification date/time", notificationTime.String())
+		return reconcile.Result{RequeueAfter: requeueAfter}, r.resetExpiration(logger, s)
+	}
+
+	notifiedCondition, found := condition.FindConditionByType(s.Status.Conditions, SpaceExpiringNotificationCreated)
+	if !found || notifiedCondition.Status != corev1.ConditionTrue {
+		// the users are notified of the time when the Space will actually expire, ie, not before the number of days of the notice
+		if err := r.sendExpiringNotifications(logger, config, s, expirationDueTime(expirationTime, time.Now(), expiringNotificationDays)); err != nil {
+			return reconcile.Result{}, r.setStatusExpiringNotificationCreationFailed(logger, s, err)
+		}
+		if err := r.setStatusExpiringNotificationCreated(s); err != nil {
+			return reconcile.Result{}, err
+		}
+		// the expiration due time is computed from the time when the users were notified
+		notifiedCondition, _ = condition.FindConditionByType(s.Status.Conditions, SpaceExpiringNotificationCreated)
+	}
+
+	dueTime := expirationDueTime(expirationTime, notifiedCondition.LastTransitionTime.Time, expiringNotificationDays)
+	if time.Now().Before(dueTime) {
+		// It is not yet time to expire so requeue when it will be
+		requeueAfter := time.Until(dueTime)
+		logger.Info("requeueing request", "RequeueAfter", requeueAfter, "Expected expiration date/time", dueTime.String())
+		return reconcile.Result{RequeueAfter: requeueAfter}, nil
+	}
+
+	return reconcile.Result{}, r.expire(logger, s, action)
+}
+
+// expirationDueTime returns the time when the Space actually expires, given the time when its users were notified:
+// the expiration is postponed if the users were notified less than the given number of days before the expiration time
+func expirationDueTime(expirationTime, notifiedTime time.Time, expiringNotificationDays time.Duration) time.Time {
+	if dueTime := notifiedTime.Add(expiringNotificationDays); dueTime.After(expirationTime) {
+		return dueTime
+	}
+	return expirationTime
+}
+
+// expirationPolicy returns the TTL and the expiration action of the given Space, read from its annotations,
+// or from the annotations of its NSTemplateTier if they are not set on the Space.
+// Returns a `0` TTL if the Space does not expire
+func (r *Reconciler) expirationPolicy(s *toolchainv1alpha1.Space) (time.Duration, string, error) {
+	ttl, ttlFound := s.Annotations[SpaceTTLAnnotationKey]
+	action, actionFound := s.Annotations[SpaceExpirationActionAnnotationKey]
+	if (!ttlFound || !actionFound) && s.Spec.TierName != "" {
+		tier := &toolchainv1alpha1.NSTemplateTier{}
+		if err := r.Client.Get(context.TODO(), types.NamespacedName{
+			Namespace: r.Namespace,
+			Name:      s.Spec.TierName,
+		}, tier); err != nil && !errors.IsNotFound(err) {
+			return 0, "", errs.Wrapf(err, "unable to get the NSTemplateTier '%s'", s.Spec.TierName)
+		}
+		if !ttlFound {
+			ttl = tier.Annotations[SpaceTTLAnnotationKey]
+		}
+		if !actionFound {
+			action = tier.Annotations[SpaceExpirationActionAnnotationKey]
+		}
+	}
+	if ttl == "" {
+		return 0, "", nil
+	}
+	d, err := time.ParseDuration(ttl)
+	if err != nil {
+		return 0, "", errs.Wrapf(err, "invalid value of the '%s' annotation", SpaceTTLAnnotationKey)
+	}
+	switch action {
+	case "":
+		action = ExpirationActionDelete
+	case ExpirationActionDelete, ExpirationActionHibernate:
+	default:
+		return 0, "", fmt.Errorf("invalid value of the '%s' annotation: '%s'", SpaceExpirationActionAnnotationKey, action)
+	}
+	return d, action, nil
+}
+
+// sendExpiringNotifications creates an expiring notification for each user bound to the given Space.
+// The notifications have a deterministic name, so the users who were already notified are not notified again
+// when the creation of some other notification failed and is retried
+func (r *Reconciler) sendExpiringNotifications(logger logr.Logger, config toolchainconfig.ToolchainConfig, s *toolchainv1alpha1.Space, expirationDueTime time.Time) error {
+	bindings := &toolchainv1alpha1.SpaceBindingList{}
+	if err := r.Client.List(context.TODO(), bindings,
+		client.InNamespace(r.Namespace),
+		client.MatchingLabels{toolchainv1alpha1.SpaceBindingSpaceLabelKey: s.Name}); err != nil {
+		return errs.Wrap(err, "unable to list the SpaceBindings")
+	}
+	for _, binding := range bindings.Items {
+		userSignup, err := r.getUserSignup(binding.Spec.MasterUserRecord)
+		if err != nil {
+			return err
+		}
+		if userSignup == nil {
+			logger.Info("no UserSignup found for the SpaceBinding, skipping the expiring notification", "spacebinding", binding.Name)
+			continue
+		}
+		keysAndVals := map[string]string{
+			toolchainconfig.NotificationContextRegistrationURLKey: config.RegistrationService().RegistrationServiceURL(),
+			"SpaceName":      s.Name,
+			"ExpirationDate": expirationDueTime.UTC().Format("January 2, 2006 15:04 MST"),
+		}
+		notification, err := notify.NewNotificationBuilder(r.Client, r.Namespace).
+			WithName(fmt.Sprintf(notificationNameFmt, s.Name, binding.Spec.MasterUserRecord)).
+			WithTemplate(notificationtemplates.SpaceExpiring.Name).
+			WithNotificationType(NotificationTypeSpaceExpiring).
+			WithCategory(notify.NotificationCategoryReminders).
+			WithControllerReference(s, r.Scheme).
+			WithUserContext(userSignup).
+			WithKeysAndValues(keysAndVals).
+			Create(userSignup.Annotations[toolchainv1alpha1.UserSignupUserEmailAnnotationKey])
+		if err != nil {
+			if errors.IsAlreadyExists(err) {
+				continue
+			}
+			return errs.Wrapf(err, "unable to create the expiring notification for the MasterUserRecord '%s'", binding.Spec.MasterUserRecord)
+		}
+		logger.Info(fmt.Sprintf("Expiring notification resource [%s] created", notification.Name))
+	}
+	return nil
+}
+
+// getUserSignup returns the UserSignup which owns the MasterUserRecord with the given name,
+// or `nil` if the MasterUserRecord or the UserSignup does not exist
+func (r *Reconciler) getUserSignup(murName string) (*toolchainv1alpha1.UserSignup, error) {
+	mur := &toolchainv1alpha1.MasterUserRecord{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Namespace: r.Namespace, Name: murName}, mur); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, errs.Wrapf(err, "unable to get the MasterUserRecord '%s'", murName)
+	}
+	userSignupName, found := mur.Labels[toolchainv1alpha1.MasterUserRecordOwnerLabelKey]
+	if !found {
+		return nil, nil
+	}
+	userSignup := &toolchainv1alpha1.UserSignup{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Namespace: r.Namespace, Name: userSignupName}, userSignup); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, errs.Wrapf(err, "unable to get the UserSignup '%s'", userSignupName)
+	}
+	return userSignup, nil
+}
+
+// expire deletes or hibernates the given Space, depending on the given action
+func (r *Reconciler) expire(logger logr.Logger, s *toolchainv1alpha1.Space, action string) error {
+	if expired, found := condition.FindConditionByType(s.Status.Conditions, SpaceExpired); found && expired.Status == corev1.ConditionTrue &&
+		action == ExpirationActionHibernate && space.IsHibernated(s) {
+		// nothing left to do
+		return nil
+	}
+	if err := r.updateStatus(s, toolchainv1alpha1.Condition{
+		Type:    SpaceExpired,
+		Status:  corev1.ConditionTrue,
+		Reason:  SpaceExpiredReason,
+		Message: fmt.Sprintf("the Space is expired and is being processed with the '%s' action", action),
+	}); err != nil {
+		return err
+	}
+	switch action {
+	case ExpirationActionHibernate:
+		logger.Info("hibernating the expired Space")
+		if s.Annotations == nil {
+			s.Annotations = map[string]string{}
+		}
+		s.Annotations[space.HibernatedAnnotationKey] = "true"
+		if err := r.Client.Update(context.TODO(), s); err != nil {
+			return r.setStatusExpirationFailed(logger, s, err)
+		}
+	default:
+		logger.Info("deleting the expired Space")
+		if err := r.Client.Delete(context.TODO(), s); err != nil && !errors.IsNotFound(err) {
+			return r.setStatusExpirationFailed(logger, s, err)
+		}
+	}
+	return nil
+}
+
+// resetExpiration resets the expiration conditions of the given Space (if any), and resumes the Space if it was hibernated
+// because of its expiration
+func (r *Reconciler) resetExpiration(logger logr.Logger, s *toolchainv1alpha1.Space) error {
+	expired, found := condition.FindConditionByType(s.Status.Conditions, SpaceExpired)
+	if found && expired.Status == corev1.ConditionTrue && space.IsHibernated(s) {
+		logger.Info("resuming the Space which is no longer expired")
+		delete(s.Annotations, space.HibernatedAnnotationKey)
+		if err := r.Client.Update(context.TODO(), s); err != nil {
+			return err
+		}
+	}
+	var conditions []toolchainv1alpha1.Condition
+	for _, t := range []toolchainv1alpha1.ConditionType{SpaceExpiringNotificationCreated, SpaceExpired} {
+		if c, found := condition.FindConditionByType(s.Status.Conditions, t); found && c.Status != corev1.ConditionFalse {
+			conditions = append(conditions, toolchainv1alpha1.Condition{
+				Type:   t,
+				Status: corev1.ConditionFalse,
+				Reason: SpaceNotInPreExpirationReason,
+			})
+		}
+	}
+	return r.updateStatus(s, conditions...)
+}
+
+func (r *Reconciler) setStatusExpiringNotificationCreated(s *toolchainv1alpha1.Space) error {
+	return r.updateStatus(s, toolchainv1alpha1.Condition{
+		Type:   SpaceExpiringNotificationCreated,
+		Status: corev1.ConditionTrue,
+		Reason: SpaceExpiringNotificationCRCreatedReason,
+	})
+}
+
+func (r *Reconciler) setStatusExpiringNotificationCreationFailed(logger logr.Logger, s *toolchainv1alpha1.Space, cause error) error {
+	if err := r.updateStatus(s, toolchainv1alpha1.Condition{
+		Type:    SpaceExpiringNotificationCreated,
+		Status:  corev1.ConditionFalse,
+		Reason:  SpaceExpiringNotificationCRCreationFailedReason,
+		Message: cause.Error(),
+	}); err != nil {
+		logger.Error(cause, "unable to create the expiring notifications")
+		return err
+	}
+	return cause
+}
+
+func (r *Reconciler) setStatusExpirationFailed(logger logr.Logger, s *toolchainv1alpha1.Space, cause error) error {
+	if err := r.updateStatus(s, toolchainv1alpha1.Condition{
+		Type:    SpaceExpired,
+		Status:  corev1.ConditionFalse,
+		Reason:  SpaceExpirationFailedReason,
+		Message: cause.Error(),
+	}); err != nil {
+		logger.Error(cause, "unable to expire the Space")
+		return err
+	}
+	return cause
+}
+
+func (r *Reconciler) updateStatus(s *toolchainv1alpha1.Space, conditions ...toolchainv1alpha1.Condition) error {
+	var updated bool
+	s.Status.Conditions, updated = condition.AddOrUpdateStatusConditions(s.Status.Conditions, conditions...)
+	if !updated {
+		// Nothing changed
+		return nil
+	}
+	return r.Client.Status().Update(context.TODO(), s)
+}
@@ -0,0 +1,408 @@
+package spaceexpiration_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	notify "github.com/codeready-toolchain/host-operator/controllers/notification"
+	"github.com/codeready-toolchain/host-operator/controllers/space"
+	"github.com/codeready-toolchain/host-operator/controllers/spaceexpiration"
+	"github.com/codeready-toolchain/host-operator/pkg/apis"
+	. "github.com/codeready-toolchain/host-operator/test"
+	notificationtest "github.com/codeready-toolchain/host-operator/test/notification"
+	tiertest "github.com/codeready-toolchain/host-operator/test/nstemplatetier"
+	spacetest "github.com/codeready-toolchain/host-operator/test/space"
+	"github.com/codeready-toolchain/host-operator/test/spacebinding"
+	commonconfig "github.com/codeready-toolchain/toolchain-common/pkg/configuration"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	murtest "github.com/codeready-toolchain/toolchain-common/pkg/test/masteruserrecord"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const day = 24 * time.Hour
+
+func TestSpaceExpiration(t *testing.T) {
+
+	config := commonconfig.NewToolchainConfigObjWithReset(t, SpaceExpiringNotificationDays(3))
+
+	t.Run("without TTL", func(t *testing.T) {
+		// given
+		s := spacetest.NewSpace("oddity", spacetest.CreatedBefore(365*day))
+		r, req, cl := prepareReconcile(t, s, config)
+
+		// when
+		res, err := r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, reconcile.Result{}, res)
+		spacetest.AssertThatSpace(t, test.HostOperatorNs, s.Name, cl).
+			Exists().
+			HasNoConditions()
+		notificationtest.AssertNoNotificationsExist(t, cl)
+	})
+
+	t.Run("before the expiring notification", func(t *testing.T) {
+		// given
+		s := spacetest.NewSpace("oddity",
+			spacetest.CreatedBefore(5*day),
+			spacetest.WithAnnotation(spaceexpiration.SpaceTTLAnnotationKey, "240h"))
+		r, req, cl := prepareReconcile(t, s, config)
+
+		// when
+		res, err := r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		// notification is due 10-3-5=2 days from now
+		assert.InDelta(t, float64(2*day), float64(res.RequeueAfter), float64(time.Minute))
+		spacetest.AssertThatSpace(t, test.HostOperatorNs, s.Name, cl).
+			Exists().
+			HasNoConditions()
+		notificationtest.AssertNoNotificationsExist(t, cl)
+	})
+
+	t.Run("expiring notifications sent to all bound users", func(t *testing.T) {
+		// given
+		s := spacetest.NewSpace("oddity",
+			spacetest.CreatedBefore(8*day),
+			spacetest.WithAnnotation(spaceexpiration.SpaceTTLAnnotationKey, "240h"))
+		r, req, cl := prepareReconcile(t, s, append(boundUsers(t, s, "lara", "joe"), config,
+			spacebinding.NewSpaceBinding("unknown", s.Name, "view"))...) // no MUR for this binding
+
+		// when
+		res, err := r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		// users were just notified, so the expiration is due 3 days from now
+		assert.InDelta(t, float64(3*day), float64(res.RequeueAfter), float64(time.Minute))
+		spacetest.AssertThatSpace(t, test.HostOperatorNs, s.Name, cl).
+			Exists().
+			HasConditions(expiringNotificationCreated())
+		for _, username := range []string{"lara", "joe"} {
+			notificationtest.OnlyOneNotificationExists(t, cl, username, spaceexpiration.NotificationTypeSpaceExpiring,
+				notificationtest.HasContext("SpaceName", "oddity"),
+				notificationtest.HasContext("UserEmail", username+"@redhat.com"),
+				notificationtest.HasAnnotation(notify.CategoryAnnotationKey, notify.NotificationCategoryReminders),
+				// the TTL elapses in 2 days, but the users are given 3 days of notice
+				hasExpirationDate(time.Now().Add(3*day)))
+		}
+
+		t.Run("not sent again", func(t *testing.T) {
+			// when
+			_, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			for _, username := range []string{"lara", "joe"} {
+				notificationtest.OnlyOneNotificationExists(t, cl, username, spaceexpiration.NotificationTypeSpaceExpiring)
+			}
+		})
+	})
+
+	t.Run("expiration postponed when the users were notified late", func(t *testing.T) {
+		// given
+		s := spacetest.NewSpace("oddity",
+			spacetest.CreatedBefore(11*day), // TTL elapsed 1 day ago
+			spacetest.WithAnnotation(spaceexpiration.SpaceTTLAnnotationKey, "240h"),
+			spacetest.WithCondition(expiringNotificationCreatedBefore(day)))
+		r, req, cl := prepareReconcile(t, s, config)
+
+		// when
+		res, err := r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assert.InDelta(t, float64(2*day), float64(res.RequeueAfter), float64(time.Minute))
+		spacetest.AssertThatSpace(t, test.HostOperatorNs, s.Name, cl).
+			Exists().
+			HasConditions(expiringNotificationCreatedBefore(day))
+	})
+
+	t.Run("expired Space is deleted", func(t *testing.T) {
+		// given
+		s := spacetest.NewSpace("oddity",
+			spacetest.CreatedBefore(11*day),
+			spacetest.WithAnnotation(spaceexpiration.SpaceTTLAnnotationKey, "240h"),
+			spacetest.WithCondition(expiringNotificationCreatedBefore(4*day)))
+		r, req, cl := prepareReconcile(t, s, config)
+
+		// when
+		res, err := r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, reconcile.Result{}, res)
+		spacetest.AssertThatSpace(t, test.HostOperatorNs, s.Name, cl).
+			DoesNotExist()
+	})
+
+	t.Run("expired Space is hibernated with the policy of the tier", func(t *testing.T) {
+		// given
+		tier := tiertest.BasicTier(t, tiertest.CurrentBasicTemplates)
+		tier.Annotations = map[string]string{
+			spaceexpiration.SpaceTTLAnnotationKey:              "240h",
+			spaceexpiration.SpaceExpirationActionAnnotationKey: spaceexpiration.ExpirationActionHibernate,
+		}
+		s := spacetest.NewSpace("oddity",
+			spacetest.WithTierName(tier.Name),
+			spacetest.CreatedBefore(11*day),
+			spacetest.WithCondition(expiringNotificationCreatedBefore(4*day)))
+		r, req, cl := prepareReconcile(t, s, config, tier)
+
+		// when
+		res, err := r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, reconcile.Result{}, res)
+		spacetest.AssertThatSpace(t, test.HostOperatorNs, s.Name, cl).
+			Exists().
+			HasAnnotation(space.HibernatedAnnotationKey, "true").
+			HasConditions(expiringNotificationCreatedBefore(4*day), expired())
+
+		t.Run("hibernated Space is resumed when TTL is extended", func(t *testing.T) {
+			// given
+			hibernated := spacetest.AssertThatSpace(t, test.HostOperatorNs, s.Name, cl).Get()
+			hibernated.Annotations[spaceexpiration.SpaceTTLAnnotationKey] = "720h" // overrides the TTL of the tier
+			err := cl.Update(context.TODO(), hibernated)
+			require.NoError(t, err)
+
+			// when
+			res, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			assert.InDelta(t, float64(16*day), float64(res.RequeueAfter), float64(time.Minute))
+			spacetest.AssertThatSpace(t, test.HostOperatorNs, s.Name, cl).
+				Exists().
+				DoesNotHaveAnnotation(space.HibernatedAnnotationKey).
+				HasConditions(notInPreExpiration(spaceexpiration.SpaceExpiringNotificationCreated), notInPreExpiration(spaceexpiration.SpaceExpired))
+		})
+	})
+
+	t.Run("Space hibernated by an admin is not resumed", func(t *testing.T) {
+		// given
+		s := spacetest.NewSpace("oddity",
+			spacetest.CreatedBefore(day),
+			spacetest.WithAnnotation(space.HibernatedAnnotationKey, "true"))
+		r, req, cl := prepareReconcile(t, s, config)
+
+		// when
+		_, err := r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		spacetest.AssertThatSpace(t, test.HostOperatorNs, s.Name, cl).
+			HasAnnotation(space.HibernatedAnnotationKey, "true").
+			HasNoConditions()
+	})
+
+	t.Run("when is already being deleted", func(t *testing.T) {
+		// given
+		s := spacetest.NewSpace("oddity",
+			spacetest.WithDeletionTimestamp(),
+			spacetest.CreatedBefore(11*day),
+			spacetest.WithAnnotation(spaceexpiration.SpaceTTLAnnotationKey, "240h"))
+		r, req, cl := prepareReconcile(t, s, config)
+
+		// when
+		res, err := r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, reconcile.Result{}, res)
+		spacetest.AssertThatSpace(t, test.HostOperatorNs, s.Name, cl).
+			HasNoConditions()
+	})
+
+	t.Run("failures", func(t *testing.T) {
+
+		t.Run("invalid TTL", func(t *testing.T) {
+			// given
+			s := spacetest.NewSpace("oddity", spacetest.WithAnnotation(spaceexpiration.SpaceTTLAnnotationKey, "10 days"))
+			r, req, _ := prepareReconcile(t, s, config)
+
+			// when
+			_, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.EqualError(t, err, `invalid value of the 'toolchain.dev.openshift.com/space-ttl' annotation: time: unknown unit " days" in duration "10 days"`)
+		})
+
+		t.Run("invalid action", func(t *testing.T) {
+			// given
+			s := spacetest.NewSpace("oddity",
+				spacetest.WithAnnotation(spaceexpiration.SpaceTTLAnnotationKey, "240h"),
+				spacetest.WithAnnotation(spaceexpiration.SpaceExpirationActionAnnotationKey, "archive"))
+			r, req, _ := prepareReconcile(t, s, config)
+
+			// when
+			_, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.EqualError(t, err, "invalid value of the 'toolchain.dev.openshift.com/space-expiration-action' annotation: 'archive'")
+		})
+
+		t.Run("when getting NSTemplateTier fails", func(t *testing.T) {
+			// given
+			s := spacetest.NewSpace("oddity")
+			r, req, cl := prepareReconcile(t, s, config)
+			cl.MockGet = func(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+				if _, ok := obj.(*toolchainv1alpha1.NSTemplateTier); ok {
+					return fmt.Errorf("some error")
+				}
+				return cl.Client.Get(ctx, key, obj)
+			}
+
+			// when
+			_, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.EqualError(t, err, "unable to get the NSTemplateTier 'basic': some error")
+		})
+
+		t.Run("when creating notification fails", func(t *testing.T) {
+			// given
+			s := spacetest.NewSpace("oddity",
+				spacetest.CreatedBefore(8*day),
+				spacetest.WithAnnotation(spaceexpiration.SpaceTTLAnnotationKey, "240h"))
+			r, req, cl := prepareReconcile(t, s, append(boundUsers(t, s, "lara"), config)...)
+			cl.MockCreate = func(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+				if _, ok := obj.(*toolchainv1alpha1.Notification); ok {
+					return fmt.Errorf("some error")
+				}
+				return cl.Client.Create(ctx, obj, opts...)
+			}
+
+			// when
+			_, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.EqualError(t, err, "unable to create the expiring notification for the MasterUserRecord 'lara': some error")
+			spacetest.AssertThatSpace(t, test.HostOperatorNs, s.Name, cl).
+				HasConditions(toolchainv1alpha1.Condition{
+					Type:    spaceexpiration.SpaceExpiringNotificationCreated,
+					Status:  corev1.ConditionFalse,
+					Reason:  spaceexpiration.SpaceExpiringNotificationCRCreationFailedReason,
+					Message: "unable to create the expiring notification for the MasterUserRecord 'lara': some error",
+				})
+		})
+
+		t.Run("when deleting Space fails", func(t *testing.T) {
+			// given
+			s := spacetest.NewSpace("oddity",
+				spacetest.CreatedBefore(11*day),
+				spacetest.WithAnnotation(spaceexpiration.SpaceTTLAnnotationKey, "240h"),
+				spacetest.WithCondition(expiringNotificationCreatedBefore(4*day)))
+			r, req, cl := prepareReconcile(t, s, config)
+			cl.MockDelete = func(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+				return fmt.Errorf("some error")
+			}
+
+			// when
+			_, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.EqualError(t, err, "some error")
+			spacetest.AssertThatSpace(t, test.HostOperatorNs, s.Name, cl).
+				Exists().
+				HasConditions(expiringNotificationCreatedBefore(4*day), toolchainv1alpha1.Condition{
+					Type:    spaceexpiration.SpaceExpired,
+					Status:  corev1.ConditionFalse,
+					Reason:  spaceexpiration.SpaceExpirationFailedReason,
+					Message: "some error",
+				})
+		})
+	})
+}
+
+// boundUsers returns a SpaceBinding, a MasterUserRecord and a UserSignup for each given user
+func boundUsers(t *testing.T, s *toolchainv1alpha1.Space, usernames ...string) []runtime.Object {
+	objs := []runtime.Object{}
+	for _, username := range usernames {
+		userSignup := NewUserSignup(WithName(username), WithEmail(username+"@redhat.com"))
+		userSignup.Status.CompliantUsername = username
+		objs = append(objs,
+			userSignup,
+			murtest.NewMasterUserRecord(t, username, murtest.WithOwnerLabel(userSignup.Name)),
+			spacebinding.NewSpaceBinding(username, s.Name, "admin"))
+	}
+	return objs
+}
+
+// hasExpirationDate checks that the expiration date in the context of the notification is the given time, give or take a minute
+func hasExpirationDate(expected time.Time) notificationtest.Assert {
+	return func(t test.T, notification toolchainv1alpha1.Notification) {
+		actual, err := time.Parse("January 2, 2006 15:04 MST", notification.Spec.Context["ExpirationDate"])
+		require.NoError(t, err)
+		assert.WithinDuration(t, expected, actual, time.Minute)
+	}
+}
+
+func expiringNotificationCreated() toolchainv1alpha1.Condition {
+	return toolchainv1alpha1.Condition{
+		Type:   spaceexpiration.SpaceExpiringNotificationCreated,
+		Status: corev1.ConditionTrue,
+		Reason: spaceexpiration.SpaceExpiringNotificationCRCreatedReason,
+	}
+}
+
+func expiringNotificationCreatedBefore(before time.Duration) toolchainv1alpha1.Condition {
+	c := expiringNotificationCreated()
+	c.LastTransitionTime = metav1.NewTime(time.Now().Add(-before))
+	return c
+}
+
+func expired() toolchainv1alpha1.Condition {
+	return toolchainv1alpha1.Condition{
+		Type:    spaceexpiration.SpaceExpired,
+		Status:  corev1.ConditionTrue,
+		Reason:  spaceexpiration.SpaceExpiredReason,
+		Message: "the Space is expired and is being processed with the 'hibernate' action",
+	}
+}
+
+func notInPreExpiration(t toolchainv1alpha1.ConditionType) toolchainv1alpha1.Condition {
+	return toolchainv1alpha1.Condition{
+		Type:   t,
+		Status: corev1.ConditionFalse,
+		Reason: spaceexpiration.SpaceNotInPreExpirationReason,
+	}
+}
+
+func prepareReconcile(t *testing.T, s *toolchainv1alpha1.Space, initObjs ...runtime.Object) (*spaceexpiration.Reconciler, reconcile.Request, *test.FakeClient) {
+	require.NoError(t, os.Setenv("WATCH_NAMESPACE", test.HostOperatorNs))
+	sch := scheme.Scheme
+	err := apis.AddToScheme(sch)
+	require.NoError(t, err)
+
+	fakeClient := test.NewFakeClient(t, append(initObjs, s)...)
+
+	r := &spaceexpiration.Reconciler{
+		Client:    fakeClient,
+		Scheme:    sch,
+		Namespace: test.HostOperatorNs,
+	}
+	req := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Namespace: test.HostOperatorNs,
+			Name:      s.Name,
+		},
+	}
+	return r, req, fakeClient
+}
@@ -2,12 +2,16 @@ package templateupdaterequest
 
 import (
 	"context"
+	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	notify "github.com/codeready-toolchain/host-operator/controllers/notification"
 	tierutil "github.com/codeready-toolchain/host-operator/controllers/nstemplatetier/util"
 	"github.com/codeready-toolchain/host-operator/controllers/toolchainconfig"
+	"github.com/codeready-toolchain/host-operator/pkg/templates/notificationtemplates"
 	"github.com/codeready-toolchain/toolchain-common/pkg/condition"
 	"github.com/go-logr/logr"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
@@ -20,6 +24,7 @@ import (
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
@@ -27,6 +32,14 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
+const (
+	// NotificationTypeTierMigrated is the type of the notifications sent to the users whose MasterUserRecord is migrated from a deprecated tier
+	NotificationTypeTierMigrated = notify.NotificationTypeTierMigrated
+
+	// notificationNameFmt the format of the name of the tier migrated notification: `<mur>-tier-migrated-<successor tier>`
+	notificationNameFmt = "%s-tier-migrated-%s"
+)
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *Reconciler) SetupWithManager(mgr manager.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
@@ -90,9 +103,24 @@ func (r *Reconciler) handleSpaceUpdate(logger logr.Logger, request ctrl.Request,
 		return reconcile.Result{}, errs.Wrap(err, "unable to get the Space associated with the TemplateUpdateRequest")
 	}
 
+	// when migrating from a deprecated tier, switch the Space to the successor tier (and let the SpaceController set the hash label of the new tier)
+	fromTier, migrating := tur.Annotations[tierutil.MigrateFromAnnotationKey]
+	if migrating && space.Spec.TierName == fromTier {
+		logger.Info("migrating the Space to the successor of its deprecated tier", "from", fromTier, "to", tur.Spec.TierName)
+		delete(space.Labels, tierutil.TemplateTierHashLabelKey(fromTier))
+		delete(space.Labels, tierutil.TemplateTierDeprecatedLabelKey(fromTier))
+		space.Spec.TierName = tur.Spec.TierName
+		if err := r.Client.Update(context.TODO(), space); err != nil {
+			logger.Error(err, "Unable to migrate the Space associated with the TemplateUpdateRequest")
+			return reconcile.Result{}, errs.Wrap(err, "unable to migrate the Space associated with the TemplateUpdateRequest")
+		}
+	}
+
 	labelKey := tierutil.TemplateTierHashLabelKey(space.Spec.TierName)
 	// if the tier hash has changed and the Space is in ready state then the update is complete
-	if tur.Spec.CurrentTierHash != space.Labels[labelKey] && condition.IsTrue(space.Status.Conditions, toolchainv1alpha1.ConditionReady) {
+	// (when migrating, the hash label of the successor tier must have been set)
+	if tur.Spec.CurrentTierHash != space.Labels[labelKey] && (!migrating || space.Labels[labelKey] != "") &&
+		condition.IsTrue(space.Status.Conditions, toolchainv1alpha1.ConditionReady) {
 		// once the Space is up-to-date, we can delete this TemplateUpdateRequest
 		logger.Info("Space is up-to-date. Marking the TemplateUpdateRequest as complete")
 		return reconcile.Result{}, r.setCompleteStatusCondition(tur)
@@ -131,8 +159,13 @@ func (r *Reconciler) handleMURUpdate(logger logr.Logger, request ctrl.Request, t
 		// then we should update the associated MasterUserRecord
 		// and retain its current syncIndexex in the status
 		// NOTE: indexes need to be "captured" before updating the MURs
-		syncIndexes := syncIndexes(tur.Spec.TierName, *mur)
-		if err := r.updateTemplateRefs(logger, *tur, mur); err != nil {
+		syncIndexes := syncIndexes(sourceTierName(*tur), *mur)
+		if err := r.sendTierMigratedNotification(logger, config, *tur, *mur); err != nil {
+			logger.Error(err, "Unable to create the tier migrated notification")
+			return reconcile.Result{}, errs.Wrap(err, "unable to create the tier migrated notification")
+		}
+		refsChanged, err := r.updateTemplateRefs(logger, *tur, mur)
+		if err != nil {
 			// we want to give ourselves a few chances before marking this MasterUserRecord update as "failed":
 			logger.Error(err, "Unable to update the MasterUserRecord associated with the TemplateUpdateRequest")
 			err = errs.Wrap(err, "unable to update the MasterUserRecord associated with the TemplateUpdateRequest")
@@ -149,6 +182,11 @@ func (r *Reconciler) handleMURUpdate(logger logr.Logger, request ctrl.Request, t
 			logger.Info("Retaining the failure in the TemplateUpdateRequest 'status.conditions'")
 			return reconcile.Result{Requeue: true, RequeueAfter: 5 * time.Second}, err
 		}
+		if !refsChanged && len(syncIndexes) > 0 {
+			// only the tier hash label was updated (eg, when the tier `deactivationTimeoutDays` changed), so there's nothing to wait for
+			logger.Info("MasterUserRecord templateRefs were already up-to-date. Marking the TemplateUpdateRequest as complete")
+			return reconcile.Result{}, r.setCompleteStatusCondition(tur)
+		}
 		// update the TemplateUpdateRequest status and requeue to keep tracking the MUR changes
 		logger.Info("MasterUserRecord update started. Updating TemplateUpdateRequest status accordingly")
 		if err = r.addUpdatingStatusCondition(tur, syncIndexes); err != nil {
@@ -178,11 +216,27 @@ func maxUpdateFailuresReached(tur toolchainv1alpha1.TemplateUpdateRequest, thres
 		toolchainv1alpha1.TemplateUpdateRequestUnableToUpdateReason) >= threshod
 }
 
-func (r Reconciler) updateTemplateRefs(logger logr.Logger, tur toolchainv1alpha1.TemplateUpdateRequest, mur *toolchainv1alpha1.MasterUserRecord) error {
+func (r Reconciler) updateTemplateRefs(logger logr.Logger, tur toolchainv1alpha1.TemplateUpdateRequest, mur *toolchainv1alpha1.MasterUserRecord) (bool, error) {
+	// the tier is needed to compute the hash label, but the templateRefs can be updated even if it was deleted in the mean time
+	tier := &toolchainv1alpha1.NSTemplateTier{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Namespace: tur.Namespace, Name: tur.Spec.TierName}, tier); err != nil {
+		if !errors.IsNotFound(err) {
+			return false, err
+		}
+		tier = nil
+	}
+	fromTier := sourceTierName(tur)
+	// when migrating from a deprecated tier, the user accounts are switched to the successor tier
+	changed := fromTier != tur.Spec.TierName
 	// update MasterUserRecord accounts whose tier matches the TemplateUpdateRequest
 	for i, ua := range mur.Spec.UserAccounts {
-		if ua.Spec.NSTemplateSet != nil && ua.Spec.NSTemplateSet.TierName == tur.Spec.TierName {
+		if ua.Spec.NSTemplateSet != nil && ua.Spec.NSTemplateSet.TierName == fromTier {
+			previousHash, err := tierutil.ComputeHashForNSTemplateSetSpec(*ua.Spec.NSTemplateSet)
+			if err != nil {
+				return false, err
+			}
 			logger.Info("updating templaterefs", "tier", tur.Spec.TierName, "target_cluster", ua.TargetCluster)
+			ua.Spec.NSTemplateSet.TierName = tur.Spec.TierName
 			namespaces := make(map[string]toolchainv1alpha1.NSTemplateSetNamespace, len(ua.Spec.NSTemplateSet.Namespaces))
 			// now, add the new templateRefs, unless there's a custom template in use
 			for _, ns := range tur.Spec.Namespaces {
@@ -204,16 +258,93 @@ func (r Reconciler) updateTemplateRefs(logger logr.Logger, tur toolchainv1alpha1
 			}
 			mur.Spec.UserAccounts[i] = ua
 			// also, update the tier template hash label
-			hash, err := tierutil.ComputeHashForNSTemplateSetSpec(*ua.Spec.NSTemplateSet)
+			refsHash, err := tierutil.ComputeHashForNSTemplateSetSpec(*ua.Spec.NSTemplateSet)
+			if err != nil {
+				return false, err
+			}
+			changed = changed || refsHash != previousHash
+			hash, err := tierutil.ComputeHashForNSTemplateSet(tier, *ua.Spec.NSTemplateSet)
 			if err != nil {
-				return err
+				return false, err
 			}
 			mur.Labels[tierutil.TemplateTierHashLabelKey(tur.Spec.TierName)] = hash
 		}
 	}
+	if fromTier != tur.Spec.TierName {
+		logger.Info("migrating the MUR to the successor of its deprecated tier", "from", fromTier, "to", tur.Spec.TierName)
+		delete(mur.Labels, tierutil.TemplateTierHashLabelKey(fromTier))
+		delete(mur.Labels, tierutil.TemplateTierDeprecatedLabelKey(fromTier))
+		if mur.Spec.TierName == fromTier {
+			mur.Spec.TierName = tur.Spec.TierName
+		}
+	}
 	logger.Info("updating the MUR")
-	return r.Client.Update(context.TODO(), mur)
+	return changed, r.Client.Update(context.TODO(), mur)
+
+}
 
+// sendTierMigratedNotification creates the notification informing the owner of the given MasterUserRecord that it is migrated from
+// a deprecated tier to its successor, if the TemplateUpdateRequest is a migration and the notification is enabled on the deprecated tier.
+// The notification has a predictable name, so it is created only once even if the MasterUserRecord update is retried.
+func (r *Reconciler) sendTierMigratedNotification(logger logr.Logger, config toolchainconfig.ToolchainConfig, tur toolchainv1alpha1.TemplateUpdateRequest, mur toolchainv1alpha1.MasterUserRecord) error {
+	fromTier, migrating := tur.Annotations[tierutil.MigrateFromAnnotationKey]
+	if !migrating || len(syncIndexes(fromTier, mur)) == 0 {
+		return nil
+	}
+	tier := &toolchainv1alpha1.NSTemplateTier{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Namespace: tur.Namespace, Name: fromTier}, tier); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return errs.Wrapf(err, "unable to get the deprecated tier '%s'", fromTier)
+	}
+	if enabled, _ := strconv.ParseBool(tier.Annotations[tierutil.DeprecationNotificationAnnotationKey]); !enabled {
+		return nil
+	}
+	userSignupName, found := mur.Labels[toolchainv1alpha1.MasterUserRecordOwnerLabelKey]
+	if !found {
+		logger.Info("no UserSignup owning the MasterUserRecord, skipping the tier migrated notification")
+		return nil
+	}
+	userSignup := &toolchainv1alpha1.UserSignup{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Namespace: tur.Namespace, Name: userSignupName}, userSignup); err != nil {
+		if errors.IsNotFound(err) {
+			logger.Info("no UserSignup owning the MasterUserRecord, skipping the tier migrated notification")
+			return nil
+		}
+		return errs.Wrapf(err, "unable to get the UserSignup '%s'", userSignupName)
+	}
+	keysAndVals := map[string]string{
+		toolchainconfig.NotificationContextRegistrationURLKey: config.RegistrationService().RegistrationServiceURL(),
+		toolchainconfig.NotificationContextSupportURLKey:      config.Notifications().SupportURL(),
+		"OldTierName": fromTier,
+		"NewTierName": tur.Spec.TierName,
+	}
+	notification, err := notify.NewNotificationBuilder(r.Client, userSignup.Namespace).
+		WithName(fmt.Sprintf(notificationNameFmt, mur.Name, tur.Spec.TierName)).
+		WithTemplate(notificationtemplates.TierChanged.Name).
+		WithNotificationType(NotificationTypeTierMigrated).
+		WithControllerReference(userSignup, r.Scheme).
+		WithUserContext(userSignup).
+		WithKeysAndValues(keysAndVals).
+		Create(userSignup.Annotations[toolchainv1alpha1.UserSignupUserEmailAnnotationKey])
+	if err != nil {
+		if errors.IsAlreadyExists(err) {
+			return nil
+		}
+		return err
+	}
+	logger.Info(fmt.Sprintf("Tier migrated notification resource [%s] created", notification.Name))
+	return nil
+}
+
+// sourceTierName returns the name of the tier of the user accounts to update: the deprecated tier when the TemplateUpdateRequest
+// migrates the MasterUserRecord to a successor tier, otherwise the tier of the TemplateUpdateRequest
+func sourceTierName(tur toolchainv1alpha1.TemplateUpdateRequest) string {
+	if fromTier, found := tur.Annotations[tierutil.MigrateFromAnnotationKey]; found {
+		return fromTier
+	}
+	return tur.Spec.TierName
 }
 
 // extract the type from the given templateRef
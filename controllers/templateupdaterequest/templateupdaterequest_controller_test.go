@@ -11,6 +11,9 @@ import (
 	tierutil "github.com/codeready-toolchain/host-operator/controllers/nstemplatetier/util"
 	"github.com/codeready-toolchain/host-operator/controllers/templateupdaterequest"
 	"github.com/codeready-toolchain/host-operator/pkg/apis"
+	. "github.com/codeready-toolchain/host-operator/test"
+	hostmurtest "github.com/codeready-toolchain/host-operator/test/masteruserrecord"
+	notificationtest "github.com/codeready-toolchain/host-operator/test/notification"
 	tiertest "github.com/codeready-toolchain/host-operator/test/nstemplatetier"
 	spacetest "github.com/codeready-toolchain/host-operator/test/space"
 	turtest "github.com/codeready-toolchain/host-operator/test/templateupdaterequest"
@@ -61,8 +64,9 @@ func TestReconcile(t *testing.T) {
 				require.Equal(t, reconcile.Result{}, res) // no need to requeue, the MUR is watched
 				// check that the MasterUserRecord was updated
 				murtest.AssertThatMasterUserRecord(t, "user-1", cl).
-					HasTier(*basicTier).
-					AllUserAccountsHaveTier(*basicTier)
+					HasTier(*basicTier)
+				hostmurtest.AssertThatMasterUserRecord(t, "user-1", cl).
+					AllUserAccountsHaveTier(basicTier)
 				// check that TemplateUpdateRequest is in "updating" condition
 				turtest.AssertThatTemplateUpdateRequest(t, "user-1", cl).
 					HasConditions(templateupdaterequest.ToBeUpdating()).
@@ -89,8 +93,9 @@ func TestReconcile(t *testing.T) {
 				require.Equal(t, reconcile.Result{}, res) // no need to requeue, the MUR is watched
 				// check that the MasterUserRecord was updated
 				murtest.AssertThatMasterUserRecord(t, "user-1", cl).
-					HasTier(*basicTier).
-					AllUserAccountsHaveTier(*basicTier)
+					HasTier(*basicTier)
+				hostmurtest.AssertThatMasterUserRecord(t, "user-1", cl).
+					AllUserAccountsHaveTier(basicTier)
 				// check that TemplateUpdateRequest is in "updating" condition
 				turtest.AssertThatTemplateUpdateRequest(t, "user-1", cl).
 					HasConditions(templateupdaterequest.ToBeUpdating()).
@@ -116,8 +121,9 @@ func TestReconcile(t *testing.T) {
 				require.Equal(t, reconcile.Result{}, res) // no need to requeue, the MUR is watched
 				// check that the MasterUserRecord was updated
 				murtest.AssertThatMasterUserRecord(t, "user-1", cl).
-					HasTier(*basicTier).
-					AllUserAccountsHaveTier(*basicTier)
+					HasTier(*basicTier)
+				hostmurtest.AssertThatMasterUserRecord(t, "user-1", cl).
+					AllUserAccountsHaveTier(basicTier)
 				// check that TemplateUpdateRequest is in "updating" condition
 				turtest.AssertThatTemplateUpdateRequest(t, "user-1", cl).
 					HasConditions(templateupdaterequest.ToBeUpdating()).
@@ -143,8 +149,9 @@ func TestReconcile(t *testing.T) {
 				require.Equal(t, reconcile.Result{}, res) // no need to requeue, the MUR is watched
 				// check that the MasterUserRecord was updated
 				murtest.AssertThatMasterUserRecord(t, "user-1", cl).
-					HasTier(*basicTier).
-					AllUserAccountsHaveTier(*basicTier)
+					HasTier(*basicTier)
+				hostmurtest.AssertThatMasterUserRecord(t, "user-1", cl).
+					AllUserAccountsHaveTier(basicTier)
 				// check that TemplateUpdateRequest is in "updating" condition
 				turtest.AssertThatTemplateUpdateRequest(t, "user-1", cl).
 					HasConditions(templateupdaterequest.ToBeUpdating()).
@@ -175,6 +182,28 @@ func TestReconcile(t *testing.T) {
 					HasConditions(templateupdaterequest.ToBeUpdating()).
 					HasNoSyncIndexes() // no sync
 			})
+
+			t.Run("when only the content of the tier changed", func(t *testing.T) {
+				// given
+				basicTier := tiertest.BasicTier(t, tiertest.CurrentBasicTemplates, tiertest.WithCurrentUpdateInProgress())
+				basicTier.Spec.DeactivationTimeoutDays = 60
+				initObjs := []runtime.Object{basicTier}
+				initObjs = append(initObjs, murtest.NewMasterUserRecord(t, "user-1",
+					murtest.Account("cluster1", *basicTier, murtest.SyncIndex("1"))))
+				initObjs = append(initObjs, turtest.NewTemplateUpdateRequest("user-1", *basicTier))
+				r, req, cl := prepareReconcile(t, initObjs...)
+				// when
+				res, err := r.Reconcile(context.TODO(), req)
+				// then
+				require.NoError(t, err)
+				require.Equal(t, reconcile.Result{}, res)
+				// check that the hash label of the MasterUserRecord was updated
+				hostmurtest.AssertThatMasterUserRecord(t, "user-1", cl).
+					AllUserAccountsHaveTier(basicTier)
+				// check that TemplateUpdateRequest is complete, since there are no templates to update
+				turtest.AssertThatTemplateUpdateRequest(t, "user-1", cl).
+					HasConditions(templateupdaterequest.ToBeComplete())
+			})
 		})
 
 		t.Run("when there are many target clusters to update", func(t *testing.T) {
@@ -198,9 +227,10 @@ func TestReconcile(t *testing.T) {
 				require.Equal(t, reconcile.Result{}, res) // no need to requeue, the MUR is watched
 				// check that the MasterUserRecord was updated
 				murtest.AssertThatMasterUserRecord(t, "user-1", cl).
-					UserAccountHasTier("cluster1", *basicTier).
-					UserAccountHasTier("cluster2", *basicTier).
 					UserAccountHasTier("cluster3", *otherTier)
+				hostmurtest.AssertThatMasterUserRecord(t, "user-1", cl).
+					UserAccountHasTier("cluster1", basicTier).
+					UserAccountHasTier("cluster2", basicTier)
 				// check that TemplateUpdateRequest is in "updating" condition
 				turtest.AssertThatTemplateUpdateRequest(t, "user-1", cl).
 					HasConditions(templateupdaterequest.ToBeUpdating()).
@@ -734,6 +764,138 @@ func TestReconcile(t *testing.T) {
 	})
 }
 
+func TestMigrateFromDeprecatedTier(t *testing.T) {
+
+	// given
+	logf.SetLogger(zap.New(zap.UseDevMode(true)))
+	basicTier := tiertest.BasicTier(t, tiertest.CurrentBasicTemplates, tiertest.WithAnnotation(tierutil.DeprecatedByAnnotationKey, "other"))
+	otherTier := tiertest.OtherTier()
+
+	t.Run("migrate the MasterUserRecord to the successor tier", func(t *testing.T) {
+		// given
+		mur := murtest.NewMasterUserRecord(t, "user-1", murtest.Account("cluster1", *basicTier, murtest.SyncIndex("1")),
+			murtest.WithLabel(tierutil.TemplateTierDeprecatedLabelKey("basic"), "other"))
+		r, req, cl := prepareReconcile(t, basicTier, otherTier, mur,
+			turtest.NewTemplateUpdateRequest("user-1", *otherTier, turtest.MigrateFrom("basic")))
+
+		// when
+		res, err := r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		require.Equal(t, reconcile.Result{}, res)
+		murtest.AssertThatMasterUserRecord(t, "user-1", cl).
+			DoesNotHaveLabel(tierutil.TemplateTierHashLabelKey("basic")).
+			DoesNotHaveLabel(tierutil.TemplateTierDeprecatedLabelKey("basic"))
+		hostmurtest.AssertThatMasterUserRecord(t, "user-1", cl).
+			AllUserAccountsHaveTier(otherTier)
+		turtest.AssertThatTemplateUpdateRequest(t, "user-1", cl).
+			HasConditions(templateupdaterequest.ToBeUpdating()).
+			HasSyncIndexes(map[string]string{
+				"cluster1": "1",
+			})
+		notificationtest.AssertNoNotificationsExist(t, cl)
+	})
+
+	t.Run("notify the user when the MasterUserRecord is migrated", func(t *testing.T) {
+		// given
+		notifiedBasicTier := tiertest.BasicTier(t, tiertest.CurrentBasicTemplates,
+			tiertest.WithAnnotation(tierutil.DeprecatedByAnnotationKey, "other"),
+			tiertest.WithAnnotation(tierutil.DeprecationNotificationAnnotationKey, "true"))
+		userSignup := NewUserSignup(WithName("john"))
+		userSignup.Status.CompliantUsername = "user-1"
+		mur := murtest.NewMasterUserRecord(t, "user-1", murtest.Account("cluster1", *notifiedBasicTier, murtest.SyncIndex("1")),
+			murtest.WithOwnerLabel(userSignup.Name))
+		r, req, cl := prepareReconcile(t, notifiedBasicTier, otherTier, userSignup, mur,
+			turtest.NewTemplateUpdateRequest("user-1", *otherTier, turtest.MigrateFrom("basic")))
+
+		// when
+		_, err := r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		hostmurtest.AssertThatMasterUserRecord(t, "user-1", cl).
+			AllUserAccountsHaveTier(otherTier)
+		notificationtest.OnlyOneNotificationExists(t, cl, "user-1", templateupdaterequest.NotificationTypeTierMigrated,
+			notificationtest.HasContext("OldTierName", "basic"),
+			notificationtest.HasContext("NewTierName", "other"))
+		notification := &toolchainv1alpha1.Notification{}
+		require.NoError(t, cl.Get(context.TODO(), types.NamespacedName{Namespace: test.HostOperatorNs, Name: "user-1-tier-migrated-other"}, notification))
+		assert.Equal(t, "tierchanged", notification.Spec.Template)
+
+		t.Run("notification not created twice", func(t *testing.T) {
+			// given the MasterUserRecord update is retried after the notification was created
+			r, req, cl := prepareReconcile(t, notifiedBasicTier, otherTier, userSignup, notification,
+				murtest.NewMasterUserRecord(t, "user-1", murtest.Account("cluster1", *notifiedBasicTier, murtest.SyncIndex("1")),
+					murtest.WithOwnerLabel(userSignup.Name)),
+				turtest.NewTemplateUpdateRequest("user-1", *otherTier, turtest.MigrateFrom("basic")))
+
+			// when
+			_, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			notificationtest.OnlyOneNotificationExists(t, cl, "user-1", templateupdaterequest.NotificationTypeTierMigrated)
+		})
+	})
+
+	t.Run("migrate the Space to the successor tier", func(t *testing.T) {
+		// given
+		space := spacetest.NewSpace("user-1", spacetest.WithTierNameAndHashLabelFor(basicTier),
+			spacetest.WithLabel(tierutil.TemplateTierDeprecatedLabelKey("basic"), "other"),
+			spacetest.WithCondition(toolchainv1alpha1.Condition{
+				Type:   toolchainv1alpha1.ConditionReady,
+				Status: corev1.ConditionTrue,
+				Reason: toolchainv1alpha1.SpaceProvisionedReason,
+			}))
+		basicHash, err := tierutil.ComputeHashForNSTemplateTier(basicTier)
+		require.NoError(t, err)
+		r, req, cl := prepareReconcile(t, basicTier, otherTier, space,
+			turtest.NewTemplateUpdateRequest("user-1", *otherTier, turtest.CurrentTierHash(basicHash), turtest.MigrateFrom("basic")))
+
+		// when
+		_, err = r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		spacetest.AssertThatSpace(t, test.HostOperatorNs, "user-1", cl).
+			HasTier("other").
+			DoesNotHaveLabel(tierutil.TemplateTierHashLabelKey("basic")).
+			DoesNotHaveLabel(tierutil.TemplateTierDeprecatedLabelKey("basic"))
+		// the update is not complete until the hash label of the successor tier is set
+		turtest.AssertThatTemplateUpdateRequest(t, "user-1", cl).
+			HasConditions(toolchainv1alpha1.Condition{
+				Type:   toolchainv1alpha1.TemplateUpdateRequestComplete,
+				Status: corev1.ConditionFalse,
+				Reason: toolchainv1alpha1.TemplateUpdateRequestUpdatingReason,
+			})
+
+		t.Run("update complete once the Space is provisioned with the successor tier", func(t *testing.T) {
+			// given
+			space := &toolchainv1alpha1.Space{}
+			require.NoError(t, cl.Get(context.TODO(), types.NamespacedName{Namespace: test.HostOperatorNs, Name: "user-1"}, space))
+			otherHash, err := tierutil.ComputeHashForNSTemplateTier(otherTier)
+			require.NoError(t, err)
+			space.Labels = map[string]string{
+				tierutil.TemplateTierHashLabelKey("other"): otherHash,
+			}
+			require.NoError(t, cl.Update(context.TODO(), space))
+
+			// when
+			_, err = r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			turtest.AssertThatTemplateUpdateRequest(t, "user-1", cl).
+				HasConditions(toolchainv1alpha1.Condition{
+					Type:   toolchainv1alpha1.TemplateUpdateRequestComplete,
+					Status: corev1.ConditionTrue,
+					Reason: toolchainv1alpha1.TemplateUpdateRequestUpdatedReason,
+				})
+		})
+	})
+}
+
 func prepareReconcile(t *testing.T, initObjs ...runtime.Object) (reconcile.Reconciler, reconcile.Request, *test.FakeClient) {
 	os.Setenv("WATCH_NAMESPACE", test.HostOperatorNs)
 	s := scheme.Scheme
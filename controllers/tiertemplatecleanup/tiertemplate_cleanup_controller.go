@@ -0,0 +1,239 @@
+package tiertemplatecleanup
+
+import (
+	"context"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/host-operator/controllers/nstemplatetier"
+	tierutil "github.com/codeready-toolchain/host-operator/controllers/nstemplatetier/util"
+	"github.com/codeready-toolchain/host-operator/controllers/toolchainconfig"
+	"github.com/codeready-toolchain/host-operator/controllers/usersignup"
+	"github.com/codeready-toolchain/host-operator/pkg/cluster"
+
+	"github.com/go-logr/logr"
+	errs "github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// creationGracePeriod the minimum age of a TierTemplate before it can be garbage collected, since the TierTemplates
+// are created before the NSTemplateTier which references them is created or updated
+const creationGracePeriod = 10 * time.Minute
+
+// Reconciler deletes the TierTemplates which are no longer referenced by any NSTemplateTier (including the previous revisions
+// retained for a rollback), TemplateUpdateRequest, MasterUserRecord or Space (including the NSTemplateSet of the Space in its member cluster)
+type Reconciler struct {
+	Client         client.Client
+	Namespace      string
+	MemberClusters map[string]cluster.Cluster
+}
+
+// SetupWithManager sets up the controller with the Manager.
+// Watches the ToolchainConfig only, since the garbage collection is periodic
+func (r *Reconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("tiertemplatecleanup").
+		For(&toolchainv1alpha1.ToolchainConfig{}).
+		Complete(r)
+}
+
+//+kubebuilder:rbac:groups=toolchain.dev.openshift.com,resources=tiertemplates,verbs=get;list;watch;delete
+//+kubebuilder:rbac:groups=toolchain.dev.openshift.com,resources=nstemplatetiers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=toolchain.dev.openshift.com,resources=templateupdaterequests,verbs=get;list;watch
+//+kubebuilder:rbac:groups=toolchain.dev.openshift.com,resources=masteruserrecords,verbs=get;list;watch
+//+kubebuilder:rbac:groups=toolchain.dev.openshift.com,resources=spaces,verbs=get;list;watch
+
+// Reconcile deletes the unused TierTemplates, and requeues the request after the interval configured in the ToolchainConfig
+func (r *Reconciler) Reconcile(ctx context.Context, _ ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx, "namespace", r.Namespace)
+
+	config, err := toolchainconfig.GetToolchainConfig(r.Client)
+	if err != nil {
+		return reconcile.Result{}, errs.Wrap(err, "unable to get the ToolchainConfig")
+	}
+	interval := config.Tiers().TemplateGCInterval()
+	if interval == 0 {
+		logger.Info("garbage collection of the TierTemplates is disabled")
+		return reconcile.Result{}, nil
+	}
+	logger.Info("garbage collecting the unused TierTemplates")
+	if err := r.DeleteUnusedTierTemplates(logger, config.Tiers().TemplateGCRetainedRevisions()); err != nil {
+		return reconcile.Result{}, err
+	}
+	return reconcile.Result{RequeueAfter: interval}, nil
+}
+
+// DeleteUnusedTierTemplates deletes the TierTemplates which are not used by the current spec of any NSTemplateTier,
+// nor by the given number of most recent revisions of the tiers, nor by any TemplateUpdateRequest, MasterUserRecord or Space.
+// The TierTemplates of a tier are all retained if a Space of this tier has no revision or an unknown revision.
+func (r *Reconciler) DeleteUnusedTierTemplates(logger logr.Logger, retainedRevisions int) error {
+	tierTemplates := &toolchainv1alpha1.TierTemplateList{}
+	if err := r.Client.List(context.TODO(), tierTemplates, client.InNamespace(r.Namespace)); err != nil {
+		return errs.Wrap(err, "unable to list the TierTemplates")
+	}
+	if len(tierTemplates.Items) == 0 {
+		return nil
+	}
+	usedRefs, retainedTiers, err := r.usedTierTemplateRefs(logger, retainedRevisions)
+	if err != nil {
+		return err
+	}
+	deleted := 0
+	for i := range tierTemplates.Items {
+		tierTmpl := &tierTemplates.Items[i]
+		if usedRefs[tierTmpl.Name] || retainedTiers[tierTmpl.Spec.TierName] ||
+			time.Since(tierTmpl.CreationTimestamp.Time) < creationGracePeriod {
+			continue
+		}
+		logger.Info("deleting unused TierTemplate", "name", tierTmpl.Name, "tier", tierTmpl.Spec.TierName)
+		if err := r.Client.Delete(context.TODO(), tierTmpl); err != nil && !errors.IsNotFound(err) {
+			return errs.Wrapf(err, "unable to delete the TierTemplate '%s'", tierTmpl.Name)
+		}
+		deleted++
+	}
+	logger.Info("garbage collected the unused TierTemplates", "deleted", deleted, "remaining", len(tierTemplates.Items)-deleted)
+	return nil
+}
+
+// usedTierTemplateRefs returns the names of the TierTemplates which are in use, along with the names of the tiers whose TierTemplates
+// must all be retained since a Space has no revision yet, or refers to a revision which is not known (anymore) by its NSTemplateTier
+func (r *Reconciler) usedTierTemplateRefs(logger logr.Logger, retainedRevisions int) (map[string]bool, map[string]bool, error) {
+	refs := map[string]bool{}
+	// the TierTemplates with the template parameter overrides of a MasterUserRecord or Space are retained along with the TierTemplates they were generated from
+	addRefs := func(spec toolchainv1alpha1.NSTemplateSetSpec, overrides map[string]string) {
+		for _, ns := range spec.Namespaces {
+			refs[ns.TemplateRef] = true
+			refs[tierutil.OverriddenTemplateRef(ns.TemplateRef, overrides)] = true
+		}
+		if spec.ClusterResources != nil && spec.ClusterResources.TemplateRef != "" {
+			refs[spec.ClusterResources.TemplateRef] = true
+			refs[tierutil.OverriddenTemplateRef(spec.ClusterResources.TemplateRef, overrides)] = true
+		}
+	}
+	retainedTiers := map[string]bool{}
+	overridesOf := func(obj metav1.Object, tierName string) map[string]string {
+		overrides, err := tierutil.GetParameterOverrides(obj)
+		if err != nil {
+			// the TierTemplates generated with the previous overrides may still be in use
+			logger.Info("retaining all TierTemplates of the tier since the template parameter overrides are invalid", "tier", tierName, "name", obj.GetName())
+			retainedTiers[tierName] = true
+		}
+		return overrides
+	}
+
+	tierList := &toolchainv1alpha1.NSTemplateTierList{}
+	if err := r.Client.List(context.TODO(), tierList, client.InNamespace(r.Namespace)); err != nil {
+		return nil, nil, errs.Wrap(err, "unable to list the NSTemplateTiers")
+	}
+	tiers := make(map[string]*toolchainv1alpha1.NSTemplateTier, len(tierList.Items))
+	for i := range tierList.Items {
+		tier := &tierList.Items[i]
+		tiers[tier.Name] = tier
+		for _, ref := range nstemplatetier.RetainedTierTemplateRefs(logger, tier, retainedRevisions) {
+			refs[ref] = true
+		}
+	}
+
+	turs := &toolchainv1alpha1.TemplateUpdateRequestList{}
+	if err := r.Client.List(context.TODO(), turs, client.InNamespace(r.Namespace)); err != nil {
+		return nil, nil, errs.Wrap(err, "unable to list the TemplateUpdateRequests")
+	}
+	for _, tur := range turs.Items {
+		for _, ns := range tur.Spec.Namespaces {
+			refs[ns.TemplateRef] = true
+		}
+		if tur.Spec.ClusterResources != nil {
+			refs[tur.Spec.ClusterResources.TemplateRef] = true
+		}
+	}
+
+	murs := &toolchainv1alpha1.MasterUserRecordList{}
+	if err := r.Client.List(context.TODO(), murs, client.InNamespace(r.Namespace)); err != nil {
+		return nil, nil, errs.Wrap(err, "unable to list the MasterUserRecords")
+	}
+	for i := range murs.Items {
+		mur := &murs.Items[i]
+		overrides := overridesOf(mur, mur.Spec.TierName)
+		for _, ua := range mur.Spec.UserAccounts {
+			if ua.Spec.NSTemplateSet != nil {
+				addRefs(*ua.Spec.NSTemplateSet, overrides)
+			}
+		}
+	}
+
+	// the Spaces only refer to their tier and to the hash of its revision, from which the TierTemplates are looked-up
+	spaces := &toolchainv1alpha1.SpaceList{}
+	if err := r.Client.List(context.TODO(), spaces, client.InNamespace(r.Namespace)); err != nil {
+		return nil, nil, errs.Wrap(err, "unable to list the Spaces")
+	}
+	for i := range spaces.Items {
+		space := &spaces.Items[i]
+		// the NSTemplateSet may still use the TierTemplates of a previous revision or of a previous tier, until it is updated
+		nsTmplSet, err := r.nsTemplateSetOf(*space)
+		if err != nil {
+			return nil, nil, err
+		}
+		if nsTmplSet != nil {
+			addRefs(nsTmplSet.Spec, nil)
+		}
+		overrides := overridesOf(space, space.Spec.TierName)
+		tierName := space.Spec.TierName
+		if tierName == "" || retainedTiers[tierName] {
+			continue
+		}
+		tier, found := tiers[tierName]
+		if !found {
+			retainedTiers[tierName] = true
+			continue
+		}
+		hash, found := space.Labels[tierutil.TemplateTierHashLabelKey(tierName)]
+		if !found {
+			// the Space is not provisioned yet, or its tier has just been changed: the revision it will be provisioned with is not known yet
+			logger.Info("retaining all TierTemplates of the tier since a Space has no revision", "tier", tierName, "space", space.Name)
+			retainedTiers[tierName] = true
+			continue
+		}
+		if currentHash, err := tierutil.ComputeHashForNSTemplateTier(tier); err == nil && hash == currentHash {
+			// provisioned with the current revision of the tier
+			addRefs(*usersignup.NewNSTemplateSetSpec(tier), overrides)
+			continue
+		}
+		revisionRefs := nstemplatetier.RevisionTierTemplateRefs(logger, tier, hash)
+		if revisionRefs == nil {
+			logger.Info("retaining all TierTemplates of the tier since a Space has an unknown revision", "tier", tierName, "space", space.Name)
+			retainedTiers[tierName] = true
+			continue
+		}
+		for _, ref := range revisionRefs {
+			refs[ref] = true
+			refs[tierutil.OverriddenTemplateRef(ref, overrides)] = true
+		}
+	}
+	return refs, retainedTiers, nil
+}
+
+// nsTemplateSetOf returns the NSTemplateSet of the given Space in the member cluster where the Space is provisioned,
+// or `nil` if the Space is not provisioned (anymore) or if the member cluster is unknown
+func (r *Reconciler) nsTemplateSetOf(space toolchainv1alpha1.Space) (*toolchainv1alpha1.NSTemplateSet, error) {
+	memberCluster, found := r.MemberClusters[space.Status.TargetCluster]
+	if space.Status.TargetCluster == "" || !found {
+		return nil, nil
+	}
+	nsTmplSet := &toolchainv1alpha1.NSTemplateSet{}
+	if err := memberCluster.Client.Get(context.TODO(), types.NamespacedName{
+		Namespace: memberCluster.OperatorNamespace,
+		Name:      space.Name,
+	}, nsTmplSet); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, errs.Wrapf(err, "unable to get the NSTemplateSet of the Space '%s' in the member cluster '%s'", space.Name, space.Status.TargetCluster)
+	}
+	return nsTmplSet, nil
+}
@@ -0,0 +1,471 @@
+package tiertemplatecleanup_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/host-operator/controllers/nstemplatetier"
+	tierutil "github.com/codeready-toolchain/host-operator/controllers/nstemplatetier/util"
+	"github.com/codeready-toolchain/host-operator/controllers/tiertemplatecleanup"
+	"github.com/codeready-toolchain/host-operator/controllers/toolchainconfig"
+	"github.com/codeready-toolchain/host-operator/pkg/apis"
+	"github.com/codeready-toolchain/host-operator/pkg/cluster"
+	tiertest "github.com/codeready-toolchain/host-operator/test/nstemplatetier"
+	spacetest "github.com/codeready-toolchain/host-operator/test/space"
+	turtest "github.com/codeready-toolchain/host-operator/test/templateupdaterequest"
+	commonconfig "github.com/codeready-toolchain/toolchain-common/pkg/configuration"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	murtest "github.com/codeready-toolchain/toolchain-common/pkg/test/masteruserrecord"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestReconcile(t *testing.T) {
+	// given
+	// the "basic" tier was updated twice: its current templates are retained, as well as the ones of the previous revision
+	// (the default retention is 3 revisions, but the "older" revision is not retained with a retention of 1 revision)
+	olderTier := newTier("basic", "older")
+	previousTier := newTier("basic", "previous")
+	currentTier := newTier("basic", "current", tiertest.WithAnnotation(nstemplatetier.RevisionsAnnotationKey, tiertest.RevisionsOf(t, olderTier, previousTier)))
+	currentTier.Status.Updates = []toolchainv1alpha1.NSTemplateTierHistory{
+		{Hash: hashOf(t, olderTier)},
+		{Hash: hashOf(t, previousTier)},
+		{Hash: hashOf(t, currentTier)},
+	}
+	tierTemplates := func() []runtime.Object {
+		objs := []runtime.Object{}
+		for _, tier := range []*toolchainv1alpha1.NSTemplateTier{olderTier, previousTier, currentTier, newTier("deleted", "current")} {
+			objs = append(objs, tiertest.TierTemplatesFor(tier)...)
+		}
+		return objs
+	}
+	retainOneRevision := `{"tiers":{"templateGC":{"retainedRevisions":1,"interval":"1h"}}}`
+
+	t.Run("unused TierTemplates deleted", func(t *testing.T) {
+		// given
+		r, req, cl := prepareReconcile(t, retainOneRevision, append(tierTemplates(), currentTier)...)
+
+		// when
+		res, err := r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, reconcile.Result{RequeueAfter: time.Hour}, res)
+		assertTierTemplatesExist(t, cl, previousTier, currentTier)
+		assertTierTemplatesDeleted(t, cl, olderTier, newTier("deleted", "current"))
+	})
+
+	t.Run("TierTemplates of the retained revisions not deleted", func(t *testing.T) {
+		// given
+		r, req, cl := prepareReconcile(t, `{"tiers":{"templateGC":{"interval":"1h"}}}`, append(tierTemplates(), currentTier)...)
+
+		// when
+		_, err := r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assertTierTemplatesExist(t, cl, olderTier, previousTier, currentTier)
+		assertTierTemplatesDeleted(t, cl, newTier("deleted", "current"))
+	})
+
+	t.Run("TierTemplates created recently not deleted", func(t *testing.T) {
+		// given
+		objs := tierTemplates()
+		for _, obj := range objs {
+			obj.(*toolchainv1alpha1.TierTemplate).CreationTimestamp = metav1.NewTime(time.Now().Add(-time.Minute))
+		}
+		r, req, cl := prepareReconcile(t, retainOneRevision, append(objs, currentTier)...)
+
+		// when
+		_, err := r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assertTierTemplatesExist(t, cl, olderTier, previousTier, currentTier, newTier("deleted", "current"))
+	})
+
+	t.Run("TierTemplates used by a TemplateUpdateRequest not deleted", func(t *testing.T) {
+		// given
+		tur := turtest.NewTemplateUpdateRequest("user-1", *olderTier)
+		r, req, cl := prepareReconcile(t, retainOneRevision, append(tierTemplates(), currentTier, tur)...)
+
+		// when
+		_, err := r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assertTierTemplatesExist(t, cl, olderTier, previousTier, currentTier)
+	})
+
+	t.Run("TierTemplates used by a MasterUserRecord not deleted", func(t *testing.T) {
+		// given
+		mur := murtest.NewMasterUserRecord(t, "user-1", murtest.Account("member-1", *newTier("deleted", "current")))
+		r, req, cl := prepareReconcile(t, retainOneRevision, append(tierTemplates(), currentTier, mur)...)
+
+		// when
+		_, err := r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assertTierTemplatesExist(t, cl, newTier("deleted", "current"))
+		assertTierTemplatesDeleted(t, cl, olderTier)
+	})
+
+	t.Run("TierTemplates used by a Space", func(t *testing.T) {
+
+		t.Run("revision of the Space not deleted", func(t *testing.T) {
+			// given
+			space := spacetest.NewSpace("user-1", spacetest.WithTierNameAndHashLabelFor(olderTier))
+			r, req, cl := prepareReconcile(t, retainOneRevision, append(tierTemplates(), currentTier, space)...)
+
+			// when
+			_, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			assertTierTemplatesExist(t, cl, olderTier, previousTier, currentTier)
+			assertTierTemplatesDeleted(t, cl, newTier("deleted", "current"))
+		})
+
+		t.Run("TierTemplates of the NSTemplateSet of the Space not deleted", func(t *testing.T) {
+			// given
+			// the tier of the Space was changed, but the NSTemplateSet in the member cluster still uses the previous tier
+			space := spacetest.NewSpace("user-1", spacetest.WithTierNameAndHashLabelFor(currentTier), spacetest.WithStatusTargetCluster("member-1"))
+			r, req, cl := prepareReconcile(t, retainOneRevision, append(tierTemplates(), currentTier, space)...)
+			r.MemberClusters = memberClusters(t, nsTemplateSetFor(space, newTier("deleted", "current")))
+
+			// when
+			_, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			assertTierTemplatesExist(t, cl, previousTier, currentTier, newTier("deleted", "current"))
+			assertTierTemplatesDeleted(t, cl, olderTier)
+		})
+
+		t.Run("all TierTemplates of the tier not deleted when the revision of the Space is unknown", func(t *testing.T) {
+			// given
+			space := spacetest.NewSpace("user-1", spacetest.WithTierName("basic"),
+				spacetest.WithLabel(tierutil.TemplateTierHashLabelKey("basic"), "unknown"))
+			r, req, cl := prepareReconcile(t, retainOneRevision, append(tierTemplates(), currentTier, space)...)
+
+			// when
+			_, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			assertTierTemplatesExist(t, cl, olderTier, previousTier, currentTier)
+			assertTierTemplatesDeleted(t, cl, newTier("deleted", "current"))
+		})
+
+		t.Run("all TierTemplates of the tier not deleted when the Space has no revision", func(t *testing.T) {
+			// given
+			// eg. the tier of the Space has just been changed, which removed its hash label
+			space := spacetest.NewSpace("user-1", spacetest.WithTierName("basic"))
+			r, req, cl := prepareReconcile(t, retainOneRevision, append(tierTemplates(), currentTier, space)...)
+
+			// when
+			_, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			assertTierTemplatesExist(t, cl, olderTier, previousTier, currentTier)
+			assertTierTemplatesDeleted(t, cl, newTier("deleted", "current"))
+		})
+
+		t.Run("all TierTemplates of the tier not deleted when the tier does not exist", func(t *testing.T) {
+			// given
+			space := spacetest.NewSpace("user-1", spacetest.WithTierName("deleted"),
+				spacetest.WithLabel(tierutil.TemplateTierHashLabelKey("deleted"), "unknown"))
+			r, req, cl := prepareReconcile(t, retainOneRevision, append(tierTemplates(), currentTier, space)...)
+
+			// when
+			_, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			assertTierTemplatesExist(t, cl, previousTier, currentTier, newTier("deleted", "current"))
+			assertTierTemplatesDeleted(t, cl, olderTier)
+		})
+	})
+
+	t.Run("TierTemplates with template parameter overrides", func(t *testing.T) {
+		overrides := map[string]string{"MEMORY_LIMIT": "10Gi"}
+		// returns the given objects along with all the TierTemplates, including the ones with the template parameter overrides
+		withOverriddenTierTemplates := func(objs ...runtime.Object) []runtime.Object {
+			objs = append(objs, tierTemplates()...)
+			for _, tier := range []*toolchainv1alpha1.NSTemplateTier{previousTier, currentTier, newTier("deleted", "current")} {
+				objs = append(objs, tiertest.TierTemplatesFor(overriddenTier(tier, overrides))...)
+			}
+			return objs
+		}
+
+		t.Run("unused TierTemplates with template parameter overrides deleted", func(t *testing.T) {
+			// given
+			r, req, cl := prepareReconcile(t, retainOneRevision, withOverriddenTierTemplates(currentTier)...)
+
+			// when
+			_, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			assertTierTemplatesExist(t, cl, previousTier, currentTier)
+			assertTierTemplatesDeleted(t, cl, overriddenTier(previousTier, overrides), overriddenTier(currentTier, overrides),
+				overriddenTier(newTier("deleted", "current"), overrides))
+		})
+
+		t.Run("TierTemplates with the template parameter overrides of a MasterUserRecord not deleted", func(t *testing.T) {
+			// given
+			mur := murtest.NewMasterUserRecord(t, "user-1", murtest.Account("member-1", *newTier("deleted", "current")),
+				murtest.WithAnnotation(tierutil.TemplateParametersAnnotationKey, `{"MEMORY_LIMIT":"10Gi"}`))
+			r, req, cl := prepareReconcile(t, retainOneRevision, withOverriddenTierTemplates(currentTier, mur)...)
+
+			// when
+			_, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			assertTierTemplatesExist(t, cl, newTier("deleted", "current"), overriddenTier(newTier("deleted", "current"), overrides))
+			assertTierTemplatesDeleted(t, cl, overriddenTier(previousTier, overrides), overriddenTier(currentTier, overrides))
+		})
+
+		t.Run("TierTemplates with the template parameter overrides of a Space not deleted", func(t *testing.T) {
+			// given
+			space := spacetest.NewSpace("user-1", spacetest.WithTierNameAndHashLabelFor(currentTier),
+				spacetest.WithAnnotation(tierutil.TemplateParametersAnnotationKey, `{"MEMORY_LIMIT":"10Gi"}`))
+			r, req, cl := prepareReconcile(t, retainOneRevision, withOverriddenTierTemplates(currentTier, space)...)
+
+			// when
+			_, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			assertTierTemplatesExist(t, cl, previousTier, currentTier, overriddenTier(currentTier, overrides))
+			assertTierTemplatesDeleted(t, cl, overriddenTier(previousTier, overrides), overriddenTier(newTier("deleted", "current"), overrides))
+		})
+
+		t.Run("TierTemplates with the template parameter overrides of the revision of a Space not deleted", func(t *testing.T) {
+			// given
+			space := spacetest.NewSpace("user-1", spacetest.WithTierNameAndHashLabelFor(previousTier),
+				spacetest.WithAnnotation(tierutil.TemplateParametersAnnotationKey, `{"MEMORY_LIMIT":"10Gi"}`))
+			r, req, cl := prepareReconcile(t, retainOneRevision, withOverriddenTierTemplates(currentTier, space)...)
+
+			// when
+			_, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			assertTierTemplatesExist(t, cl, previousTier, currentTier, overriddenTier(previousTier, overrides))
+			assertTierTemplatesDeleted(t, cl, overriddenTier(currentTier, overrides), overriddenTier(newTier("deleted", "current"), overrides))
+		})
+
+		t.Run("all TierTemplates of the tier not deleted when the template parameter overrides of a Space are invalid", func(t *testing.T) {
+			// given
+			space := spacetest.NewSpace("user-1", spacetest.WithTierNameAndHashLabelFor(currentTier),
+				spacetest.WithAnnotation(tierutil.TemplateParametersAnnotationKey, `{"MEMORY_LIMIT":`))
+			r, req, cl := prepareReconcile(t, retainOneRevision, withOverriddenTierTemplates(currentTier, space)...)
+
+			// when
+			_, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			assertTierTemplatesExist(t, cl, olderTier, previousTier, currentTier, overriddenTier(previousTier, overrides), overriddenTier(currentTier, overrides))
+			assertTierTemplatesDeleted(t, cl, overriddenTier(newTier("deleted", "current"), overrides))
+		})
+	})
+
+	t.Run("garbage collection disabled by default", func(t *testing.T) {
+		// given
+		r, req, cl := prepareReconcile(t, "", append(tierTemplates(), currentTier)...)
+
+		// when
+		res, err := r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, reconcile.Result{}, res)
+		assertTierTemplatesExist(t, cl, olderTier, previousTier, currentTier, newTier("deleted", "current"))
+	})
+
+	t.Run("failures", func(t *testing.T) {
+
+		t.Run("unable to list the Spaces", func(t *testing.T) {
+			// given
+			r, req, cl := prepareReconcile(t, retainOneRevision, append(tierTemplates(), currentTier)...)
+			cl.MockList = func(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+				if _, ok := list.(*toolchainv1alpha1.SpaceList); ok {
+					return fmt.Errorf("mock error")
+				}
+				return cl.Client.List(ctx, list, opts...)
+			}
+
+			// when
+			_, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.EqualError(t, err, "unable to list the Spaces: mock error")
+			assertTierTemplatesExist(t, cl, olderTier, previousTier, currentTier, newTier("deleted", "current"))
+		})
+
+		t.Run("unable to get the NSTemplateSet of a Space", func(t *testing.T) {
+			// given
+			space := spacetest.NewSpace("user-1", spacetest.WithTierNameAndHashLabelFor(currentTier), spacetest.WithStatusTargetCluster("member-1"))
+			r, req, cl := prepareReconcile(t, retainOneRevision, append(tierTemplates(), currentTier, space)...)
+			r.MemberClusters = memberClusters(t)
+			r.MemberClusters["member-1"].Client.(*test.FakeClient).MockGet = func(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+				return fmt.Errorf("mock error")
+			}
+
+			// when
+			_, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.EqualError(t, err, "unable to get the NSTemplateSet of the Space 'user-1' in the member cluster 'member-1': mock error")
+			assertTierTemplatesExist(t, cl, olderTier, previousTier, currentTier, newTier("deleted", "current"))
+		})
+
+		t.Run("unable to delete a TierTemplate", func(t *testing.T) {
+			// given
+			r, req, cl := prepareReconcile(t, retainOneRevision, append(tierTemplates(), currentTier)...)
+			cl.MockDelete = func(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+				return fmt.Errorf("mock error")
+			}
+
+			// when
+			_, err := r.Reconcile(context.TODO(), req)
+
+			// then
+			require.EqualError(t, err, "unable to delete the TierTemplate 'basic-clusterresources-older': mock error")
+		})
+	})
+}
+
+// newTier returns an NSTemplateTier with the given name, whose TierTemplates are suffixed with the given revision
+func newTier(name, revision string, options ...tiertest.TierOption) *toolchainv1alpha1.NSTemplateTier {
+	tier := &toolchainv1alpha1.NSTemplateTier{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: test.HostOperatorNs,
+			Name:      name,
+		},
+		Spec: toolchainv1alpha1.NSTemplateTierSpec{
+			Namespaces: []toolchainv1alpha1.NSTemplateTierNamespace{
+				{TemplateRef: fmt.Sprintf("%s-dev-%s", name, revision)},
+				{TemplateRef: fmt.Sprintf("%s-stage-%s", name, revision)},
+			},
+			ClusterResources: &toolchainv1alpha1.NSTemplateTierClusterResources{
+				TemplateRef: fmt.Sprintf("%s-clusterresources-%s", name, revision),
+			},
+		},
+	}
+	for _, set := range options {
+		set(tier)
+	}
+	return tier
+}
+
+// overriddenTier returns a copy of the given tier which refers to the TierTemplates with the given template parameter overrides
+func overriddenTier(tier *toolchainv1alpha1.NSTemplateTier, overrides map[string]string) *toolchainv1alpha1.NSTemplateTier {
+	overridden := tier.DeepCopy()
+	for i, ns := range overridden.Spec.Namespaces {
+		overridden.Spec.Namespaces[i].TemplateRef = tierutil.OverriddenTemplateRef(ns.TemplateRef, overrides)
+	}
+	overridden.Spec.ClusterResources.TemplateRef = tierutil.OverriddenTemplateRef(overridden.Spec.ClusterResources.TemplateRef, overrides)
+	return overridden
+}
+
+func hashOf(t *testing.T, tier *toolchainv1alpha1.NSTemplateTier) string {
+	hash, err := tierutil.ComputeHashForNSTemplateTier(tier)
+	require.NoError(t, err)
+	return hash
+}
+
+func assertTierTemplatesExist(t *testing.T, cl client.Client, tiers ...*toolchainv1alpha1.NSTemplateTier) {
+	for _, ref := range templateRefsOf(tiers...) {
+		err := cl.Get(context.TODO(), types.NamespacedName{Namespace: test.HostOperatorNs, Name: ref}, &toolchainv1alpha1.TierTemplate{})
+		assert.NoError(t, err, "TierTemplate '%s' should exist", ref)
+	}
+}
+
+func assertTierTemplatesDeleted(t *testing.T, cl client.Client, tiers ...*toolchainv1alpha1.NSTemplateTier) {
+	for _, ref := range templateRefsOf(tiers...) {
+		err := cl.Get(context.TODO(), types.NamespacedName{Namespace: test.HostOperatorNs, Name: ref}, &toolchainv1alpha1.TierTemplate{})
+		assert.True(t, errors.IsNotFound(err), "TierTemplate '%s' should be deleted", ref)
+	}
+}
+
+// nsTemplateSetFor returns the NSTemplateSet of the given Space in its member cluster, which uses the TierTemplates of the given tier
+func nsTemplateSetFor(space *toolchainv1alpha1.Space, tier *toolchainv1alpha1.NSTemplateTier) *toolchainv1alpha1.NSTemplateSet {
+	nsTmplSet := &toolchainv1alpha1.NSTemplateSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: test.MemberOperatorNs,
+			Name:      space.Name,
+		},
+		Spec: toolchainv1alpha1.NSTemplateSetSpec{
+			TierName: tier.Name,
+			ClusterResources: &toolchainv1alpha1.NSTemplateSetClusterResources{
+				TemplateRef: tier.Spec.ClusterResources.TemplateRef,
+			},
+		},
+	}
+	for _, ns := range tier.Spec.Namespaces {
+		nsTmplSet.Spec.Namespaces = append(nsTmplSet.Spec.Namespaces, toolchainv1alpha1.NSTemplateSetNamespace{TemplateRef: ns.TemplateRef})
+	}
+	return nsTmplSet
+}
+
+func memberClusters(t *testing.T, initObjs ...runtime.Object) map[string]cluster.Cluster {
+	return map[string]cluster.Cluster{
+		"member-1": {
+			OperatorNamespace: test.MemberOperatorNs,
+			Client:            test.NewFakeClient(t, initObjs...),
+		},
+	}
+}
+
+func templateRefsOf(tiers ...*toolchainv1alpha1.NSTemplateTier) []string {
+	refs := []string{}
+	for _, tier := range tiers {
+		for _, ns := range tier.Spec.Namespaces {
+			refs = append(refs, ns.TemplateRef)
+		}
+		refs = append(refs, tier.Spec.ClusterResources.TemplateRef)
+	}
+	return refs
+}
+
+func prepareReconcile(t *testing.T, hostConfigExtension string, initObjs ...runtime.Object) (*tiertemplatecleanup.Reconciler, reconcile.Request, *test.FakeClient) {
+	require.NoError(t, os.Setenv("WATCH_NAMESPACE", test.HostOperatorNs))
+	err := apis.AddToScheme(scheme.Scheme)
+	require.NoError(t, err)
+	config := commonconfig.NewToolchainConfigObjWithReset(t)
+	if hostConfigExtension != "" {
+		config.Annotations = map[string]string{
+			toolchainconfig.HostConfigExtensionAnnotationKey: hostConfigExtension,
+		}
+	}
+	cl := test.NewFakeClient(t, append(initObjs, config)...)
+	r := &tiertemplatecleanup.Reconciler{
+		Client:    cl,
+		Namespace: test.HostOperatorNs,
+	}
+	req := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Namespace: test.HostOperatorNs,
+			Name:      "config",
+		},
+	}
+	return r, req, cl
+}
@@ -19,13 +19,35 @@ const (
 	// NotificationDeliveryServiceMailgun is the notification delivery service to use during production
 	NotificationDeliveryServiceMailgun = "mailgun"
 
+	// NotificationDeliveryServiceSMTP is the notification delivery service which sends the notifications via an SMTP server
+	NotificationDeliveryServiceSMTP = "smtp"
+
+	// SMTPTLSModeStartTLS upgrades the connection to the SMTP server with the STARTTLS command
+	SMTPTLSModeStartTLS = "starttls"
+	// SMTPTLSModeTLS connects to the SMTP server with implicit TLS
+	SMTPTLSModeTLS = "tls"
+	// SMTPTLSModeNone connects to the SMTP server without TLS
+	SMTPTLSModeNone = "none"
+
+	// NotificationChannelEmail is the delivery channel which sends the notifications by email, using the configured notification delivery service
+	NotificationChannelEmail = "email"
+	// NotificationChannelWebhook is the delivery channel which posts the notifications to a webhook
+	NotificationChannelWebhook = "webhook"
+
+	// WebhookFormatJSON is the webhook payload format with the subject, the body, the type and the context of the notification
+	WebhookFormatJSON = "json"
+	// WebhookFormatSlack is the webhook payload format compatible with Slack incoming webhooks
+	WebhookFormatSlack = "slack"
+
 	NotificationContextRegistrationURLKey = "RegistrationURL"
+	NotificationContextSupportURLKey      = "SupportURL"
 )
 
 var logger = logf.Log.WithName("toolchainconfig")
 
 type ToolchainConfig struct {
 	cfg     *toolchainv1alpha1.ToolchainConfigSpec
+	ext     HostConfigExtension
 	secrets map[string]map[string]string
 }
 
@@ -70,11 +92,16 @@ func newToolchainConfig(config runtime.Object, secrets map[string]map[string]str
 		logger.Error(fmt.Errorf("cache does not contain toolchainconfig resource type"), "failed to get ToolchainConfig from resource, using default configuration")
 		return ToolchainConfig{cfg: &toolchainv1alpha1.ToolchainConfigSpec{}}
 	}
-	return ToolchainConfig{cfg: &toolchaincfg.Spec, secrets: secrets}
+	ext, err := hostConfigExtensionOrLastValid(toolchaincfg)
+	if err != nil {
+		// keep the last valid extension along with the rest of the config (the error is reported in the status of the ToolchainConfig)
+		logger.Error(err, "failed to get the host config extension from the ToolchainConfig resource, using the last valid values for the extension")
+	}
+	return ToolchainConfig{cfg: &toolchaincfg.Spec, ext: ext, secrets: secrets}
 }
 
 func (c *ToolchainConfig) Print() {
-	logger.Info("Toolchain configuration", "config", c.cfg)
+	logger.Info("Toolchain configuration", "config", c.cfg, "extension", c.ext)
 }
 
 func (c *ToolchainConfig) Environment() string {
@@ -86,7 +113,10 @@ func (c *ToolchainConfig) AutomaticApproval() AutoApprovalConfig {
 }
 
 func (c *ToolchainConfig) Deactivation() DeactivationConfig {
-	return DeactivationConfig{c.cfg.Host.Deactivation}
+	return DeactivationConfig{
+		dctv: c.cfg.Host.Deactivation,
+		ext:  c.ext.Deactivation,
+	}
 }
 
 func (c *ToolchainConfig) Metrics() MetricsConfig {
@@ -96,6 +126,7 @@ func (c *ToolchainConfig) Metrics() MetricsConfig {
 func (c *ToolchainConfig) Notifications() NotificationsConfig {
 	return NotificationsConfig{
 		c:       c.cfg.Host.Notifications,
+		ext:     c.ext.Notifications,
 		secrets: c.secrets,
 	}
 }
@@ -104,12 +135,22 @@ func (c *ToolchainConfig) RegistrationService() RegistrationServiceConfig {
 	return RegistrationServiceConfig{c.cfg.Host.RegistrationService}
 }
 
+func (c *ToolchainConfig) Spaces() SpacesConfig {
+	return SpacesConfig{c.ext.Spaces}
+}
+
 func (c *ToolchainConfig) Tiers() TiersConfig {
-	return TiersConfig{c.cfg.Host.Tiers}
+	return TiersConfig{
+		tiers: c.cfg.Host.Tiers,
+		ext:   c.ext.Tiers,
+	}
 }
 
 func (c *ToolchainConfig) ToolchainStatus() ToolchainStatusConfig {
-	return ToolchainStatusConfig{c.cfg.Host.ToolchainStatus}
+	return ToolchainStatusConfig{
+		t:   c.cfg.Host.ToolchainStatus,
+		ext: c.ext.ToolchainStatus,
+	}
 }
 
 func (c *ToolchainConfig) Users() UsersConfig {
@@ -142,6 +183,7 @@ func (a AutoApprovalConfig) MaxNumberOfUsersSpecificPerMemberCluster() map[strin
 
 type DeactivationConfig struct {
 	dctv toolchainv1alpha1.DeactivationConfig
+	ext  DeactivationConfigExtension
 }
 
 func (d DeactivationConfig) DeactivatingNotificationDays() int {
@@ -164,6 +206,25 @@ func (d DeactivationConfig) UserSignupUnverifiedRetentionDays() int {
 	return commonconfig.GetInt(d.dctv.UserSignupUnverifiedRetentionDays, 7)
 }
 
+func (d DeactivationConfig) ThrottleInterval() time.Duration {
+	v := commonconfig.GetString(d.ext.Throttle.Interval, "1m")
+	duration, err := time.ParseDuration(v)
+	if err != nil || duration <= 0 {
+		duration = time.Minute
+	}
+	return duration
+}
+
+// MaxDeactivationsPerInterval returns the maximum number of deactivations within the throttle interval across all member clusters.
+// 0 means that there is no limit.
+func (d DeactivationConfig) MaxDeactivationsPerInterval() int {
+	return commonconfig.GetInt(d.ext.Throttle.MaxDeactivationsPerInterval, 0)
+}
+
+func (d DeactivationConfig) MaxDeactivationsPerIntervalSpecificPerMemberCluster() map[string]int {
+	return d.ext.Throttle.SpecificPerMemberCluster
+}
+
 type MetricsConfig struct {
 	metrics toolchainv1alpha1.MetricsConfig
 }
@@ -174,6 +235,7 @@ func (d MetricsConfig) ForceSynchronization() bool {
 
 type NotificationsConfig struct {
 	c       toolchainv1alpha1.NotificationsConfig
+	ext     NotificationsConfigExtension
 	secrets map[string]map[string]string
 }
 
@@ -219,6 +281,122 @@ func (n NotificationsConfig) MailgunReplyToEmail() string {
 	return n.notificationSecret(key)
 }
 
+func (n NotificationsConfig) SMTPHost() string {
+	return commonconfig.GetString(n.ext.SMTP.Host, "")
+}
+
+func (n NotificationsConfig) SMTPPort() int {
+	return commonconfig.GetInt(n.ext.SMTP.Port, 587)
+}
+
+func (n NotificationsConfig) SMTPTLSMode() string {
+	return commonconfig.GetString(n.ext.SMTP.TLSMode, SMTPTLSModeStartTLS)
+}
+
+func (n NotificationsConfig) SMTPSenderEmail() string {
+	return commonconfig.GetString(n.ext.SMTP.SenderEmail, "")
+}
+
+func (n NotificationsConfig) SMTPReplyToEmail() string {
+	return commonconfig.GetString(n.ext.SMTP.ReplyToEmail, "")
+}
+
+func (n NotificationsConfig) SMTPUsername() string {
+	key := commonconfig.GetString(n.ext.SMTP.Secret.Username, "smtpUsername")
+	return n.notificationSecret(key)
+}
+
+func (n NotificationsConfig) SMTPPassword() string {
+	key := commonconfig.GetString(n.ext.SMTP.Secret.Password, "smtpPassword")
+	return n.notificationSecret(key)
+}
+
+func (n NotificationsConfig) WebhookURL() string {
+	key := commonconfig.GetString(n.ext.Webhook.Secret.URL, "webhookURL")
+	return n.notificationSecret(key)
+}
+
+func (n NotificationsConfig) WebhookFormat() string {
+	return commonconfig.GetString(n.ext.Webhook.Format, WebhookFormatJSON)
+}
+
+// NotificationRouting returns the delivery channels to use per notification type
+func (n NotificationsConfig) NotificationRouting() map[string]string {
+	return n.ext.Routing
+}
+
+// DeliveryMaxAttempts returns the maximum number of attempts to deliver a notification
+func (n NotificationsConfig) DeliveryMaxAttempts() int {
+	return commonconfig.GetInt(n.ext.Retries.MaxAttempts, 5)
+}
+
+// DeliveryInitialBackoff returns the delay between the first and the second attempts to deliver a notification
+func (n NotificationsConfig) DeliveryInitialBackoff() time.Duration {
+	v := commonconfig.GetString(n.ext.Retries.InitialBackoff, "1m")
+	duration, err := time.ParseDuration(v)
+	if err != nil {
+		duration = time.Minute
+	}
+	return duration
+}
+
+// QuietPeriod returns the duration during which a notification identical to a notification already sent to the same recipient is suppressed
+// (0 if disabled)
+func (n NotificationsConfig) QuietPeriod() time.Duration {
+	v := commonconfig.GetString(n.ext.QuietPeriod, "0s")
+	duration, err := time.ParseDuration(v)
+	if err != nil || duration < 0 {
+		duration = 0
+	}
+	return duration
+}
+
+// MaxPerRecipientPerDay returns the maximum number of notifications sent to the same recipient within 24 hours (0 means unlimited)
+func (n NotificationsConfig) MaxPerRecipientPerDay() int {
+	return commonconfig.GetInt(n.ext.MaxPerRecipientPerDay, 0)
+}
+
+// UnsubscribeSigningKey returns the key used to sign the unsubscribe tokens (empty if no unsubscribe token must be generated)
+func (n NotificationsConfig) UnsubscribeSigningKey() string {
+	key := commonconfig.GetString(n.ext.Unsubscribe.Secret.SigningKey, "unsubscribeSigningKey")
+	return n.notificationSecret(key)
+}
+
+// UnsubscribeTokenValidity returns the duration during which the unsubscribe tokens can be used
+func (n NotificationsConfig) UnsubscribeTokenValidity() time.Duration {
+	v := commonconfig.GetString(n.ext.Unsubscribe.TokenValidity, "720h")
+	duration, err := time.ParseDuration(v)
+	if err != nil || duration <= 0 {
+		duration = 30 * 24 * time.Hour
+	}
+	return duration
+}
+
+// SupportURL returns the URL of the page where the users can get help
+func (n NotificationsConfig) SupportURL() string {
+	return commonconfig.GetString(n.ext.SupportURL, "https://developers.redhat.com/developer-sandbox")
+}
+
+// DeliveryMaxBackoff returns the maximum delay between two attempts to deliver a notification
+func (n NotificationsConfig) DeliveryMaxBackoff() time.Duration {
+	v := commonconfig.GetString(n.ext.Retries.MaxBackoff, "1h")
+	duration, err := time.ParseDuration(v)
+	if err != nil {
+		duration = time.Hour
+	}
+	return duration
+}
+
+// DeliveryFailedRetention returns the duration during which a notification whose delivery was abandoned is kept
+func (n NotificationsConfig) DeliveryFailedRetention() time.Duration {
+	v := commonconfig.GetString(n.ext.Retries.FailedRetention, "720h")
+	duration, err := time.ParseDuration(v)
+	if err != nil || duration < 0 {
+		duration = 30 * 24 * time.Hour
+	}
+	return duration
+}
+
 type RegistrationServiceConfig struct {
 	c toolchainv1alpha1.RegistrationServiceConfig
 }
@@ -235,8 +413,17 @@ func (r RegistrationServiceConfig) RegistrationServiceURL() string {
 	return commonconfig.GetString(r.c.RegistrationServiceURL, "https://registration.crt-placeholder.com")
 }
 
+type SpacesConfig struct {
+	s SpacesConfigExtension
+}
+
+func (s SpacesConfig) ExpiringNotificationDays() int {
+	return commonconfig.GetInt(s.s.ExpiringNotificationDays, 3)
+}
+
 type TiersConfig struct {
 	tiers toolchainv1alpha1.TiersConfig
+	ext   TiersConfigExtension
 }
 
 func (d TiersConfig) DefaultTier() string {
@@ -260,8 +447,59 @@ func (d TiersConfig) TemplateUpdateRequestMaxPoolSize() int {
 	return commonconfig.GetInt(d.tiers.TemplateUpdateRequestMaxPoolSize, 5)
 }
 
+// RolloutCanaryPercentage returns the default percentage of the outdated MasterUserRecords and Spaces updated in the canary stage
+// of the rollout of an NSTemplateTier update (0 if there is no canary stage)
+func (d TiersConfig) RolloutCanaryPercentage() int {
+	return commonconfig.GetInt(d.ext.Rollout.CanaryPercentage, 0)
+}
+
+// RolloutMaxFailurePercentage returns the default percentage of failed updates beyond which the rollout of an NSTemplateTier update is halted
+func (d TiersConfig) RolloutMaxFailurePercentage() int {
+	return commonconfig.GetInt(d.ext.Rollout.MaxFailurePercentage, 50)
+}
+
+// RolloutMinUpdatesBeforeHalt returns the number of updates processed before the failure percentage of a rollout is checked
+func (d TiersConfig) RolloutMinUpdatesBeforeHalt() int {
+	return commonconfig.GetInt(d.ext.Rollout.MinUpdatesBeforeHalt, 10)
+}
+
+// BundlesDirectory returns the path of the directory containing the tier bundles loaded at runtime, or an empty string if none is configured
+func (d TiersConfig) BundlesDirectory() string {
+	return commonconfig.GetString(d.ext.Bundles.Directory, "")
+}
+
+// BundlesResyncPeriod returns the interval at which the tier bundles are checked for changes
+func (d TiersConfig) BundlesResyncPeriod() time.Duration {
+	v := commonconfig.GetString(d.ext.Bundles.ResyncPeriod, "1m")
+	duration, err := time.ParseDuration(v)
+	if err != nil || duration <= 0 {
+		duration = time.Minute
+	}
+	return duration
+}
+
+// TemplateGCRetainedRevisions returns the number of previous revisions of each NSTemplateTier whose TierTemplates are not garbage collected
+func (d TiersConfig) TemplateGCRetainedRevisions() int {
+	retained := commonconfig.GetInt(d.ext.TemplateGC.RetainedRevisions, 3)
+	if retained < 0 {
+		return 0
+	}
+	return retained
+}
+
+// TemplateGCInterval returns the interval between two garbage collections of the unused TierTemplates (0 if disabled)
+func (d TiersConfig) TemplateGCInterval() time.Duration {
+	v := commonconfig.GetString(d.ext.TemplateGC.Interval, "0s")
+	duration, err := time.ParseDuration(v)
+	if err != nil || duration < 0 {
+		duration = 0
+	}
+	return duration
+}
+
 type ToolchainStatusConfig struct {
-	t toolchainv1alpha1.ToolchainStatusConfig
+	t   toolchainv1alpha1.ToolchainStatusConfig
+	ext ToolchainStatusConfigExtension
 }
 
 func (d ToolchainStatusConfig) ToolchainStatusRefreshTime() time.Duration {
@@ -273,6 +511,51 @@ func (d ToolchainStatusConfig) ToolchainStatusRefreshTime() time.Duration {
 	return duration
 }
 
+// DurationAfterUnready returns the duration after which the admins are notified when the ToolchainStatus stays unready
+func (d ToolchainStatusConfig) DurationAfterUnready() time.Duration {
+	v := commonconfig.GetString(d.ext.Notifications.DurationAfterUnready, "10m")
+	duration, err := time.ParseDuration(v)
+	if err != nil {
+		duration = 10 * time.Minute
+	}
+	return duration
+}
+
+// EscalationInterval returns the delay before the first escalation sent while the ToolchainStatus stays unready (0 if disabled)
+func (d ToolchainStatusConfig) EscalationInterval() time.Duration {
+	v := commonconfig.GetString(d.ext.Notifications.EscalationInterval, "0s")
+	duration, err := time.ParseDuration(v)
+	if err != nil {
+		duration = 0
+	}
+	return duration
+}
+
+// MaxEscalationInterval returns the maximum delay between two escalations
+func (d ToolchainStatusConfig) MaxEscalationInterval() time.Duration {
+	v := commonconfig.GetString(d.ext.Notifications.MaxEscalationInterval, "24h")
+	duration, err := time.ParseDuration(v)
+	if err != nil {
+		duration = 24 * time.Hour
+	}
+	return duration
+}
+
+// SuppressedComponents returns the components for which no notification is sent when they are not ready
+func (d ToolchainStatusConfig) SuppressedComponents() []string {
+	return d.ext.Notifications.SuppressedComponents
+}
+
+// DigestInterval returns the interval between two digests of the components which became unready (0 if disabled)
+func (d ToolchainStatusConfig) DigestInterval() time.Duration {
+	v := commonconfig.GetString(d.ext.Notifications.DigestInterval, "0s")
+	duration, err := time.ParseDuration(v)
+	if err != nil {
+		duration = 0
+	}
+	return duration
+}
+
 type UsersConfig struct {
 	c toolchainv1alpha1.UsersConfig
 }
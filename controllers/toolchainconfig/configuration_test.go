@@ -224,6 +224,51 @@ func TestNewToolchainConfig(t *testing.T) {
 		assert.Equal(t, "prod", toolchainCfg.Environment())
 	})
 
+	t.Run("invalid host config extension - default extension values used", func(t *testing.T) {
+		// given
+		lastValidHostConfigExtension.ext = HostConfigExtension{}
+		toolchainCfgObj := testconfig.NewToolchainConfigObj(t, testconfig.Environment("e2e-tests"))
+		toolchainCfgObj.Annotations = map[string]string{
+			HostConfigExtensionAnnotationKey: `{"deactivation":`,
+		}
+
+		// when
+		toolchainCfg := newToolchainConfig(toolchainCfgObj, nil)
+
+		// then
+		assert.Equal(t, "e2e-tests", toolchainCfg.Environment())
+		assert.Equal(t, 0, toolchainCfg.Deactivation().MaxDeactivationsPerInterval())
+	})
+
+	t.Run("invalid host config extension - last valid extension values used", func(t *testing.T) {
+		// given
+		toolchainCfgObj := testconfig.NewToolchainConfigObj(t, testconfig.Environment("e2e-tests"))
+		toolchainCfgObj.Annotations = map[string]string{
+			HostConfigExtensionAnnotationKey: `{"deactivation":{"throttle":{"maxDeactivationsPerInterval":20}},"notifications":{"quietPeriod":"72h"}}`,
+		}
+		newToolchainConfig(toolchainCfgObj, nil)
+		toolchainCfgObj.Annotations[HostConfigExtensionAnnotationKey] = `{"deactivation":{"throttle":{"maxDeactivationsPerInterval":"fifty"}},"notifications":{"quietPeriod":"72h"}}`
+
+		// when
+		toolchainCfg := newToolchainConfig(toolchainCfgObj, nil)
+
+		// then
+		assert.Equal(t, "e2e-tests", toolchainCfg.Environment())
+		assert.Equal(t, 20, toolchainCfg.Deactivation().MaxDeactivationsPerInterval())
+		assert.Equal(t, 72*time.Hour, toolchainCfg.Notifications().QuietPeriod())
+
+		t.Run("annotation removed - default extension values used", func(t *testing.T) {
+			// given
+			delete(toolchainCfgObj.Annotations, HostConfigExtensionAnnotationKey)
+
+			// when
+			toolchainCfg := newToolchainConfig(toolchainCfgObj, nil)
+
+			// then
+			assert.Equal(t, 0, toolchainCfg.Deactivation().MaxDeactivationsPerInterval())
+		})
+	})
+
 	t.Run("nil toolchainconfig resource - default config used", func(t *testing.T) {
 		// when
 		toolchainCfg := newToolchainConfig(nil, nil)
@@ -265,6 +310,9 @@ func TestDeactivationConfig(t *testing.T) {
 		assert.Empty(t, toolchainCfg.Deactivation().DeactivationDomainsExcluded())
 		assert.Equal(t, 730, toolchainCfg.Deactivation().UserSignupDeactivatedRetentionDays())
 		assert.Equal(t, 7, toolchainCfg.Deactivation().UserSignupUnverifiedRetentionDays())
+		assert.Equal(t, time.Minute, toolchainCfg.Deactivation().ThrottleInterval())
+		assert.Equal(t, 0, toolchainCfg.Deactivation().MaxDeactivationsPerInterval())
+		assert.Empty(t, toolchainCfg.Deactivation().MaxDeactivationsPerIntervalSpecificPerMemberCluster())
 	})
 	t.Run("non-default", func(t *testing.T) {
 		cfg := commonconfig.NewToolchainConfigObjWithReset(t, testconfig.Deactivation().
@@ -272,12 +320,27 @@ func TestDeactivationConfig(t *testing.T) {
 			DeactivationDomainsExcluded("@redhat.com,@ibm.com").
 			UserSignupDeactivatedRetentionDays(44).
 			UserSignupUnverifiedRetentionDays(77))
+		cfg.Annotations = map[string]string{
+			HostConfigExtensionAnnotationKey: `{"deactivation":{"throttle":{"interval":"5m","maxDeactivationsPerInterval":20,"specificPerMemberCluster":{"member1":5}}}}`,
+		}
 		toolchainCfg := newToolchainConfig(cfg, map[string]map[string]string{})
 
 		assert.Equal(t, 5, toolchainCfg.Deactivation().DeactivatingNotificationDays())
 		assert.Equal(t, []string{"@redhat.com", "@ibm.com"}, toolchainCfg.Deactivation().DeactivationDomainsExcluded())
 		assert.Equal(t, 44, toolchainCfg.Deactivation().UserSignupDeactivatedRetentionDays())
 		assert.Equal(t, 77, toolchainCfg.Deactivation().UserSignupUnverifiedRetentionDays())
+		assert.Equal(t, 5*time.Minute, toolchainCfg.Deactivation().ThrottleInterval())
+		assert.Equal(t, 20, toolchainCfg.Deactivation().MaxDeactivationsPerInterval())
+		assert.Equal(t, map[string]int{"member1": 5}, toolchainCfg.Deactivation().MaxDeactivationsPerIntervalSpecificPerMemberCluster())
+	})
+	t.Run("invalid throttle interval", func(t *testing.T) {
+		cfg := commonconfig.NewToolchainConfigObjWithReset(t)
+		cfg.Annotations = map[string]string{
+			HostConfigExtensionAnnotationKey: `{"deactivation":{"throttle":{"interval":"banana"}}}`,
+		}
+		toolchainCfg := newToolchainConfig(cfg, map[string]map[string]string{})
+
+		assert.Equal(t, time.Minute, toolchainCfg.Deactivation().ThrottleInterval())
 	})
 }
 
@@ -323,6 +386,25 @@ func TestNotifications(t *testing.T) {
 		assert.Empty(t, toolchainCfg.Notifications().MailgunReplyToEmail())
 		assert.Equal(t, "mailgun", toolchainCfg.Notifications().NotificationDeliveryService())
 		assert.Equal(t, 24*time.Hour, toolchainCfg.Notifications().DurationBeforeNotificationDeletion())
+		assert.Empty(t, toolchainCfg.Notifications().SMTPHost())
+		assert.Equal(t, 587, toolchainCfg.Notifications().SMTPPort())
+		assert.Equal(t, "starttls", toolchainCfg.Notifications().SMTPTLSMode())
+		assert.Empty(t, toolchainCfg.Notifications().SMTPSenderEmail())
+		assert.Empty(t, toolchainCfg.Notifications().SMTPReplyToEmail())
+		assert.Empty(t, toolchainCfg.Notifications().SMTPUsername())
+		assert.Empty(t, toolchainCfg.Notifications().SMTPPassword())
+		assert.Empty(t, toolchainCfg.Notifications().WebhookURL())
+		assert.Equal(t, "json", toolchainCfg.Notifications().WebhookFormat())
+		assert.Empty(t, toolchainCfg.Notifications().NotificationRouting())
+		assert.Equal(t, 5, toolchainCfg.Notifications().DeliveryMaxAttempts())
+		assert.Equal(t, time.Minute, toolchainCfg.Notifications().DeliveryInitialBackoff())
+		assert.Equal(t, time.Hour, toolchainCfg.Notifications().DeliveryMaxBackoff())
+		assert.Equal(t, 30*24*time.Hour, toolchainCfg.Notifications().DeliveryFailedRetention())
+		assert.Equal(t, time.Duration(0), toolchainCfg.Notifications().QuietPeriod())
+		assert.Equal(t, 0, toolchainCfg.Notifications().MaxPerRecipientPerDay())
+		assert.Empty(t, toolchainCfg.Notifications().UnsubscribeSigningKey())
+		assert.Equal(t, 30*24*time.Hour, toolchainCfg.Notifications().UnsubscribeTokenValidity())
+		assert.Equal(t, "https://developers.redhat.com/developer-sandbox", toolchainCfg.Notifications().SupportURL())
 	})
 	t.Run("non-default", func(t *testing.T) {
 		cfg := commonconfig.NewToolchainConfigObjWithReset(t,
@@ -355,14 +437,128 @@ func TestNotifications(t *testing.T) {
 		assert.Equal(t, 48*time.Hour, toolchainCfg.Notifications().DurationBeforeNotificationDeletion())
 	})
 
+	t.Run("smtp", func(t *testing.T) {
+		cfg := commonconfig.NewToolchainConfigObjWithReset(t,
+			testconfig.Notifications().
+				NotificationDeliveryService("smtp").
+				Secret().
+				Ref("notifications"))
+		cfg.Annotations = map[string]string{
+			HostConfigExtensionAnnotationKey: `{"notifications":{"smtp":{"host":"smtp.redhat.com","port":465,"tlsMode":"tls",` +
+				`"senderEmail":"devsandbox@redhat.com","replyToEmail":"devsandbox_rulez@redhat.com","secret":{"password":"smtpPass"}}}}`,
+		}
+		secrets := map[string]map[string]string{
+			"notifications": {
+				"smtpUsername": "sandbox",
+				"smtpPass":     "s3cr3t",
+			},
+		}
+
+		toolchainCfg := newToolchainConfig(cfg, secrets)
+
+		assert.Equal(t, "smtp", toolchainCfg.Notifications().NotificationDeliveryService())
+		assert.Equal(t, "smtp.redhat.com", toolchainCfg.Notifications().SMTPHost())
+		assert.Equal(t, 465, toolchainCfg.Notifications().SMTPPort())
+		assert.Equal(t, "tls", toolchainCfg.Notifications().SMTPTLSMode())
+		assert.Equal(t, "devsandbox@redhat.com", toolchainCfg.Notifications().SMTPSenderEmail())
+		assert.Equal(t, "devsandbox_rulez@redhat.com", toolchainCfg.Notifications().SMTPReplyToEmail())
+		assert.Equal(t, "sandbox", toolchainCfg.Notifications().SMTPUsername()) // default key
+		assert.Equal(t, "s3cr3t", toolchainCfg.Notifications().SMTPPassword())
+	})
+
+	t.Run("webhook", func(t *testing.T) {
+		cfg := commonconfig.NewToolchainConfigObjWithReset(t,
+			testconfig.Notifications().
+				Secret().
+				Ref("notifications"))
+		cfg.Annotations = map[string]string{
+			HostConfigExtensionAnnotationKey: `{"notifications":{"webhook":{"format":"slack"},"routing":{"toolchainstatus":"webhook"}}}`,
+		}
+		secrets := map[string]map[string]string{
+			"notifications": {
+				"webhookURL": "https://hooks.slack.com/services/T000/B000/XXXX",
+			},
+		}
+
+		toolchainCfg := newToolchainConfig(cfg, secrets)
+
+		assert.Equal(t, "https://hooks.slack.com/services/T000/B000/XXXX", toolchainCfg.Notifications().WebhookURL())
+		assert.Equal(t, "slack", toolchainCfg.Notifications().WebhookFormat())
+		assert.Equal(t, map[string]string{"toolchainstatus": "webhook"}, toolchainCfg.Notifications().NotificationRouting())
+	})
+
+	t.Run("unsubscribe", func(t *testing.T) {
+		cfg := commonconfig.NewToolchainConfigObjWithReset(t,
+			testconfig.Notifications().
+				Secret().
+				Ref("notifications"))
+		cfg.Annotations = map[string]string{
+			HostConfigExtensionAnnotationKey: `{"notifications":{"unsubscribe":{"secret":{"signingKey":"signing-key"},"tokenValidity":"168h"}}}`,
+		}
+		secrets := map[string]map[string]string{
+			"notifications": {
+				"signing-key": "s3cr3t",
+			},
+		}
+
+		toolchainCfg := newToolchainConfig(cfg, secrets)
+
+		assert.Equal(t, "s3cr3t", toolchainCfg.Notifications().UnsubscribeSigningKey())
+		assert.Equal(t, 7*24*time.Hour, toolchainCfg.Notifications().UnsubscribeTokenValidity())
+	})
+
+	t.Run("support URL", func(t *testing.T) {
+		cfg := commonconfig.NewToolchainConfigObjWithReset(t)
+		cfg.Annotations = map[string]string{
+			HostConfigExtensionAnnotationKey: `{"notifications":{"supportURL":"https://support.example.com"}}`,
+		}
+
+		toolchainCfg := newToolchainConfig(cfg, nil)
+
+		assert.Equal(t, "https://support.example.com", toolchainCfg.Notifications().SupportURL())
+	})
+
+	t.Run("retries", func(t *testing.T) {
+		cfg := commonconfig.NewToolchainConfigObjWithReset(t)
+		cfg.Annotations = map[string]string{
+			HostConfigExtensionAnnotationKey: `{"notifications":{"retries":{"maxAttempts":3,"initialBackoff":"10s","maxBackoff":"5m","failedRetention":"168h"}}}`,
+		}
+
+		toolchainCfg := newToolchainConfig(cfg, nil)
+
+		assert.Equal(t, 3, toolchainCfg.Notifications().DeliveryMaxAttempts())
+		assert.Equal(t, 10*time.Second, toolchainCfg.Notifications().DeliveryInitialBackoff())
+		assert.Equal(t, 5*time.Minute, toolchainCfg.Notifications().DeliveryMaxBackoff())
+		assert.Equal(t, 7*24*time.Hour, toolchainCfg.Notifications().DeliveryFailedRetention())
+	})
+
+	t.Run("deduplication and rate limit", func(t *testing.T) {
+		cfg := commonconfig.NewToolchainConfigObjWithReset(t)
+		cfg.Annotations = map[string]string{
+			HostConfigExtensionAnnotationKey: `{"notifications":{"quietPeriod":"72h","maxPerRecipientPerDay":10}}`,
+		}
+
+		toolchainCfg := newToolchainConfig(cfg, nil)
+
+		assert.Equal(t, 72*time.Hour, toolchainCfg.Notifications().QuietPeriod())
+		assert.Equal(t, 10, toolchainCfg.Notifications().MaxPerRecipientPerDay())
+	})
+
 	t.Run("edge case", func(t *testing.T) {
 		cfg := commonconfig.NewToolchainConfigObjWithReset(t,
 			testconfig.Notifications().
 				DurationBeforeNotificationDeletion("banana"))
+		cfg.Annotations = map[string]string{
+			HostConfigExtensionAnnotationKey: `{"notifications":{"retries":{"initialBackoff":"banana","maxBackoff":"cherry","failedRetention":"apple"},"quietPeriod":"kiwi"}}`,
+		}
 
 		toolchainCfg := newToolchainConfig(cfg, nil)
 
 		assert.Equal(t, 24*time.Hour, toolchainCfg.Notifications().DurationBeforeNotificationDeletion())
+		assert.Equal(t, time.Minute, toolchainCfg.Notifications().DeliveryInitialBackoff())
+		assert.Equal(t, time.Hour, toolchainCfg.Notifications().DeliveryMaxBackoff())
+		assert.Equal(t, 30*24*time.Hour, toolchainCfg.Notifications().DeliveryFailedRetention())
+		assert.Equal(t, time.Duration(0), toolchainCfg.Notifications().QuietPeriod())
 	})
 }
 
@@ -390,6 +586,24 @@ func TestRegistrationService(t *testing.T) {
 	})
 }
 
+func TestSpaces(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		cfg := commonconfig.NewToolchainConfigObjWithReset(t)
+		toolchainCfg := newToolchainConfig(cfg, map[string]map[string]string{})
+
+		assert.Equal(t, 3, toolchainCfg.Spaces().ExpiringNotificationDays())
+	})
+	t.Run("non-default", func(t *testing.T) {
+		cfg := commonconfig.NewToolchainConfigObjWithReset(t)
+		cfg.Annotations = map[string]string{
+			HostConfigExtensionAnnotationKey: `{"spaces":{"expiringNotificationDays":7}}`,
+		}
+		toolchainCfg := newToolchainConfig(cfg, map[string]map[string]string{})
+
+		assert.Equal(t, 7, toolchainCfg.Spaces().ExpiringNotificationDays())
+	})
+}
+
 func TestTiers(t *testing.T) {
 	t.Run("default", func(t *testing.T) {
 		cfg := commonconfig.NewToolchainConfigObjWithReset(t)
@@ -399,12 +613,25 @@ func TestTiers(t *testing.T) {
 		assert.Equal(t, "base", toolchainCfg.Tiers().DefaultSpaceTier())
 		assert.Equal(t, 24*time.Hour, toolchainCfg.Tiers().DurationBeforeChangeTierRequestDeletion())
 		assert.Equal(t, 5, toolchainCfg.Tiers().TemplateUpdateRequestMaxPoolSize())
+		assert.Equal(t, 0, toolchainCfg.Tiers().RolloutCanaryPercentage())
+		assert.Equal(t, 50, toolchainCfg.Tiers().RolloutMaxFailurePercentage())
+		assert.Equal(t, 10, toolchainCfg.Tiers().RolloutMinUpdatesBeforeHalt())
+		assert.Empty(t, toolchainCfg.Tiers().BundlesDirectory())
+		assert.Equal(t, time.Minute, toolchainCfg.Tiers().BundlesResyncPeriod())
+		assert.Equal(t, 3, toolchainCfg.Tiers().TemplateGCRetainedRevisions())
+		assert.Equal(t, time.Duration(0), toolchainCfg.Tiers().TemplateGCInterval())
 	})
 	t.Run("invalid", func(t *testing.T) {
 		cfg := commonconfig.NewToolchainConfigObjWithReset(t, testconfig.Tiers().DurationBeforeChangeTierRequestDeletion("rapid"))
+		cfg.Annotations = map[string]string{
+			HostConfigExtensionAnnotationKey: `{"tiers":{"bundles":{"resyncPeriod":"often"},"templateGC":{"retainedRevisions":-1,"interval":"sometimes"}}}`,
+		}
 		toolchainCfg := newToolchainConfig(cfg, map[string]map[string]string{})
 
 		assert.Equal(t, 24*time.Hour, toolchainCfg.Tiers().DurationBeforeChangeTierRequestDeletion())
+		assert.Equal(t, time.Minute, toolchainCfg.Tiers().BundlesResyncPeriod())
+		assert.Equal(t, 0, toolchainCfg.Tiers().TemplateGCRetainedRevisions())
+		assert.Equal(t, time.Duration(0), toolchainCfg.Tiers().TemplateGCInterval())
 	})
 	t.Run("non-default", func(t *testing.T) {
 		cfg := commonconfig.NewToolchainConfigObjWithReset(t, testconfig.Tiers().
@@ -412,12 +639,22 @@ func TestTiers(t *testing.T) {
 			DefaultSpaceTier("advanced").
 			DurationBeforeChangeTierRequestDeletion("48h").
 			TemplateUpdateRequestMaxPoolSize(40))
+		cfg.Annotations = map[string]string{
+			HostConfigExtensionAnnotationKey: `{"tiers":{"rollout":{"canaryPercentage":10,"maxFailurePercentage":20,"minUpdatesBeforeHalt":5},"bundles":{"directory":"/etc/tiers","resyncPeriod":"5m"},"templateGC":{"retainedRevisions":10,"interval":"6h"}}}`,
+		}
 		toolchainCfg := newToolchainConfig(cfg, map[string]map[string]string{})
 
 		assert.Equal(t, "advanced", toolchainCfg.Tiers().DefaultTier())
 		assert.Equal(t, "advanced", toolchainCfg.Tiers().DefaultSpaceTier())
 		assert.Equal(t, 48*time.Hour, toolchainCfg.Tiers().DurationBeforeChangeTierRequestDeletion())
 		assert.Equal(t, 40, toolchainCfg.Tiers().TemplateUpdateRequestMaxPoolSize())
+		assert.Equal(t, 10, toolchainCfg.Tiers().RolloutCanaryPercentage())
+		assert.Equal(t, 20, toolchainCfg.Tiers().RolloutMaxFailurePercentage())
+		assert.Equal(t, 5, toolchainCfg.Tiers().RolloutMinUpdatesBeforeHalt())
+		assert.Equal(t, "/etc/tiers", toolchainCfg.Tiers().BundlesDirectory())
+		assert.Equal(t, 5*time.Minute, toolchainCfg.Tiers().BundlesResyncPeriod())
+		assert.Equal(t, 10, toolchainCfg.Tiers().TemplateGCRetainedRevisions())
+		assert.Equal(t, 6*time.Hour, toolchainCfg.Tiers().TemplateGCInterval())
 	})
 }
 
@@ -427,19 +664,41 @@ func TestToolchainStatus(t *testing.T) {
 		toolchainCfg := newToolchainConfig(cfg, map[string]map[string]string{})
 
 		assert.Equal(t, 5*time.Second, toolchainCfg.ToolchainStatus().ToolchainStatusRefreshTime())
+		assert.Equal(t, 10*time.Minute, toolchainCfg.ToolchainStatus().DurationAfterUnready())
+		assert.Equal(t, time.Duration(0), toolchainCfg.ToolchainStatus().EscalationInterval())
+		assert.Equal(t, 24*time.Hour, toolchainCfg.ToolchainStatus().MaxEscalationInterval())
+		assert.Empty(t, toolchainCfg.ToolchainStatus().SuppressedComponents())
+		assert.Equal(t, time.Duration(0), toolchainCfg.ToolchainStatus().DigestInterval())
 	})
 	t.Run("non-default", func(t *testing.T) {
 		cfg := commonconfig.NewToolchainConfigObjWithReset(t, testconfig.ToolchainStatus().ToolchainStatusRefreshTime("10s"))
+		cfg.Annotations = map[string]string{
+			HostConfigExtensionAnnotationKey: `{"toolchainStatus":{"notifications":{"durationAfterUnready":"30m","escalationInterval":"2h",` +
+				`"maxEscalationInterval":"12h","suppressedComponents":["hostRoutes"],"digestInterval":"6h"}}}`,
+		}
 		toolchainCfg := newToolchainConfig(cfg, map[string]map[string]string{})
 
 		assert.Equal(t, 10*time.Second, toolchainCfg.ToolchainStatus().ToolchainStatusRefreshTime())
+		assert.Equal(t, 30*time.Minute, toolchainCfg.ToolchainStatus().DurationAfterUnready())
+		assert.Equal(t, 2*time.Hour, toolchainCfg.ToolchainStatus().EscalationInterval())
+		assert.Equal(t, 12*time.Hour, toolchainCfg.ToolchainStatus().MaxEscalationInterval())
+		assert.Equal(t, []string{"hostRoutes"}, toolchainCfg.ToolchainStatus().SuppressedComponents())
+		assert.Equal(t, 6*time.Hour, toolchainCfg.ToolchainStatus().DigestInterval())
 	})
 	t.Run("edge case", func(t *testing.T) {
 		cfg := commonconfig.NewToolchainConfigObjWithReset(t, testconfig.ToolchainStatus().ToolchainStatusRefreshTime("banana"))
+		cfg.Annotations = map[string]string{
+			HostConfigExtensionAnnotationKey: `{"toolchainStatus":{"notifications":{"durationAfterUnready":"banana","escalationInterval":"cherry",` +
+				`"maxEscalationInterval":"kiwi","digestInterval":"mango"}}}`,
+		}
 
 		toolchainCfg := newToolchainConfig(cfg, nil)
 
 		assert.Equal(t, 5*time.Second, toolchainCfg.ToolchainStatus().ToolchainStatusRefreshTime())
+		assert.Equal(t, 10*time.Minute, toolchainCfg.ToolchainStatus().DurationAfterUnready())
+		assert.Equal(t, time.Duration(0), toolchainCfg.ToolchainStatus().EscalationInterval())
+		assert.Equal(t, 24*time.Hour, toolchainCfg.ToolchainStatus().MaxEscalationInterval())
+		assert.Equal(t, time.Duration(0), toolchainCfg.ToolchainStatus().DigestInterval())
 	})
 }
 
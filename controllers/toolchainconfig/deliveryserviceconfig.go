@@ -23,3 +23,43 @@ func (d DeliveryServiceFactoryConfig) GetMailgunSenderEmail() string {
 func (d DeliveryServiceFactoryConfig) GetMailgunReplyToEmail() string {
 	return d.Notifications().MailgunReplyToEmail()
 }
+
+func (d DeliveryServiceFactoryConfig) GetSMTPHost() string {
+	return d.Notifications().SMTPHost()
+}
+
+func (d DeliveryServiceFactoryConfig) GetSMTPPort() int {
+	return d.Notifications().SMTPPort()
+}
+
+func (d DeliveryServiceFactoryConfig) GetSMTPTLSMode() string {
+	return d.Notifications().SMTPTLSMode()
+}
+
+func (d DeliveryServiceFactoryConfig) GetSMTPUsername() string {
+	return d.Notifications().SMTPUsername()
+}
+
+func (d DeliveryServiceFactoryConfig) GetSMTPPassword() string {
+	return d.Notifications().SMTPPassword()
+}
+
+func (d DeliveryServiceFactoryConfig) GetSMTPSenderEmail() string {
+	return d.Notifications().SMTPSenderEmail()
+}
+
+func (d DeliveryServiceFactoryConfig) GetSMTPReplyToEmail() string {
+	return d.Notifications().SMTPReplyToEmail()
+}
+
+func (d DeliveryServiceFactoryConfig) GetWebhookURL() string {
+	return d.Notifications().WebhookURL()
+}
+
+func (d DeliveryServiceFactoryConfig) GetWebhookFormat() string {
+	return d.Notifications().WebhookFormat()
+}
+
+func (d DeliveryServiceFactoryConfig) GetNotificationRouting() map[string]string {
+	return d.Notifications().NotificationRouting()
+}
@@ -0,0 +1,349 @@
+package toolchainconfig
+
+import (
+	"encoding/json"
+	"sync"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+
+	"github.com/pkg/errors"
+)
+
+// HostConfigExtensionAnnotationKey is the key of the ToolchainConfig annotation holding the host operator settings
+// which are not (yet) part of the ToolchainConfig spec. The value is a JSON document matching the HostConfigExtension type,
+// eg: `{"deactivation":{"throttle":{"maxDeactivationsPerInterval":50}}}`
+const HostConfigExtensionAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "host-config"
+
+// HostConfigExtension contains the host operator settings provided via the HostConfigExtensionAnnotationKey annotation
+type HostConfigExtension struct {
+	// Keeps parameters concerned with user deactivation
+	// +optional
+	Deactivation DeactivationConfigExtension `json:"deactivation,omitempty"`
+
+	// Keeps parameters concerned with notifications
+	// +optional
+	Notifications NotificationsConfigExtension `json:"notifications,omitempty"`
+
+	// Keeps parameters concerned with Spaces
+	// +optional
+	Spaces SpacesConfigExtension `json:"spaces,omitempty"`
+
+	// Keeps parameters concerned with the ToolchainStatus
+	// +optional
+	ToolchainStatus ToolchainStatusConfigExtension `json:"toolchainStatus,omitempty"`
+
+	// Keeps parameters concerned with the tiers
+	// +optional
+	Tiers TiersConfigExtension `json:"tiers,omitempty"`
+}
+
+// DeactivationConfigExtension contains the additional settings concerned with user deactivation
+type DeactivationConfigExtension struct {
+	// Throttle limits the number of users which can be deactivated within a given interval
+	// +optional
+	Throttle DeactivationThrottleConfig `json:"throttle,omitempty"`
+}
+
+// DeactivationThrottleConfig limits the number of deactivations within an interval, globally and per member cluster
+type DeactivationThrottleConfig struct {
+	// Interval is the duration of the window in which the deactivations are counted, eg. "1m"
+	// +optional
+	Interval *string `json:"interval,omitempty"`
+
+	// MaxDeactivationsPerInterval is the maximum number of users which can be deactivated within the interval, across all
+	// member clusters. A value of 0 disables the global limit.
+	// +optional
+	MaxDeactivationsPerInterval *int `json:"maxDeactivationsPerInterval,omitempty"`
+
+	// SpecificPerMemberCluster is the maximum number of users which can be deactivated within the interval, per member cluster
+	// +optional
+	// +mapType=atomic
+	SpecificPerMemberCluster map[string]int `json:"specificPerMemberCluster,omitempty"`
+}
+
+// NotificationsConfigExtension contains the additional settings concerned with notifications
+type NotificationsConfigExtension struct {
+	// SMTP contains the settings of the SMTP notification delivery service
+	// +optional
+	SMTP SMTPConfig `json:"smtp,omitempty"`
+
+	// Webhook contains the settings of the webhook notification delivery channel
+	// +optional
+	Webhook WebhookConfig `json:"webhook,omitempty"`
+
+	// Routing maps the notification types (eg. `toolchainstatus`, `deactivated`) to the delivery channel to use
+	// for them, ie, `email` or `webhook`. The notifications whose type is not listed here are sent by email.
+	// +optional
+	// +mapType=atomic
+	Routing map[string]string `json:"routing,omitempty"`
+
+	// Retries controls how the delivery of a notification is retried when it fails
+	// +optional
+	Retries NotificationRetriesConfig `json:"retries,omitempty"`
+
+	// QuietPeriod is the duration during which a notification identical to a notification already sent to the same recipient
+	// (same type, template, subject, content and context) is suppressed, eg. "24h". The deduplication is disabled by default ("0s").
+	// The notifications sent to the admins about the ToolchainStatus are never suppressed.
+	// +optional
+	QuietPeriod *string `json:"quietPeriod,omitempty"`
+
+	// MaxPerRecipientPerDay is the maximum number of notifications sent to the same recipient within 24 hours, eg. 10.
+	// The notifications beyond this limit are postponed. There is no limit by default (0). The notifications sent to the admins
+	// about the ToolchainStatus are not limited.
+	// +optional
+	MaxPerRecipientPerDay *int `json:"maxPerRecipientPerDay,omitempty"`
+
+	// Unsubscribe contains the settings of the unsubscribe tokens placed in the context of the non-essential notifications
+	// +optional
+	Unsubscribe UnsubscribeConfig `json:"unsubscribe,omitempty"`
+
+	// SupportURL is the URL of the page where the users can get help, which is mentioned in the notifications about changes
+	// of their account made by an admin (eg. a change of tier or a ban), `https://developers.redhat.com/developer-sandbox` by default
+	// +optional
+	SupportURL *string `json:"supportURL,omitempty"`
+}
+
+// UnsubscribeConfig contains the settings of the unsubscribe tokens, which allow the users to opt out of a category
+// of notifications (eg. the reminders) with a single click
+type UnsubscribeConfig struct {
+	// Secret contains the key of the signing key of the unsubscribe tokens in the notification secret
+	// +optional
+	Secret UnsubscribeSecret `json:"secret,omitempty"`
+
+	// TokenValidity is the duration during which an unsubscribe token can be used after the notification was created, eg. "720h" (default)
+	// +optional
+	TokenValidity *string `json:"tokenValidity,omitempty"`
+}
+
+// UnsubscribeSecret contains the keys of the unsubscribe settings in the notification secret
+type UnsubscribeSecret struct {
+	// SigningKey is the key of the key used to sign the unsubscribe tokens in the notification secret, `unsubscribeSigningKey` by default.
+	// No unsubscribe token is generated if the secret does not contain the signing key.
+	// +optional
+	SigningKey *string `json:"signingKey,omitempty"`
+}
+
+// NotificationRetriesConfig defines the exponential backoff between the attempts to deliver a notification,
+// and the number of attempts after which the delivery is abandoned
+type NotificationRetriesConfig struct {
+	// MaxAttempts is the maximum number of delivery attempts, after which the notification is marked as failed (5 by default)
+	// +optional
+	MaxAttempts *int `json:"maxAttempts,omitempty"`
+
+	// InitialBackoff is the delay before the second attempt, eg. "1m" (default). The delay doubles after each failed attempt.
+	// +optional
+	InitialBackoff *string `json:"initialBackoff,omitempty"`
+
+	// MaxBackoff is the maximum delay between two attempts, eg. "1h" (default)
+	// +optional
+	MaxBackoff *string `json:"maxBackoff,omitempty"`
+
+	// FailedRetention is the duration during which a notification whose delivery was abandoned is kept after it was marked as failed,
+	// eg. "720h" (default). The sent notifications are deleted after the `durationBeforeNotificationDeletion` instead.
+	// +optional
+	FailedRetention *string `json:"failedRetention,omitempty"`
+}
+
+// WebhookConfig contains the settings of the webhook notification delivery channel
+type WebhookConfig struct {
+	// Format is the format of the payload sent to the webhook: `json` (default) or `slack`
+	// +optional
+	Format *string `json:"format,omitempty"`
+
+	// Secret contains the key of the webhook URL in the notification secret
+	// +optional
+	Secret WebhookSecret `json:"secret,omitempty"`
+}
+
+// WebhookSecret contains the keys of the webhook settings in the notification secret
+type WebhookSecret struct {
+	// URL is the key of the webhook URL in the notification secret, `webhookURL` by default.
+	// The webhook channel is disabled if the secret does not contain the URL.
+	// +optional
+	URL *string `json:"url,omitempty"`
+}
+
+// SMTPConfig contains the settings of the SMTP server used to deliver the notifications when the `smtp` notification
+// delivery service is selected. The credentials are read from the notification secret.
+type SMTPConfig struct {
+	// Host is the hostname of the SMTP server
+	// +optional
+	Host *string `json:"host,omitempty"`
+
+	// Port is the port of the SMTP server, 587 by default
+	// +optional
+	Port *int `json:"port,omitempty"`
+
+	// TLSMode is the way the connection to the SMTP server is secured: `starttls` (default), `tls` (implicit TLS, usually on port 465)
+	// or `none`
+	// +optional
+	TLSMode *string `json:"tlsMode,omitempty"`
+
+	// SenderEmail is the email address used as the sender of the notifications
+	// +optional
+	SenderEmail *string `json:"senderEmail,omitempty"`
+
+	// ReplyToEmail is the (optional) reply-to email address of the notifications
+	// +optional
+	ReplyToEmail *string `json:"replyToEmail,omitempty"`
+
+	// Secret contains the keys of the SMTP credentials in the notification secret
+	// +optional
+	Secret SMTPSecret `json:"secret,omitempty"`
+}
+
+// SMTPSecret contains the keys of the SMTP credentials in the notification secret
+type SMTPSecret struct {
+	// Username is the key of the SMTP username in the notification secret, `smtpUsername` by default.
+	// No authentication is performed if the secret does not contain the username.
+	// +optional
+	Username *string `json:"username,omitempty"`
+
+	// Password is the key of the SMTP password in the notification secret, `smtpPassword` by default
+	// +optional
+	Password *string `json:"password,omitempty"`
+}
+
+// SpacesConfigExtension contains the settings concerned with Spaces
+type SpacesConfigExtension struct {
+	// ExpiringNotificationDays is the number of days before the expiration of a Space (see the `space-ttl` annotation)
+	// when the users bound to the Space are notified
+	// +optional
+	ExpiringNotificationDays *int `json:"expiringNotificationDays,omitempty"`
+}
+
+// ToolchainStatusConfigExtension contains the additional settings concerned with the ToolchainStatus
+type ToolchainStatusConfigExtension struct {
+	// Notifications controls the notifications sent to the admins when the ToolchainStatus is not ready
+	// +optional
+	Notifications ToolchainStatusNotificationsConfig `json:"notifications,omitempty"`
+}
+
+// ToolchainStatusNotificationsConfig contains the settings of the notifications sent to the admins about the ToolchainStatus
+type ToolchainStatusNotificationsConfig struct {
+	// DurationAfterUnready is the duration after which the admins are notified when the ToolchainStatus stays unready, eg. "10m" (default)
+	// +optional
+	DurationAfterUnready *string `json:"durationAfterUnready,omitempty"`
+
+	// EscalationInterval is the delay between the first notification and the first escalation sent while the ToolchainStatus
+	// stays unready, eg. "1h". The delay doubles after each escalation. The escalations are disabled by default ("0s").
+	// +optional
+	EscalationInterval *string `json:"escalationInterval,omitempty"`
+
+	// MaxEscalationInterval is the maximum delay between two escalations, eg. "24h" (default)
+	// +optional
+	MaxEscalationInterval *string `json:"maxEscalationInterval,omitempty"`
+
+	// SuppressedComponents is the list of components (`hostOperator`, `members`, `registrationService`, `hostRoutes`
+	// or `MasterUserRecord and UserAccount counter`) for which no notification is sent when they are not ready
+	// +optional
+	// +listType=set
+	SuppressedComponents []string `json:"suppressedComponents,omitempty"`
+
+	// DigestInterval is the interval between two digests summarizing the components which became unready during the interval,
+	// eg. "24h". No digest is sent if no component became unready. The digests are disabled by default ("0s").
+	// +optional
+	DigestInterval *string `json:"digestInterval,omitempty"`
+}
+
+// TiersConfigExtension contains the additional settings concerned with the tiers
+type TiersConfigExtension struct {
+	// Rollout controls how the updates of the NSTemplateTiers are rolled out to the MasterUserRecords and Spaces
+	// +optional
+	Rollout TierRolloutConfig `json:"rollout,omitempty"`
+
+	// Bundles controls the tier bundles loaded at runtime, in addition to the tiers embedded in the host operator
+	// +optional
+	Bundles TierBundlesConfig `json:"bundles,omitempty"`
+
+	// TemplateGC controls the garbage collection of the TierTemplates which are no longer used
+	// +optional
+	TemplateGC TierTemplateGCConfig `json:"templateGC,omitempty"`
+}
+
+// TierTemplateGCConfig contains the settings of the garbage collection of the TierTemplates which are no longer referenced
+// by any NSTemplateTier, TemplateUpdateRequest, MasterUserRecord or Space
+type TierTemplateGCConfig struct {
+	// RetainedRevisions is the number of previous revisions of each NSTemplateTier (from its `status.updates`) whose TierTemplates
+	// are retained so the tier can be rolled back to them, eg. 3 (default)
+	// +optional
+	RetainedRevisions *int `json:"retainedRevisions,omitempty"`
+
+	// Interval is the interval between two garbage collections, eg. "1h". The garbage collection is disabled by default ("0s").
+	// +optional
+	Interval *string `json:"interval,omitempty"`
+}
+
+// TierBundlesConfig contains the settings of the tier bundles loaded at runtime from a directory mounted in the host operator pod
+// and from the ConfigMaps labelled with `toolchain.dev.openshift.com/nstemplatetier-bundle` in the host operator namespace
+type TierBundlesConfig struct {
+	// Directory is the path of the directory containing the tier bundles, with the same layout as the `deploy/templates/nstemplatetiers`
+	// directory of the host operator (ie, a `metadata.yaml` file and a sub-directory per tier). No directory is loaded by default.
+	// +optional
+	Directory *string `json:"directory,omitempty"`
+
+	// ResyncPeriod is the interval at which the tier bundles are checked for changes, eg. "1m" (default)
+	// +optional
+	ResyncPeriod *string `json:"resyncPeriod,omitempty"`
+}
+
+// TierRolloutConfig contains the default settings of the rollout of the NSTemplateTier updates, which can be overridden per tier
+// with annotations
+type TierRolloutConfig struct {
+	// CanaryPercentage is the percentage of the outdated MasterUserRecords and Spaces which are updated first, before the rest
+	// of the rollout (0 by default, ie, no canary stage)
+	// +optional
+	CanaryPercentage *int `json:"canaryPercentage,omitempty"`
+
+	// MaxFailurePercentage is the percentage of failed updates beyond which the rollout is halted (50 by default).
+	// A value of 100 disables the halt.
+	// +optional
+	MaxFailurePercentage *int `json:"maxFailurePercentage,omitempty"`
+
+	// MinUpdatesBeforeHalt is the number of updates processed before the failure percentage is checked (10 by default),
+	// except at the end of the canary stage when it is always checked
+	// +optional
+	MinUpdatesBeforeHalt *int `json:"minUpdatesBeforeHalt,omitempty"`
+}
+
+// lastValidHostConfigExtension is the last host config extension which was successfully parsed. It is used instead of the
+// default values when the annotation becomes invalid, so a typo in one setting does not reset all the other settings.
+var lastValidHostConfigExtension = struct {
+	sync.RWMutex
+	ext HostConfigExtension
+}{}
+
+// hostConfigExtensionOrLastValid parses the host config extension annotation of the given ToolchainConfig.
+// Returns the last valid extension along with the parse error if the annotation is invalid.
+func hostConfigExtensionOrLastValid(config *toolchainv1alpha1.ToolchainConfig) (HostConfigExtension, error) {
+	ext, err := hostConfigExtension(config)
+	if err != nil {
+		lastValidHostConfigExtension.RLock()
+		defer lastValidHostConfigExtension.RUnlock()
+		return lastValidHostConfigExtension.ext, err
+	}
+	lastValidHostConfigExtension.Lock()
+	defer lastValidHostConfigExtension.Unlock()
+	lastValidHostConfigExtension.ext = ext
+	return ext, nil
+}
+
+// ValidateHostConfigExtension returns an error if the host config extension annotation of the given ToolchainConfig is invalid
+func ValidateHostConfigExtension(config *toolchainv1alpha1.ToolchainConfig) error {
+	_, err := hostConfigExtension(config)
+	return err
+}
+
+// hostConfigExtension parses the host config extension annotation of the given ToolchainConfig.
+// Returns an empty extension (ie, default values) if the annotation is not set.
+func hostConfigExtension(config *toolchainv1alpha1.ToolchainConfig) (HostConfigExtension, error) {
+	ext := HostConfigExtension{}
+	value, found := config.Annotations[HostConfigExtensionAnnotationKey]
+	if !found || value == "" {
+		return ext, nil
+	}
+	if err := json.Unmarshal([]byte(value), &ext); err != nil {
+		return HostConfigExtension{}, errors.Wrapf(err, "invalid value of the '%s' annotation", HostConfigExtensionAnnotationKey)
+	}
+	return ext, nil
+}
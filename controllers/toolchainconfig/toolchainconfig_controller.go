@@ -37,6 +37,16 @@ import (
 
 const configResourceName = "config"
 
+const (
+	// HostConfigExtensionValid is the type of the ToolchainConfig condition reporting whether the host config extension annotation is valid
+	HostConfigExtensionValid toolchainv1alpha1.ConditionType = "HostConfigExtensionValid"
+	// HostConfigExtensionValidReason is the reason of the HostConfigExtensionValid condition when the annotation is valid
+	HostConfigExtensionValidReason = "Valid"
+	// HostConfigExtensionInvalidReason is the reason of the HostConfigExtensionValid condition when the annotation is invalid,
+	// in which case the last valid values of the host config extension are used
+	HostConfigExtensionInvalidReason = "Invalid"
+)
+
 // DefaultReconcile requeue every 10 seconds by default to ensure the MemberOperatorConfig on each member remains synchronized with the ToolchainConfig
 var DefaultReconcile = reconcile.Result{RequeueAfter: 10 * time.Second}
 
@@ -105,6 +115,11 @@ func (r *Reconciler) Reconcile(ctx context.Context, request ctrl.Request) (ctrl.
 		return reconcile.Result{}, r.WrapErrorWithStatusUpdate(reqLogger, toolchainConfig, r.setStatusDeployRegistrationServiceFailed, err, "failed to load the latest configuration")
 	}
 
+	// Report an invalid host config extension annotation
+	if err := r.updateHostConfigExtensionStatus(reqLogger, toolchainConfig); err != nil {
+		return reconcile.Result{}, errs.Wrap(err, "failed to update the status of the host config extension")
+	}
+
 	// Deploy registration service
 	if err := r.ensureRegistrationService(reqLogger, toolchainConfig, getVars(request.Namespace, cfg)); err != nil {
 		// immediately reconcile again if there was an error
@@ -159,6 +174,21 @@ func (r *Reconciler) ensureRegistrationService(reqLogger logr.Logger, toolchainC
 	return r.updateStatusCondition(toolchainConfig, ToRegServiceDeployComplete(), false)
 }
 
+// updateHostConfigExtensionStatus sets the HostConfigExtensionValid condition when the host config extension annotation is set,
+// or when the condition was already set
+func (r *Reconciler) updateHostConfigExtensionStatus(reqLogger logr.Logger, toolchainConfig *toolchainv1alpha1.ToolchainConfig) error {
+	if err := ValidateHostConfigExtension(toolchainConfig); err != nil {
+		reqLogger.Error(err, "invalid host config extension, the last valid values are used")
+		return r.updateStatusCondition(toolchainConfig, ToHostConfigExtensionInvalid(err.Error()), false)
+	}
+	if _, found := toolchainConfig.Annotations[HostConfigExtensionAnnotationKey]; !found {
+		if _, found := condition.FindConditionByType(toolchainConfig.Status.Conditions, HostConfigExtensionValid); !found {
+			return nil
+		}
+	}
+	return r.updateStatusCondition(toolchainConfig, ToHostConfigExtensionValid(), false)
+}
+
 type templateVars map[string]string
 
 func getVars(namespace string, cfg ToolchainConfig) templateVars {
@@ -253,3 +283,22 @@ func ToRegServiceDeployFailure(msg string) toolchainv1alpha1.Condition {
 		Message: msg,
 	}
 }
+
+// ToHostConfigExtensionValid condition when the host config extension annotation is valid
+func ToHostConfigExtensionValid() toolchainv1alpha1.Condition {
+	return toolchainv1alpha1.Condition{
+		Type:   HostConfigExtensionValid,
+		Status: corev1.ConditionTrue,
+		Reason: HostConfigExtensionValidReason,
+	}
+}
+
+// ToHostConfigExtensionInvalid condition when the host config extension annotation is invalid
+func ToHostConfigExtensionInvalid(msg string) toolchainv1alpha1.Condition {
+	return toolchainv1alpha1.Condition{
+		Type:    HostConfigExtensionValid,
+		Status:  corev1.ConditionFalse,
+		Reason:  HostConfigExtensionInvalidReason,
+		Message: msg,
+	}
+}
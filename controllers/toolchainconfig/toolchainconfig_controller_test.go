@@ -184,6 +184,89 @@ func TestReconcile(t *testing.T) {
 		})
 	})
 
+	t.Run("host config extension", func(t *testing.T) {
+
+		t.Run("invalid annotation reported in the status", func(t *testing.T) {
+			// given
+			config := commonconfig.NewToolchainConfigObjWithReset(t, testconfig.AutomaticApproval().Enabled(true))
+			config.Annotations = map[string]string{
+				toolchainconfig.HostConfigExtensionAnnotationKey: `{"deactivation":{"throttle":{"maxDeactivationsPerInterval":20}}}`,
+			}
+			hostCl := test.NewFakeClient(t, config)
+			members := NewGetMemberClusters(NewMemberCluster(t, "member1", v1.ConditionTrue))
+			controller := newController(t, hostCl, members)
+			_, err := controller.Reconcile(context.TODO(), newRequest())
+			require.NoError(t, err)
+			testconfig.AssertThatToolchainConfig(t, test.HostOperatorNs, hostCl).
+				HasConditions(
+					toolchainconfig.ToHostConfigExtensionValid(),
+					toolchainconfig.ToSyncComplete(),
+					toolchainconfig.ToRegServiceDeploying("updated resources: [ServiceAccount: registration-service Role: registration-service RoleBinding: registration-service Deployment: registration-service Service: registration-service Route: registration-service Service: api Route: api]"))
+			// a malformed annotation
+			err = hostCl.Get(context.TODO(), types.NamespacedName{Name: config.Name, Namespace: config.Namespace}, config)
+			require.NoError(t, err)
+			config.Annotations[toolchainconfig.HostConfigExtensionAnnotationKey] = `{"deactivation":{"throttle":{"maxDeactivationsPerInterval":50}}`
+			require.NoError(t, hostCl.Update(context.TODO(), config))
+
+			// when
+			_, err = controller.Reconcile(context.TODO(), newRequest())
+
+			// then
+			require.NoError(t, err)
+			testconfig.AssertThatToolchainConfig(t, test.HostOperatorNs, hostCl).
+				HasConditions(
+					toolchainconfig.ToHostConfigExtensionInvalid("invalid value of the 'toolchain.dev.openshift.com/host-config' annotation: unexpected end of JSON input"),
+					toolchainconfig.ToSyncComplete(),
+					toolchainconfig.ToRegServiceDeployComplete())
+			// the last valid values are still used
+			actual, err := toolchainconfig.GetToolchainConfig(hostCl)
+			require.NoError(t, err)
+			assert.True(t, actual.AutomaticApproval().IsEnabled())
+			assert.Equal(t, 20, actual.Deactivation().MaxDeactivationsPerInterval())
+
+			t.Run("fixed annotation reported in the status", func(t *testing.T) {
+				// given
+				err = hostCl.Get(context.TODO(), types.NamespacedName{Name: config.Name, Namespace: config.Namespace}, config)
+				require.NoError(t, err)
+				config.Annotations[toolchainconfig.HostConfigExtensionAnnotationKey] = `{"deactivation":{"throttle":{"maxDeactivationsPerInterval":30}}}`
+				require.NoError(t, hostCl.Update(context.TODO(), config))
+
+				// when
+				_, err = controller.Reconcile(context.TODO(), newRequest())
+
+				// then
+				require.NoError(t, err)
+				testconfig.AssertThatToolchainConfig(t, test.HostOperatorNs, hostCl).
+					HasConditions(
+						toolchainconfig.ToHostConfigExtensionValid(),
+						toolchainconfig.ToSyncComplete(),
+						toolchainconfig.ToRegServiceDeployComplete())
+				actual, err := toolchainconfig.GetToolchainConfig(hostCl)
+				require.NoError(t, err)
+				assert.Equal(t, 30, actual.Deactivation().MaxDeactivationsPerInterval())
+			})
+		})
+
+		t.Run("error updating the status", func(t *testing.T) {
+			// given
+			config := commonconfig.NewToolchainConfigObjWithReset(t)
+			config.Annotations = map[string]string{
+				toolchainconfig.HostConfigExtensionAnnotationKey: `{"deactivation":`,
+			}
+			hostCl := test.NewFakeClient(t, config)
+			hostCl.MockStatusUpdate = func(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+				return fmt.Errorf("status update error")
+			}
+			controller := newController(t, hostCl, NewGetMemberClusters())
+
+			// when
+			_, err := controller.Reconcile(context.TODO(), newRequest())
+
+			// then
+			require.EqualError(t, err, "failed to update the status of the host config extension: status update error")
+		})
+	})
+
 	t.Run("failures", func(t *testing.T) {
 
 		t.Run("error getting the toolchainconfig resource", func(t *testing.T) {
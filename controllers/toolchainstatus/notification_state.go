@@ -0,0 +1,134 @@
+package toolchainstatus
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+
+	"github.com/go-logr/logr"
+	errs "github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// NotificationStateAnnotationKey is the key of the ToolchainStatus annotation holding the state of the notifications sent to the admins,
+// ie, the escalations sent during the current unready period and the components which became unready since the last digest
+const NotificationStateAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "notification-state"
+
+// notificationState is the state of the notifications sent to the admins, stored in the NotificationStateAnnotationKey annotation
+type notificationState struct {
+	// UnreadyComponents are the components which were not ready during the previous reconcile
+	UnreadyComponents []string `json:"unreadyComponents,omitempty"`
+	// Escalations is the number of escalations sent since the unready notification
+	Escalations int `json:"escalations,omitempty"`
+	// LastUnreadyNotificationTime is the time when the last unready notification or escalation was sent
+	LastUnreadyNotificationTime *metav1.Time `json:"lastUnreadyNotificationTime,omitempty"`
+	// Flaps is the number of times each component became unready since the last digest
+	Flaps map[string]int `json:"flaps,omitempty"`
+	// LastDigestTime is the time when the last digest was sent (or when the first digest interval started)
+	LastDigestTime *metav1.Time `json:"lastDigestTime,omitempty"`
+}
+
+// getNotificationState returns the notification state stored in the annotation of the given ToolchainStatus.
+// Returns an empty state if the annotation is missing or invalid.
+func getNotificationState(logger logr.Logger, toolchainStatus *toolchainv1alpha1.ToolchainStatus) *notificationState {
+	state := &notificationState{}
+	value, found := toolchainStatus.Annotations[NotificationStateAnnotationKey]
+	if !found || value == "" {
+		return state
+	}
+	if err := json.Unmarshal([]byte(value), state); err != nil {
+		logger.Error(err, "invalid notification state, resetting it", "annotation", NotificationStateAnnotationKey)
+		return &notificationState{}
+	}
+	return state
+}
+
+// saveNotificationState stores the given notification state in the annotation of the given ToolchainStatus, if it changed.
+// The annotation is removed when the state is empty.
+func saveNotificationState(cl client.Client, toolchainStatus *toolchainv1alpha1.ToolchainStatus, state *notificationState) error {
+	if reflect.DeepEqual(state, &notificationState{}) {
+		if _, found := toolchainStatus.Annotations[NotificationStateAnnotationKey]; !found {
+			return nil
+		}
+		delete(toolchainStatus.Annotations, NotificationStateAnnotationKey)
+		return errs.Wrap(cl.Update(context.TODO(), toolchainStatus), "unable to save the notification state")
+	}
+	value, err := json.Marshal(state)
+	if err != nil {
+		return errs.Wrap(err, "unable to marshal the notification state")
+	}
+	if toolchainStatus.Annotations[NotificationStateAnnotationKey] == string(value) {
+		return nil
+	}
+	if toolchainStatus.Annotations == nil {
+		toolchainStatus.Annotations = map[string]string{}
+	}
+	toolchainStatus.Annotations[NotificationStateAnnotationKey] = string(value)
+	return errs.Wrap(cl.Update(context.TODO(), toolchainStatus), "unable to save the notification state")
+}
+
+// recordFlaps increments the number of flaps of the given unready components which were ready during the previous reconcile
+func (s *notificationState) recordFlaps(unreadyComponents []string) {
+	for _, component := range unreadyComponents {
+		if !contains(s.UnreadyComponents, component) {
+			if s.Flaps == nil {
+				s.Flaps = map[string]int{}
+			}
+			s.Flaps[component]++
+		}
+	}
+	s.UnreadyComponents = unreadyComponents
+}
+
+// unreadyNotificationSent records that the unready notification was sent
+func (s *notificationState) unreadyNotificationSent(now time.Time) {
+	s.Escalations = 0
+	s.LastUnreadyNotificationTime = &metav1.Time{Time: now}
+}
+
+// escalationSent records that an escalation was sent
+func (s *notificationState) escalationSent(now time.Time) {
+	s.Escalations++
+	s.LastUnreadyNotificationTime = &metav1.Time{Time: now}
+}
+
+// resetEscalations clears the escalations once the ToolchainStatus is ready again
+func (s *notificationState) resetEscalations() {
+	s.Escalations = 0
+	s.LastUnreadyNotificationTime = nil
+}
+
+// resetDigest clears the components which became unready since the last digest, along with the time of the last digest
+func (s *notificationState) resetDigest() {
+	s.UnreadyComponents = nil
+	s.Flaps = nil
+	s.LastDigestTime = nil
+}
+
+// escalationDue returns `true` if the next escalation should be sent, ie, if the escalation interval (doubled after each escalation,
+// up to the given maximum) has elapsed since the last unready notification or escalation
+func (s *notificationState) escalationDue(now time.Time, interval, maxInterval time.Duration) bool {
+	if interval <= 0 || s.LastUnreadyNotificationTime == nil {
+		return false
+	}
+	for i := 0; i < s.Escalations && interval < maxInterval; i++ {
+		interval *= 2
+	}
+	if interval > maxInterval {
+		interval = maxInterval
+	}
+	return !now.Before(s.LastUnreadyNotificationTime.Add(interval))
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package toolchainstatus
+
+import (
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestGetAndSaveNotificationState(t *testing.T) {
+	// given
+	logger := logf.Log.WithName("test")
+
+	t.Run("empty state when annotation is missing", func(t *testing.T) {
+		// when
+		state := getNotificationState(logger, &toolchainv1alpha1.ToolchainStatus{})
+
+		// then
+		assert.Equal(t, &notificationState{}, state)
+	})
+
+	t.Run("empty state when annotation is invalid", func(t *testing.T) {
+		// given
+		toolchainStatus := &toolchainv1alpha1.ToolchainStatus{}
+		toolchainStatus.Annotations = map[string]string{NotificationStateAnnotationKey: "{"}
+
+		// when
+		state := getNotificationState(logger, toolchainStatus)
+
+		// then
+		assert.Equal(t, &notificationState{}, state)
+	})
+
+	t.Run("state is saved and read back", func(t *testing.T) {
+		// given
+		toolchainStatus := &toolchainv1alpha1.ToolchainStatus{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "toolchain-status",
+				Namespace: test.HostOperatorNs,
+			},
+		}
+		cl := test.NewFakeClient(t, toolchainStatus)
+		state := &notificationState{
+			UnreadyComponents: []string{"members"},
+			Escalations:       2,
+			Flaps:             map[string]int{"members": 3},
+		}
+
+		// when
+		err := saveNotificationState(cl, toolchainStatus, state)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, state, getNotificationState(logger, toolchainStatus))
+	})
+}
+
+func TestRecordFlaps(t *testing.T) {
+	// given
+	state := &notificationState{}
+
+	// when
+	state.recordFlaps([]string{"members", "hostRoutes"})
+	state.recordFlaps([]string{"members"})
+	state.recordFlaps(nil)
+	state.recordFlaps([]string{"members"})
+
+	// then
+	assert.Equal(t, map[string]int{"members": 2, "hostRoutes": 1}, state.Flaps)
+	assert.Equal(t, []string{"members"}, state.UnreadyComponents)
+}
+
+func TestEscalationDue(t *testing.T) {
+	// given
+	now := time.Now()
+	stateWith := func(escalations int, lastNotification time.Duration) *notificationState {
+		return &notificationState{
+			Escalations:                 escalations,
+			LastUnreadyNotificationTime: &metav1.Time{Time: now.Add(-lastNotification)},
+		}
+	}
+
+	t.Run("first escalation after the interval", func(t *testing.T) {
+		assert.False(t, stateWith(0, 59*time.Minute).escalationDue(now, time.Hour, 24*time.Hour))
+		assert.True(t, stateWith(0, time.Hour).escalationDue(now, time.Hour, 24*time.Hour))
+	})
+
+	t.Run("interval doubles after each escalation", func(t *testing.T) {
+		assert.False(t, stateWith(2, 3*time.Hour).escalationDue(now, time.Hour, 24*time.Hour))
+		assert.True(t, stateWith(2, 4*time.Hour).escalationDue(now, time.Hour, 24*time.Hour))
+	})
+
+	t.Run("interval is capped", func(t *testing.T) {
+		assert.True(t, stateWith(10, 6*time.Hour).escalationDue(now, time.Hour, 6*time.Hour))
+	})
+
+	t.Run("escalations disabled", func(t *testing.T) {
+		assert.False(t, stateWith(0, 48*time.Hour).escalationDue(now, 0, 24*time.Hour))
+	})
+
+	t.Run("no unready notification sent", func(t *testing.T) {
+		assert.False(t, (&notificationState{}).escalationDue(now, time.Hour, 24*time.Hour))
+	})
+}
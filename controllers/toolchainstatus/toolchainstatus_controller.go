@@ -21,6 +21,7 @@ import (
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
 	"github.com/codeready-toolchain/host-operator/pkg/counter"
 	"github.com/codeready-toolchain/host-operator/pkg/templates/registrationservice"
+	"github.com/codeready-toolchain/host-operator/pkg/templates/validation"
 	"github.com/codeready-toolchain/host-operator/version"
 	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
 	"github.com/codeready-toolchain/toolchain-common/pkg/condition"
@@ -64,19 +65,25 @@ const (
 	hostOperatorTag        statusComponentTag = "hostOperator"
 	memberConnectionsTag   statusComponentTag = "members"
 	counterTag             statusComponentTag = "MasterUserRecord and UserAccount counter"
-	durationAfterUnready   time.Duration      = 10 * time.Minute
 )
 
 const (
-	adminUnreadyNotificationSubject  = "ToolchainStatus has been in an unready status for an extended period"
-	adminRestoredNotificationSubject = "ToolchainStatus has now been restored to ready status"
+	adminUnreadyNotificationSubject    = "ToolchainStatus has been in an unready status for an extended period"
+	adminRestoredNotificationSubject   = "ToolchainStatus has now been restored to ready status"
+	adminEscalationNotificationSubject = "ToolchainStatus is still in an unready status"
+	adminDigestNotificationSubject     = "ToolchainStatus digest: components which became unready"
+
+	// NotificationTypeToolchainStatus the type of the notifications sent to the admins when the ToolchainStatus becomes unready or is restored
+	NotificationTypeToolchainStatus = notify.NotificationTypeToolchainStatus
 )
 
 type toolchainStatusNotificationType string
 
 const (
-	unreadyStatus  toolchainStatusNotificationType = "unready"
-	restoredStatus toolchainStatusNotificationType = "restored"
+	unreadyStatus    toolchainStatusNotificationType = "unready"
+	restoredStatus   toolchainStatusNotificationType = "restored"
+	escalationStatus toolchainStatusNotificationType = "escalation"
+	digestStatus     toolchainStatusNotificationType = "digest"
 )
 
 const (
@@ -95,6 +102,15 @@ const (
 <div><span style="font-weight:bold;padding-right:10px">{{$key}}:</span>{{$value}}</div>
 {{end}}
 </div>
+{{end}}`
+
+	digestNotificationTemplate = `<h3>The following components became unready since the last digest<h3>
+{{range $key, $value := .clusterURLs}}
+<div><span style="font-weight:bold;padding-right:10px">{{$key}}:</span>{{$value}}</div>
+{{end}}
+
+{{range $component, $count := .flaps}}
+<div><span style="font-weight:bold;padding-right:10px">{{$component}}:</span>{{$count}} time(s)</div>
 {{end}}`
 )
 
@@ -118,6 +134,8 @@ type Reconciler struct {
 	GetMembersFunc cluster.GetMemberClustersFunc
 	HTTPClientImpl HTTPClient
 	Namespace      string
+	// TemplateValidation is the result of the validation of the templates at startup, reported in the status of the host operator
+	TemplateValidation *validation.Result
 }
 
 //+kubebuilder:rbac:groups=toolchain.dev.openshift.com,resources=toolchainstatuses,verbs=get;list;watch;create;update;patch;delete
@@ -196,31 +214,73 @@ func (r *Reconciler) aggregateAndUpdateStatus(reqLogger logr.Logger, toolchainSt
 		}
 	}
 
+	config, err := toolchainconfig.GetToolchainConfig(r.Client)
+	if err != nil {
+		return errs.Wrapf(err, "unable to get ToolchainConfig")
+	}
+	// the suppressed components neither trigger notifications nor appear in the digests
+	notifiableComponents := notifiable(unreadyComponents, config.ToolchainStatus().SuppressedComponents())
+	state := getNotificationState(reqLogger, toolchainStatus)
+	digestsEnabled := config.ToolchainStatus().DigestInterval() > 0
+	if digestsEnabled {
+		state.recordFlaps(notifiableComponents)
+	}
+
 	// if any components were not ready then set the overall status to not ready
 	if len(unreadyComponents) > 0 {
-		err := r.notificationCheck(reqLogger, toolchainStatus)
-		if err != nil {
+		if len(notifiableComponents) > 0 {
+			if err := r.notificationCheck(reqLogger, config, toolchainStatus, state); err != nil {
+				return err
+			}
+		}
+		if err := r.setStatusNotReady(reqLogger, toolchainStatus, fmt.Sprintf("components not ready: %v", unreadyComponents)); err != nil {
+			return err
+		}
+	} else {
+		state.resetEscalations()
+		if err := r.setStatusReady(reqLogger, toolchainStatus); err != nil {
 			return err
 		}
+	}
 
-		return r.setStatusNotReady(reqLogger, toolchainStatus, fmt.Sprintf("components not ready: %v", unreadyComponents))
+	if err := r.digestCheck(reqLogger, config, toolchainStatus, state); err != nil {
+		return err
+	}
+	// do not keep track of the escalations and digests when they are disabled, so the ToolchainStatus is not updated for nothing
+	if config.ToolchainStatus().EscalationInterval() <= 0 {
+		state.resetEscalations()
+	}
+	if !digestsEnabled {
+		state.resetDigest()
 	}
+	return saveNotificationState(r.Client, toolchainStatus, state)
+}
 
-	return r.setStatusReady(reqLogger, toolchainStatus)
+// notifiable returns the given unready components which are not suppressed
+func notifiable(unreadyComponents, suppressedComponents []string) []string {
+	var result []string
+	for _, component := range unreadyComponents {
+		if !contains(suppressedComponents, component) {
+			result = append(result, component)
+		}
+	}
+	return result
 }
 
-func (r *Reconciler) notificationCheck(reqLogger logr.Logger, toolchainStatus *toolchainv1alpha1.ToolchainStatus) error {
+func (r *Reconciler) notificationCheck(reqLogger logr.Logger, config toolchainconfig.ToolchainConfig, toolchainStatus *toolchainv1alpha1.ToolchainStatus,
+	state *notificationState) error {
 	// If the current ToolchainStatus:
 	// a) Is currently not ready,
 	// b) has not been ready for longer than the configured threshold, and
 	// c) no notification has been already sent, then
-	// send a notification to the admin mailing list
+	// send a notification to the admin mailing list.
+	// Once the notification was sent, escalations are sent at increasing intervals while the ToolchainStatus stays unready
 	c, found := condition.FindConditionByType(toolchainStatus.Status.Conditions, toolchainv1alpha1.ConditionReady)
 	if found && c.Status == corev1.ConditionFalse {
-		threshold := time.Now().Add(-durationAfterUnready)
+		threshold := time.Now().Add(-config.ToolchainStatus().DurationAfterUnready())
 		if c.LastTransitionTime.Before(&metav1.Time{Time: threshold}) {
 			if !condition.IsTrue(toolchainStatus.Status.Conditions, toolchainv1alpha1.ToolchainStatusUnreadyNotificationCreated) {
-				if err := r.sendToolchainStatusNotification(reqLogger, toolchainStatus, unreadyStatus); err != nil {
+				if err := r.sendToolchainStatusNotification(reqLogger, toolchainStatus, unreadyStatus, state); err != nil {
 					reqLogger.Error(err, "Failed to create toolchain status unready notification")
 
 					// set the failed to create notification status condition
@@ -228,11 +288,28 @@ func (r *Reconciler) notificationCheck(reqLogger logr.Logger, toolchainStatus *t
 						r.setStatusUnreadyNotificationCreationFailed, err,
 						"Failed to create toolchain status unready notification")
 				}
+				state.unreadyNotificationSent(time.Now())
 
 				if err := r.setStatusToolchainStatusUnreadyNotificationCreated(reqLogger, toolchainStatus); err != nil {
 					reqLogger.Error(err, "Failed to update notification created status")
 					return err
 				}
+				return nil
+			}
+
+			if state.LastUnreadyNotificationTime == nil {
+				// the unready notification was sent before the escalations were tracked
+				state.unreadyNotificationSent(time.Now())
+				return nil
+			}
+			if state.escalationDue(time.Now(), config.ToolchainStatus().EscalationInterval(), config.ToolchainStatus().MaxEscalationInterval()) {
+				if err := r.sendToolchainStatusNotification(reqLogger, toolchainStatus, escalationStatus, state); err != nil {
+					reqLogger.Error(err, "Failed to create toolchain status escalation notification")
+					return r.wrapErrorWithStatusUpdate(reqLogger, toolchainStatus,
+						r.setStatusUnreadyNotificationCreationFailed, err,
+						"Failed to create toolchain status escalation notification")
+				}
+				state.escalationSent(time.Now())
 			}
 		}
 	}
@@ -240,6 +317,33 @@ func (r *Reconciler) notificationCheck(reqLogger logr.Logger, toolchainStatus *t
 	return nil
 }
 
+// digestCheck sends a digest of the components which became unready once the digest interval elapsed, unless no component became unready
+func (r *Reconciler) digestCheck(reqLogger logr.Logger, config toolchainconfig.ToolchainConfig, toolchainStatus *toolchainv1alpha1.ToolchainStatus,
+	state *notificationState) error {
+	interval := config.ToolchainStatus().DigestInterval()
+	if interval <= 0 {
+		return nil
+	}
+	now := time.Now()
+	if state.LastDigestTime == nil {
+		// start the first digest interval
+		state.LastDigestTime = &metav1.Time{Time: now}
+		return nil
+	}
+	if now.Before(state.LastDigestTime.Add(interval)) {
+		return nil
+	}
+	if len(state.Flaps) > 0 {
+		if err := r.sendToolchainStatusNotification(reqLogger, toolchainStatus, digestStatus, state); err != nil {
+			reqLogger.Error(err, "Failed to create toolchain status digest notification")
+			return errs.Wrap(err, "Failed to create toolchain status digest notification")
+		}
+	}
+	state.Flaps = nil
+	state.LastDigestTime = &metav1.Time{Time: now}
+	return nil
+}
+
 func (r *Reconciler) restoredCheck(reqLogger logr.Logger, toolchainStatus *toolchainv1alpha1.ToolchainStatus) error {
 	// If the current ToolchainStatus:
 	// a) Was not ready before,
@@ -248,7 +352,7 @@ func (r *Reconciler) restoredCheck(reqLogger logr.Logger, toolchainStatus *toolc
 	// send a notification to the admin mailing list
 	if condition.IsFalse(toolchainStatus.Status.Conditions, toolchainv1alpha1.ConditionReady) {
 		if condition.IsTrue(toolchainStatus.Status.Conditions, toolchainv1alpha1.ToolchainStatusUnreadyNotificationCreated) {
-			if err := r.sendToolchainStatusNotification(reqLogger, toolchainStatus, restoredStatus); err != nil {
+			if err := r.sendToolchainStatusNotification(reqLogger, toolchainStatus, restoredStatus, nil); err != nil {
 				reqLogger.Error(err, "Failed to create toolchain status restored notification")
 				// set the failed to create notification status condition
 				return r.wrapErrorWithStatusUpdate(reqLogger, toolchainStatus,
@@ -299,7 +403,23 @@ func (r *Reconciler) hostOperatorHandleStatus(reqLogger logr.Logger, toolchainSt
 	// update toolchainStatus
 	operatorStatus.Conditions = deploymentConditions
 	toolchainStatus.Status.HostOperator = operatorStatus
-	return err == nil
+	return r.templatesHandleStatus(reqLogger, operatorStatus) && err == nil
+}
+
+// templatesHandleStatus revalidates the ConfigMaps overriding the notification templates and adds the result of the validation
+// of the templates to the status of the host operator. It returns false if some embedded templates are invalid
+func (r *Reconciler) templatesHandleStatus(reqLogger logr.Logger, operatorStatus *toolchainv1alpha1.HostOperatorStatus) bool {
+	if r.TemplateValidation == nil {
+		return true
+	}
+	r.TemplateValidation.ValidateTemplateOverrides(r.Client, r.Namespace)
+	condition := r.TemplateValidation.Condition()
+	operatorStatus.Conditions = append(operatorStatus.Conditions, condition)
+	if condition.Reason == validation.InvalidTemplatesReason {
+		reqLogger.Info("some templates are invalid", "message", condition.Message)
+		return false
+	}
+	return true
 }
 
 // registrationServiceHandleStatus retrieves the Deployment for the registration service and adds its status to ToolchainStatus. It returns false
@@ -430,7 +550,7 @@ func getAPIEndpoint(clusterName string, memberClusters []*cluster.CachedToolchai
 }
 
 func (r *Reconciler) sendToolchainStatusNotification(logger logr.Logger,
-	toolchainStatus *toolchainv1alpha1.ToolchainStatus, status toolchainStatusNotificationType) error {
+	toolchainStatus *toolchainv1alpha1.ToolchainStatus, status toolchainStatusNotificationType, state *notificationState) error {
 
 	config, err := toolchainconfig.GetToolchainConfig(r.Client)
 	if err != nil {
@@ -446,7 +566,7 @@ func (r *Reconciler) sendToolchainStatusNotification(logger logr.Logger,
 	contentString := ""
 	subjectString := ""
 	switch status {
-	case unreadyStatus:
+	case unreadyStatus, escalationStatus:
 		toolchainStatus = toolchainStatus.DeepCopy()
 		toolchainStatus.ManagedFields = nil // we don't need these managed fields in the notification
 
@@ -456,6 +576,15 @@ func (r *Reconciler) sendToolchainStatusNotification(logger logr.Logger,
 			return err
 		}
 		subjectString = adminUnreadyNotificationSubject
+		if status == escalationStatus {
+			subjectString = fmt.Sprintf("%s (escalation #%d)", adminEscalationNotificationSubject, state.Escalations+1)
+		}
+	case digestStatus:
+		contentString, err = GenerateDigestNotificationContent(ClusterURLs(toolchainStatus), state.Flaps)
+		if err != nil {
+			return err
+		}
+		subjectString = adminDigestNotificationSubject
 	case restoredStatus:
 		contentString = "<div><pre>ToolchainStatus is back to ready status.</pre></div>"
 		subjectString = adminRestoredNotificationSubject
@@ -466,6 +595,7 @@ func (r *Reconciler) sendToolchainStatusNotification(logger logr.Logger,
 	notification, err := notify.NewNotificationBuilder(r.Client, toolchainStatus.Namespace).
 		WithName(fmt.Sprintf("toolchainstatus-%s-%s", string(status), tsValue)).
 		WithControllerReference(toolchainStatus, r.Scheme).
+		WithNotificationType(NotificationTypeToolchainStatus).
 		WithSubjectAndContent(subjectString, contentString).
 		Create(config.Notifications().AdminEmail())
 
@@ -494,6 +624,24 @@ func ClusterURLs(instance *toolchainv1alpha1.ToolchainStatus) map[string]string
 	}
 }
 
+// GenerateDigestNotificationContent generates the content of the digest listing the number of times each component became unready
+func GenerateDigestNotificationContent(clusterURLs map[string]string, flaps map[string]int) (string, error) {
+	tmpl, err := template.New("digest").Parse(digestNotificationTemplate)
+	if err != nil {
+		return "", err
+	}
+
+	var output bytes.Buffer
+	templateContext := map[string]interface{}{
+		"clusterURLs": clusterURLs,
+		"flaps":       flaps,
+	}
+	if err := tmpl.Execute(&output, templateContext); err != nil {
+		return "", err
+	}
+	return output.String(), nil
+}
+
 type ComponentNotReadyStatus struct {
 	ComponentType string
 	ComponentName string
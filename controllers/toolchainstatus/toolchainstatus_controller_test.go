@@ -3,6 +3,7 @@ package toolchainstatus
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -17,11 +18,15 @@ import (
 	"k8s.io/apimachinery/pkg/util/intstr"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	notify "github.com/codeready-toolchain/host-operator/controllers/notification"
 	"github.com/codeready-toolchain/host-operator/controllers/toolchainconfig"
 	"github.com/codeready-toolchain/host-operator/pkg/counter"
 	"github.com/codeready-toolchain/host-operator/pkg/metrics"
+	"github.com/codeready-toolchain/host-operator/pkg/templates/assets"
 	"github.com/codeready-toolchain/host-operator/pkg/templates/registrationservice"
+	"github.com/codeready-toolchain/host-operator/pkg/templates/validation"
 	. "github.com/codeready-toolchain/host-operator/test"
+	testnstemplatetiers "github.com/codeready-toolchain/host-operator/test/templates/nstemplatetiers"
 	"github.com/codeready-toolchain/host-operator/version"
 	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
 	commonconfig "github.com/codeready-toolchain/toolchain-common/pkg/configuration"
@@ -806,6 +811,101 @@ func TestToolchainStatusConditions(t *testing.T) {
 	})
 }
 
+func TestToolchainStatusTemplateValidation(t *testing.T) {
+	// given
+	logf.SetLogger(zap.New(zap.UseDevMode(true)))
+	restore := test.SetEnvVarsAndRestore(t, test.Env(commonconfig.OperatorNameEnvVar, defaultHostOperatorName))
+	defer restore()
+	requestName := toolchainconfig.ToolchainStatusName
+	hostOperatorDeployment := newDeploymentWithConditions(defaultHostOperatorDeploymentName, status.DeploymentAvailableCondition(), status.DeploymentProgressingCondition())
+	registrationServiceDeployment := newDeploymentWithConditions(registrationservice.ResourceName, status.DeploymentAvailableCondition(), status.DeploymentProgressingCondition())
+	memberStatus := newMemberStatus(ready())
+	s := scheme.Scheme
+	require.NoError(t, apis.AddToScheme(s))
+
+	t.Run("all templates valid", func(t *testing.T) {
+		// given
+		reconciler, req, fakeClient := prepareReconcile(t, requestName, newResponseGood(), []string{"member-1", "member-2"},
+			hostOperatorDeployment, memberStatus, registrationServiceDeployment, NewToolchainStatus(), proxyRoute())
+		reconciler.TemplateValidation = validation.ValidateTemplates(s, test.HostOperatorNs, assets.NewAssets(testnstemplatetiers.AssetNames, testnstemplatetiers.Asset))
+
+		// when
+		res, err := reconciler.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, requeueResult, res)
+		AssertThatToolchainStatus(t, req.Namespace, requestName, fakeClient).
+			HasConditions(componentsReady(), unreadyNotificationNotCreated()).
+			HasHostOperatorStatus(hostOperatorStatusReady(toolchainv1alpha1.Condition{
+				Type:   validation.TemplatesValid,
+				Status: corev1.ConditionTrue,
+				Reason: validation.AllTemplatesValidReason,
+			}))
+	})
+
+	t.Run("invalid template override", func(t *testing.T) {
+		// given
+		override := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "userdeactivated-override",
+				Namespace: test.HostOperatorNs,
+				Labels: map[string]string{
+					notify.TemplateOverrideLabelKey: "userdeactivated",
+				},
+			},
+			Data: map[string]string{
+				"notification.html": "<p>Your account was deactivated</p>",
+			},
+		}
+		reconciler, req, fakeClient := prepareReconcile(t, requestName, newResponseGood(), []string{"member-1", "member-2"},
+			hostOperatorDeployment, memberStatus, registrationServiceDeployment, NewToolchainStatus(), proxyRoute(), override)
+		reconciler.TemplateValidation = validation.ValidateTemplates(s, test.HostOperatorNs, assets.NewAssets(testnstemplatetiers.AssetNames, testnstemplatetiers.Asset))
+
+		// when
+		res, err := reconciler.Reconcile(context.TODO(), req)
+
+		// then the embedded template is used instead, hence the host operator is still ready
+		require.NoError(t, err)
+		assert.Equal(t, requeueResult, res)
+		AssertThatToolchainStatus(t, req.Namespace, requestName, fakeClient).
+			HasConditions(componentsReady(), unreadyNotificationNotCreated()).
+			HasHostOperatorStatus(hostOperatorStatusReady(toolchainv1alpha1.Condition{
+				Type:    validation.TemplatesValid,
+				Status:  corev1.ConditionFalse,
+				Reason:  validation.InvalidTemplateOverridesReason,
+				Message: "invalid override of the 'userdeactivated' notification template: the ConfigMap 'userdeactivated-override' must contain notification.html and subject.txt",
+			}))
+	})
+
+	t.Run("invalid tier template", func(t *testing.T) {
+		// given
+		reconciler, req, fakeClient := prepareReconcile(t, requestName, newResponseGood(), []string{"member-1", "member-2"},
+			hostOperatorDeployment, memberStatus, registrationServiceDeployment, NewToolchainStatus(), proxyRoute())
+		reconciler.TemplateValidation = validation.ValidateTemplates(s, test.HostOperatorNs, assets.NewAssets(testnstemplatetiers.AssetNames, func(name string) ([]byte, error) {
+			if name == "metadata.yaml" {
+				return testnstemplatetiers.Asset(name)
+			}
+			return nil, fmt.Errorf("an error")
+		}))
+
+		// when
+		res, err := reconciler.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, requeueResult, res)
+		AssertThatToolchainStatus(t, req.Namespace, requestName, fakeClient).
+			HasConditions(componentsNotReady(string(hostOperatorTag))).
+			HasHostOperatorStatus(hostOperatorStatusReady(toolchainv1alpha1.Condition{
+				Type:    validation.TemplatesValid,
+				Status:  corev1.ConditionFalse,
+				Reason:  validation.InvalidTemplatesReason,
+				Message: "unable to generate the TierTemplates and NSTemplateTiers: unable to load templates: an error",
+			}))
+	})
+}
+
 func TestToolchainStatusReadyConditionTimestamps(t *testing.T) {
 	// set the operator name environment variable for all the tests which is used to get the host operator deployment name
 	restore := test.SetEnvVarsAndRestore(t, test.Env(commonconfig.OperatorNameEnvVar, defaultHostOperatorName))
@@ -1019,6 +1119,7 @@ func TestToolchainStatusNotifications(t *testing.T) {
 				require.NotNil(t, notification)
 				require.Equal(t, notification.Spec.Subject, "ToolchainStatus has been in an unready status for an extended period")
 				require.Equal(t, notification.Spec.Recipient, "admin@dev.sandbox.com")
+				require.Equal(t, "toolchainstatus", notification.Labels[toolchainv1alpha1.NotificationTypeLabelKey])
 
 				t.Run("Toolchain status now ok again, notification should be removed", func(t *testing.T) {
 					hostOperatorDeployment := newDeploymentWithConditions(defaultHostOperatorDeploymentName,
@@ -1106,6 +1207,234 @@ func TestToolchainStatusNotifications(t *testing.T) {
 	})
 }
 
+func TestToolchainStatusNotificationEscalationsAndDigest(t *testing.T) {
+	// given
+	restore := test.SetEnvVarsAndRestore(t, test.Env(commonconfig.OperatorNameEnvVar, defaultHostOperatorName))
+	defer restore()
+	defer counter.Reset()
+	requestName := toolchainconfig.ToolchainStatusName
+	memberStatus := newMemberStatus(ready())
+	registrationServiceDeployment := newDeploymentWithConditions(registrationservice.ResourceName,
+		status.DeploymentAvailableCondition(), status.DeploymentProgressingCondition())
+	hostOperatorNotReady := newDeploymentWithConditions(defaultHostOperatorDeploymentName,
+		status.DeploymentNotAvailableCondition(), status.DeploymentProgressingCondition())
+	hostOperatorReady := newDeploymentWithConditions(defaultHostOperatorDeploymentName,
+		status.DeploymentAvailableCondition())
+	unreadyForADay := toolchainv1alpha1.Condition{
+		Type:               toolchainv1alpha1.ConditionReady,
+		Status:             corev1.ConditionFalse,
+		Reason:             toolchainv1alpha1.ToolchainStatusComponentsNotReadyReason,
+		LastTransitionTime: metav1.Time{Time: time.Now().Add(-24 * time.Hour)},
+	}
+	unreadyNotificationCreated := toolchainv1alpha1.Condition{
+		Type:   toolchainv1alpha1.ToolchainStatusUnreadyNotificationCreated,
+		Status: corev1.ConditionTrue,
+		Reason: toolchainv1alpha1.ToolchainStatusUnreadyNotificationCRCreatedReason,
+	}
+	toolchainStatusWithState := func(state notificationState) *toolchainv1alpha1.ToolchainStatus {
+		toolchainStatus := NewToolchainStatus()
+		value, err := json.Marshal(state)
+		require.NoError(t, err)
+		toolchainStatus.Annotations = map[string]string{NotificationStateAnnotationKey: string(value)}
+		return toolchainStatus
+	}
+	hoursAgo := func(hours int) *metav1.Time {
+		return &metav1.Time{Time: time.Now().Add(-time.Duration(hours) * time.Hour)}
+	}
+
+	t.Run("escalation sent when the escalation interval elapsed", func(t *testing.T) {
+		// given
+		toolchainConfig := commonconfig.NewToolchainConfigObjWithReset(t, testconfig.Notifications().AdminEmail("admin@dev.sandbox.com"),
+			ToolchainStatusNotificationIntervals("1h", "24h"))
+		toolchainStatus := toolchainStatusWithState(notificationState{
+			UnreadyComponents:           []string{string(hostOperatorTag)},
+			LastUnreadyNotificationTime: hoursAgo(2),
+			LastDigestTime:              hoursAgo(1),
+		})
+		reconciler, req, fakeClient := prepareReconcileWithStatusConditions(t, requestName, []string{"member-1", "member-2"},
+			[]toolchainv1alpha1.Condition{unreadyForADay, unreadyNotificationCreated},
+			hostOperatorNotReady, memberStatus, registrationServiceDeployment, toolchainStatus, toolchainConfig, proxyRoute())
+
+		// when
+		_, err := reconciler.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		notification := assertToolchainStatusNotificationCreated(t, fakeClient)
+		assert.True(t, strings.HasPrefix(notification.Name, "toolchainstatus-escalation-"))
+		assert.Equal(t, "ToolchainStatus is still in an unready status (escalation #1)", notification.Spec.Subject)
+		assert.Equal(t, "admin@dev.sandbox.com", notification.Spec.Recipient)
+		assert.True(t, strings.HasPrefix(notification.Spec.Content, "<h3>The following issues"))
+		state := assertNotificationState(t, fakeClient)
+		assert.Equal(t, 1, state.Escalations)
+		assert.True(t, state.LastUnreadyNotificationTime.After(time.Now().Add(-time.Minute)))
+	})
+
+	t.Run("escalation not sent before the doubled escalation interval elapsed", func(t *testing.T) {
+		// given
+		toolchainConfig := commonconfig.NewToolchainConfigObjWithReset(t, testconfig.Notifications().AdminEmail("admin@dev.sandbox.com"),
+			ToolchainStatusNotificationIntervals("1h", "24h"))
+		toolchainStatus := toolchainStatusWithState(notificationState{
+			UnreadyComponents:           []string{string(hostOperatorTag)},
+			Escalations:                 1,
+			LastUnreadyNotificationTime: hoursAgo(1),
+			LastDigestTime:              hoursAgo(1),
+		})
+		reconciler, req, fakeClient := prepareReconcileWithStatusConditions(t, requestName, []string{"member-1", "member-2"},
+			[]toolchainv1alpha1.Condition{unreadyForADay, unreadyNotificationCreated},
+			hostOperatorNotReady, memberStatus, registrationServiceDeployment, toolchainStatus, toolchainConfig, proxyRoute())
+
+		// when
+		_, err := reconciler.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assertToolchainStatusNotificationNotCreated(t, fakeClient, "toolchainstatus-")
+		assert.Equal(t, 1, assertNotificationState(t, fakeClient).Escalations)
+	})
+
+	t.Run("no notification when the unready components are suppressed", func(t *testing.T) {
+		// given
+		toolchainConfig := commonconfig.NewToolchainConfigObjWithReset(t, testconfig.Notifications().AdminEmail("admin@dev.sandbox.com"),
+			ToolchainStatusNotificationIntervals("1h", "24h"))
+		toolchainConfig.Annotations = map[string]string{
+			toolchainconfig.HostConfigExtensionAnnotationKey: `{"toolchainStatus":{"notifications":{"escalationInterval":"1h","digestInterval":"24h","suppressedComponents":["hostOperator"]}}}`,
+		}
+		reconciler, req, fakeClient := prepareReconcileWithStatusConditions(t, requestName, []string{"member-1", "member-2"},
+			[]toolchainv1alpha1.Condition{unreadyForADay},
+			hostOperatorNotReady, memberStatus, registrationServiceDeployment, NewToolchainStatus(), toolchainConfig, proxyRoute())
+
+		// when
+		_, err := reconciler.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assertToolchainStatusNotificationNotCreated(t, fakeClient, "toolchainstatus-")
+		AssertThatToolchainStatus(t, req.Namespace, requestName, fakeClient).
+			HasConditions(componentsNotReady(string(hostOperatorTag)))
+		assert.Empty(t, assertNotificationState(t, fakeClient).Flaps)
+	})
+
+	t.Run("flap recorded when a component becomes unready", func(t *testing.T) {
+		// given
+		toolchainConfig := commonconfig.NewToolchainConfigObjWithReset(t, testconfig.Notifications().AdminEmail("admin@dev.sandbox.com"),
+			ToolchainStatusNotificationIntervals("1h", "24h"))
+		toolchainStatus := toolchainStatusWithState(notificationState{
+			Flaps:          map[string]int{string(hostOperatorTag): 1},
+			LastDigestTime: hoursAgo(1),
+		})
+		reconciler, req, fakeClient := prepareReconcile(t, requestName, newResponseGood(), []string{"member-1", "member-2"},
+			hostOperatorNotReady, memberStatus, registrationServiceDeployment, toolchainStatus, toolchainConfig, proxyRoute())
+
+		// when
+		_, err := reconciler.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		state := assertNotificationState(t, fakeClient)
+		assert.Equal(t, map[string]int{string(hostOperatorTag): 2}, state.Flaps)
+		assert.Equal(t, []string{string(hostOperatorTag)}, state.UnreadyComponents)
+	})
+
+	t.Run("digest sent when the digest interval elapsed", func(t *testing.T) {
+		// given
+		toolchainConfig := commonconfig.NewToolchainConfigObjWithReset(t, testconfig.Notifications().AdminEmail("admin@dev.sandbox.com"),
+			ToolchainStatusNotificationIntervals("1h", "24h"))
+		toolchainStatus := toolchainStatusWithState(notificationState{
+			Flaps:          map[string]int{string(memberConnectionsTag): 3},
+			LastDigestTime: hoursAgo(25),
+		})
+		reconciler, req, fakeClient := prepareReconcile(t, requestName, newResponseGood(), []string{"member-1", "member-2"},
+			hostOperatorReady, memberStatus, registrationServiceDeployment, toolchainStatus, toolchainConfig, proxyRoute())
+
+		// when
+		_, err := reconciler.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		notification := assertToolchainStatusNotificationCreated(t, fakeClient)
+		assert.True(t, strings.HasPrefix(notification.Name, "toolchainstatus-digest-"))
+		assert.Equal(t, "ToolchainStatus digest: components which became unready", notification.Spec.Subject)
+		assert.Contains(t, notification.Spec.Content, `<span style="font-weight:bold;padding-right:10px">members:</span>3 time(s)`)
+		state := assertNotificationState(t, fakeClient)
+		assert.Empty(t, state.Flaps)
+		assert.True(t, state.LastDigestTime.After(time.Now().Add(-time.Minute)))
+	})
+
+	t.Run("digest not sent when no component became unready", func(t *testing.T) {
+		// given
+		toolchainConfig := commonconfig.NewToolchainConfigObjWithReset(t, testconfig.Notifications().AdminEmail("admin@dev.sandbox.com"),
+			ToolchainStatusNotificationIntervals("1h", "24h"))
+		toolchainStatus := toolchainStatusWithState(notificationState{
+			LastDigestTime: hoursAgo(25),
+		})
+		reconciler, req, fakeClient := prepareReconcile(t, requestName, newResponseGood(), []string{"member-1", "member-2"},
+			hostOperatorReady, memberStatus, registrationServiceDeployment, toolchainStatus, toolchainConfig, proxyRoute())
+
+		// when
+		_, err := reconciler.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assertToolchainStatusNotificationNotCreated(t, fakeClient, "toolchainstatus-")
+		assert.True(t, assertNotificationState(t, fakeClient).LastDigestTime.After(time.Now().Add(-time.Minute)))
+	})
+
+	t.Run("digest disabled", func(t *testing.T) {
+		// given
+		toolchainConfig := commonconfig.NewToolchainConfigObjWithReset(t, testconfig.Notifications().AdminEmail("admin@dev.sandbox.com"))
+		toolchainConfig.Annotations = map[string]string{
+			toolchainconfig.HostConfigExtensionAnnotationKey: `{"toolchainStatus":{"notifications":{"digestInterval":"0s"}}}`,
+		}
+		toolchainStatus := toolchainStatusWithState(notificationState{
+			Flaps:          map[string]int{string(memberConnectionsTag): 3},
+			LastDigestTime: hoursAgo(25),
+		})
+		reconciler, req, fakeClient := prepareReconcile(t, requestName, newResponseGood(), []string{"member-1", "member-2"},
+			hostOperatorReady, memberStatus, registrationServiceDeployment, toolchainStatus, toolchainConfig, proxyRoute())
+
+		// when
+		_, err := reconciler.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assertToolchainStatusNotificationNotCreated(t, fakeClient, "toolchainstatus-")
+		// the state is not tracked anymore
+		assertNoNotificationState(t, fakeClient)
+	})
+
+	t.Run("state not stored when a component becomes unready and the escalations and digests are disabled", func(t *testing.T) {
+		// given
+		toolchainConfig := commonconfig.NewToolchainConfigObjWithReset(t, testconfig.Notifications().AdminEmail("admin@dev.sandbox.com"))
+		reconciler, req, fakeClient := prepareReconcile(t, requestName, newResponseGood(), []string{"member-1", "member-2"},
+			hostOperatorNotReady, memberStatus, registrationServiceDeployment, NewToolchainStatus(), toolchainConfig, proxyRoute())
+
+		// when
+		_, err := reconciler.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assertNoNotificationState(t, fakeClient)
+	})
+}
+
+func assertNotificationState(t *testing.T, cl client.Client) *notificationState {
+	toolchainStatus := &toolchainv1alpha1.ToolchainStatus{}
+	err := cl.Get(context.TODO(), test.NamespacedName(test.HostOperatorNs, toolchainconfig.ToolchainStatusName), toolchainStatus)
+	require.NoError(t, err)
+	require.Contains(t, toolchainStatus.Annotations, NotificationStateAnnotationKey)
+	state := &notificationState{}
+	require.NoError(t, json.Unmarshal([]byte(toolchainStatus.Annotations[NotificationStateAnnotationKey]), state))
+	return state
+}
+
+func assertNoNotificationState(t *testing.T, cl client.Client) {
+	toolchainStatus := &toolchainv1alpha1.ToolchainStatus{}
+	err := cl.Get(context.TODO(), test.NamespacedName(test.HostOperatorNs, toolchainconfig.ToolchainStatusName), toolchainStatus)
+	require.NoError(t, err)
+	assert.NotContains(t, toolchainStatus.Annotations, NotificationStateAnnotationKey)
+}
+
 func overrideLastTransitionTime(t *testing.T, toolchainStatus *toolchainv1alpha1.ToolchainStatus, overrideTime metav1.Time) {
 	found := false
 	for i, cond := range toolchainStatus.Status.Conditions {
@@ -1614,15 +1943,15 @@ func componentsNotReady(components ...string) toolchainv1alpha1.Condition {
 	}
 }
 
-func hostOperatorStatusReady() toolchainv1alpha1.HostOperatorStatus {
+func hostOperatorStatusReady(additionalConditions ...toolchainv1alpha1.Condition) toolchainv1alpha1.HostOperatorStatus {
 	return toolchainv1alpha1.HostOperatorStatus{
-		Conditions: []toolchainv1alpha1.Condition{
+		Conditions: append([]toolchainv1alpha1.Condition{
 			{
 				Type:   toolchainv1alpha1.ConditionReady,
 				Status: corev1.ConditionTrue,
 				Reason: "DeploymentReady",
 			},
-		},
+		}, additionalConditions...),
 		BuildTimestamp: version.BuildTime,
 		DeploymentName: defaultHostOperatorDeploymentName,
 		Revision:       version.Commit,
@@ -37,7 +37,7 @@ func migrateOrFixMurIfNecessary(mur *toolchainv1alpha1.MasterUserRecord, nstempl
 		tierName := ua.Spec.NSTemplateSet.TierName
 		// only set the label if it is missing.
 		if _, ok := mur.Labels[tierutil.TemplateTierHashLabelKey(tierName)]; !ok {
-			hash, err := tierutil.ComputeHashForNSTemplateSetSpec(*ua.Spec.NSTemplateSet)
+			hash, err := tierutil.ComputeHashForNSTemplateSet(nstemplateTier, *ua.Spec.NSTemplateSet)
 			if err != nil {
 				return false, err
 			}
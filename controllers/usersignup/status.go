@@ -11,6 +11,21 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+const (
+	// UserSignupUserBannedNotificationCreated is the type of the condition of a UserSignup reporting whether the notification
+	// informing the user of the ban was created
+	UserSignupUserBannedNotificationCreated toolchainv1alpha1.ConditionType = "UserBannedNotificationCreated"
+
+	// UserSignupBannedNotificationCRCreatedReason is the reason of the `UserBannedNotificationCreated=True` condition
+	UserSignupBannedNotificationCRCreatedReason = "NotificationCRCreated"
+	// UserSignupBannedNotificationUserIsNotBannedReason is the reason of the `UserBannedNotificationCreated=False` condition
+	// of a UserSignup which is not banned (anymore)
+	UserSignupBannedNotificationUserIsNotBannedReason = "UserIsNotBanned"
+	// UserSignupBannedNotificationCRCreationFailedReason is the reason of the `UserBannedNotificationCreated=False` condition
+	// when the notification could not be created
+	UserSignupBannedNotificationCRCreationFailedReason = "NotificationCRCreationFailed"
+)
+
 type StatusUpdater struct {
 	Client client.Client
 }
@@ -299,6 +314,37 @@ func (u *StatusUpdater) setStatusDeactivatingNotificationCreationFailed(userSign
 		})
 }
 
+func (u *StatusUpdater) setStatusBannedNotificationCreated(userSignup *toolchainv1alpha1.UserSignup, _ string) error {
+	return u.updateStatusConditions(
+		userSignup,
+		toolchainv1alpha1.Condition{
+			Type:   UserSignupUserBannedNotificationCreated,
+			Status: corev1.ConditionTrue,
+			Reason: UserSignupBannedNotificationCRCreatedReason,
+		})
+}
+
+func (u *StatusUpdater) setStatusBannedNotificationUserIsNotBanned(userSignup *toolchainv1alpha1.UserSignup, _ string) error {
+	return u.updateStatusConditions(
+		userSignup,
+		toolchainv1alpha1.Condition{
+			Type:   UserSignupUserBannedNotificationCreated,
+			Status: corev1.ConditionFalse,
+			Reason: UserSignupBannedNotificationUserIsNotBannedReason,
+		})
+}
+
+func (u *StatusUpdater) setStatusBannedNotificationCreationFailed(userSignup *toolchainv1alpha1.UserSignup, message string) error {
+	return u.updateStatusConditions(
+		userSignup,
+		toolchainv1alpha1.Condition{
+			Type:    UserSignupUserBannedNotificationCreated,
+			Status:  corev1.ConditionFalse,
+			Reason:  UserSignupBannedNotificationCRCreationFailedReason,
+			Message: message,
+		})
+}
+
 func (u *StatusUpdater) updateStatus(logger logr.Logger, userSignup *toolchainv1alpha1.UserSignup,
 	statusUpdater func(userAcc *toolchainv1alpha1.UserSignup, message string) error) error {
 
@@ -9,6 +9,7 @@ import (
 	"strings"
 
 	notify "github.com/codeready-toolchain/host-operator/controllers/notification"
+	tierutil "github.com/codeready-toolchain/host-operator/controllers/nstemplatetier/util"
 	"github.com/codeready-toolchain/host-operator/controllers/toolchainconfig"
 	"github.com/codeready-toolchain/host-operator/pkg/pending"
 
@@ -39,6 +40,9 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
+// NotificationTypeBanned is the type of the notifications sent to the users who were banned
+const NotificationTypeBanned = notify.NotificationTypeBanned
+
 type StatusUpdaterFunc func(userAcc *toolchainv1alpha1.UserSignup, message string) error
 
 // SetupWithManager sets up the controller with the Manager.
@@ -139,6 +143,14 @@ func (r *Reconciler) Reconcile(ctx context.Context, request ctrl.Request) (ctrl.
 		}
 	}
 
+	// If the usersignup is not banned (anymore) then reset the banned notification status, so the user is notified again
+	// if banned again later.
+	if !banned && condition.IsTrue(userSignup.Status.Conditions, UserSignupUserBannedNotificationCreated) {
+		if err := r.updateStatus(logger, userSignup, r.setStatusBannedNotificationUserIsNotBanned); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+
 	if states.Deactivating(userSignup) && condition.IsNotTrue(userSignup.Status.Conditions,
 		toolchainv1alpha1.UserSignupUserDeactivatingNotificationCreated) {
 
@@ -167,6 +179,19 @@ func (r *Reconciler) Reconcile(ctx context.Context, request ctrl.Request) (ctrl.
 		if err := r.setStateLabel(logger, userSignup, toolchainv1alpha1.UserSignupStateLabelValueBanned); err != nil {
 			return reconcile.Result{}, err
 		}
+		if condition.IsNotTrue(userSignup.Status.Conditions, UserSignupUserBannedNotificationCreated) {
+			if err := r.sendBannedNotification(logger, config, userSignup); err != nil {
+				logger.Error(err, "Failed to create user banned notification")
+
+				// set the failed to create notification status condition
+				return reconcile.Result{}, r.wrapErrorWithStatusUpdate(logger, userSignup, r.setStatusBannedNotificationCreationFailed, err, "Failed to create user banned notification")
+			}
+
+			if err := r.updateStatus(logger, userSignup, r.setStatusBannedNotificationCreated); err != nil {
+				logger.Error(err, "Failed to update notification created status")
+				return reconcile.Result{}, err
+			}
+		}
 
 		return reconcile.Result{}, r.updateStatus(logger, userSignup, r.setStatusBanned)
 	}
@@ -418,10 +443,9 @@ func updateUserSignupMetricsByState(oldState, newState string) {
 	}
 }
 
+// getNsTemplateTier returns the NSTemplateTier with the given name, or its successor if this tier is deprecated
 func getNsTemplateTier(cl client.Client, tierName, namespace string) (*toolchainv1alpha1.NSTemplateTier, error) {
-	nstemplateTier := &toolchainv1alpha1.NSTemplateTier{}
-	err := cl.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: tierName}, nstemplateTier)
-	return nstemplateTier, err
+	return tierutil.GetAssignableTier(cl, namespace, tierName)
 }
 
 func (r *Reconciler) generateCompliantUsername(config toolchainconfig.ToolchainConfig, instance *toolchainv1alpha1.UserSignup) (string, error) {
@@ -631,6 +655,43 @@ func (r *Reconciler) sendDeactivatedNotification(logger logr.Logger, config tool
 	return nil
 }
 
+func (r *Reconciler) sendBannedNotification(logger logr.Logger, config toolchainconfig.ToolchainConfig, userSignup *toolchainv1alpha1.UserSignup) error {
+	// the compliant username is not set if the user was banned before being approved, hence the lookup by the name of the UserSignup
+	labels := map[string]string{
+		toolchainv1alpha1.OwnerLabelKey:            userSignup.Name,
+		toolchainv1alpha1.NotificationTypeLabelKey: NotificationTypeBanned,
+	}
+	notificationList := &toolchainv1alpha1.NotificationList{}
+	if err := r.Client.List(context.TODO(), notificationList, client.InNamespace(userSignup.Namespace), client.MatchingLabels(labels)); err != nil {
+		return err
+	}
+
+	// if there is no existing notification with these labels
+	if len(notificationList.Items) == 0 {
+		keysAndVals := map[string]string{
+			toolchainconfig.NotificationContextRegistrationURLKey: config.RegistrationService().RegistrationServiceURL(),
+			toolchainconfig.NotificationContextSupportURLKey:      config.Notifications().SupportURL(),
+		}
+
+		notification, err := notify.NewNotificationBuilder(r.Client, userSignup.Namespace).
+			WithTemplate(notificationtemplates.Banned.Name).
+			WithNotificationType(NotificationTypeBanned).
+			WithControllerReference(userSignup, r.Scheme).
+			WithUserContext(userSignup).
+			WithKeysAndValues(keysAndVals).
+			WithLabel(toolchainv1alpha1.OwnerLabelKey, userSignup.Name).
+			Create(userSignup.Annotations[toolchainv1alpha1.UserSignupUserEmailAnnotationKey])
+
+		if err != nil {
+			logger.Error(err, "Failed to create banned notification resource")
+			return err
+		}
+
+		logger.Info(fmt.Sprintf("Banned notification resource [%s] created", notification.Name))
+	}
+	return nil
+}
+
 // validateEmailHash calculates an md5 hash value for the provided userEmail string, and compares it to the provided
 // userEmailHash.  If the values are the same the function returns true, otherwise it will return false
 func validateEmailHash(userEmail, userEmailHash string) bool {
@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	tierutil "github.com/codeready-toolchain/host-operator/controllers/nstemplatetier/util"
 	"github.com/codeready-toolchain/host-operator/controllers/toolchainconfig"
 	"github.com/codeready-toolchain/host-operator/pkg/apis"
 	"github.com/codeready-toolchain/host-operator/pkg/counter"
@@ -545,6 +546,32 @@ func TestNonDefaultNSTemplateTier(t *testing.T) {
 	})
 }
 
+func TestDeprecatedDefaultNSTemplateTier(t *testing.T) {
+
+	// given
+	deprecatedTier := newNsTemplateTier("base", "dev", "stage")
+	deprecatedTier.Annotations = map[string]string{
+		tierutil.DeprecatedByAnnotationKey: "custom",
+	}
+	customTier := newNsTemplateTier("custom", "dev", "stage")
+	config := commonconfig.NewToolchainConfigObjWithReset(t, testconfig.AutomaticApproval().Enabled(true))
+	userSignup := NewUserSignup()
+	ready := NewGetMemberClusters(NewMemberCluster(t, "member1", v1.ConditionTrue))
+	r, req, _ := prepareReconcile(t, userSignup.Name, ready, userSignup, config, deprecatedTier, customTier)
+	InitializeCounters(t, NewToolchainStatus())
+
+	// when
+	_, err := r.Reconcile(context.TODO(), req)
+
+	// then the successor of the deprecated default tier is used
+	require.NoError(t, err)
+	murtest.AssertThatMasterUserRecord(t, "foo", r.Client).
+		HasUserAccounts(1).
+		HasUserAccountTierName("custom").
+		HasUserAccountNamespaceTemplateRefs("custom-dev-123abc1", "custom-stage-123abc2").
+		HasUserAccountClusterResourceTemplateRefs("custom-clusterresources-654321b")
+}
+
 func TestUserSignupFailedMissingNSTemplateTier(t *testing.T) {
 
 	type variation struct {
@@ -2540,11 +2567,26 @@ func TestUserSignupBanned(t *testing.T) {
 			Type:   toolchainv1alpha1.UserSignupComplete,
 			Status: v1.ConditionTrue,
 			Reason: "Banned",
+		},
+		toolchainv1alpha1.Condition{
+			Type:   UserSignupUserBannedNotificationCreated,
+			Status: v1.ConditionTrue,
+			Reason: UserSignupBannedNotificationCRCreatedReason,
 		})
 
 	// Confirm that the MUR has now been deleted
 	murtest.AssertThatMasterUserRecords(t, r.Client).HaveCount(0)
 
+	// A banned notification should have been created
+	notifications := &toolchainv1alpha1.NotificationList{}
+	err = r.Client.List(context.TODO(), notifications)
+	require.NoError(t, err)
+	require.Len(t, notifications.Items, 1)
+	assert.Equal(t, "banned", notifications.Items[0].Spec.Template)
+	assert.Equal(t, NotificationTypeBanned, notifications.Items[0].Labels[toolchainv1alpha1.NotificationTypeLabelKey])
+	assert.Equal(t, "foo@redhat.com", notifications.Items[0].Spec.Recipient)
+	assert.Equal(t, "https://developers.redhat.com/developer-sandbox", notifications.Items[0].Spec.Context["SupportURL"])
+
 	AssertThatCountersAndMetrics(t).
 		HaveMasterUserRecordsPerDomain(toolchainv1alpha1.Metric{
 			string(metrics.External): 1,
@@ -2718,10 +2760,27 @@ func TestUserSignupBannedMURExists(t *testing.T) {
 				Type:   toolchainv1alpha1.UserSignupApproved,
 				Status: v1.ConditionTrue,
 				Reason: "ApprovedAutomatically",
+			},
+			toolchainv1alpha1.Condition{
+				Type:   UserSignupUserBannedNotificationCreated,
+				Status: v1.ConditionTrue,
+				Reason: UserSignupBannedNotificationCRCreatedReason,
 			})
 
 		// Confirm that there is still no MUR
 		murtest.AssertThatMasterUserRecords(t, r.Client).HaveCount(0)
+
+		t.Run("third reconcile does not create another notification", func(t *testing.T) {
+			// when
+			_, err = r.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			notifications := &toolchainv1alpha1.NotificationList{}
+			err = r.Client.List(context.TODO(), notifications)
+			require.NoError(t, err)
+			require.Len(t, notifications.Items, 1)
+		})
 		AssertThatCountersAndMetrics(t).
 			HaveMasterUserRecordsPerDomain(toolchainv1alpha1.Metric{
 				string(metrics.External): 1,
@@ -2732,6 +2791,110 @@ func TestUserSignupBannedMURExists(t *testing.T) {
 	})
 }
 
+func TestUserSignupBannedNotification(t *testing.T) {
+	// given
+	newBannedUser := func() *toolchainv1alpha1.BannedUser {
+		return &toolchainv1alpha1.BannedUser{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "banned-foo",
+				Namespace: test.HostOperatorNs,
+				Labels: map[string]string{
+					toolchainv1alpha1.BannedUserEmailHashLabelKey: "fd2addbd8d82f0d2dc088fa122377eaa",
+				},
+			},
+			Spec: toolchainv1alpha1.BannedUserSpec{
+				Email: "foo@redhat.com",
+			},
+		}
+	}
+
+	t.Run("notification creation fails", func(t *testing.T) {
+		// given
+		userSignup := NewUserSignup()
+		r, req, fakeClient := prepareReconcile(t, userSignup.Name, NewGetMemberClusters(), userSignup, newBannedUser(), commonconfig.NewToolchainConfigObjWithReset(t), baseNSTemplateTier)
+		InitializeCounters(t, NewToolchainStatus())
+		fakeClient.MockCreate = func(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+			if _, ok := obj.(*toolchainv1alpha1.Notification); ok {
+				return errors.New("unable to create notification")
+			}
+			return fakeClient.Client.Create(ctx, obj, opts...)
+		}
+
+		// when
+		_, err := r.Reconcile(context.TODO(), req)
+
+		// then
+		require.EqualError(t, err, "Failed to create user banned notification: unable to create notification")
+		err = r.Client.Get(context.TODO(), test.NamespacedName(test.HostOperatorNs, userSignup.Name), userSignup)
+		require.NoError(t, err)
+		test.AssertContainsCondition(t, userSignup.Status.Conditions, toolchainv1alpha1.Condition{
+			Type:    UserSignupUserBannedNotificationCreated,
+			Status:  v1.ConditionFalse,
+			Reason:  UserSignupBannedNotificationCRCreationFailedReason,
+			Message: "unable to create notification",
+		})
+	})
+
+	t.Run("notification created for each user banned before being approved", func(t *testing.T) {
+		// given
+		john := NewUserSignup(WithName("john"))
+		jane := NewUserSignup(WithName("jane"))
+		r, _, fakeClient := prepareReconcile(t, john.Name, NewGetMemberClusters(), john, jane, newBannedUser(), commonconfig.NewToolchainConfigObjWithReset(t), baseNSTemplateTier)
+		InitializeCounters(t, NewToolchainStatus())
+
+		for _, userSignup := range []*toolchainv1alpha1.UserSignup{john, jane} {
+			// when
+			_, err := r.Reconcile(context.TODO(), newReconcileRequest(userSignup.Name))
+
+			// then
+			require.NoError(t, err)
+			err = r.Client.Get(context.TODO(), test.NamespacedName(test.HostOperatorNs, userSignup.Name), userSignup)
+			require.NoError(t, err)
+			require.Empty(t, userSignup.Status.CompliantUsername)
+			test.AssertContainsCondition(t, userSignup.Status.Conditions, toolchainv1alpha1.Condition{
+				Type:   UserSignupUserBannedNotificationCreated,
+				Status: v1.ConditionTrue,
+				Reason: UserSignupBannedNotificationCRCreatedReason,
+			})
+			notifications := &toolchainv1alpha1.NotificationList{}
+			err = fakeClient.List(context.TODO(), notifications, client.MatchingLabels{toolchainv1alpha1.OwnerLabelKey: userSignup.Name})
+			require.NoError(t, err)
+			require.Len(t, notifications.Items, 1)
+			assert.Equal(t, NotificationTypeBanned, notifications.Items[0].Labels[toolchainv1alpha1.NotificationTypeLabelKey])
+		}
+		notifications := &toolchainv1alpha1.NotificationList{}
+		require.NoError(t, fakeClient.List(context.TODO(), notifications))
+		assert.Len(t, notifications.Items, 2)
+	})
+
+	t.Run("condition reset when the user is not banned anymore", func(t *testing.T) {
+		// given
+		userSignup := NewUserSignup()
+		userSignup.Status.Conditions = []toolchainv1alpha1.Condition{
+			{
+				Type:   UserSignupUserBannedNotificationCreated,
+				Status: v1.ConditionTrue,
+				Reason: UserSignupBannedNotificationCRCreatedReason,
+			},
+		}
+		r, req, _ := prepareReconcile(t, userSignup.Name, NewGetMemberClusters(), userSignup, commonconfig.NewToolchainConfigObjWithReset(t), baseNSTemplateTier)
+		InitializeCounters(t, NewToolchainStatus())
+
+		// when
+		_, err := r.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		err = r.Client.Get(context.TODO(), test.NamespacedName(test.HostOperatorNs, userSignup.Name), userSignup)
+		require.NoError(t, err)
+		test.AssertContainsCondition(t, userSignup.Status.Conditions, toolchainv1alpha1.Condition{
+			Type:   UserSignupUserBannedNotificationCreated,
+			Status: v1.ConditionFalse,
+			Reason: UserSignupBannedNotificationUserIsNotBannedReason,
+		})
+	})
+}
+
 func TestUserSignupListBannedUsersFails(t *testing.T) {
 	// given
 	userSignup := NewUserSignup()
@@ -13,11 +13,14 @@ import (
 	"github.com/codeready-toolchain/host-operator/controllers/masteruserrecord"
 	"github.com/codeready-toolchain/host-operator/controllers/notification"
 	"github.com/codeready-toolchain/host-operator/controllers/nstemplatetier"
+	"github.com/codeready-toolchain/host-operator/controllers/nstemplatetierbundle"
 	"github.com/codeready-toolchain/host-operator/controllers/space"
 	"github.com/codeready-toolchain/host-operator/controllers/spacebindingcleanup"
 	"github.com/codeready-toolchain/host-operator/controllers/spacecleanup"
 	"github.com/codeready-toolchain/host-operator/controllers/spacecompletion"
+	"github.com/codeready-toolchain/host-operator/controllers/spaceexpiration"
 	"github.com/codeready-toolchain/host-operator/controllers/templateupdaterequest"
+	"github.com/codeready-toolchain/host-operator/controllers/tiertemplatecleanup"
 	"github.com/codeready-toolchain/host-operator/controllers/toolchainconfig"
 	"github.com/codeready-toolchain/host-operator/controllers/toolchainstatus"
 	"github.com/codeready-toolchain/host-operator/controllers/usersignup"
@@ -27,6 +30,7 @@ import (
 	"github.com/codeready-toolchain/host-operator/pkg/metrics"
 	"github.com/codeready-toolchain/host-operator/pkg/templates/assets"
 	"github.com/codeready-toolchain/host-operator/pkg/templates/nstemplatetiers"
+	"github.com/codeready-toolchain/host-operator/pkg/templates/validation"
 	"github.com/codeready-toolchain/host-operator/version"
 	"github.com/codeready-toolchain/toolchain-common/controllers/toolchaincluster"
 	commoncluster "github.com/codeready-toolchain/toolchain-common/pkg/cluster"
@@ -187,6 +191,9 @@ func main() {
 		os.Exit(1)
 	}
 
+	// validate the embedded notification and tier templates: the operator is not ready if some of them are invalid
+	templateValidation := validation.ValidateTemplates(mgr.GetScheme(), namespace, assets.NewAssets(nstemplatetiers.AssetNames, nstemplatetiers.Asset))
+
 	// Setup all Controllers
 	if err = toolchaincluster.NewReconciler(
 		mgr,
@@ -219,8 +226,9 @@ func main() {
 		os.Exit(1)
 	}
 	if err := (&notification.Reconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:    mgr.GetClient(),
+		Scheme:    mgr.GetScheme(),
+		Namespace: namespace,
 	}).SetupWithManager(mgr, crtConfig); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Notification")
 		os.Exit(1)
@@ -232,6 +240,16 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", "NSTemplateTier")
 		os.Exit(1)
 	}
+	tierBundles := &nstemplatetierbundle.Reconciler{
+		Client:    mgr.GetClient(),
+		Scheme:    mgr.GetScheme(),
+		Namespace: namespace,
+		Embedded:  assets.NewAssets(nstemplatetiers.AssetNames, nstemplatetiers.Asset),
+	}
+	if err := tierBundles.SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "NSTemplateTierBundle")
+		os.Exit(1)
+	}
 	if err := (&templateupdaterequest.Reconciler{
 		Client: mgr.GetClient(),
 		Scheme: mgr.GetScheme(),
@@ -239,6 +257,14 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", "TemplateUpdateRequest")
 		os.Exit(1)
 	}
+	if err := (&tiertemplatecleanup.Reconciler{
+		Client:         mgr.GetClient(),
+		Namespace:      namespace,
+		MemberClusters: memberClusters,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "TierTemplateCleanup")
+		os.Exit(1)
+	}
 	if err := (&toolchainconfig.Reconciler{
 		Client:         mgr.GetClient(),
 		GetMembersFunc: commoncluster.GetMemberClusters,
@@ -248,11 +274,12 @@ func main() {
 		os.Exit(1)
 	}
 	if err := (&toolchainstatus.Reconciler{
-		Client:         mgr.GetClient(),
-		Scheme:         mgr.GetScheme(),
-		HTTPClientImpl: &http.Client{},
-		GetMembersFunc: commoncluster.GetMemberClusters,
-		Namespace:      namespace,
+		Client:             mgr.GetClient(),
+		Scheme:             mgr.GetScheme(),
+		HTTPClientImpl:     &http.Client{},
+		GetMembersFunc:     commoncluster.GetMemberClusters,
+		Namespace:          namespace,
+		TemplateValidation: templateValidation,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "ToolchainStatus")
 		os.Exit(1)
@@ -305,6 +332,14 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", "SpaceCleanup")
 		os.Exit(1)
 	}
+	if err = (&spaceexpiration.Reconciler{
+		Client:    mgr.GetClient(),
+		Scheme:    mgr.GetScheme(),
+		Namespace: namespace,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "SpaceExpiration")
+		os.Exit(1)
+	}
 	//+kubebuilder:scaffold:builder
 
 	stopChannel := ctrl.SetupSignalHandler()
@@ -327,10 +362,9 @@ func main() {
 		}
 		setupLog.Info("Created/updated the ToolchainStatus resource")
 
-		// create or update all NSTemplateTiers on the cluster at startup
+		// create or update all NSTemplateTiers on the cluster at startup (the changes of the tier bundles are then handled by the controller)
 		setupLog.Info("Creating/updating the NSTemplateTier resources")
-		tierAssets := assets.NewAssets(nstemplatetiers.AssetNames, nstemplatetiers.Asset)
-		if err := nstemplatetiers.CreateOrUpdateResources(mgr.GetScheme(), mgr.GetClient(), namespace, tierAssets); err != nil {
+		if err := tierBundles.CreateOrUpdateTiers(setupLog, crtConfig); err != nil {
 			setupLog.Error(err, "")
 			os.Exit(1)
 		}
@@ -345,6 +379,10 @@ func main() {
 		setupLog.Error(err, "unable to set up ready check")
 		os.Exit(1)
 	}
+	if err := mgr.AddReadyzCheck("templates", templateValidation.Checker); err != nil {
+		setupLog.Error(err, "unable to set up ready check")
+		os.Exit(1)
+	}
 
 	setupLog.Info("starting manager")
 	if err := mgr.Start(stopChannel); err != nil {
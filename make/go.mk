@@ -19,6 +19,11 @@ $(OUT_DIR)/operator:
 		-o $(OUT_DIR)/bin/host-operator \
 		main.go
 
+.PHONY: build-tier-diff
+## Build the tool which shows the changes between two revisions of a tier
+build-tier-diff:
+	$(Q)go build ${V_FLAG} -o $(OUT_DIR)/bin/tier-diff cmd/tier-diff/main.go
+
 .PHONY: vendor
 vendor:
 	$(Q)go mod vendor
@@ -30,6 +30,31 @@ var (
 
 	// UserSignupDeletedWithoutInitiatingVerificationTotal is incremented each time a user signup is deleted due to verification time trial expired, and verification was NOT initiated
 	UserSignupDeletedWithoutInitiatingVerificationTotal prometheus.Counter
+
+	// NotificationDeadLetteredTotal is incremented each time the delivery of a notification is abandoned, because the error
+	// was permanent or because the maximum number of attempts was reached
+	NotificationDeadLetteredTotal prometheus.Counter
+)
+
+// gauges
+var (
+	// UserSignupDeactivationBacklog reflects the current number of users whose deactivation is due but was postponed by the deactivation throttle
+	UserSignupDeactivationBacklog prometheus.Gauge
+
+	// NotificationUnsentGauge reflects the current number of notifications which are pending delivery, ie, which were not sent yet
+	// but whose delivery was not abandoned (nor suppressed)
+	NotificationUnsentGauge prometheus.Gauge
+)
+
+// counters with labels
+var (
+	// NotificationCreatedCounterVec is incremented each time a notification is created, with a label for the notification type
+	NotificationCreatedCounterVec *prometheus.CounterVec
+	// NotificationSentCounterVec is incremented each time a notification is sent, with labels for the notification type and the delivery service
+	NotificationSentCounterVec *prometheus.CounterVec
+	// NotificationDeliveryFailedCounterVec is incremented each time an attempt to deliver a notification fails, with labels for the notification type
+	// and the delivery service
+	NotificationDeliveryFailedCounterVec *prometheus.CounterVec
 )
 
 // gauge with labels
@@ -42,11 +67,20 @@ var (
 	MasterUserRecordGaugeVec *prometheus.GaugeVec
 )
 
+// histograms with labels
+var (
+	// NotificationDeliveryLatencyHistogramVec observes the time between the creation of a notification and its successful delivery,
+	// with labels for the notification type and the delivery service
+	NotificationDeliveryLatencyHistogramVec *prometheus.HistogramVec
+)
+
 // collections
 var (
-	allCounters  = []prometheus.Counter{}
-	allGauges    = []prometheus.Gauge{}
-	allGaugeVecs = []*prometheus.GaugeVec{}
+	allCounters      = []prometheus.Counter{}
+	allCounterVecs   = []*prometheus.CounterVec{}
+	allGauges        = []prometheus.Gauge{}
+	allGaugeVecs     = []*prometheus.GaugeVec{}
+	allHistogramVecs = []*prometheus.HistogramVec{}
 )
 
 func init() {
@@ -65,10 +99,21 @@ func initMetrics() {
 	UserSignupAutoDeactivatedTotal = newCounter("user_signups_auto_deactivated_total", "Total number of automatically deactivated UserSignups")
 	UserSignupDeletedWithInitiatingVerificationTotal = newCounter("user_signups_deleted_with_initiating_verification_total", "Total number of UserSignups deleted after verification time trial and with verification initiated")
 	UserSignupDeletedWithoutInitiatingVerificationTotal = newCounter("user_signups_deleted_without_initiating_verification_total", "Total number of deleted UserSignups after verification time trial but without verification initiated")
+	NotificationDeadLetteredTotal = newCounter("notifications_dead_lettered_total", "Total number of Notifications whose delivery was abandoned")
+	// Counters with labels
+	NotificationCreatedCounterVec = newCounterVec("notifications_created_total", "Total number of created Notifications (per type)", "type")
+	NotificationSentCounterVec = newCounterVec("notifications_sent_total", "Total number of sent Notifications (per type and delivery service)", "type", "delivery_service")
+	NotificationDeliveryFailedCounterVec = newCounterVec("notifications_delivery_failed_total", "Total number of failed attempts to deliver a Notification (per type and delivery service)", "type", "delivery_service")
+	// Gauges
+	UserSignupDeactivationBacklog = newGauge("user_signups_deactivation_backlog_current", "Current number of UserSignups whose deactivation is due but postponed by the deactivation throttle")
+	NotificationUnsentGauge = newGauge("notifications_unsent_current", "Current number of Notifications pending delivery")
 	// Gauges with labels
 	UserAccountGaugeVec = newGaugeVec("user_accounts_current", "Current number of UserAccounts (per member cluster)", "cluster_name")
 	UserSignupsPerActivationAndDomainGaugeVec = newGaugeVec("users_per_activations_and_domain", "Number of UserSignups per activations and domain", []string{"activations", "domain"}...)
 	MasterUserRecordGaugeVec = newGaugeVec("master_user_records", "Number of MasterUserRecords per email address domain ('internal' vs 'external')", "domain")
+	// Histograms with labels
+	NotificationDeliveryLatencyHistogramVec = newHistogramVec("notifications_delivery_latency_seconds", "Time between the creation and the delivery of the Notifications (per type and delivery service)",
+		[]float64{1, 5, 15, 30, 60, 300, 900, 1800, 3600, 7200, 21600, 86400}, "type", "delivery_service")
 	log.Info("custom metrics initialized")
 }
 
@@ -87,6 +132,15 @@ func newCounter(name, help string) prometheus.Counter {
 	return c
 }
 
+func newCounterVec(name, help string, labels ...string) *prometheus.CounterVec {
+	v := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: metricsPrefix + name,
+		Help: help,
+	}, labels)
+	allCounterVecs = append(allCounterVecs, v)
+	return v
+}
+
 func newGauge(name, help string) prometheus.Gauge {
 	g := prometheus.NewGauge(prometheus.GaugeOpts{
 		Name: metricsPrefix + name,
@@ -105,17 +159,33 @@ func newGaugeVec(name, help string, labels ...string) *prometheus.GaugeVec {
 	return v
 }
 
+func newHistogramVec(name, help string, buckets []float64, labels ...string) *prometheus.HistogramVec {
+	v := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    metricsPrefix + name,
+		Help:    help,
+		Buckets: buckets,
+	}, labels)
+	allHistogramVecs = append(allHistogramVecs, v)
+	return v
+}
+
 // RegisterCustomMetrics registers the custom metrics
 func RegisterCustomMetrics() {
 	// register metrics
 	for _, c := range allCounters {
 		k8smetrics.Registry.MustRegister(c)
 	}
+	for _, v := range allCounterVecs {
+		k8smetrics.Registry.MustRegister(v)
+	}
 	for _, g := range allGauges {
 		k8smetrics.Registry.MustRegister(g)
 	}
 	for _, v := range allGaugeVecs {
 		k8smetrics.Registry.MustRegister(v)
 	}
+	for _, v := range allHistogramVecs {
+		k8smetrics.Registry.MustRegister(v)
+	}
 	log.Info("custom metrics registered")
 }
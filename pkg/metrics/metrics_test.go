@@ -19,6 +19,20 @@ func TestInitCounter(t *testing.T) {
 	assert.Equal(t, float64(1), promtestutil.ToFloat64(m))
 }
 
+func TestInitCounterVec(t *testing.T) {
+	// given
+	m := newCounterVec("test_counter_vec", "test counter description", "type", "delivery_service")
+
+	// when
+	m.WithLabelValues("userdeactivated", "mailgun").Inc()
+	m.WithLabelValues("userdeactivated", "mailgun").Inc()
+	m.WithLabelValues("userprovisioned", "mailgun").Inc()
+
+	// then
+	assert.Equal(t, float64(2), promtestutil.ToFloat64(m.WithLabelValues("userdeactivated", "mailgun")))
+	assert.Equal(t, float64(1), promtestutil.ToFloat64(m.WithLabelValues("userprovisioned", "mailgun")))
+}
+
 func TestInitGauge(t *testing.T) {
 	// given
 	m := newGauge("test_gauge", "test gauge description")
@@ -43,6 +57,18 @@ func TestInitGaugeVec(t *testing.T) {
 	assert.Equal(t, float64(2), promtestutil.ToFloat64(m.WithLabelValues("member-2")))
 }
 
+func TestInitHistogramVec(t *testing.T) {
+	// given
+	m := newHistogramVec("test_histogram_vec", "test histogram description", []float64{1, 10}, "type")
+
+	// when
+	m.WithLabelValues("userdeactivated").Observe(0.5)
+	m.WithLabelValues("userprovisioned").Observe(5)
+
+	// then
+	assert.Equal(t, 2, promtestutil.CollectAndCount(m))
+}
+
 func TestRegisterCustomMetrics(t *testing.T) {
 	// when
 	RegisterCustomMetrics()
@@ -53,6 +79,10 @@ func TestRegisterCustomMetrics(t *testing.T) {
 		assert.True(t, k8smetrics.Registry.Unregister(m))
 	}
 
+	for _, m := range allCounterVecs {
+		assert.True(t, k8smetrics.Registry.Unregister(m))
+	}
+
 	for _, m := range allGauges {
 		assert.True(t, k8smetrics.Registry.Unregister(m))
 	}
@@ -60,6 +90,10 @@ func TestRegisterCustomMetrics(t *testing.T) {
 	for _, m := range allGaugeVecs {
 		assert.True(t, k8smetrics.Registry.Unregister(m))
 	}
+
+	for _, m := range allHistogramVecs {
+		assert.True(t, k8smetrics.Registry.Unregister(m))
+	}
 }
 
 func TestResetMetrics(t *testing.T) {
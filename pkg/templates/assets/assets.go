@@ -1,5 +1,14 @@
 package assets
 
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
 // NewAssets returns a new wrapper for the binary assets generated by go-bindata
 func NewAssets(names func() []string, asset func(string) ([]byte, error)) Assets {
 	return Assets{
@@ -13,3 +22,34 @@ type Assets struct {
 	Names func() []string
 	Asset func(string) ([]byte, error)
 }
+
+// NewDirAssets returns a new wrapper for the YAML files in the given directory (and its sub-directories), whose names
+// are the paths relative to the directory, as with the binary assets generated by go-bindata
+func NewDirAssets(dir string) (Assets, error) {
+	var names []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || filepath.Ext(path) != ".yaml" {
+			return nil
+		}
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		names = append(names, filepath.ToSlash(name))
+		return nil
+	})
+	if err != nil {
+		return Assets{}, errors.Wrapf(err, "unable to load the assets from '%s'", dir)
+	}
+	sort.Strings(names)
+	return NewAssets(
+		func() []string {
+			return names
+		},
+		func(name string) ([]byte, error) {
+			return ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		}), nil
+}
@@ -1,6 +1,9 @@
 package notificationtemplates
 
 import (
+	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/codeready-toolchain/host-operator/pkg/templates/assets"
@@ -8,32 +11,200 @@ import (
 	"github.com/pkg/errors"
 )
 
+// DefaultLocale is the locale of the default variant of the notification templates, ie, `notification.html`, `subject.txt`
+// and the optional `notification.txt` (plain-text variant of the content).
+// The variants in other locales are named after their locale, eg. `notification.de.html` and `subject.de.txt`
+const DefaultLocale = "en"
+
 var notificationTemplates map[string]NotificationTemplate
+
+// localizedNotificationTemplates contains the variants of the notification templates in the locales other than the default one,
+// indexed by template name and then by locale
+var localizedNotificationTemplates map[string]map[string]NotificationTemplate
+
 var UserProvisioned, _, _ = GetNotificationTemplate("userprovisioned")
 var UserDeactivated, _, _ = GetNotificationTemplate("userdeactivated")
 var UserDeactivating, _, _ = GetNotificationTemplate("userdeactivating")
+var SpaceExpiring, _, _ = GetNotificationTemplate("spaceexpiring")
+var TierChanged, _, _ = GetNotificationTemplate("tierchanged")
+var Banned, _, _ = GetNotificationTemplate("banned")
 
-// NotificationTemplate contains the template subject and content
+// NotificationTemplate contains the template subject and content.
+// The optional text content is the plain-text variant of the (HTML) content.
 type NotificationTemplate struct {
-	Subject string
-	Content string
-	Name    string
+	Subject     string
+	Content     string
+	TextContent string
+	Name        string
+	Locale      string
 }
 
 // GetNotificationTemplate returns a notification subject, body and a boolean
-// indicating whether or not a template was found. Otherwise, an error will be returned
-func GetNotificationTemplate(name string) (*NotificationTemplate, bool, error) {
+// indicating whether or not a template was found. Otherwise, an error will be returned.
+// If some locales are given (in order of preference), then the variant of the template in the first of these locales
+// which is available is returned, or the variant in the default locale if none is available.
+func GetNotificationTemplate(name string, locales ...string) (*NotificationTemplate, bool, error) {
+	templates, err := GetTemplates()
+	if err != nil {
+		return nil, false, err
+	}
+	template, found := templates.Get(name, locales...)
+	return template, found, nil
+}
+
+// GetTemplates returns all the notification templates, along with their variants in the locales other than the default one
+func GetTemplates() (*Templates, error) {
 	templates, err := loadTemplates()
 	if err != nil {
-		return nil, false, errors.Wrap(err, "unable to get notification templates")
+		return nil, errors.Wrap(err, "unable to get notification templates")
+	}
+	return &Templates{
+		templates:          templates,
+		localizedTemplates: localizedNotificationTemplates,
+	}, nil
+}
+
+// Templates is a set of notification templates, along with their variants in the locales other than the default one
+type Templates struct {
+	templates          map[string]NotificationTemplate
+	localizedTemplates map[string]map[string]NotificationTemplate
+}
+
+// Get returns the template with the given name and a boolean indicating whether or not it was found.
+// If some locales are given (in order of preference), then the variant of the template in the first of these locales
+// which is available is returned, or the variant in the default locale if none is available.
+func (t *Templates) Get(name string, locales ...string) (*NotificationTemplate, bool) {
+	if template, found := t.localizedTemplate(name, locales); found {
+		return &template, true
+	}
+	template, found := t.templates[name]
+	return &template, found
+}
+
+// Names returns the sorted names of the templates
+func (t *Templates) Names() []string {
+	names := make([]string, 0, len(t.templates))
+	for name := range t.templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Variants returns the variants of the template with the given name, starting with the variant in the default locale
+// followed by the variants in the other locales sorted by locale
+func (t *Templates) Variants(name string) []NotificationTemplate {
+	var variants []NotificationTemplate
+	if template, found := t.templates[name]; found {
+		variants = append(variants, template)
+	}
+	locales := make([]string, 0, len(t.localizedTemplates[name]))
+	for locale := range t.localizedTemplates[name] {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	for _, locale := range locales {
+		variants = append(variants, t.localizedTemplates[name][locale])
+	}
+	return variants
+}
+
+// localizedTemplate returns the variant of the template in the first of the given locales which is available.
+// A locale with a region (eg. `de-CH`) falls back to its language (`de`).
+func (t *Templates) localizedTemplate(name string, locales []string) (NotificationTemplate, bool) {
+	for _, locale := range locales {
+		locale = normalizeLocale(locale)
+		for _, candidate := range []string{locale, strings.Split(locale, "-")[0]} {
+			if candidate == DefaultLocale {
+				return NotificationTemplate{}, false
+			}
+			if template, found := t.localizedTemplates[name][candidate]; found {
+				return template, true
+			}
+		}
 	}
-	template, found := templates[name]
-	return &template, found, nil
+	return NotificationTemplate{}, false
+}
+
+// Locales returns the sorted locales in which the notification templates are available, besides the default locale
+func Locales() ([]string, error) {
+	if _, err := loadTemplates(); err != nil {
+		return nil, errors.Wrap(err, "unable to get notification templates")
+	}
+	localeSet := map[string]bool{}
+	for _, variants := range localizedNotificationTemplates {
+		for locale := range variants {
+			localeSet[locale] = true
+		}
+	}
+	locales := make([]string, 0, len(localeSet))
+	for locale := range localeSet {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales, nil
+}
+
+// PreferredLocales returns the locales listed in the given value, which is either a single locale (eg. `de`) or the value of an
+// Accept-Language HTTP header (eg. `de-CH,de;q=0.9,en;q=0.8`), in order of preference
+func PreferredLocales(value string) []string {
+	type weightedLocale struct {
+		locale string
+		weight float64
+	}
+	var weighted []weightedLocale
+	for _, item := range strings.Split(value, ",") {
+		segments := strings.Split(strings.TrimSpace(item), ";")
+		locale := strings.TrimSpace(segments[0])
+		if locale == "" || locale == "*" {
+			continue
+		}
+		weight := 1.0
+		for _, param := range segments[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err == nil {
+					weight = q
+				}
+			}
+		}
+		if weight <= 0 {
+			continue
+		}
+		weighted = append(weighted, weightedLocale{locale: normalizeLocale(locale), weight: weight})
+	}
+	sort.SliceStable(weighted, func(i, j int) bool {
+		return weighted[i].weight > weighted[j].weight
+	})
+	locales := make([]string, len(weighted))
+	for i, w := range weighted {
+		locales[i] = w.locale
+	}
+	return locales
+}
+
+// normalizeLocale returns the given locale in lower case and with a dash as separator, eg. `de_CH` -> `de-ch`
+func normalizeLocale(locale string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(locale)), "_", "-")
 }
 
 func templatesForAssets(assets assets.Assets) (map[string]NotificationTemplate, error) {
+	templates, err := ParseTemplates(assets)
+	if err != nil {
+		return nil, err
+	}
+	notificationTemplates = templates.templates
+	localizedNotificationTemplates = templates.localizedTemplates
+	return notificationTemplates, nil
+}
+
+// ParseTemplates parses and validates the notification templates contained in the given assets, whose names are `<template>/<file>`
+// where the file is one of `notification.html`, `notification.txt` and `subject.txt` for the default locale, or
+// `notification.<locale>.html`, `notification.<locale>.txt` and `subject.<locale>.txt` for the other locales
+func ParseTemplates(assets assets.Assets) (*Templates, error) {
 	paths := assets.Names()
-	notificationTemplates = make(map[string]NotificationTemplate)
+	templates := make(map[string]NotificationTemplate)
+	localizedTemplates := make(map[string]map[string]NotificationTemplate)
 	for _, path := range paths {
 		content, err := assets.Asset(path)
 		if err != nil {
@@ -46,21 +217,55 @@ func templatesForAssets(assets assets.Assets) (map[string]NotificationTemplate,
 		directoryName := segments[0]
 		filename := segments[1]
 
-		template := notificationTemplates[directoryName]
+		// the filename is either `<kind>.<ext>` for the default locale or `<kind>.<locale>.<ext>`
+		locale := DefaultLocale
+		if parts := strings.Split(filename, "."); len(parts) == 3 {
+			locale = normalizeLocale(parts[1])
+			filename = parts[0] + "." + parts[2]
+		}
+
+		template := templates[directoryName]
+		if locale != DefaultLocale {
+			template = localizedTemplates[directoryName][locale]
+		}
 		template.Name = directoryName
+		template.Locale = locale
 		switch filename {
 		case "notification.html":
 			template.Content = string(content)
-			notificationTemplates[directoryName] = template
+		case "notification.txt":
+			template.TextContent = string(content)
 		case "subject.txt":
 			template.Subject = string(content)
-			notificationTemplates[directoryName] = template
 		default:
 			return nil, errors.Wrapf(errors.New("must contain notification.html and subject.txt"), "unable to load templates")
 		}
+		if locale == DefaultLocale {
+			templates[directoryName] = template
+			continue
+		}
+		if localizedTemplates[directoryName] == nil {
+			localizedTemplates[directoryName] = map[string]NotificationTemplate{}
+		}
+		localizedTemplates[directoryName][locale] = template
 	}
 
-	return notificationTemplates, nil
+	// the localized variants must be complete and must have a default variant to fall back to
+	for name, variants := range localizedTemplates {
+		if _, found := templates[name]; !found {
+			return nil, errors.Wrapf(fmt.Errorf("the '%s' template has no variant in the default locale", name), "unable to load templates")
+		}
+		for locale, template := range variants {
+			if template.Subject == "" || template.Content == "" {
+				return nil, errors.Wrapf(fmt.Errorf("the '%s' variant of the '%s' template must contain notification.%s.html and subject.%s.txt", locale, name, locale, locale), "unable to load templates")
+			}
+		}
+	}
+
+	return &Templates{
+		templates:          templates,
+		localizedTemplates: localizedTemplates,
+	}, nil
 }
 
 func loadTemplates() (map[string]NotificationTemplate, error) {
@@ -1,6 +1,8 @@
 package notificationtemplates
 
 import (
+	"regexp"
+	"sort"
 	"testing"
 
 	"github.com/codeready-toolchain/host-operator/pkg/templates/assets"
@@ -37,6 +39,39 @@ func TestGetNotificationTemplate(t *testing.T) {
 			assert.Equal(t, "Notice: Your Developer Sandbox for Red Hat OpenShift account is provisioned", template.Subject)
 			assert.Contains(t, template.Content, "Your account has been provisioned and is ready to use. Your account will be active for 30 days.")
 		})
+		t.Run("get spaceexpiring notification template", func(t *testing.T) {
+			// when
+			defer resetNotificationTemplateCache()
+			template, found, err := GetNotificationTemplate("spaceexpiring")
+			// then
+			require.NoError(t, err)
+			require.NotNil(t, template)
+			assert.True(t, found)
+			assert.Equal(t, "Notice: Your Developer Sandbox for Red Hat OpenShift space {{.SpaceName}} will expire soon", template.Subject)
+			assert.Contains(t, template.Content, "This space will expire on {{.ExpirationDate}}.")
+		})
+		t.Run("get tierchanged notification template", func(t *testing.T) {
+			// when
+			defer resetNotificationTemplateCache()
+			template, found, err := GetNotificationTemplate("tierchanged")
+			// then
+			require.NoError(t, err)
+			require.NotNil(t, template)
+			assert.True(t, found)
+			assert.Equal(t, "Notice: Your Developer Sandbox for Red Hat OpenShift account was moved to the {{.NewTierName}} tier", template.Subject)
+			assert.Contains(t, template.Content, "Your account was moved from the {{.OldTierName}} tier to the {{.NewTierName}} tier by an administrator.")
+		})
+		t.Run("get banned notification template", func(t *testing.T) {
+			// when
+			defer resetNotificationTemplateCache()
+			template, found, err := GetNotificationTemplate("banned")
+			// then
+			require.NoError(t, err)
+			require.NotNil(t, template)
+			assert.True(t, found)
+			assert.Equal(t, "Notice: Your Developer Sandbox for Red Hat OpenShift account is disabled", template.Subject)
+			assert.Contains(t, template.Content, "If you believe this is a mistake, please visit {{.SupportURL}}.")
+		})
 		t.Run("ensure cache is used", func(t *testing.T) {
 			// when
 			defer resetNotificationTemplateCache()
@@ -106,6 +141,203 @@ func TestGetNotificationTemplate(t *testing.T) {
 	})
 }
 
+func TestGetNotificationTemplateWithTextContent(t *testing.T) {
+	// given
+	defer resetNotificationTemplateCache()
+	_, err := templatesForAssets(fakeAssets(map[string]string{
+		"userdeactivated/notification.html":    "<p>Your account is deactivated</p>",
+		"userdeactivated/notification.txt":     "Your account is deactivated",
+		"userdeactivated/subject.txt":          "Deactivated",
+		"userdeactivated/notification.de.html": "<p>Ihr Konto ist deaktiviert</p>",
+		"userdeactivated/subject.de.txt":       "Deaktiviert",
+	}))
+	require.NoError(t, err)
+
+	t.Run("with text content", func(t *testing.T) {
+		// when
+		template, found, err := GetNotificationTemplate("userdeactivated")
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, "<p>Your account is deactivated</p>", template.Content)
+		assert.Equal(t, "Your account is deactivated", template.TextContent)
+	})
+
+	t.Run("without text content", func(t *testing.T) {
+		// when
+		template, found, err := GetNotificationTemplate("userdeactivated", "de")
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, "<p>Ihr Konto ist deaktiviert</p>", template.Content)
+		assert.Empty(t, template.TextContent)
+	})
+}
+
+func TestGetLocalizedNotificationTemplate(t *testing.T) {
+	// given
+	defer resetNotificationTemplateCache()
+	_, err := templatesForAssets(fakeAssets(map[string]string{
+		"userdeactivated/notification.html":       "Your account is deactivated",
+		"userdeactivated/subject.txt":             "Deactivated",
+		"userdeactivated/notification.de.html":    "Ihr Konto ist deaktiviert",
+		"userdeactivated/subject.de.txt":          "Deaktiviert",
+		"userdeactivated/notification.pt_BR.html": "Sua conta está desativada",
+		"userdeactivated/subject.pt_BR.txt":       "Desativada",
+	}))
+	require.NoError(t, err)
+
+	for name, tc := range map[string]struct {
+		locales         []string
+		expectedLocale  string
+		expectedSubject string
+	}{
+		"no locale":                     {locales: nil, expectedLocale: "en", expectedSubject: "Deactivated"},
+		"default locale":                {locales: []string{"en"}, expectedLocale: "en", expectedSubject: "Deactivated"},
+		"available locale":              {locales: []string{"de"}, expectedLocale: "de", expectedSubject: "Deaktiviert"},
+		"available locale with region":  {locales: []string{"pt-BR"}, expectedLocale: "pt-br", expectedSubject: "Desativada"},
+		"fallback to language":          {locales: []string{"de-CH"}, expectedLocale: "de", expectedSubject: "Deaktiviert"},
+		"fallback to second preference": {locales: []string{"fr", "de"}, expectedLocale: "de", expectedSubject: "Deaktiviert"},
+		"default locale preferred":      {locales: []string{"en-US", "de"}, expectedLocale: "en", expectedSubject: "Deactivated"},
+		"fallback to default locale":    {locales: []string{"fr", "pt"}, expectedLocale: "en", expectedSubject: "Deactivated"},
+		"empty locale":                  {locales: []string{""}, expectedLocale: "en", expectedSubject: "Deactivated"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			// when
+			template, found, err := GetNotificationTemplate("userdeactivated", tc.locales...)
+
+			// then
+			require.NoError(t, err)
+			assert.True(t, found)
+			assert.Equal(t, "userdeactivated", template.Name)
+			assert.Equal(t, tc.expectedLocale, template.Locale)
+			assert.Equal(t, tc.expectedSubject, template.Subject)
+		})
+	}
+
+	t.Run("unknown template", func(t *testing.T) {
+		// when
+		_, found, err := GetNotificationTemplate("unknown", "de")
+
+		// then
+		require.NoError(t, err)
+		assert.False(t, found)
+	})
+
+	t.Run("locales", func(t *testing.T) {
+		// when
+		locales, err := Locales()
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []string{"de", "pt-br"}, locales)
+	})
+
+	t.Run("failures", func(t *testing.T) {
+
+		t.Run("incomplete locale", func(t *testing.T) {
+			// given
+			defer resetNotificationTemplateCache()
+
+			// when
+			_, err := templatesForAssets(fakeAssets(map[string]string{
+				"userdeactivated/notification.html":    "Your account is deactivated",
+				"userdeactivated/subject.txt":          "Deactivated",
+				"userdeactivated/notification.de.html": "Ihr Konto ist deaktiviert",
+			}))
+
+			// then
+			require.EqualError(t, err, "unable to load templates: the 'de' variant of the 'userdeactivated' template must contain notification.de.html and subject.de.txt")
+		})
+
+		t.Run("no default locale", func(t *testing.T) {
+			// given
+			defer resetNotificationTemplateCache()
+
+			// when
+			_, err := templatesForAssets(fakeAssets(map[string]string{
+				"userdeactivated/notification.de.html": "Ihr Konto ist deaktiviert",
+				"userdeactivated/subject.de.txt":       "Deaktiviert",
+			}))
+
+			// then
+			require.EqualError(t, err, "unable to load templates: the 'userdeactivated' template has no variant in the default locale")
+		})
+	})
+}
+
+func TestAllLocalesHaveTheSameTemplates(t *testing.T) {
+	// given
+	defer resetNotificationTemplateCache()
+	templates, err := loadTemplates()
+	require.NoError(t, err)
+
+	// when
+	locales, err := Locales()
+
+	// then
+	require.NoError(t, err)
+	require.NotEmpty(t, locales, "no template is available in a locale other than the default one")
+	for _, locale := range locales {
+		for name, defaultTemplate := range templates {
+			t.Run(locale+"/"+name, func(t *testing.T) {
+				template, found := localizedNotificationTemplates[name][locale]
+				require.True(t, found, "the '%s' template is not available in the '%s' locale", name, locale)
+				assert.NotEmpty(t, template.Subject)
+				assert.NotEmpty(t, template.Content)
+				// the variants render the same values
+				assert.Equal(t, placeholdersOf(defaultTemplate.Subject), placeholdersOf(template.Subject))
+				assert.Equal(t, placeholdersOf(defaultTemplate.Content), placeholdersOf(template.Content))
+			})
+		}
+	}
+}
+
+// placeholdersOf returns the sorted and deduplicated actions of the given template definition, eg. `{{.UserEmail}}`
+func placeholdersOf(definition string) []string {
+	placeholders := map[string]bool{}
+	for _, placeholder := range regexp.MustCompile(`{{[^}]*}}`).FindAllString(definition, -1) {
+		placeholders[placeholder] = true
+	}
+	result := make([]string, 0, len(placeholders))
+	for placeholder := range placeholders {
+		result = append(result, placeholder)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func TestPreferredLocales(t *testing.T) {
+	for value, expected := range map[string][]string{
+		"":                                {},
+		"de":                              {"de"},
+		"pt_BR":                           {"pt-br"},
+		"de-CH,de;q=0.9,en;q=0.8,*;q=0.5": {"de-ch", "de", "en"},
+		"en;q=0.5, fr , de;q=0.7":         {"fr", "de", "en"},
+		"fr;q=0, de":                      {"de"},
+		"de;q=invalid":                    {"de"},
+	} {
+		t.Run(value, func(t *testing.T) {
+			assert.Equal(t, expected, PreferredLocales(value))
+		})
+	}
+}
+
+func fakeAssets(files map[string]string) assets.Assets {
+	return assets.NewAssets(func() []string {
+		names := make([]string, 0, len(files))
+		for name := range files {
+			names = append(names, name)
+		}
+		return names
+	}, func(name string) ([]byte, error) {
+		return []byte(files[name]), nil
+	})
+}
+
 func resetNotificationTemplateCache() {
 	notificationTemplates = nil
+	localizedNotificationTemplates = nil
 }
@@ -0,0 +1,165 @@
+package nstemplatetiers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/host-operator/pkg/templates/assets"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// BundleLabelKey is the key of the label of the ConfigMaps which contain a tier bundle. The value of the label is the name of the tier,
+// and the data of the ConfigMap contains the files of the tier, as in the `deploy/templates/nstemplatetiers/<tier>` directory
+// (ie, `tier.yaml`, `cluster.yaml` and `ns_<type>.yaml`, or `based_on_tier.yaml`), as well as an optional `metadata.yaml`
+// with the revisions of these files (eg. `ns_dev: 1a2b3c4`)
+const BundleLabelKey = toolchainv1alpha1.LabelKeyPrefix + "nstemplatetier-bundle"
+
+const metadataAsset = "metadata.yaml"
+
+// NewConfigMapAssets returns the assets of the tier bundles contained in the given ConfigMaps, with the same layout as the
+// embedded assets. The invalid ConfigMaps are skipped and passed to the given `onInvalid` func (if not nil) along with the error,
+// so that they do not prevent the other tiers from being loaded: the ConfigMaps without tier name or with an invalid `metadata.yaml`,
+// and the ConfigMaps which define a tier already defined by another ConfigMap (the first one in the alphabetical order is kept).
+func NewConfigMapAssets(configMaps []corev1.ConfigMap, onInvalid func(cm corev1.ConfigMap, err error)) (assets.Assets, error) {
+	configMaps = append([]corev1.ConfigMap{}, configMaps...)
+	sort.Slice(configMaps, func(i, j int) bool {
+		return configMaps[i].Name < configMaps[j].Name
+	})
+	files := map[string][]byte{}
+	revisions := map[string]string{}
+	configMapsByTier := map[string]string{}
+	for _, cm := range configMaps {
+		cmFiles, cmRevisions, err := configMapBundle(cm, configMapsByTier)
+		if err != nil {
+			if onInvalid != nil {
+				onInvalid(cm, err)
+			}
+			continue
+		}
+		configMapsByTier[cm.Labels[BundleLabelKey]] = cm.Name
+		for name, content := range cmFiles {
+			files[name] = content
+		}
+		for name, revision := range cmRevisions {
+			revisions[name] = revision
+		}
+	}
+	return newMemoryAssets(files, revisions)
+}
+
+// configMapBundle returns the files and the revisions of the tier bundle contained in the given ConfigMap.
+// Returns an error if the ConfigMap is invalid, or if its tier is already defined by one of the given ConfigMaps (indexed by tier name)
+func configMapBundle(cm corev1.ConfigMap, configMapsByTier map[string]string) (map[string][]byte, map[string]string, error) {
+	tier := cm.Labels[BundleLabelKey]
+	if tier == "" {
+		return nil, nil, fmt.Errorf("the ConfigMap '%s' has no tier name in its '%s' label", cm.Name, BundleLabelKey)
+	}
+	if other, found := configMapsByTier[tier]; found {
+		return nil, nil, fmt.Errorf("the '%s' tier is defined by several ConfigMaps: %s, %s", tier, other, cm.Name)
+	}
+	files := map[string][]byte{}
+	revisions := map[string]string{}
+	for key, content := range cm.Data {
+		if key != metadataAsset {
+			files[tier+"/"+key] = []byte(content)
+			continue
+		}
+		metadata := map[string]string{}
+		if err := yaml.Unmarshal([]byte(content), &metadata); err != nil {
+			return nil, nil, errors.Wrapf(err, "invalid %s in the ConfigMap '%s'", metadataAsset, cm.Name)
+		}
+		for file, revision := range metadata {
+			revisions[tier+"/"+strings.TrimSuffix(file, ".yaml")] = revision
+		}
+	}
+	return files, revisions, nil
+}
+
+// MergeAssets merges the tier bundles of the given assets. A tier of an asset replaces the tier with the same name in the previous
+// assets (along with all its files), so that the embedded tiers can be overridden.
+// The revision of a file which is not listed in the `metadata.yaml` of its asset is computed from the content of the file.
+func MergeAssets(sources ...assets.Assets) (assets.Assets, error) {
+	files := map[string][]byte{}
+	revisions := map[string]string{}
+	for _, source := range sources {
+		sourceRevisions := map[string]string{}
+		sourceFiles := map[string][]byte{}
+		sourceTiers := map[string]bool{}
+		for _, name := range source.Names() {
+			content, err := source.Asset(name)
+			if err != nil {
+				return assets.Assets{}, errors.Wrapf(err, "unable to load the '%s' asset", name)
+			}
+			if name == metadataAsset {
+				if err := yaml.Unmarshal(content, &sourceRevisions); err != nil {
+					return assets.Assets{}, errors.Wrapf(err, "invalid %s", metadataAsset)
+				}
+				continue
+			}
+			sourceFiles[name] = content
+			sourceTiers[tierOfAsset(name)] = true
+		}
+		for name := range files {
+			if sourceTiers[tierOfAsset(name)] {
+				delete(files, name)
+				delete(revisions, strings.TrimSuffix(name, ".yaml"))
+			}
+		}
+		for name, content := range sourceFiles {
+			files[name] = content
+			key := strings.TrimSuffix(name, ".yaml")
+			if revision := sourceRevisions[key]; revision != "" {
+				revisions[key] = revision
+			} else {
+				revisions[key] = contentRevision(content)
+			}
+		}
+	}
+	return newMemoryAssets(files, revisions)
+}
+
+// tierOfAsset returns the name of the tier of the asset with the given name, ie, the name of its parent directory
+func tierOfAsset(name string) string {
+	return strings.SplitN(name, "/", 2)[0]
+}
+
+// contentRevision returns a revision computed from the given content, in the form of a short git commit hash
+func contentRevision(content []byte) string {
+	hash := sha256.Sum256(content)
+	return hex.EncodeToString(hash[:])[:7]
+}
+
+// newMemoryAssets returns the assets of the given files, along with a `metadata.yaml` asset containing the given revisions
+func newMemoryAssets(files map[string][]byte, revisions map[string]string) (assets.Assets, error) {
+	metadata, err := yaml.Marshal(revisions)
+	if err != nil {
+		return assets.Assets{}, errors.Wrapf(err, "unable to marshal the %s asset", metadataAsset)
+	}
+	names := make([]string, 0, len(files)+1)
+	for name := range files {
+		names = append(names, name)
+	}
+	names = append(names, metadataAsset)
+	sort.Strings(names)
+	return assets.NewAssets(
+		func() []string {
+			return names
+		},
+		func(name string) ([]byte, error) {
+			if name == metadataAsset {
+				return metadata, nil
+			}
+			content, found := files[name]
+			if !found {
+				return nil, fmt.Errorf("asset %s not found", name)
+			}
+			return content, nil
+		}), nil
+}
@@ -0,0 +1,247 @@
+package nstemplatetiers_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/codeready-toolchain/host-operator/pkg/apis"
+	"github.com/codeready-toolchain/host-operator/pkg/templates/assets"
+	"github.com/codeready-toolchain/host-operator/pkg/templates/nstemplatetiers"
+	testsupport "github.com/codeready-toolchain/toolchain-common/pkg/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+func TestNewConfigMapAssets(t *testing.T) {
+
+	t.Run("ok", func(t *testing.T) {
+		// given
+		configMaps := []corev1.ConfigMap{
+			newBundleConfigMap("custom-tier", "custom", map[string]string{
+				"based_on_tier.yaml": "from: base\n",
+				"metadata.yaml":      "based_on_tier: 1a2b3c4\n",
+			}),
+			newBundleConfigMap("other-tier", "other", map[string]string{
+				"tier.yaml":   "kind: Template\n",
+				"ns_dev.yaml": "kind: Template\n",
+			}),
+		}
+
+		// when
+		cmAssets, err := nstemplatetiers.NewConfigMapAssets(configMaps, failOnInvalid(t))
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []string{"custom/based_on_tier.yaml", "metadata.yaml", "other/ns_dev.yaml", "other/tier.yaml"}, cmAssets.Names())
+		content, err := cmAssets.Asset("custom/based_on_tier.yaml")
+		require.NoError(t, err)
+		assert.Equal(t, "from: base\n", string(content))
+		assert.Equal(t, map[string]string{"custom/based_on_tier": "1a2b3c4"}, metadataOf(t, cmAssets))
+		_, err = cmAssets.Asset("custom/tier.yaml")
+		require.EqualError(t, err, "asset custom/tier.yaml not found")
+	})
+
+	t.Run("invalid ConfigMaps skipped", func(t *testing.T) {
+
+		t.Run("no tier name", func(t *testing.T) {
+			// given
+			cm := newBundleConfigMap("custom-tier", "", map[string]string{"based_on_tier.yaml": "from: base\n"})
+			invalid := map[string]error{}
+
+			// when
+			cmAssets, err := nstemplatetiers.NewConfigMapAssets([]corev1.ConfigMap{cm}, collectInvalid(invalid))
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, []string{"metadata.yaml"}, cmAssets.Names())
+			require.Len(t, invalid, 1)
+			require.EqualError(t, invalid["custom-tier"], "the ConfigMap 'custom-tier' has no tier name in its 'toolchain.dev.openshift.com/nstemplatetier-bundle' label")
+		})
+
+		t.Run("tier defined by several ConfigMaps", func(t *testing.T) {
+			// given
+			configMaps := []corev1.ConfigMap{
+				newBundleConfigMap("custom-tier-2", "custom", map[string]string{"based_on_tier.yaml": "from: base\n"}),
+				newBundleConfigMap("custom-tier-1", "custom", map[string]string{"based_on_tier.yaml": "from: advanced\n"}),
+				newBundleConfigMap("other-tier", "other", map[string]string{"based_on_tier.yaml": "from: base\n"}),
+			}
+			invalid := map[string]error{}
+
+			// when
+			cmAssets, err := nstemplatetiers.NewConfigMapAssets(configMaps, collectInvalid(invalid))
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, []string{"custom/based_on_tier.yaml", "metadata.yaml", "other/based_on_tier.yaml"}, cmAssets.Names())
+			content, err := cmAssets.Asset("custom/based_on_tier.yaml")
+			require.NoError(t, err)
+			assert.Equal(t, "from: advanced\n", string(content))
+			require.Len(t, invalid, 1)
+			require.EqualError(t, invalid["custom-tier-2"], "the 'custom' tier is defined by several ConfigMaps: custom-tier-1, custom-tier-2")
+		})
+
+		t.Run("invalid metadata", func(t *testing.T) {
+			// given
+			configMaps := []corev1.ConfigMap{
+				newBundleConfigMap("custom-tier", "custom", map[string]string{
+					"based_on_tier.yaml": "from: base\n",
+					"metadata.yaml":      "- not a map",
+				}),
+				newBundleConfigMap("other-tier", "other", map[string]string{"based_on_tier.yaml": "from: base\n"}),
+			}
+			invalid := map[string]error{}
+
+			// when
+			cmAssets, err := nstemplatetiers.NewConfigMapAssets(configMaps, collectInvalid(invalid))
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, []string{"metadata.yaml", "other/based_on_tier.yaml"}, cmAssets.Names())
+			require.Len(t, invalid, 1)
+			require.Error(t, invalid["custom-tier"])
+			assert.Contains(t, invalid["custom-tier"].Error(), "invalid metadata.yaml in the ConfigMap 'custom-tier'")
+		})
+	})
+}
+
+// failOnInvalid returns a func which fails the test when it is called with an invalid ConfigMap
+func failOnInvalid(t *testing.T) func(corev1.ConfigMap, error) {
+	return func(cm corev1.ConfigMap, err error) {
+		assert.Fail(t, "unexpected invalid ConfigMap", "%s: %v", cm.Name, err)
+	}
+}
+
+// collectInvalid returns a func which collects the errors of the invalid ConfigMaps in the given map
+func collectInvalid(invalid map[string]error) func(corev1.ConfigMap, error) {
+	return func(cm corev1.ConfigMap, err error) {
+		invalid[cm.Name] = err
+	}
+}
+
+func TestMergeAssets(t *testing.T) {
+
+	s := scheme.Scheme
+	err := apis.AddToScheme(s)
+	require.NoError(t, err)
+	embedded := assets.NewAssets(nstemplatetiers.AssetNames, nstemplatetiers.Asset)
+
+	t.Run("new and overridden tiers", func(t *testing.T) {
+		// given
+		cmAssets, err := nstemplatetiers.NewConfigMapAssets([]corev1.ConfigMap{
+			newBundleConfigMap("custom-tier", "custom", map[string]string{
+				"based_on_tier.yaml": "from: base\nparameters:\n- name: IDLER_TIMEOUT_SECONDS\n  value: \"3600\"\n",
+			}),
+			newBundleConfigMap("advanced-tier", "advanced", map[string]string{
+				"based_on_tier.yaml": "from: base\n",
+				"metadata.yaml":      "based_on_tier: 1a2b3c4\n",
+			}),
+		}, failOnInvalid(t))
+		require.NoError(t, err)
+
+		// when
+		merged, err := nstemplatetiers.MergeAssets(embedded, cmAssets)
+
+		// then
+		require.NoError(t, err)
+		assert.Contains(t, merged.Names(), "custom/based_on_tier.yaml")
+		assert.Contains(t, merged.Names(), "base/tier.yaml")
+		metadata := metadataOf(t, merged)
+		assert.Equal(t, "1a2b3c4", metadata["advanced/based_on_tier"])
+		assert.Len(t, metadata["custom/based_on_tier"], 7) // computed from the content
+		assert.Equal(t, "c14885a", metadata["base/tier"])
+
+		t.Run("generate the TierTemplates of the new tier", func(t *testing.T) {
+			// when
+			tierTmpls, err := nstemplatetiers.GenerateTierTemplates(s, testsupport.HostOperatorNs, merged, "custom")
+
+			// then
+			require.NoError(t, err)
+			require.NotEmpty(t, tierTmpls)
+			for _, tierTmpl := range tierTmpls {
+				assert.Equal(t, "custom", tierTmpl.Spec.TierName)
+				assert.Contains(t, tierTmpl.Name, metadata["custom/based_on_tier"])
+			}
+		})
+
+		t.Run("generate the TierTemplates of the overridden tier", func(t *testing.T) {
+			// when
+			tierTmpls, err := nstemplatetiers.GenerateTierTemplates(s, testsupport.HostOperatorNs, merged, "advanced")
+
+			// then
+			require.NoError(t, err)
+			require.NotEmpty(t, tierTmpls)
+			for _, tierTmpl := range tierTmpls {
+				assert.Contains(t, tierTmpl.Name, "-1a2b3c4-")
+			}
+		})
+	})
+
+	t.Run("a tier replaces all the files of the previous tier with the same name", func(t *testing.T) {
+		// given
+		cmAssets, err := nstemplatetiers.NewConfigMapAssets([]corev1.ConfigMap{
+			newBundleConfigMap("base-tier", "base", map[string]string{
+				"based_on_tier.yaml": "from: test\n",
+			}),
+		}, failOnInvalid(t))
+		require.NoError(t, err)
+
+		// when
+		merged, err := nstemplatetiers.MergeAssets(embedded, cmAssets)
+
+		// then
+		require.NoError(t, err)
+		assert.Contains(t, merged.Names(), "base/based_on_tier.yaml")
+		assert.NotContains(t, merged.Names(), "base/tier.yaml")
+		assert.NotContains(t, merged.Names(), "base/ns_dev.yaml")
+		assert.NotContains(t, metadataOf(t, merged), "base/tier")
+	})
+
+	t.Run("directory without metadata", func(t *testing.T) {
+		// given
+		dir, err := ioutil.TempDir("", "tiers")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		require.NoError(t, os.Mkdir(filepath.Join(dir, "custom"), 0755))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "custom", "based_on_tier.yaml"), []byte("from: base\n"), 0600))
+		dirAssets, err := assets.NewDirAssets(dir)
+		require.NoError(t, err)
+
+		// when
+		merged, err := nstemplatetiers.MergeAssets(embedded, dirAssets)
+
+		// then
+		require.NoError(t, err)
+		assert.Contains(t, merged.Names(), "custom/based_on_tier.yaml")
+		assert.Len(t, metadataOf(t, merged)["custom/based_on_tier"], 7)
+		_, err = nstemplatetiers.GenerateTierTemplates(s, testsupport.HostOperatorNs, merged, "custom")
+		require.NoError(t, err)
+	})
+}
+
+func newBundleConfigMap(name, tier string, data map[string]string) corev1.ConfigMap {
+	return corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: testsupport.HostOperatorNs,
+			Name:      name,
+			Labels: map[string]string{
+				nstemplatetiers.BundleLabelKey: tier,
+			},
+		},
+		Data: data,
+	}
+}
+
+func metadataOf(t *testing.T, a assets.Assets) map[string]string {
+	content, err := a.Asset("metadata.yaml")
+	require.NoError(t, err)
+	metadata := map[string]string{}
+	require.NoError(t, yaml.Unmarshal(content, &metadata))
+	return metadata
+}
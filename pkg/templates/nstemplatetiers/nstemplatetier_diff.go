@@ -0,0 +1,330 @@
+package nstemplatetiers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/host-operator/pkg/templates/assets"
+	commonTemplate "github.com/codeready-toolchain/toolchain-common/pkg/template"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ChangeType the type of change of an object or of a field between two revisions of a tier
+type ChangeType string
+
+const (
+	// Added the object or field only exists in the new revision
+	Added ChangeType = "added"
+	// Removed the object or field only exists in the old revision
+	Removed ChangeType = "removed"
+	// Modified the object or field exists in both revisions, with different values
+	Modified ChangeType = "modified"
+)
+
+// TierDiff the changes of the objects provisioned on the member clusters between two revisions of a tier
+type TierDiff struct {
+	From    []string     `json:"from"`
+	To      []string     `json:"to"`
+	Objects []ObjectDiff `json:"objects"`
+}
+
+// ObjectDiff the change of an object provisioned by a TierTemplate
+type ObjectDiff struct {
+	TemplateType string      `json:"templateType"`
+	APIVersion   string      `json:"apiVersion"`
+	Kind         string      `json:"kind"`
+	Namespace    string      `json:"namespace,omitempty"`
+	Name         string      `json:"name"`
+	Change       ChangeType  `json:"change"`
+	Fields       []FieldDiff `json:"fields,omitempty"`
+}
+
+// FieldDiff the change of a field of a modified object. The path of the field is in the `metadata.labels.name` or `rules[0].verbs` form
+type FieldDiff struct {
+	Path   string      `json:"path"`
+	Change ChangeType  `json:"change"`
+	Old    interface{} `json:"old,omitempty"`
+	New    interface{} `json:"new,omitempty"`
+}
+
+// IsEmpty returns `true` if there is no change between the two revisions
+func (d *TierDiff) IsEmpty() bool {
+	return len(d.Objects) == 0
+}
+
+// Text returns a human-readable representation of the diff
+func (d *TierDiff) Text() string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "--- %s\n", strings.Join(d.From, ", "))
+	fmt.Fprintf(b, "+++ %s\n", strings.Join(d.To, ", "))
+	if d.IsEmpty() {
+		b.WriteString("no changes\n")
+		return b.String()
+	}
+	for _, obj := range d.Objects {
+		name := obj.Name
+		if obj.Namespace != "" {
+			name = obj.Namespace + "/" + name
+		}
+		fmt.Fprintf(b, "%s %s %s (%s template, %s)\n", changeSymbol(obj.Change), obj.Kind, name, obj.TemplateType, obj.Change)
+		for _, field := range obj.Fields {
+			switch field.Change {
+			case Added:
+				fmt.Fprintf(b, "    + %s: %v\n", field.Path, field.New)
+			case Removed:
+				fmt.Fprintf(b, "    - %s: %v\n", field.Path, field.Old)
+			default:
+				fmt.Fprintf(b, "    ~ %s: %v -> %v\n", field.Path, field.Old, field.New)
+			}
+		}
+	}
+	return b.String()
+}
+
+func changeSymbol(change ChangeType) string {
+	switch change {
+	case Added:
+		return "+"
+	case Removed:
+		return "-"
+	default:
+		return "~"
+	}
+}
+
+// GenerateTierTemplates generates the TierTemplates of the given tier from the given assets (eg, the `deploy/templates/nstemplatetiers` bundle),
+// without creating any resource
+func GenerateTierTemplates(s *runtime.Scheme, namespace string, assets assets.Assets, tierName string) ([]*toolchainv1alpha1.TierTemplate, error) {
+	generator, err := newTierGenerator(s, nil, namespace, assets)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to generate the TierTemplates")
+	}
+	data, found := generator.templatesByTier[tierName]
+	if !found {
+		return nil, fmt.Errorf("no tier named '%s' in the templates", tierName)
+	}
+	return data.tierTemplates, nil
+}
+
+// GetTierTemplates returns the TierTemplates referenced by the given NSTemplateTier
+func GetTierTemplates(cl client.Client, tier *toolchainv1alpha1.NSTemplateTier) ([]*toolchainv1alpha1.TierTemplate, error) {
+	refs := make([]string, 0, len(tier.Spec.Namespaces)+1)
+	for _, ns := range tier.Spec.Namespaces {
+		refs = append(refs, ns.TemplateRef)
+	}
+	if tier.Spec.ClusterResources != nil {
+		refs = append(refs, tier.Spec.ClusterResources.TemplateRef)
+	}
+	tierTmpls := make([]*toolchainv1alpha1.TierTemplate, 0, len(refs))
+	for _, ref := range refs {
+		tierTmpl := &toolchainv1alpha1.TierTemplate{}
+		if err := cl.Get(context.TODO(), client.ObjectKey{Namespace: tier.Namespace, Name: ref}, tierTmpl); err != nil {
+			return nil, errors.Wrapf(err, "unable to get the '%s' TierTemplate", ref)
+		}
+		tierTmpls = append(tierTmpls, tierTmpl)
+	}
+	return tierTmpls, nil
+}
+
+// DiffTierTemplates processes the `from` and `to` TierTemplates with the given parameters and compares the resulting objects.
+// The TierTemplates are matched by type (`dev`, `stage`, `clusterresources`, etc.), so the objects of a TierTemplate whose type only exists
+// on one side are all added or removed. The parameters which are not provided and have no default value are set with representative values.
+func DiffTierTemplates(s *runtime.Scheme, from, to []*toolchainv1alpha1.TierTemplate, params map[string]string) (*TierDiff, error) {
+	diff := &TierDiff{
+		From:    []string{},
+		To:      []string{},
+		Objects: []ObjectDiff{},
+	}
+	fromByType := make(map[string]*toolchainv1alpha1.TierTemplate, len(from))
+	tmplTypes := []string{}
+	for _, tierTmpl := range from {
+		diff.From = append(diff.From, tierTmpl.Name)
+		fromByType[tierTmpl.Spec.Type] = tierTmpl
+		tmplTypes = append(tmplTypes, tierTmpl.Spec.Type)
+	}
+	toByType := make(map[string]*toolchainv1alpha1.TierTemplate, len(to))
+	for _, tierTmpl := range to {
+		diff.To = append(diff.To, tierTmpl.Name)
+		toByType[tierTmpl.Spec.Type] = tierTmpl
+		if _, found := fromByType[tierTmpl.Spec.Type]; !found {
+			tmplTypes = append(tmplTypes, tierTmpl.Spec.Type)
+		}
+	}
+	sort.Strings(tmplTypes)
+
+	processor := commonTemplate.NewProcessor(s)
+	for _, tmplType := range tmplTypes {
+		fromObjs, err := processTierTemplate(processor, fromByType[tmplType], params)
+		if err != nil {
+			return nil, err
+		}
+		toObjs, err := processTierTemplate(processor, toByType[tmplType], params)
+		if err != nil {
+			return nil, err
+		}
+		diff.Objects = append(diff.Objects, diffObjects(tmplType, fromObjs, toObjs)...)
+	}
+	return diff, nil
+}
+
+// processTierTemplate processes the given TierTemplate and returns the resulting objects indexed by kind, namespace and name.
+// Returns an empty map if the TierTemplate is nil.
+func processTierTemplate(processor commonTemplate.Processor, tierTmpl *toolchainv1alpha1.TierTemplate, params map[string]string) (map[objectKey]map[string]interface{}, error) {
+	objs := map[objectKey]map[string]interface{}{}
+	if tierTmpl == nil {
+		return objs, nil
+	}
+	values := map[string]string{}
+	for _, param := range tierTmpl.Spec.Template.Parameters {
+		if value, found := params[param.Name]; found {
+			values[param.Name] = value
+		} else if param.Value == "" {
+			// also covers the generated values, which would be different each time
+			values[param.Name] = sampleParameterValue(param.Name)
+		}
+	}
+	processed, err := processor.Process(tierTmpl.Spec.Template.DeepCopy(), values)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to process the '%s' TierTemplate", tierTmpl.Name)
+	}
+	for _, obj := range processed {
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to convert an object of the '%s' TierTemplate", tierTmpl.Name)
+		}
+		gvk := obj.GetObjectKind().GroupVersionKind()
+		objs[objectKey{
+			apiVersion: gvk.GroupVersion().String(),
+			kind:       gvk.Kind,
+			namespace:  obj.GetNamespace(),
+			name:       obj.GetName(),
+		}] = content
+	}
+	return objs, nil
+}
+
+// sampleParameterValue returns a representative value for the parameter with the given name
+func sampleParameterValue(name string) string {
+	if value, found := sampleParameters[name]; found {
+		return value
+	}
+	return strings.ReplaceAll(strings.ToLower(name), "_", "-")
+}
+
+type objectKey struct {
+	apiVersion string
+	kind       string
+	namespace  string
+	name       string
+}
+
+func (k objectKey) String() string {
+	return strings.Join([]string{k.kind, k.namespace, k.name, k.apiVersion}, "/")
+}
+
+// diffObjects compares the objects processed from the TierTemplates of the given type
+func diffObjects(tmplType string, from, to map[objectKey]map[string]interface{}) []ObjectDiff {
+	keys := make([]objectKey, 0, len(from)+len(to))
+	for key := range from {
+		keys = append(keys, key)
+	}
+	for key := range to {
+		if _, found := from[key]; !found {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+	var diffs []ObjectDiff
+	for _, key := range keys {
+		objDiff := ObjectDiff{
+			TemplateType: tmplType,
+			APIVersion:   key.apiVersion,
+			Kind:         key.kind,
+			Namespace:    key.namespace,
+			Name:         key.name,
+		}
+		fromObj, inFrom := from[key]
+		toObj, inTo := to[key]
+		switch {
+		case !inFrom:
+			objDiff.Change = Added
+		case !inTo:
+			objDiff.Change = Removed
+		default:
+			objDiff.Fields = diffFields("", fromObj, toObj)
+			if len(objDiff.Fields) == 0 {
+				continue
+			}
+			objDiff.Change = Modified
+		}
+		diffs = append(diffs, objDiff)
+	}
+	return diffs
+}
+
+// diffFields recursively compares the given values, and returns the changes of the leaf fields (or of the whole lists
+// and maps which only exist on one side)
+func diffFields(path string, from, to interface{}) []FieldDiff {
+	switch fromValue := from.(type) {
+	case map[string]interface{}:
+		if toValue, ok := to.(map[string]interface{}); ok {
+			keys := make([]string, 0, len(fromValue)+len(toValue))
+			for key := range fromValue {
+				keys = append(keys, key)
+			}
+			for key := range toValue {
+				if _, found := fromValue[key]; !found {
+					keys = append(keys, key)
+				}
+			}
+			sort.Strings(keys)
+			var diffs []FieldDiff
+			for _, key := range keys {
+				fieldPath := key
+				if path != "" {
+					fieldPath = path + "." + key
+				}
+				f, inFrom := fromValue[key]
+				t, inTo := toValue[key]
+				switch {
+				case !inFrom:
+					diffs = append(diffs, FieldDiff{Path: fieldPath, Change: Added, New: t})
+				case !inTo:
+					diffs = append(diffs, FieldDiff{Path: fieldPath, Change: Removed, Old: f})
+				default:
+					diffs = append(diffs, diffFields(fieldPath, f, t)...)
+				}
+			}
+			return diffs
+		}
+	case []interface{}:
+		if toValue, ok := to.([]interface{}); ok {
+			var diffs []FieldDiff
+			for i := 0; i < len(fromValue) || i < len(toValue); i++ {
+				itemPath := fmt.Sprintf("%s[%d]", path, i)
+				switch {
+				case i >= len(fromValue):
+					diffs = append(diffs, FieldDiff{Path: itemPath, Change: Added, New: toValue[i]})
+				case i >= len(toValue):
+					diffs = append(diffs, FieldDiff{Path: itemPath, Change: Removed, Old: fromValue[i]})
+				default:
+					diffs = append(diffs, diffFields(itemPath, fromValue[i], toValue[i])...)
+				}
+			}
+			return diffs
+		}
+	}
+	if reflect.DeepEqual(from, to) {
+		return nil
+	}
+	return []FieldDiff{{Path: path, Change: Modified, Old: from, New: to}}
+}
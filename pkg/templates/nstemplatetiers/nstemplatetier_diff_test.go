@@ -0,0 +1,207 @@
+package nstemplatetiers_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/host-operator/pkg/apis"
+	"github.com/codeready-toolchain/host-operator/pkg/templates/assets"
+	"github.com/codeready-toolchain/host-operator/pkg/templates/nstemplatetiers"
+	testsupport "github.com/codeready-toolchain/toolchain-common/pkg/test"
+
+	templatev1 "github.com/openshift/api/template/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+func TestDiffTierTemplates(t *testing.T) {
+
+	s := scheme.Scheme
+	err := apis.AddToScheme(s)
+	require.NoError(t, err)
+
+	t.Run("no changes between the templates on disk and the embedded templates", func(t *testing.T) {
+		// given
+		dirAssets, err := assets.NewDirAssets("../../../deploy/templates/nstemplatetiers")
+		require.NoError(t, err)
+		embedded, err := nstemplatetiers.GenerateTierTemplates(s, testsupport.HostOperatorNs, assets.NewAssets(nstemplatetiers.AssetNames, nstemplatetiers.Asset), "base")
+		require.NoError(t, err)
+		onDisk, err := nstemplatetiers.GenerateTierTemplates(s, testsupport.HostOperatorNs, dirAssets, "base")
+		require.NoError(t, err)
+
+		// when
+		diff, err := nstemplatetiers.DiffTierTemplates(s, embedded, onDisk, nil)
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, diff.IsEmpty())
+		assert.Contains(t, diff.Text(), "no changes")
+	})
+
+	t.Run("changes between two revisions", func(t *testing.T) {
+		// given
+		from := []*toolchainv1alpha1.TierTemplate{
+			newTierTemplate("basic-dev-aaaaaaa", "dev",
+				`{"apiVersion":"rbac.authorization.k8s.io/v1","kind":"Role","metadata":{"name":"edit","namespace":"${USERNAME}-dev"},"rules":[{"apiGroups":[""],"resources":["pods"],"verbs":["get"]}]}`,
+				`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"settings","namespace":"${USERNAME}-dev","labels":{"owner":"${USERNAME}"}}}`),
+		}
+		to := []*toolchainv1alpha1.TierTemplate{
+			newTierTemplate("basic-dev-bbbbbbb", "dev",
+				`{"apiVersion":"rbac.authorization.k8s.io/v1","kind":"Role","metadata":{"name":"edit","namespace":"${USERNAME}-dev"},"rules":[{"apiGroups":[""],"resources":["pods"],"verbs":["get","list"]}]}`,
+				`{"apiVersion":"v1","kind":"LimitRange","metadata":{"name":"resource-limits","namespace":"${USERNAME}-dev"}}`),
+			newTierTemplate("basic-clusterresources-bbbbbbb", "clusterresources",
+				`{"apiVersion":"quota.openshift.io/v1","kind":"ClusterResourceQuota","metadata":{"name":"for-${USERNAME}"}}`),
+		}
+
+		// when
+		diff, err := nstemplatetiers.DiffTierTemplates(s, from, to, map[string]string{"USERNAME": "johnsmith"})
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []string{"basic-dev-aaaaaaa"}, diff.From)
+		assert.Equal(t, []string{"basic-dev-bbbbbbb", "basic-clusterresources-bbbbbbb"}, diff.To)
+		assert.Equal(t, []nstemplatetiers.ObjectDiff{
+			{
+				TemplateType: "clusterresources",
+				APIVersion:   "quota.openshift.io/v1",
+				Kind:         "ClusterResourceQuota",
+				Name:         "for-johnsmith",
+				Change:       nstemplatetiers.Added,
+			},
+			{
+				TemplateType: "dev",
+				APIVersion:   "v1",
+				Kind:         "ConfigMap",
+				Namespace:    "johnsmith-dev",
+				Name:         "settings",
+				Change:       nstemplatetiers.Removed,
+			},
+			{
+				TemplateType: "dev",
+				APIVersion:   "v1",
+				Kind:         "LimitRange",
+				Namespace:    "johnsmith-dev",
+				Name:         "resource-limits",
+				Change:       nstemplatetiers.Added,
+			},
+			{
+				TemplateType: "dev",
+				APIVersion:   "rbac.authorization.k8s.io/v1",
+				Kind:         "Role",
+				Namespace:    "johnsmith-dev",
+				Name:         "edit",
+				Change:       nstemplatetiers.Modified,
+				Fields: []nstemplatetiers.FieldDiff{
+					{
+						Path:   "rules[0].verbs[1]",
+						Change: nstemplatetiers.Added,
+						New:    "list",
+					},
+				},
+			},
+		}, diff.Objects)
+
+		t.Run("text", func(t *testing.T) {
+			assert.Equal(t, `--- basic-dev-aaaaaaa
++++ basic-dev-bbbbbbb, basic-clusterresources-bbbbbbb
++ ClusterResourceQuota for-johnsmith (clusterresources template, added)
+- ConfigMap johnsmith-dev/settings (dev template, removed)
++ LimitRange johnsmith-dev/resource-limits (dev template, added)
+~ Role johnsmith-dev/edit (dev template, modified)
+    + rules[0].verbs[1]: list
+`, diff.Text())
+		})
+
+		t.Run("json", func(t *testing.T) {
+			result, err := json.Marshal(diff)
+			require.NoError(t, err)
+			assert.Contains(t, string(result), `{"templateType":"dev","apiVersion":"rbac.authorization.k8s.io/v1","kind":"Role","namespace":"johnsmith-dev","name":"edit","change":"modified","fields":[{"path":"rules[0].verbs[1]","change":"added","new":"list"}]}`)
+		})
+	})
+
+	t.Run("modified fields", func(t *testing.T) {
+		// given
+		from := []*toolchainv1alpha1.TierTemplate{
+			newTierTemplate("basic-dev-aaaaaaa", "dev",
+				`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"settings","namespace":"${USERNAME}-dev","labels":{"owner":"${USERNAME}","tier":"basic"}},"data":{"timeout":"10"}}`),
+		}
+		to := []*toolchainv1alpha1.TierTemplate{
+			newTierTemplate("basic-dev-bbbbbbb", "dev",
+				`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"settings","namespace":"${USERNAME}-dev","labels":{"owner":"${USERNAME}"}},"data":{"timeout":"20","idle":"true"}}`),
+		}
+
+		// when
+		diff, err := nstemplatetiers.DiffTierTemplates(s, from, to, nil)
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, diff.Objects, 1)
+		assert.Equal(t, "johnsmith-dev", diff.Objects[0].Namespace) // representative value of the USERNAME parameter
+		assert.Equal(t, []nstemplatetiers.FieldDiff{
+			{
+				Path:   "data.idle",
+				Change: nstemplatetiers.Added,
+				New:    "true",
+			},
+			{
+				Path:   "data.timeout",
+				Change: nstemplatetiers.Modified,
+				Old:    "10",
+				New:    "20",
+			},
+			{
+				Path:   "metadata.labels.tier",
+				Change: nstemplatetiers.Removed,
+				Old:    "basic",
+			},
+		}, diff.Objects[0].Fields)
+	})
+
+	t.Run("failures", func(t *testing.T) {
+
+		t.Run("unknown tier", func(t *testing.T) {
+			// when
+			_, err := nstemplatetiers.GenerateTierTemplates(s, testsupport.HostOperatorNs, assets.NewAssets(nstemplatetiers.AssetNames, nstemplatetiers.Asset), "unknown")
+
+			// then
+			require.EqualError(t, err, "no tier named 'unknown' in the templates")
+		})
+
+		t.Run("missing templates directory", func(t *testing.T) {
+			// when
+			_, err := assets.NewDirAssets("/does/not/exist")
+
+			// then
+			require.Error(t, err)
+		})
+	})
+}
+
+func newTierTemplate(name, tmplType string, objects ...string) *toolchainv1alpha1.TierTemplate {
+	tmpl := templatev1.Template{
+		Parameters: []templatev1.Parameter{
+			{
+				Name:     "USERNAME",
+				Required: true,
+			},
+		},
+	}
+	for _, obj := range objects {
+		tmpl.Objects = append(tmpl.Objects, runtime.RawExtension{Raw: []byte(obj)})
+	}
+	return &toolchainv1alpha1.TierTemplate{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: testsupport.HostOperatorNs,
+			Name:      name,
+		},
+		Spec: toolchainv1alpha1.TierTemplateSpec{
+			TierName: "basic",
+			Type:     tmplType,
+			Template: tmpl,
+		},
+	}
+}
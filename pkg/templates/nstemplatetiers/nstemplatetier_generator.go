@@ -8,6 +8,8 @@ import (
 	"strings"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/host-operator/controllers/nstemplatetier"
+	tierutil "github.com/codeready-toolchain/host-operator/controllers/nstemplatetier/util"
 	"github.com/codeready-toolchain/host-operator/pkg/templates/assets"
 	commonclient "github.com/codeready-toolchain/toolchain-common/pkg/client"
 	commonTemplate "github.com/codeready-toolchain/toolchain-common/pkg/template"
@@ -52,6 +54,42 @@ func CreateOrUpdateResources(s *runtime.Scheme, client client.Client, namespace
 	return nil
 }
 
+// sampleParameters contains representative values of the parameters of the TierTemplates which have no default value
+var sampleParameters = map[string]string{
+	"USERNAME": "johnsmith",
+}
+
+// ValidateTemplates generates the TierTemplates and the NSTemplateTiers from the given assets and processes every TierTemplate with
+// its default parameters (and representative values for the parameters without default value), without creating any resource.
+// It returns the errors.
+func ValidateTemplates(s *runtime.Scheme, namespace string, assets assets.Assets) []error {
+	generator, err := newTierGenerator(s, nil, namespace, assets)
+	if err != nil {
+		return []error{errors.Wrap(err, "unable to generate the TierTemplates and NSTemplateTiers")}
+	}
+	tiers := make([]string, 0, len(generator.templatesByTier))
+	for tier := range generator.templatesByTier {
+		tiers = append(tiers, tier)
+	}
+	sort.Strings(tiers)
+	processor := commonTemplate.NewProcessor(s)
+	var validationErrs []error
+	for _, tier := range tiers {
+		for _, tierTmpl := range generator.templatesByTier[tier].tierTemplates {
+			params := map[string]string{}
+			for _, param := range tierTmpl.Spec.Template.Parameters {
+				if param.Value == "" && param.Generate == "" {
+					params[param.Name] = sampleParameters[param.Name]
+				}
+			}
+			if _, err := processor.Process(tierTmpl.Spec.Template.DeepCopy(), params); err != nil {
+				validationErrs = append(validationErrs, errors.Wrapf(err, "unable to process the '%s' TierTemplate", tierTmpl.Name))
+			}
+		}
+	}
+	return validationErrs
+}
+
 type tierGenerator struct {
 	client          client.Client
 	namespace       string
@@ -119,6 +157,9 @@ func newTierGenerator(s *runtime.Scheme, client client.Client, namespace string,
 //
 // Which defines that for creating baseextendedidling tier the base tier should be used and
 // the parameter IDLER_TIMEOUT_SECONDS should be set to 43200
+//
+// The tier may itself be based on another tier, and it may contain template files (eg. `ns_ml.yaml` or `cluster.yaml`)
+// which are added to or replace the templates of the tier it is based on
 type BasedOnTier struct {
 	Revision   string
 	From       string                 `json:"from"`
@@ -207,16 +248,6 @@ func loadTemplatesByTiers(assets assets.Assets) (map[string]*tierData, error) {
 		}
 	}
 
-	// check that none of the tiers uses combination of based_on_tier.yaml file together with any template file
-	for tier, tierData := range results {
-		if tierData.rawTemplates.basedOnTier != nil &&
-			(tierData.rawTemplates.clusterTemplate != nil ||
-				len(tierData.rawTemplates.namespaceTemplates) > 0 ||
-				tierData.rawTemplates.nsTemplateTier != nil) {
-			return nil, fmt.Errorf("the tier %s contains a mix of based_on_tier.yaml file together with a regular template file", tier)
-		}
-	}
-
 	return results, nil
 }
 
@@ -230,15 +261,11 @@ func (t *tierGenerator) initTierTemplates() error {
 	}
 	sort.Strings(tiers)
 	for _, tier := range tiers {
-		tierData := t.templatesByTier[tier]
-		basedOnTierFileRevision := ""
-		var parameters []templatev1.Parameter
-		if tierData.basedOnTier != nil {
-			parameters = tierData.basedOnTier.Parameters
-			basedOnTierFileRevision = tierData.rawTemplates.basedOnTier.revision
-			tierData = t.templatesByTier[tierData.basedOnTier.From]
+		resolved, err := t.resolveTier(tier)
+		if err != nil {
+			return err
 		}
-		tierTemplates, err := t.newTierTemplates(basedOnTierFileRevision, tierData, tier, parameters)
+		tierTemplates, err := t.newTierTemplates(tier, resolved)
 		if err != nil {
 			return err
 		}
@@ -248,28 +275,130 @@ func (t *tierGenerator) initTierTemplates() error {
 	return nil
 }
 
-func (t *tierGenerator) newTierTemplates(basedOnTierFileRevision string, tierData *tierData, tier string, parameters []templatev1.Parameter) ([]*toolchainv1alpha1.TierTemplate, error) {
+// resolvedTier the templates of a tier, after resolving its chain of `based_on_tier.yaml` files
+type resolvedTier struct {
+	namespaceTemplates map[string]resolvedTemplate // indexed by type ("dev", "code", "stage")
+	clusterTemplate    *resolvedTemplate
+	nsTemplateTier     *resolvedTemplate
+}
+
+// resolvedTemplate a template of a tier, which may be inherited from one of the tiers it is (directly or indirectly) based on
+type resolvedTemplate struct {
+	template
+	// the name of the tier containing the template file
+	sourceTier string
+	// the revision of the `based_on_tier.yaml` files of the tiers between the tier and the source tier, or an empty string if
+	// the template is not inherited
+	basedOnTierRevision string
+	// the parameters of the `based_on_tier.yaml` files of the tiers between the tier and the source tier, the parameters of the most
+	// derived tiers being last (so they take precedence)
+	parameters []templatev1.Parameter
+}
+
+// resolveTier resolves the chain of the `based_on_tier.yaml` files of the given tier: the namespace templates, cluster template and
+// tier template of a tier replace the ones of the tier it is based on, and so on. A template inherits the parameters of the
+// `based_on_tier.yaml` files of the tiers between the tier and the one containing the template file, the most derived tiers taking precedence.
+// Returns an error if a tier of the chain does not exist or if the chain contains a cycle.
+func (t *tierGenerator) resolveTier(tier string) (*resolvedTier, error) {
+	chain, err := t.basedOnTierChain(tier)
+	if err != nil {
+		return nil, err
+	}
+	resolved := &resolvedTier{
+		namespaceTemplates: map[string]resolvedTemplate{},
+	}
+	// from the root tier to the given tier, so that the templates of the most derived tiers replace the inherited ones
+	for i := len(chain) - 1; i >= 0; i-- {
+		level := chain[i]
+		var revisions []string
+		var parameters []templatev1.Parameter
+		for j := i; j >= 0; j-- {
+			if chain[j].basedOnTier != nil {
+				revisions = append([]string{chain[j].rawTemplates.basedOnTier.revision}, revisions...)
+				parameters = append(parameters, chain[j].basedOnTier.Parameters...)
+			}
+		}
+		newResolvedTemplate := func(tmpl template) resolvedTemplate {
+			return resolvedTemplate{
+				template:            tmpl,
+				sourceTier:          level.name,
+				basedOnTierRevision: combineRevisions(revisions),
+				parameters:          parameters,
+			}
+		}
+		for kind, tmpl := range level.rawTemplates.namespaceTemplates {
+			resolved.namespaceTemplates[kind] = newResolvedTemplate(tmpl)
+		}
+		if level.rawTemplates.clusterTemplate != nil {
+			tmpl := newResolvedTemplate(*level.rawTemplates.clusterTemplate)
+			resolved.clusterTemplate = &tmpl
+		}
+		if level.rawTemplates.nsTemplateTier != nil {
+			tmpl := newResolvedTemplate(*level.rawTemplates.nsTemplateTier)
+			resolved.nsTemplateTier = &tmpl
+		}
+	}
+	return resolved, nil
+}
+
+// basedOnTierChain returns the data of the given tier, followed by the data of the tier it is based on, and so on
+func (t *tierGenerator) basedOnTierChain(tier string) ([]*tierData, error) {
+	var chain []*tierData
+	names := []string{}
+	for name := tier; ; {
+		for _, previous := range names {
+			if previous == name {
+				return nil, fmt.Errorf("the tier %s has a cyclic chain of based_on_tier.yaml files: %s -> %s", tier, strings.Join(names, " -> "), name)
+			}
+		}
+		data, found := t.templatesByTier[name]
+		if !found {
+			return nil, fmt.Errorf("the tier %s is based on the tier %s which does not exist", names[len(names)-1], name)
+		}
+		chain = append(chain, data)
+		names = append(names, name)
+		if data.basedOnTier == nil {
+			return chain, nil
+		}
+		name = data.basedOnTier.From
+	}
+}
+
+// combineRevisions returns the single revision of the `based_on_tier.yaml` files of an inheritance chain, which is the revision of the file
+// for a single level of inheritance, or a revision computed from all the revisions (from the most to the least derived tier) otherwise
+func combineRevisions(revisions []string) string {
+	switch len(revisions) {
+	case 0:
+		return ""
+	case 1:
+		return revisions[0]
+	default:
+		return contentRevision([]byte(strings.Join(revisions, ",")))
+	}
+}
+
+func (t *tierGenerator) newTierTemplates(tier string, resolved *resolvedTier) ([]*toolchainv1alpha1.TierTemplate, error) {
 	decoder := serializer.NewCodecFactory(t.scheme).UniversalDeserializer()
 
 	// namespace templates
-	kinds := make([]string, 0, len(tierData.rawTemplates.namespaceTemplates))
-	for kind := range tierData.rawTemplates.namespaceTemplates {
+	kinds := make([]string, 0, len(resolved.namespaceTemplates))
+	for kind := range resolved.namespaceTemplates {
 		kinds = append(kinds, kind)
 	}
 
 	var tierTmpls []*toolchainv1alpha1.TierTemplate
 	sort.Strings(kinds)
 	for _, kind := range kinds {
-		tmpl := tierData.rawTemplates.namespaceTemplates[kind]
-		tierTmpl, err := t.newTierTemplate(decoder, basedOnTierFileRevision, tier, kind, tmpl, parameters)
+		tmpl := resolved.namespaceTemplates[kind]
+		tierTmpl, err := t.newTierTemplate(decoder, tmpl.basedOnTierRevision, tier, kind, tmpl.template, tmpl.parameters)
 		if err != nil {
 			return nil, err
 		}
 		tierTmpls = append(tierTmpls, tierTmpl)
 	}
 	// cluster resources templates
-	if tierData.rawTemplates.clusterTemplate != nil {
-		tierTmpl, err := t.newTierTemplate(decoder, basedOnTierFileRevision, tier, toolchainv1alpha1.ClusterResourcesTemplateType, *tierData.rawTemplates.clusterTemplate, parameters)
+	if tmpl := resolved.clusterTemplate; tmpl != nil {
+		tierTmpl, err := t.newTierTemplate(decoder, tmpl.basedOnTierRevision, tier, toolchainv1alpha1.ClusterResourcesTemplateType, tmpl.template, tmpl.parameters)
 		if err != nil {
 			return nil, err
 		}
@@ -344,17 +473,14 @@ func NewTierTemplateName(tier, kind, revision string) string {
 func (t *tierGenerator) initNSTemplateTiers() error {
 
 	for tierName, tierData := range t.templatesByTier {
-		nsTemplateTier := tierData.rawTemplates.nsTemplateTier
-		tierTemplates := tierData.tierTemplates
-		sourceTierName := tierName
-		var parameters []templatev1.Parameter
-		if tierData.basedOnTier != nil {
-			parameters = tierData.basedOnTier.Parameters
-			fromData := t.templatesByTier[tierData.basedOnTier.From]
-			nsTemplateTier = fromData.rawTemplates.nsTemplateTier
-			sourceTierName = fromData.name
+		resolved, err := t.resolveTier(tierName)
+		if err != nil {
+			return err
 		}
-		tmpl, err := t.newNSTemplateTier(sourceTierName, tierName, *nsTemplateTier, tierTemplates, parameters)
+		if resolved.nsTemplateTier == nil {
+			return fmt.Errorf("tier %s is missing a tier.yaml file", tierName)
+		}
+		tmpl, err := t.newNSTemplateTier(resolved.nsTemplateTier.sourceTier, tierName, resolved.nsTemplateTier.template, tierData.tierTemplates, resolved.nsTemplateTier.parameters)
 		if err != nil {
 			return err
 		}
@@ -388,6 +514,21 @@ func (t *tierGenerator) createNSTemplateTiers() error {
 		}
 		labels[toolchainv1alpha1.ProviderLabelKey] = toolchainv1alpha1.ProviderLabelValue
 
+		existing := &toolchainv1alpha1.NSTemplateTier{}
+		if err := t.client.Get(context.TODO(), client.ObjectKeyFromObject(tier), existing); err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "unable to get the '%s' NSTemplateTier", tierName)
+		} else if err == nil {
+			hash, err := tierutil.ComputeHashForNSTemplateTier(tier)
+			if err != nil {
+				return errors.Wrapf(err, "unable to compute the hash of the '%s' NSTemplateTier", tierName)
+			}
+			if existing.Annotations[nstemplatetier.RolledBackFromAnnotationKey] == hash {
+				log.Info("NSTemplateTier was rolled back from this revision, not applying it again", "name", tierName, "revision", hash)
+				continue
+			}
+			retainAnnotations(existing, tier)
+		}
+
 		updated, err := applyCl.ApplyObject(tier, commonclient.ForceUpdate(true))
 		if err != nil {
 			return errors.Wrapf(err, "unable to create or update the '%s' NSTemplateTier", tierName)
@@ -408,6 +549,25 @@ func (t *tierGenerator) createNSTemplateTiers() error {
 	return nil
 }
 
+// retainAnnotations copies the toolchain annotations of the existing NSTemplateTier (rollout state, revisions, rollout settings, etc.)
+// which are not set in the generated one, so they are not lost when the generated NSTemplateTier is applied
+func retainAnnotations(existing, tier *toolchainv1alpha1.NSTemplateTier) {
+	for key, value := range existing.Annotations {
+		if !strings.HasPrefix(key, toolchainv1alpha1.LabelKeyPrefix) ||
+			key == commonclient.LastAppliedConfigurationAnnotationKey ||
+			key == nstemplatetier.RolledBackFromAnnotationKey {
+			continue
+		}
+		if _, found := tier.Annotations[key]; found {
+			continue
+		}
+		if tier.Annotations == nil {
+			tier.Annotations = map[string]string{}
+		}
+		tier.Annotations[key] = value
+	}
+}
+
 // NewNSTemplateTier generates a complete NSTemplateTier object via Openshift Template based on the contents of tier.yaml and
 // by embedding the `<tier>-code.yaml`, `<tier>-dev.yaml` and `<tier>-stage.yaml` and cluster.yaml references.
 //
@@ -458,6 +618,48 @@ func (t *tierGenerator) newNSTemplateTier(sourceTierName, tierName string, nsTem
 	}
 	for i := range toolchainObjects {
 		toolchainObjects[i].SetName(strings.Replace(toolchainObjects[i].GetName(), sourceTierName, tierName, 1))
+		if obj, ok := toolchainObjects[i].(*unstructured.Unstructured); ok {
+			if err := addUnreferencedTemplates(obj, tierTemplates); err != nil {
+				return nil, errors.Wrapf(err, "unable to generate '%s' NSTemplateTier manifest", tierName)
+			}
+		}
 	}
 	return toolchainObjects, nil
 }
+
+// addUnreferencedTemplates adds the references to the TierTemplates which are not referenced by the given NSTemplateTier,
+// ie, when a tier based on another tier adds a namespace template or a cluster template, without overriding the `tier.yaml` file
+func addUnreferencedTemplates(tier *unstructured.Unstructured, tierTemplates []*toolchainv1alpha1.TierTemplate) error {
+	namespaces, _, err := unstructured.NestedSlice(tier.Object, "spec", "namespaces")
+	if err != nil {
+		return err
+	}
+	referenced := map[string]bool{}
+	for _, ns := range namespaces {
+		if ns, ok := ns.(map[string]interface{}); ok {
+			if ref, ok := ns["templateRef"].(string); ok {
+				referenced[ref] = true
+			}
+		}
+	}
+	added := false
+	for _, tierTmpl := range tierTemplates {
+		if referenced[tierTmpl.Name] {
+			continue
+		}
+		if tierTmpl.Spec.Type == toolchainv1alpha1.ClusterResourcesTemplateType {
+			if _, found, _ := unstructured.NestedString(tier.Object, "spec", "clusterResources", "templateRef"); !found {
+				if err := unstructured.SetNestedField(tier.Object, tierTmpl.Name, "spec", "clusterResources", "templateRef"); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		namespaces = append(namespaces, map[string]interface{}{"templateRef": tierTmpl.Name})
+		added = true
+	}
+	if !added {
+		return nil
+	}
+	return unstructured.SetNestedSlice(tier.Object, namespaces, "spec", "namespaces")
+}
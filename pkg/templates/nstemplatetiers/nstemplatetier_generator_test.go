@@ -3,11 +3,14 @@ package nstemplatetiers_test
 import (
 	"context"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/gofrs/uuid"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/host-operator/controllers/nstemplatetier"
+	tierutil "github.com/codeready-toolchain/host-operator/controllers/nstemplatetier/util"
 	"github.com/codeready-toolchain/host-operator/pkg/apis"
 	"github.com/codeready-toolchain/host-operator/pkg/templates/assets"
 	"github.com/codeready-toolchain/host-operator/pkg/templates/nstemplatetiers"
@@ -247,6 +250,104 @@ func TestCreateOrUpdateResources(t *testing.T) {
 		})
 	})
 
+	t.Run("rollback", func(t *testing.T) {
+
+		initialAssets := assets.NewAssets(testnstemplatetiers.AssetNames, testnstemplatetiers.Asset)
+		newAssets := assets.NewAssets(testnstemplatetiers.AssetNames, func(name string) ([]byte, error) {
+			if name == "metadata.yaml" {
+				return []byte(
+					`advanced/based_on_tier: "0001111"` + "\n" +
+						`base/cluster: "111111a"` + "\n" +
+						`base/ns_dev: "222222a"` + "\n" +
+						`base/ns_stage: "222222b"` + "\n" +
+						`nocluster/ns_dev: "222222e"` + "\n" +
+						`nocluster/ns_stage: "222222f"`), nil
+			}
+			return testnstemplatetiers.Asset(name)
+		})
+
+		t.Run("rolled back revision is not applied again", func(t *testing.T) {
+			// given
+			namespace := "host-operator" + uuid.Must(uuid.NewV4()).String()[:7]
+			clt := testsupport.NewFakeClient(t)
+			err := nstemplatetiers.CreateOrUpdateResources(s, clt, namespace, initialAssets)
+			require.NoError(t, err)
+			// simulate a rollback to a previous revision
+			tier := &toolchainv1alpha1.NSTemplateTier{}
+			err = clt.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: "advanced"}, tier)
+			require.NoError(t, err)
+			hash, err := tierutil.ComputeHashForNSTemplateTier(tier)
+			require.NoError(t, err)
+			tier.Annotations[nstemplatetier.RolledBackFromAnnotationKey] = hash
+			tier.Spec.Namespaces[0].TemplateRef = "advanced-dev-previous"
+			err = clt.Update(context.TODO(), tier)
+			require.NoError(t, err)
+
+			// when
+			err = nstemplatetiers.CreateOrUpdateResources(s, clt, namespace, initialAssets)
+
+			// then
+			require.NoError(t, err)
+			tier = &toolchainv1alpha1.NSTemplateTier{}
+			err = clt.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: "advanced"}, tier)
+			require.NoError(t, err)
+			assert.Equal(t, "advanced-dev-previous", tier.Spec.Namespaces[0].TemplateRef) // still rolled back
+			assert.Equal(t, hash, tier.Annotations[nstemplatetier.RolledBackFromAnnotationKey])
+
+			t.Run("newer revision is applied", func(t *testing.T) {
+				// when
+				err = nstemplatetiers.CreateOrUpdateResources(s, clt, namespace, newAssets)
+
+				// then
+				require.NoError(t, err)
+				tier := &toolchainv1alpha1.NSTemplateTier{}
+				err = clt.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: "advanced"}, tier)
+				require.NoError(t, err)
+				assert.Equal(t, "advanced-dev-0001111-222222a", tier.Spec.Namespaces[0].TemplateRef)
+				assert.NotContains(t, tier.Annotations, nstemplatetier.RolledBackFromAnnotationKey)
+			})
+		})
+	})
+
+	t.Run("toolchain annotations are retained", func(t *testing.T) {
+		// given
+		newAssets := assets.NewAssets(testnstemplatetiers.AssetNames, func(name string) ([]byte, error) {
+			if name == "metadata.yaml" {
+				return []byte(
+					`advanced/based_on_tier: "0001111"` + "\n" +
+						`base/cluster: "111111a"` + "\n" +
+						`base/ns_dev: "222222a"` + "\n" +
+						`base/ns_stage: "222222b"` + "\n" +
+						`nocluster/ns_dev: "222222e"` + "\n" +
+						`nocluster/ns_stage: "222222f"`), nil
+			}
+			return testnstemplatetiers.Asset(name)
+		})
+		namespace := "host-operator" + uuid.Must(uuid.NewV4()).String()[:7]
+		clt := testsupport.NewFakeClient(t)
+		err := nstemplatetiers.CreateOrUpdateResources(s, clt, namespace, testassets)
+		require.NoError(t, err)
+		tier := &toolchainv1alpha1.NSTemplateTier{}
+		err = clt.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: "advanced"}, tier)
+		require.NoError(t, err)
+		tier.Annotations[nstemplatetier.RolloutStateAnnotationKey] = `{"hash":"abcd","stage":"full"}`
+		tier.Annotations["other"] = "value"
+		err = clt.Update(context.TODO(), tier)
+		require.NoError(t, err)
+
+		// when
+		err = nstemplatetiers.CreateOrUpdateResources(s, clt, namespace, newAssets)
+
+		// then
+		require.NoError(t, err)
+		tier = &toolchainv1alpha1.NSTemplateTier{}
+		err = clt.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: "advanced"}, tier)
+		require.NoError(t, err)
+		assert.Equal(t, "advanced-dev-0001111-222222a", tier.Spec.Namespaces[0].TemplateRef)
+		assert.Equal(t, `{"hash":"abcd","stage":"full"}`, tier.Annotations[nstemplatetier.RolloutStateAnnotationKey])
+		assert.NotContains(t, tier.Annotations, "other")
+	})
+
 	t.Run("failures", func(t *testing.T) {
 
 		namespace := "host-operator" + uuid.Must(uuid.NewV4()).String()[:7]
@@ -335,3 +436,63 @@ func TestCreateOrUpdateResources(t *testing.T) {
 		})
 	})
 }
+
+func TestValidateTemplates(t *testing.T) {
+
+	s := scheme.Scheme
+	err := apis.AddToScheme(s)
+	require.NoError(t, err)
+	logf.SetLogger(zap.New(zap.UseDevMode(true)))
+
+	t.Run("embedded templates are valid", func(t *testing.T) {
+		// when
+		validationErrs := nstemplatetiers.ValidateTemplates(s, "host-operator", assets.NewAssets(nstemplatetiers.AssetNames, nstemplatetiers.Asset))
+
+		// then
+		assert.Empty(t, validationErrs)
+	})
+
+	t.Run("test templates are valid", func(t *testing.T) {
+		// when
+		validationErrs := nstemplatetiers.ValidateTemplates(s, "host-operator", assets.NewAssets(testnstemplatetiers.AssetNames, testnstemplatetiers.Asset))
+
+		// then
+		assert.Empty(t, validationErrs)
+	})
+
+	t.Run("missing required parameter", func(t *testing.T) {
+		// given
+		testassets := assets.NewAssets(testnstemplatetiers.AssetNames, func(name string) ([]byte, error) {
+			content, err := testnstemplatetiers.Asset(name)
+			if err != nil || name != "base/ns_dev.yaml" {
+				return content, err
+			}
+			return []byte(strings.ReplaceAll(string(content), "USERNAME", "NICKNAME")), nil
+		})
+
+		// when
+		validationErrs := nstemplatetiers.ValidateTemplates(s, "host-operator", testassets)
+
+		// then the TierTemplate of the 'advanced' tier, which is based on the 'base' tier, is invalid too
+		require.Len(t, validationErrs, 2)
+		assert.EqualError(t, validationErrs[0], "unable to process the 'advanced-dev-abcd123-123456b' TierTemplate: unable to process template: template.parameters[0]: Required value: template.parameters[0]: parameter NICKNAME is required and must be specified")
+		assert.EqualError(t, validationErrs[1], "unable to process the 'base-dev-123456b-123456b' TierTemplate: unable to process template: template.parameters[0]: Required value: template.parameters[0]: parameter NICKNAME is required and must be specified")
+	})
+
+	t.Run("failed to read assets", func(t *testing.T) {
+		// given
+		fakeAssets := assets.NewAssets(testnstemplatetiers.AssetNames, func(name string) ([]byte, error) {
+			if name == "metadata.yaml" {
+				return testnstemplatetiers.Asset(name)
+			}
+			return nil, errors.Errorf("an error")
+		})
+
+		// when
+		validationErrs := nstemplatetiers.ValidateTemplates(s, "host-operator", fakeAssets)
+
+		// then
+		require.Len(t, validationErrs, 1)
+		assert.EqualError(t, validationErrs[0], "unable to generate the TierTemplates and NSTemplateTiers: unable to load templates: an error")
+	})
+}
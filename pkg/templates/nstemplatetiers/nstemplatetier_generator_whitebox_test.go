@@ -271,37 +271,190 @@ func TestLoadTemplatesByTiers(t *testing.T) {
 			assert.Contains(t, err.Error(), "unable to load templates: unknown scope for file 'advanced/foo.yaml'")
 		})
 
-		t.Run("should fail when tier contains a mix of based_on_tier.yaml file together with a regular template file", func(t *testing.T) {
+		t.Run("cyclic chain of based_on_tier.yaml files", func(t *testing.T) {
 			// given
 			s := scheme.Scheme
 			err := apis.AddToScheme(s)
 			require.NoError(t, err)
-			clt := test.NewFakeClient(t)
+			testassets := withExtraAssets(t, map[string]string{
+				"base/based_on_tier.yaml": "from: advanced\n",
+			}, "base/based_on_tier: 1111111\n")
 
-			for _, tmplName := range []string{"cluster.yaml", "ns_dev.yaml", "ns_stage.yaml", "tier.yaml"} {
-				t.Run("for template name "+tmplName, func(t *testing.T) {
-					// given
-					testassets := assets.NewAssets(func() []string {
-						return append(testnstemplatetiers.AssetNames(), fmt.Sprintf("advanced/%s", tmplName))
+			// when
+			_, err = newTierGenerator(s, nil, test.HostOperatorNs, testassets)
 
-					}, func(path string) (bytes []byte, e error) {
-						if path == "advanced/"+tmplName {
-							return []byte(""), nil
-						}
-						return testnstemplatetiers.Asset(path)
-					})
+			// then
+			require.EqualError(t, err, "the tier advanced has a cyclic chain of based_on_tier.yaml files: advanced -> base -> advanced")
+		})
 
-					// when
-					_, err := newTierGenerator(s, clt, test.HostOperatorNs, testassets)
+		t.Run("based on an unknown tier", func(t *testing.T) {
+			// given
+			s := scheme.Scheme
+			err := apis.AddToScheme(s)
+			require.NoError(t, err)
+			testassets := withExtraAssets(t, map[string]string{
+				"extended/based_on_tier.yaml": "from: unknown\n",
+			}, "extended/based_on_tier: 1111111\n")
 
-					// then
-					require.EqualError(t, err, "the tier advanced contains a mix of based_on_tier.yaml file together with a regular template file")
-				})
-			}
+			// when
+			_, err = newTierGenerator(s, nil, test.HostOperatorNs, testassets)
+
+			// then
+			require.EqualError(t, err, "the tier extended is based on the tier unknown which does not exist")
 		})
 	})
 }
 
+func TestBasedOnTierChain(t *testing.T) {
+
+	s := scheme.Scheme
+	err := apis.AddToScheme(s)
+	require.NoError(t, err)
+	// `extended` is based on `advanced` (itself based on `base`), and adds an `ml` namespace template,
+	// while `custom` is based on `extended` and overrides its cluster template
+	testassets := withExtraAssets(t, map[string]string{
+		"extended/based_on_tier.yaml": "from: advanced\nparameters:\n- name: CPU_LIMIT\n  value: 8000m\n",
+		"extended/ns_ml.yaml": `apiVersion: template.openshift.io/v1
+kind: Template
+metadata:
+  name: extended-ml
+objects:
+- apiVersion: v1
+  kind: Namespace
+  metadata:
+    name: ${USERNAME}-ml
+parameters:
+- name: USERNAME
+  required: true
+- name: CPU_LIMIT
+  value: 1000m
+`,
+		"custom/based_on_tier.yaml": "from: extended\nparameters:\n- name: CPU_LIMIT\n  value: 16000m\n",
+		"custom/cluster.yaml": `apiVersion: template.openshift.io/v1
+kind: Template
+metadata:
+  name: custom-cluster-resources
+objects: []
+parameters:
+- name: USERNAME
+  required: true
+- name: CPU_LIMIT
+  value: 2000m
+`,
+	}, "extended/based_on_tier: 1111111\nextended/ns_ml: 2222222\ncustom/based_on_tier: 3333333\ncustom/cluster: 4444444\n")
+
+	// when
+	generator, err := newTierGenerator(s, nil, test.HostOperatorNs, testassets)
+
+	// then
+	require.NoError(t, err)
+
+	t.Run("single level of inheritance is unchanged", func(t *testing.T) {
+		assert.Equal(t, []string{
+			"advanced-dev-abcd123-123456b",
+			"advanced-stage-abcd123-123456c",
+			"advanced-clusterresources-abcd123-654321a",
+		}, tierTemplateNames(generator.templatesByTier["advanced"].tierTemplates))
+	})
+
+	t.Run("two levels of inheritance with an additional namespace template", func(t *testing.T) {
+		// given
+		extendedRevision := combineRevisions([]string{"1111111", "abcd123"})
+		tierTmpls := generator.templatesByTier["extended"].tierTemplates
+
+		// then
+		require.Len(t, extendedRevision, 7)
+		assert.Equal(t, []string{
+			"extended-dev-" + extendedRevision + "-123456b",
+			"extended-ml-1111111-2222222",
+			"extended-stage-" + extendedRevision + "-123456c",
+			"extended-clusterresources-" + extendedRevision + "-654321a",
+		}, tierTemplateNames(tierTmpls))
+		assert.Equal(t, "8000m", parameterValue(t, tierTmpls[3], "CPU_LIMIT"))
+		assert.Equal(t, "8000m", parameterValue(t, tierTmpls[1], "CPU_LIMIT"))
+		tier := nsTemplateTierOf(t, generator, "extended")
+		assert.Equal(t, "extended", tier.Name)
+		assert.Equal(t, 0, tier.Spec.DeactivationTimeoutDays) // inherited from advanced
+		assert.Equal(t, []toolchainv1alpha1.NSTemplateTierNamespace{
+			{TemplateRef: "extended-dev-" + extendedRevision + "-123456b"},
+			{TemplateRef: "extended-stage-" + extendedRevision + "-123456c"},
+			{TemplateRef: "extended-ml-1111111-2222222"},
+		}, tier.Spec.Namespaces)
+		assert.Equal(t, "extended-clusterresources-"+extendedRevision+"-654321a", tier.Spec.ClusterResources.TemplateRef)
+	})
+
+	t.Run("three levels of inheritance with an overridden cluster template", func(t *testing.T) {
+		// given
+		customRevision := combineRevisions([]string{"3333333", "1111111", "abcd123"})
+		mlRevision := combineRevisions([]string{"3333333", "1111111"})
+		tierTmpls := generator.templatesByTier["custom"].tierTemplates
+
+		// then
+		assert.NotEqual(t, customRevision, mlRevision)
+		assert.Equal(t, []string{
+			"custom-dev-" + customRevision + "-123456b",
+			"custom-ml-" + mlRevision + "-2222222",
+			"custom-stage-" + customRevision + "-123456c",
+			"custom-clusterresources-3333333-4444444",
+		}, tierTemplateNames(tierTmpls))
+		assert.Equal(t, "16000m", parameterValue(t, tierTmpls[1], "CPU_LIMIT")) // the most derived tier takes precedence
+		assert.Equal(t, "16000m", parameterValue(t, tierTmpls[3], "CPU_LIMIT"))
+		tier := nsTemplateTierOf(t, generator, "custom")
+		assert.Equal(t, "custom", tier.Name)
+		assert.Len(t, tier.Spec.Namespaces, 3)
+		assert.Equal(t, "custom-clusterresources-3333333-4444444", tier.Spec.ClusterResources.TemplateRef)
+	})
+}
+
+// withExtraAssets returns the test assets along with the given files and metadata
+func withExtraAssets(t *testing.T, files map[string]string, metadata string) assets.Assets {
+	names := []string{"metadata.yaml"}
+	for name := range files {
+		names = append(names, name)
+	}
+	extra := assets.NewAssets(
+		func() []string {
+			return names
+		},
+		func(name string) ([]byte, error) {
+			if name == "metadata.yaml" {
+				return []byte(metadata), nil
+			}
+			return []byte(files[name]), nil
+		})
+	merged, err := MergeAssets(assets.NewAssets(testnstemplatetiers.AssetNames, testnstemplatetiers.Asset), extra)
+	require.NoError(t, err)
+	return merged
+}
+
+func tierTemplateNames(tierTmpls []*toolchainv1alpha1.TierTemplate) []string {
+	names := make([]string, len(tierTmpls))
+	for i, tierTmpl := range tierTmpls {
+		names[i] = tierTmpl.Name
+	}
+	return names
+}
+
+func parameterValue(t *testing.T, tierTmpl *toolchainv1alpha1.TierTemplate, name string) string {
+	for _, param := range tierTmpl.Spec.Template.Parameters {
+		if param.Name == name {
+			return param.Value
+		}
+	}
+	require.Failf(t, "missing parameter", "no parameter named '%s' in the '%s' TierTemplate", name, tierTmpl.Name)
+	return ""
+}
+
+func nsTemplateTierOf(t *testing.T, generator *tierGenerator, name string) *toolchainv1alpha1.NSTemplateTier {
+	objs := generator.templatesByTier[name].nstmplTierObjs
+	require.Len(t, objs, 1)
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(objs[0])
+	require.NoError(t, err)
+	tier := &toolchainv1alpha1.NSTemplateTier{}
+	require.NoError(t, runtime.DefaultUnstructuredConverter.FromUnstructured(content, tier))
+	return tier
+}
+
 func TestNewNSTemplateTier(t *testing.T) {
 
 	s := scheme.Scheme
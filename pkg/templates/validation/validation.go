@@ -0,0 +1,131 @@
+package validation
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/host-operator/controllers/notification"
+	"github.com/codeready-toolchain/host-operator/pkg/templates/assets"
+	"github.com/codeready-toolchain/host-operator/pkg/templates/notificationtemplates"
+	"github.com/codeready-toolchain/host-operator/pkg/templates/nstemplatetiers"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var log = logf.Log.WithName("template-validation")
+
+const (
+	// TemplatesValid is the type of the condition of the host operator status (in the ToolchainStatus) which reports
+	// the result of the validation of the templates
+	TemplatesValid toolchainv1alpha1.ConditionType = "TemplatesValid"
+
+	// AllTemplatesValidReason is the reason of the `TemplatesValid=True` condition
+	AllTemplatesValidReason = "AllTemplatesValid"
+	// InvalidTemplatesReason is the reason of the `TemplatesValid=False` condition when some embedded templates are invalid,
+	// in which case the host operator is not ready
+	InvalidTemplatesReason = "InvalidTemplates"
+	// InvalidTemplateOverridesReason is the reason of the `TemplatesValid=False` condition when only some ConfigMaps overriding
+	// the notification templates are invalid, in which case the embedded templates are used instead
+	InvalidTemplateOverridesReason = "InvalidTemplateOverrides"
+)
+
+// Result is the result of the validation of the templates.
+// The errors in the embedded notification and tier templates are fatal, ie, the host operator is not ready, whereas the errors
+// in the ConfigMaps overriding the notification templates are not.
+type Result struct {
+	lock         sync.RWMutex
+	fatalErrs    []error
+	overrideErrs []error
+}
+
+// ValidateTemplates parses and dry-renders all the embedded notification templates, and processes all the tier templates
+// contained in the given assets
+func ValidateTemplates(s *runtime.Scheme, namespace string, tierAssets assets.Assets) *Result {
+	var fatalErrs []error
+	templates, err := notificationtemplates.GetTemplates()
+	if err != nil {
+		fatalErrs = append(fatalErrs, err)
+	} else {
+		fatalErrs = append(fatalErrs, notification.ValidateTemplates(templates)...)
+	}
+	fatalErrs = append(fatalErrs, nstemplatetiers.ValidateTemplates(s, namespace, tierAssets)...)
+	for _, err := range fatalErrs {
+		log.Error(err, "invalid template")
+	}
+	return &Result{
+		fatalErrs: fatalErrs,
+	}
+}
+
+// ValidateTemplateOverrides validates the ConfigMaps in the given namespace which override the notification templates,
+// and replaces the errors of the previous validation of the overrides
+func (r *Result) ValidateTemplateOverrides(cl client.Client, namespace string) {
+	var overrideErrs []error
+	if templates, err := notificationtemplates.GetTemplates(); err == nil {
+		overrideErrs = notification.ValidateTemplateOverrides(cl, namespace, templates)
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.overrideErrs = overrideErrs
+}
+
+// FatalErrors returns the errors in the embedded templates
+func (r *Result) FatalErrors() []error {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.fatalErrs
+}
+
+// OverrideErrors returns the errors in the ConfigMaps overriding the notification templates
+func (r *Result) OverrideErrors() []error {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.overrideErrs
+}
+
+// Condition returns the `TemplatesValid` condition reporting the result of the validation
+func (r *Result) Condition() toolchainv1alpha1.Condition {
+	if fatalErrs := r.FatalErrors(); len(fatalErrs) > 0 {
+		return toolchainv1alpha1.Condition{
+			Type:    TemplatesValid,
+			Status:  corev1.ConditionFalse,
+			Reason:  InvalidTemplatesReason,
+			Message: join(append(append([]error{}, fatalErrs...), r.OverrideErrors()...)),
+		}
+	}
+	if overrideErrs := r.OverrideErrors(); len(overrideErrs) > 0 {
+		return toolchainv1alpha1.Condition{
+			Type:    TemplatesValid,
+			Status:  corev1.ConditionFalse,
+			Reason:  InvalidTemplateOverridesReason,
+			Message: join(overrideErrs),
+		}
+	}
+	return toolchainv1alpha1.Condition{
+		Type:   TemplatesValid,
+		Status: corev1.ConditionTrue,
+		Reason: AllTemplatesValidReason,
+	}
+}
+
+// Checker is a readiness check which fails if some embedded templates are invalid
+func (r *Result) Checker(_ *http.Request) error {
+	if fatalErrs := r.FatalErrors(); len(fatalErrs) > 0 {
+		return fmt.Errorf("%d invalid template(s): %s", len(fatalErrs), join(fatalErrs))
+	}
+	return nil
+}
+
+func join(errs []error) string {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
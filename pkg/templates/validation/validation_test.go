@@ -0,0 +1,97 @@
+package validation
+
+import (
+	"fmt"
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/host-operator/controllers/notification"
+	"github.com/codeready-toolchain/host-operator/pkg/apis"
+	"github.com/codeready-toolchain/host-operator/pkg/templates/assets"
+	testnstemplatetiers "github.com/codeready-toolchain/host-operator/test/templates/nstemplatetiers"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+func TestValidateTemplates(t *testing.T) {
+	// given
+	s := scheme.Scheme
+	err := apis.AddToScheme(s)
+	require.NoError(t, err)
+
+	t.Run("all templates valid", func(t *testing.T) {
+		// when
+		result := ValidateTemplates(s, test.HostOperatorNs, assets.NewAssets(testnstemplatetiers.AssetNames, testnstemplatetiers.Asset))
+
+		// then
+		assert.Empty(t, result.FatalErrors())
+		assert.NoError(t, result.Checker(nil))
+		assert.Equal(t, toolchainv1alpha1.Condition{
+			Type:   TemplatesValid,
+			Status: corev1.ConditionTrue,
+			Reason: AllTemplatesValidReason,
+		}, result.Condition())
+
+		t.Run("invalid template override", func(t *testing.T) {
+			// given
+			cl := test.NewFakeClient(t, &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "userdeactivated-override",
+					Namespace: test.HostOperatorNs,
+					Labels: map[string]string{
+						notification.TemplateOverrideLabelKey: "userdeactivated",
+					},
+				},
+				Data: map[string]string{
+					"subject.txt": "Goodbye",
+				},
+			})
+
+			// when
+			result.ValidateTemplateOverrides(cl, test.HostOperatorNs)
+
+			// then the host operator is still ready
+			assert.NoError(t, result.Checker(nil))
+			assert.Equal(t, toolchainv1alpha1.Condition{
+				Type:    TemplatesValid,
+				Status:  corev1.ConditionFalse,
+				Reason:  InvalidTemplateOverridesReason,
+				Message: "invalid override of the 'userdeactivated' notification template: the ConfigMap 'userdeactivated-override' must contain notification.html and subject.txt",
+			}, result.Condition())
+
+			t.Run("template override fixed", func(t *testing.T) {
+				// when
+				result.ValidateTemplateOverrides(test.NewFakeClient(t), test.HostOperatorNs)
+
+				// then
+				assert.Empty(t, result.OverrideErrors())
+				assert.Equal(t, corev1.ConditionTrue, result.Condition().Status)
+			})
+		})
+	})
+
+	t.Run("invalid tier templates", func(t *testing.T) {
+		// when
+		result := ValidateTemplates(s, test.HostOperatorNs, assets.NewAssets(testnstemplatetiers.AssetNames, func(name string) ([]byte, error) {
+			if name == "metadata.yaml" {
+				return testnstemplatetiers.Asset(name)
+			}
+			return nil, fmt.Errorf("an error")
+		}))
+
+		// then
+		require.Len(t, result.FatalErrors(), 1)
+		assert.EqualError(t, result.Checker(nil), "1 invalid template(s): unable to generate the TierTemplates and NSTemplateTiers: unable to load templates: an error")
+		assert.Equal(t, toolchainv1alpha1.Condition{
+			Type:    TemplatesValid,
+			Status:  corev1.ConditionFalse,
+			Reason:  InvalidTemplatesReason,
+			Message: "unable to generate the TierTemplates and NSTemplateTiers: unable to load templates: an error",
+		}, result.Condition())
+	})
+}
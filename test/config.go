@@ -0,0 +1,58 @@
+package test
+
+import (
+	"encoding/json"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/host-operator/controllers/toolchainconfig"
+)
+
+// HostConfigExtensionOption is a ToolchainConfig option which modifies the settings stored in the host config extension annotation
+type HostConfigExtensionOption func(*toolchainconfig.HostConfigExtension)
+
+// Apply applies the modification to the host config extension annotation of the given ToolchainConfig,
+// while retaining the (valid) settings that were already set in the annotation
+func (o HostConfigExtensionOption) Apply(config *toolchainv1alpha1.ToolchainConfig) {
+	ext := toolchainconfig.HostConfigExtension{}
+	if value, found := config.Annotations[toolchainconfig.HostConfigExtensionAnnotationKey]; found {
+		_ = json.Unmarshal([]byte(value), &ext)
+	}
+	o(&ext)
+	value, _ := json.Marshal(ext) // marshalling a struct with plain fields only can't fail
+	if config.Annotations == nil {
+		config.Annotations = map[string]string{}
+	}
+	config.Annotations[toolchainconfig.HostConfigExtensionAnnotationKey] = string(value)
+}
+
+// DeactivationThrottle sets the deactivation throttle in the host config extension
+func DeactivationThrottle(interval string, maxPerInterval int, perMemberCluster map[string]int) HostConfigExtensionOption {
+	return func(ext *toolchainconfig.HostConfigExtension) {
+		ext.Deactivation.Throttle.Interval = &interval
+		ext.Deactivation.Throttle.MaxDeactivationsPerInterval = &maxPerInterval
+		ext.Deactivation.Throttle.SpecificPerMemberCluster = perMemberCluster
+	}
+}
+
+// SpaceExpiringNotificationDays sets the number of days before the expiration of a Space when the bound users are notified
+func SpaceExpiringNotificationDays(days int) HostConfigExtensionOption {
+	return func(ext *toolchainconfig.HostConfigExtension) {
+		ext.Spaces.ExpiringNotificationDays = &days
+	}
+}
+
+// TierBundles sets the directory of the tier bundles loaded at runtime and the period at which they are checked for changes
+func TierBundles(directory, resyncPeriod string) HostConfigExtensionOption {
+	return func(ext *toolchainconfig.HostConfigExtension) {
+		ext.Tiers.Bundles.Directory = &directory
+		ext.Tiers.Bundles.ResyncPeriod = &resyncPeriod
+	}
+}
+
+// ToolchainStatusNotificationIntervals sets the intervals of the escalations and of the digests sent to the admins about the ToolchainStatus
+func ToolchainStatusNotificationIntervals(escalationInterval, digestInterval string) HostConfigExtensionOption {
+	return func(ext *toolchainconfig.HostConfigExtension) {
+		ext.ToolchainStatus.Notifications.EscalationInterval = &escalationInterval
+		ext.ToolchainStatus.Notifications.DigestInterval = &digestInterval
+	}
+}
@@ -0,0 +1,79 @@
+package masteruserrecord
+
+import (
+	"context"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	tierutil "github.com/codeready-toolchain/host-operator/controllers/nstemplatetier/util"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Assertion an assertion helper for a MasterUserRecord, which verifies the tier hash labels computed by the host operator
+// (the assertions provided by toolchain-common expect the legacy tier hash)
+type Assertion struct {
+	mur            *toolchainv1alpha1.MasterUserRecord
+	client         client.Client
+	namespacedName types.NamespacedName
+	t              test.T
+}
+
+func (a *Assertion) loadResource() error {
+	mur := &toolchainv1alpha1.MasterUserRecord{}
+	err := a.client.Get(context.TODO(), a.namespacedName, mur)
+	a.mur = mur
+	return err
+}
+
+// AssertThatMasterUserRecord helper func to begin with the assertions on a MasterUserRecord
+func AssertThatMasterUserRecord(t test.T, name string, client client.Client) *Assertion {
+	return &Assertion{
+		client:         client,
+		namespacedName: test.NamespacedName(test.HostOperatorNs, name),
+		t:              t,
+	}
+}
+
+// AllUserAccountsHaveTier verifies that all the user accounts have the templateRefs of the given tier,
+// and that the MasterUserRecord has the hash label of the tier
+func (a *Assertion) AllUserAccountsHaveTier(tier *toolchainv1alpha1.NSTemplateTier) *Assertion {
+	err := a.loadResource()
+	require.NoError(a.t, err)
+	for _, ua := range a.mur.Spec.UserAccounts {
+		a.userAccountHasTier(ua, tier)
+	}
+	return a.hasTierHashLabel(tier)
+}
+
+// UserAccountHasTier verifies that the user account on the given target cluster has the templateRefs of the given tier,
+// and that the MasterUserRecord has the hash label of the tier
+func (a *Assertion) UserAccountHasTier(targetCluster string, tier *toolchainv1alpha1.NSTemplateTier) *Assertion {
+	err := a.loadResource()
+	require.NoError(a.t, err)
+	found := false
+	for _, ua := range a.mur.Spec.UserAccounts {
+		if ua.TargetCluster == targetCluster {
+			a.userAccountHasTier(ua, tier)
+			found = true
+		}
+	}
+	assert.True(a.t, found, "no user account on the target cluster '%s'", targetCluster)
+	return a.hasTierHashLabel(tier)
+}
+
+func (a *Assertion) userAccountHasTier(ua toolchainv1alpha1.UserAccountEmbedded, tier *toolchainv1alpha1.NSTemplateTier) {
+	require.NotNil(a.t, ua.Spec.NSTemplateSet)
+	assert.Equal(a.t, tier.Name, ua.Spec.NSTemplateSet.TierName)
+	assert.True(a.t, tierutil.TierHashMatches(tier, *ua.Spec.NSTemplateSet), "templateRefs of the user account on '%s' do not match the tier", ua.TargetCluster)
+}
+
+func (a *Assertion) hasTierHashLabel(tier *toolchainv1alpha1.NSTemplateTier) *Assertion {
+	hash, err := tierutil.ComputeHashForNSTemplateTier(tier)
+	require.NoError(a.t, err)
+	assert.Equal(a.t, hash, a.mur.Labels[tierutil.TemplateTierHashLabelKey(tier.Name)])
+	return a
+}
@@ -71,3 +71,9 @@ func HasContext(key, expected string) Assert {
 		assert.Equal(t, expected, not.Spec.Context[key])
 	}
 }
+
+func HasAnnotation(key, expected string) Assert {
+	return func(t test.T, not toolchainv1alpha1.Notification) {
+		assert.Equal(t, expected, not.Annotations[key])
+	}
+}
@@ -77,3 +77,27 @@ func (a *Assertion) HasLatestUpdate(expected toolchainv1alpha1.NSTemplateTierHis
 
 	return a
 }
+
+// HasConditions verifies the conditions of the NSTemplateTier
+func (a *Assertion) HasConditions(expected ...toolchainv1alpha1.Condition) *Assertion {
+	err := a.loadResource()
+	require.NoError(a.t, err)
+	test.AssertConditionsMatch(a.t, a.tier.Status.Conditions, expected...)
+	return a
+}
+
+// HasNoConditions verifies that the NSTemplateTier has no condition
+func (a *Assertion) HasNoConditions() *Assertion {
+	err := a.loadResource()
+	require.NoError(a.t, err)
+	assert.Empty(a.t, a.tier.Status.Conditions)
+	return a
+}
+
+// HasAnnotation verifies that the NSTemplateTier has the given annotation
+func (a *Assertion) HasAnnotation(key, value string) *Assertion {
+	err := a.loadResource()
+	require.NoError(a.t, err)
+	assert.Equal(a.t, value, a.tier.Annotations[key])
+	return a
+}
@@ -1,13 +1,16 @@
 package nstemplatetier
 
 import (
+	"encoding/json"
 	"testing"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
 	tierutil "github.com/codeready-toolchain/host-operator/controllers/nstemplatetier/util"
 
+	templatev1 "github.com/openshift/api/template/v1"
 	"github.com/stretchr/testify/require"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 // PreviousBasicTemplates previous templates for the "basic" tier
@@ -114,6 +117,47 @@ func WithCurrentUpdateInProgress() TierOption {
 	}
 }
 
+// WithAnnotation sets the given annotation on the NSTemplateTier
+func WithAnnotation(key, value string) TierOption {
+	return func(tier *toolchainv1alpha1.NSTemplateTier) {
+		if tier.Annotations == nil {
+			tier.Annotations = map[string]string{}
+		}
+		tier.Annotations[key] = value
+	}
+}
+
+// WithCondition adds the given condition in the NSTemplateTier status
+func WithCondition(c toolchainv1alpha1.Condition) TierOption {
+	return func(tier *toolchainv1alpha1.NSTemplateTier) {
+		tier.Status.Conditions = append(tier.Status.Conditions, c)
+	}
+}
+
+// RevisionsOf returns the value of the revisions annotation of an NSTemplateTier with the template refs of the given tiers
+func RevisionsOf(t *testing.T, tiers ...*toolchainv1alpha1.NSTemplateTier) string {
+	type revision struct {
+		Namespaces       []string `json:"namespaces"`
+		ClusterResources string   `json:"clusterResources,omitempty"`
+	}
+	revisions := map[string]revision{}
+	for _, tier := range tiers {
+		r := revision{}
+		for _, ns := range tier.Spec.Namespaces {
+			r.Namespaces = append(r.Namespaces, ns.TemplateRef)
+		}
+		if tier.Spec.ClusterResources != nil {
+			r.ClusterResources = tier.Spec.ClusterResources.TemplateRef
+		}
+		hash, err := tierutil.ComputeHashForNSTemplateTier(tier)
+		require.NoError(t, err)
+		revisions[hash] = r
+	}
+	value, err := json.Marshal(revisions)
+	require.NoError(t, err)
+	return string(value)
+}
+
 // OtherTier returns an "other" NSTemplateTier
 func OtherTier() *toolchainv1alpha1.NSTemplateTier {
 	return &toolchainv1alpha1.NSTemplateTier{
@@ -166,3 +210,33 @@ func TierWithoutDeactivationTimeout() *toolchainv1alpha1.NSTemplateTier {
 		},
 	}
 }
+
+// TierTemplatesFor returns the TierTemplates referenced by the given tier. As in the `base` tier, all the templates declare the `USERNAME`
+// parameter, and the cluster resources template also declares the `MEMORY_LIMIT` parameter
+func TierTemplatesFor(tier *toolchainv1alpha1.NSTemplateTier) []runtime.Object {
+	tierTemplates := []runtime.Object{}
+	for _, ns := range tier.Spec.Namespaces {
+		tierTemplates = append(tierTemplates, newTierTemplate(tier, ns.TemplateRef, templatev1.Parameter{Name: "USERNAME", Required: true}))
+	}
+	if tier.Spec.ClusterResources != nil {
+		tierTemplates = append(tierTemplates, newTierTemplate(tier, tier.Spec.ClusterResources.TemplateRef,
+			templatev1.Parameter{Name: "USERNAME", Required: true},
+			templatev1.Parameter{Name: "MEMORY_LIMIT", Value: "7Gi"}))
+	}
+	return tierTemplates
+}
+
+func newTierTemplate(tier *toolchainv1alpha1.NSTemplateTier, ref string, params ...templatev1.Parameter) *toolchainv1alpha1.TierTemplate {
+	return &toolchainv1alpha1.TierTemplate{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: tier.Namespace,
+			Name:      ref,
+		},
+		Spec: toolchainv1alpha1.TierTemplateSpec{
+			TierName: tier.Name,
+			Template: templatev1.Template{
+				Parameters: params,
+			},
+		},
+	}
+}
@@ -82,6 +82,24 @@ func WithStateLabel(stateValue string) Option {
 	}
 }
 
+func WithLabel(key, value string) Option {
+	return func(space *toolchainv1alpha1.Space) {
+		if space.Labels == nil {
+			space.Labels = map[string]string{}
+		}
+		space.Labels[key] = value
+	}
+}
+
+func WithAnnotation(key, value string) Option {
+	return func(space *toolchainv1alpha1.Space) {
+		if space.Annotations == nil {
+			space.Annotations = map[string]string{}
+		}
+		space.Annotations[key] = value
+	}
+}
+
 func CreatedBefore(before time.Duration) Option {
 	return func(space *toolchainv1alpha1.Space) {
 		space.ObjectMeta.CreationTimestamp = metav1.Time{Time: time.Now().Add(-before)}
@@ -96,6 +96,14 @@ func (a *Assertion) HasStateLabel(stateValue string) *Assertion {
 	return a
 }
 
+func (a *Assertion) HasLabel(key, value string) *Assertion {
+	err := a.loadResource()
+	require.NoError(a.t, err)
+	require.NotNil(a.t, a.space.Labels)
+	assert.Equal(a.t, value, a.space.Labels[key])
+	return a
+}
+
 func (a *Assertion) DoesNotHaveLabel(key string) *Assertion {
 	err := a.loadResource()
 	require.NoError(a.t, err)
@@ -103,6 +111,21 @@ func (a *Assertion) DoesNotHaveLabel(key string) *Assertion {
 	return a
 }
 
+func (a *Assertion) HasAnnotation(key, value string) *Assertion {
+	err := a.loadResource()
+	require.NoError(a.t, err)
+	require.NotNil(a.t, a.space.Annotations)
+	assert.Equal(a.t, value, a.space.Annotations[key])
+	return a
+}
+
+func (a *Assertion) DoesNotHaveAnnotation(key string) *Assertion {
+	err := a.loadResource()
+	require.NoError(a.t, err)
+	require.NotContains(a.t, a.space.Annotations, key)
+	return a
+}
+
 func (a *Assertion) HasNoSpecTargetCluster() *Assertion {
 	err := a.loadResource()
 	require.NoError(a.t, err)
@@ -238,3 +261,28 @@ func TerminatingFailed(msg string) toolchainv1alpha1.Condition {
 		Message: msg,
 	}
 }
+
+func Hibernating() toolchainv1alpha1.Condition {
+	return toolchainv1alpha1.Condition{
+		Type:   toolchainv1alpha1.ConditionReady,
+		Status: corev1.ConditionFalse,
+		Reason: "Hibernating",
+	}
+}
+
+func Hibernated() toolchainv1alpha1.Condition {
+	return toolchainv1alpha1.Condition{
+		Type:   toolchainv1alpha1.ConditionReady,
+		Status: corev1.ConditionFalse,
+		Reason: "Hibernated",
+	}
+}
+
+func UnableToHibernate(msg string) toolchainv1alpha1.Condition {
+	return toolchainv1alpha1.Condition{
+		Type:    toolchainv1alpha1.ConditionReady,
+		Status:  corev1.ConditionFalse,
+		Reason:  "UnableToHibernate",
+		Message: msg,
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	tierutil "github.com/codeready-toolchain/host-operator/controllers/nstemplatetier/util"
 	"github.com/codeready-toolchain/toolchain-common/pkg/test"
 
 	coputil "github.com/redhat-cop/operator-utils/pkg/util"
@@ -143,3 +144,15 @@ var _ Option = Condition(toolchainv1alpha1.Condition{})
 func (c Condition) applyToTemplateUpdateRequest(r *toolchainv1alpha1.TemplateUpdateRequest) {
 	r.Status.Conditions = append(r.Status.Conditions, toolchainv1alpha1.Condition(c))
 }
+
+// MigrateFrom sets the name of the deprecated tier from which the MasterUserRecord or Space is migrated
+type MigrateFrom string
+
+var _ Option = MigrateFrom("")
+
+func (m MigrateFrom) applyToTemplateUpdateRequest(r *toolchainv1alpha1.TemplateUpdateRequest) {
+	if r.Annotations == nil {
+		r.Annotations = map[string]string{}
+	}
+	r.Annotations[tierutil.MigrateFromAnnotationKey] = string(m)
+}